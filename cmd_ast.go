@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runAST implements "baryon ast [-o file.json] <file.bala>".
+func runAST(args []string) error {
+	fs := flag.NewFlagSet("ast", flag.ExitOnError)
+	outputFile := fs.String("o", "", "Write the JSON to a file instead of standard output")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one input file is required")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	program, err := parseProgram(string(data))
+	if err != nil {
+		return fmt.Errorf("parsing error: %w", err)
+	}
+
+	out, err := json.MarshalIndent(program, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding AST: %w", err)
+	}
+	out = append(out, '\n')
+
+	if *outputFile == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return writeFileSafely(*outputFile, out)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/bench"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+)
+
+// runBench implements "baryon bench [-targets all] [-runs 20] <file.bala
+// or directory>...", which runs each stage on a corpus of programs and
+// reports its time and allocations. The .bala files of directories are
+// part of the corpus.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	targetList := fs.String("targets", "all", "Comma-separated targets to transpile to, or all")
+	runs := fs.Int("runs", 20, "Number of runs of each stage on the corpus")
+	asJSON := fs.Bool("json", false, "Print the results as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one input file or directory is required")
+	}
+	corpus := []bench.Source{}
+	for _, input := range fs.Args() {
+		paths := []string{input}
+		if info, err := os.Stat(input); err == nil && info.IsDir() {
+			if paths, err = filepath.Glob(filepath.Join(input, "*.bala")); err != nil {
+				return err
+			}
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("reading file: %w", err)
+			}
+			corpus = append(corpus, bench.Source{Path: path, Code: string(data)})
+		}
+	}
+	if len(corpus) == 0 {
+		return fmt.Errorf("no .bala files in %v", fs.Args())
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if err := registerPlugins(cfg); err != nil {
+		return err
+	}
+	targets, err := parseTargets(*targetList)
+	if err != nil {
+		return err
+	}
+	stages, err := bench.Stages(corpus, targets)
+	if err != nil {
+		return err
+	}
+
+	results := []bench.Result{}
+	for _, stage := range stages {
+		result, err := bench.Measure(stage, *runs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", stage.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding results: %w", err)
+		}
+		_, err = os.Stdout.Write(append(out, '\n'))
+		return err
+	}
+	fmt.Printf("%d program(s), %d run(s) per stage\n", len(corpus), *runs)
+	return bench.WriteTable(os.Stdout, results)
+}
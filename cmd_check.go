@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// runCheck implements "baryon check [-targets r,galaxy] <file.bala>".
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	inputFile := fs.String("input", "", "Input Baryon file (.bala)")
+	targetsFlag := fs.String("targets", "",
+		fmt.Sprintf("Comma-separated targets to report compatibility for, or \"all\": %s",
+			strings.Join(transpiler.GetTranspilerNames(), ", ")))
+	verifyImages := fs.Bool("verify-images", false, "Check that container images exist in their registry")
+	fs.Parse(args)
+
+	input := *inputFile
+	if input == "" && fs.NArg() > 0 {
+		input = fs.Arg(0)
+	}
+	if input == "" {
+		fs.Usage()
+		return fmt.Errorf("input file is required")
+	}
+
+	program, err := loadProgram(input, analysisOptions{verifyImages: *verifyImages})
+	if err != nil {
+		return err
+	}
+	// Targets are resolved after loading, which registers the plugins
+	targets, err := parseTargets(*targetsFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Println("✅ Syntax check passed")
+	if len(targets) == 0 {
+		fmt.Print(program.String())
+		return nil
+	}
+
+	fmt.Println("Compatibility:")
+	incompatible := []string{}
+	for _, target := range targets {
+		issues, err := transpiler.CheckCompatibility(target, program)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %s:\n", target)
+		if len(issues) == 0 {
+			if descriptor, _ := transpiler.GetTranspiler(target); descriptor.Capabilities == nil {
+				fmt.Println("    provided by a plugin, compatibility is unknown")
+				continue
+			}
+			fmt.Println("    fully supported")
+		}
+		unsupported := false
+		for _, issue := range issues {
+			fmt.Printf("    %s\n", issue)
+			if issue.Support == transpiler.Unsupported {
+				unsupported = true
+			}
+		}
+		if unsupported {
+			incompatible = append(incompatible, target)
+		}
+	}
+	if len(incompatible) > 0 {
+		return fmt.Errorf("program uses features unsupported by: %s", strings.Join(incompatible, ", "))
+	}
+	return nil
+}
+
+// parseTargets splits a comma-separated target list, expanding "all" to
+// every registered target.
+func parseTargets(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	if value == "all" {
+		return transpiler.GetTranspilerNames(), nil
+	}
+	targets := []string{}
+	for _, target := range strings.Split(value, ",") {
+		target = strings.ToLower(strings.TrimSpace(target))
+		if target == "" {
+			continue
+		}
+		if _, err := transpiler.GetTranspiler(target); err != nil {
+			return nil, fmt.Errorf("unsupported target '%s'", target)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
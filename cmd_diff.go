@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/diff"
+)
+
+// diffReport is the output of "baryon diff -json".
+type diffReport struct {
+	Changes []diff.Change `json:"changes"`
+	Bump    string        `json:"bump,omitempty"`
+}
+
+// runDiff implements "baryon diff [-json] <old.bala> <new.bala>", which
+// reports the interface changes between two versions of a program and the
+// version bump they call for.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("exactly two input files are required")
+	}
+	old, err := loadProgram(fs.Arg(0), analysisOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	new, err := loadProgram(fs.Arg(1), analysisOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+
+	changes := diff.Programs(old, new)
+	bump, changed := diff.Bump(changes)
+
+	if *asJSON {
+		report := diffReport{Changes: changes}
+		if changed {
+			report.Bump = bump.String()
+		}
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding report: %w", err)
+		}
+		_, err = os.Stdout.Write(append(out, '\n'))
+		return err
+	}
+
+	if !changed {
+		fmt.Println("No changes")
+		return nil
+	}
+	for _, c := range changes {
+		marker := "~"
+		switch c.Kind {
+		case diff.Added:
+			marker = "+"
+		case diff.Removed:
+			marker = "-"
+		}
+		fmt.Printf("%s %s\n", marker, c)
+	}
+	fmt.Printf("\nSuggested version bump: %s\n", bump)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+// runFmt implements "baryon fmt [-fix] [-w] <file.bala>", which prints a
+// program in canonical form.
+func runFmt(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "Rewrite deprecated constructs to their current form")
+	write := fs.Bool("w", false, "Write the result to the file instead of standard output")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one input file is required")
+	}
+	input := fs.Arg(0)
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	source := string(data)
+	program, err := parser.New(lexer.New(source)).ParseProgram()
+	if err != nil {
+		return fmt.Errorf("parsing error: %w", err)
+	}
+
+	if *fix && len(program.Deprecations) > 0 {
+		source = parser.ApplyFixes(source, program.Deprecations)
+		// The rewrite must keep the program valid
+		if _, err := parseProgram(source); err != nil {
+			return fmt.Errorf("fixed program does not parse: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Fixed %d deprecated construct(s)\n", len(program.Deprecations))
+	}
+
+	source, err = parser.Format(source)
+	if err != nil {
+		return fmt.Errorf("formatting: %w", err)
+	}
+
+	if !*write {
+		fmt.Print(source)
+		return nil
+	}
+	if err := writeFileSafely(input, []byte(source)); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/registry"
+)
+
+// runGet implements "baryon get [-dir .] org/tool@version", which fetches
+// a program shared in a registry, with its lockfile, into a directory.
+// The registry is the -registry URL or the one of the [registry] table of
+// the baryon.toml of the directory.
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	url := fs.String("registry", "", "Base URL of the registry, overriding baryon.toml")
+	dir := fs.String("dir", ".", "Directory to write the files to")
+	force := fs.Bool("force", false, "Overwrite files with a different content")
+	refresh := fs.Bool("refresh", false, "Download the files even when they are cached")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one module, org/tool@version, is required")
+	}
+	module, err := registry.ParseModule(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(*dir)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if *url == "" {
+		*url = cfg.Registry.URL
+	}
+	if *url == "" {
+		return fmt.Errorf("no registry configured, set url in the [registry] table of %s or use -registry", config.FileName)
+	}
+	cache := cfg.Registry.Cache
+	if cache == "" {
+		if cache, err = registry.DefaultCache(); err != nil {
+			return fmt.Errorf("locating the cache: %w", err)
+		}
+	}
+
+	client := registry.NewClient(*url, cache, registryTimeout)
+	client.Refresh = *refresh
+	download, err := client.Get(module)
+	if err != nil {
+		return err
+	}
+
+	// Every file is read and checked before any is written
+	contents := make([][]byte, len(download.Files))
+	for i, file := range download.Files {
+		if contents[i], err = os.ReadFile(file.Path); err != nil {
+			return fmt.Errorf("reading cached %s: %w", file.Name, err)
+		}
+		target := filepath.Join(*dir, file.Name)
+		if existing, err := os.ReadFile(target); err == nil && !bytes.Equal(existing, contents[i]) && !*force {
+			return fmt.Errorf("%s already exists with a different content, use -force to overwrite it", target)
+		}
+	}
+	program, err := parseProgram(string(contents[0]))
+	if err != nil {
+		return fmt.Errorf("%s of %s: parsing error: %w", download.Files[0].Name, download.Module, err)
+	}
+	if program.Name != module.Tool {
+		fmt.Fprintf(os.Stderr, "Warning: %s defines the program '%s'\n", download.Module, program.Name)
+	}
+
+	names := []string{}
+	for i, file := range download.Files {
+		if err := writeFileSafely(filepath.Join(*dir, file.Name), contents[i]); err != nil {
+			return fmt.Errorf("writing %s: %w", file.Name, err)
+		}
+		names = append(names, file.Name)
+	}
+	source := "downloaded"
+	if download.Cached {
+		source = "cached"
+	}
+	fmt.Printf("Fetched %s (%s): %s\n", download.Module, source, strings.Join(names, ", "))
+	return nil
+}
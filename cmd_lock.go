@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lockfile"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/registry"
+)
+
+// runLock implements "baryon lock [-update | -audit] <file.bala>", which
+// pins the digests of the images of a program in its lockfile. Without
+// flags, the images that aren't locked yet are; -update resolves them all
+// again; -audit changes nothing and reports the images that drifted from
+// their locked digest or whose tag was yanked.
+func runLock(args []string) error {
+	fs := flag.NewFlagSet("lock", flag.ExitOnError)
+	update := fs.Bool("update", false, "Resolve the digest of every image again")
+	audit := fs.Bool("audit", false, "Report drifted, yanked, unlocked and stale images without changing the lockfile")
+	yankedFile := fs.String("yanked", "", "File listing yanked image tags, in addition to the ones of the registry")
+	url := fs.String("registry", "", "Base URL of the program registry publishing yanked tags, overriding baryon.toml")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one input file is required")
+	}
+	if *update && *audit {
+		return fmt.Errorf("-update and -audit are exclusive")
+	}
+	input := fs.Arg(0)
+	program, err := loadProgram(input, analysisOptions{})
+	if err != nil {
+		return err
+	}
+	lock, err := readLockfile(lockfile.Path(input))
+	if err != nil {
+		return err
+	}
+	resolve := image.NewRegistry(registryTimeout).Digest
+
+	if *audit {
+		yanked, err := loadYanked(filepath.Dir(input), *url, *yankedFile)
+		if err != nil {
+			return err
+		}
+		findings := lockfile.Audit(lock, program, resolve, yanked)
+		for _, finding := range findings {
+			fmt.Printf("%s: %s\n", finding.Kind, finding.Message)
+		}
+		if len(findings) > 0 {
+			return fmt.Errorf("%d image(s) need attention, run 'baryon lock -update' once they are reviewed", len(findings))
+		}
+		fmt.Printf("✅ %d image(s) match %s\n", len(lock.Entries), lockfile.Path(input))
+		return nil
+	}
+
+	changed, err := lockfile.Update(lock, program, resolve, *update)
+	if err != nil {
+		return err
+	}
+	if err := writeFileSafely(lockfile.Path(input), lock.Format(filepath.Base(input))); err != nil {
+		return fmt.Errorf("writing lockfile: %w", err)
+	}
+	for _, entry := range changed {
+		fmt.Printf("Locked %s to %s\n", entry.Image, entry.Digest)
+	}
+	fmt.Printf("%s: %d image(s), %d changed\n", lockfile.Path(input), len(lock.Entries), len(changed))
+	return nil
+}
+
+// readLockfile reads a lockfile, which is empty when it doesn't exist yet.
+func readLockfile(path string) (*lockfile.Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &lockfile.Lockfile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading lockfile: %w", err)
+	}
+	lock, err := lockfile.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// loadYanked reads the yanked tags of the program registry, the url or the
+// one of the configuration of dir, and of a local file.
+func loadYanked(dir, url, file string) (lockfile.Yanked, error) {
+	yanked := lockfile.Yanked{}
+	add := func(source string, data []byte) error {
+		list, err := lockfile.ParseYanked(data)
+		if err != nil {
+			return fmt.Errorf("yanked tags of %s: %w", source, err)
+		}
+		for tag, reason := range list {
+			yanked[tag] = reason
+		}
+		return nil
+	}
+
+	if url == "" {
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return nil, fmt.Errorf("loading configuration: %w", err)
+		}
+		url = cfg.Registry.URL
+	}
+	if url != "" {
+		data, err := registry.NewClient(url, "", registryTimeout).Yanked()
+		if err != nil {
+			return nil, fmt.Errorf("fetching yanked tags: %w", err)
+		}
+		if err := add(url, data); err != nil {
+			return nil, err
+		}
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading yanked tags: %w", err)
+		}
+		if err := add(file, data); err != nil {
+			return nil, err
+		}
+	}
+	return yanked, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// runMigrate implements "baryon migrate [-to 1.3] [-w] <file.bala or
+// directory>...", which rewrites the deprecated constructs of programs to
+// the current grammar and checks that they keep to the constructs of the
+// -to version. Without -w, it lists the rewrites; with it, the programs are
+// rewritten in place, formatted. The .bala files of directories are
+// migrated.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := fs.String("to", transpiler.LanguageVersion, "Language version the programs must keep to")
+	write := fs.Bool("w", false, "Rewrite the files instead of listing the rewrites")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("at least one input file or directory is required")
+	}
+	paths := []string{}
+	for _, input := range fs.Args() {
+		if info, err := os.Stat(input); err == nil && info.IsDir() {
+			files, err := filepath.Glob(filepath.Join(input, "*.bala"))
+			if err != nil {
+				return err
+			}
+			paths = append(paths, files...)
+			continue
+		}
+		paths = append(paths, input)
+	}
+
+	failed, rewritten := 0, 0
+	for _, path := range paths {
+		changed, err := migrateFile(path, *to, *write)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		if changed {
+			rewritten++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d program(s) can't be migrated to bala %s", failed, len(paths), *to)
+	}
+	switch {
+	case rewritten == 0:
+		fmt.Printf("✅ %d program(s) already follow bala %s\n", len(paths), *to)
+	case *write:
+		fmt.Printf("✅ Migrated %d of %d program(s) to bala %s\n", rewritten, len(paths), *to)
+	default:
+		fmt.Printf("%d of %d program(s) need migrating, run with -w to rewrite them\n", rewritten, len(paths))
+	}
+	return nil
+}
+
+// migrateFile migrates a program, reporting whether it changed. Programs
+// using constructs introduced after the version aren't rewritten.
+func migrateFile(path, version string, write bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("reading file: %w", err)
+	}
+	source, fixed, err := parser.Migrate(string(data))
+	if err != nil {
+		return false, err
+	}
+	program, err := parseProgram(source)
+	if err != nil {
+		return false, fmt.Errorf("parsing error: %w", err)
+	}
+	issues, err := transpiler.NewerFeatures(program, version)
+	if err != nil {
+		return false, err
+	}
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			fmt.Fprintf(os.Stderr, "%s: %s: %s\n", path, issue.Pos, issue.Note)
+		}
+		return false, fmt.Errorf("%d construct(s) introduced after bala %s", len(issues), version)
+	}
+	if len(fixed) == 0 {
+		return false, nil
+	}
+
+	if !write {
+		for _, deprecation := range fixed {
+			fmt.Printf("%s: %s: %s -> %s\n", path, deprecation.Pos, deprecation.Construct, deprecation.Replacement)
+		}
+		return true, nil
+	}
+	if source, err = parser.Format(source); err != nil {
+		return false, fmt.Errorf("formatting: %w", err)
+	}
+	if err := writeFileSafely(path, []byte(source)); err != nil {
+		return false, fmt.Errorf("writing output: %w", err)
+	}
+	fmt.Printf("Migrated %s: %d construct(s) rewritten\n", path, len(fixed))
+	return true, nil
+}
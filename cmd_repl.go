@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/repl"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// runRepl implements "baryon repl [-target python]", an interactive
+// session where S-expressions are parsed as they are entered, and the
+// program they make up is checked and transpiled, see package repl. The
+// lint rules, target options and plugins come from the baryon.toml of the
+// -config directory.
+func runRepl(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	configDir := fs.String("config", ".", "Directory of the baryon.toml configuration")
+	target := fs.String("target", "", "Target the program is transpiled to after each input")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configDir)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if err := registerPlugins(cfg); err != nil {
+		return err
+	}
+	session := repl.New(os.Stdout)
+	session.Rules, session.Options = cfg.Lint.Rules, cfg.Options
+	if *target != "" {
+		session.Target = strings.ToLower(*target)
+		if _, err := transpiler.GetTranspiler(session.Target); err != nil {
+			return err
+		}
+	}
+	// Prompts would clutter the output of piped input
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		session.Prompt = true
+		fmt.Println("baryon repl, :help for the commands, :quit to exit")
+	}
+	return session.Run(os.Stdin)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/server"
+)
+
+// shutdownTimeout bounds the time the requests in flight have to complete
+// when the server stops.
+const shutdownTimeout = 10 * time.Second
+
+// runServe implements "baryon serve [-addr localhost:8080] [-grpc addr]",
+// serving the parser, the checks and the transpilers over HTTP, and the
+// gRPC service of api/baryon/v1 on -grpc, until interrupted. The lint
+// rules, target options and plugins come from the baryon.toml of the
+// -config directory.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	grpcAddr := fs.String("grpc", "", "Address to serve the gRPC service on, over unencrypted HTTP/2")
+	configDir := fs.String("config", ".", "Directory of the baryon.toml configuration")
+	maxBytes := fs.Int64("max-bytes", server.DefaultMaxBytes, "Largest program accepted, in bytes")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configDir)
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if err := registerPlugins(cfg); err != nil {
+		return err
+	}
+	s := &server.Server{Rules: cfg.Lint.Rules, Options: cfg.Options, MaxBytes: *maxBytes}
+	servers := []*http.Server{{
+		Addr:              *addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+	if *grpcAddr != "" {
+		grpcServer := &http.Server{
+			Addr:              *grpcAddr,
+			Handler:           s.GRPCHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+			Protocols:         new(http.Protocols),
+		}
+		grpcServer.Protocols.SetUnencryptedHTTP2(true)
+		servers = append(servers, grpcServer)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, len(servers))
+	for _, httpServer := range servers {
+		go func() { errs <- httpServer.ListenAndServe() }()
+	}
+	fmt.Printf("Serving on http://%s (POST /parse, /lint, /transpile?lang=)\n", *addr)
+	if *grpcAddr != "" {
+		fmt.Printf("Serving baryon.v1.Baryon over gRPC on %s\n", *grpcAddr)
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, httpServer := range servers {
+		if err := httpServer.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
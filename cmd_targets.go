@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// runTargets implements "baryon targets [-describe] [target...]".
+func runTargets(args []string) error {
+	fs := flag.NewFlagSet("targets", flag.ExitOnError)
+	describe := fs.Bool("describe", false, "Print the features each target supports")
+	fs.Parse(args)
+
+	// Plugins are registered from the configuration of the current directory
+	cfg, err := config.Load(".")
+	if err != nil {
+		return fmt.Errorf("loading configuration: %w", err)
+	}
+	if err := registerPlugins(cfg); err != nil {
+		return err
+	}
+	targets, err := parseTargets(strings.Join(fs.Args(), ","))
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		targets = transpiler.GetTranspilerNames()
+	}
+
+	for _, name := range targets {
+		descriptor, _ := transpiler.GetTranspiler(name)
+		extension := descriptor.Extension
+		if extension == "" {
+			extension = "-"
+		}
+		if !*describe {
+			line := fmt.Sprintf("%-12s %-12s %-6s", name, descriptor.Display, extension)
+			if transpiler.IsPlugin(name) {
+				line += " plugin"
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+			continue
+		}
+
+		fmt.Printf("%s (%s, %s)\n", name, descriptor.Display, extension)
+		fmt.Printf("  language:        bala %s\n", descriptor.DSLVersion())
+		printCapabilities(descriptor.Capabilities)
+		if configurable, ok := descriptor.Initializer().(transpiler.Configurable); ok {
+			printOptions(configurable.TargetOptions())
+		}
+	}
+	return nil
+}
+
+// printOptions prints the target options for -describe.
+func printOptions(options []transpiler.TargetOption) {
+	if len(options) == 0 {
+		return
+	}
+	fmt.Println("  options:")
+	for _, o := range options {
+		values := "any value"
+		if len(o.Values) > 0 {
+			values = strings.Join(o.Values, "|")
+		}
+		fmt.Printf("    %s=%s (default %s): %s\n", o.Name, values, o.Default, o.Help)
+	}
+}
+
+// printCapabilities prints the capabilities of a target for -describe.
+func printCapabilities(c *transpiler.Capabilities) {
+	if c == nil {
+		fmt.Println("  provided by a plugin, capabilities are unknown")
+		return
+	}
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "none"
+		}
+		return strings.Join(items, ", ")
+	}
+	fmt.Printf("  parameter types: %s\n", list(c.ParameterTypes))
+	fmt.Printf("  implementations: %s\n", list(c.Implementations))
+	fmt.Printf("  outputs:         %s\n", c.Outputs)
+	if len(c.Limitations) > 0 {
+		fmt.Println("  limitations:")
+	}
+	for _, feature := range slices.Sorted(maps.Keys(c.Limitations)) {
+		limitation := c.Limitations[feature]
+		fmt.Printf("    %s: %s: %s\n", feature, limitation.Support, limitation.Note)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/testrun"
+)
+
+// runTest implements "baryon test [-execute] <file.bala>", which runs the
+// tests block of a program. Without -execute it prints the container
+// command of each test; with it, the containers run on the inputs of the
+// test-data directory and the assertions are checked on their outputs.
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	execute := fs.Bool("execute", false, "Run the containers of the tests and check their outputs")
+	pattern := fs.String("run", "", "Run only the tests whose name matches the regular expression")
+	data := fs.String("data", "", "Directory of the test inputs and expected files (default: test-data next to the program)")
+	docker := fs.String("docker", "docker", "Docker-compatible command running the containers, e.g. podman")
+	keep := fs.Bool("keep", false, "Keep the work directories of the tests")
+	timeout := fs.Duration("timeout", 30*time.Minute, "Time limit of each test")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one input file is required")
+	}
+	input := fs.Arg(0)
+	program, err := loadProgram(input, analysisOptions{})
+	if err != nil {
+		return err
+	}
+	match, err := regexp.Compile(*pattern)
+	if err != nil {
+		return fmt.Errorf("-run: %w", err)
+	}
+	if *data == "" {
+		*data = filepath.Join(filepath.Dir(input), testrun.DataDir)
+	}
+
+	runner := &testrun.Runner{Docker: *docker, Data: *data, Keep: *keep}
+	ran, failed := 0, 0
+	for _, test := range program.Tests {
+		if !match.MatchString(test.Name) {
+			continue
+		}
+		ran++
+		if !*execute {
+			plan, err := testrun.NewPlan(program, test, "<work>")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %s %s\n", test.Name, *docker, strings.Join(plan.Args, " "))
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		result, err := runner.Run(ctx, program, test)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("test '%s': %w", test.Name, err)
+		}
+		status := "PASS"
+		if !result.Passed() {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("--- %s: %s (%s)\n", status, test.Name, result.Duration.Round(time.Millisecond))
+		for _, failure := range result.Failures {
+			fmt.Printf("    %s\n", strings.ReplaceAll(failure, "\n", "\n    "))
+		}
+		if result.WorkDir != "" {
+			fmt.Printf("    work directory: %s\n", result.WorkDir)
+		}
+	}
+
+	switch {
+	case ran == 0:
+		return fmt.Errorf("%s has no tests to run", input)
+	case failed > 0:
+		return fmt.Errorf("%d of %d test(s) failed", failed, ran)
+	case *execute:
+		fmt.Printf("✅ %d test(s) passed\n", ran)
+	}
+	return nil
+}
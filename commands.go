@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+)
+
+// command is a CLI subcommand, such as "baryon check".
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+// commands lists the subcommands, by name. Invocations not starting with a
+// subcommand use the flag-based interface of main.
+var commands = map[string]command{
+	"ast":     {"Print the syntax tree of a program as JSON", runAST},
+	"bench":   {"Measure the time and allocations of each stage on programs", runBench},
+	"check":   {"Check a program and its compatibility with targets", runCheck},
+	"diff":    {"Report the interface changes between two versions of a program", runDiff},
+	"fmt":     {"Format a program, fixing deprecated constructs with -fix", runFmt},
+	"get":     {"Fetch a program shared in a registry, with its lockfile", runGet},
+	"lock":    {"Pin the image digests of a program, or audit them with -audit", runLock},
+	"migrate": {"Rewrite programs to the current grammar, keeping to the -to version", runMigrate},
+	"repl":    {"Parse, check and transpile S-expressions interactively", runRepl},
+	"serve":   {"Serve parsing, checks and transpilation over HTTP", runServe},
+	"targets": {"List the targets, and their capabilities with -describe", runTargets},
+	"test":    {"Run the tests of a program in their containers with -execute", runTest},
+}
+
+// commandNames returns the subcommand names, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usage prints the flags of the transpiler mode and the subcommands.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s -input <file.bala> [flags]\n", os.Args[0])
+	fmt.Fprintf(out, "       %s <command> [flags] <file.bala>\n\nFlags:\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\nCommands:")
+	for _, name := range commandNames() {
+		fmt.Fprintf(out, "  %-8s %s\n", name, commands[name].summary)
+	}
+}
+
+// runCommand runs the subcommand named by the first argument, reporting
+// whether there was one.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// loadProgram reads, parses and analyzes a Baryon file.
+func loadProgram(path string, opts analysisOptions) (*ast.Program, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	program, err := decodeProgram(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing error: %w", err)
+	}
+	cfg, err := config.Load(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("loading configuration: %w", err)
+	}
+	if err := registerPlugins(cfg); err != nil {
+		return nil, err
+	}
+	if err := analyzeProgram(program, cfg, opts); err != nil {
+		return nil, fmt.Errorf("semantic error: %w", err)
+	}
+	return program, nil
+}
@@ -3,12 +3,27 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 )
 
+// Position identifies a location in the source file.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"` // byte offset in the source
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("Line %d, Column %d", p.Line, p.Column)
+}
+
 // BaseNode represents the common fields for all AST nodes.
 type BaseNode struct {
 	fmt.Stringer
 	Description string
+	Pos         Position
 }
 
 // NamedBaseNode represents a BaseNode with a name field.
@@ -24,6 +39,10 @@ type Program struct {
 	Implementations []ImplementationBlock
 	Metadata        map[string]string
 	Outputs         []OutputBlock
+	Tests           []TestBlock
+	Resources       []Resource
+	Comments        []Comment
+	Deprecations    []Deprecation
 }
 
 func (p Program) String() string {
@@ -34,8 +53,8 @@ func (p Program) String() string {
 	}
 	if len(p.Metadata) > 0 {
 		buf.WriteString("\tMetadata:\n")
-		for k, v := range p.Metadata {
-			buf.WriteString(fmt.Sprintf("\t\t%s: %s\n", k, v))
+		for _, k := range slices.Sorted(maps.Keys(p.Metadata)) {
+			buf.WriteString(fmt.Sprintf("\t\t%s: %s\n", k, p.Metadata[k]))
 		}
 	}
 	if len(p.Parameters) > 0 {
@@ -56,9 +75,52 @@ func (p Program) String() string {
 			buf.WriteString(output.String())
 		}
 	}
+	if len(p.Tests) > 0 {
+		buf.WriteString("\tTests:\n")
+		for _, test := range p.Tests {
+			buf.WriteString(test.String())
+		}
+	}
+	if len(p.Resources) > 0 {
+		buf.WriteString("\tResources:\n")
+		for _, resource := range p.Resources {
+			buf.WriteString(fmt.Sprintf("\t\t%s: %v\n", resource.Name, resource.Value))
+		}
+	}
 	return buf.String()
 }
 
+// Resource returns the value of a resource requested by the program, if it
+// is requested.
+func (p Program) Resource(name string) (any, bool) {
+	for _, resource := range p.Resources {
+		if resource.Name == name {
+			return resource.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Built-in parameter types.
+const (
+	TypeString      = "string"
+	TypeNumber      = "number"
+	TypeInteger     = "integer"
+	TypeBoolean     = "boolean"
+	TypeEnum        = "enum"
+	TypeFile        = "file"
+	TypeDirectory   = "directory"
+	TypeCharacter   = "character"
+	TypeSamplesheet = "samplesheet" // a CSV file with a header, a sample per row
+)
+
+// Types lists the built-in parameter types.
+var Types = []string{
+	TypeString, TypeNumber, TypeInteger, TypeBoolean,
+	TypeEnum, TypeFile, TypeDirectory, TypeCharacter,
+	TypeSamplesheet,
+}
+
 // Parameter defines a parameter for the program.
 type Parameter struct {
 	NamedBaseNode
@@ -66,6 +128,76 @@ type Parameter struct {
 	Constraints []any // For enum type
 	Default     any
 	Metadata    map[string]string // extensible (e.g., label)
+	When        *Condition        // nil for parameters that are always used
+}
+
+// Condition makes a parameter depend on the value of another one, e.g.
+// (when mode "sensitive"): the parameter is only used when mode is one of
+// the values.
+type Condition struct {
+	Param  string
+	Values []any // string or boolean literals
+	Pos    Position
+}
+
+// Column is a column of a samplesheet, e.g. fastq_1:file in
+// (columns "sample, fastq_1:file").
+type Column struct {
+	Name string
+	Type string
+}
+
+// ColumnTypes lists the types of the columns of samplesheets.
+var ColumnTypes = []string{TypeString, TypeInteger, TypeNumber, TypeBoolean, TypeFile, TypeDirectory}
+
+// Columns returns the columns of a samplesheet parameter, from its columns
+// metadata: the names separated by commas, each with an optional type after
+// a colon, string by default.
+func (p Parameter) Columns() []Column {
+	columns := []Column{}
+	for _, spec := range strings.Split(p.Metadata["columns"], ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		name, typ, ok := strings.Cut(spec, ":")
+		if !ok {
+			typ = TypeString
+		}
+		columns = append(columns, Column{Name: strings.TrimSpace(name), Type: strings.TrimSpace(typ)})
+	}
+	return columns
+}
+
+// LabelPrefix prefixes the metadata keys of the translations of the
+// description of a parameter, e.g. label.it for (label (it "Letture")).
+const LabelPrefix = "label."
+
+// Locales returns the locales the description of a parameter is translated
+// to, sorted.
+func (p Parameter) Locales() []string {
+	locales := []string{}
+	for key := range p.Metadata {
+		if locale, ok := strings.CutPrefix(key, LabelPrefix); ok {
+			locales = append(locales, locale)
+		}
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Label returns the description of a parameter in a locale, e.g. it or
+// it_IT, falling back from a regional locale to its language. It reports
+// whether the parameter has a translation.
+func (p Parameter) Label(locale string) (string, bool) {
+	if label, ok := p.Metadata[LabelPrefix+locale]; ok {
+		return label, true
+	}
+	if i := strings.IndexAny(locale, "_-"); i > 0 {
+		if label, ok := p.Metadata[LabelPrefix+locale[:i]]; ok {
+			return label, true
+		}
+	}
+	return p.Description, false
 }
 
 func (p Parameter) String() string {
@@ -78,10 +210,13 @@ func (p Parameter) String() string {
 	if p.Description != "" {
 		buf.WriteString(fmt.Sprintf("\t\t\tDescription: %s\n", p.Description))
 	}
+	if p.When != nil {
+		buf.WriteString(fmt.Sprintf("\t\t\tWhen: %s in %v\n", p.When.Param, p.When.Values))
+	}
 	if len(p.Metadata) > 0 {
 		buf.WriteString("\t\t\tMetadata:\n")
-		for k, v := range p.Metadata {
-			buf.WriteString(fmt.Sprintf("\t\t\t\t%s: %s\n", k, v))
+		for _, k := range slices.Sorted(maps.Keys(p.Metadata)) {
+			buf.WriteString(fmt.Sprintf("\t\t\t\t%s: %s\n", k, p.Metadata[k]))
 		}
 	}
 	return buf.String()
@@ -90,8 +225,20 @@ func (p Parameter) String() string {
 // ImplementationBlock is a generic node for any implementation section
 type ImplementationBlock struct {
 	BaseNode
-	Name   string         // e.g., "run_docker"
-	Fields map[string]any // Holds fields like "image", "volumes", "arguments" and their values
+	Name       string         // e.g., "run_docker"
+	Fields     map[string]any // Holds fields like "image", "volumes", "arguments" and their values
+	References []Reference    // Identifiers used in "arguments", "env" and "volumes", and placeholders of "config_files"
+	Literals   []Literal      // String literals used in list and pair fields
+	FieldPos   map[string]Position
+}
+
+// FieldPosition returns the position of a field, falling back to the
+// position of the block itself.
+func (ib ImplementationBlock) FieldPosition(name string) Position {
+	if pos, ok := ib.FieldPos[name]; ok {
+		return pos
+	}
+	return ib.Pos
 }
 
 func (ib ImplementationBlock) String() string {
@@ -99,13 +246,45 @@ func (ib ImplementationBlock) String() string {
 	buf.WriteString(fmt.Sprintf("\t\tBlock: %s\n", ib.Name))
 	if len(ib.Fields) > 0 {
 		buf.WriteString("\t\t\tFields:\n")
-		for k, v := range ib.Fields {
-			buf.WriteString(fmt.Sprintf("\t\t\t\t%s: %v\n", k, v))
+		for _, k := range slices.Sorted(maps.Keys(ib.Fields)) {
+			buf.WriteString(fmt.Sprintf("\t\t\t\t%s: %v\n", k, ib.Fields[k]))
 		}
 	}
 	return buf.String()
 }
 
+// Reference records an identifier used inside an implementation block field,
+// which is expected to resolve to a parameter or a known keyword.
+type Reference struct {
+	Name  string   `json:"name"`
+	Field string   `json:"field"` // e.g., "arguments", "env", "volumes"
+	Pos   Position `json:"pos"`
+}
+
+// Literal records a string literal used inside an implementation block
+// field, with its position.
+type Literal struct {
+	Value string   `json:"value"`
+	Field string   `json:"field"`
+	Pos   Position `json:"pos"`
+}
+
+// Comment is a "; ..." comment of the source file.
+type Comment struct {
+	Text     string   `json:"text"` // without the leading semicolon
+	Pos      Position `json:"pos"`
+	Trailing bool     `json:"trailing,omitempty"` // follows code on the same line
+}
+
+// Deprecation records a deprecated construct of the source, with the text
+// that replaces it in the current form of the language.
+type Deprecation struct {
+	Pos         Position `json:"pos"`
+	End         int      `json:"end"` // byte offset past the construct in the source
+	Construct   string   `json:"construct"`
+	Replacement string   `json:"replacement"`
+}
+
 // Represents a value which could be a literal or an identifier reference
 type Value struct {
 	Literal    any    // string, number, bool, special like "_"
@@ -143,3 +322,93 @@ func (ob OutputBlock) String() string {
 	}
 	return buf.String()
 }
+
+// TestBlock defines a test of the program: the values of its parameters
+// and the assertions on its outputs after a run.
+type TestBlock struct {
+	NamedBaseNode
+	Params []TestParam
+	Expect []OutputExpectation
+}
+
+// Resource is a compute resource requested by the program, e.g. (cpus 4).
+type Resource struct {
+	Name  string
+	Value any // string, number or boolean literal
+	Pos   Position
+}
+
+// Resources of the resources block.
+const (
+	ResourceCPUs    = "cpus"     // the number of CPUs
+	ResourceMemory  = "memory"   // the memory, e.g. "8 GB"
+	ResourceTime    = "time"     // the wall time, e.g. "2h" or "1h 30m"
+	ResourceGPUs    = "gpus"     // the number of GPUs
+	ResourceGPUType = "gpu_type" // the type of GPU, e.g. "nvidia-tesla-v100"
+	ResourceLabel   = "label"    // the label of the process, e.g. "process_high"
+	ResourceRetries = "retries"  // the number of times a failed run is retried, with more memory and time
+
+	ResourceScratch     = "scratch"       // run in a local scratch directory: true, or the directory, e.g. "$TMPDIR"
+	ResourceStageInMode = "stage_in_mode" // how inputs are staged: symlink, rellink, link or copy
+	ResourceWorkDir     = "work_dir"      // the work directory of the tasks, e.g. on a shared filesystem
+)
+
+// ResourceNames lists the resources of the resources block.
+var ResourceNames = []string{
+	ResourceCPUs, ResourceMemory, ResourceTime, ResourceGPUs, ResourceGPUType, ResourceLabel, ResourceRetries,
+	ResourceScratch, ResourceStageInMode, ResourceWorkDir,
+}
+
+// StageInModes lists the modes of the stage_in_mode resource.
+var StageInModes = []string{"symlink", "rellink", "link", "copy"}
+
+// TestParam is the value of a parameter in a test.
+type TestParam struct {
+	Name  string
+	Value any // string, number or boolean literal
+	Pos   Position
+}
+
+// OutputExpectation lists the assertions on an output in a test.
+type OutputExpectation struct {
+	Output     string
+	Assertions []Assertion
+	Pos        Position
+}
+
+// Assertion is a check of an output, e.g. (has_text "chr1").
+type Assertion struct {
+	Kind  string // e.g. "has_text", "checksum"
+	Value any
+	Pos   Position
+}
+
+// Assertion kinds of the tests block.
+const (
+	AssertHasText  = "has_text" // the output contains the text
+	AssertHasLine  = "has_line" // the output has a line equal to the text
+	AssertMatches  = "matches"  // the output matches the regular expression
+	AssertLines    = "lines"    // the output has the number of lines
+	AssertChecksum = "checksum" // the output has the <algorithm>:<digest> checksum
+	AssertFile     = "file"     // the output is equal to the file
+)
+
+// AssertionKinds lists the assertion kinds of the tests block.
+var AssertionKinds = []string{
+	AssertHasText, AssertHasLine, AssertMatches, AssertLines, AssertChecksum, AssertFile,
+}
+
+// String provides a string representation of the TestBlock.
+func (tb TestBlock) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("\t\tTest: %s\n", tb.Name))
+	for _, param := range tb.Params {
+		buf.WriteString(fmt.Sprintf("\t\t\tParam: %s = %v\n", param.Name, param.Value))
+	}
+	for _, expect := range tb.Expect {
+		for _, assertion := range expect.Assertions {
+			buf.WriteString(fmt.Sprintf("\t\t\tExpect: %s %s %v\n", expect.Output, assertion.Kind, assertion.Value))
+		}
+	}
+	return buf.String()
+}
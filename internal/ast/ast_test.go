@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -73,6 +74,37 @@ func TestParameterString_Empty(t *testing.T) {
 	}
 }
 
+func TestParameterColumns(t *testing.T) {
+	param := Parameter{
+		NamedBaseNode: NamedBaseNode{Name: "samples"},
+		Type:          TypeSamplesheet,
+		Metadata:      map[string]string{"columns": " sample, fastq_1 : file,,depth:integer "},
+	}
+	expected := []Column{{"sample", TypeString}, {"fastq_1", TypeFile}, {"depth", TypeInteger}}
+	if columns := param.Columns(); !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Columns() = %v, want %v", columns, expected)
+	}
+	if columns := (Parameter{}).Columns(); len(columns) != 0 {
+		t.Errorf("expected no columns without metadata, got %v", columns)
+	}
+}
+
+func TestParameterLabel(t *testing.T) {
+	param := Parameter{
+		NamedBaseNode: NamedBaseNode{Name: "reads", BaseNode: BaseNode{Description: "Reads"}},
+		Metadata:      map[string]string{"desc": "Reads", "label.it": "Letture", "label.pt_BR": "Leituras"},
+	}
+	if locales := param.Locales(); !reflect.DeepEqual(locales, []string{"it", "pt_BR"}) {
+		t.Errorf("Locales() = %v, want it and pt_BR", locales)
+	}
+	for locale, expected := range map[string]string{"it": "Letture", "it_CH": "Letture", "pt_BR": "Leituras", "pt": "Reads", "en": "Reads"} {
+		label, ok := param.Label(locale)
+		if label != expected || ok != (expected != "Reads") {
+			t.Errorf("Label(%q) = %q, %v, want %q", locale, label, ok, expected)
+		}
+	}
+}
+
 func TestImplementationBlockString_EmptyFields(t *testing.T) {
 	ib := ImplementationBlock{Name: "block"}
 	out := ib.String()
@@ -0,0 +1,302 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the version of the JSON representation of a Program,
+// described in docs/ast-json.md. It changes only when a field is removed
+// or changes meaning; new optional fields keep the version.
+const SchemaVersion = 1
+
+type jsonProgram struct {
+	SchemaVersion   int                  `json:"schema_version"`
+	Name            string               `json:"name"`
+	Description     string               `json:"description,omitempty"`
+	Pos             Position             `json:"pos"`
+	Metadata        map[string]string    `json:"metadata,omitempty"`
+	Parameters      []jsonParameter      `json:"parameters"`
+	Implementations []jsonImplementation `json:"implementations"`
+	Outputs         []jsonOutput         `json:"outputs,omitempty"`
+	Tests           []jsonTest           `json:"tests,omitempty"`
+	Resources       []jsonResource       `json:"resources,omitempty"`
+	Comments        []Comment            `json:"comments,omitempty"`
+	Deprecations    []Deprecation        `json:"deprecations,omitempty"`
+}
+
+type jsonParameter struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Description string            `json:"description,omitempty"`
+	Constraints []any             `json:"constraints,omitempty"`
+	Default     any               `json:"default,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	When        *jsonCondition    `json:"when,omitempty"`
+	Pos         Position          `json:"pos"`
+}
+
+type jsonCondition struct {
+	Param  string   `json:"param"`
+	Values []any    `json:"values"`
+	Pos    Position `json:"pos"`
+}
+
+type jsonImplementation struct {
+	Name           string              `json:"name"`
+	Fields         map[string]any      `json:"fields"`
+	FieldPositions map[string]Position `json:"field_positions,omitempty"`
+	References     []Reference         `json:"references,omitempty"`
+	Literals       []Literal           `json:"literals,omitempty"`
+	Pos            Position            `json:"pos"`
+}
+
+type jsonOutput struct {
+	Name        string            `json:"name"`
+	Format      string            `json:"format,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Pos         Position          `json:"pos"`
+}
+
+type jsonTest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description,omitempty"`
+	Params      []jsonTestParam         `json:"params,omitempty"`
+	Expect      []jsonOutputExpectation `json:"expect,omitempty"`
+	Pos         Position                `json:"pos"`
+}
+
+type jsonTestParam struct {
+	Name  string   `json:"name"`
+	Value any      `json:"value"`
+	Pos   Position `json:"pos"`
+}
+
+type jsonResource struct {
+	Name  string   `json:"name"`
+	Value any      `json:"value"`
+	Pos   Position `json:"pos"`
+}
+
+type jsonOutputExpectation struct {
+	Output     string          `json:"output"`
+	Assertions []jsonAssertion `json:"assertions"`
+	Pos        Position        `json:"pos"`
+}
+
+type jsonAssertion struct {
+	Kind  string   `json:"kind"`
+	Value any      `json:"value"`
+	Pos   Position `json:"pos"`
+}
+
+// MarshalJSON encodes a Program in the documented JSON schema.
+func (p Program) MarshalJSON() ([]byte, error) {
+	out := jsonProgram{
+		SchemaVersion:   SchemaVersion,
+		Name:            p.Name,
+		Description:     p.Description,
+		Pos:             p.Pos,
+		Metadata:        p.Metadata,
+		Parameters:      []jsonParameter{},
+		Implementations: []jsonImplementation{},
+		Comments:        p.Comments,
+		Deprecations:    p.Deprecations,
+	}
+	for _, param := range p.Parameters {
+		var when *jsonCondition
+		if param.When != nil {
+			when = &jsonCondition{Param: param.When.Param, Values: param.When.Values, Pos: param.When.Pos}
+		}
+		out.Parameters = append(out.Parameters, jsonParameter{
+			Name:        param.Name,
+			Type:        param.Type,
+			Description: param.Description,
+			Constraints: param.Constraints,
+			Default:     param.Default,
+			Metadata:    param.Metadata,
+			When:        when,
+			Pos:         param.Pos,
+		})
+	}
+	for _, impl := range p.Implementations {
+		fields := impl.Fields
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		out.Implementations = append(out.Implementations, jsonImplementation{
+			Name:           impl.Name,
+			Fields:         fields,
+			FieldPositions: impl.FieldPos,
+			References:     impl.References,
+			Literals:       impl.Literals,
+			Pos:            impl.Pos,
+		})
+	}
+	for _, output := range p.Outputs {
+		out.Outputs = append(out.Outputs, jsonOutput{
+			Name:        output.Name,
+			Format:      output.Format,
+			Path:        output.Path,
+			Description: output.Description,
+			Metadata:    output.Metadata,
+			Pos:         output.Pos,
+		})
+	}
+	for _, test := range p.Tests {
+		t := jsonTest{Name: test.Name, Description: test.Description, Pos: test.Pos}
+		for _, param := range test.Params {
+			t.Params = append(t.Params, jsonTestParam(param))
+		}
+		for _, expect := range test.Expect {
+			e := jsonOutputExpectation{Output: expect.Output, Assertions: []jsonAssertion{}, Pos: expect.Pos}
+			for _, assertion := range expect.Assertions {
+				e.Assertions = append(e.Assertions, jsonAssertion(assertion))
+			}
+			t.Expect = append(t.Expect, e)
+		}
+		out.Tests = append(out.Tests, t)
+	}
+	for _, resource := range p.Resources {
+		out.Resources = append(out.Resources, jsonResource(resource))
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a Program from the documented JSON schema. Numbers
+// without a fraction or exponent decode to int, other numbers to float64,
+// as the parser produces them.
+func (p *Program) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var in jsonProgram
+	if err := decoder.Decode(&in); err != nil {
+		return err
+	}
+	if in.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("unsupported AST schema version %d, expected %d", in.SchemaVersion, SchemaVersion)
+	}
+
+	*p = Program{
+		NamedBaseNode: NamedBaseNode{
+			BaseNode: BaseNode{Description: in.Description, Pos: in.Pos},
+			Name:     in.Name,
+		},
+		Parameters:      []Parameter{},
+		Implementations: []ImplementationBlock{},
+		Metadata:        in.Metadata,
+		Comments:        in.Comments,
+		Deprecations:    in.Deprecations,
+	}
+	if p.Metadata == nil {
+		p.Metadata = map[string]string{}
+	}
+	for _, param := range in.Parameters {
+		metadata := param.Metadata
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		constraints, _ := jsonValue(param.Constraints).([]any)
+		var when *Condition
+		if param.When != nil {
+			values, _ := jsonValue(param.When.Values).([]any)
+			when = &Condition{Param: param.When.Param, Values: values, Pos: param.When.Pos}
+		}
+		p.Parameters = append(p.Parameters, Parameter{
+			NamedBaseNode: NamedBaseNode{
+				BaseNode: BaseNode{Description: param.Description, Pos: param.Pos},
+				Name:     param.Name,
+			},
+			Type:        param.Type,
+			Constraints: constraints,
+			Default:     jsonValue(param.Default),
+			Metadata:    metadata,
+			When:        when,
+		})
+	}
+	for _, impl := range in.Implementations {
+		fields := map[string]any{}
+		for name, value := range impl.Fields {
+			fields[name] = jsonValue(value)
+		}
+		fieldPos := impl.FieldPositions
+		if fieldPos == nil {
+			fieldPos = map[string]Position{}
+		}
+		p.Implementations = append(p.Implementations, ImplementationBlock{
+			BaseNode:   BaseNode{Pos: impl.Pos},
+			Name:       impl.Name,
+			Fields:     fields,
+			References: impl.References,
+			Literals:   impl.Literals,
+			FieldPos:   fieldPos,
+		})
+	}
+	for _, output := range in.Outputs {
+		metadata := output.Metadata
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		p.Outputs = append(p.Outputs, OutputBlock{
+			NamedBaseNode: NamedBaseNode{
+				BaseNode: BaseNode{Description: output.Description, Pos: output.Pos},
+				Name:     output.Name,
+			},
+			Format:   output.Format,
+			Path:     output.Path,
+			Metadata: metadata,
+		})
+	}
+	for _, test := range in.Tests {
+		t := TestBlock{
+			NamedBaseNode: NamedBaseNode{
+				BaseNode: BaseNode{Description: test.Description, Pos: test.Pos},
+				Name:     test.Name,
+			},
+		}
+		for _, param := range test.Params {
+			t.Params = append(t.Params, TestParam{Name: param.Name, Value: jsonValue(param.Value), Pos: param.Pos})
+		}
+		for _, expect := range test.Expect {
+			e := OutputExpectation{Output: expect.Output, Pos: expect.Pos}
+			for _, assertion := range expect.Assertions {
+				e.Assertions = append(e.Assertions, Assertion{Kind: assertion.Kind, Value: jsonValue(assertion.Value), Pos: assertion.Pos})
+			}
+			t.Expect = append(t.Expect, e)
+		}
+		p.Tests = append(p.Tests, t)
+	}
+	for _, resource := range in.Resources {
+		p.Resources = append(p.Resources, Resource{Name: resource.Name, Value: jsonValue(resource.Value), Pos: resource.Pos})
+	}
+	return nil
+}
+
+// jsonValue converts the json.Number values of a decoded value to int or
+// float64, recursively.
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if i, err := v.Int64(); err == nil {
+				return int(i)
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		if v == nil {
+			return v
+		}
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = jsonValue(item)
+		}
+		return items
+	}
+	return value
+}
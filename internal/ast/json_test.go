@@ -0,0 +1,97 @@
+package ast
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func jsonTestProgram() *Program {
+	return &Program{
+		NamedBaseNode: NamedBaseNode{
+			BaseNode: BaseNode{Description: "Align reads", Pos: Position{Line: 1, Column: 7, Offset: 6}},
+			Name:     "align",
+		},
+		Metadata: map[string]string{"version": "1.0"},
+		Parameters: []Parameter{
+			{
+				NamedBaseNode: NamedBaseNode{BaseNode: BaseNode{Description: "Threads"}, Name: "threads"},
+				Type:          TypeInteger,
+				Default:       4,
+				Metadata:      map[string]string{"default": "4"},
+			},
+			{
+				NamedBaseNode: NamedBaseNode{Name: "ratio"},
+				Type:          TypeNumber,
+				Default:       0.5,
+				Metadata:      map[string]string{},
+				When:          &Condition{Param: "mode", Values: []any{"slow"}, Pos: Position{Line: 3, Column: 22}},
+			},
+			{
+				NamedBaseNode: NamedBaseNode{Name: "mode"},
+				Type:          TypeEnum,
+				Constraints:   []any{"fast", "slow"},
+				Default:       "fast",
+				Metadata:      map[string]string{},
+			},
+		},
+		Implementations: []ImplementationBlock{{
+			BaseNode: BaseNode{Pos: Position{Line: 5, Column: 3}},
+			Name:     "run_docker",
+			Fields: map[string]any{
+				"image":     "ubuntu:22.04",
+				"volumes":   []any{[]any{"reads", "/data"}},
+				"arguments": []any{"-t", "threads"},
+			},
+			FieldPos:   map[string]Position{"image": {Line: 6, Column: 4}},
+			References: []Reference{{Name: "threads", Field: "arguments", Pos: Position{Line: 7}}},
+			Literals:   []Literal{{Value: "-t", Field: "arguments", Pos: Position{Line: 7}}},
+		}},
+		Outputs: []OutputBlock{{
+			NamedBaseNode: NamedBaseNode{Name: "bam"},
+			Format:        "bam",
+			Path:          "/data/out.bam",
+			Metadata:      map[string]string{},
+		}},
+		Tests: []TestBlock{{
+			NamedBaseNode: NamedBaseNode{BaseNode: BaseNode{Description: "Small input"}, Name: "small"},
+			Params:        []TestParam{{Name: "threads", Value: 2, Pos: Position{Line: 9}}},
+			Expect: []OutputExpectation{{
+				Output:     "bam",
+				Assertions: []Assertion{{Kind: AssertLines, Value: 12}, {Kind: AssertHasText, Value: "chr1"}},
+			}},
+		}},
+		Resources: []Resource{{Name: ResourceCPUs, Value: 4, Pos: Position{Line: 10}}, {Name: ResourceMemory, Value: "8 GB"}},
+		Comments:  []Comment{{Text: " note", Pos: Position{Line: 2}, Trailing: true}},
+	}
+}
+
+func TestProgramJSON_RoundTrip(t *testing.T) {
+	program := jsonTestProgram()
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatalf("Marshal() unexpected error: %v", err)
+	}
+	for _, key := range []string{`"schema_version":1`, `"field_positions"`, `"default":4`, `"line":5`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("Marshal() output lacks %s: %s", key, data)
+		}
+	}
+
+	var decoded Program
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, program) {
+		t.Errorf("round trip changed the program:\n got %#v\nwant %#v", decoded, *program)
+	}
+}
+
+func TestProgramJSON_SchemaVersion(t *testing.T) {
+	var decoded Program
+	err := json.Unmarshal([]byte(`{"schema_version": 99, "name": "x"}`), &decoded)
+	if err == nil || !strings.Contains(err.Error(), "unsupported AST schema version 99") {
+		t.Errorf("Unmarshal() expected schema version error, got %v", err)
+	}
+}
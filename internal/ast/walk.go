@@ -0,0 +1,69 @@
+package ast
+
+import "fmt"
+
+// Node is a node of the syntax tree that Walk visits: *Program, *Parameter,
+// *ImplementationBlock, *OutputBlock or *TestBlock.
+type Node interface {
+	Position() Position
+}
+
+// Position returns the position of the node in the source file.
+func (b BaseNode) Position() Position {
+	return b.Pos
+}
+
+// A Visitor's Visit method is called for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree in depth-first order: it starts by calling
+// v.Visit(node), then walks the parameters, implementation blocks, outputs
+// and tests of a program in source order. Nodes are passed as pointers into
+// the tree, so visitors may modify them.
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for i := range n.Parameters {
+			Walk(&n.Parameters[i], v)
+		}
+		for i := range n.Implementations {
+			Walk(&n.Implementations[i], v)
+		}
+		for i := range n.Outputs {
+			Walk(&n.Outputs[i], v)
+		}
+		for i := range n.Tests {
+			Walk(&n.Tests[i], v)
+		}
+	case *Parameter, *ImplementationBlock, *OutputBlock, *TestBlock:
+		// leaves
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree in depth-first order, calling f(node)
+// for each node. If f returns true, Inspect invokes f for the children of
+// node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
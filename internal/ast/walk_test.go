@@ -0,0 +1,93 @@
+package ast
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+type recorder struct{ visited *[]string }
+
+func (r recorder) Visit(node Node) Visitor {
+	switch n := node.(type) {
+	case nil:
+		*r.visited = append(*r.visited, "end")
+	case *Program:
+		*r.visited = append(*r.visited, "program "+n.Name)
+	case *Parameter:
+		*r.visited = append(*r.visited, "parameter "+n.Name)
+	case *ImplementationBlock:
+		*r.visited = append(*r.visited, "implementation "+n.Name)
+	case *OutputBlock:
+		*r.visited = append(*r.visited, "output "+n.Name)
+	default:
+		*r.visited = append(*r.visited, fmt.Sprintf("%T", n))
+	}
+	return r
+}
+
+func walkProgram() *Program {
+	return &Program{
+		NamedBaseNode: NamedBaseNode{Name: "prog"},
+		Parameters: []Parameter{
+			{NamedBaseNode: NamedBaseNode{Name: "a"}},
+			{NamedBaseNode: NamedBaseNode{Name: "b"}},
+		},
+		Implementations: []ImplementationBlock{{Name: "run_docker"}},
+		Outputs:         []OutputBlock{{NamedBaseNode: NamedBaseNode{Name: "out"}}},
+	}
+}
+
+func TestWalk_Order(t *testing.T) {
+	visited := []string{}
+	Walk(walkProgram(), recorder{&visited})
+
+	want := []string{
+		"program prog",
+		"parameter a", "end",
+		"parameter b", "end",
+		"implementation run_docker", "end",
+		"output out", "end",
+		"end",
+	}
+	if !slices.Equal(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+}
+
+func TestInspect_Prune(t *testing.T) {
+	count := 0
+	Inspect(walkProgram(), func(node Node) bool {
+		if node != nil {
+			count++
+		}
+		return false
+	})
+	if count != 1 {
+		t.Errorf("expected only the program to be visited, got %d nodes", count)
+	}
+}
+
+func TestInspect_ModifiesTree(t *testing.T) {
+	program := walkProgram()
+	Inspect(program, func(node Node) bool {
+		if p, ok := node.(*Parameter); ok {
+			p.Type = TypeString
+		}
+		return true
+	})
+	for _, p := range program.Parameters {
+		if p.Type != TypeString {
+			t.Errorf("parameter '%s' was not modified", p.Name)
+		}
+	}
+}
+
+func TestNodePosition(t *testing.T) {
+	program := walkProgram()
+	program.Parameters[0].Pos = Position{Line: 3, Column: 5}
+	var node Node = &program.Parameters[0]
+	if node.Position() != (Position{Line: 3, Column: 5}) {
+		t.Errorf("unexpected position %v", node.Position())
+	}
+}
@@ -0,0 +1,185 @@
+// Package bench measures the time and the allocations of the stages of
+// baryon-lang, lexing, parsing, the semantic checks and each transpiler,
+// on a corpus of programs, for "baryon bench". The Go benchmarks of the
+// lexer, parser and transpiler packages cover the same stages on their
+// fixtures.
+package bench
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// Source is a program of the corpus.
+type Source struct {
+	Path string
+	Code string
+}
+
+// Stage is a step run on the whole corpus.
+type Stage struct {
+	Name string
+	Run  func() error
+	// Skip is why the stage isn't run, e.g. a feature of the corpus the
+	// target doesn't support.
+	Skip string
+}
+
+// Result is the cost of a run of a stage on the corpus, averaged over the
+// runs.
+type Result struct {
+	Stage       string `json:"stage"`
+	Runs        int    `json:"runs"`
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp uint64 `json:"allocs_per_op"`
+	BytesPerOp  uint64 `json:"bytes_per_op"`
+	Skipped     string `json:"skipped,omitempty"`
+}
+
+// Stages returns the stages of a corpus: "lex", "parse", "analyze" and a
+// "transpile:<target>" stage per target. The programs are parsed once to
+// check that they can be, so that the later stages measure only their own
+// work. Targets that don't support a program of the corpus are skipped.
+func Stages(corpus []Source, targets []string) ([]Stage, error) {
+	programs := make([]*ast.Program, len(corpus))
+	for i, source := range corpus {
+		program, err := parse(source.Code)
+		if err != nil {
+			return nil, fmt.Errorf("%s: parsing error: %w", source.Path, err)
+		}
+		programs[i] = program
+	}
+
+	stages := []Stage{
+		{Name: "lex", Run: func() error {
+			for _, source := range corpus {
+				for token := range lexer.New(source.Code).Token() {
+					if token.Type == lexer.TOKEN_EOF {
+						break
+					}
+				}
+			}
+			return nil
+		}},
+		{Name: "parse", Run: func() error {
+			for _, source := range corpus {
+				if _, err := parse(source.Code); err != nil {
+					return fmt.Errorf("%s: %w", source.Path, err)
+				}
+			}
+			return nil
+		}},
+		{Name: "analyze", Run: func() error {
+			for _, program := range programs {
+				semantic.New().Analyze(program)
+			}
+			return nil
+		}},
+	}
+	for _, target := range targets {
+		descriptor, err := transpiler.GetTranspiler(target)
+		if err != nil {
+			return nil, err
+		}
+		stage := Stage{Name: "transpile:" + target, Run: func() error {
+			for i, program := range programs {
+				if err := descriptor.Initializer().TranspileTo(io.Discard, program); err != nil {
+					return fmt.Errorf("%s: %s: %w", corpus[i].Path, target, err)
+				}
+			}
+			return nil
+		}}
+		for i, program := range programs {
+			if issue, ok := unsupported(target, program); ok {
+				stage.Skip = fmt.Sprintf("%s: %s", corpus[i].Path, issue.Note)
+				break
+			}
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// Measure runs a stage the given number of times, after a warm-up run, and
+// returns its average cost. Skipped stages aren't run.
+func Measure(stage Stage, runs int) (Result, error) {
+	if runs < 1 {
+		runs = 1
+	}
+	if stage.Skip != "" {
+		return Result{Stage: stage.Name, Skipped: stage.Skip}, nil
+	}
+	if err := stage.Run(); err != nil {
+		return Result{}, err
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for range runs {
+		if err := stage.Run(); err != nil {
+			return Result{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return Result{
+		Stage:       stage.Name,
+		Runs:        runs,
+		NsPerOp:     elapsed.Nanoseconds() / int64(runs),
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / uint64(runs),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / uint64(runs),
+	}, nil
+}
+
+// WriteTable writes results as an aligned table, in the units of go test
+// -bench, followed by the reasons stages were skipped for.
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "stage\truns\ttime/op\tallocs/op\tB/op")
+	for _, r := range results {
+		if r.Skipped != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\n", r.Stage)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\n", r.Stage, r.Runs, time.Duration(r.NsPerOp), r.AllocsPerOp, r.BytesPerOp)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Skipped != "" {
+			if _, err := fmt.Fprintf(w, "%s skipped, %s\n", r.Stage, r.Skipped); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unsupported returns the first feature of a program a target doesn't
+// support.
+func unsupported(target string, program *ast.Program) (transpiler.CompatibilityIssue, bool) {
+	issues, _ := transpiler.CheckCompatibility(target, program)
+	for _, issue := range issues {
+		if issue.Support == transpiler.Unsupported {
+			return issue, true
+		}
+	}
+	return transpiler.CompatibilityIssue{}, false
+}
+
+func parse(code string) (*ast.Program, error) {
+	return parser.New(lexer.New(code)).ParseProgram()
+}
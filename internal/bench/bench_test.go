@@ -0,0 +1,58 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+)
+
+var corpus = []Source{{Path: "align.bala", Code: `(bala align (
+	(reads file (desc "Input reads"))
+	(run_docker (image "biocontainers/bwa:0.7.17") (arguments "bwa" reads))
+))`}}
+
+func TestStages(t *testing.T) {
+	stages, err := Stages(corpus, []string{"python", "bash", "streamflow"})
+	if err != nil {
+		t.Fatalf("Stages() unexpected error: %v", err)
+	}
+	names := []string{}
+	results := []Result{}
+	for _, stage := range stages {
+		names = append(names, stage.Name)
+		result, err := Measure(stage, 2)
+		if err != nil {
+			t.Fatalf("Measure(%s) unexpected error: %v", stage.Name, err)
+		}
+		if stage.Name == "transpile:streamflow" {
+			if result.Skipped == "" || result.Runs != 0 {
+				t.Errorf("expected the unsupported target to be skipped, got %+v", result)
+			}
+		} else if result.Runs != 2 || result.NsPerOp <= 0 {
+			t.Errorf("Measure(%s) = %+v", stage.Name, result)
+		}
+		results = append(results, result)
+	}
+	if strings.Join(names, " ") != "lex parse analyze transpile:python transpile:bash transpile:streamflow" {
+		t.Errorf("Stages() = %v", names)
+	}
+	if results[1].AllocsPerOp == 0 {
+		t.Errorf("expected the parser to allocate, got %+v", results[1])
+	}
+
+	var table strings.Builder
+	if err := WriteTable(&table, results); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(table.String()), "\n"); len(lines) != 8 || !strings.HasPrefix(lines[7], "transpile:streamflow skipped, align.bala:") || !strings.HasPrefix(lines[0], "stage") {
+		t.Errorf("WriteTable() =\n%s", table.String())
+	}
+}
+
+func TestStages_Errors(t *testing.T) {
+	if _, err := Stages([]Source{{Path: "broken.bala", Code: "(bala"}}, nil); err == nil || !strings.Contains(err.Error(), "broken.bala") {
+		t.Errorf("Stages() error = %v, want the path of the broken program", err)
+	}
+	if _, err := Stages(corpus, []string{"cobol"}); err == nil {
+		t.Error("Stages() expected error for an unknown target")
+	}
+}
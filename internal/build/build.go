@@ -0,0 +1,42 @@
+// Package build runs the tasks of multi-file and multi-target builds, such
+// as parsing each program or transpiling it to each target, on a bounded
+// pool of goroutines.
+package build
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// DefaultJobs is the default number of tasks run at once, one per CPU.
+func DefaultJobs() int {
+	return runtime.NumCPU()
+}
+
+// Run runs task for every index below count, at most jobs at a time, and
+// waits for them all. Failing tasks don't stop the others: their errors
+// are joined in the order of their indexes, so that a build reports every
+// broken file at once, in a stable order. jobs below 1 runs one task at a
+// time.
+func Run(jobs, count int, task func(i int) error) error {
+	jobs = max(1, min(jobs, count))
+	errs := make([]error, count)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = task(i)
+			}
+		}()
+	}
+	for i := range count {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errors.Join(errs...)
+}
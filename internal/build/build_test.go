@@ -0,0 +1,63 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	var running, peak atomic.Int32
+	results := make([]int, 20)
+	err := Run(3, len(results), func(i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		results[i] = i * i
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+	if peak.Load() > 3 {
+		t.Errorf("Run() ran %d tasks at once, want at most 3", peak.Load())
+	}
+	for i, r := range results {
+		if r != i*i {
+			t.Errorf("task %d was not run", i)
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	var runs atomic.Int32
+	err := Run(4, 10, func(i int) error {
+		runs.Add(1)
+		if i%3 == 0 {
+			return fmt.Errorf("task %d failed", i)
+		}
+		return nil
+	})
+	if runs.Load() != 10 {
+		t.Errorf("Run() ran %d tasks, want all of them despite the errors", runs.Load())
+	}
+	expected := "task 0 failed\ntask 3 failed\ntask 6 failed\ntask 9 failed"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Run() error = %q, want %q", err, expected)
+	}
+
+	if err := Run(0, 2, func(i int) error { return errors.New("failed") }); err == nil {
+		t.Error("Run() with no jobs expected the tasks to run")
+	}
+	if err := Run(2, 0, func(i int) error { return errors.New("failed") }); err != nil {
+		t.Errorf("Run() without tasks = %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+// Package config loads the project configuration from baryon.toml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the configuration file, looked up in the
+// directory of the program and its parents.
+const FileName = "baryon.toml"
+
+// Config is the project configuration.
+type Config struct {
+	// Path is the file the configuration was loaded from, empty when no
+	// file was found.
+	Path    string
+	Lint    Lint
+	Plugins Plugins
+	// Templates maps targets to the template files overriding sections of
+	// their output, by section name, from [templates.<target>] tables.
+	Templates map[string]map[string]string
+	// Options maps targets to their target options, by option name, from
+	// [options.<target>] tables.
+	Options map[string]map[string]string
+	// Registry is where "baryon get" fetches shared programs from.
+	Registry Registry
+	// Verify configures the checkers of -verify.
+	Verify Verify
+}
+
+// Verify configures the checkers of the generated code, from the [verify]
+// table.
+type Verify struct {
+	// GalaxySchema is the galaxy.xsd file Galaxy tools are validated
+	// against.
+	GalaxySchema string
+}
+
+// Registry configures the registry of shared programs, from the [registry]
+// table.
+type Registry struct {
+	// URL is the base URL of the registry, see registry.Client.
+	URL string
+	// Cache is the directory downloads are cached in, the user cache
+	// directory when empty.
+	Cache string
+}
+
+// Lint configures the semantic checks.
+type Lint struct {
+	// Rules enables (true) or disables (false) checks by rule name. Rules
+	// not listed keep their default.
+	Rules map[string]bool
+}
+
+// Plugins configures the external transpilers.
+type Plugins struct {
+	// Dirs are searched for plugin executables, see transpiler.PluginPrefix.
+	Dirs []string
+	// Targets declares plugins explicitly, by target name.
+	Targets map[string]Plugin
+}
+
+// Plugin declares the command of an external transpiler, from a
+// [plugins.<target>] table.
+type Plugin struct {
+	Command   []string
+	Display   string
+	Extension string
+	// Language is the DSL version the plugin understands, e.g. "1.1".
+	Language string
+}
+
+// Parse parses the content of a configuration file.
+func Parse(data []byte) (*Config, error) {
+	tables, err := parseTOML(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := empty()
+	for _, key := range sortedKeys(tables["lint.rules"]) {
+		enabled, ok := tables["lint.rules"][key].(bool)
+		if !ok {
+			return nil, fmt.Errorf("lint.rules.%s: expected true or false", key)
+		}
+		cfg.Lint.Rules[key] = enabled
+	}
+
+	if dirs, ok := tables["plugins"]["dirs"]; ok {
+		if cfg.Plugins.Dirs, err = stringList(dirs); err != nil {
+			return nil, fmt.Errorf("plugins.dirs: %w", err)
+		}
+	}
+	for _, name := range sortedTables(tables, "plugins.") {
+		target := strings.TrimPrefix(name, "plugins.")
+		plugin, err := parsePlugin(tables[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		cfg.Plugins.Targets[target] = plugin
+	}
+
+	for _, name := range sortedTables(tables, "templates.") {
+		target := strings.TrimPrefix(name, "templates.")
+		cfg.Templates[target] = map[string]string{}
+		for _, section := range sortedKeys(tables[name]) {
+			path, ok := tables[name][section].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.%s: expected the path of a template file", name, section)
+			}
+			cfg.Templates[target][section] = path
+		}
+	}
+
+	for _, name := range sortedTables(tables, "options.") {
+		target := strings.TrimPrefix(name, "options.")
+		cfg.Options[target] = map[string]string{}
+		for _, option := range sortedKeys(tables[name]) {
+			switch value := tables[name][option].(type) {
+			case string, bool, int64, float64:
+				cfg.Options[target][option] = fmt.Sprint(value)
+			default:
+				return nil, fmt.Errorf("%s.%s: expected a string, number or boolean", name, option)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(tables["registry"]) {
+		value, ok := tables["registry"][key].(string)
+		if !ok {
+			return nil, fmt.Errorf("registry.%s: expected a string", key)
+		}
+		switch key {
+		case "url":
+			cfg.Registry.URL = value
+		case "cache":
+			cfg.Registry.Cache = value
+		default:
+			return nil, fmt.Errorf("registry: unknown key '%s'", key)
+		}
+	}
+
+	for _, key := range sortedKeys(tables["verify"]) {
+		value, ok := tables["verify"][key].(string)
+		if !ok {
+			return nil, fmt.Errorf("verify.%s: expected a string", key)
+		}
+		switch key {
+		case "galaxy_schema":
+			cfg.Verify.GalaxySchema = value
+		default:
+			return nil, fmt.Errorf("verify: unknown key '%s'", key)
+		}
+	}
+	return cfg, nil
+}
+
+func empty() *Config {
+	return &Config{
+		Lint:      Lint{Rules: map[string]bool{}},
+		Plugins:   Plugins{Targets: map[string]Plugin{}},
+		Templates: map[string]map[string]string{},
+		Options:   map[string]map[string]string{},
+	}
+}
+
+// parsePlugin reads a plugin table, whose command is a string or an array
+// of strings.
+func parsePlugin(t table) (Plugin, error) {
+	plugin := Plugin{}
+	switch command := t["command"].(type) {
+	case string:
+		plugin.Command = []string{command}
+	case []any:
+		list, err := stringList(command)
+		if err != nil {
+			return plugin, fmt.Errorf("command: %w", err)
+		}
+		plugin.Command = list
+	case nil:
+		return plugin, fmt.Errorf("missing command")
+	default:
+		return plugin, fmt.Errorf("command: expected a string or an array of strings")
+	}
+	if len(plugin.Command) == 0 || plugin.Command[0] == "" {
+		return plugin, fmt.Errorf("command is empty")
+	}
+
+	for _, key := range sortedKeys(t) {
+		switch key {
+		case "command":
+		case "display", "extension", "language":
+			value, ok := t[key].(string)
+			if !ok {
+				return plugin, fmt.Errorf("%s: expected a string", key)
+			}
+			switch key {
+			case "display":
+				plugin.Display = value
+			case "extension":
+				plugin.Extension = value
+			default:
+				plugin.Language = value
+			}
+		default:
+			return plugin, fmt.Errorf("unknown key '%s'", key)
+		}
+	}
+	return plugin, nil
+}
+
+// resolve makes the relative plugin, template, cache and schema paths relative to dir, the
+// directory of the configuration file. Commands without a slash are looked up in the
+// PATH and are left as they are.
+func (c *Config) resolve(dir string) {
+	for i, d := range c.Plugins.Dirs {
+		if !filepath.IsAbs(d) {
+			c.Plugins.Dirs[i] = filepath.Join(dir, d)
+		}
+	}
+	for _, plugin := range c.Plugins.Targets {
+		if command := plugin.Command[0]; strings.ContainsRune(command, '/') && !filepath.IsAbs(command) {
+			plugin.Command[0] = filepath.Join(dir, command)
+		}
+	}
+	if c.Registry.Cache != "" && !filepath.IsAbs(c.Registry.Cache) {
+		c.Registry.Cache = filepath.Join(dir, c.Registry.Cache)
+	}
+	if c.Verify.GalaxySchema != "" && !filepath.IsAbs(c.Verify.GalaxySchema) {
+		c.Verify.GalaxySchema = filepath.Join(dir, c.Verify.GalaxySchema)
+	}
+	for _, sections := range c.Templates {
+		for section, path := range sections {
+			if !filepath.IsAbs(path) {
+				sections[section] = filepath.Join(dir, path)
+			}
+		}
+	}
+}
+
+// Load finds the configuration file in dir or its closest parent and
+// parses it. It returns an empty configuration if there is none.
+func Load(dir string) (*Config, error) {
+	path, err := find(dir)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return empty(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Path = path
+	cfg.resolve(filepath.Dir(path))
+	return cfg, nil
+}
+
+func find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func sortedKeys(t table) []string {
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedTables returns the names of the tables starting with prefix,
+// sorted.
+func sortedTables(tables map[string]table, prefix string) []string {
+	names := []string{}
+	for name := range tables {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func stringList(value any) ([]string, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of strings")
+	}
+	list := make([]string, len(items))
+	for i, item := range items {
+		if list[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("expected an array of strings")
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	input := `
+# top-level keys
+name = "demo" # trailing comment
+count = 3
+ratio = 0.5
+
+[lint.rules]
+unused-parameter = false
+"unpinned-image" = true
+
+[paths]
+list = [
+	"a#b",
+	'c\d',
+]
+`
+	tables, err := parseTOML(input)
+	if err != nil {
+		t.Fatalf("parseTOML() unexpected error: %v", err)
+	}
+	expected := map[string]table{
+		"":           {"name": "demo", "count": 3, "ratio": 0.5},
+		"lint.rules": {"unused-parameter": false, "unpinned-image": true},
+		"paths":      {"list": []any{"a#b", `c\d`}},
+	}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("parseTOML() = %v, want %v", tables, expected)
+	}
+}
+
+func TestParseTOML_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"key",
+		"key = ",
+		"key = \"unterminated",
+		"key = [1, 2",
+		"key = 1\nkey = 2",
+		"[a]\n[a]",
+		"[[array]]",
+		"bad key = 1",
+	} {
+		if _, err := parseTOML(input); err == nil {
+			t.Errorf("parseTOML(%q) expected error", input)
+		}
+	}
+}
+
+func TestParse_LintRules(t *testing.T) {
+	cfg, err := Parse([]byte("[lint.rules]\nunused-parameter = false\n"))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if enabled, ok := cfg.Lint.Rules["unused-parameter"]; !ok || enabled {
+		t.Errorf("Lint.Rules = %v, want unused-parameter disabled", cfg.Lint.Rules)
+	}
+
+	if _, err := Parse([]byte("[lint.rules]\nunused-parameter = \"off\"\n")); err == nil {
+		t.Error("Parse() expected error for non-boolean rule")
+	}
+}
+
+func TestLoad_SearchesParents(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "workflows", "rnaseq")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, FileName)
+	if err := os.WriteFile(path, []byte("[lint.rules]\nunpinned-image = false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(nested)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if cfg.Path != path {
+		t.Errorf("Load().Path = %q, want %q", cfg.Path, path)
+	}
+	if enabled, ok := cfg.Lint.Rules["unpinned-image"]; !ok || enabled {
+		t.Errorf("Lint.Rules = %v, want unpinned-image disabled", cfg.Lint.Rules)
+	}
+}
+
+func TestParse_Options(t *testing.T) {
+	cfg, err := Parse([]byte(`
+[options.python]
+models = "pydantic"
+verbose = true
+`))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	expected := map[string]map[string]string{"python": {"models": "pydantic", "verbose": "true"}}
+	if !reflect.DeepEqual(cfg.Options, expected) {
+		t.Errorf("Parse() options = %v, want %v", cfg.Options, expected)
+	}
+	if _, err := Parse([]byte("[options.python]\nmodels = [\"a\"]")); err == nil {
+		t.Error("Parse() expected error for an array option")
+	}
+}
+
+func TestParse_Plugins(t *testing.T) {
+	cfg, err := Parse([]byte(`
+[plugins]
+dirs = ["plugins"]
+
+[plugins.snakemake]
+command = ["python3", "./tools/snakemake.py"]
+extension = ".smk"
+display = "Snakemake"
+
+[plugins.cwl]
+command = "baryon-cwl"
+language = "1.1"
+`))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	expected := Plugins{
+		Dirs: []string{"plugins"},
+		Targets: map[string]Plugin{
+			"snakemake": {Command: []string{"python3", "./tools/snakemake.py"}, Display: "Snakemake", Extension: ".smk"},
+			"cwl":       {Command: []string{"baryon-cwl"}, Language: "1.1"},
+		},
+	}
+	if !reflect.DeepEqual(cfg.Plugins, expected) {
+		t.Errorf("Parse() plugins = %+v, want %+v", cfg.Plugins, expected)
+	}
+
+	for _, input := range []string{
+		"[plugins.x]\nextension = \".x\"",
+		"[plugins.x]\ncommand = []",
+		"[plugins.x]\ncommand = 1",
+		"[plugins.x]\ncommand = \"x\"\nextention = \".x\"",
+		"[plugins]\ndirs = \"plugins\"",
+	} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) expected error", input)
+		}
+	}
+}
+
+func TestLoad_Templates(t *testing.T) {
+	dir := t.TempDir()
+	data := "[templates.r]\nheader = \"templates/header.R.tmpl\"\nresult = \"/abs/result.tmpl\"\n"
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	expected := map[string]map[string]string{
+		"r": {
+			"header": filepath.Join(dir, "templates", "header.R.tmpl"),
+			"result": "/abs/result.tmpl",
+		},
+	}
+	if !reflect.DeepEqual(cfg.Templates, expected) {
+		t.Errorf("Load().Templates = %v, want %v", cfg.Templates, expected)
+	}
+
+	if _, err := Parse([]byte("[templates.r]\nheader = 1\n")); err == nil {
+		t.Error("Parse() expected error for a non-string template path")
+	}
+}
+
+func TestParse_Registry(t *testing.T) {
+	cfg, err := Parse([]byte("[registry]\nurl = \"https://bala.example.org\"\ncache = \".cache\"\n"))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	expected := Registry{URL: "https://bala.example.org", Cache: ".cache"}
+	if cfg.Registry != expected {
+		t.Errorf("Parse() registry = %+v, want %+v", cfg.Registry, expected)
+	}
+	for _, input := range []string{"[registry]\nurl = 1", "[registry]\nadress = \"x\""} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) expected error", input)
+		}
+	}
+}
+
+func TestParse_Verify(t *testing.T) {
+	cfg, err := Parse([]byte("[verify]\ngalaxy_schema = \"schemas/galaxy.xsd\"\n"))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if cfg.Verify.GalaxySchema != "schemas/galaxy.xsd" {
+		t.Errorf("Parse() galaxy schema = %q", cfg.Verify.GalaxySchema)
+	}
+	for _, input := range []string{"[verify]\ngalaxy_schema = true", "[verify]\nschema = \"x\""} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) expected error", input)
+		}
+	}
+}
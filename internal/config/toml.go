@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// table holds the key/value pairs of a TOML table.
+type table map[string]any
+
+// parseTOML parses the subset of TOML used by baryon.toml: tables
+// ([name] and [dotted.name]), bare or quoted keys, and string, integer,
+// float, boolean and array values. It returns the tables by name; keys
+// before the first header belong to the "" table.
+func parseTOML(data string) (map[string]table, error) {
+	tables := map[string]table{"": {}}
+	current := ""
+
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header '%s'", lineNo, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty table name", lineNo)
+			}
+			if _, ok := tables[name]; ok {
+				return nil, fmt.Errorf("line %d: table '%s' defined twice", lineNo, name)
+			}
+			tables[name] = table{}
+			current = name
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key = value'", lineNo)
+		}
+		key, err := parseKey(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		value = strings.TrimSpace(value)
+
+		// Arrays may span several lines, until the brackets balance.
+		for strings.HasPrefix(value, "[") && !balanced(value) && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+
+		parsed, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if _, ok := tables[current][key]; ok {
+			return nil, fmt.Errorf("line %d: key '%s' defined twice", lineNo, key)
+		}
+		tables[current][key] = parsed
+	}
+	return tables, nil
+}
+
+// stripComment removes a trailing '#' comment, ignoring '#' inside strings.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// balanced reports whether the brackets of an array value are closed.
+func balanced(value string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+func parseKey(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	if key[0] == '"' || key[0] == '\'' {
+		s, rest, err := parseString(key)
+		if err != nil {
+			return "", err
+		}
+		if rest != "" {
+			return "", fmt.Errorf("unexpected '%s' after key", rest)
+		}
+		return s, nil
+	}
+	for _, c := range key {
+		if !(c == '_' || c == '-' || c == '.' || '0' <= c && c <= '9' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return "", fmt.Errorf("invalid key '%s'", key)
+		}
+	}
+	return key, nil
+}
+
+func parseValue(value string) (any, error) {
+	v, rest, err := parseValuePrefix(value)
+	if err != nil {
+		return nil, err
+	}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		return nil, fmt.Errorf("unexpected '%s' after value", rest)
+	}
+	return v, nil
+}
+
+// parseValuePrefix parses the value at the start of s, returning the rest.
+func parseValuePrefix(s string) (any, string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case s[0] == '"' || s[0] == '\'':
+		return parseString(s)
+	case s[0] == '[':
+		return parseArray(s)
+	}
+
+	end := strings.IndexAny(s, ",]")
+	if end < 0 {
+		end = len(s)
+	}
+	token, rest := strings.TrimSpace(s[:end]), s[end:]
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	clean := strings.ReplaceAll(token, "_", "")
+	if n, err := strconv.ParseInt(clean, 10, 64); err == nil {
+		return int(n), rest, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value '%s'", token)
+}
+
+// parseString parses a basic ("...") or literal ('...') string at the
+// start of s.
+func parseString(s string) (string, string, error) {
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return sb.String(), s[i+1:], nil
+		case c == '\\' && quote == '"' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\':
+				sb.WriteByte(s[i])
+			default:
+				return "", "", fmt.Errorf("invalid escape '\\%c'", s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+func parseArray(s string) ([]any, string, error) {
+	items := []any{}
+	rest := strings.TrimSpace(s[1:])
+	for {
+		if strings.HasPrefix(rest, "]") {
+			return items, rest[1:], nil
+		}
+		item, after, err := parseValuePrefix(rest)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, item)
+		rest = strings.TrimSpace(after)
+		switch {
+		case strings.HasPrefix(rest, ","):
+			rest = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(rest, "]"):
+		default:
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+	}
+}
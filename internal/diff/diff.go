@@ -0,0 +1,321 @@
+// Package diff compares two versions of a program, reporting the changes
+// of its interface, such as added parameters or a new container image, and
+// the version bump they call for.
+package diff
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Kind is the kind of a change.
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Impact is the semantic version bump a change calls for.
+type Impact int
+
+const (
+	Patch Impact = iota
+	Minor
+	Major
+)
+
+func (i Impact) String() string {
+	switch i {
+	case Major:
+		return "major"
+	case Minor:
+		return "minor"
+	}
+	return "patch"
+}
+
+func (i Impact) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// Change is a difference between two versions of a program.
+type Change struct {
+	Kind    Kind   `json:"kind"`
+	Subject string `json:"subject"` // e.g. "parameter 'threads'" or "image of run_docker"
+	Detail  string `json:"detail,omitempty"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+	Impact  Impact `json:"impact"`
+}
+
+func (c Change) String() string {
+	var sb strings.Builder
+	sb.WriteString(c.Subject + " " + string(c.Kind))
+	if c.Detail != "" {
+		sb.WriteString(": " + c.Detail)
+	}
+	if c.Old != "" || c.New != "" {
+		sb.WriteString(fmt.Sprintf(": %q -> %q", c.Old, c.New))
+	}
+	sb.WriteString(" (" + c.Impact.String() + ")")
+	return sb.String()
+}
+
+// Bump returns the largest impact of the changes, and false when there are
+// none.
+func Bump(changes []Change) (Impact, bool) {
+	bump := Patch
+	for _, c := range changes {
+		bump = max(bump, c.Impact)
+	}
+	return bump, len(changes) > 0
+}
+
+// Programs returns the changes from old to new, in the order of the
+// parameters, implementations, outputs and resources of the programs.
+func Programs(old, new *ast.Program) []Change {
+	d := &differ{changes: []Change{}}
+	if old.Name != new.Name {
+		d.add(Changed, "program name", "", old.Name, new.Name, Major)
+	}
+	if old.Description != new.Description {
+		d.add(Changed, "program description", "", old.Description, new.Description, Patch)
+	}
+	d.metadata("program", old.Metadata, new.Metadata)
+	d.parameters(old.Parameters, new.Parameters)
+	d.implementations(old.Implementations, new.Implementations)
+	d.outputs(old.Outputs, new.Outputs)
+	d.resources(old.Resources, new.Resources)
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(kind Kind, subject, detail, old, new string, impact Impact) {
+	d.changes = append(d.changes, Change{
+		Kind: kind, Subject: subject, Detail: detail, Old: old, New: new, Impact: impact,
+	})
+}
+
+func (d *differ) parameters(old, new []ast.Parameter) {
+	for _, o := range old {
+		if !slices.ContainsFunc(new, func(p ast.Parameter) bool { return p.Name == o.Name }) {
+			d.add(Removed, fmt.Sprintf("parameter '%s'", o.Name), "", "", "", Major)
+		}
+	}
+	for _, n := range new {
+		subject := fmt.Sprintf("parameter '%s'", n.Name)
+		i := slices.IndexFunc(old, func(p ast.Parameter) bool { return p.Name == n.Name })
+		if i < 0 {
+			if n.Default == nil {
+				d.add(Added, subject, "required", "", "", Major)
+			} else {
+				d.add(Added, subject, "optional", "", "", Minor)
+			}
+			continue
+		}
+		o := old[i]
+
+		if o.Type != n.Type {
+			d.add(Changed, subject, "type", o.Type, n.Type, Major)
+		} else if o.Type == ast.TypeEnum {
+			oldValues, newValues := values(o.Constraints), values(n.Constraints)
+			if removed := missing(oldValues, newValues); len(removed) > 0 {
+				d.add(Changed, subject, "enum values removed: "+strings.Join(removed, ", "), "", "", Major)
+			}
+			if added := missing(newValues, oldValues); len(added) > 0 {
+				d.add(Changed, subject, "enum values added: "+strings.Join(added, ", "), "", "", Minor)
+			}
+		}
+
+		oldDefault, newDefault := defaultValue(o), defaultValue(n)
+		switch {
+		case o.Default != nil && n.Default == nil:
+			d.add(Changed, subject, "default removed, the parameter is now required", oldDefault, "", Major)
+		case o.Default == nil && n.Default != nil:
+			d.add(Changed, subject, "default added, the parameter is now optional", "", newDefault, Minor)
+		case oldDefault != newDefault:
+			d.add(Changed, subject, "default", oldDefault, newDefault, Minor)
+		}
+
+		// A new condition can ignore a parameter its callers set
+		if oldWhen, newWhen := condition(o), condition(n); oldWhen != newWhen {
+			impact := Major
+			if newWhen == "" {
+				impact = Minor
+			}
+			d.add(Changed, subject, "condition", oldWhen, newWhen, impact)
+		}
+
+		if o.Description != n.Description {
+			d.add(Changed, subject, "description", o.Description, n.Description, Patch)
+		}
+		d.metadata(subject, o.Metadata, n.Metadata)
+	}
+}
+
+// metadata compares the metadata entries other than the description and
+// default, which are compared with the fields they set.
+func (d *differ) metadata(subject string, old, new map[string]string) {
+	keys := slices.Sorted(maps.Keys(old))
+	for _, k := range slices.Sorted(maps.Keys(new)) {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if k == "desc" || k == "default" || old[k] == new[k] {
+			continue
+		}
+		d.add(Changed, subject, k, old[k], new[k], Patch)
+	}
+}
+
+func (d *differ) implementations(old, new []ast.ImplementationBlock) {
+	// Blocks are matched by name, in order
+	matched := make([]bool, len(old))
+	for _, n := range new {
+		i := slices.IndexFunc(old, func(b ast.ImplementationBlock) bool { return b.Name == n.Name })
+		for i >= 0 && matched[i] {
+			i++
+			for i < len(old) && old[i].Name != n.Name {
+				i++
+			}
+			if i == len(old) {
+				i = -1
+			}
+		}
+		if i < 0 {
+			d.add(Added, "implementation "+n.Name, "", "", "", Major)
+			continue
+		}
+		matched[i] = true
+		o := old[i]
+
+		fields := slices.Sorted(maps.Keys(o.Fields))
+		for _, k := range slices.Sorted(maps.Keys(n.Fields)) {
+			if _, ok := o.Fields[k]; !ok {
+				fields = append(fields, k)
+			}
+		}
+		for _, field := range fields {
+			oldValue, newValue := fieldValue(o.Fields[field]), fieldValue(n.Fields[field])
+			if oldValue == newValue {
+				continue
+			}
+			impact := Major
+			if field == "image" {
+				// A new image keeps the interface, but may change the results
+				impact = Minor
+			}
+			d.add(Changed, fmt.Sprintf("%s of %s", field, n.Name), "", oldValue, newValue, impact)
+		}
+	}
+	for i, o := range old {
+		if !matched[i] {
+			d.add(Removed, "implementation "+o.Name, "", "", "", Major)
+		}
+	}
+}
+
+func (d *differ) outputs(old, new []ast.OutputBlock) {
+	for _, o := range old {
+		if !slices.ContainsFunc(new, func(b ast.OutputBlock) bool { return b.Name == o.Name }) {
+			d.add(Removed, fmt.Sprintf("output '%s'", o.Name), "", "", "", Major)
+		}
+	}
+	for _, n := range new {
+		subject := fmt.Sprintf("output '%s'", n.Name)
+		i := slices.IndexFunc(old, func(b ast.OutputBlock) bool { return b.Name == n.Name })
+		if i < 0 {
+			d.add(Added, subject, "", "", "", Minor)
+			continue
+		}
+		o := old[i]
+		if o.Format != n.Format {
+			d.add(Changed, subject, "format", o.Format, n.Format, Major)
+		}
+		if o.Path != n.Path {
+			d.add(Changed, subject, "path", o.Path, n.Path, Major)
+		}
+		if o.Description != n.Description {
+			d.add(Changed, subject, "description", o.Description, n.Description, Patch)
+		}
+		d.metadata(subject, o.Metadata, n.Metadata)
+	}
+}
+
+// resources compares the requested resources, which don't change the
+// interface of the program.
+func (d *differ) resources(old, new []ast.Resource) {
+	requested := func(resources []ast.Resource) map[string]string {
+		values := map[string]string{}
+		for _, r := range resources {
+			values[r.Name] = fmt.Sprint(r.Value)
+		}
+		return values
+	}
+	oldValues, newValues := requested(old), requested(new)
+	for _, r := range old {
+		if _, ok := newValues[r.Name]; !ok {
+			d.add(Removed, fmt.Sprintf("resource '%s'", r.Name), "", oldValues[r.Name], "", Patch)
+		}
+	}
+	for _, r := range new {
+		subject := fmt.Sprintf("resource '%s'", r.Name)
+		if o, ok := oldValues[r.Name]; !ok {
+			d.add(Added, subject, "", "", newValues[r.Name], Patch)
+		} else if o != newValues[r.Name] {
+			d.add(Changed, subject, "", o, newValues[r.Name], Patch)
+		}
+	}
+}
+
+func values(constraints []any) []string {
+	result := make([]string, len(constraints))
+	for i, c := range constraints {
+		result[i] = fmt.Sprint(c)
+	}
+	return result
+}
+
+// missing returns the values of a that are not in b.
+func missing(a, b []string) []string {
+	result := []string{}
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func defaultValue(p ast.Parameter) string {
+	if p.Default == nil {
+		return ""
+	}
+	return fmt.Sprint(p.Default)
+}
+
+// condition returns the condition of a parameter, e.g. "mode in [sensitive]".
+func condition(p ast.Parameter) string {
+	if p.When == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s in %v", p.When.Param, p.When.Values)
+}
+
+func fieldValue(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+const base = `
+(bala align (
+	(reads file (desc "Input reads"))
+	(mode enum ("fast" "sensitive") (default "fast"))
+	(run_docker
+		(image "biocontainers/bwa:0.7.17")
+		(arguments "bwa" reads))
+	(outputs
+		(bam file "/data/out.bam"))
+))
+`
+
+func parse(t *testing.T, input string) []Change {
+	t.Helper()
+	old, err := parser.New(lexer.New(base)).ParseProgram()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	new, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	return Programs(old, new)
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+		want    []string
+		bump    Impact
+	}{
+		{
+			name:    "optional parameter added",
+			replace: [2]string{`(run_docker`, `(threads integer (default 4)) (run_docker`},
+			want:    []string{`parameter 'threads' added: optional (minor)`},
+			bump:    Minor,
+		},
+		{
+			name:    "required parameter added",
+			replace: [2]string{`(run_docker`, `(index file) (run_docker`},
+			want:    []string{`parameter 'index' added: required (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "parameter removed",
+			replace: [2]string{`(reads file (desc "Input reads"))`, ``},
+			want:    []string{`parameter 'reads' removed (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "type changed",
+			replace: [2]string{`(reads file`, `(reads string`},
+			want:    []string{`parameter 'reads' changed: type: "file" -> "string" (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "enum values",
+			replace: [2]string{`("fast" "sensitive")`, `("fast" "exact")`},
+			want: []string{
+				`parameter 'mode' changed: enum values removed: sensitive (major)`,
+				`parameter 'mode' changed: enum values added: exact (minor)`,
+			},
+			bump: Major,
+		},
+		{
+			name:    "default changed",
+			replace: [2]string{`(default "fast")`, `(default "sensitive")`},
+			want:    []string{`parameter 'mode' changed: default: "fast" -> "sensitive" (minor)`},
+			bump:    Minor,
+		},
+		{
+			name:    "condition added",
+			replace: [2]string{`(reads file (desc "Input reads"))`, `(reads file (desc "Input reads") (when mode "sensitive"))`},
+			want:    []string{`parameter 'reads' changed: condition: "" -> "mode in [sensitive]" (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "image changed",
+			replace: [2]string{`bwa:0.7.17`, `bwa:0.7.18`},
+			want: []string{
+				`image of run_docker changed: "biocontainers/bwa:0.7.17" -> "biocontainers/bwa:0.7.18" (minor)`,
+			},
+			bump: Minor,
+		},
+		{
+			name:    "description changed",
+			replace: [2]string{`"Input reads"`, `"Reads to align"`},
+			want: []string{
+				`parameter 'reads' changed: description: "Input reads" -> "Reads to align" (patch)`,
+			},
+			bump: Patch,
+		},
+		{
+			name:    "arguments changed",
+			replace: [2]string{`"bwa" reads`, `"bwa" "mem" reads`},
+			want:    []string{`arguments of run_docker changed: "[bwa reads]" -> "[bwa mem reads]" (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "output removed",
+			replace: [2]string{`(bam file "/data/out.bam")`, ``},
+			want:    []string{`output 'bam' removed (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "implementation added",
+			replace: [2]string{`(outputs`, `(run_docker (image "samtools:1.19") (arguments "samtools")) (outputs`},
+			want:    []string{`implementation run_docker added (major)`},
+			bump:    Major,
+		},
+		{
+			name:    "resource requested",
+			replace: [2]string{`(outputs`, `(resources (cpus 4)) (outputs`},
+			want:    []string{`resource 'cpus' added: "" -> "4" (patch)`},
+			bump:    Patch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := parse(t, strings.Replace(base, tt.replace[0], tt.replace[1], 1))
+			got := make([]string, len(changes))
+			for i, c := range changes {
+				got[i] = c.String()
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got changes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if bump, ok := Bump(changes); !ok || bump != tt.bump {
+				t.Errorf("got bump %v, want %v", bump, tt.bump)
+			}
+		})
+	}
+}
+
+func TestPrograms_Unchanged(t *testing.T) {
+	changes := parse(t, base)
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+	if _, ok := Bump(changes); ok {
+		t.Error("expected no bump without changes")
+	}
+}
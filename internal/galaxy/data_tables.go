@@ -0,0 +1,29 @@
+package galaxy
+
+import "encoding/xml"
+
+// Tables describes the data tables of tools, in the
+// tool_data_table_conf.xml.sample of a repository that admins merge into
+// the configuration of their Galaxy.
+//
+// https://docs.galaxyproject.org/en/latest/dev/data_tables.html
+type Tables struct {
+	XMLName xml.Name `xml:"tables"`
+	Table   []Table  `xml:"table"`
+}
+
+// A data table: the names of its columns, in the order of the columns of
+// the tab-separated lines of its .loc file.
+type Table struct {
+	XMLName     xml.Name  `xml:"table"`
+	Name        string    `xml:"name,attr"`
+	CommentChar string    `xml:"comment_char,attr"`
+	Columns     string    `xml:"columns"`
+	File        TableFile `xml:"file"`
+}
+
+// The .loc file of a data table.
+type TableFile struct {
+	XMLName xml.Name `xml:"file"`
+	Path    string   `xml:"path,attr"`
+}
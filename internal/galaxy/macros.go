@@ -0,0 +1,27 @@
+package galaxy
+
+import "encoding/xml"
+
+// Macros are the definitions shared by the tools of a suite, usually
+// written in a macros.xml file that the tools import.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-macros
+type Macros struct {
+	XMLName xml.Name `xml:"macros"`
+	// The files of macros imported by a tool.
+	Import []string `xml:"import,omitempty"`
+	XML    []Macro  `xml:"xml,omitempty"`
+}
+
+// A named XML fragment, inserted in a tool by an <expand macro="name"/>.
+type Macro struct {
+	XMLName  xml.Name `xml:"xml"`
+	Name     string   `xml:"name,attr"`
+	Elements []any    `xml:",any"`
+}
+
+// Inserts the XML fragment of a macro.
+type Expand struct {
+	XMLName xml.Name `xml:"expand"`
+	Macro   string   `xml:"macro,attr"`
+}
@@ -0,0 +1,90 @@
+package galaxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Profiles are the Galaxy releases a tool can declare as its profile, the
+// release whose behavior it expects, from the oldest. Tools without a
+// profile get the behavior of the releases before 16.04.
+var Profiles = []string{
+	"16.04", "16.07", "16.10", "17.01", "17.05", "17.09", "18.01", "18.05", "18.09",
+	"19.01", "19.05", "19.09", "20.01", "20.05", "20.09", "21.01", "21.05", "21.09",
+	"22.01", "22.05", "23.0", "23.1", "23.2", "24.0", "24.1", "24.2",
+}
+
+// ProfileAtLeast reports whether a profile is the given release or a later
+// one. An empty profile is before every release.
+func ProfileAtLeast(profile, release string) bool {
+	return profile != "" && slices.Index(Profiles, profile) >= slices.Index(Profiles, release)
+}
+
+// detectErrors are the values of the detect_errors attribute of a command.
+var detectErrors = []string{"default", "exit_code", "aggressive"}
+
+// Implements Validable: the tool declares a known profile, and its
+// containers, params and outputs are valid for it.
+func (t Tool) Validate() error {
+	if t.Profile != "" && !slices.Contains(Profiles, t.Profile) {
+		return fmt.Errorf("Profile \"%s\" is not a known Galaxy release.", t.Profile)
+	}
+	if t.Id == "" || strings.ContainsAny(t.Id, " \t") {
+		return fmt.Errorf("Id \"%s\" must be non empty and without spaces.", t.Id)
+	}
+	if t.Command == nil {
+		return fmt.Errorf("Command has no value specified.")
+	}
+	if t.Command.DetectErrors != "" && !slices.Contains(detectErrors, t.Command.DetectErrors) {
+		return fmt.Errorf("Detect errors \"%s\" is not one of %s.", t.Command.DetectErrors, strings.Join(detectErrors, ", "))
+	}
+
+	validables := []Validable{}
+	if t.Requirements != nil {
+		for _, container := range t.Requirements.Container {
+			validables = append(validables, container)
+		}
+	}
+	if t.Inputs != nil {
+		for _, param := range inputParams(t.Inputs.Elements) {
+			validables = append(validables, param)
+		}
+	}
+	if t.Outputs != nil {
+		for _, data := range t.Outputs.Data {
+			validables = append(validables, data)
+		}
+		for _, collection := range t.Outputs.Collection {
+			for _, data := range collection.Data {
+				validables = append(validables, data)
+			}
+		}
+	}
+	for _, validable := range validables {
+		if err := validable.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inputParams returns the params of the inputs, including those of their
+// sections and conditionals.
+func inputParams(elements []any) []Param {
+	params := []Param{}
+	for _, element := range elements {
+		switch element := element.(type) {
+		case Param:
+			params = append(params, element)
+		case Section:
+			params = append(params, inputParams(element.Elements)...)
+		case Conditional:
+			params = append(params, element.Param)
+			for _, when := range element.When {
+				params = append(params, inputParams(when.Elements)...)
+			}
+		}
+	}
+	return params
+}
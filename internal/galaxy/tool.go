@@ -4,6 +4,8 @@ package galaxy
 import (
 	"encoding/xml"
 	"fmt"
+	"regexp"
+	"strconv"
 )
 
 // Validable represents a validable object.
@@ -21,16 +23,28 @@ type Tool struct {
 	//
 	// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-description
 	Description    string          `xml:"description"`
+	Macros         *Macros         `xml:"macros,omitempty"`
 	EdamTopics     *EdamTopics     `xml:"edam_topics,omitempty"`
 	EdamOperations *EdamOperations `xml:"edam_operations,omitempty"`
 	Xrefs          *Xrefs          `xml:"xrefs,omitempty"`
 	Creator        *Creator        `xml:"creator,omitempty"`
+	Expand         []Expand        `xml:"expand,omitempty"` // the requirements macro, instead of Requirements
 	Requirements   *Requirements   `xml:"requirements"`
+	Stdio          *Stdio          `xml:"stdio,omitempty"`
+	VersionCommand *VersionCommand `xml:"version_command,omitempty"`
 	Command        *Command        `xml:"command"`
+	Configfiles    *Configfiles    `xml:"configfiles,omitempty"`
 	Inputs         *Inputs         `xml:"inputs"`
 	Outputs        *Outputs        `xml:"outputs"`
+	Tests          *Tests          `xml:"tests,omitempty"`
+	Help           *Help           `xml:"help,omitempty"`
+	Citations      *Citations      `xml:"citations,omitempty"`
 	Id             string          `xml:"id,attr"`
 	Name           string          `xml:"name,attr"`
+	// This string allows for version numbering of the tool.
+	Version string `xml:"version,attr,omitempty"`
+	// The Galaxy release whose behavior the tool expects, e.g. 23.1.
+	Profile string `xml:"profile,attr,omitempty"`
 }
 
 // Container tag set for the <edam_topic> tags. A tool can have any number of
@@ -158,6 +172,73 @@ func (c Container) Validate() error {
 // https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-command
 type Command struct {
 	XMLName xml.Name `xml:"command"`
+	// One of default, exit_code or aggressive: whether a job fails on
+	// output to stderr or on a non-zero exit code.
+	DetectErrors string `xml:"detect_errors,attr,omitempty"`
+	Value        string `xml:",cdata"`
+}
+
+// Files Galaxy writes before running the command, from Cheetah templates
+// filled with the values of the params. The command refers to the path of
+// each as $name.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-configfiles
+type Configfiles struct {
+	XMLName    xml.Name     `xml:"configfiles"`
+	Configfile []Configfile `xml:"configfile"`
+}
+
+type Configfile struct {
+	XMLName xml.Name `xml:"configfile"`
+	Name    string   `xml:"name,attr"`
+	Value   string   `xml:",cdata"`
+}
+
+// Tools write output to two streams - standard output (stdout) and standard
+// error (stderr). Applications handle these streams differently, and the
+// stdio tag set describes how the exit codes and the output of a tool are
+// interpreted as warnings or errors.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-stdio
+type Stdio struct {
+	XMLName  xml.Name   `xml:"stdio"`
+	ExitCode []ExitCode `xml:"exit_code,omitempty"`
+	Regex    []Regex    `xml:"regex,omitempty"`
+}
+
+// Tools may use exit codes to indicate specific execution errors.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-stdio-exit-code
+type ExitCode struct {
+	XMLName xml.Name `xml:"exit_code"`
+	// This indicates the range of exit codes to check, e.g. 1: for all
+	// positive codes or 137 for a single one.
+	Range string `xml:"range,attr"`
+	// One of log, qc, warning, fatal or fatal_oom.
+	Level       string `xml:"level,attr,omitempty"`
+	Description string `xml:"description,attr,omitempty"`
+}
+
+// A regular expression defines a pattern of characters to match in the
+// output of a tool.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-stdio-regex
+type Regex struct {
+	XMLName xml.Name `xml:"regex"`
+	Match   string   `xml:"match,attr"`
+	// One of stdout, stderr or both.
+	Source      string `xml:"source,attr,omitempty"`
+	Level       string `xml:"level,attr,omitempty"`
+	Description string `xml:"description,attr,omitempty"`
+}
+
+// Specifies the command to be run in order to get the tool’s version string.
+// The resulting value will be found in the “Info” field of the history
+// dataset.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-version-command
+type VersionCommand struct {
+	XMLName xml.Name `xml:"version_command"`
 	Value   string   `xml:",cdata"`
 }
 
@@ -167,6 +248,9 @@ type Command struct {
 type Inputs struct {
 	XMLName xml.Name `xml:"inputs"`
 	Param   []Param  `xml:"param"`
+	// Elements are the params and macro expansions of a generated tool, in
+	// the order of the form. They are left empty when parsing a tool.
+	Elements []any `xml:",any"`
 }
 
 // Contained within the <inputs> tag set - each of these specifies a field that
@@ -180,13 +264,92 @@ type Param struct {
 	Type            string   `xml:"type,attr"`
 	Name            string   `xml:"name,omitempty,attr"`
 	Value           string   `xml:"value,omitempty,attr"`
-	Options         []Option `xml:"option"`
-	OptionsTag      *Options `xml:"options"`
-	Argument        string   `xml:"argument,omitempty"`
-	Label           string   `xml:"label,omitempty"`
-	Help            string   `xml:"help,omitempty"`
-	Optional        bool     `xml:"optional,omitempty"`
-	RefreshOnChange bool     `xml:"refresh_on_change,omitempty"`
+	Format          string   `xml:"format,omitempty,attr"`
+	Checked         string      `xml:"checked,omitempty,attr"`
+	Min             string      `xml:"min,omitempty,attr"`
+	Max             string      `xml:"max,omitempty,attr"`
+	Optional        bool        `xml:"optional,omitempty,attr"`
+	Options         []Option    `xml:"option"`
+	OptionsTag      *Options    `xml:"options"`
+	Argument        string      `xml:"argument,omitempty"`
+	Label           string      `xml:"label,omitempty"`
+	Help            string      `xml:"help,omitempty"`
+	Validators      []Validator `xml:"validator,omitempty"`
+	Sanitizer       *Sanitizer  `xml:"sanitizer,omitempty"`
+	RefreshOnChange bool        `xml:"refresh_on_change,omitempty"`
+}
+
+// A validator checks the value of a param in the tool form, e.g. a regex
+// validator holding the expression the value must match.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-param-validator
+type Validator struct {
+	XMLName xml.Name `xml:"validator"`
+	Type    string   `xml:"type,attr"`
+	Message string   `xml:"message,omitempty,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+// A sanitizer replaces the characters of a text param that are not valid
+// with invalid_char before the value reaches the command, an empty
+// invalid_char removing them.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-param-sanitizer
+type Sanitizer struct {
+	XMLName     xml.Name `xml:"sanitizer"`
+	InvalidChar string   `xml:"invalid_char,attr"`
+	Valid       *Valid   `xml:"valid,omitempty"`
+}
+
+// The characters a sanitizer keeps: the initial Python string constants,
+// e.g. string.ascii_letters, and the added characters.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-param-sanitizer-valid
+type Valid struct {
+	XMLName xml.Name       `xml:"valid"`
+	Initial string         `xml:"initial,attr"`
+	Add     []SanitizerAdd `xml:"add,omitempty"`
+}
+
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-param-sanitizer-valid-add
+type SanitizerAdd struct {
+	XMLName xml.Name `xml:"add"`
+	Value   string   `xml:"value,attr"`
+}
+
+// This is a container for conditional parameters in the tool (must contain
+// ‘when’ tag sets) - the command line (or portions thereof) are then wrapped
+// in an if-else statement.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-conditional
+type Conditional struct {
+	XMLName xml.Name `xml:"conditional"`
+	Name    string   `xml:"name,attr"`
+	// The select or boolean param whose value selects a when.
+	Param Param  `xml:"param"`
+	When  []When `xml:"when"`
+}
+
+// This directive is used to describe parameters in the tool interface that
+// are displayed for a value of the param of a conditional.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-conditional-when
+type When struct {
+	XMLName  xml.Name `xml:"when"`
+	Value    string   `xml:"value,attr"`
+	Elements []any    `xml:",any"`
+}
+
+// This tag is used to group parameters into sections, shown collapsed in
+// the tool form unless expanded is true.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-section
+type Section struct {
+	XMLName  xml.Name `xml:"section"`
+	Name     string   `xml:"name,attr"`
+	Title    string   `xml:"title,attr"`
+	Expanded bool     `xml:"expanded,attr"`
+	Elements []any    `xml:",any"`
 }
 
 // https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-param-options
@@ -239,8 +402,19 @@ func (p Param) Validate() error {
 	if _, ok := allowedType[p.Type]; !ok {
 		return fmt.Errorf("Type \"%s\" is not an allowed type.", p.Type)
 	}
-	if p.Optional && p.Value == "" {
-		return fmt.Errorf("Non optional parameter has no value specified.")
+	if p.Min != "" && p.Max != "" {
+		min, errMin := strconv.ParseFloat(p.Min, 64)
+		max, errMax := strconv.ParseFloat(p.Max, 64)
+		if errMin != nil || errMax != nil || min > max {
+			return fmt.Errorf("Range [%s, %s] of \"%s\" is not valid.", p.Min, p.Max, p.Name)
+		}
+	}
+	for _, validator := range p.Validators {
+		if validator.Type == "regex" {
+			if _, err := regexp.Compile(validator.Value); err != nil {
+				return fmt.Errorf("Regex validator of \"%s\" is not valid: %v.", p.Name, err)
+			}
+		}
 	}
 	return nil
 }
@@ -261,10 +435,25 @@ type Outputs struct {
 
 // https://docs.galaxyproject.org/en/master/dev/schema.html#tool-outputs-collection
 type Collection struct {
-	XMLName xml.Name `xml:"collection"`
-	Name    string   `xml:"name,attr"`
-	Type    string   `xml:"type,attr"` // e.g., "list", "paired", "list:paired"
-	Data    []Data   `xml:"data,omitempty"`
+	XMLName          xml.Name           `xml:"collection"`
+	Name             string             `xml:"name,attr"`
+	Type             string             `xml:"type,attr"` // e.g., "list", "paired", "list:paired"
+	Label            string             `xml:"label,omitempty,attr"`
+	Data             []Data             `xml:"data,omitempty"`
+	DiscoverDatasets []DiscoverDatasets `xml:"discover_datasets,omitempty"`
+}
+
+// Describes how the datasets of a collection are discovered in the files
+// the tool writes: the files of directory whose name matches pattern, a
+// regular expression naming the element with its designation group, or one
+// of the built-in patterns such as __name_and_ext__.
+//
+// https://docs.galaxyproject.org/en/master/dev/schema.html#tool-outputs-collection-discover-datasets
+type DiscoverDatasets struct {
+	XMLName   xml.Name `xml:"discover_datasets"`
+	Pattern   string   `xml:"pattern,attr"`
+	Directory string   `xml:"directory,omitempty,attr"`
+	Format    string   `xml:"format,omitempty,attr"`
 }
 
 // This tag set is contained within the <outputs> tag set, and it defines the
@@ -292,4 +481,124 @@ func (d Data) Validate() error {
 	return nil
 }
 
+// This tag set includes all of the necessary details of how to use the tool.
+// This tag set should be included as the next to the last tag set, before
+// citations, in the tool config. Tool help is written in reStructuredText.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-help
+type Help struct {
+	XMLName xml.Name `xml:"help"`
+	Value   string   `xml:",cdata"`
+}
+
+// Tool files may declare one citations element. Each citations element can
+// contain one or more citation tag elements - each of which specifies tool
+// citation information using either a DOI or a BibTeX entry.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-citations
+type Citations struct {
+	XMLName  xml.Name   `xml:"citations"`
+	Citation []Citation `xml:"citation"`
+}
+
+// Each citations element can contain one or more citation tag elements.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-citations-citation
+type Citation struct {
+	XMLName xml.Name `xml:"citation"`
+	// Type of citation - currently doi and bibtex are the only supported
+	// options.
+	Type  string `xml:"type,attr"`
+	Value string `xml:",innerxml"` // escaped XML, keeping the lines of BibTeX entries
+}
+
+// Container tag set to specify tests via the <test> tag sets. Any number of
+// tests can be included, and each test is wrapped within separate <test>
+// tag sets.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-tests
+type Tests struct {
+	XMLName xml.Name `xml:"tests"`
+	Test    []Test   `xml:"test"`
+}
+
+// This tag set contains the necessary parameter values for executing the
+// tool via the functional test framework.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-tests-test
+type Test struct {
+	XMLName          xml.Name           `xml:"test"`
+	Param            []TestParam        `xml:"param"`
+	Output           []TestOutput       `xml:"output"`
+	OutputCollection []OutputCollection `xml:"output_collection"`
+}
+
+// This tag set defines the tool's input parameters for executing the tool
+// via the functional test framework. Data values are files of the
+// test-data directory.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-tests-test-param
+type TestParam struct {
+	XMLName xml.Name `xml:"param"`
+	Name    string   `xml:"name,attr"`
+	Value   string   `xml:"value,attr"`
+}
+
+// This tag set defines the variable that names the output dataset for the
+// functional test framework, and the checks of its content.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-tests-test-output
+type TestOutput struct {
+	XMLName        xml.Name        `xml:"output"`
+	Name           string          `xml:"name,attr"`
+	File           string          `xml:"file,attr,omitempty"`
+	Checksum       string          `xml:"checksum,attr,omitempty"` // hash_type$hash_value
+	AssertContents *AssertContents `xml:"assert_contents,omitempty"`
+}
+
+// Defines a collection output for the functional test framework.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-tests-test-output-collection
+type OutputCollection struct {
+	XMLName xml.Name `xml:"output_collection"`
+	Name    string   `xml:"name,attr"`
+}
 
+// This tag set defines a sequence of checks or assertions to run against the
+// target output.
+//
+// https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-tests-test-output-assert-contents
+type AssertContents struct {
+	XMLName         xml.Name          `xml:"assert_contents"`
+	HasText         []HasText         `xml:"has_text"`
+	HasLine         []HasLine         `xml:"has_line"`
+	HasTextMatching []HasTextMatching `xml:"has_text_matching"`
+	HasNLines       []HasNLines       `xml:"has_n_lines"`
+}
+
+// Asserts the specified output contains the substring specified by the text
+// attribute.
+type HasText struct {
+	XMLName xml.Name `xml:"has_text"`
+	Text    string   `xml:"text,attr"`
+}
+
+// Asserts the specified output contains the line specified by the line
+// attribute.
+type HasLine struct {
+	XMLName xml.Name `xml:"has_line"`
+	Line    string   `xml:"line,attr"`
+}
+
+// Asserts the specified output contains text matching the regular
+// expression specified by the expression attribute.
+type HasTextMatching struct {
+	XMLName    xml.Name `xml:"has_text_matching"`
+	Expression string   `xml:"expression,attr"`
+}
+
+// Asserts that the specified output has n lines.
+type HasNLines struct {
+	XMLName xml.Name `xml:"has_n_lines"`
+	N       int      `xml:"n,attr"`
+}
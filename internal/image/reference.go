@@ -0,0 +1,120 @@
+// Package image handles container image references, following the grammar
+// of the OCI distribution specification.
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultTag is used by container engines when a reference has no tag.
+const DefaultTag = "latest"
+
+var (
+	domainPattern    = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?$`)
+	componentPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagPattern       = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
+
+// Reference is a parsed image reference, such as
+// "quay.io/biocontainers/samtools:1.17--h00cdaf9_0" or
+// "ubuntu@sha256:<hex>".
+type Reference struct {
+	Domain string // registry host, empty for the default registry
+	Path   string // repository path, e.g. "biocontainers/samtools"
+	Tag    string
+	Digest string
+}
+
+// Parse parses an image reference, returning an error if it is not
+// syntactically valid.
+func Parse(s string) (Reference, error) {
+	ref := Reference{}
+	if s == "" {
+		return ref, fmt.Errorf("image reference is empty")
+	}
+
+	name := s
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !digestPattern.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest '%s' in image reference '%s'", ref.Digest, s)
+		}
+	}
+
+	// A colon after the last slash separates the tag; earlier colons belong
+	// to the registry port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagPattern.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag '%s' in image reference '%s'", ref.Tag, s)
+		}
+	}
+
+	components := strings.Split(name, "/")
+	if len(components) > 1 && isDomain(components[0]) {
+		ref.Domain = components[0]
+		if !domainPattern.MatchString(ref.Domain) {
+			return ref, fmt.Errorf("invalid registry '%s' in image reference '%s'", ref.Domain, s)
+		}
+		components = components[1:]
+	}
+
+	for _, component := range components {
+		if !componentPattern.MatchString(component) {
+			return ref, fmt.Errorf("invalid repository name '%s' in image reference '%s'", name, s)
+		}
+	}
+	ref.Path = strings.Join(components, "/")
+
+	return ref, nil
+}
+
+// isDomain reports whether the first component of a name is a registry
+// host rather than part of the repository path.
+func isDomain(component string) bool {
+	return strings.ContainsAny(component, ".:") ||
+		component == "localhost" ||
+		strings.ToLower(component) != component
+}
+
+// Pinned reports whether the reference identifies a fixed image, that is it
+// has a digest or an explicit tag other than "latest".
+func (r Reference) Pinned() bool {
+	return r.Digest != "" || (r.Tag != "" && r.Tag != DefaultTag)
+}
+
+// Qualified returns the reference with the defaults container engines fill
+// in: the docker.io registry, the library namespace of its official images
+// and the latest tag. Engines without a default registry, such as podman,
+// need qualified references.
+func (r Reference) Qualified() Reference {
+	if r.Domain == "" || r.Domain == "index.docker.io" {
+		r.Domain = "docker.io"
+	}
+	if r.Domain == "docker.io" && !strings.Contains(r.Path, "/") {
+		r.Path = "library/" + r.Path
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = DefaultTag
+	}
+	return r
+}
+
+func (r Reference) String() string {
+	s := r.Path
+	if r.Domain != "" {
+		s = r.Domain + "/" + s
+	}
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package image
+
+import "testing"
+
+func TestParse_Valid(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		input    string
+		expected Reference
+	}{
+		{"ubuntu", Reference{Path: "ubuntu"}},
+		{"ubuntu:22.04", Reference{Path: "ubuntu", Tag: "22.04"}},
+		{"repbioinfo/qiime2023", Reference{Path: "repbioinfo/qiime2023"}},
+		{"quay.io/biocontainers/samtools:1.17--h00cdaf9_0",
+			Reference{Domain: "quay.io", Path: "biocontainers/samtools", Tag: "1.17--h00cdaf9_0"}},
+		{"localhost:5000/tools/bwa:0.7.17",
+			Reference{Domain: "localhost:5000", Path: "tools/bwa", Tag: "0.7.17"}},
+		{"ubuntu@" + digest, Reference{Path: "ubuntu", Digest: digest}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.expected)
+		}
+		if got.String() != tt.input {
+			t.Errorf("Parse(%q).String() = %q", tt.input, got.String())
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"Ubuntu",
+		"ubuntu:",
+		"ubuntu:bad tag",
+		"ubuntu@sha256:abc",
+		"my_registry.io//tool",
+		"-tool:1.0",
+	} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) expected error", input)
+		}
+	}
+}
+
+func TestReference_Pinned(t *testing.T) {
+	tests := map[string]bool{
+		"ubuntu":        false,
+		"ubuntu:latest": false,
+		"ubuntu:22.04":  true,
+		"ubuntu@sha256:0123456789abcdef0123456789abcdef": true,
+	}
+	for input, expected := range tests {
+		ref, err := Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", input, err)
+		}
+		if ref.Pinned() != expected {
+			t.Errorf("Parse(%q).Pinned() = %v, want %v", input, ref.Pinned(), expected)
+		}
+	}
+}
+
+func TestReference_Qualified(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"ubuntu", "docker.io/library/ubuntu:latest"},
+		{"ubuntu:22.04", "docker.io/library/ubuntu:22.04"},
+		{"biocontainers/bwa:0.7.17", "docker.io/biocontainers/bwa:0.7.17"},
+		{"index.docker.io/ubuntu@" + digest, "docker.io/library/ubuntu@" + digest},
+		{"quay.io/biocontainers/samtools:1.17--h00cdaf9_0", "quay.io/biocontainers/samtools:1.17--h00cdaf9_0"},
+		{"localhost:5000/bwa", "localhost:5000/bwa:latest"},
+	}
+	for _, tt := range tests {
+		ref, err := Parse(tt.input)
+		if err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", tt.input, err)
+		}
+		if got := ref.Qualified().String(); got != tt.expected {
+			t.Errorf("Parse(%q).Qualified() = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultRegistry is the registry of references without a domain.
+const DefaultRegistry = "registry-1.docker.io"
+
+// manifestMediaTypes are the manifest formats accepted when checking that an
+// image exists, covering single and multi-platform images.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Registry checks images against their container registry, using the
+// distribution API.
+type Registry struct {
+	Client *http.Client
+}
+
+// NewRegistry creates a Registry with a client that times out after the
+// given duration.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{Client: &http.Client{Timeout: timeout}}
+}
+
+// Exists checks that the manifest of a reference exists in its registry,
+// with a HEAD request. Anonymous tokens are requested when the registry asks
+// for them, as Docker Hub and most public registries do.
+func (reg *Registry) Exists(ref Reference) error {
+	_, err := reg.manifest(ref)
+	return err
+}
+
+// Digest returns the digest of the manifest a reference points to in its
+// registry, the one its tag currently designates.
+func (reg *Registry) Digest(ref Reference) (string, error) {
+	resp, err := reg.manifest(ref)
+	if err != nil {
+		return "", err
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if !digestPattern.MatchString(digest) {
+		return "", fmt.Errorf("registry of image '%s' returned no digest", ref)
+	}
+	return digest, nil
+}
+
+// manifest requests the manifest of a reference, returning the response
+// when it exists.
+func (reg *Registry) manifest(ref Reference) (*http.Response, error) {
+	domain, repository := ref.Domain, ref.Path
+	if domain == "" || domain == "docker.io" || domain == "index.docker.io" {
+		domain = DefaultRegistry
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	manifest := ref.Digest
+	if manifest == "" {
+		manifest = ref.Tag
+	}
+	if manifest == "" {
+		manifest = DefaultTag
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", domain, repository, manifest)
+
+	resp, err := reg.head(manifestURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("checking image '%s': %w", ref, err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := reg.token(resp.Header.Get("WWW-Authenticate"), repository)
+		if err != nil {
+			return nil, fmt.Errorf("authenticating to %s: %w", domain, err)
+		}
+		if resp, err = reg.head(manifestURL, token); err != nil {
+			return nil, fmt.Errorf("checking image '%s': %w", ref, err)
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("image '%s' not found in %s", ref, domain)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("image '%s' is not accessible in %s, it may not exist or be private", ref, domain)
+	}
+	return nil, fmt.Errorf("checking image '%s': unexpected status %s from %s", ref, resp.Status, domain)
+}
+
+func (reg *Registry) head(manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := reg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// token requests an anonymous pull token from the realm of a Bearer
+// challenge.
+func (reg *Registry) token(challenge, repository string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication scheme '%s'", scheme)
+	}
+	values := parseChallenge(params)
+	realm := values["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("missing realm in authentication challenge")
+	}
+
+	query := url.Values{}
+	if service := values["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+repository+":pull")
+	resp, err := reg.Client.Get(realm + "?" + query.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseChallenge parses the key="value" parameters of a WWW-Authenticate
+// header.
+func parseChallenge(params string) map[string]string {
+	values := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok {
+			values[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return values
+}
@@ -0,0 +1,70 @@
+package image
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeRegistry serves the manifests of the given repository:tag keys,
+// requiring an anonymous token like Docker Hub does. The digest of a
+// manifest is the one of its key, see fakeDigest.
+func fakeRegistry(t *testing.T, manifests map[string]bool) (*httptest.Server, *Registry) {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"token": "anonymous"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="registry.test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name, manifest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+		if r.Method != http.MethodHead || !manifests[name+":"+manifest] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", fakeDigest(name+":"+manifest))
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &Registry{Client: srv.Client()}
+}
+
+func fakeDigest(key string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(key)))
+}
+
+func TestRegistry_Exists(t *testing.T) {
+	srv, reg := fakeRegistry(t, map[string]bool{"tools/bwa:0.7.17": true})
+	domain := strings.TrimPrefix(srv.URL, "https://")
+
+	if err := reg.Exists(Reference{Domain: domain, Path: "tools/bwa", Tag: "0.7.17"}); err != nil {
+		t.Errorf("Exists() unexpected error: %v", err)
+	}
+
+	err := reg.Exists(Reference{Domain: domain, Path: "tools/bwa", Tag: "0.7.71"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Exists() expected not found error, got %v", err)
+	}
+}
+
+func TestRegistry_Digest(t *testing.T) {
+	srv, reg := fakeRegistry(t, map[string]bool{"tools/bwa:0.7.17": true})
+	domain := strings.TrimPrefix(srv.URL, "https://")
+
+	digest, err := reg.Digest(Reference{Domain: domain, Path: "tools/bwa", Tag: "0.7.17"})
+	if err != nil || digest != fakeDigest("tools/bwa:0.7.17") {
+		t.Errorf("Digest() = %q, %v, want %q", digest, err, fakeDigest("tools/bwa:0.7.17"))
+	}
+	if _, err := reg.Digest(Reference{Domain: domain, Path: "tools/bwa", Tag: "0.7.71"}); err == nil {
+		t.Error("Digest() expected error for a missing tag")
+	}
+}
@@ -2,6 +2,7 @@ package importer
 
 import (
 	"encoding/xml"
+	"fmt"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
@@ -17,6 +18,9 @@ var _ Importer = (*GalaxyImporter)(nil)
 // Export implements Importer.
 func (g *GalaxyImporter) Export() (string, error) {
 	g.Buffer.Reset()
+	if g.galaxyTool.Requirements == nil || len(g.galaxyTool.Requirements.Container) == 0 {
+		return "", fmt.Errorf("tool %s has no container requirement", g.galaxyTool.Name)
+	}
 
 	g.WriteLine("(bala %s (", g.galaxyTool.Name)
 	g.SetIndentLevel(g.GetIndentLevel() + 1)
@@ -68,7 +72,7 @@ func (g *GalaxyImporter) Export() (string, error) {
 	g.SetIndentLevel(g.GetIndentLevel() - 1)
 	g.WriteLine(")", "")
 
-	return "", nil
+	return g.Buffer.String(), nil
 }
 
 // Import implements Importer.
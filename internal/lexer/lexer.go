@@ -2,16 +2,17 @@ package lexer
 
 import (
 	"iter"
-	"strings"
+	"unicode/utf8"
 )
 
+// Lexer splits a program into tokens. It scans the input forward only,
+// decoding it as UTF-8: strings and comments may hold any character, and
+// columns count characters rather than bytes.
 type Lexer struct {
-	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
-	line         int  // current line number
-	column       int  // current column number
+	input  string
+	offset int // byte offset of the next character
+	line   int // line of the next character
+	column int // column of the next character, in characters
 }
 
 type TokenType int
@@ -47,248 +48,179 @@ func (tt TokenType) String() string {
 	return "UNKNOWN"
 }
 
+// Token is a token of the input. Line and Column are 1-based, the column
+// counting characters; Offset and End are byte offsets.
 type Token struct {
 	Type    TokenType
 	Literal string
 	Line    int
 	Column  int
+	Offset  int // byte offset of the first character in the input
+	End     int // byte offset past the last character in the input
 }
 
+// byteOrderMark may start UTF-8 files written by some editors.
+const byteOrderMark = '\uFEFF'
+
 // Creates a new Lexer.
 func New(input string) *Lexer {
-	lexer := &Lexer{
-		input: input,
-		line:  1,
+	lexer := &Lexer{input: input, line: 1, column: 1}
+	if r, size := utf8.DecodeRuneInString(input); r == byteOrderMark {
+		lexer.offset = size
 	}
-	lexer.readChar() // Initialize ch, position, readPosition, column
 	return lexer
 }
 
-// readChar reads the next character and advances the position.
-func (l *Lexer) readChar() {
-	startColumn := l.column
-	if l.readPosition >= len(l.input) {
-		l.ch = 0 // EOF
-	} else {
-		l.ch = l.input[l.readPosition]
-	}
-
-	l.position = l.readPosition
-	l.readPosition++
+// Input returns the source read by the lexer, which the Offset and End of
+// its tokens index.
+func (l *Lexer) Input() string {
+	return l.input
+}
 
-	if l.ch == '\n' {
-		l.line++
-		l.column = 0 // Reset column after newline
-	} else {
-		// Only increment column if it's not a newline
-		l.column++
-	}
-	// Handle potential '\r\n' - if we just read \r, peek for \n
-	if l.ch == '\r' && l.peekChar() == '\n' {
-		l.readChar() // Consume the \n, readChar handles line/col update
-	} else if l.ch == '\r' { // Handle standalone \r as newline
-		l.line++
-		l.column = 0
+// peek returns the next character, 0 at the end of the input. Invalid
+// UTF-8 is returned as utf8.RuneError, one byte at a time.
+func (l *Lexer) peek() (rune, int) {
+	if l.offset >= len(l.input) {
+		return 0, 0
 	}
-
-	// If column reset due to newline, ensure it starts at 1 for the next char
-	if startColumn > 0 && l.column == 0 {
-		l.column = 1
-	} else if startColumn == 0 && l.column == 0 && l.ch != 0 {
-		// Initial character or after newline
-		l.column = 1
+	if b := l.input[l.offset]; b < utf8.RuneSelf {
+		return rune(b), 1
 	}
+	return utf8.DecodeRuneInString(l.input[l.offset:])
 }
 
-// peekChar looks ahead without consuming the character.
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
+// advance consumes the next character. "\r\n", "\n" and "\r" end a line.
+func (l *Lexer) advance() {
+	r, size := l.peek()
+	l.offset += size
+	switch {
+	case r == '\r' && l.offset < len(l.input) && l.input[l.offset] == '\n':
+		l.offset++
+		fallthrough
+	case r == '\n' || r == '\r':
+		l.line++
+		l.column = 1
+	case size > 0:
+		l.column++
 	}
-	return l.input[l.readPosition]
 }
 
 // skipWhitespace skips spaces, tabs, and newlines/carriage returns.
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	for {
+		switch r, _ := l.peek(); r {
+		case ' ', '\t', '\n', '\r':
+			l.advance()
+		default:
+			return
+		}
 	}
 }
 
-// readString reads a string literal enclosed in double or single quotes.
-// It handles basic escape sequences for the quote character itself.
-func (l *Lexer) readString(quoteType byte) string {
-	position := l.position + 1 // Skip the opening quote
-	var sb strings.Builder
+// readString reads a string literal enclosed in double or single quotes,
+// returning its content as written: a backslash escapes the character
+// after it, which is kept with it. An unterminated string runs to the end
+// of the input.
+func (l *Lexer) readString(quote rune) string {
+	l.advance() // the opening quote
+	start := l.offset
 	for {
-		prevCh := l.ch
-		l.readChar()
-		if l.ch == quoteType {
-			// Check for escaped quote
-			if prevCh == '\\' {
-				// This means we have an escaped quote, continue reading
-				currentContent := sb.String()
-				if len(currentContent) > 0 {
-					sb.Reset()
-					sb.WriteString(currentContent[:len(currentContent)-1])
-				}
-				sb.WriteByte(quoteType) // Add the actual quote char
-				continue
-			}
-			// End of string found
-			break
-		}
-		if l.ch == 0 { // EOF before closing quote
-			break
+		r, size := l.peek()
+		switch {
+		case size == 0:
+			return l.input[start:]
+		case r == quote:
+			end := l.offset
+			l.advance()
+			return l.input[start:end]
+		case r == '\\':
+			l.advance()
 		}
-		sb.WriteByte(l.ch)
-		// if we see \, peek next. If it's a quote, skip \
-		// TODO: More complex escapes (\n, \t etc.) are not handled here but could be added.
-		if l.ch == '\\' && l.peekChar() == quoteType {
-			// Read the escaped quote in the next iteration
-			l.readChar()
-		}
-	}
-	// TODO: remove the escape characters (\).
-	// A more robust implementation would build the string char by char, handling escapes.
-	str := l.input[position:l.position]
-	// TODO: Add proper escape sequence processing if needed. For now, return raw content.
-	if l.ch == quoteType {
-		l.readChar() // Consume the closing quote
+		l.advance()
 	}
-
-	return str
 }
 
 // readComment reads from ';' to the end of the line.
 func (l *Lexer) readComment() string {
-	position := l.position + 1 // Skip the semicolon
-	for l.ch != '\n' && l.ch != '\r' && l.ch != 0 {
-		l.readChar()
+	l.advance() // the semicolon
+	start := l.offset
+	for r, size := l.peek(); size > 0 && r != '\n' && r != '\r'; r, size = l.peek() {
+		l.advance()
 	}
-	return l.input[position:l.position] // Excludes the newline
+	return l.input[start:l.offset] // Excludes the newline
 }
 
-// readIdentifier reads a sequence of letters, digits, or underscores.
-func (l *Lexer) readIdentifier() string {
-	position := l.position
-	// Allow leading underscore
-	if isLetter(l.ch) || l.ch == '_' {
-		l.readChar()
-		for isLetter(l.ch) || isDigit(l.ch) || l.ch == '_' {
-			l.readChar()
-		}
+// readWhile reads the characters accepted by accept.
+func (l *Lexer) readWhile(accept func(r rune) bool) string {
+	start := l.offset
+	for r, size := l.peek(); size > 0 && accept(r); r, size = l.peek() {
+		l.advance()
 	}
-	// Backtrack one char because the loop reads one past the identifier
-	l.readPosition--
-	l.position--
-	if l.input[l.position] == '\n' { // Correct column if backtrack crossed newline
-		l.line--
-		// Calculate previous line's length (tricky, maybe store last line length?)
-		// For simplicity, reset column - less accurate but avoids complexity
-		l.column = 0 // Less accurate, but simpler
-	} else {
-		l.column--
-	}
-	l.ch = l.input[l.position] // Restore char
-
-	ident := l.input[position:l.readPosition]
-
-	// Advance again for the next token read
-	l.readChar()
-	return ident
+	return l.input[start:l.offset]
 }
 
-// readNumber reads an integer or floating-point number.
+// readNumber reads an integer or floating-point number, with at most one
+// dot.
 func (l *Lexer) readNumber() string {
-	position := l.position
 	hasDot := false
-	for isDigit(l.ch) || (l.ch == '.' && !hasDot) {
-		if l.ch == '.' {
+	return l.readWhile(func(r rune) bool {
+		if r == '.' && !hasDot {
 			hasDot = true
+			return true
 		}
-		l.readChar()
-	}
-	// Backtrack one char
-	l.readPosition--
-	l.position--
-	if l.input[l.position] == '\n' {
-		l.line--
-		l.column = 0 // Simpler column handling
-	} else {
-		l.column--
-	}
-	l.ch = l.input[l.position]
-
-	numStr := l.input[position:l.readPosition]
-
-	// Advance again
-	l.readChar()
-	return numStr
+		return isDigit(r)
+	})
 }
 
-// Token generates the sequence of tokens.
+// Token generates the sequence of tokens, ending with TOKEN_EOF.
 func (l *Lexer) Token() iter.Seq[Token] {
 	return func(yield func(Token) bool) {
 		for {
 			l.skipWhitespace()
+			tok := Token{Line: l.line, Column: l.column, Offset: l.offset}
 
-			tok := Token{Line: l.line, Column: l.column} // Capture position before consuming char
-
-			currentChar := l.ch // Character that determines the token type
-
-			// Handle single-character tokens first
-			switch currentChar {
-			case '(':
+			switch r, size := l.peek(); {
+			case size == 0:
+				tok.Type = TOKEN_EOF
+			case r == '(':
 				tok.Type, tok.Literal = TOKEN_LPAREN, "("
-				l.readChar() // Consume '('
-			case ')':
+				l.advance()
+			case r == ')':
 				tok.Type, tok.Literal = TOKEN_RPAREN, ")"
-				l.readChar() // Consume ')'
-			case '"':
-				tok.Type = TOKEN_STRING
-				// readString consumes the closing quote
-				tok.Literal = l.readString('"')
-			case '\'':
-				tok.Type = TOKEN_CHARACTER
-				// readString consumes the closing quote
-				tok.Literal = l.readString('\'')
-			case ';':
-				tok.Type = TOKEN_COMMENT
-				// readComment consumes until newline
-				tok.Literal = l.readComment()
-				// Do not consume the newline itself here, let skipWhitespace handle it
-			case 0:
-				tok.Type, tok.Literal = TOKEN_EOF, ""
-				// Don't consume EOF
+				l.advance()
+			case r == '"':
+				tok.Type, tok.Literal = TOKEN_STRING, l.readString(r)
+			case r == '\'':
+				tok.Type, tok.Literal = TOKEN_CHARACTER, l.readString(r)
+			case r == ';':
+				// The newline is left for skipWhitespace
+				tok.Type, tok.Literal = TOKEN_COMMENT, l.readComment()
+			case isLetter(r) || r == '_':
+				// A letter or underscore followed by letters, digits,
+				// underscores, dashes or dots
+				tok.Type, tok.Literal = TOKEN_IDENTIFIER, l.readWhile(func(r rune) bool {
+					return isLetter(r) || isDigit(r) || r == '_' || r == '-' || r == '.'
+				})
+			case isDigit(r):
+				tok.Type, tok.Literal = TOKEN_NUMBER, l.readNumber()
 			default:
-				// Multi-character tokens
-				if isLetter(currentChar) || currentChar == '_' {
-					// readIdentifier consumes the identifier chars + 1 extra
-					tok.Type, tok.Literal = TOKEN_IDENTIFIER, l.readIdentifier()
-				} else if isDigit(currentChar) {
-					// readNumber consumes the number chars + 1 extra
-					tok.Type, tok.Literal = TOKEN_NUMBER, l.readNumber()
-				} else {
-					// Unrecognized character
-					tok.Type, tok.Literal = TOKEN_ILLEGAL, string(currentChar)
-					l.readChar() // Consume the illegal character
-				}
+				// Unrecognized character, or byte of invalid UTF-8
+				tok.Type, tok.Literal = TOKEN_ILLEGAL, l.input[l.offset:l.offset+size]
+				l.advance()
 			}
+			tok.End = l.offset
 
-			// Yield the token
 			if !yield(tok) || tok.Type == TOKEN_EOF {
-				break // Stop iteration if yield returns false or EOF is reached
+				return
 			}
 		}
 	}
 }
 
-// Helper functions (keep as before)
-func isLetter(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+func isLetter(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
 }
 
-func isDigit(ch byte) bool {
-	return '0' <= ch && ch <= '9'
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
 }
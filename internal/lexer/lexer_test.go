@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"os"
 	"testing"
 )
 
@@ -53,3 +54,145 @@ func TestLexer(t *testing.T) {
 		}
 	}
 }
+
+func TestLexer_LineNumbers(t *testing.T) {
+	input := "(bala\nname\n42\n\"s\")"
+	expectedLines := []int{1, 1, 2, 3, 4, 4}
+	result := collectTokens(New(input))
+	for i, line := range expectedLines {
+		if result[i].Line != line {
+			t.Errorf("token %d (%q): expected line %d, got %d", i, result[i].Literal, line, result[i].Line)
+		}
+	}
+}
+
+func TestLexer_Offsets(t *testing.T) {
+	input := "(name \"a b\")\n; c\n"
+	expected := [][2]int{{0, 1}, {1, 5}, {6, 11}, {11, 12}, {13, 16}, {17, 17}}
+	result := collectTokens(New(input))
+	if len(result) != len(expected) {
+		t.Fatalf("wrong number of tokens: expected %d, got %d", len(expected), len(result))
+	}
+	for i, span := range expected {
+		if result[i].Offset != span[0] || result[i].End != span[1] {
+			t.Errorf("token %d (%q): expected span %v, got [%d %d]", i, result[i].Literal, span, result[i].Offset, result[i].End)
+		}
+	}
+}
+
+func TestLexer_IdentifierPunctuation(t *testing.T) {
+	result := collectTokens(New("(sample-id read.length -x)"))
+	expected := []string{"(", "sample-id", "read.length", "-", "x", ")", ""}
+	if len(result) != len(expected) {
+		t.Fatalf("wrong number of tokens: expected %d, got %+v", len(expected), result)
+	}
+	for i, literal := range expected {
+		if result[i].Literal != literal {
+			t.Errorf("token %d: expected %q, got %q", i, literal, result[i].Literal)
+		}
+	}
+}
+
+func TestLexer_Columns(t *testing.T) {
+	// Columns start at 1 on every line, whatever its newline
+	input := "(bala\n  name\r\n\t42\r\"s\")"
+	expected := [][2]int{{1, 1}, {1, 2}, {2, 3}, {3, 2}, {4, 1}, {4, 4}}
+	result := collectTokens(New(input))
+	for i, position := range expected {
+		if result[i].Line != position[0] || result[i].Column != position[1] {
+			t.Errorf("token %d (%q): expected line %d, column %d, got %d, %d",
+				i, result[i].Literal, position[0], position[1], result[i].Line, result[i].Column)
+		}
+	}
+}
+
+func TestLexer_UTF8(t *testing.T) {
+	input := "\uFEFF(desc \"Lectures d'entrée\") ; résumé\n(é \xff)"
+	expected := []Token{
+		{Type: TOKEN_LPAREN, Literal: "(", Line: 1, Column: 1, Offset: 3, End: 4},
+		{Type: TOKEN_IDENTIFIER, Literal: "desc", Line: 1, Column: 2, Offset: 4, End: 8},
+		{Type: TOKEN_STRING, Literal: "Lectures d'entrée", Line: 1, Column: 7, Offset: 9, End: 29},
+		{Type: TOKEN_RPAREN, Literal: ")", Line: 1, Column: 26, Offset: 29, End: 30},
+		{Type: TOKEN_COMMENT, Literal: " résumé", Line: 1, Column: 28, Offset: 31, End: 41},
+		{Type: TOKEN_LPAREN, Literal: "(", Line: 2, Column: 1, Offset: 42, End: 43},
+		{Type: TOKEN_ILLEGAL, Literal: "é", Line: 2, Column: 2, Offset: 43, End: 45},
+		{Type: TOKEN_ILLEGAL, Literal: "\xff", Line: 2, Column: 4, Offset: 46, End: 47},
+		{Type: TOKEN_RPAREN, Literal: ")", Line: 2, Column: 5, Offset: 47, End: 48},
+		{Type: TOKEN_EOF, Line: 2, Column: 6, Offset: 48, End: 48},
+	}
+	result := collectTokens(New(input))
+	if len(result) != len(expected) {
+		t.Fatalf("wrong number of tokens: expected %d, got %+v", len(expected), result)
+	}
+	for i, tok := range expected {
+		if result[i] != tok {
+			t.Errorf("token %d: expected %+v, got %+v", i, tok, result[i])
+		}
+	}
+}
+
+func TestLexer_Strings(t *testing.T) {
+	tests := []struct {
+		input   string
+		literal string
+		next    string
+	}{
+		{`"say \"hi\"" x`, `say \"hi\"`, "x"},
+		{`"back\\" x`, `back\\`, "x"},
+		{`'\'' x`, `\'`, "x"},
+		{"\"two\nlines\" x", "two\nlines", "x"},
+		{`"unterminated`, "unterminated", ""},
+	}
+	for _, tt := range tests {
+		result := collectTokens(New(tt.input))
+		if result[0].Literal != tt.literal || result[1].Literal != tt.next {
+			t.Errorf("%q: expected %q then %q, got %+v", tt.input, tt.literal, tt.next, result)
+		}
+	}
+}
+
+// BenchmarkLexer lexes the example program, see also "baryon bench".
+func BenchmarkLexer(b *testing.B) {
+	data, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		collectTokens(New(string(data)))
+	}
+}
+
+// FuzzLexer checks that any input is split into tokens that follow each
+// other and end with TOKEN_EOF. The seed corpus is in testdata/fuzz.
+func FuzzLexer(f *testing.F) {
+	data, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(string(data))
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens := collectTokens(New(input))
+		// Each token but the last consumes at least a byte
+		if len(tokens) > len(input)+1 {
+			t.Fatalf("%d tokens for %d bytes", len(tokens), len(input))
+		}
+		end := 0
+		for i, tok := range tokens {
+			if tok.Offset < end || tok.End > len(input) || tok.Line < 1 || tok.Column < 1 {
+				t.Fatalf("token %d %+v out of place after offset %d", i, tok, end)
+			}
+			if (tok.Type == TOKEN_EOF) != (i == len(tokens)-1) || (tok.Type != TOKEN_EOF && tok.End <= tok.Offset) {
+				t.Fatalf("token %d %+v: unexpected end", i, tok)
+			}
+			switch tok.Type {
+			case TOKEN_LPAREN, TOKEN_RPAREN, TOKEN_IDENTIFIER, TOKEN_NUMBER, TOKEN_ILLEGAL:
+				if tok.Literal != input[tok.Offset:tok.End] {
+					t.Fatalf("token %d %+v: literal isn't its source %q", i, tok, input[tok.Offset:tok.End])
+				}
+			}
+			end = tok.End
+		}
+	})
+}
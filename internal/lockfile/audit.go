@@ -0,0 +1,140 @@
+package lockfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// Resolver returns the digest an image reference currently points to, as
+// image.Registry.Digest does.
+type Resolver func(image.Reference) (string, error)
+
+// Update locks the images of a program that aren't locked yet, or all of
+// them when all is set, and unlocks the ones it no longer uses. It returns
+// the entries that were added or changed.
+func Update(lock *Lockfile, program *ast.Program, resolve Resolver, all bool) ([]Entry, error) {
+	images := Images(program)
+	for _, entry := range slices.Clone(lock.Entries) {
+		if !slices.Contains(images, entry.Image) {
+			lock.Remove(entry.Image)
+		}
+	}
+	changed := []Entry{}
+	for _, img := range images {
+		locked, ok := lock.Digest(img)
+		if ok && !all {
+			continue
+		}
+		ref, _ := image.Parse(img)
+		digest, err := resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		if digest != locked {
+			lock.Set(img, digest)
+			changed = append(changed, Entry{Image: img, Digest: digest})
+		}
+	}
+	return changed, nil
+}
+
+// The kinds of audit findings.
+const (
+	Drifted    = "drifted"    // the tag points to another digest than the locked one
+	YankedTag  = "yanked"     // the tag was withdrawn by its publisher
+	Unlocked   = "unlocked"   // the image is not in the lockfile
+	Stale      = "stale"      // the lockfile has an image the program doesn't use
+	Unresolved = "unresolved" // the registry couldn't be queried
+)
+
+// Finding is an image of the lockfile or of the program that needs
+// attention.
+type Finding struct {
+	Image   string
+	Kind    string
+	Message string
+}
+
+// Audit compares the images of a program with its lockfile and the
+// registries, reporting the unlocked, stale, drifted and yanked ones.
+func Audit(lock *Lockfile, program *ast.Program, resolve Resolver, yanked Yanked) []Finding {
+	findings := []Finding{}
+	images := Images(program)
+	for _, img := range images {
+		if reason, ok := yanked.Reason(img); ok {
+			message := fmt.Sprintf("image '%s' has a yanked tag", img)
+			if reason != "" {
+				message += ": " + reason
+			}
+			findings = append(findings, Finding{Image: img, Kind: YankedTag, Message: message})
+		}
+		locked, ok := lock.Digest(img)
+		if !ok {
+			findings = append(findings, Finding{Image: img, Kind: Unlocked,
+				Message: fmt.Sprintf("image '%s' is not locked", img)})
+			continue
+		}
+		ref, _ := image.Parse(img)
+		digest, err := resolve(ref)
+		switch {
+		case err != nil:
+			findings = append(findings, Finding{Image: img, Kind: Unresolved, Message: err.Error()})
+		case digest != locked:
+			findings = append(findings, Finding{Image: img, Kind: Drifted,
+				Message: fmt.Sprintf("image '%s' now points to %s, locked to %s", img, digest, locked)})
+		}
+	}
+	for _, entry := range lock.Entries {
+		if !slices.Contains(images, entry.Image) {
+			findings = append(findings, Finding{Image: entry.Image, Kind: Stale,
+				Message: fmt.Sprintf("locked image '%s' is not used by the program", entry.Image)})
+		}
+	}
+	return findings
+}
+
+// Yanked maps the yanked image tags, in their qualified form, to the
+// reason they were yanked for.
+type Yanked map[string]string
+
+// ParseYanked parses a list of yanked tags, with an image reference per
+// line followed by an optional reason:
+//
+//	biocontainers/bwa:0.7.16 corrupted index files
+func ParseYanked(data []byte) (Yanked, error) {
+	yanked := Yanked{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		img, reason, _ := strings.Cut(text, " ")
+		ref, err := image.Parse(img)
+		if err != nil || ref.Digest != "" {
+			return nil, fmt.Errorf("line %d: invalid image tag '%s'", line, img)
+		}
+		yanked[ref.Qualified().String()] = strings.TrimSpace(reason)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return yanked, nil
+}
+
+// Reason returns why the tag of an image was yanked, reporting whether it
+// was.
+func (y Yanked) Reason(img string) (string, bool) {
+	ref, err := image.Parse(img)
+	if err != nil {
+		return "", false
+	}
+	reason, ok := y[ref.Qualified().String()]
+	return reason, ok
+}
@@ -0,0 +1,89 @@
+package lockfile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// resolver resolves the images of the given digests, counting the
+// lookups.
+func resolver(digests map[string]string, lookups *int) Resolver {
+	return func(ref image.Reference) (string, error) {
+		*lookups++
+		if digest, ok := digests[ref.String()]; ok {
+			return digest, nil
+		}
+		return "", errors.New("image '" + ref.String() + "' not found")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	program := parse(t, pipeline)
+	lookups := 0
+	resolve := resolver(map[string]string{
+		"biocontainers/bwa:0.7.17":    digestB,
+		"biocontainers/samtools:1.17": digestB,
+	}, &lookups)
+	lock := &Lockfile{Entries: []Entry{{"biocontainers/bwa:0.7.17", digestA}, {"biocontainers/gone:1", digestA}}}
+
+	// Only the unlocked images are resolved, and the unused ones unlocked
+	changed, err := Update(lock, program, resolve, false)
+	if err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	expected := []Entry{{"biocontainers/bwa:0.7.17", digestA}, {"biocontainers/samtools:1.17", digestB}}
+	if lookups != 1 || !reflect.DeepEqual(lock.Entries, expected) || len(changed) != 1 {
+		t.Errorf("Update() = %+v after %d lookups, entries %+v", changed, lookups, lock.Entries)
+	}
+
+	// All of them with all
+	changed, err = Update(lock, program, resolve, true)
+	if err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []Entry{{"biocontainers/bwa:0.7.17", digestB}}) {
+		t.Errorf("Update(all) changed = %+v, want the drifted bwa", changed)
+	}
+
+	if _, err := Update(&Lockfile{}, program, resolver(nil, &lookups), false); err == nil {
+		t.Error("Update() expected an error for an unresolved image")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	program := parse(t, pipeline)
+	lookups := 0
+	resolve := resolver(map[string]string{"biocontainers/bwa:0.7.17": digestB}, &lookups)
+	yanked, err := ParseYanked([]byte("# withdrawn tags\ndocker.io/biocontainers/bwa:0.7.17 corrupted index\nubuntu:20.04\n"))
+	if err != nil {
+		t.Fatalf("ParseYanked() unexpected error: %v", err)
+	}
+	lock := &Lockfile{Entries: []Entry{{"biocontainers/bwa:0.7.17", digestA}, {"biocontainers/gone:1", digestA}}}
+
+	kinds := map[string][]string{}
+	for _, finding := range Audit(lock, program, resolve, yanked) {
+		kinds[finding.Image] = append(kinds[finding.Image], finding.Kind)
+	}
+	expected := map[string][]string{
+		"biocontainers/bwa:0.7.17":    {YankedTag, Drifted},
+		"biocontainers/samtools:1.17": {Unlocked},
+		"biocontainers/gone:1":        {Stale},
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Audit() = %v, want %v", kinds, expected)
+	}
+
+	lock.Set("biocontainers/samtools:1.17", digestB)
+	for _, finding := range Audit(lock, program, resolve, nil) {
+		if finding.Image == "biocontainers/samtools:1.17" && finding.Kind != Unresolved {
+			t.Errorf("expected samtools to be unresolved, got %+v", finding)
+		}
+	}
+
+	if _, err := ParseYanked([]byte("ubuntu@" + digestA)); err == nil {
+		t.Error("ParseYanked() expected error for a digest")
+	}
+}
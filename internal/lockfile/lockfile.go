@@ -0,0 +1,128 @@
+// Package lockfile reads and writes the lockfiles of programs, which pin
+// the digest each container image resolved to when it was locked, and
+// audits them against the registries. The lockfile of align.bala is
+// align.bala.lock, with a line per image:
+//
+//	# comments
+//	biocontainers/bwa:0.7.17 sha256:<digest>
+package lockfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// Extension is appended to the path of a program to name its lockfile.
+const Extension = ".lock"
+
+// Path returns the path of the lockfile of a program.
+func Path(program string) string {
+	return program + Extension
+}
+
+// Entry is the digest an image was locked to.
+type Entry struct {
+	Image  string // as written in the program
+	Digest string // e.g. "sha256:..."
+}
+
+// Lockfile is the list of the locked images, sorted by image.
+type Lockfile struct {
+	Entries []Entry
+}
+
+// Parse parses the content of a lockfile.
+func Parse(data []byte) (*Lockfile, error) {
+	lock := &Lockfile{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected an image and its digest", line)
+		}
+		if ref, err := image.Parse(fields[0]); err != nil || ref.Digest != "" {
+			return nil, fmt.Errorf("line %d: invalid image '%s', expected a reference without digest", line, fields[0])
+		}
+		if _, err := image.Parse(fields[0] + "@" + fields[1]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid digest '%s'", line, fields[1])
+		}
+		if _, ok := lock.Digest(fields[0]); ok {
+			return nil, fmt.Errorf("line %d: image '%s' is locked twice", line, fields[0])
+		}
+		lock.Set(fields[0], fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Format returns the content of the lockfile, for the program of the
+// given name.
+func (l *Lockfile) Format(program string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Image digests of %s, written by \"baryon lock\"\n", program)
+	for _, entry := range l.Entries {
+		fmt.Fprintf(&b, "%s %s\n", entry.Image, entry.Digest)
+	}
+	return b.Bytes()
+}
+
+// Digest returns the digest an image is locked to.
+func (l *Lockfile) Digest(img string) (string, bool) {
+	i, found := l.find(img)
+	if !found {
+		return "", false
+	}
+	return l.Entries[i].Digest, true
+}
+
+// Set locks an image to a digest.
+func (l *Lockfile) Set(img, digest string) {
+	i, found := l.find(img)
+	if found {
+		l.Entries[i].Digest = digest
+		return
+	}
+	l.Entries = slices.Insert(l.Entries, i, Entry{Image: img, Digest: digest})
+}
+
+// Remove unlocks an image.
+func (l *Lockfile) Remove(img string) {
+	if i, found := l.find(img); found {
+		l.Entries = slices.Delete(l.Entries, i, i+1)
+	}
+}
+
+func (l *Lockfile) find(img string) (int, bool) {
+	return slices.BinarySearchFunc(l.Entries, img, func(e Entry, img string) int {
+		return strings.Compare(e.Image, img)
+	})
+}
+
+// Images returns the images of the implementations of a program that can
+// be locked, each once, in order: the valid references without a digest,
+// as the ones with a digest are pinned already.
+func Images(program *ast.Program) []string {
+	images := []string{}
+	for _, impl := range program.Implementations {
+		img, ok := impl.Fields["image"].(string)
+		if !ok || slices.Contains(images, img) {
+			continue
+		}
+		if ref, err := image.Parse(img); err == nil && ref.Digest == "" {
+			images = append(images, img)
+		}
+	}
+	return images
+}
@@ -0,0 +1,68 @@
+package lockfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+var (
+	digestA = "sha256:" + strings.Repeat("a", 64)
+	digestB = "sha256:" + strings.Repeat("b", 64)
+)
+
+const pipeline = `(bala align (
+	(reads file (desc "Input reads"))
+	(run_docker (image "biocontainers/bwa:0.7.17") (arguments "bwa" reads))
+	(run_docker (image "biocontainers/samtools:1.17") (arguments "samtools"))
+	(run_docker (image "biocontainers/bwa:0.7.17") (arguments "bwa"))
+	(run_docker (image "ubuntu@` + "sha256:0000000000000000000000000000000000000000000000000000000000000000" + `") (arguments "ls"))
+))`
+
+func parse(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	program, err := parser.New(lexer.New(source)).ParseProgram()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	return program
+}
+
+func TestImages(t *testing.T) {
+	expected := []string{"biocontainers/bwa:0.7.17", "biocontainers/samtools:1.17"}
+	if images := Images(parse(t, pipeline)); !reflect.DeepEqual(images, expected) {
+		t.Errorf("Images() = %v, want %v", images, expected)
+	}
+}
+
+func TestParse_Format(t *testing.T) {
+	data := "# comment\nbiocontainers/samtools:1.17 " + digestB + "\n\nbiocontainers/bwa:0.7.17 " + digestA + "\n"
+	lock, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	expected := []Entry{{"biocontainers/bwa:0.7.17", digestA}, {"biocontainers/samtools:1.17", digestB}}
+	if !reflect.DeepEqual(lock.Entries, expected) {
+		t.Errorf("Parse() = %+v, want %+v", lock.Entries, expected)
+	}
+	formatted := string(lock.Format("align.bala"))
+	if !strings.HasPrefix(formatted, "# Image digests of align.bala") ||
+		!strings.HasSuffix(formatted, "biocontainers/bwa:0.7.17 "+digestA+"\nbiocontainers/samtools:1.17 "+digestB+"\n") {
+		t.Errorf("Format() = %q", formatted)
+	}
+
+	for _, input := range []string{
+		"biocontainers/bwa:0.7.17",
+		"biocontainers/bwa:0.7.17 sha256:xyz",
+		"ubuntu@" + digestA + " " + digestA,
+		"bwa:1 " + digestA + "\nbwa:1 " + digestB,
+	} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) expected error", input)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package naming maps Baryon parameter names to valid identifiers of each
+// target language.
+//
+// A name is mangled for a target as follows:
+//   - every character other than an ASCII letter, digit or '_' becomes '_';
+//   - a name starting with a digit, or with '_' where the target doesn't
+//     allow it, gets the "p_" prefix;
+//   - a reserved word of the target, or a name the generated code defines
+//     such as dry_run, gets a '_' suffix.
+//
+// The "target_name" metadata of a parameter overrides the mangled name.
+package naming
+
+import (
+	"slices"
+	"strings"
+)
+
+// MetadataKey is the parameter metadata overriding the target name, as in
+// (sample-id string (target_name "sample_id")).
+const MetadataKey = "target_name"
+
+// Rules describes the identifiers of a target language.
+type Rules struct {
+	Reserved []string
+	// NoLeadingUnderscore is set when identifiers can't start with '_'.
+	NoLeadingUnderscore bool
+}
+
+var targets = map[string]Rules{
+	"r": {
+		NoLeadingUnderscore: true,
+		Reserved: []string{
+			"if", "else", "repeat", "while", "function", "for", "in", "next", "break",
+			"TRUE", "FALSE", "NULL", "Inf", "NaN", "NA", "NA_integer_", "NA_real_",
+			"NA_character_", "NA_complex_",
+			// the arguments of the generated wrappers
+			"dry_run", "output_dir",
+		},
+	},
+	"python": {
+		Reserved: []string{
+			"False", "None", "True", "and", "as", "assert", "async", "await", "break",
+			"class", "continue", "def", "del", "elif", "else", "except", "finally",
+			"for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal",
+			"not", "or", "pass", "raise", "return", "try", "while", "with", "yield",
+			// the arguments of the generated wrappers
+			"dry_run", "output_dir",
+		},
+	},
+	"nextflow": {
+		Reserved: []string{
+			"abstract", "as", "assert", "boolean", "break", "byte", "case", "catch",
+			"char", "class", "const", "continue", "def", "default", "do", "double",
+			"else", "enum", "extends", "false", "final", "finally", "float", "for",
+			"goto", "if", "implements", "import", "in", "instanceof", "int",
+			"interface", "long", "native", "new", "null", "package", "private",
+			"protected", "public", "return", "short", "static", "strictfp", "super",
+			"switch", "synchronized", "this", "throw", "throws", "transient", "true",
+			"try", "var", "void", "volatile", "while",
+			// the params of the generated pipelines
+			"outdir",
+		},
+	},
+	"bash": {
+		// the option of the generated scripts
+		Reserved: []string{"dry_run"},
+	},
+	"galaxy": {},
+}
+
+// Targets returns the names of the targets with naming rules, sorted.
+func Targets() []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Mangle returns the identifier a name maps to in a target. Names of
+// targets without rules are returned unchanged.
+func Mangle(target, name string) string {
+	rules, ok := targets[target]
+	if !ok || name == "" {
+		return name
+	}
+
+	var sb strings.Builder
+	for _, c := range name {
+		if isIdentifierChar(c) {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	mangled := sb.String()
+
+	if isDigit(mangled[0]) || (rules.NoLeadingUnderscore && mangled[0] == '_') {
+		mangled = "p_" + mangled
+	}
+	if slices.Contains(rules.Reserved, mangled) {
+		mangled += "_"
+	}
+	return mangled
+}
+
+// Valid reports whether a name is a valid identifier of a target as is.
+func Valid(target, name string) bool {
+	return Mangle(target, name) == name
+}
+
+// TargetName returns the identifier of a parameter in a target: its
+// "target_name" metadata if set, its mangled name otherwise.
+func TargetName(target, name string, metadata map[string]string) string {
+	if override := metadata[MetadataKey]; override != "" {
+		return override
+	}
+	return Mangle(target, name)
+}
+
+// EnvVariable returns the environment variable overriding the default of
+// a parameter in the command lines of a tool, BARYON_<TOOL>_<PARAM>: the
+// names are upper-cased, with every character other than an ASCII letter or
+// digit replaced by '_'.
+func EnvVariable(tool, name string) string {
+	var sb strings.Builder
+	sb.WriteString("BARYON_")
+	for _, c := range strings.ToUpper(tool) + "_" + strings.ToUpper(name) {
+		if isIdentifierChar(c) {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+func isIdentifierChar(c rune) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,56 @@
+package naming
+
+import "testing"
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		target, name, expected string
+	}{
+		{"python", "input_file", "input_file"},
+		{"python", "input-file", "input_file"},
+		{"r", "sample.id", "sample_id"},
+		{"nextflow", "2nd_pass", "p_2nd_pass"},
+		{"r", "_hidden", "p__hidden"},
+		{"python", "_hidden", "_hidden"},
+		{"python", "lambda", "lambda_"},
+		{"r", "function", "function_"},
+		{"nextflow", "default", "default_"},
+		{"bash", "default", "default"},
+		{"python", "dry_run", "dry_run_"},
+		{"bash", "dry_run", "dry_run_"},
+		{"r", "output_dir", "output_dir_"},
+		{"nextflow", "outdir", "outdir_"},
+		{"galaxy", "min-len", "min_len"},
+		{"unknown", "min-len", "min-len"},
+	}
+	for _, tt := range tests {
+		if got := Mangle(tt.target, tt.name); got != tt.expected {
+			t.Errorf("Mangle(%q, %q) = %q, want %q", tt.target, tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestTargetName(t *testing.T) {
+	metadata := map[string]string{MetadataKey: "sample"}
+	if got := TargetName("python", "sample-id", metadata); got != "sample" {
+		t.Errorf("TargetName() = %q, want the target_name override", got)
+	}
+	if got := TargetName("python", "sample-id", nil); got != "sample_id" {
+		t.Errorf("TargetName() = %q, want %q", got, "sample_id")
+	}
+}
+
+func TestEnvVariable(t *testing.T) {
+	tests := []struct {
+		tool, name, expected string
+	}{
+		{"align_reads", "threads", "BARYON_ALIGN_READS_THREADS"},
+		{"align-reads", "min.len", "BARYON_ALIGN_READS_MIN_LEN"},
+		{"fastqc", "dry_run_", "BARYON_FASTQC_DRY_RUN_"},
+	}
+	for _, tt := range tests {
+		if got := EnvVariable(tt.tool, tt.name); got != tt.expected {
+			t.Errorf("EnvVariable(%q, %q) = %q, want %q", tt.tool, tt.name, got, tt.expected)
+		}
+	}
+}
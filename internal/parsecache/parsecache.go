@@ -0,0 +1,140 @@
+// Package parsecache caches the syntax trees of programs on disk, in the
+// JSON form of "baryon ast", keyed by the SHA-256 of their source. Builds
+// that read the same unchanged programs again, for several targets or at
+// each run, decode their tree instead of parsing them.
+package parsecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+// Cache is a directory of cached syntax trees. A Cache without a directory,
+// or a nil one, parses every program.
+type Cache struct {
+	Dir string
+	// MaxDepth is the number of nested lists the programs may have, set
+	// with parser.SetMaxDepth; 0 removes the limit.
+	MaxDepth int
+}
+
+// DefaultDir returns the cache directory of the syntax trees, under the
+// user cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "baryon", "ast"), nil
+}
+
+// Parse returns the syntax tree of a source, from the cache when it has
+// it. Programs that don't parse aren't cached, so that their errors are
+// reported each time; failing to write the cache doesn't fail the parse.
+func (c *Cache) Parse(source string) (*ast.Program, error) {
+	if c == nil {
+		return parse(source, parser.DefaultMaxDepth)
+	}
+	if c.Dir == "" {
+		return parse(source, c.MaxDepth)
+	}
+	path := c.path(source)
+	if data, err := os.ReadFile(path); err == nil {
+		program := &ast.Program{}
+		if json.Unmarshal(data, program) == nil {
+			return program, nil
+		}
+	}
+
+	program, err := parse(source, c.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(program); err == nil {
+		c.write(path, data)
+	}
+	return program, nil
+}
+
+// path returns the file of the tree of a source, named after its key. Trees
+// parsed with another depth limit have another key, as the source may not
+// parse with this one.
+func (c *Cache) path(source string) string {
+	key := Key(fmt.Sprintf("%d\x00%s", c.MaxDepth, source))
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// write replaces a cache file at once, so that concurrent builds never read
+// it partially written.
+func (c *Cache) write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// Key returns the cache key of a source: the SHA-256 of the source and of
+// the version of the parser, so that a new build of baryon-lang doesn't
+// reuse the trees of an older one.
+func Key(source string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00", version())
+	hash.Write([]byte(source))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// version identifies the build of the parser: its VCS revision when it was
+// built from a checkout, otherwise the size and time of the executable.
+var version = sync.OnceValue(func() string {
+	id := fmt.Sprintf("ast/%d", ast.SchemaVersion)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		revision, modified := "", ""
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value
+			}
+		}
+		if revision != "" && modified != "true" {
+			return id + "/" + revision
+		}
+	}
+	if executable, err := os.Executable(); err == nil {
+		if stat, err := os.Stat(executable); err == nil {
+			return fmt.Sprintf("%s/%d/%d", id, stat.Size(), stat.ModTime().UnixNano())
+		}
+	}
+	return id
+})
+
+func parse(source string, maxDepth int) (*ast.Program, error) {
+	p := parser.New(lexer.New(source))
+	p.SetMaxDepth(maxDepth)
+	return p.ParseProgram()
+}
@@ -0,0 +1,120 @@
+package parsecache
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+func TestCache_Parse(t *testing.T) {
+	data, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(data)
+	expected, err := parse(source, parser.DefaultMaxDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &Cache{Dir: t.TempDir(), MaxDepth: parser.DefaultMaxDepth}
+	for _, name := range []string{"miss", "hit"} {
+		program, err := cache.Parse(source)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(program, expected) {
+			t.Errorf("%s: program differs from the parsed one", name)
+		}
+		if _, err := os.Stat(cache.path(source)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestCache_ParseHit(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir(), MaxDepth: parser.DefaultMaxDepth}
+	source := `(bala align (image "aligner:1.0"))`
+	if _, err := cache.Parse(source); err != nil {
+		t.Fatal(err)
+	}
+	// The cached tree is used rather than the source
+	other := `(bala other (image "aligner:1.0"))`
+	cached, err := os.ReadFile(cache.path(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.write(cache.path(other), cached); err != nil {
+		t.Fatal(err)
+	}
+	program, err := cache.Parse(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.Name != "align" {
+		t.Errorf("Name = %q, want the cached align", program.Name)
+	}
+}
+
+func TestCache_ParseCorrupt(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir(), MaxDepth: parser.DefaultMaxDepth}
+	source := `(bala align (image "aligner:1.0"))`
+	if err := cache.write(cache.path(source), []byte(`{"schema_version": 1, "name": `)); err != nil {
+		t.Fatal(err)
+	}
+	program, err := cache.Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.Name != "align" {
+		t.Errorf("Name = %q, want align", program.Name)
+	}
+	// The entry is replaced by the parsed tree
+	data, err := os.ReadFile(cache.path(source))
+	if err != nil || !strings.Contains(string(data), `"align"`) {
+		t.Errorf("cache entry = %q, %v", data, err)
+	}
+}
+
+func TestCache_ParseError(t *testing.T) {
+	cache := &Cache{Dir: t.TempDir(), MaxDepth: parser.DefaultMaxDepth}
+	source := `(bala align`
+	if _, err := cache.Parse(source); err == nil {
+		t.Fatal("Parse() = nil error, want a parsing error")
+	}
+	if _, err := os.Stat(cache.path(source)); !os.IsNotExist(err) {
+		t.Errorf("programs that don't parse are cached: %v", err)
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	var cache *Cache
+	program, err := cache.Parse(`(bala align (image "aligner:1.0"))`)
+	if err != nil || program.Name != "align" {
+		t.Errorf("Parse() = %v, %v", program, err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("(bala a)") == Key("(bala b)") {
+		t.Error("different sources have the same key")
+	}
+	if Key("(bala a)") != Key("(bala a)") {
+		t.Error("the key of a source changes")
+	}
+}
+
+func TestCache_ParseMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	source := `(bala align ((run_docker (image "aligner:1.0") (command "align"))))`
+	if _, err := (&Cache{Dir: dir, MaxDepth: 10}).Parse(source); err != nil {
+		t.Fatal(err)
+	}
+	// The tree cached under a higher limit isn't reused
+	if _, err := (&Cache{Dir: dir, MaxDepth: 3}).Parse(source); err == nil {
+		t.Error("Parse() = nil error, want the depth limit exceeded")
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+)
+
+// deprecate records that the source from the start of node to end is a
+// deprecated construct, to be rewritten as replacement.
+func (p *Parser) deprecate(node *SExpr, end int, construct, replacement string) {
+	p.deprecations = append(p.deprecations, ast.Deprecation{
+		Pos:         tokenPosition(node.Token),
+		End:         end,
+		Construct:   construct,
+		Replacement: replacement,
+	})
+}
+
+// deprecateFlatEnum records the (name enum "A" "B") form, replaced by
+// (name (enum ("A" "B"))).
+func (p *Parser) deprecateFlatEnum(enumNode *SExpr, values []*SExpr) {
+	if len(values) == 0 {
+		return
+	}
+	// The values keep their quotes and escapes, as when formatted
+	f := &formatter{source: p.source}
+	quoted := []string{}
+	for _, value := range values {
+		items := []*SExpr{value}
+		if isList(value) {
+			items = value.Children
+		}
+		for _, item := range items {
+			if item.Token.Type == lexer.TOKEN_STRING {
+				quoted = append(quoted, f.text(item))
+			}
+		}
+	}
+	p.deprecate(enumNode, values[len(values)-1].End,
+		"the flat 'enum \"A\" \"B\"' form",
+		fmt.Sprintf("(enum (%s))", strings.Join(quoted, " ")))
+}
+
+// deprecateKeywordString records the old "parent-folder" spelling of the
+// parent_folder keyword, quoted or not.
+func (p *Parser) deprecateKeywordString(node *SExpr) {
+	if node.Token.Literal != "parent-folder" {
+		return
+	}
+	switch node.Token.Type {
+	case lexer.TOKEN_STRING:
+		p.deprecate(node, node.End, `the "parent-folder" string`, "parent_folder")
+	case lexer.TOKEN_IDENTIFIER:
+		p.deprecate(node, node.End, "the 'parent-folder' keyword", "parent_folder")
+	}
+}
+
+// deprecateUppercaseBoolean records the R-style TRUE and FALSE literals,
+// replaced by true and false.
+func (p *Parser) deprecateUppercaseBoolean(node *SExpr) {
+	if node.Token.Type != lexer.TOKEN_IDENTIFIER {
+		return
+	}
+	if literal := node.Token.Literal; literal == "TRUE" || literal == "FALSE" {
+		p.deprecate(node, node.End, "the '"+literal+"' literal", strings.ToLower(literal))
+	}
+}
+
+// ApplyFixes rewrites the deprecated constructs of a source to their
+// current form. Overlapping constructs are left for a later pass.
+func ApplyFixes(source string, deprecations []ast.Deprecation) string {
+	fixes := append([]ast.Deprecation{}, deprecations...)
+	sort.Slice(fixes, func(i, j int) bool { return fixes[i].Pos.Offset > fixes[j].Pos.Offset })
+
+	limit := len(source)
+	for _, fix := range fixes {
+		if fix.End > limit || fix.Pos.Offset > fix.End {
+			continue
+		}
+		source = source[:fix.Pos.Offset] + fix.Replacement + source[fix.End:]
+		limit = fix.Pos.Offset
+	}
+	return source
+}
+
+// maxFixPasses bounds the passes of Migrate; each pass fixes at least one
+// construct, and constructs overlap at most a few levels deep.
+const maxFixPasses = 10
+
+// Migrate rewrites every deprecated construct of a source to its current
+// form, in as many passes as overlapping constructs need. It returns the
+// rewritten source and the constructs fixed, in the order of the passes.
+func Migrate(source string) (string, []ast.Deprecation, error) {
+	fixed := []ast.Deprecation{}
+	for range maxFixPasses {
+		program, err := New(lexer.New(source)).ParseProgram()
+		if err != nil {
+			if len(fixed) > 0 {
+				return "", nil, fmt.Errorf("rewritten program does not parse: %w", err)
+			}
+			return "", nil, err
+		}
+		if len(program.Deprecations) == 0 {
+			return source, fixed, nil
+		}
+		source = ApplyFixes(source, program.Deprecations)
+		fixed = append(fixed, program.Deprecations...)
+	}
+	return "", nil, fmt.Errorf("deprecated constructs are left after %d passes", maxFixPasses)
+}
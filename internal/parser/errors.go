@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// ErrSyntax matches every ParseError with errors.Is.
+var ErrSyntax = errors.New("syntax error")
+
+// Code identifies the kind of a ParseError, for callers that handle some
+// differently, e.g. an editor completing an unclosed list.
+type Code string
+
+const (
+	CodeUnexpectedEOF   Code = "unexpected-eof"   // the input ends before the program
+	CodeUnclosedList    Code = "unclosed-list"    // a list misses its closing parenthesis
+	CodeMaxDepth        Code = "max-depth"        // lists nested deeper than the limit
+	CodeInvalidProgram  Code = "invalid-program"  // not a (bala <name> (...)) program
+	CodeUnexpectedToken Code = "unexpected-token" // an atom where a list is expected
+	CodeMalformedBlock  Code = "malformed-block"  // an entry of a block not of its shape
+	CodeUnknownSection  Code = "unknown-section"  // an unknown section of a test
+)
+
+// ParseError is a syntax error at a position of the source. ParseProgram
+// joins the errors it finds with errors.Join, errors.As extracts the first.
+type ParseError struct {
+	Pos     ast.Position
+	Code    Code
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Is makes errors.Is(err, ErrSyntax) report true.
+func (e *ParseError) Is(target error) bool {
+	return target == ErrSyntax
+}
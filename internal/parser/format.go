@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// formatWidth is the line length up to which lists are kept on one line.
+const formatWidth = 80
+
+// Format prints a program in canonical form: lists that fit are kept on one
+// line, the others have one element per line, indented by two spaces, and
+// the entries of the program body are indented under the (bala name (
+// header. Comments are kept, and runs of blank lines are reduced to one.
+func Format(source string) (string, error) {
+	tree, err := ParseTree(source)
+	if err != nil {
+		return "", err
+	}
+	// Only valid programs are formatted
+	if _, err := tree.Program(); err != nil {
+		return "", err
+	}
+
+	f := &formatter{source: source, comments: tree.Comments}
+	var sb strings.Builder
+	end := 0
+	for _, c := range f.commentsIn(0, tree.Root.Token.Offset) {
+		sb.WriteString(f.blankLine(end, c.Pos.Offset, end > 0))
+		sb.WriteString(";" + c.Text + "\n")
+		end = commentEnd(c)
+	}
+	sb.WriteString(f.blankLine(end, tree.Root.Token.Offset, end > 0))
+	sb.WriteString(f.program(tree.Root) + "\n")
+	end = tree.Root.End
+	for _, c := range f.commentsIn(tree.Root.End, len(source)) {
+		if c.Trailing && end == tree.Root.End {
+			trimmed := strings.TrimSuffix(sb.String(), "\n")
+			sb.Reset()
+			sb.WriteString(trimmed + " ;" + c.Text + "\n")
+		} else {
+			sb.WriteString(f.blankLine(end, c.Pos.Offset, true))
+			sb.WriteString(";" + c.Text + "\n")
+		}
+		end = commentEnd(c)
+	}
+	return sb.String(), nil
+}
+
+type formatter struct {
+	source   string
+	comments []ast.Comment
+}
+
+// formatItem is a child of a list or a comment between its children.
+type formatItem struct {
+	node    *SExpr
+	comment *ast.Comment
+}
+
+func (i formatItem) start() int {
+	if i.comment != nil {
+		return i.comment.Pos.Offset
+	}
+	return i.node.Token.Offset
+}
+
+func (i formatItem) end() int {
+	if i.comment != nil {
+		return commentEnd(*i.comment)
+	}
+	return i.node.End
+}
+
+func commentEnd(c ast.Comment) int {
+	return c.Pos.Offset + len(";"+c.Text)
+}
+
+// commentsIn returns the comments starting between start and end.
+func (f *formatter) commentsIn(start, end int) []ast.Comment {
+	found := []ast.Comment{}
+	for _, c := range f.comments {
+		if start <= c.Pos.Offset && c.Pos.Offset < end {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// items returns the children of a list, with the comments between them,
+// in source order.
+func (f *formatter) items(node *SExpr) []formatItem {
+	comments := f.commentsIn(node.Token.Offset, node.End)
+	items := []formatItem{}
+	for _, child := range node.Children {
+		for len(comments) > 0 && comments[0].Pos.Offset < child.Token.Offset {
+			items = append(items, formatItem{comment: &comments[0]})
+			comments = comments[1:]
+		}
+		// Skip the comments inside the child
+		for len(comments) > 0 && comments[0].Pos.Offset < child.End {
+			comments = comments[1:]
+		}
+		items = append(items, formatItem{node: child})
+	}
+	for i := range comments {
+		items = append(items, formatItem{comment: &comments[i]})
+	}
+	return items
+}
+
+// blankLine returns an empty line if the source leaves one between the
+// offsets, and a line may be added there.
+func (f *formatter) blankLine(start, end int, allowed bool) string {
+	if allowed && start <= end && strings.Count(f.source[start:end], "\n") >= 2 {
+		return "\n"
+	}
+	return ""
+}
+
+// program prints the (bala name (body)) list, with the entries of the body
+// on their own lines.
+func (f *formatter) program(root *SExpr) string {
+	if len(root.Children) != 3 || !isList(root.Children[2]) ||
+		len(f.commentsIn(root.Token.Offset, root.Children[2].Token.Offset)) > 0 ||
+		len(f.commentsIn(root.Children[2].End, root.End)) > 0 {
+		return f.list(root, 0)
+	}
+	body := root.Children[2]
+	var sb strings.Builder
+	sb.WriteString("(" + f.text(root.Children[0]) + " " + f.text(root.Children[1]) + " (")
+	f.lines(&sb, f.items(body), body.Token.Offset+1, 2)
+	sb.WriteString("\n))")
+	return sb.String()
+}
+
+// lines prints items on their own lines at an indentation, trailing
+// comments at the end of the previous line.
+func (f *formatter) lines(sb *strings.Builder, items []formatItem, end, indent int) {
+	for i, item := range items {
+		if item.comment != nil && item.comment.Trailing {
+			sb.WriteString(" ;" + item.comment.Text)
+		} else {
+			sb.WriteString("\n" + f.blankLine(end, item.start(), i > 0))
+			sb.WriteString(strings.Repeat(" ", indent) + f.render(item, indent))
+		}
+		end = item.end()
+	}
+}
+
+func (f *formatter) render(item formatItem, indent int) string {
+	if item.comment != nil {
+		return ";" + item.comment.Text
+	}
+	return f.list(item.node, indent)
+}
+
+// list prints a node starting at an indentation: on one line if it fits
+// and has no comments, otherwise with its leading atoms on the first line
+// and an element per line.
+func (f *formatter) list(node *SExpr, indent int) string {
+	if !isList(node) {
+		return f.text(node)
+	}
+	if len(f.commentsIn(node.Token.Offset, node.End)) == 0 {
+		if flat := f.flat(node); indent+len(flat) <= formatWidth {
+			return flat
+		}
+	}
+
+	items := f.items(node)
+	var sb strings.Builder
+	sb.WriteString("(")
+	i := 0
+	for ; i < len(items) && items[i].node != nil && !isList(items[i].node); i++ {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(f.text(items[i].node))
+	}
+	childIndent := indent + 2
+	end := node.Token.Offset + 1
+	if i == 0 && len(items) > 0 && items[0].node != nil {
+		// ((a b) (c d)): the first list follows the parenthesis and the
+		// others are aligned with it
+		childIndent = indent + 1
+		sb.WriteString(f.list(items[0].node, childIndent))
+		i = 1
+	}
+	if i > 0 {
+		end = items[i-1].end()
+	}
+	f.lines(&sb, items[i:], end, childIndent)
+
+	if len(items) > 0 && items[len(items)-1].comment != nil {
+		// Don't close the list in a comment
+		sb.WriteString("\n" + strings.Repeat(" ", indent))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// flat prints a node on one line.
+func (f *formatter) flat(node *SExpr) string {
+	if !isList(node) {
+		return f.text(node)
+	}
+	parts := make([]string, len(node.Children))
+	for i, child := range node.Children {
+		parts[i] = f.flat(child)
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// text returns the source of an atom, keeping its quotes and escapes.
+func (f *formatter) text(node *SExpr) string {
+	return f.source[node.Token.Offset:node.Token.End]
+}
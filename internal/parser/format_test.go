@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{
+			"canonical layout",
+			`(bala  tool(
+(reads   file (desc "Reads"))
+    (run_docker (image "ubuntu:22.04") (arguments "cat" reads "--a-long-option-name" "--another-long-option" "value"))
+)
+)`,
+			`(bala tool (
+  (reads file (desc "Reads"))
+  (run_docker
+    (image "ubuntu:22.04")
+    (arguments "cat" reads "--a-long-option-name" "--another-long-option" "value"))
+))
+`,
+		},
+		{
+			"comments and blank lines",
+			`; header
+
+(bala tool ( ; body
+  (desc "Tool") ; trailing
+
+
+
+  ; leading
+  (run_docker (image "ubuntu:22.04") ; image
+    (volumes ((reads "/data") (ref "/ref"))) (arguments reads ; last
+  ))))
+; footer
+`,
+			`; header
+
+(bala tool ( ; body
+  (desc "Tool") ; trailing
+
+  ; leading
+  (run_docker
+    (image "ubuntu:22.04") ; image
+    (volumes ((reads "/data") (ref "/ref")))
+    (arguments reads ; last
+    ))
+))
+; footer
+`,
+		},
+	}
+	for _, tt := range tests {
+		formatted, err := Format(tt.input)
+		if err != nil {
+			t.Fatalf("%s: Format() unexpected error: %v", tt.name, err)
+		}
+		if formatted != tt.expected {
+			t.Errorf("%s: Format() =\n%s\nwant\n%s", tt.name, formatted, tt.expected)
+		}
+		if again, _ := Format(formatted); again != formatted {
+			t.Errorf("%s: Format() is not idempotent:\n%s", tt.name, again)
+		}
+	}
+
+	if _, err := Format(`(bala tool ((desc "x")`); err == nil {
+		t.Error("Format() expected error for an unbalanced program")
+	}
+}
+
+func TestFormat_KeepsProgram(t *testing.T) {
+	files, _ := filepath.Glob("../../examples/*.bala")
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := Format(string(data))
+		if err != nil {
+			t.Fatalf("%s: Format() unexpected error: %v", file, err)
+		}
+		before, _ := parseInput(string(data))
+		after, err := parseInput(formatted)
+		if err != nil {
+			t.Fatalf("%s: formatted program does not parse: %v\n%s", file, err, formatted)
+		}
+		if before.String() != after.String() {
+			t.Errorf("%s: Format() changed the program:\n%s", file, formatted)
+		}
+	}
+}
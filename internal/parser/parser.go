@@ -1,13 +1,15 @@
 package parser
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"iter"
-	"strings"
+	"strconv"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/schema"
 )
 
 type Parser struct {
@@ -16,19 +18,33 @@ type Parser struct {
 	stopIter     func()
 	currentToken lexer.Token
 	peekToken    lexer.Token
-	errors       []string
+	comments     []ast.Comment
+	deprecations []ast.Deprecation
+	errors       []error
+	ctx          context.Context
+	maxDepth     int
+	depth        int // lists open at the current token
+	// source is the source of the tree a program is built from.
+	source string
 }
 
+// DefaultMaxDepth is the number of nested lists a program may have by
+// default, far more than any program needs. It bounds the recursion of the
+// parser on malformed or hostile input.
+const DefaultMaxDepth = 100
+
 // Structure to represent an S-expression node (for intermediate parsing)
 type SExpr struct {
 	Token    lexer.Token
 	Children []*SExpr
+	End      int // byte offset past the node, including the closing parenthesis
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		lexer:  l,
-		errors: []string{},
+		lexer:    l,
+		errors:   []error{},
+		maxDepth: DefaultMaxDepth,
 	}
 	p.nextToken, p.stopIter = iter.Pull(l.Token())
 	p.advance() // Set currentToken
@@ -44,32 +60,35 @@ func (p *Parser) advance() {
 		if !ok || p.peekToken.Type != lexer.TOKEN_COMMENT {
 			break
 		}
+		p.comments = append(p.comments, ast.Comment{
+			Text:     p.peekToken.Literal,
+			Pos:      tokenPosition(p.peekToken),
+			Trailing: p.currentToken.Line == p.peekToken.Line,
+		})
 	}
 	if !ok {
 		p.peekToken = lexer.Token{Type: lexer.TOKEN_EOF}
 	}
 }
 
-func (p *Parser) ParseProgram() (*ast.Program, error) {
-	defer p.stopIter()
+// SetMaxDepth sets the number of nested lists the program may have, past
+// which parsing fails; 0 removes the limit.
+func (p *Parser) SetMaxDepth(depth int) {
+	p.maxDepth = depth
+}
 
-	// Parse the entire file into an S-expression tree
-	root, err := p.parseSExpr()
-	if err != nil {
-		return nil, err
-	}
+func (p *Parser) ParseProgram() (*ast.Program, error) {
+	return p.ParseProgramContext(context.Background())
+}
 
-	// Transform the S-expression tree into an AST
-	program, err := p.sExprToAST(root)
+// ParseProgramContext is like ParseProgram, but stops with the error of ctx
+// when ctx is canceled before the program is parsed.
+func (p *Parser) ParseProgramContext(ctx context.Context) (*ast.Program, error) {
+	tree, err := p.parseTree(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(p.errors) > 0 {
-		return nil, p.getError()
-	}
-
-	return program, nil
+	return tree.Program()
 }
 
 // Parse the current input into an S-expression tree
@@ -80,7 +99,7 @@ func (p *Parser) parseSExpr() (*SExpr, error) {
 	}
 
 	if p.currentToken.Type == lexer.TOKEN_EOF {
-		p.addError("unexpected end of input before program definition")
+		p.addError(CodeUnexpectedEOF, "unexpected end of input before program definition")
 		return nil, p.getError()
 	}
 
@@ -97,10 +116,19 @@ func (p *Parser) parseSExprNode() (*SExpr, error) {
 
 	// If this is an opening parenthesis, parse its contents
 	if p.currentToken.Type == lexer.TOKEN_LPAREN {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.maxDepth > 0 && p.depth > p.maxDepth {
+			p.addError(CodeMaxDepth, fmt.Sprintf("lists nested deeper than %d levels", p.maxDepth))
+			return nil, p.getError()
+		}
 		p.advance() // Consume the opening parenthesis
 
 		// Parse all child nodes until we hit the closing parenthesis
 		for p.currentToken.Type != lexer.TOKEN_RPAREN && p.currentToken.Type != lexer.TOKEN_EOF {
+			if err := p.ctx.Err(); err != nil {
+				return nil, err
+			}
 			if p.currentToken.Type == lexer.TOKEN_LPAREN {
 				// Parse nested S-expression
 				child, err := p.parseSExprNode()
@@ -113,6 +141,7 @@ func (p *Parser) parseSExprNode() (*SExpr, error) {
 				leaf := &SExpr{
 					Token:    p.currentToken,
 					Children: []*SExpr{},
+					End:      p.currentToken.End,
 				}
 				node.Children = append(node.Children, leaf)
 				p.advance() // Consume the token
@@ -120,13 +149,15 @@ func (p *Parser) parseSExprNode() (*SExpr, error) {
 		}
 
 		if p.currentToken.Type == lexer.TOKEN_RPAREN {
+			node.End = p.currentToken.End
 			p.advance() // Consume the closing parenthesis
 		} else {
-			p.addError("missing closing parenthesis in S-expression")
+			p.addError(CodeUnclosedList, "missing closing parenthesis in S-expression")
 			return nil, p.getError()
 		}
 	} else {
 		// For non-parenthesis tokens, just consume and return
+		node.End = p.currentToken.End
 		p.advance()
 	}
 
@@ -136,26 +167,27 @@ func (p *Parser) parseSExprNode() (*SExpr, error) {
 // Transform an S-expression tree into an AST
 func (p *Parser) sExprToAST(root *SExpr) (*ast.Program, error) {
 	if len(root.Children) < 3 {
-		p.addError("invalid program structure: not enough elements")
+		p.addError(CodeInvalidProgram, "invalid program structure: not enough elements")
 		return nil, p.getError()
 	}
 
 	// First child should be 'bala'
 	if root.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER ||
 		root.Children[0].Token.Literal != "bala" {
-		p.addError("program must start with 'bala'")
+		p.addError(CodeInvalidProgram, "program must start with 'bala'")
 		return nil, p.getError()
 	}
 
 	// Second child should be the program name
 	if root.Children[1].Token.Type != lexer.TOKEN_IDENTIFIER {
-		p.addError("invalid program name")
+		p.addError(CodeInvalidProgram, "invalid program name")
 		return nil, p.getError()
 	}
 
 	program := &ast.Program{
 		NamedBaseNode: ast.NamedBaseNode{
-			Name: root.Children[1].Token.Literal,
+			BaseNode: ast.BaseNode{Pos: tokenPosition(root.Children[1].Token)},
+			Name:     root.Children[1].Token.Literal,
 		},
 		Parameters:      []ast.Parameter{},
 		Implementations: []ast.ImplementationBlock{},
@@ -164,7 +196,7 @@ func (p *Parser) sExprToAST(root *SExpr) (*ast.Program, error) {
 
 	// Third child should be the program body
 	if len(root.Children) < 3 {
-		p.addError("program body is empty")
+		p.addError(CodeInvalidProgram, "program body is empty")
 		return nil, p.getError()
 	}
 
@@ -180,7 +212,7 @@ func (p *Parser) sExprToAST(root *SExpr) (*ast.Program, error) {
 		firstElement := child.Children[0]
 
 		if firstElement.Token.Type != lexer.TOKEN_IDENTIFIER {
-			p.addError(fmt.Sprintf("unexpected token %s in program body", firstElement.Token.Type))
+			p.addError(CodeUnexpectedToken, fmt.Sprintf("unexpected token %s in program body", firstElement.Token.Type))
 			continue
 		}
 
@@ -190,14 +222,31 @@ func (p *Parser) sExprToAST(root *SExpr) (*ast.Program, error) {
 			if len(child.Children) > 1 && child.Children[1].Token.Type == lexer.TOKEN_STRING {
 				program.Description = child.Children[1].Token.Literal
 			}
-		case "run_docker":
-			// Implementation block
-			impl := p.parseImplementationBlockSExpr(child)
-			program.Implementations = append(program.Implementations, impl)
 		case "outputs":
 			impl := p.parseOutputsSExpr(child)
 			program.Outputs = impl
+		case "tests":
+			program.Tests = append(program.Tests, p.parseTestsSExpr(child)...)
+		case "resources":
+			program.Resources = append(program.Resources, p.parseResourcesSExpr(child)...)
+		case "version", "doi", "bibtex", "author", "maintainer", "license", "homepage", "keywords", "return":
+			// Program metadata, e.g. (version "1.2.0"); a parameter of the
+			// same name has a type instead of a string
+			if len(child.Children) == 2 && child.Children[1].Token.Type == lexer.TOKEN_STRING {
+				program.Metadata[firstElement.Token.Literal] = child.Children[1].Token.Literal
+				continue
+			}
+			fallthrough
 		default:
+			// Implementation blocks are the ones with a registered schema,
+			// and the ones shaped like them, e.g. misspelled, which the
+			// semantic checks report as unknown
+			if _, ok := schema.Lookup(firstElement.Token.Literal); ok || looksLikeImplementation(child) {
+				impl := p.parseImplementationBlockSExpr(child)
+				program.Implementations = append(program.Implementations, impl)
+				continue
+			}
+
 			// Must be a parameter definition
 			param := p.parseParameterSExpr(child)
 			program.Parameters = append(program.Parameters, param)
@@ -207,6 +256,40 @@ func (p *Parser) sExprToAST(root *SExpr) (*ast.Program, error) {
 	return program, nil
 }
 
+// looksLikeImplementation reports whether a block without a registered
+// schema is an implementation block rather than a parameter: it isn't
+// typed, and it is named like a registered block or has the fields of one,
+// e.g. (run_dockr (image "ubuntu:22.04")).
+func looksLikeImplementation(node *SExpr) bool {
+	if len(node.Children) > 1 {
+		second := node.Children[1]
+		if second.Token.Type == lexer.TOKEN_IDENTIFIER || len(second.Children) == 0 ||
+			second.Children[0].Token.Literal == "enum" {
+			return false
+		}
+	}
+	if schema.Closest(node.Children[0].Token.Literal, schema.Names()) != "" {
+		return true
+	}
+	hasField := false
+	for _, field := range node.Children[1:] {
+		if len(field.Children) == 0 || field.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+			return false
+		}
+		for _, name := range schema.Names() {
+			implSchema, _ := schema.Lookup(name)
+			if _, ok := implSchema.Field(field.Children[0].Token.Literal); ok {
+				hasField = true
+			}
+		}
+	}
+	return hasField
+}
+
+// parameterMarkers lists the metadata of parameters that may be written
+// without a value, e.g. (hidden), which is then true.
+var parameterMarkers = map[string]bool{"hidden": true, "advanced": true}
+
 // Parse a parameter definition from an S-expression
 func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 	if len(node.Children) == 0 {
@@ -218,7 +301,8 @@ func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 
 	param := ast.Parameter{
 		NamedBaseNode: ast.NamedBaseNode{
-			Name: paramName,
+			BaseNode: ast.BaseNode{Pos: tokenPosition(node.Children[0].Token)},
+			Name:     paramName,
 		},
 		Metadata: make(map[string]string),
 	}
@@ -232,11 +316,12 @@ func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 				param.Type = "enum"
 
 				// Process enum values starting from the third child
+				values := []*SExpr{}
 				if len(node.Children) > 2 {
 					for i := 2; i < len(node.Children); i++ {
 						child := node.Children[i]
-						// Skip desc nodes
-						if len(child.Children) > 0 && child.Children[0].Token.Literal == "desc" {
+						// Skip metadata nodes, e.g. desc or when
+						if len(child.Children) > 0 && child.Children[0].Token.Type == lexer.TOKEN_IDENTIFIER {
 							continue
 						}
 
@@ -244,6 +329,7 @@ func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 						if child.Token.Type == lexer.TOKEN_STRING {
 							// Direct string value
 							param.Constraints = append(param.Constraints, child.Token.Literal)
+							values = append(values, child)
 						} else if len(child.Children) > 0 {
 							// Values in a nested list
 							for _, valueNode := range child.Children {
@@ -251,9 +337,11 @@ func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 									param.Constraints = append(param.Constraints, valueNode.Token.Literal)
 								}
 							}
+							values = append(values, child)
 						}
 					}
 				}
+				p.deprecateFlatEnum(node.Children[1], values)
 			} else {
 				// Simple type like "string", "number", etc.
 				param.Type = node.Children[1].Token.Literal
@@ -292,9 +380,36 @@ func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 					param.Description = desc
 					param.Metadata["desc"] = desc
 				}
+			} else if keyword == "default" && len(metaNode.Children) > 1 {
+				p.deprecateUppercaseBoolean(metaNode.Children[1])
+				param.Default = literalValue(metaNode.Children[1].Token)
+				param.Metadata["default"] = metaNode.Children[1].Token.Literal
+			} else if keyword == "when" && len(metaNode.Children) > 1 {
+				// (when mode "sensitive" ...), checked during semantic analysis
+				param.When = &ast.Condition{
+					Param: metaNode.Children[1].Token.Literal,
+					Pos:   tokenPosition(metaNode.Children[0].Token),
+				}
+				for _, valueNode := range metaNode.Children[2:] {
+					param.When.Values = append(param.When.Values, literalValue(valueNode.Token))
+				}
+			} else if keyword == "label" && len(metaNode.Children) > 1 && isList(metaNode.Children[1]) {
+				// (label (en "Reads") (it "Letture")), the translations
+				// of the description
+				for _, pair := range metaNode.Children[1:] {
+					if len(pair.Children) != 2 || pair.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER ||
+						pair.Children[1].Token.Type != lexer.TOKEN_STRING {
+						p.addErrorAt(pair.Token, CodeMalformedBlock, fmt.Sprintf("the labels of parameter '%s' must be (<locale> <string>) pairs", paramName))
+						continue
+					}
+					param.Metadata[ast.LabelPrefix+pair.Children[0].Token.Literal] = pair.Children[1].Token.Literal
+				}
 			} else if len(metaNode.Children) > 1 {
 				// Other metadata
 				param.Metadata[keyword] = metaNode.Children[1].Token.Literal
+			} else if parameterMarkers[keyword] {
+				// (hidden) stands for (hidden true)
+				param.Metadata[keyword] = "true"
 			}
 		}
 	}
@@ -305,9 +420,12 @@ func (p *Parser) parseParameterSExpr(node *SExpr) ast.Parameter {
 // Parse an implementation block from an S-expression
 func (p *Parser) parseImplementationBlockSExpr(node *SExpr) ast.ImplementationBlock {
 	block := ast.ImplementationBlock{
-		Name:   node.Children[0].Token.Literal,
-		Fields: make(map[string]any),
+		BaseNode: ast.BaseNode{Pos: tokenPosition(node.Children[0].Token)},
+		Name:     node.Children[0].Token.Literal,
+		Fields:   make(map[string]any),
+		FieldPos: make(map[string]ast.Position),
 	}
+	implSchema, _ := schema.Lookup(block.Name)
 
 	// Process each field in the implementation block
 	for i := 1; i < len(node.Children); i++ {
@@ -320,52 +438,73 @@ func (p *Parser) parseImplementationBlockSExpr(node *SExpr) ast.ImplementationBl
 		// If field has a name, process as a named field
 		if fieldNode.Children[0].Token.Type == lexer.TOKEN_IDENTIFIER {
 			fieldName := fieldNode.Children[0].Token.Literal
+			block.FieldPos[fieldName] = tokenPosition(fieldNode.Children[0].Token)
+
+			field, known := implSchema.Field(fieldName)
 
-			switch fieldName {
-			case "image", "command":
+			switch {
+			case !known:
+				// Generic field handling, unknown fields are reported
+				// during semantic analysis
+				if len(fieldNode.Children) > 1 {
+					block.Fields[fieldName] = fieldNode.Children[1].Token.Literal
+				} else {
+					block.Fields[fieldName] = nil
+				}
+			case field.Kind == schema.FieldString:
 				// Simple string value fields
 				if len(fieldNode.Children) > 1 && fieldNode.Children[1].Token.Type == lexer.TOKEN_STRING {
 					block.Fields[fieldName] = fieldNode.Children[1].Token.Literal
 				}
-			case "volumes":
-				// Volumes with nested key-value pairs
-				volumes := []any{}
+			case field.Kind == schema.FieldPairs:
+				// Key-value pairs, either inline or wrapped in a list:
+				// (volumes (a "/x") (b "/y")) or (volumes ((a "/x") (b "/y")))
+				pairs := []any{}
+
+				for _, pairNode := range pairItems(fieldNode) {
+					// Keep malformed entries, they are reported during
+					// semantic analysis
+					items := pairNode.Children
+					if !isList(pairNode) {
+						items = []*SExpr{pairNode}
+					}
 
-				// Process each volume definition
-				for j := 1; j < len(fieldNode.Children); j++ {
-					volumeNode := fieldNode.Children[j]
+					// e.g. volume hosts and env values may reference parameters
+					if field.PairReference != schema.NoReference && field.PairReference < len(items) {
+						p.recordReference(&block, fieldName, items[field.PairReference])
+						if fieldName == "volumes" {
+							p.deprecateKeywordString(items[field.PairReference])
+						}
+					}
 
-					if len(volumeNode.Children) >= 2 {
-						// Create a key-value pair from first two children
-						key := volumeNode.Children[0].Token.Literal
-						value := volumeNode.Children[1].Token.Literal
+					// e.g. config file templates reference parameters in
+					// their placeholders
+					if field.Templates && len(items) > 0 {
+						p.recordPlaceholders(&block, fieldName, items[len(items)-1])
+					}
 
-						// Store as an array to preserve order
-						volumes = append(volumes, []any{key, value})
+					// Store as an array to preserve order
+					entry := []any{}
+					for _, item := range items {
+						p.recordLiteral(&block, fieldName, item)
+						entry = append(entry, item.Token.Literal)
 					}
+					pairs = append(pairs, entry)
 				}
 
-				block.Fields[fieldName] = volumes
-			case "arguments":
-				// Arguments list
+				block.Fields[fieldName] = pairs
+			case field.Kind == schema.FieldList:
+				// Arguments list, either inline or wrapped in a list
 				args := []any{}
 
-				// Direct argument values
-				for j := 1; j < len(fieldNode.Children); j++ {
-					argNode := fieldNode.Children[j]
-
+				for _, argNode := range argumentItems(fieldNode) {
 					// Can be string or identifier
+					p.recordReference(&block, fieldName, argNode)
+					p.recordLiteral(&block, fieldName, argNode)
 					args = append(args, argNode.Token.Literal)
 				}
 
 				block.Fields[fieldName] = args
-			default:
-				// Generic field handling
-				if len(fieldNode.Children) > 1 {
-					block.Fields[fieldName] = fieldNode.Children[1].Token.Literal
-				} else {
-					block.Fields[fieldName] = nil
-				}
 			}
 		}
 	}
@@ -373,13 +512,114 @@ func (p *Parser) parseImplementationBlockSExpr(node *SExpr) ast.ImplementationBl
 	return block
 }
 
-func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, fmt.Sprintf("Line %d, Column %d: %s",
-		p.currentToken.Line, p.currentToken.Column, msg))
+// recordReference stores identifier nodes used in an implementation field, so
+// they can be resolved during semantic analysis.
+func (p *Parser) recordReference(block *ast.ImplementationBlock, field string, node *SExpr) {
+	if node.Token.Type != lexer.TOKEN_IDENTIFIER {
+		return
+	}
+	block.References = append(block.References, ast.Reference{
+		Name:  node.Token.Literal,
+		Field: field,
+		Pos:   tokenPosition(node.Token),
+	})
+}
+
+// recordPlaceholders stores the parameters referenced by the ${name}
+// placeholders of a template, at the position of the template.
+func (p *Parser) recordPlaceholders(block *ast.ImplementationBlock, field string, node *SExpr) {
+	if node.Token.Type != lexer.TOKEN_STRING {
+		return
+	}
+	for _, name := range schema.Placeholders(node.Token.Literal) {
+		block.References = append(block.References, ast.Reference{
+			Name:  name,
+			Field: field,
+			Pos:   tokenPosition(node.Token),
+		})
+	}
+}
+
+// recordLiteral stores string nodes used in an implementation field, with
+// their position, for the checks on literal values.
+func (p *Parser) recordLiteral(block *ast.ImplementationBlock, field string, node *SExpr) {
+	if node.Token.Type != lexer.TOKEN_STRING {
+		return
+	}
+	block.Literals = append(block.Literals, ast.Literal{
+		Value: node.Token.Literal,
+		Field: field,
+		Pos:   tokenPosition(node.Token),
+	})
+}
+
+// isList reports whether the node is a parenthesized list.
+func isList(node *SExpr) bool {
+	return node.Token.Type == lexer.TOKEN_LPAREN
+}
+
+// argumentItems returns the values of a list field, unwrapping the
+// (field (a b c)) form into (field a b c).
+func argumentItems(fieldNode *SExpr) []*SExpr {
+	items := fieldNode.Children[1:]
+	if len(items) == 1 && isList(items[0]) {
+		return items[0].Children
+	}
+	return items
+}
+
+// pairItems returns the pairs of a key-value field, unwrapping the
+// (field ((a b) (c d))) form into (field (a b) (c d)).
+func pairItems(fieldNode *SExpr) []*SExpr {
+	items := fieldNode.Children[1:]
+	if len(items) == 1 && isList(items[0]) && len(items[0].Children) > 0 {
+		for _, child := range items[0].Children {
+			if !isList(child) {
+				return items
+			}
+		}
+		return items[0].Children
+	}
+	return items
+}
+
+// literalValue converts a literal token to its Go value: numbers become int
+// or float64, true and false become booleans, anything else stays a string.
+func literalValue(tok lexer.Token) any {
+	switch tok.Type {
+	case lexer.TOKEN_NUMBER:
+		if i, err := strconv.Atoi(tok.Literal); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(tok.Literal, 64); err == nil {
+			return f
+		}
+	case lexer.TOKEN_IDENTIFIER:
+		switch tok.Literal {
+		case "true", "TRUE":
+			return true
+		case "false", "FALSE":
+			return false
+		}
+	}
+	return tok.Literal
+}
+
+func tokenPosition(tok lexer.Token) ast.Position {
+	return ast.Position{Line: tok.Line, Column: tok.Column, Offset: tok.Offset}
+}
+
+func (p *Parser) addError(code Code, msg string) {
+	p.errors = append(p.errors, &ParseError{Pos: tokenPosition(p.currentToken), Code: code, Message: msg})
+}
+
+// addErrorAt records an error at the position of a token.
+func (p *Parser) addErrorAt(tok lexer.Token, code Code, msg string) {
+	p.errors = append(p.errors, &ParseError{Pos: tokenPosition(tok), Code: code, Message: msg})
 }
 
 func (p *Parser) getError() error {
-	return errors.New(strings.Join(p.errors, "\n"))
+	return errors.Join(p.errors...)
 }
 
 func (p *Parser) parseOutputsSExpr(node *SExpr) []ast.OutputBlock {
@@ -398,7 +638,8 @@ func (p *Parser) parseOutputsSExpr(node *SExpr) []ast.OutputBlock {
 		if child.Children[0].Token.Type == lexer.TOKEN_IDENTIFIER {
 			output := ast.OutputBlock{
 				NamedBaseNode: ast.NamedBaseNode{
-					Name: child.Children[0].Token.Literal,
+					BaseNode: ast.BaseNode{Pos: tokenPosition(child.Children[0].Token)},
+					Name:     child.Children[0].Token.Literal,
 				},
 				Metadata: make(map[string]string),
 			}
@@ -438,3 +679,98 @@ func (p *Parser) parseOutputsSExpr(node *SExpr) []ast.OutputBlock {
 
 	return outputs
 }
+
+// parseTestsSExpr parses the tests block: each test is a list of its name
+// and of its (desc ...), (params (<param> <value>) ...) and
+// (expect (<output> (<assertion> <value>) ...) ...) sections.
+// parseResourcesSExpr parses the (<resource> <value>) pairs of a resources
+// block.
+func (p *Parser) parseResourcesSExpr(node *SExpr) []ast.Resource {
+	resources := []ast.Resource{}
+	for _, pair := range node.Children[1:] {
+		if len(pair.Children) != 2 || pair.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+			p.addErrorAt(pair.Token, CodeMalformedBlock, "the resources must be (<resource> <value>) pairs")
+			continue
+		}
+		resources = append(resources, ast.Resource{
+			Name:  pair.Children[0].Token.Literal,
+			Value: literalValue(pair.Children[1].Token),
+			Pos:   tokenPosition(pair.Children[0].Token),
+		})
+	}
+	return resources
+}
+
+func (p *Parser) parseTestsSExpr(node *SExpr) []ast.TestBlock {
+	tests := []ast.TestBlock{}
+
+	for _, child := range node.Children[1:] {
+		if len(child.Children) == 0 || child.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+			p.addErrorAt(child.Token, CodeMalformedBlock, "a test must start with its name")
+			continue
+		}
+		test := ast.TestBlock{
+			NamedBaseNode: ast.NamedBaseNode{
+				BaseNode: ast.BaseNode{Pos: tokenPosition(child.Children[0].Token)},
+				Name:     child.Children[0].Token.Literal,
+			},
+		}
+
+		for _, section := range child.Children[1:] {
+			if len(section.Children) == 0 || section.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+				p.addErrorAt(section.Token, CodeMalformedBlock, fmt.Sprintf("unexpected %s in test '%s'", section.Token.Type, test.Name))
+				continue
+			}
+			keyword := section.Children[0].Token
+			switch keyword.Literal {
+			case "desc":
+				if len(section.Children) > 1 && section.Children[1].Token.Type == lexer.TOKEN_STRING {
+					test.Description = section.Children[1].Token.Literal
+				}
+			case "params":
+				for _, pair := range section.Children[1:] {
+					if len(pair.Children) != 2 || pair.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+						p.addErrorAt(pair.Token, CodeMalformedBlock, fmt.Sprintf("the params of test '%s' must be (<parameter> <value>) pairs", test.Name))
+						continue
+					}
+					p.deprecateUppercaseBoolean(pair.Children[1])
+					test.Params = append(test.Params, ast.TestParam{
+						Name:  pair.Children[0].Token.Literal,
+						Value: literalValue(pair.Children[1].Token),
+						Pos:   tokenPosition(pair.Children[0].Token),
+					})
+				}
+			case "expect":
+				for _, output := range section.Children[1:] {
+					if len(output.Children) == 0 || output.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+						p.addErrorAt(output.Token, CodeMalformedBlock, fmt.Sprintf("the expectations of test '%s' must start with an output name", test.Name))
+						continue
+					}
+					expect := ast.OutputExpectation{
+						Output: output.Children[0].Token.Literal,
+						Pos:    tokenPosition(output.Children[0].Token),
+					}
+					for _, assertion := range output.Children[1:] {
+						if len(assertion.Children) != 2 || assertion.Children[0].Token.Type != lexer.TOKEN_IDENTIFIER {
+							p.addErrorAt(assertion.Token, CodeMalformedBlock, fmt.Sprintf("the assertions on output '%s' must be (<kind> <value>) pairs", expect.Output))
+							continue
+						}
+						expect.Assertions = append(expect.Assertions, ast.Assertion{
+							Kind:  assertion.Children[0].Token.Literal,
+							Value: literalValue(assertion.Children[1].Token),
+							Pos:   tokenPosition(assertion.Children[0].Token),
+						})
+					}
+					test.Expect = append(test.Expect, expect)
+				}
+			default:
+				p.addErrorAt(keyword, CodeUnknownSection, fmt.Sprintf("unknown section '%s' in test '%s', expected desc, params or expect",
+					keyword.Literal, test.Name))
+			}
+		}
+
+		tests = append(tests, test)
+	}
+
+	return tests
+}
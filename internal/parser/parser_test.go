@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"errors"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -105,4 +108,528 @@ func TestParseProgram_MissingParen(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), "missing closing parenthesis") {
 		t.Errorf("expected missing parenthesis error, got %v", err)
 	}
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrSyntax) || parseErr.Pos.Line == 0 {
+		t.Errorf("expected a positioned ParseError, got %#v", err)
+	}
+}
+
+func TestParseProgram_ErrorCodes(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		code  Code
+	}{
+		{``, CodeUnexpectedEOF},
+		{`(bala align (`, CodeUnclosedList},
+		{`(program align ())`, CodeInvalidProgram},
+		{`(bala align (("reads" file)))`, CodeUnexpectedToken},
+		{`(bala align ((resources cpus)))`, CodeMalformedBlock},
+		{`(bala align ((tests (small (setup "x")))))`, CodeUnknownSection},
+	} {
+		_, err := parseInput(tt.input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Code != tt.code {
+			t.Errorf("%q: expected a ParseError with code %s, got %#v", tt.input, tt.code, err)
+		}
+	}
+}
+
+func TestParseImplementationBlock_FieldsAndReferences(t *testing.T) {
+	input := `
+	(bala myprog
+		(
+			(param1 file (desc "Input file"))
+			(param2 string (desc "Output prefix"))
+			(run_docker
+				(image "biocontainers/enrichment:latest")
+				(volumes (("input" "/data/input") (param1 "/data/output")))
+				(env ((MODE "fast") (PREFIX param2)))
+				(arguments ("--input" param1 "--output" param2))
+			)
+		)
+	)
+	`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	impl := prog.Implementations[0]
+
+	volumes, ok := impl.Fields["volumes"].([]any)
+	if !ok || len(volumes) != 2 {
+		t.Fatalf("expected 2 volumes, got %v", impl.Fields["volumes"])
+	}
+	env, ok := impl.Fields["env"].([]any)
+	if !ok || len(env) != 2 {
+		t.Fatalf("expected 2 env entries, got %v", impl.Fields["env"])
+	}
+	if pair := env[1].([]any); pair[0] != "PREFIX" || pair[1] != "param2" {
+		t.Errorf("unexpected env entry %v", pair)
+	}
+	args, ok := impl.Fields["arguments"].([]any)
+	if !ok || len(args) != 4 {
+		t.Fatalf("expected 4 arguments, got %v", impl.Fields["arguments"])
+	}
+
+	// Only identifiers are references, string literals are not.
+	expected := []ast.Reference{
+		{Name: "param1", Field: "volumes"},
+		{Name: "param2", Field: "env"},
+		{Name: "param1", Field: "arguments"},
+		{Name: "param2", Field: "arguments"},
+	}
+	if len(impl.References) != len(expected) {
+		t.Fatalf("expected %d references, got %v", len(expected), impl.References)
+	}
+	for i, ref := range expected {
+		got := impl.References[i]
+		if got.Name != ref.Name || got.Field != ref.Field {
+			t.Errorf("reference %d = %+v, want %+v", i, got, ref)
+		}
+		if got.Pos.Line == 0 {
+			t.Errorf("reference %d has no position", i)
+		}
+	}
+	if prog.Parameters[1].Pos.Line != 5 {
+		t.Errorf("expected param2 on line 5, got %v", prog.Parameters[1].Pos)
+	}
+}
+
+func TestParseParameterSExpr_Default(t *testing.T) {
+	input := `
+	(bala myprog
+		(
+			(name string (default "sample") (desc "Name"))
+			(threads integer (default 4))
+			(ratio number (default 0.5))
+			(verbose boolean (default true))
+		)
+	)
+	`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []any{"sample", 4, 0.5, true}
+	for i, def := range expected {
+		if got := prog.Parameters[i].Default; got != def {
+			t.Errorf("parameter %q: expected default %#v, got %#v", prog.Parameters[i].Name, def, got)
+		}
+	}
+}
+
+func TestParseParameterSExpr_Markers(t *testing.T) {
+	input := `(bala myprog (
+		(threads integer (default 4) (advanced))
+		(seed integer (default 1) (hidden false))
+		(prefix string (optional))
+	))`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := prog.Parameters[0].Metadata["advanced"]; got != "true" {
+		t.Errorf("threads: expected advanced \"true\", got %q", got)
+	}
+	if got := prog.Parameters[1].Metadata["hidden"]; got != "false" {
+		t.Errorf("seed: expected hidden \"false\", got %q", got)
+	}
+	// Only the markers may be written without a value
+	if _, ok := prog.Parameters[2].Metadata["optional"]; ok {
+		t.Errorf("prefix: (optional) without a value shouldn't be metadata")
+	}
+}
+
+func TestParseParameterSExpr_Labels(t *testing.T) {
+	input := `(bala myprog (
+		(reads file (desc "Reads") (label (en "Reads") (it "Letture")))
+		(sep character (label "Separator"))
+	))`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if label, ok := prog.Parameters[0].Label("it"); !ok || label != "Letture" {
+		t.Errorf("reads: expected the it label \"Letture\", got %q", label)
+	}
+	if locales := prog.Parameters[0].Locales(); len(locales) != 2 {
+		t.Errorf("reads: expected the en and it labels, got %v", locales)
+	}
+	if got := prog.Parameters[1].Metadata["label"]; got != "Separator" {
+		t.Errorf("sep: expected the label metadata \"Separator\", got %q", got)
+	}
+
+	_, err = parseInput(`(bala myprog ((reads file (label (it)))))`)
+	if err == nil || !strings.Contains(err.Error(), "the labels of parameter 'reads' must be (<locale> <string>) pairs") {
+		t.Errorf("expected an error for a malformed label, got %v", err)
+	}
+}
+
+func TestParseProgram_Metadata(t *testing.T) {
+	input := `(bala myprog (
+		(desc "Aligner")
+		(version "1.2.0")
+		(doi "10.1093/bioinformatics/btp324")
+		(bibtex "@misc{bwa, title = {bwa}}")
+		(license "MIT")
+		(author string (default "me"))
+	))`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"version": "1.2.0",
+		"doi":     "10.1093/bioinformatics/btp324",
+		"bibtex":  "@misc{bwa, title = {bwa}}",
+		"license": "MIT",
+	}
+	if !reflect.DeepEqual(prog.Metadata, expected) {
+		t.Errorf("expected the metadata %v, got %v", expected, prog.Metadata)
+	}
+	// A parameter may be named after a metadata key
+	if len(prog.Parameters) != 1 || prog.Parameters[0].Name != "author" || prog.Parameters[0].Type != "string" {
+		t.Errorf("expected the author parameter, got %#v", prog.Parameters)
+	}
+}
+
+func TestParseProgram_UnknownImplementation(t *testing.T) {
+	input := `(bala myprog (
+		(reads (desc "Untyped"))
+		(mode (enum ("fast" "slow")))
+		(run_dockr (image "ubuntu:22.04"))
+		(run_podman (image "ubuntu:22.04") (arguments reads))
+	))`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var parameters, implementations []string
+	for _, param := range prog.Parameters {
+		parameters = append(parameters, param.Name)
+	}
+	for _, impl := range prog.Implementations {
+		implementations = append(implementations, impl.Name)
+	}
+	if !reflect.DeepEqual(parameters, []string{"reads", "mode"}) {
+		t.Errorf("expected the parameters reads and mode, got %v", parameters)
+	}
+	if !reflect.DeepEqual(implementations, []string{"run_dockr", "run_podman"}) {
+		t.Errorf("expected the implementations run_dockr and run_podman, got %v", implementations)
+	}
+}
+
+func TestParseProgram_Tests(t *testing.T) {
+	input := `
+	(bala myprog
+		(
+			(reads file)
+			(threads integer (default 4))
+			(tests
+				(small (desc "Small input")
+					(params (reads "reads.fq") (threads 2))
+					(expect (aligned (has_text "chr1") (lines 12)))))
+		)
+	)
+	`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prog.Tests) != 1 {
+		t.Fatalf("expected 1 test, got %d", len(prog.Tests))
+	}
+	test := prog.Tests[0]
+	if test.Name != "small" || test.Description != "Small input" {
+		t.Errorf("unexpected test %q: %q", test.Name, test.Description)
+	}
+	if len(test.Params) != 2 || test.Params[0].Value != "reads.fq" || test.Params[1].Value != 2 {
+		t.Errorf("unexpected params %#v", test.Params)
+	}
+	if len(test.Expect) != 1 || test.Expect[0].Output != "aligned" || len(test.Expect[0].Assertions) != 2 {
+		t.Fatalf("unexpected expectations %#v", test.Expect)
+	}
+	if a := test.Expect[0].Assertions[1]; a.Kind != ast.AssertLines || a.Value != 12 {
+		t.Errorf("unexpected assertion %#v", a)
+	}
+	if len(prog.Parameters) != 2 {
+		t.Errorf("the tests block should not be parsed as a parameter: %#v", prog.Parameters)
+	}
+
+	_, err = parseInput(`(bala myprog ((tests (small (param (reads "a"))))))`)
+	if err == nil || !strings.Contains(err.Error(), "unknown section 'param' in test 'small'") {
+		t.Errorf("expected an error on the unknown section, got %v", err)
+	}
+}
+
+func TestParseProgram_Resources(t *testing.T) {
+	input := `
+	(bala myprog
+		(
+			(reads file)
+			(resources (cpus 4) (memory "8 GB") (label "process_high"))
+		)
+	)
+	`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prog.Resources) != 3 || prog.Resources[0].Name != ast.ResourceCPUs || prog.Resources[0].Value != 4 {
+		t.Fatalf("unexpected resources %#v", prog.Resources)
+	}
+	if memory, _ := prog.Resource(ast.ResourceMemory); memory != "8 GB" {
+		t.Errorf("unexpected memory %v", memory)
+	}
+	if len(prog.Parameters) != 1 {
+		t.Errorf("the resources block should not be parsed as a parameter: %#v", prog.Parameters)
+	}
+
+	_, err = parseInput(`(bala myprog ((resources (cpus))))`)
+	if err == nil || !strings.Contains(err.Error(), "the resources must be (<resource> <value>) pairs") {
+		t.Errorf("expected an error on the malformed resource, got %v", err)
+	}
+}
+
+func TestParseProgram_When(t *testing.T) {
+	input := `
+	(bala myprog
+		(
+			(mode enum "fast" "sensitive" (default "fast"))
+			(index file (desc "Index") (when mode "sensitive"))
+			(seed integer (when fast false))
+		)
+	)
+	`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mode := prog.Parameters[0]; len(mode.Constraints) != 2 || mode.When != nil {
+		t.Errorf("the default should not be parsed as an enum value: %#v", mode)
+	}
+	when := prog.Parameters[1].When
+	if when == nil || when.Param != "mode" || len(when.Values) != 1 || when.Values[0] != "sensitive" || when.Pos.Line != 5 {
+		t.Errorf("unexpected condition %#v", when)
+	}
+	if when := prog.Parameters[2].When; when == nil || len(when.Values) != 1 || when.Values[0] != false {
+		t.Errorf("unexpected condition %#v", when)
+	}
+	if _, ok := prog.Parameters[1].Metadata["when"]; ok {
+		t.Errorf("the condition should not be stored as metadata: %#v", prog.Parameters[1].Metadata)
+	}
+}
+
+func TestParseProgram_Comments(t *testing.T) {
+	input := `; header
+(bala myprog
+	(
+		(name string (desc "Name")) ; trailing
+	)
+)
+`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ast.Comment{
+		{Text: " header", Pos: ast.Position{Line: 1}, Trailing: false},
+		{Text: " trailing", Pos: ast.Position{Line: 4}, Trailing: true},
+	}
+	if len(prog.Comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %v", len(expected), prog.Comments)
+	}
+	for i, c := range expected {
+		got := prog.Comments[i]
+		if got.Text != c.Text || got.Pos.Line != c.Pos.Line || got.Trailing != c.Trailing {
+			t.Errorf("comment %d: expected %+v, got %+v", i, c, got)
+		}
+	}
+}
+
+func TestApplyFixes_Deprecations(t *testing.T) {
+	input := `(bala myprog (
+	(mode enum "A" "B" (desc "Mode"))
+	(other enum ("x" "y"))
+	(verbose boolean (default TRUE))
+	(run_docker
+		(image "ubuntu:22.04")
+		(volumes ("parent-folder" "/scratch")))
+))`
+	expected := `(bala myprog (
+	(mode (enum ("A" "B")) (desc "Mode"))
+	(other (enum ("x" "y")))
+	(verbose boolean (default true))
+	(run_docker
+		(image "ubuntu:22.04")
+		(volumes (parent_folder "/scratch")))
+))`
+	prog, err := parseInput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prog.Deprecations) != 4 {
+		t.Fatalf("expected 4 deprecations, got %+v", prog.Deprecations)
+	}
+
+	fixed := ApplyFixes(input, prog.Deprecations)
+	if fixed != expected {
+		t.Errorf("ApplyFixes() =\n%s\nwant\n%s", fixed, expected)
+	}
+
+	prog, err = parseInput(fixed)
+	if err != nil {
+		t.Fatalf("unexpected error parsing fixed source: %v", err)
+	}
+	if len(prog.Deprecations) != 0 {
+		t.Errorf("expected no deprecations after fixing, got %+v", prog.Deprecations)
+	}
+	if got := prog.Parameters[0].Constraints; len(got) != 2 {
+		t.Errorf("expected enum values to be kept, got %v", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	input := `(bala myprog (
+	(mode enum "A" "B" (default "A"))
+	(verbose boolean (default FALSE))
+	(run_docker
+		(image "ubuntu:22.04")
+		(volumes (parent-folder "/scratch")))
+	(tests (quiet (params (verbose TRUE))))
+))`
+	migrated, fixed, err := Migrate(input)
+	if err != nil {
+		t.Fatalf("Migrate() unexpected error: %v", err)
+	}
+	if len(fixed) != 4 {
+		t.Errorf("Migrate() fixed %+v, want 4 constructs", fixed)
+	}
+	prog, err := parseInput(migrated)
+	if err != nil || len(prog.Deprecations) != 0 {
+		t.Fatalf("migrated program = %v, %+v, want no deprecations", err, prog)
+	}
+	if !strings.Contains(migrated, `(enum ("A" "B"))`) || !strings.Contains(migrated, "(parent_folder") {
+		t.Errorf("Migrate() =\n%s", migrated)
+	}
+
+	// Current programs are left as they are
+	if again, fixed, err := Migrate(migrated); err != nil || again != migrated || len(fixed) != 0 {
+		t.Errorf("Migrate(migrated) = %q, %v, %v", again, fixed, err)
+	}
+	if _, _, err := Migrate("(bala broken"); err == nil {
+		t.Error("Migrate() expected a parsing error")
+	}
+}
+
+func TestMigrate_FlatEnumEscapes(t *testing.T) {
+	input := `(bala myprog (
+	(sep enum "a\"b" "c:\\d" (default "a\"b"))
+))`
+	migrated, _, err := Migrate(input)
+	if err != nil {
+		t.Fatalf("Migrate() unexpected error: %v", err)
+	}
+	if expected := `(sep (enum ("a\"b" "c:\\d")) (default "a\"b"))`; !strings.Contains(migrated, expected) {
+		t.Errorf("Migrate() =\n%s\nwant it to contain %s", migrated, expected)
+	}
+	original, _ := parseInput(input)
+	prog, err := parseInput(migrated)
+	if err != nil {
+		t.Fatalf("migrated program does not parse: %v", err)
+	}
+	if !reflect.DeepEqual(prog.Parameters[0].Constraints, original.Parameters[0].Constraints) {
+		t.Errorf("migrated values = %q, want %q", prog.Parameters[0].Constraints, original.Parameters[0].Constraints)
+	}
+}
+
+func TestParseProgram_MaxDepth(t *testing.T) {
+	input := `(bala align ((run_docker (image "aligner:1.0") (command "align"))))`
+	deep := strings.Repeat("(", 100_000) + strings.Repeat(")", 100_000)
+	tests := []struct {
+		name     string
+		input    string
+		maxDepth int
+		wantErr  bool
+	}{
+		{"within the limit", input, 4, false},
+		{"past the limit", input, 3, true},
+		{"no limit", input, 0, false},
+		{"deep input", deep, DefaultMaxDepth, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.SetMaxDepth(tt.maxDepth)
+			_, err := p.ParseProgram()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProgram() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (!errors.Is(err, ErrSyntax) || !strings.Contains(err.Error(), "nested deeper")) {
+				t.Errorf("error = %v, want the depth limit exceeded", err)
+			}
+		})
+	}
+}
+
+func TestParseSnippet(t *testing.T) {
+	program, err := ParseSnippet("repl", `(reads file (desc "Reads"))
+  (run_docker (image "aligner:1.0") (arguments reads))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.Name != "repl" || len(program.Parameters) != 1 || len(program.Implementations) != 1 {
+		t.Fatalf("ParseSnippet() = %s", program)
+	}
+	if pos := program.Implementations[0].Pos; pos.Line != 2 || pos.Column != 4 {
+		t.Errorf("implementation at %s, want line 2, column 4 of the snippet", pos)
+	}
+	if program, err := ParseSnippet("repl", ""); err != nil || len(program.Parameters) != 0 {
+		t.Errorf("ParseSnippet(\"\") = %v, %v, want an empty program", program, err)
+	}
+
+	for _, input := range []string{`(reads file) "reads"`, `(reads file`} {
+		if _, err := ParseSnippet("repl", input); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseSnippet(%q) error = %v, want a syntax error", input, err)
+		}
+	}
+}
+
+// BenchmarkParseProgram parses the example program, see also "baryon bench".
+func BenchmarkParseProgram(b *testing.B) {
+	data, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := parseInput(string(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// FuzzParseProgram checks that any input either parses or fails with
+// syntax errors, without panicking or recursing past DefaultMaxDepth. The
+// seed corpus is in testdata/fuzz.
+func FuzzParseProgram(f *testing.F) {
+	data, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(string(data))
+	f.Fuzz(func(t *testing.T, input string) {
+		program, err := parseInput(input)
+		if err != nil {
+			if !errors.Is(err, ErrSyntax) {
+				t.Fatalf("ParseProgram() error %v isn't a syntax error", err)
+			}
+			return
+		}
+		if program == nil {
+			t.Fatal("ParseProgram() = nil program and nil error")
+		}
+		_ = program.String()
+	})
 }
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+)
+
+// ParseSnippet parses the elements of a program body, such as parameters,
+// implementation blocks or the outputs, as the program named name made of
+// them only. The positions are those of the snippet, which "baryon repl"
+// parses as it is typed.
+func ParseSnippet(name, source string) (*ast.Program, error) {
+	p := New(lexer.New(source))
+	defer p.stopIter()
+	p.ctx = context.Background()
+
+	body := &SExpr{Token: lexer.Token{Type: lexer.TOKEN_LPAREN, Literal: "("}, Children: []*SExpr{}}
+	for p.currentToken.Type != lexer.TOKEN_EOF {
+		if p.currentToken.Type != lexer.TOKEN_LPAREN {
+			p.addError(CodeUnexpectedToken, fmt.Sprintf("expected a list, got %s %q", p.currentToken.Type, p.currentToken.Literal))
+			return nil, p.getError()
+		}
+		node, err := p.parseSExprNode()
+		if err != nil {
+			return nil, err
+		}
+		body.Children = append(body.Children, node)
+	}
+
+	root := &SExpr{Token: lexer.Token{Type: lexer.TOKEN_LPAREN, Literal: "("}, Children: []*SExpr{
+		{Token: lexer.Token{Type: lexer.TOKEN_IDENTIFIER, Literal: "bala", Line: 1, Column: 1}},
+		{Token: lexer.Token{Type: lexer.TOKEN_IDENTIFIER, Literal: name, Line: 1, Column: 1}},
+		body,
+	}}
+	tree := &Tree{Root: root, Comments: p.comments, source: source, next: p.currentToken}
+	return tree.Program()
+}
@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+)
+
+// Tree is the S-expression tree of a program source. Editors keep the tree
+// of an open file and update it with Reparse after each edit, which parses
+// again only the innermost list containing the edit.
+type Tree struct {
+	Root     *SExpr
+	Comments []ast.Comment
+	source   string
+	// next is the token following Root, where the errors found while
+	// building the program are reported.
+	next lexer.Token
+}
+
+// Edit describes a change of the source: the bytes from Start to OldEnd of
+// the previous source were replaced by the bytes from Start to NewEnd of
+// the new one.
+type Edit struct {
+	Start, OldEnd, NewEnd int
+}
+
+// ParseTree parses a source into its S-expression tree.
+func ParseTree(source string) (*Tree, error) {
+	tree, err := New(lexer.New(source)).parseTree(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func (p *Parser) parseTree(ctx context.Context) (*Tree, error) {
+	defer p.stopIter()
+	p.ctx = ctx
+
+	// Parse the entire file into an S-expression tree
+	root, err := p.parseSExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{Root: root, Comments: p.comments, source: p.lexer.Input(), next: p.currentToken}, nil
+}
+
+// Program transforms the tree into a program, as ParseProgram does.
+func (t *Tree) Program() (*ast.Program, error) {
+	p := &Parser{currentToken: t.next, errors: []error{}, source: t.source}
+	program, err := p.sExprToAST(t.Root)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.errors) > 0 {
+		return nil, p.getError()
+	}
+
+	program.Comments = slices.Clone(t.Comments)
+	program.Deprecations = p.deprecations
+	return program, nil
+}
+
+// Reparse updates the tree after an edit of its source. The innermost list
+// containing the edit is parsed again and the positions of the nodes that
+// follow are shifted; edits that change the structure around that list
+// fall back to parsing the whole source. On error the tree is left empty,
+// and the next call parses the whole source.
+func (t *Tree) Reparse(source string, edit Edit) error {
+	delta := edit.NewEnd - edit.OldEnd
+	valid := 0 <= edit.Start && edit.Start <= edit.OldEnd && edit.Start <= edit.NewEnd &&
+		edit.OldEnd <= len(t.source) && len(source) == len(t.source)+delta
+	if t.Root == nil || !valid {
+		return t.parseAll(source)
+	}
+
+	// The innermost list whose parentheses enclose the edit, and the lists
+	// enclosing it
+	var node *SExpr
+	depth := 0
+	for n := t.Root; n != nil; depth++ {
+		node, n = n, nil
+		for _, child := range node.Children {
+			if isList(child) && child.Token.Offset < edit.Start && edit.OldEnd < child.End {
+				n = child
+				break
+			}
+		}
+	}
+	if !(node.Token.Offset < edit.Start && edit.OldEnd < node.End) {
+		return t.parseAll(source)
+	}
+
+	start, oldEnd := node.Token.Offset, node.End
+	sub := New(lexer.New(source[start : oldEnd+delta]))
+	sub.ctx = context.Background()
+	sub.depth = depth - 1
+	replacement, err := sub.parseSExprNode()
+	sub.stopIter()
+	if err != nil || len(sub.errors) > 0 || sub.currentToken.Type != lexer.TOKEN_EOF || !isList(replacement) {
+		return t.parseAll(source)
+	}
+
+	lines := newLineIndex(source)
+	move := func(tok *lexer.Token, by int) {
+		tok.Offset += by
+		tok.End += by
+		tok.Line, tok.Column = lines.position(tok.Offset)
+	}
+	// Shift the nodes following the edited list, and the end of its
+	// ancestors
+	var shift func(n *SExpr)
+	shift = func(n *SExpr) {
+		if n == node {
+			return
+		}
+		if n.Token.Offset >= oldEnd {
+			move(&n.Token, delta)
+		}
+		if n.End >= oldEnd {
+			n.End += delta
+		}
+		for _, child := range n.Children {
+			shift(child)
+		}
+	}
+	shift(t.Root)
+	var relocate func(n *SExpr)
+	relocate = func(n *SExpr) {
+		move(&n.Token, start)
+		n.End += start
+		for _, child := range n.Children {
+			relocate(child)
+		}
+	}
+	relocate(replacement)
+	*node = *replacement
+
+	comments := []ast.Comment{}
+	for _, c := range t.Comments {
+		if c.Pos.Offset < start {
+			comments = append(comments, c)
+		}
+	}
+	for _, c := range sub.comments {
+		c.Pos.Offset += start
+		c.Pos.Line, c.Pos.Column = lines.position(c.Pos.Offset)
+		comments = append(comments, c)
+	}
+	for _, c := range t.Comments {
+		if c.Pos.Offset >= oldEnd {
+			c.Pos.Offset += delta
+			c.Pos.Line, c.Pos.Column = lines.position(c.Pos.Offset)
+			comments = append(comments, c)
+		}
+	}
+	t.Comments = comments
+	move(&t.next, delta)
+	t.source = source
+	return nil
+}
+
+func (t *Tree) parseAll(source string) error {
+	tree, err := ParseTree(source)
+	if err != nil {
+		*t = Tree{source: source}
+		return err
+	}
+	*t = *tree
+	return nil
+}
+
+// lineIndex holds the offsets at which the lines after the first start,
+// in its source.
+type lineIndex struct {
+	source string
+	starts []int
+}
+
+func newLineIndex(source string) lineIndex {
+	starts := []int{}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' || source[i] == '\r' && (i+1 == len(source) || source[i+1] != '\n') {
+			starts = append(starts, i+1)
+		}
+	}
+	return lineIndex{source: source, starts: starts}
+}
+
+// position returns the line and column the lexer reports for an offset:
+// columns count the characters from the start of the line, from 1.
+func (l lineIndex) position(offset int) (line, column int) {
+	n := sort.SearchInts(l.starts, offset+1)
+	start := 0
+	if n > 0 {
+		start = l.starts[n-1]
+	} else if strings.HasPrefix(l.source, "\uFEFF") {
+		start = len("\uFEFF") // the byte order mark the lexer skips
+	}
+	return n + 1, utf8.RuneCountInString(l.source[start:offset]) + 1
+}
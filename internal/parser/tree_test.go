@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const treeSource = `; rnaseq
+(bala align (
+	(desc "Align reads") ; program
+	(reads file (desc "Input reads"))
+	(threads integer (default 4))
+	(run_docker
+		(image "biocontainers/bwa:0.7.17")
+		(arguments "bwa" "mem" "-t" threads reads)) ; run
+))
+`
+
+func TestTree_Reparse(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		// whether the lists around the edited one are kept
+		incremental bool
+	}{
+		{"rename a parameter", "reads file", "sample_reads file", true},
+		{"edit a string", `"Input reads"`, `"Input reads, gzipped"`, true},
+		{"write UTF-8", `"Input reads"`, `"Lectures d'entrée — gzippées"`, true},
+		{"add a line", "(default 4)", "(default 4)\n\t\t(desc \"Threads\")", true},
+		{"add a comment", `"-t" threads`, "\"-t\" ; threads\n\t\t\tthreads", true},
+		{"remove an argument", `"mem" "-t" threads`, `"mem"`, true},
+		{"unbalance a list", "(default 4))", "(default 4)", false},
+		{"add a parameter", "\t(threads", "\t(debug boolean)\n\t(threads", false},
+		{"nest too deep", "(default 4)", "(default " + strings.Repeat("(", DefaultMaxDepth-3) + strings.Repeat(")", DefaultMaxDepth-3) + ")", false},
+	}
+	for _, tt := range tests {
+		tree, err := ParseTree(treeSource)
+		if err != nil {
+			t.Fatalf("ParseTree() unexpected error: %v", err)
+		}
+		before := tree.Root.Children[2].Children[0] // the desc of the program
+
+		start := strings.Index(treeSource, tt.old)
+		source := treeSource[:start] + tt.new + treeSource[start+len(tt.old):]
+		err = tree.Reparse(source, Edit{Start: start, OldEnd: start + len(tt.old), NewEnd: start + len(tt.new)})
+
+		expected, expectedErr := ParseTree(source)
+		if (err != nil) != (expectedErr != nil) {
+			t.Fatalf("%s: Reparse() error = %v, want %v", tt.name, err, expectedErr)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(tree.Root, expected.Root) || !reflect.DeepEqual(tree.Comments, expected.Comments) ||
+			tree.next != expected.next {
+			t.Errorf("%s: Reparse() tree differs from ParseTree()", tt.name)
+		}
+		program, _ := tree.Program()
+		expectedProgram, _ := expected.Program()
+		if !reflect.DeepEqual(program, expectedProgram) {
+			t.Errorf("%s: Program() = %v, want %v", tt.name, program, expectedProgram)
+		}
+		if kept := tree.Root.Children[2].Children[0] == before; kept != tt.incremental {
+			t.Errorf("%s: Reparse() kept the unchanged lists = %v, want %v", tt.name, kept, tt.incremental)
+		}
+	}
+}
+
+func TestTree_ReparseInvalid(t *testing.T) {
+	tree, err := ParseTree(treeSource)
+	if err != nil {
+		t.Fatalf("ParseTree() unexpected error: %v", err)
+	}
+	end := strings.LastIndex(treeSource, ")")
+	broken := treeSource[:end] + treeSource[end+1:]
+	if err := tree.Reparse(broken, Edit{Start: end, OldEnd: end + 1, NewEnd: end}); err == nil {
+		t.Fatal("Reparse() expected error")
+	}
+	// The tree recovers on the next edit
+	if err := tree.Reparse(treeSource, Edit{Start: end, OldEnd: end, NewEnd: end + 1}); err != nil {
+		t.Fatalf("Reparse() unexpected error: %v", err)
+	}
+	if _, err := tree.Program(); err != nil {
+		t.Errorf("Program() unexpected error: %v", err)
+	}
+}
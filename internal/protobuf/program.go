@@ -0,0 +1,571 @@
+package protobuf
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// MarshalProgram encodes a program as a baryon.v1.Program message.
+func MarshalProgram(program *ast.Program) []byte {
+	var e Encoder
+	EncodeProgram(&e, program)
+	return e.Bytes()
+}
+
+// UnmarshalProgram decodes a baryon.v1.Program message into the program
+// UnmarshalJSON would decode from the same tree: numbers with an integral
+// value decode to int, other numbers to float64. A message without a
+// schema_version is taken to be of the current one.
+func UnmarshalProgram(data []byte) (*ast.Program, error) {
+	program := &ast.Program{
+		Parameters:      []ast.Parameter{},
+		Implementations: []ast.ImplementationBlock{},
+		Metadata:        map[string]string{},
+	}
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			if version := f.Int(); version != ast.SchemaVersion {
+				return fmt.Errorf("unsupported AST schema version %d, expected %d", version, ast.SchemaVersion)
+			}
+		case 2:
+			program.Name = f.String()
+		case 3:
+			program.Description = f.String()
+		case 4:
+			program.Pos, err = DecodePosition(f.Data)
+		case 5:
+			err = decodeStringMap(f.Data, program.Metadata)
+		case 6:
+			var param ast.Parameter
+			if param, err = decodeParameter(f.Data); err == nil {
+				program.Parameters = append(program.Parameters, param)
+			}
+		case 7:
+			var impl ast.ImplementationBlock
+			if impl, err = decodeImplementation(f.Data); err == nil {
+				program.Implementations = append(program.Implementations, impl)
+			}
+		case 8:
+			var output ast.OutputBlock
+			if output, err = decodeOutput(f.Data); err == nil {
+				program.Outputs = append(program.Outputs, output)
+			}
+		case 9:
+			var test ast.TestBlock
+			if test, err = decodeTest(f.Data); err == nil {
+				program.Tests = append(program.Tests, test)
+			}
+		case 10:
+			var resource ast.Resource
+			err = decodeNamedValue(f.Data, &resource.Name, &resource.Value, &resource.Pos)
+			program.Resources = append(program.Resources, resource)
+		case 11:
+			var comment ast.Comment
+			if comment, err = decodeComment(f.Data); err == nil {
+				program.Comments = append(program.Comments, comment)
+			}
+		case 12:
+			var deprecation ast.Deprecation
+			if deprecation, err = decodeDeprecation(f.Data); err == nil {
+				program.Deprecations = append(program.Deprecations, deprecation)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// EncodeProgram writes the fields of a baryon.v1.Program message.
+func EncodeProgram(e *Encoder, program *ast.Program) {
+	e.Int(1, ast.SchemaVersion)
+	e.String(2, program.Name)
+	e.String(3, program.Description)
+	position(e, 4, program.Pos)
+	e.StringMap(5, program.Metadata, slices.Sorted(maps.Keys(program.Metadata)))
+	for _, param := range program.Parameters {
+		e.Message(6, func(e *Encoder) {
+			e.String(1, param.Name)
+			e.String(2, param.Type)
+			e.String(3, param.Description)
+			for _, constraint := range param.Constraints {
+				value(e, 4, constraint)
+			}
+			if param.Default != nil {
+				value(e, 5, param.Default)
+			}
+			e.StringMap(6, param.Metadata, slices.Sorted(maps.Keys(param.Metadata)))
+			if param.When != nil {
+				e.Message(7, func(e *Encoder) {
+					e.String(1, param.When.Param)
+					for _, v := range param.When.Values {
+						value(e, 2, v)
+					}
+					position(e, 3, param.When.Pos)
+				})
+			}
+			position(e, 8, param.Pos)
+		})
+	}
+	for _, impl := range program.Implementations {
+		e.Message(7, func(e *Encoder) {
+			e.String(1, impl.Name)
+			for _, name := range slices.Sorted(maps.Keys(impl.Fields)) {
+				e.Message(2, func(entry *Encoder) {
+					entry.String(1, name)
+					value(entry, 2, impl.Fields[name])
+				})
+			}
+			for _, name := range slices.Sorted(maps.Keys(impl.FieldPos)) {
+				e.Message(3, func(entry *Encoder) {
+					entry.String(1, name)
+					position(entry, 2, impl.FieldPos[name])
+				})
+			}
+			for _, ref := range impl.References {
+				e.Message(4, func(e *Encoder) {
+					e.String(1, ref.Name)
+					e.String(2, ref.Field)
+					position(e, 3, ref.Pos)
+				})
+			}
+			for _, literal := range impl.Literals {
+				e.Message(5, func(e *Encoder) {
+					e.String(1, literal.Value)
+					e.String(2, literal.Field)
+					position(e, 3, literal.Pos)
+				})
+			}
+			position(e, 6, impl.Pos)
+		})
+	}
+	for _, output := range program.Outputs {
+		e.Message(8, func(e *Encoder) {
+			e.String(1, output.Name)
+			e.String(2, output.Format)
+			e.String(3, output.Path)
+			e.String(4, output.Description)
+			e.StringMap(5, output.Metadata, slices.Sorted(maps.Keys(output.Metadata)))
+			position(e, 6, output.Pos)
+		})
+	}
+	for _, test := range program.Tests {
+		e.Message(9, func(e *Encoder) {
+			e.String(1, test.Name)
+			e.String(2, test.Description)
+			for _, param := range test.Params {
+				e.Message(3, func(e *Encoder) { namedValue(e, param.Name, param.Value, param.Pos) })
+			}
+			for _, expect := range test.Expect {
+				e.Message(4, func(e *Encoder) {
+					e.String(1, expect.Output)
+					for _, assertion := range expect.Assertions {
+						e.Message(2, func(e *Encoder) { namedValue(e, assertion.Kind, assertion.Value, assertion.Pos) })
+					}
+					position(e, 3, expect.Pos)
+				})
+			}
+			position(e, 5, test.Pos)
+		})
+	}
+	for _, resource := range program.Resources {
+		e.Message(10, func(e *Encoder) { namedValue(e, resource.Name, resource.Value, resource.Pos) })
+	}
+	for _, comment := range program.Comments {
+		e.Message(11, func(e *Encoder) {
+			e.String(1, comment.Text)
+			position(e, 2, comment.Pos)
+			e.Bool(3, comment.Trailing)
+		})
+	}
+	for _, deprecation := range program.Deprecations {
+		e.Message(12, func(e *Encoder) {
+			position(e, 1, deprecation.Pos)
+			e.Int(2, deprecation.End)
+			e.String(3, deprecation.Construct)
+			e.String(4, deprecation.Replacement)
+		})
+	}
+}
+
+// EncodePosition writes the fields of a baryon.v1.Position message.
+func EncodePosition(e *Encoder, pos ast.Position) {
+	e.Int(1, pos.Line)
+	e.Int(2, pos.Column)
+	e.Int(3, pos.Offset)
+}
+
+func position(e *Encoder, field int, pos ast.Position) {
+	e.Message(field, func(e *Encoder) { EncodePosition(e, pos) })
+}
+
+// DecodePosition decodes a baryon.v1.Position message.
+func DecodePosition(data []byte) (ast.Position, error) {
+	var pos ast.Position
+	err := Decode(data, func(f Field) error {
+		switch f.Number {
+		case 1:
+			pos.Line = f.Int()
+		case 2:
+			pos.Column = f.Int()
+		case 3:
+			pos.Offset = f.Int()
+		}
+		return nil
+	})
+	return pos, err
+}
+
+// namedValue writes the fields shared by the TestParam, Assertion and
+// Resource messages: a name, a value and a position.
+func namedValue(e *Encoder, name string, v any, pos ast.Position) {
+	e.String(1, name)
+	if v != nil {
+		value(e, 2, v)
+	}
+	position(e, 3, pos)
+}
+
+func decodeNamedValue(data []byte, name *string, v *any, pos *ast.Position) error {
+	return Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			*name = f.String()
+		case 2:
+			*v, err = decodeValue(f.Data)
+		case 3:
+			*pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+}
+
+// value writes a google.protobuf.Value field: null, a number, a string, a
+// boolean, a list or a struct.
+func value(e *Encoder, field int, v any) {
+	e.Message(field, func(e *Encoder) {
+		switch v := v.(type) {
+		case nil:
+			e.Varint(1, 0)
+		case int:
+			e.Double(2, float64(v))
+		case float64:
+			e.Double(2, v)
+		case string:
+			e.RawBytes(3, []byte(v))
+		case bool:
+			if v {
+				e.Varint(4, 1)
+			} else {
+				e.Varint(4, 0)
+			}
+		case map[string]any:
+			e.Message(5, func(e *Encoder) {
+				for _, key := range slices.Sorted(maps.Keys(v)) {
+					e.Message(1, func(entry *Encoder) {
+						entry.String(1, key)
+						value(entry, 2, v[key])
+					})
+				}
+			})
+		case []any:
+			e.Message(6, func(e *Encoder) {
+				for _, item := range v {
+					value(e, 1, item)
+				}
+			})
+		default:
+			e.RawBytes(3, []byte(fmt.Sprint(v)))
+		}
+	})
+}
+
+// decodeValue decodes a google.protobuf.Value message.
+func decodeValue(data []byte) (any, error) {
+	var v any
+	err := Decode(data, func(f Field) error {
+		switch f.Number {
+		case 1:
+			v = nil
+		case 2:
+			v = number(f.Double())
+		case 3:
+			v = f.String()
+		case 4:
+			v = f.Varint != 0
+		case 5:
+			fields := map[string]any{}
+			err := Decode(f.Data, func(f Field) error {
+				var key string
+				var item any
+				err := Decode(f.Data, func(f Field) error {
+					var err error
+					switch f.Number {
+					case 1:
+						key = f.String()
+					case 2:
+						item, err = decodeValue(f.Data)
+					}
+					return err
+				})
+				fields[key] = item
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			v = fields
+		case 6:
+			items := []any{}
+			err := Decode(f.Data, func(f Field) error {
+				item, err := decodeValue(f.Data)
+				items = append(items, item)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			v = items
+		}
+		return nil
+	})
+	return v, err
+}
+
+// number returns an integral number as an int, as the parser reads it.
+func number(f float64) any {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int(f)
+	}
+	return f
+}
+
+func decodeStringMap(data []byte, m map[string]string) error {
+	key, value, err := DecodeStringEntry(data)
+	m[key] = value
+	return err
+}
+
+func decodeParameter(data []byte) (ast.Parameter, error) {
+	param := ast.Parameter{Metadata: map[string]string{}}
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			param.Name = f.String()
+		case 2:
+			param.Type = f.String()
+		case 3:
+			param.Description = f.String()
+		case 4:
+			var constraint any
+			if constraint, err = decodeValue(f.Data); err == nil {
+				param.Constraints = append(param.Constraints, constraint)
+			}
+		case 5:
+			param.Default, err = decodeValue(f.Data)
+		case 6:
+			err = decodeStringMap(f.Data, param.Metadata)
+		case 7:
+			param.When, err = decodeCondition(f.Data)
+		case 8:
+			param.Pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+	return param, err
+}
+
+func decodeCondition(data []byte) (*ast.Condition, error) {
+	condition := &ast.Condition{}
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			condition.Param = f.String()
+		case 2:
+			var v any
+			if v, err = decodeValue(f.Data); err == nil {
+				condition.Values = append(condition.Values, v)
+			}
+		case 3:
+			condition.Pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+	return condition, err
+}
+
+func decodeImplementation(data []byte) (ast.ImplementationBlock, error) {
+	impl := ast.ImplementationBlock{Fields: map[string]any{}, FieldPos: map[string]ast.Position{}}
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			impl.Name = f.String()
+		case 2:
+			var name string
+			var v any
+			err = Decode(f.Data, func(f Field) error {
+				var err error
+				switch f.Number {
+				case 1:
+					name = f.String()
+				case 2:
+					v, err = decodeValue(f.Data)
+				}
+				return err
+			})
+			impl.Fields[name] = v
+		case 3:
+			var name string
+			var pos ast.Position
+			err = Decode(f.Data, func(f Field) error {
+				var err error
+				switch f.Number {
+				case 1:
+					name = f.String()
+				case 2:
+					pos, err = DecodePosition(f.Data)
+				}
+				return err
+			})
+			impl.FieldPos[name] = pos
+		case 4:
+			var ref ast.Reference
+			err = decodeFieldText(f.Data, &ref.Name, &ref.Field, &ref.Pos)
+			impl.References = append(impl.References, ref)
+		case 5:
+			var literal ast.Literal
+			err = decodeFieldText(f.Data, &literal.Value, &literal.Field, &literal.Pos)
+			impl.Literals = append(impl.Literals, literal)
+		case 6:
+			impl.Pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+	return impl, err
+}
+
+// decodeFieldText decodes a Reference or Literal message: a text, the
+// field it is used in and its position.
+func decodeFieldText(data []byte, text, field *string, pos *ast.Position) error {
+	return Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			*text = f.String()
+		case 2:
+			*field = f.String()
+		case 3:
+			*pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+}
+
+func decodeOutput(data []byte) (ast.OutputBlock, error) {
+	output := ast.OutputBlock{Metadata: map[string]string{}}
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			output.Name = f.String()
+		case 2:
+			output.Format = f.String()
+		case 3:
+			output.Path = f.String()
+		case 4:
+			output.Description = f.String()
+		case 5:
+			err = decodeStringMap(f.Data, output.Metadata)
+		case 6:
+			output.Pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+	return output, err
+}
+
+func decodeTest(data []byte) (ast.TestBlock, error) {
+	var test ast.TestBlock
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			test.Name = f.String()
+		case 2:
+			test.Description = f.String()
+		case 3:
+			var param ast.TestParam
+			err = decodeNamedValue(f.Data, &param.Name, &param.Value, &param.Pos)
+			test.Params = append(test.Params, param)
+		case 4:
+			var expect ast.OutputExpectation
+			err = Decode(f.Data, func(f Field) error {
+				var err error
+				switch f.Number {
+				case 1:
+					expect.Output = f.String()
+				case 2:
+					var assertion ast.Assertion
+					err = decodeNamedValue(f.Data, &assertion.Kind, &assertion.Value, &assertion.Pos)
+					expect.Assertions = append(expect.Assertions, assertion)
+				case 3:
+					expect.Pos, err = DecodePosition(f.Data)
+				}
+				return err
+			})
+			test.Expect = append(test.Expect, expect)
+		case 5:
+			test.Pos, err = DecodePosition(f.Data)
+		}
+		return err
+	})
+	return test, err
+}
+
+func decodeComment(data []byte) (ast.Comment, error) {
+	var comment ast.Comment
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			comment.Text = f.String()
+		case 2:
+			comment.Pos, err = DecodePosition(f.Data)
+		case 3:
+			comment.Trailing = f.Varint != 0
+		}
+		return err
+	})
+	return comment, err
+}
+
+func decodeDeprecation(data []byte) (ast.Deprecation, error) {
+	var deprecation ast.Deprecation
+	err := Decode(data, func(f Field) error {
+		var err error
+		switch f.Number {
+		case 1:
+			deprecation.Pos, err = DecodePosition(f.Data)
+		case 2:
+			deprecation.End = f.Int()
+		case 3:
+			deprecation.Construct = f.String()
+		case 4:
+			deprecation.Replacement = f.String()
+		}
+		return err
+	})
+	return deprecation, err
+}
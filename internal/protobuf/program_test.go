@@ -0,0 +1,120 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+const testProgram = `(bala align (
+  ; Aligns reads
+  (desc "Align reads")
+  (version "1.2.0")
+  (reads file (desc "Input reads") (label (it "Letture")))
+  (mode (enum ("fast" "sensitive")) (default "fast"))
+  (threads integer (default 4) (min 1))
+  (ratio number (default 0.5))
+  (index file (when mode "sensitive") (optional true))
+  (paired boolean (default false)) ; trailing
+  (level enum "low" "high")
+  (run_docker
+    (image "bwa:0.7.17")
+    (env (THREADS threads))
+    (volumes (reads "/data/reads"))
+    (arguments "mem" "-t" threads mode reads))
+  (outputs (bam file "/data/out.bam" (desc "Alignments")))
+  (resources (cpus 4) (memory "8 GB"))
+  (tests
+    (small (desc "Small input")
+      (params (reads "reads.fq") (threads 2) (paired true))
+      (expect (bam (has_text "chr1") (lines 12)))))
+))`
+
+func parseProgram(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	program, err := parser.New(lexer.New(source)).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram() unexpected error: %v", err)
+	}
+	return program
+}
+
+// jsonRoundTrip returns a program as decoded from its JSON, which the
+// protocol buffer messages mirror.
+func jsonRoundTrip(t *testing.T, program *ast.Program) *ast.Program {
+	t.Helper()
+	data, err := json.Marshal(program)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	decoded := &ast.Program{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("json.Unmarshal() unexpected error: %v", err)
+	}
+	return decoded
+}
+
+func TestProgramRoundTrip(t *testing.T) {
+	example, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range map[string]string{"test": testProgram, "example": string(example)} {
+		program := parseProgram(t, source)
+		decoded, err := UnmarshalProgram(MarshalProgram(program))
+		if err != nil {
+			t.Fatalf("%s: UnmarshalProgram() unexpected error: %v", name, err)
+		}
+		if expected := jsonRoundTrip(t, program); !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("%s: decoded program = %#v\nwant %#v", name, decoded, expected)
+		}
+	}
+}
+
+func TestProgramValues(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "values"},
+		Resources: []ast.Resource{
+			{Name: "int", Value: -3},
+			{Name: "float", Value: 2.5},
+			{Name: "false", Value: false},
+			{Name: "empty", Value: ""},
+			{Name: "list", Value: []any{"a", []any{1, true}}},
+			{Name: "struct", Value: map[string]any{"a": nil, "b": "c"}},
+		},
+	}
+	decoded, err := UnmarshalProgram(MarshalProgram(program))
+	if err != nil {
+		t.Fatalf("UnmarshalProgram() unexpected error: %v", err)
+	}
+	for i, resource := range decoded.Resources {
+		if !reflect.DeepEqual(resource, program.Resources[i]) {
+			t.Errorf("resource %d = %#v, want %#v", i, resource, program.Resources[i])
+		}
+	}
+}
+
+func TestUnmarshalProgram_Errors(t *testing.T) {
+	var e Encoder
+	e.Int(1, ast.SchemaVersion+1)
+	if _, err := UnmarshalProgram(e.Bytes()); err == nil {
+		t.Errorf("expected an error for another schema version")
+	}
+	if _, err := UnmarshalProgram(MarshalProgram(parseProgram(t, testProgram))[:40]); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+
+	// Fields of newer versions of the schema are skipped
+	e = Encoder{}
+	e.String(2, "newer")
+	e.String(99, "unknown")
+	e.Double(100, 1)
+	if program, err := UnmarshalProgram(e.Bytes()); err != nil || program.Name != "newer" {
+		t.Errorf("UnmarshalProgram() = %v, %v, want the program named newer", program, err)
+	}
+}
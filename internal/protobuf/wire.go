@@ -0,0 +1,181 @@
+// Package protobuf encodes Baryon programs in the protocol buffer wire
+// format, as the messages of api/baryon/v1/baryon.proto. The encoding is
+// written by hand so that baryon-lang keeps to the standard library; the
+// messages are the ones the stubs generated from the schema exchange.
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Wire types of the fields.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Encoder appends the fields of a message to a buffer. The scalar
+// methods leave out the zero values, as proto3 does.
+type Encoder struct {
+	buf []byte
+}
+
+// Bytes returns the encoded message.
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) tag(field, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wire))
+}
+
+// Varint writes a varint field, even when it is zero, e.g. for the
+// members of a oneof.
+func (e *Encoder) Varint(field int, v uint64) {
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+// Int writes an int32 or enum field.
+func (e *Encoder) Int(field, v int) {
+	if v != 0 {
+		e.Varint(field, uint64(int64(v)))
+	}
+}
+
+// Bool writes a bool field.
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.Varint(field, 1)
+	}
+}
+
+// Double writes a double field, even when it is zero.
+func (e *Encoder) Double(field int, v float64) {
+	e.tag(field, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// RawBytes writes a bytes field, even when it is empty.
+func (e *Encoder) RawBytes(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// String writes a string field.
+func (e *Encoder) String(field int, v string) {
+	if v != "" {
+		e.RawBytes(field, []byte(v))
+	}
+}
+
+// Message writes a message field, encoded by encode.
+func (e *Encoder) Message(field int, encode func(*Encoder)) {
+	var m Encoder
+	encode(&m)
+	e.RawBytes(field, m.buf)
+}
+
+// StringMap writes a map<string, string> field, its entries in the order
+// of keys.
+func (e *Encoder) StringMap(field int, m map[string]string, keys []string) {
+	for _, key := range keys {
+		e.Message(field, func(entry *Encoder) {
+			entry.String(1, key)
+			entry.String(2, m[key])
+		})
+	}
+}
+
+// Field is a field of a decoded message: its value is in Varint, Fixed or
+// Data depending on its wire type.
+type Field struct {
+	Number int
+	Wire   int
+	Varint uint64
+	Fixed  uint64
+	Data   []byte
+}
+
+// Int returns the value of an int32 or enum field.
+func (f Field) Int() int {
+	return int(int32(f.Varint))
+}
+
+// Double returns the value of a double field.
+func (f Field) Double() float64 {
+	return math.Float64frombits(f.Fixed)
+}
+
+// String returns the value of a string field.
+func (f Field) String() string {
+	return string(f.Data)
+}
+
+var errTruncated = errors.New("truncated message")
+
+// Decode calls fn on each field of a message, in order. The fields fn
+// doesn't know are expected to be skipped, as they may come from a newer
+// version of the schema.
+func Decode(data []byte, fn func(Field) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		f := Field{Number: int(key >> 3), Wire: int(key & 7)}
+		if f.Number == 0 {
+			return errors.New("invalid field number 0")
+		}
+		switch f.Wire {
+		case wireVarint:
+			if f.Varint, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			f.Fixed, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			f.Fixed, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errTruncated
+			}
+			f.Data, data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported wire type %d of field %d", f.Wire, f.Number)
+		}
+		if err := fn(f); err != nil {
+			return fmt.Errorf("field %d: %w", f.Number, err)
+		}
+	}
+	return nil
+}
+
+// DecodeStringEntry decodes an entry of a map<string, string> field.
+func DecodeStringEntry(data []byte) (key, value string, err error) {
+	err = Decode(data, func(f Field) error {
+		switch f.Number {
+		case 1:
+			key = f.String()
+		case 2:
+			value = f.String()
+		}
+		return nil
+	})
+	return key, value, err
+}
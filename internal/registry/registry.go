@@ -0,0 +1,307 @@
+// Package registry fetches the programs shared in a registry, for
+// "baryon get". A registry is a tree of static files served over HTTP, such
+// as a Git repository through its raw file URLs, or a local clone of one
+// as a file:// URL:
+//
+//	<url>/yanked                                  the yanked image tags, optional
+//	<url>/<org>/<tool>/latest                     the latest version, optional
+//	<url>/<org>/<tool>/<version>/SHA256SUMS       the checksums of the files
+//	<url>/<org>/<tool>/<version>/<tool>.bala      the program
+//	<url>/<org>/<tool>/<version>/<tool>.bala.lock its lockfile, optional
+//
+// SHA256SUMS is in the format of sha256sum and lists the files of the
+// version, the program and its lockfile only, which are verified against it when they are downloaded and when
+// they are read from the cache.
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ChecksumsFile is the file listing the checksums of the files of a
+// version.
+const ChecksumsFile = "SHA256SUMS"
+
+// maxFileSize bounds the size of the files downloaded from a registry.
+const maxFileSize = 10 << 20
+
+var (
+	namePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	versionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+)
+
+// Module identifies a program of a registry, written org/tool@version.
+// Version is empty for the latest one.
+type Module struct {
+	Org, Tool, Version string
+}
+
+// ParseModule parses an org/tool@version reference, whose version is
+// optional.
+func ParseModule(s string) (Module, error) {
+	name, version, hasVersion := strings.Cut(s, "@")
+	org, tool, ok := strings.Cut(name, "/")
+	if !ok || !namePattern.MatchString(org) || !namePattern.MatchString(tool) {
+		return Module{}, fmt.Errorf("invalid module '%s', expected org/tool@version", s)
+	}
+	if hasVersion && !versionPattern.MatchString(version) {
+		return Module{}, fmt.Errorf("invalid version '%s' of module '%s'", version, name)
+	}
+	return Module{Org: org, Tool: tool, Version: version}, nil
+}
+
+func (m Module) String() string {
+	if m.Version == "" {
+		return m.Org + "/" + m.Tool
+	}
+	return m.Org + "/" + m.Tool + "@" + m.Version
+}
+
+// Program is the name of the program file of the module.
+func (m Module) Program() string {
+	return m.Tool + ".bala"
+}
+
+// File is a downloaded file, in the cache.
+type File struct {
+	Name   string // its name in the registry, e.g. "bwa.bala"
+	Path   string // its path in the cache
+	SHA256 string
+}
+
+// Download is the result of Client.Get.
+type Download struct {
+	Module Module // with the version, resolved when the latest was asked for
+	Files  []File // the program first
+	Cached bool   // whether the files were read from the cache
+}
+
+// Client fetches modules from a registry, caching them in a directory.
+type Client struct {
+	URL   string
+	Cache string
+	HTTP  *http.Client
+	// Refresh downloads the files even when they are cached.
+	Refresh bool
+}
+
+// NewClient creates a Client for the registry at url, whose requests time
+// out after the given duration. file:// URLs are read from the local file
+// system; the clients of the other registries don't read files, and don't
+// follow the redirects to another scheme.
+func NewClient(url, cache string, timeout time.Duration) *Client {
+	var transport http.RoundTripper = http.DefaultTransport.(*http.Transport).Clone()
+	if strings.HasPrefix(strings.ToLower(url), "file://") {
+		transport = http.NewFileTransport(http.Dir("/"))
+	}
+	return &Client{
+		URL:   strings.TrimSuffix(url, "/"),
+		Cache: cache,
+		HTTP:  &http.Client{Timeout: timeout, Transport: transport, CheckRedirect: checkRedirect},
+	}
+}
+
+// checkRedirect refuses the redirects to another scheme than the one of
+// the registry, and stops after 10 redirects as the default policy does.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if req.URL.Scheme != via[0].URL.Scheme {
+		return fmt.Errorf("refusing the redirect from %s to %s", via[0].URL.Scheme, req.URL)
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// DefaultCache returns the cache directory of the registry files, under
+// the user cache directory.
+func DefaultCache() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "baryon", "registry"), nil
+}
+
+// Get returns the files of a module, from the cache when they are there
+// and match their checksums, from the registry otherwise. Modules without
+// a version resolve to the one of the latest file.
+func (c *Client) Get(m Module) (*Download, error) {
+	if m.Version == "" {
+		data, err := c.fetch(m.Org + "/" + m.Tool + "/latest")
+		if err != nil {
+			return nil, fmt.Errorf("resolving the latest version of %s: %w", m, err)
+		}
+		m.Version = strings.TrimSpace(string(data))
+		if !versionPattern.MatchString(m.Version) {
+			return nil, fmt.Errorf("invalid latest version '%s' of %s", m.Version, m)
+		}
+	}
+
+	dir := filepath.Join(c.Cache, m.Org, m.Tool, m.Version)
+	if !c.Refresh {
+		if files, err := c.cached(m, dir); err == nil {
+			return &Download{Module: m, Files: files, Cached: true}, nil
+		}
+	}
+
+	base := m.Org + "/" + m.Tool + "/" + m.Version + "/"
+	sums, err := c.fetch(base + ChecksumsFile)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", m, err)
+	}
+	checksums, err := parseChecksums(m, sums)
+	if err != nil {
+		return nil, fmt.Errorf("%s of %s: %w", ChecksumsFile, m, err)
+	}
+
+	contents := map[string][]byte{}
+	for _, sum := range checksums {
+		data, err := c.fetch(base + sum.Name)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s of %s: %w", sum.Name, m, err)
+		}
+		if actual := digest(data); actual != sum.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch for %s of %s: expected sha256 %s, got %s", sum.Name, m, sum.SHA256, actual)
+		}
+		contents[sum.Name] = data
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache: %w", err)
+	}
+	files := []File{}
+	for _, sum := range checksums {
+		sum.Path = filepath.Join(dir, sum.Name)
+		if err := os.WriteFile(sum.Path, contents[sum.Name], 0644); err != nil {
+			return nil, fmt.Errorf("caching %s: %w", sum.Name, err)
+		}
+		files = append(files, sum)
+	}
+	// The checksums are written last, so that partial downloads aren't
+	// taken for cached ones.
+	if err := os.WriteFile(filepath.Join(dir, ChecksumsFile), sums, 0644); err != nil {
+		return nil, fmt.Errorf("caching %s: %w", ChecksumsFile, err)
+	}
+	return &Download{Module: m, Files: files}, nil
+}
+
+// Yanked returns the list of the yanked image tags of the registry, in the
+// format of lockfile.ParseYanked, or nil if it has none.
+func (c *Client) Yanked() ([]byte, error) {
+	data, err := c.fetch("yanked")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// cached returns the files of a module in the cache, if they are all there
+// and match their checksums.
+func (c *Client) cached(m Module, dir string) ([]File, error) {
+	sums, err := os.ReadFile(filepath.Join(dir, ChecksumsFile))
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := parseChecksums(m, sums)
+	if err != nil {
+		return nil, err
+	}
+	for i, sum := range checksums {
+		checksums[i].Path = filepath.Join(dir, sum.Name)
+		data, err := os.ReadFile(checksums[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		if digest(data) != sum.SHA256 {
+			return nil, fmt.Errorf("cached %s of %s doesn't match its checksum", sum.Name, m)
+		}
+	}
+	return checksums, nil
+}
+
+// fetch downloads a file of the registry, by its path under the URL.
+func (c *Client) fetch(path string) ([]byte, error) {
+	url := c.URL + "/" + path
+	resp, err := c.HTTP.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", url, fs.ErrNotExist)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: unexpected status %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("%s: larger than %d bytes", url, maxFileSize)
+	}
+	return data, nil
+}
+
+// parseChecksums parses the lines of a SHA256SUMS file, "<digest>  <name>"
+// or "<digest> *<name>". The names are files of the version directory, the
+// program of the module coming first; only the program and its lockfile
+// are accepted, so a registry can't write other files, like a baryon.toml.
+func parseChecksums(m Module, data []byte) ([]File, error) {
+	files := []File{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(text, " ")
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("line %d: expected a sha256 digest and a file name", line)
+		}
+		if !namePattern.MatchString(name) || name == ChecksumsFile {
+			return nil, fmt.Errorf("line %d: invalid file name '%s'", line, name)
+		}
+		if name != m.Program() && name != m.Program()+".lock" {
+			return nil, fmt.Errorf("line %d: unexpected file '%s', expected %s or %s.lock", line, name, m.Program(), m.Program())
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("line %d: duplicate file '%s'", line, name)
+		}
+		seen[name] = true
+		file := File{Name: name, SHA256: strings.ToLower(sum)}
+		if name == m.Program() {
+			files = append([]File{file}, files...)
+		} else {
+			files = append(files, file)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !seen[m.Program()] {
+		return nil, fmt.Errorf("missing the checksum of %s", m.Program())
+	}
+	return files, nil
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const bwaSource = "(bala bwa ((run_docker (image \"biocontainers/bwa:0.7.17\") (arguments \"bwa\"))))\n"
+
+// fakeRegistry serves the given files, by path, counting the requests.
+func fakeRegistry(t *testing.T, files map[string]string) (*Client, *int) {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		content, ok := files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	return &Client{URL: srv.URL, Cache: t.TempDir(), HTTP: srv.Client()}, &requests
+}
+
+func TestParseModule(t *testing.T) {
+	m, err := ParseModule("lab/bwa@0.7.17")
+	if err != nil || m != (Module{Org: "lab", Tool: "bwa", Version: "0.7.17"}) {
+		t.Errorf("ParseModule() = %+v, %v", m, err)
+	}
+	if m, err := ParseModule("lab/bwa"); err != nil || m.Version != "" {
+		t.Errorf("ParseModule() without version = %+v, %v", m, err)
+	}
+	for _, input := range []string{"bwa", "lab/bwa@", "lab/../bwa", "/bwa@1", "lab/bwa@1/2"} {
+		if _, err := ParseModule(input); err == nil {
+			t.Errorf("ParseModule(%q) expected error", input)
+		}
+	}
+}
+
+func TestClient_Get(t *testing.T) {
+	lock := "biocontainers/bwa:0.7.17 sha256:" + strings.Repeat("a", 64) + "\n"
+	sums := fmt.Sprintf("%s  bwa.bala.lock\n%s *bwa.bala\n", digest([]byte(lock)), digest([]byte(bwaSource)))
+	client, requests := fakeRegistry(t, map[string]string{
+		"lab/bwa/latest":               "0.7.17\n",
+		"lab/bwa/0.7.17/SHA256SUMS":    sums,
+		"lab/bwa/0.7.17/bwa.bala":      bwaSource,
+		"lab/bwa/0.7.17/bwa.bala.lock": lock,
+	})
+
+	download, err := client.Get(Module{Org: "lab", Tool: "bwa"})
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if download.Module.Version != "0.7.17" || download.Cached {
+		t.Errorf("Get() = %+v, want a download of the latest version", download)
+	}
+	if len(download.Files) != 2 || download.Files[0].Name != "bwa.bala" || download.Files[1].Name != "bwa.bala.lock" {
+		t.Fatalf("Get() files = %+v, want the program then its lockfile", download.Files)
+	}
+	if data, _ := os.ReadFile(download.Files[0].Path); string(data) != bwaSource {
+		t.Errorf("cached program = %q", data)
+	}
+
+	// The second time, the files come from the cache
+	*requests = 0
+	download, err = client.Get(Module{Org: "lab", Tool: "bwa", Version: "0.7.17"})
+	if err != nil || !download.Cached || *requests != 0 {
+		t.Errorf("Get() = %+v, %v after %d requests, want the cached files", download, err, *requests)
+	}
+
+	// Corrupted files are downloaded again
+	os.WriteFile(download.Files[0].Path, []byte("tampered"), 0644)
+	download, err = client.Get(Module{Org: "lab", Tool: "bwa", Version: "0.7.17"})
+	if err != nil || download.Cached {
+		t.Errorf("Get() = %+v, %v, want a new download", download, err)
+	}
+}
+
+func TestClient_Get_Errors(t *testing.T) {
+	wrong := strings.Repeat("0", 64)
+	for _, tt := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"unknown version", map[string]string{}, "file does not exist"},
+		{"checksum mismatch", map[string]string{
+			"lab/bwa/1/SHA256SUMS": wrong + "  bwa.bala\n",
+			"lab/bwa/1/bwa.bala":   bwaSource,
+		}, "checksum mismatch for bwa.bala"},
+		{"missing program", map[string]string{
+			"lab/bwa/1/SHA256SUMS": wrong + "  bwa.bala.lock\n",
+		}, "missing the checksum of bwa.bala"},
+		{"path in checksums", map[string]string{
+			"lab/bwa/1/SHA256SUMS": wrong + "  bwa.bala\n" + wrong + "  ../../evil\n",
+		}, "invalid file name '../../evil'"},
+		{"other file in checksums", map[string]string{
+			"lab/bwa/1/SHA256SUMS": wrong + "  bwa.bala\n" + wrong + "  baryon.toml\n",
+		}, "unexpected file 'baryon.toml'"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := fakeRegistry(t, tt.files)
+			_, err := client.Get(Module{Org: "lab", Tool: "bwa", Version: "1"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Get() error = %v, want %q", err, tt.want)
+			}
+			if entries, _ := os.ReadDir(client.Cache); len(entries) != 0 {
+				t.Errorf("expected nothing to be cached, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestClient_Get_File(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "lab", "bwa", "1.0")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "bwa.bala"), []byte(bwaSource), 0644)
+	os.WriteFile(filepath.Join(dir, ChecksumsFile), []byte(digest([]byte(bwaSource))+"  bwa.bala\n"), 0644)
+
+	client := NewClient("file://"+root, t.TempDir(), time.Second)
+	download, err := client.Get(Module{Org: "lab", Tool: "bwa", Version: "1.0"})
+	if err != nil || len(download.Files) != 1 {
+		t.Errorf("Get() = %+v, %v, want the program of the local registry", download, err)
+	}
+}
+
+func TestClient_Redirects(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "latest"), []byte("1.0\n"), 0644)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lab/bwa/latest":
+			http.Redirect(w, r, "/moved/latest", http.StatusFound)
+		case "/moved/latest":
+			fmt.Fprint(w, "2.0\n")
+		default:
+			http.Redirect(w, r, "file://"+filepath.ToSlash(root)+"/latest", http.StatusFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewClient(srv.URL, t.TempDir(), time.Second)
+	if data, err := client.fetch("lab/bwa/latest"); err != nil || string(data) != "2.0\n" {
+		t.Errorf("fetch() = %q, %v, want the redirect within the registry followed", data, err)
+	}
+	if data, err := client.fetch("lab/evil/latest"); err == nil || !strings.Contains(err.Error(), "refusing the redirect") {
+		t.Errorf("fetch() = %q, %v, want the redirect to a file refused", data, err)
+	}
+}
+
+func TestClient_Yanked(t *testing.T) {
+	client, _ := fakeRegistry(t, map[string]string{"yanked": "biocontainers/bwa:0.7.16\n"})
+	if data, err := client.Yanked(); err != nil || string(data) != "biocontainers/bwa:0.7.16\n" {
+		t.Errorf("Yanked() = %q, %v", data, err)
+	}
+	client, _ = fakeRegistry(t, map[string]string{})
+	if data, err := client.Yanked(); err != nil || data != nil {
+		t.Errorf("Yanked() = %q, %v, want none", data, err)
+	}
+}
@@ -0,0 +1,349 @@
+// Package repl implements the interactive session of "baryon repl". The
+// S-expressions entered at the prompt are parsed and their syntax tree is
+// printed; the parameters, implementation blocks and other elements make
+// up a program, which the commands starting with ":" check, print and
+// transpile. A whole (bala ...) program replaces the one of the session.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+const (
+	prompt       = "bala> "
+	continuation = "....> "
+	// maxLine bounds the length of a line, pasted programs being read
+	// line by line.
+	maxLine = 1 << 20
+)
+
+const help = `Enter S-expressions to add them to the program, e.g.
+  (reads file (desc "Input reads"))
+  (run_docker (image "aligner:1.0") (arguments reads))
+or a whole (bala name (...)) program to replace it. Commands:
+  :ast              print the syntax tree of the program as JSON
+  :check            run the semantic checks on the program
+  :show             print the program
+  :transpile [lang] transpile the program to a target, the one of :target by default
+  :target [lang]    transpile the program after each input, "off" to stop
+  :load <file>      replace the program with the one of a file
+  :reset            start a new program
+  :help             print this help
+  :quit             end the session
+`
+
+// Session is an interactive session, building up a program.
+type Session struct {
+	// Rules enables or disables semantic checks by rule name, as the lint
+	// section of baryon.toml.
+	Rules map[string]bool
+	// Options are the target options by target.
+	Options map[string]map[string]string
+	// Target is the target the program is transpiled to after each input,
+	// none when empty.
+	Target string
+	// Prompt enables the prompts, when the input is a terminal.
+	Prompt bool
+
+	out  io.Writer
+	name string
+	// body holds the sources of the elements of the program, in order.
+	body []string
+}
+
+// New returns a session with an empty program, writing to out.
+func New(out io.Writer) *Session {
+	return &Session{out: out, name: "repl"}
+}
+
+// Run reads and evaluates the input until its end or :quit. An input ends
+// with the line closing its lists, so S-expressions can span lines; a
+// command entered before then discards the unclosed lists.
+func (s *Session) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, maxLine)
+	s.prompt(prompt)
+	input := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if input != "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			fmt.Fprintln(s.out, "error: unclosed list discarded")
+			input = ""
+		}
+		input += line + "\n"
+		if !strings.HasPrefix(strings.TrimSpace(input), ":") && !balanced(input) {
+			s.prompt(continuation)
+			continue
+		}
+		if !s.Eval(input) {
+			return nil
+		}
+		input = ""
+		s.prompt(prompt)
+	}
+	if strings.TrimSpace(input) != "" {
+		s.Eval(input)
+	}
+	return scanner.Err()
+}
+
+func (s *Session) prompt(text string) {
+	if s.Prompt {
+		fmt.Fprint(s.out, text)
+	}
+}
+
+// balanced reports whether an input closes every list it opens.
+func balanced(input string) bool {
+	depth := 0
+	for tok := range lexer.New(input).Token() {
+		switch tok.Type {
+		case lexer.TOKEN_LPAREN:
+			depth++
+		case lexer.TOKEN_RPAREN:
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// Eval evaluates an input, a command or S-expressions, reporting whether
+// the session goes on.
+func (s *Session) Eval(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return true
+	}
+	if !strings.HasPrefix(trimmed, ":") {
+		s.enter(input)
+		return true
+	}
+
+	name, arg, _ := strings.Cut(trimmed[1:], " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "ast":
+		s.printAST()
+	case "check":
+		if program, ok := s.program(); ok && s.check(program) == 0 {
+			fmt.Fprintln(s.out, "✅ No issues found")
+		}
+	case "show":
+		fmt.Fprint(s.out, s.source())
+	case "transpile":
+		if arg == "" {
+			arg = s.Target
+		}
+		if arg == "" {
+			fmt.Fprintln(s.out, "error: no target, use :transpile <lang> or :target <lang>")
+			break
+		}
+		s.transpile(arg)
+	case "target":
+		s.setTarget(arg)
+	case "load":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+			break
+		}
+		s.enter(string(data))
+	case "reset":
+		s.name, s.body = "repl", nil
+	case "help":
+		fmt.Fprint(s.out, help)
+	case "quit", "q":
+		return false
+	default:
+		fmt.Fprintf(s.out, "error: unknown command :%s, see :help\n", name)
+	}
+	return true
+}
+
+// enter adds the elements of an input to the program, or replaces the
+// program with the one of the input, printing their syntax tree.
+func (s *Session) enter(input string) {
+	var entered *ast.Program
+	if isProgram(input) {
+		tree, err := parser.ParseTree(input)
+		if err == nil {
+			entered, err = tree.Program()
+		}
+		if err != nil {
+			printError(s.out, err)
+			return
+		}
+		body := tree.Root.Children[2]
+		s.name, s.body = entered.Name, []string{input[body.Token.Offset+1 : body.End-1]}
+	} else {
+		var err error
+		if entered, err = parser.ParseSnippet(s.name, input); err != nil {
+			printError(s.out, err)
+			return
+		}
+		s.body = append(s.body, strings.TrimSpace(input))
+	}
+	fmt.Fprint(s.out, entered.String())
+	for _, d := range entered.Deprecations {
+		fmt.Fprintf(s.out, "%s: deprecated %s, use %s\n", d.Pos, d.Construct, d.Replacement)
+	}
+	if s.Target != "" {
+		s.transpile(s.Target)
+	}
+}
+
+// isProgram reports whether an input is a whole program, starting with
+// (bala.
+func isProgram(input string) bool {
+	tokens := []lexer.Token{}
+	for tok := range lexer.New(input).Token() {
+		if tok.Type == lexer.TOKEN_COMMENT {
+			continue
+		}
+		if tokens = append(tokens, tok); len(tokens) == 2 {
+			break
+		}
+	}
+	return len(tokens) == 2 && tokens[0].Type == lexer.TOKEN_LPAREN &&
+		tokens[1].Type == lexer.TOKEN_IDENTIFIER && tokens[1].Literal == "bala"
+}
+
+// source returns the source of the program, formatted. The positions
+// reported by the commands are the ones of this source, as :show prints it.
+func (s *Session) source() string {
+	source := fmt.Sprintf("(bala %s (\n%s\n))\n", s.name, strings.Join(s.body, "\n"))
+	if formatted, err := parser.Format(source); err == nil {
+		return formatted
+	}
+	return source
+}
+
+// program parses the program, printing the errors.
+func (s *Session) program() (*ast.Program, bool) {
+	program, err := parser.New(lexer.New(s.source())).ParseProgram()
+	if err != nil {
+		printError(s.out, err)
+		return nil, false
+	}
+	return program, true
+}
+
+func (s *Session) printAST() {
+	program, ok := s.program()
+	if !ok {
+		return
+	}
+	out, err := json.MarshalIndent(program, "", "  ")
+	if err != nil {
+		printError(s.out, err)
+		return
+	}
+	fmt.Fprintf(s.out, "%s\n", out)
+}
+
+// check runs the semantic checks, printing the diagnostics, and returns
+// their number, -1 when the program has errors.
+func (s *Session) check(program *ast.Program) int {
+	analyzer := semantic.New()
+	for _, rule := range slices.Sorted(maps.Keys(s.Rules)) {
+		if err := analyzer.SetEnabled(rule, s.Rules[rule]); err != nil {
+			printError(s.out, err)
+			return -1
+		}
+	}
+	diagnostics := analyzer.Analyze(program)
+	for _, d := range diagnostics {
+		fmt.Fprintln(s.out, d)
+	}
+	if semantic.HasErrors(diagnostics) {
+		return -1
+	}
+	return len(diagnostics)
+}
+
+// transpile prints the code of the program for a target, once it passes
+// the semantic checks.
+func (s *Session) transpile(lang string) {
+	descriptor, err := transpiler.GetTranspiler(lang)
+	if err != nil {
+		printError(s.out, err)
+		return
+	}
+	program, ok := s.program()
+	if !ok || s.check(program) < 0 {
+		return
+	}
+	warnings, err := transpiler.NegotiateFeatures(lang, program)
+	if err != nil {
+		printError(s.out, err)
+		return
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(s.out, "%s: warning: %s [%s]\n", w.Pos, w.Note, w.Feature)
+	}
+	t := descriptor.Initializer()
+	if err := transpiler.ApplyOptions(t, lang, s.Options[lang]); err != nil {
+		printError(s.out, err)
+		return
+	}
+	var code strings.Builder
+	if err := transpiler.TranspileContext(context.Background(), t, &code, program); err != nil {
+		printError(s.out, fmt.Errorf("transpilation failed: %w", err))
+		return
+	}
+	fmt.Fprint(s.out, code.String())
+	if !strings.HasSuffix(code.String(), "\n") {
+		fmt.Fprintln(s.out)
+	}
+}
+
+// setTarget sets the target the program is transpiled to after each
+// input, or prints it and the available ones without a name.
+func (s *Session) setTarget(lang string) {
+	switch lang {
+	case "":
+		current := s.Target
+		if current == "" {
+			current = "none"
+		}
+		fmt.Fprintf(s.out, "target: %s (%s)\n", current, strings.Join(transpiler.GetTranspilerNames(), ", "))
+	case "off", "none":
+		s.Target = ""
+	default:
+		lang = strings.ToLower(lang)
+		if _, err := transpiler.GetTranspiler(lang); err != nil {
+			printError(s.out, err)
+			return
+		}
+		s.Target = lang
+		if len(s.body) > 0 {
+			s.transpile(lang)
+		}
+	}
+}
+
+// printError prints an error, or each of the errors it joins, e.g. the
+// syntax errors of a program.
+func printError(out io.Writer, err error) {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		fmt.Fprintf(out, "error: %v\n", err)
+	}
+}
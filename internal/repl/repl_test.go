@@ -0,0 +1,87 @@
+package repl
+
+import (
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, input string) (*Session, string) {
+	t.Helper()
+	var out strings.Builder
+	session := New(&out)
+	if err := session.Run(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	return session, out.String()
+}
+
+func TestSession_Run(t *testing.T) {
+	session, out := run(t, `(reads file (desc "Reads"))
+(run_docker
+  (image "aligner:1.0")
+  (arguments "align" reads))
+(threads
+:check
+:show
+`)
+	if !strings.Contains(out, "Param: reads") || !strings.Contains(out, "Block: run_docker") {
+		t.Errorf("output lacks the syntax trees of the snippets:\n%s", out)
+	}
+	if !strings.Contains(out, "error: unclosed list discarded") || !strings.Contains(out, "No issues found") {
+		t.Errorf("output lacks the outcome of the unclosed list and of :check:\n%s", out)
+	}
+	expected := `(bala repl (
+  (reads file (desc "Reads"))
+  (run_docker (image "aligner:1.0") (arguments "align" reads))
+))
+`
+	if source := session.source(); source != expected || !strings.HasSuffix(out, expected) {
+		t.Errorf("source() = %q, want %q", source, expected)
+	}
+}
+
+func TestSession_Errors(t *testing.T) {
+	session, out := run(t, `(reads file)
+(bala "name" ())
+"reads"
+(threads integer (default "x"))
+:check
+:transpile
+:bogus
+`)
+	for _, want := range []string{
+		"invalid program name",
+		`error: Line 1, Column 1: expected a list, got STRING "reads"`,
+		"Line 3, Column 4: error: default of parameter 'threads' is a string",
+		"error: no target",
+		"error: unknown command :bogus",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	// The syntax errors leave the program unchanged
+	if len(session.body) != 2 || session.name != "repl" {
+		t.Errorf("body = %q, want the two parameters", session.body)
+	}
+}
+
+func TestSession_Transpile(t *testing.T) {
+	session, out := run(t, `:target bash
+(bala align (
+  ; the reads
+  (reads file)
+  (run_docker (image "aligner:1.0") (arguments reads))))
+:quit
+(never file)
+`)
+	if session.name != "align" || !strings.Contains(session.source(), "; the reads") {
+		t.Errorf("source() = %q, want the entered program", session.source())
+	}
+	if !strings.Contains(out, `run_docker "aligner:1.0"`) {
+		t.Errorf("output lacks the bash script:\n%s", out)
+	}
+	if strings.Contains(out, "never") {
+		t.Errorf("input after :quit was evaluated:\n%s", out)
+	}
+}
@@ -0,0 +1,168 @@
+// Package schema describes the fields accepted by each implementation block,
+// shared by the parser and the semantic checks.
+package schema
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+)
+
+// FieldKind describes the shape of a field value.
+type FieldKind int
+
+const (
+	FieldString FieldKind = iota // (image "ubuntu:22.04")
+	FieldList                    // (arguments a "b" c)
+	FieldPairs                   // (volumes (a "/x") (b "/y"))
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case FieldList:
+		return "list"
+	case FieldPairs:
+		return "list of pairs"
+	}
+	return "string"
+}
+
+// Field describes a field of an implementation block.
+type Field struct {
+	Name     string
+	Kind     FieldKind
+	Required bool
+	// PairReference is the index of the pair element that may reference a
+	// parameter, for FieldPairs fields, or NoReference.
+	PairReference int
+	// Templates reports whether the last element of each pair is a
+	// template, whose ${name} placeholders reference parameters.
+	Templates bool
+}
+
+// NoReference is the PairReference of pairs that don't reference
+// parameters.
+const NoReference = -1
+
+// Implementation describes an implementation block and its fields.
+type Implementation struct {
+	Name   string
+	Fields []Field
+}
+
+// Field returns the schema of a field by name.
+func (i Implementation) Field(name string) (Field, bool) {
+	idx := slices.IndexFunc(i.Fields, func(f Field) bool { return f.Name == name })
+	if idx < 0 {
+		return Field{}, false
+	}
+	return i.Fields[idx], true
+}
+
+// FieldNames returns the names of the fields, in declaration order.
+func (i Implementation) FieldNames() []string {
+	names := make([]string, len(i.Fields))
+	for idx, f := range i.Fields {
+		names[idx] = f.Name
+	}
+	return names
+}
+
+var registry = map[string]Implementation{}
+
+func init() {
+	Register(Implementation{
+		Name: "run_docker",
+		Fields: []Field{
+			{Name: "image", Kind: FieldString, Required: true},
+			{Name: "command", Kind: FieldString},
+			{Name: "volumes", Kind: FieldPairs, PairReference: 0},
+			{Name: "env", Kind: FieldPairs, PairReference: 1},
+			{Name: "arguments", Kind: FieldList},
+			{Name: "version_command", Kind: FieldString},
+			{Name: "exit_codes", Kind: FieldPairs, PairReference: NoReference},
+			{Name: "error_patterns", Kind: FieldPairs, PairReference: NoReference},
+			{Name: "config_files", Kind: FieldPairs, PairReference: NoReference, Templates: true},
+		},
+	})
+}
+
+// Register adds or replaces the schema of an implementation block.
+func Register(impl Implementation) {
+	registry[impl.Name] = impl
+}
+
+// Lookup retrieves the schema of an implementation block by name.
+func Lookup(name string) (Implementation, bool) {
+	impl, ok := registry[name]
+	return impl, ok
+}
+
+// Names returns the registered implementation block names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// placeholder matches the ${name} placeholders of templates.
+var placeholder = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// Placeholders returns the names of the placeholders of a template, in
+// order.
+func Placeholders(template string) []string {
+	names := []string{}
+	for _, match := range placeholder.FindAllStringSubmatch(template, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// ExpandTemplate returns a template with each placeholder replaced by the
+// expansion of its name, and the text around them by the escaping of it.
+func ExpandTemplate(template string, expand func(name string) string, escape func(text string) string) string {
+	var out []byte
+	last := 0
+	for _, match := range placeholder.FindAllStringSubmatchIndex(template, -1) {
+		out = append(out, escape(template[last:match[0]])...)
+		out = append(out, expand(template[match[2]:match[3]])...)
+		last = match[1]
+	}
+	return string(append(out, escape(template[last:])...))
+}
+
+// Closest returns the candidate closest to name, e.g. to one of Names or
+// FieldNames, when it is close enough to be a likely typo, or "".
+func Closest(name string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(b)]
+}
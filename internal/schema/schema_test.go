@@ -0,0 +1,48 @@
+package schema
+
+import "testing"
+
+func TestLookup_RunDocker(t *testing.T) {
+	impl, ok := Lookup("run_docker")
+	if !ok {
+		t.Fatal("run_docker schema not registered")
+	}
+	image, ok := impl.Field("image")
+	if !ok || !image.Required || image.Kind != FieldString {
+		t.Errorf("unexpected image field %+v", image)
+	}
+	if _, ok := impl.Field("imagee"); ok {
+		t.Error("unexpected field imagee")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register(Implementation{
+		Name:   "run_test",
+		Fields: []Field{{Name: "script", Kind: FieldString, Required: true}},
+	})
+	defer delete(registry, "run_test")
+
+	if _, ok := Lookup("run_test"); !ok {
+		t.Fatal("run_test schema not registered")
+	}
+	names := Names()
+	if len(names) != 2 || names[0] != "run_docker" || names[1] != "run_test" {
+		t.Errorf("Names() = %v", names)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	if d := editDistance("imagee", "image"); d != 1 {
+		t.Errorf("editDistance(imagee, image) = %d, want 1", d)
+	}
+	if d := editDistance("", "env"); d != 3 {
+		t.Errorf("editDistance(\"\", env) = %d, want 3", d)
+	}
+	if best := Closest("run_dockr", Names()); best != "run_docker" {
+		t.Errorf("Closest(run_dockr) = %q, want run_docker", best)
+	}
+	if best := Closest("reads", Names()); best != "" {
+		t.Errorf("Closest(reads) = %q, want none", best)
+	}
+}
@@ -0,0 +1,45 @@
+package semantic
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// ChecksumAlgorithms are the algorithms of the checksum metadata, with the
+// length of their hexadecimal digests. Every target computes all of them.
+var ChecksumAlgorithms = map[string]int{
+	"md5":    32,
+	"sha1":   40,
+	"sha256": 64,
+	"sha512": 128,
+}
+
+// checkChecksums verifies that the checksum metadata, which the targets
+// verify before running the container, is on file parameters and has the
+// <algorithm>:<hex digest> form.
+func checkChecksums(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		checksum, ok := param.Metadata["checksum"]
+		if !ok {
+			continue
+		}
+		if param.Type != "file" {
+			r.Errorf(param.Pos, "checksum of parameter '%s' applies to files, not %s parameters",
+				param.Name, param.Type)
+			continue
+		}
+		algorithm, digest, _ := strings.Cut(checksum, ":")
+		length, known := ChecksumAlgorithms[algorithm]
+		if !known {
+			r.Errorf(param.Pos, "checksum '%s' of parameter '%s' must be <algorithm>:<digest>, with md5, sha1, sha256 or sha512",
+				checksum, param.Name)
+			continue
+		}
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != length {
+			r.Errorf(param.Pos, "%s digest of parameter '%s' must have %d hexadecimal digits, got '%s'",
+				algorithm, param.Name, length, digest)
+		}
+	}
+}
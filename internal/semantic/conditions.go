@@ -0,0 +1,61 @@
+package semantic
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkConditions verifies that the condition of a parameter depends on
+// another, unconditional, enum or boolean parameter, and on values it can
+// take. A parameter depending on another is in its group, if any.
+func checkConditions(r Reporter, program *ast.Program) {
+	params := map[string]ast.Parameter{}
+	names := []string{}
+	for _, param := range program.Parameters {
+		params[param.Name] = param
+		names = append(names, param.Name)
+	}
+
+	for _, param := range program.Parameters {
+		when := param.When
+		if when == nil {
+			continue
+		}
+		selector, ok := params[when.Param]
+		switch {
+		case !ok:
+			r.Errorf(when.Pos, "parameter '%s' depends on the undefined parameter '%s'%s",
+				param.Name, when.Param, suggestion(when.Param, names))
+			continue
+		case selector.Name == param.Name:
+			r.Errorf(when.Pos, "parameter '%s' can't depend on itself", param.Name)
+			continue
+		case selector.Type != ast.TypeEnum && selector.Type != ast.TypeBoolean:
+			r.Errorf(when.Pos, "parameter '%s' depends on '%s', a %s parameter, expected an enum or a boolean",
+				param.Name, selector.Name, selector.Type)
+			continue
+		case selector.When != nil:
+			r.Errorf(when.Pos, "parameter '%s' depends on '%s', which depends on '%s' itself; conditions can't be chained",
+				param.Name, selector.Name, selector.When.Param)
+			continue
+		}
+
+		if group := param.Metadata["group"]; group != "" && group != selector.Metadata["group"] {
+			r.Errorf(when.Pos, "parameter '%s' is in group '%s' but depends on '%s', which is not; it is shown with '%s'",
+				param.Name, group, selector.Name, selector.Name)
+		}
+		if len(when.Values) == 0 {
+			r.Errorf(when.Pos, "condition of parameter '%s' lists no values of '%s'", param.Name, selector.Name)
+		}
+		for _, value := range when.Values {
+			if !LiteralMatchesType(value, selector.Type) {
+				r.Errorf(when.Pos, "value '%v' in the condition of parameter '%s' is a %s, expected a %s value of '%s'",
+					value, param.Name, literalKind(value), selector.Type, selector.Name)
+			} else if selector.Type == ast.TypeEnum && !slices.Contains(selector.Constraints, value) {
+				r.Errorf(when.Pos, "value '%v' in the condition of parameter '%s' is not one of the allowed values %v of '%s'",
+					value, param.Name, selector.Constraints, selector.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package semantic
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkConfigFiles verifies that the config files of an implementation are
+// (path template) pairs, each written to a distinct absolute path of the
+// container. The placeholders of the templates are checked with the other
+// references.
+func checkConfigFiles(r Reporter, program *ast.Program) {
+	for _, impl := range program.Implementations {
+		files, ok := impl.Fields["config_files"].([]any)
+		if !ok {
+			continue
+		}
+		pos := impl.FieldPosition("config_files")
+		seen := map[string]bool{}
+		for i, item := range files {
+			entry, _ := item.([]any)
+			if len(entry) != 2 {
+				r.Errorf(pos, "config file %d of '%s' must be a (path template) pair, got %v", i+1, impl.Name, entry)
+				continue
+			}
+			file := fmt.Sprintf("%v", entry[0])
+			if !path.IsAbs(file) {
+				r.Errorf(pos, "path '%s' of config file %d of '%s' must be absolute", file, i+1, impl.Name)
+				continue
+			}
+			if file = path.Clean(file); seen[file] {
+				r.Errorf(pos, "config file '%s' of '%s' is declared more than once", file, impl.Name)
+			}
+			seen[file] = true
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package semantic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkConstraints verifies the constraints on the values of parameters:
+// the min and max metadata are numbers bounding integer and number
+// parameters, the pattern metadata is a regular expression on string
+// parameters, optional is true or false, and defaults satisfy them.
+func checkConstraints(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		bounds := map[string]float64{}
+		for _, key := range []string{"min", "max"} {
+			value, ok := param.Metadata[key]
+			if !ok {
+				continue
+			}
+			if param.Type != ast.TypeInteger && param.Type != ast.TypeNumber {
+				r.Errorf(param.Pos, "%s of parameter '%s' applies to integer and number parameters, not %s parameters",
+					key, param.Name, param.Type)
+				continue
+			}
+			bound, err := strconv.ParseFloat(value, 64)
+			if _, errInt := strconv.Atoi(value); err != nil || param.Type == ast.TypeInteger && errInt != nil {
+				r.Errorf(param.Pos, "%s of parameter '%s' must be a %s, got '%s'", key, param.Name, param.Type, value)
+				continue
+			}
+			bounds[key] = bound
+		}
+		low, hasMin := bounds["min"]
+		high, hasMax := bounds["max"]
+		if hasMin && hasMax && low > high {
+			r.Errorf(param.Pos, "min %v of parameter '%s' is greater than its max %v", low, param.Name, high)
+		}
+		if value, ok := numericDefault(param.Default); ok && (hasMin && value < low || hasMax && value > high) {
+			r.Errorf(param.Pos, "default %v of parameter '%s' is outside of its range %s",
+				param.Default, param.Name, rangeText(param.Metadata["min"], param.Metadata["max"]))
+		}
+
+		if pattern, ok := param.Metadata["pattern"]; ok {
+			re, err := regexp.Compile(pattern)
+			switch {
+			case param.Type != ast.TypeString:
+				r.Errorf(param.Pos, "pattern of parameter '%s' applies to string parameters, not %s parameters",
+					param.Name, param.Type)
+			case err != nil:
+				r.Errorf(param.Pos, "invalid regular expression '%s' in the pattern of parameter '%s': %v", pattern, param.Name, err)
+			default:
+				if value, ok := param.Default.(string); ok && !re.MatchString(value) {
+					r.Errorf(param.Pos, "default '%s' of parameter '%s' does not match its pattern '%s'", value, param.Name, pattern)
+				}
+			}
+		}
+
+		if optional, ok := param.Metadata["optional"]; ok {
+			switch {
+			case optional != "true" && optional != "false":
+				r.Errorf(param.Pos, "optional of parameter '%s' must be true or false, got '%s'", param.Name, optional)
+			case optional == "true" && param.Default != nil:
+				r.Warnf(param.Pos, "parameter '%s' is optional and has a default, which is used when it is omitted", param.Name)
+			}
+		}
+	}
+}
+
+// numericDefault returns the default of a parameter as a number, if it is
+// one.
+func numericDefault(value any) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// rangeText describes the range of a parameter, e.g. [1, 64], with either
+// bound possibly missing.
+func rangeText(low, high string) string {
+	if low == "" {
+		low = "-∞"
+	}
+	if high == "" {
+		high = "∞"
+	}
+	return fmt.Sprintf("[%s, %s]", low, high)
+}
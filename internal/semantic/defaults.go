@@ -0,0 +1,42 @@
+package semantic
+
+import (
+	"fmt"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkDefaultTypes verifies that every default value is a literal of the
+// parameter's type, so the transpilers can format defaults by their Go type.
+func checkDefaultTypes(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		if param.Default == nil || LiteralMatchesType(param.Default, param.Type) {
+			continue
+		}
+		r.Errorf(param.Pos, "default of parameter '%s' is a %s, expected a %s value",
+			param.Name, literalKind(param.Default), param.Type)
+	}
+}
+
+// checkEnumDefaults verifies that the default of an enum parameter is one of
+// its allowed values.
+func checkEnumDefaults(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		if param.Type != "enum" || param.Default == nil {
+			continue
+		}
+
+		def := fmt.Sprintf("%v", param.Default)
+		found := false
+		for _, c := range param.Constraints {
+			if fmt.Sprintf("%v", c) == def {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.Errorf(param.Pos, "default '%s' of parameter '%s' is not one of the allowed values %v",
+				def, param.Name, param.Constraints)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package semantic
+
+import (
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkDeprecations warns about the deprecated constructs found by the
+// parser, which "baryon fmt -fix" rewrites.
+func checkDeprecations(r Reporter, program *ast.Program) {
+	for _, d := range program.Deprecations {
+		r.Warnf(d.Pos, "%s is deprecated, use '%s' instead (run 'baryon fmt -fix' to rewrite it)",
+			d.Construct, d.Replacement)
+	}
+}
@@ -0,0 +1,77 @@
+package semantic
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// ErrorLevels are the levels of the exit codes and error patterns of an
+// implementation, from a message that is only logged to a failure caused by
+// the container running out of memory.
+var ErrorLevels = []string{"log", "warning", "fatal", "fatal_oom"}
+
+// checkExitCodes verifies that the exit codes of an implementation are
+// (range level [description]) entries, a range being a code or a N:M range
+// open on either side, and that its error patterns are (regex level
+// [description]) entries with a valid regular expression.
+func checkExitCodes(r Reporter, program *ast.Program) {
+	for _, impl := range program.Implementations {
+		for _, field := range []string{"exit_codes", "error_patterns"} {
+			entries, ok := impl.Fields[field].([]any)
+			if !ok {
+				continue
+			}
+			pos := impl.FieldPosition(field)
+			first := "range"
+			if field == "error_patterns" {
+				first = "regex"
+			}
+			for i, item := range entries {
+				entry, _ := item.([]any)
+				if len(entry) < 2 || len(entry) > 3 {
+					r.Errorf(pos, "entry %d of the %s of '%s' must be a (%s level [description]) list, got %v",
+						i+1, field, impl.Name, first, entry)
+					continue
+				}
+				value := fmt.Sprintf("%v", entry[0])
+				if field == "exit_codes" && !validExitRange(value) {
+					r.Errorf(pos, "exit code range '%s' of '%s' must be a code, or N:M with either side omitted",
+						value, impl.Name)
+				}
+				if field == "error_patterns" {
+					if _, err := regexp.Compile(value); err != nil {
+						r.Errorf(pos, "invalid regular expression '%s' in the error patterns of '%s': %v", value, impl.Name, err)
+					}
+				}
+				if level := fmt.Sprintf("%v", entry[1]); !slices.Contains(ErrorLevels, level) {
+					r.Errorf(pos, "unknown level '%s' in the %s of '%s', expected one of %s%s",
+						level, field, impl.Name, strings.Join(ErrorLevels, ", "), suggestion(level, ErrorLevels))
+				}
+			}
+		}
+	}
+}
+
+// validExitRange reports whether a range of exit codes is a code, e.g. 137,
+// or a N:M range, e.g. 1:, :-1 or 2:5.
+func validExitRange(value string) bool {
+	from, to, isRange := strings.Cut(value, ":")
+	if !isRange {
+		_, err := strconv.Atoi(value)
+		return err == nil
+	}
+	if from == "" && to == "" {
+		return false
+	}
+	for _, bound := range []string{from, to} {
+		if _, err := strconv.Atoi(bound); bound != "" && err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package semantic
+
+import (
+	"sort"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/schema"
+)
+
+// checkImplementationFields validates implementation blocks against their
+// registered schema: unknown fields, missing required fields and values of
+// the wrong shape.
+func checkImplementationFields(r Reporter, program *ast.Program) {
+	for _, impl := range program.Implementations {
+		implSchema, ok := schema.Lookup(impl.Name)
+		if !ok {
+			r.Errorf(impl.Pos, "unknown implementation block '%s'%s",
+				impl.Name, suggestion(impl.Name, schema.Names()))
+			continue
+		}
+
+		for _, name := range fieldNames(impl) {
+			field, ok := implSchema.Field(name)
+			if !ok {
+				r.Errorf(impl.FieldPosition(name), "unknown field '%s' in '%s'%s",
+					name, impl.Name, suggestion(name, implSchema.FieldNames()))
+				continue
+			}
+			if !valueMatchesKind(impl.Fields[name], field.Kind) {
+				r.Errorf(impl.FieldPosition(name), "field '%s' of '%s' must be a %s",
+					name, impl.Name, field.Kind)
+			}
+		}
+
+		for _, field := range implSchema.Fields {
+			if _, ok := impl.Fields[field.Name]; field.Required && !ok {
+				if _, present := impl.FieldPos[field.Name]; !present {
+					r.Errorf(impl.Pos, "missing required field '%s' in '%s'", field.Name, impl.Name)
+				}
+			}
+		}
+	}
+}
+
+// fieldNames returns the fields present in the block, either parsed or set
+// programmatically, sorted by name.
+func fieldNames(impl ast.ImplementationBlock) []string {
+	seen := map[string]bool{}
+	for name := range impl.Fields {
+		seen[name] = true
+	}
+	for name := range impl.FieldPos {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func valueMatchesKind(value any, kind schema.FieldKind) bool {
+	switch kind {
+	case schema.FieldString:
+		_, ok := value.(string)
+		return ok
+	case schema.FieldList, schema.FieldPairs:
+		_, ok := value.([]any)
+		return ok
+	}
+	return false
+}
+
+// suggestion returns a "did you mean" hint for the closest candidate, if
+// any is close enough to be a likely typo.
+func suggestion(name string, candidates []string) string {
+	best := schema.Closest(name, candidates)
+	if best == "" {
+		return ""
+	}
+	return ", did you mean '" + best + "'?"
+}
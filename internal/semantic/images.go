@@ -0,0 +1,62 @@
+package semantic
+
+import (
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// checkImageReferences verifies that every container image is a valid OCI
+// reference.
+func checkImageReferences(r Reporter, program *ast.Program) {
+	for _, impl := range program.Implementations {
+		img, ok := impl.Fields["image"].(string)
+		if !ok {
+			continue
+		}
+		if _, err := image.Parse(img); err != nil {
+			r.Errorf(impl.FieldPosition("image"), "%v", err)
+		}
+	}
+}
+
+// checkUnpinnedImages warns about images without a tag or digest, or tagged
+// "latest", since their content can change between runs.
+func checkUnpinnedImages(r Reporter, program *ast.Program) {
+	for _, impl := range program.Implementations {
+		img, ok := impl.Fields["image"].(string)
+		if !ok {
+			continue
+		}
+		ref, err := image.Parse(img)
+		if err != nil || ref.Pinned() {
+			continue
+		}
+		r.Warnf(impl.FieldPosition("image"),
+			"image '%s' is not pinned to a version tag or digest, results may not be reproducible", img)
+	}
+}
+
+// CheckImagesExist returns a check that queries the registry of every valid
+// image reference with exists, reporting the images it fails to find. Each
+// image is looked up once.
+func CheckImagesExist(exists func(image.Reference) error) Check {
+	return func(r Reporter, program *ast.Program) {
+		results := map[string]error{}
+		for _, impl := range program.Implementations {
+			img, ok := impl.Fields["image"].(string)
+			if !ok {
+				continue
+			}
+			ref, err := image.Parse(img)
+			if err != nil {
+				continue
+			}
+			if _, ok := results[img]; !ok {
+				results[img] = exists(ref)
+			}
+			if err := results[img]; err != nil {
+				r.Errorf(impl.FieldPosition("image"), "%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package semantic
+
+import (
+	"regexp"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// locale matches the locales of labels, a language optionally followed by
+// a region, e.g. it or pt_BR.
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}([_-][A-Za-z0-9]{2,8})?$`)
+
+// checkLabels verifies the label translations of parameters: their locales
+// are well formed and they translate a description, which the targets use
+// when no locale is selected.
+func checkLabels(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		locales := param.Locales()
+		for _, l := range locales {
+			if !localePattern.MatchString(l) {
+				r.Errorf(param.Pos, "label locale '%s' of parameter '%s' must be a language, optionally with a region, e.g. it or pt_BR",
+					l, param.Name)
+			}
+		}
+		if len(locales) > 0 && param.Description == "" {
+			r.Warnf(param.Pos, "parameter '%s' has labels but no desc, used when no locale is selected", param.Name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package semantic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/naming"
+)
+
+// checkParameterNames warns about parameter names that are not valid
+// identifiers in some target, and so get mangled there, and reports
+// parameters that end up with the same name in a target.
+func checkParameterNames(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		if override := param.Metadata[naming.MetadataKey]; override != "" {
+			if invalid := invalidTargets(override); len(invalid) > 0 {
+				r.Warnf(param.Pos, "%s '%s' of parameter '%s' is not a valid identifier in %s",
+					naming.MetadataKey, override, param.Name, strings.Join(invalid, ", "))
+			}
+			continue
+		}
+
+		// Group the targets by the name the parameter is renamed to
+		renamed := map[string][]string{}
+		order := []string{}
+		for _, target := range invalidTargets(param.Name) {
+			mangled := naming.Mangle(target, param.Name)
+			if _, ok := renamed[mangled]; !ok {
+				order = append(order, mangled)
+			}
+			renamed[mangled] = append(renamed[mangled], target)
+		}
+		if len(order) == 0 {
+			continue
+		}
+		renames := []string{}
+		for _, mangled := range order {
+			renames = append(renames, fmt.Sprintf("'%s' in %s", mangled, strings.Join(renamed[mangled], ", ")))
+		}
+		r.Warnf(param.Pos, "parameter '%s' is renamed to %s, where it is not a valid identifier (set (%s \"...\") to choose the name)",
+			param.Name, strings.Join(renames, " and "), naming.MetadataKey)
+	}
+
+	for _, target := range naming.Targets() {
+		seen := map[string]string{}
+		for _, param := range program.Parameters {
+			name := naming.TargetName(target, param.Name, param.Metadata)
+			if other, ok := seen[name]; ok {
+				r.Errorf(param.Pos, "parameters '%s' and '%s' are both named '%s' in %s",
+					other, param.Name, name, target)
+				continue
+			}
+			seen[name] = param.Name
+		}
+	}
+}
+
+// invalidTargets returns the targets where a name is not a valid identifier.
+func invalidTargets(name string) []string {
+	invalid := []string{}
+	for _, target := range naming.Targets() {
+		if !naming.Valid(target, name) {
+			invalid = append(invalid, target)
+		}
+	}
+	return invalid
+}
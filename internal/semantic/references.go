@@ -0,0 +1,54 @@
+package semantic
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Keywords are identifiers with a meaning of their own inside implementation
+// blocks, which don't need to be declared as parameters.
+var Keywords = []string{
+	"_",             // argument placeholder, skipped by the transpilers
+	"parent_folder", // directory of the first file parameter
+}
+
+// checkReferences verifies that every identifier used in an implementation
+// block resolves to a declared parameter or a keyword.
+func checkReferences(r Reporter, program *ast.Program) {
+	declared := map[string]bool{}
+	for _, param := range program.Parameters {
+		declared[param.Name] = true
+	}
+
+	for _, impl := range program.Implementations {
+		for _, ref := range impl.References {
+			if declared[ref.Name] || slices.Contains(Keywords, ref.Name) {
+				continue
+			}
+			r.Errorf(ref.Pos, "undefined parameter '%s' in %s of '%s'",
+				ref.Name, ref.Field, impl.Name)
+		}
+	}
+}
+
+// checkUnusedParameters warns about parameters that no implementation block
+// references, which usually means a typo in the wrapper.
+func checkUnusedParameters(r Reporter, program *ast.Program) {
+	if len(program.Implementations) == 0 {
+		return
+	}
+
+	used := map[string]bool{}
+	for _, impl := range program.Implementations {
+		for _, ref := range impl.References {
+			used[ref.Name] = true
+		}
+	}
+
+	for _, param := range program.Parameters {
+		if !used[param.Name] {
+			r.Warnf(param.Pos, "parameter '%s' is never used by any implementation", param.Name)
+		}
+	}
+}
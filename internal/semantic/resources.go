@@ -0,0 +1,81 @@
+package semantic
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+var (
+	memoryAmount = regexp.MustCompile(`(?i)^\d+(\.\d+)?\s*(B|KB|MB|GB|TB)$`)
+	timeDuration = regexp.MustCompile(`^(\d+(\.\d+)?\s*(ms|s|m|h|d)\s*)+$`)
+	processLabel = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// checkResources verifies that the resources block requests known
+// resources, once each, with values of the expected kind: a positive number
+// of CPUs or GPUs, a memory amount such as "8 GB", a duration such as
+// "1h 30m", a label that can be used as an identifier, a number of
+// retries, a boolean or a
+// directory for scratch, a known stage-in mode and a work directory.
+func checkResources(r Reporter, program *ast.Program) {
+	seen := map[string]bool{}
+	for _, resource := range program.Resources {
+		if !slices.Contains(ast.ResourceNames, resource.Name) {
+			r.Errorf(resource.Pos, "unknown resource '%s', expected one of %s%s", resource.Name,
+				strings.Join(ast.ResourceNames, ", "), suggestion(resource.Name, ast.ResourceNames))
+			continue
+		}
+		if seen[resource.Name] {
+			r.Errorf(resource.Pos, "resource '%s' is requested more than once", resource.Name)
+		}
+		seen[resource.Name] = true
+
+		text, isText := resource.Value.(string)
+		switch resource.Name {
+		case ast.ResourceCPUs, ast.ResourceGPUs:
+			if n, ok := resource.Value.(int); !ok || n < 1 {
+				r.Errorf(resource.Pos, "%s must be a positive integer, got '%v'", resource.Name, resource.Value)
+			}
+		case ast.ResourceRetries:
+			if n, ok := resource.Value.(int); !ok || n < 0 {
+				r.Errorf(resource.Pos, "retries must be a non-negative integer, got '%v'", resource.Value)
+			}
+		case ast.ResourceMemory:
+			if !isText || !memoryAmount.MatchString(text) {
+				r.Errorf(resource.Pos, "memory must be an amount with a unit, e.g. \"8 GB\", got '%v'", resource.Value)
+			}
+		case ast.ResourceTime:
+			if !isText || !timeDuration.MatchString(text) {
+				r.Errorf(resource.Pos, "time must be a duration in ms, s, m, h or d, e.g. \"1h 30m\", got '%v'", resource.Value)
+			}
+		case ast.ResourceGPUType:
+			if !isText || text == "" {
+				r.Errorf(resource.Pos, "gpu_type must be a string, got '%v'", resource.Value)
+			}
+		case ast.ResourceLabel:
+			if !isText || !processLabel.MatchString(text) {
+				r.Errorf(resource.Pos, "label must be an identifier, e.g. \"process_high\", got '%v'", resource.Value)
+			}
+		case ast.ResourceScratch:
+			if _, isBool := resource.Value.(bool); !isBool && (!isText || text == "") {
+				r.Errorf(resource.Pos, "scratch must be true, false or a directory, e.g. \"$TMPDIR\", got '%v'", resource.Value)
+			}
+		case ast.ResourceStageInMode:
+			if !isText || !slices.Contains(ast.StageInModes, text) {
+				r.Errorf(resource.Pos, "stage_in_mode must be one of %s, got '%v'%s", strings.Join(ast.StageInModes, ", "),
+					resource.Value, suggestion(text, ast.StageInModes))
+			}
+		case ast.ResourceWorkDir:
+			if !isText || text == "" {
+				r.Errorf(resource.Pos, "work_dir must be a directory, got '%v'", resource.Value)
+			}
+		}
+	}
+	if _, ok := program.Resource(ast.ResourceGPUType); ok && !seen[ast.ResourceGPUs] {
+		i := slices.IndexFunc(program.Resources, func(r ast.Resource) bool { return r.Name == ast.ResourceGPUType })
+		r.Errorf(program.Resources[i].Pos, "gpu_type is requested without a number of gpus")
+	}
+}
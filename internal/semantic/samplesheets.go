@@ -0,0 +1,46 @@
+package semantic
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkSamplesheets verifies that samplesheet parameters declare their
+// columns, each with a unique identifier and a known type, and at least one
+// file or directory column. The columns metadata is only used by
+// samplesheets.
+func checkSamplesheets(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		if param.Type != ast.TypeSamplesheet {
+			if _, ok := param.Metadata["columns"]; ok {
+				r.Warnf(param.Pos, "parameter '%s' declares columns but only samplesheets have columns", param.Name)
+			}
+			continue
+		}
+		columns := param.Columns()
+		if len(columns) == 0 {
+			r.Errorf(param.Pos, "samplesheet '%s' needs its columns, e.g. (columns \"sample, fastq_1:file\")", param.Name)
+			continue
+		}
+		seen := map[string]bool{}
+		paths := false
+		for _, column := range columns {
+			if !processLabel.MatchString(column.Name) {
+				r.Errorf(param.Pos, "column '%s' of samplesheet '%s' must be an identifier", column.Name, param.Name)
+			} else if seen[column.Name] {
+				r.Errorf(param.Pos, "column '%s' of samplesheet '%s' is declared more than once", column.Name, param.Name)
+			}
+			seen[column.Name] = true
+			if !slices.Contains(ast.ColumnTypes, column.Type) {
+				r.Errorf(param.Pos, "column '%s' of samplesheet '%s' has unknown type '%s', expected one of %s%s",
+					column.Name, param.Name, column.Type, strings.Join(ast.ColumnTypes, ", "), suggestion(column.Type, ast.ColumnTypes))
+			}
+			paths = paths || column.Type == ast.TypeFile || column.Type == ast.TypeDirectory
+		}
+		if !paths {
+			r.Errorf(param.Pos, "samplesheet '%s' has no file or directory column", param.Name)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package semantic implements checks over a parsed Baryon program, which run
+// before transpilation so that every target sees a program with the same
+// guarantees.
+package semantic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Severity describes how a diagnostic affects the build.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a single finding reported by a check.
+type Diagnostic struct {
+	Severity Severity
+	Rule     string
+	Pos      ast.Position
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Pos, d.Severity, d.Message, d.Rule)
+}
+
+// Reporter collects the findings of a check.
+type Reporter interface {
+	Errorf(pos ast.Position, format string, args ...any)
+	Warnf(pos ast.Position, format string, args ...any)
+}
+
+// Check inspects a program and reports its findings.
+type Check func(r Reporter, program *ast.Program)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Analyzer runs a set of registered checks over a program.
+type Analyzer struct {
+	checks   []namedCheck
+	disabled map[string]bool
+}
+
+// New creates an Analyzer with the default checks registered.
+func New() *Analyzer {
+	a := &Analyzer{disabled: map[string]bool{}}
+	a.RegisterCheck("unresolved-reference", checkReferences)
+	a.RegisterCheck("unused-parameter", checkUnusedParameters)
+	a.RegisterCheck("unknown-type", checkParameterTypes)
+	a.RegisterCheck("parameter-name", checkParameterNames)
+	a.RegisterCheck("default-type", checkDefaultTypes)
+	a.RegisterCheck("enum-default", checkEnumDefaults)
+	a.RegisterCheck("condition", checkConditions)
+	a.RegisterCheck("checksum", checkChecksums)
+	a.RegisterCheck("constraint", checkConstraints)
+	a.RegisterCheck("visibility", checkVisibility)
+	a.RegisterCheck("labels", checkLabels)
+	a.RegisterCheck("implementation-schema", checkImplementationFields)
+	a.RegisterCheck("image-reference", checkImageReferences)
+	a.RegisterCheck("unpinned-image", checkUnpinnedImages)
+	a.RegisterCheck("volume-shape", checkVolumes)
+	a.RegisterCheck("exit-codes", checkExitCodes)
+	a.RegisterCheck("config-files", checkConfigFiles)
+	a.RegisterCheck("output-path", checkOutputPaths)
+	a.RegisterCheck("tests", checkTests)
+	a.RegisterCheck("resources", checkResources)
+	a.RegisterCheck("samplesheets", checkSamplesheets)
+	a.RegisterCheck("shell-injection", checkShellInjection)
+	a.RegisterCheck("deprecated", checkDeprecations)
+	return a
+}
+
+// RegisterCheck adds a check, identified by its rule name.
+func (a *Analyzer) RegisterCheck(name string, check Check) {
+	a.checks = append(a.checks, namedCheck{name: name, check: check})
+}
+
+// Rules returns the names of the registered checks, in registration order.
+func (a *Analyzer) Rules() []string {
+	names := make([]string, len(a.checks))
+	for i, c := range a.checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// SetEnabled enables or disables a registered check.
+func (a *Analyzer) SetEnabled(rule string, enabled bool) error {
+	if !slices.Contains(a.Rules(), rule) {
+		return fmt.Errorf("unknown lint rule '%s'%s", rule, suggestion(rule, a.Rules()))
+	}
+	a.disabled[rule] = !enabled
+	return nil
+}
+
+// Analyze runs all enabled checks and returns their diagnostics, in the
+// order the checks were registered. Diagnostics suppressed by a
+// "; baryon:disable=rule" comment are dropped.
+func (a *Analyzer) Analyze(program *ast.Program) []Diagnostic {
+	suppressed := suppressions(program)
+	diagnostics := []Diagnostic{}
+	for _, c := range a.checks {
+		if a.disabled[c.name] {
+			continue
+		}
+		r := &reporter{rule: c.name}
+		c.check(r, program)
+		for _, d := range r.diagnostics {
+			if !slices.Contains(suppressed[d.Pos.Line], d.Rule) {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// Analyze runs the default checks over a program.
+func Analyze(program *ast.Program) []Diagnostic {
+	return New().Analyze(program)
+}
+
+// HasErrors reports whether any diagnostic is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrSemantic matches every SemanticError with errors.Is.
+var ErrSemantic = errors.New("semantic error")
+
+// SemanticError is an error diagnostic returned as an error. The rule of
+// the diagnostic identifies the kind of error.
+type SemanticError struct {
+	Diagnostic
+}
+
+func (e *SemanticError) Error() string {
+	return e.Diagnostic.String()
+}
+
+// Is makes errors.Is(err, ErrSemantic) report true.
+func (e *SemanticError) Is(target error) bool {
+	return target == ErrSemantic
+}
+
+// Error joins the error diagnostics into a single error of SemanticErrors,
+// or returns nil if there are none.
+func Error(diagnostics []Diagnostic) error {
+	errs := []error{}
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, &SemanticError{d})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type reporter struct {
+	rule        string
+	diagnostics []Diagnostic
+}
+
+func (r *reporter) Errorf(pos ast.Position, format string, args ...any) {
+	r.report(SeverityError, pos, format, args...)
+}
+
+func (r *reporter) Warnf(pos ast.Position, format string, args ...any) {
+	r.report(SeverityWarning, pos, format, args...)
+}
+
+func (r *reporter) report(severity Severity, pos ast.Position, format string, args ...any) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Severity: severity,
+		Rule:     r.rule,
+		Pos:      pos,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
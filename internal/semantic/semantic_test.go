@@ -0,0 +1,844 @@
+package semantic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+func analyzeInput(t *testing.T, input string) []Diagnostic {
+	t.Helper()
+	prog, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	return Analyze(prog)
+}
+
+func diagnosticsForRule(diagnostics []Diagnostic, rule string) []Diagnostic {
+	found := []Diagnostic{}
+	for _, d := range diagnostics {
+		if d.Rule == rule {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func TestCheckReferences_Resolved(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(mode string (desc "Mode"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(volumes (input "/data") (parent_folder "/scratch"))
+			(env (MODE mode))
+			(arguments "--input" input _))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "unresolved-reference")
+	if len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diagnostics)
+	}
+}
+
+func TestCheckReferences_Unresolved(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(volumes (inptu "/data"))
+			(env (MODE mdoe))
+			(arguments "--input" input outptu))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "unresolved-reference")
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %v", diagnostics)
+	}
+	for _, name := range []string{"inptu", "mdoe", "outptu"} {
+		found := false
+		for _, d := range diagnostics {
+			if strings.Contains(d.Message, "'"+name+"'") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing diagnostic for %q in %v", name, diagnostics)
+		}
+	}
+	if d := diagnostics[0]; d.Severity != SeverityError || d.Pos.Line != 6 {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+	if !HasErrors(diagnostics) || Error(diagnostics) == nil {
+		t.Errorf("expected diagnostics to be errors")
+	}
+	var semErr *SemanticError
+	if err := Error(diagnostics); !errors.As(err, &semErr) || !errors.Is(err, ErrSemantic) ||
+		semErr.Rule != "unresolved-reference" {
+		t.Errorf("expected a SemanticError, got %#v", err)
+	}
+}
+
+func TestError_NoErrors(t *testing.T) {
+	diagnostics := []Diagnostic{{Severity: SeverityWarning, Rule: "x", Message: "warn"}}
+	if HasErrors(diagnostics) {
+		t.Error("HasErrors() = true, want false")
+	}
+	if err := Error(diagnostics); err != nil {
+		t.Errorf("Error() = %v, want nil", err)
+	}
+}
+
+func TestCheckUnusedParameters(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(threads integer (desc "Threads"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(arguments input))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "unused-parameter")
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diagnostics)
+	}
+	d := diagnostics[0]
+	if d.Severity != SeverityWarning || !strings.Contains(d.Message, "'threads'") || d.Pos.Line != 4 {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+}
+
+func TestCheckUnusedParameters_NoImplementations(t *testing.T) {
+	input := `(bala myprog ((input file (desc "Input"))))`
+	if diagnostics := diagnosticsForRule(analyzeInput(t, input), "unused-parameter"); len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diagnostics)
+	}
+}
+
+func TestCheckEnumDefaults(t *testing.T) {
+	input := `
+	(bala myprog (
+		(good (enum ("A" "B")) (default "B") (desc "Valid default"))
+		(bad (enum ("A" "B")) (default "C") (desc "Invalid default"))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "enum-default")
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diagnostics)
+	}
+	d := diagnostics[0]
+	if d.Severity != SeverityError || !strings.Contains(d.Message, "'bad'") || d.Pos.Line != 4 {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+}
+
+func TestCheckDefaultTypes(t *testing.T) {
+	input := `
+	(bala myprog (
+		(threads integer (default "four"))
+		(ratio number (default true))
+		(sep character (default "ab"))
+		(name string (default "ok"))
+		(scale number (default 2))
+		(flag boolean (default false))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "default-type")
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %v", diagnostics)
+	}
+	for i, name := range []string{"threads", "ratio", "sep"} {
+		if !strings.Contains(diagnostics[i].Message, "'"+name+"'") {
+			t.Errorf("diagnostic %d: expected parameter %q, got %v", i, name, diagnostics[i])
+		}
+	}
+}
+
+func TestCheckChecksums(t *testing.T) {
+	input := `
+	(bala myprog (
+		(reads file (checksum "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"))
+		(index file (checksum "md5:D41D8CD98F00B204E9800998ECF8427E"))
+		(name string (checksum "md5:d41d8cd98f00b204e9800998ecf8427e"))
+		(genome file (checksum "crc32:cbf43926"))
+		(bed file (checksum "sha1:abc"))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "checksum")
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %v", diagnostics)
+	}
+	for i, name := range []string{"name", "genome", "bed"} {
+		if diagnostics[i].Severity != SeverityError || !strings.Contains(diagnostics[i].Message, "'"+name+"'") {
+			t.Errorf("diagnostic %d: expected an error on parameter %q, got %v", i, name, diagnostics[i])
+		}
+	}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	input := `
+	(bala myprog (
+		(threads integer (default 4) (min 1) (max 64))
+		(ratio number (min 0.5))
+		(sample string (pattern "^[A-Z]+$") (default "ABC"))
+		(prefix string (optional true))
+		(reads file (min 1))
+		(depth integer (min 1.5))
+		(window integer (min 10) (max 5))
+		(trim integer (default 0) (min 1))
+		(name string (pattern "(") )
+		(chars character (pattern "a"))
+		(label string (pattern "^x") (default "y"))
+		(verbose boolean (optional yes))
+		(seed integer (default 1) (optional true))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "constraint")
+	expected := []string{
+		"min of parameter 'reads' applies to integer and number parameters",
+		"min of parameter 'depth' must be a integer, got '1.5'",
+		"min 10 of parameter 'window' is greater than its max 5",
+		"default 0 of parameter 'trim' is outside of its range [1, ∞]",
+		"invalid regular expression '(' in the pattern of parameter 'name'",
+		"pattern of parameter 'chars' applies to string parameters",
+		"default 'y' of parameter 'label' does not match its pattern '^x'",
+		"optional of parameter 'verbose' must be true or false, got 'yes'",
+		"parameter 'seed' is optional and has a default",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if !strings.Contains(diagnostics[i].Message, message) {
+			t.Errorf("diagnostic %d: expected %q, got %q", i, message, diagnostics[i].Message)
+		}
+	}
+	if diagnostics[len(diagnostics)-1].Severity != SeverityWarning {
+		t.Errorf("an optional parameter with a default should be a warning, got %v", diagnostics[len(diagnostics)-1])
+	}
+}
+
+func TestCheckVisibility(t *testing.T) {
+	input := `
+	(bala myprog (
+		(threads integer (default 4) (advanced))
+		(prefix string (optional true) (hidden))
+		(mode (enum ("fast" "slow")) (default "fast") (hidden))
+		(index file (when mode "slow"))
+		(seed integer (advanced))
+		(chunk integer (default 1) (hidden) (advanced))
+		(verbose boolean (default false) (hidden yes))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "visibility")
+	expected := []string{
+		"parameter 'mode' can't be hidden, other parameters depend on it",
+		"advanced parameter 'seed' must have a default or be optional",
+		"parameter 'chunk' is hidden, marking it advanced has no effect",
+		"hidden of parameter 'verbose' must be true or false, got 'yes'",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if !strings.Contains(diagnostics[i].Message, message) {
+			t.Errorf("diagnostic %d: expected %q, got %q", i, message, diagnostics[i].Message)
+		}
+	}
+	if diagnostics[2].Severity != SeverityWarning {
+		t.Errorf("a hidden and advanced parameter should be a warning, got %v", diagnostics[2])
+	}
+}
+
+func TestCheckLabels(t *testing.T) {
+	input := `
+	(bala myprog (
+		(reads file (desc "Reads") (label (it "Letture") (pt_BR "Leituras")))
+		(sep character (label (Italian "Separatore")))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "labels")
+	expected := []string{
+		"label locale 'Italian' of parameter 'sep' must be a language",
+		"parameter 'sep' has labels but no desc",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if !strings.Contains(diagnostics[i].Message, message) {
+			t.Errorf("diagnostic %d: expected %q, got %q", i, message, diagnostics[i].Message)
+		}
+	}
+	if diagnostics[1].Severity != SeverityWarning {
+		t.Errorf("labels without a desc should be a warning, got %v", diagnostics[1])
+	}
+}
+
+func TestCheckParameterTypes(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(table data (desc "Galaxy-only type"))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "unknown-type")
+	if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityWarning {
+		t.Fatalf("expected 1 warning, got %v", diagnostics)
+	}
+}
+
+func TestCheckImages(t *testing.T) {
+	input := `
+	(bala myprog (
+		(run_docker (image "ubuntu:22.04"))
+		(run_docker (image "Bad Image"))
+		(run_docker (image "repbioinfo/qiime2023"))
+		(run_docker
+			(image "ubuntu:latest"))
+	))
+	`
+	diagnostics := analyzeInput(t, input)
+	invalid := diagnosticsForRule(diagnostics, "image-reference")
+	if len(invalid) != 1 || invalid[0].Pos.Line != 4 {
+		t.Errorf("expected 1 invalid image on line 4, got %v", invalid)
+	}
+	unpinned := diagnosticsForRule(diagnostics, "unpinned-image")
+	if len(unpinned) != 2 {
+		t.Fatalf("expected 2 unpinned images, got %v", unpinned)
+	}
+	if unpinned[1].Severity != SeverityWarning || unpinned[1].Pos.Line != 7 {
+		t.Errorf("unexpected diagnostic %v", unpinned[1])
+	}
+}
+
+func TestCheckVolumes(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(volumes
+				(input "/data")
+				(parent_folder "/scratch")
+				("/tmp" "/tmp")
+				(input "relative")
+				("/only-host")
+				("named" "/named")
+				(unknown "/unknown"))
+			(arguments input))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "volume-shape")
+	expected := []string{"'relative'", "volume 5", "'named'"}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, fragment := range expected {
+		if !strings.Contains(diagnostics[i].Message, fragment) {
+			t.Errorf("diagnostic %d: expected %q in %v", i, fragment, diagnostics[i])
+		}
+		if diagnostics[i].Pos.Line != 6 {
+			t.Errorf("diagnostic %d: expected line 6, got %v", i, diagnostics[i].Pos)
+		}
+	}
+}
+
+func TestCheckExitCodes(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(exit_codes
+				("1:" "fatal")
+				(137 "fatal_oom" "Out of memory")
+				(":-1" "warning")
+				("a:b" "fatal")
+				("2" "fail")
+				("3"))
+			(error_patterns
+				("^Error:" "fatal")
+				("[" "warning"))
+			(arguments input))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "exit-codes")
+	expected := []string{"'a:b'", "'fail'", "entry 6", "'['"}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, fragment := range expected {
+		if !strings.Contains(diagnostics[i].Message, fragment) {
+			t.Errorf("diagnostic %d: expected %q in %v", i, fragment, diagnostics[i])
+		}
+	}
+}
+
+func TestCheckConfigFiles(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(threads integer (desc "Threads"))
+		(unused string (desc "Unused"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(config_files
+				("/data/tool.ini" "threads = ${threads}
+input = ${input}")
+				("/data/other.ini" "mode = ${mdoe}")
+				("/data/tool.ini" "")
+				("relative.ini" "")
+				("/data/lonely.ini"))
+			(arguments "--config" "/data/tool.ini"))
+	))
+	`
+	diagnostics := analyzeInput(t, input)
+	messages := []string{}
+	for _, rule := range []string{"config-files", "unresolved-reference", "unused-parameter"} {
+		for _, diagnostic := range diagnosticsForRule(diagnostics, rule) {
+			messages = append(messages, diagnostic.Message)
+		}
+	}
+	expected := []string{
+		"config file '/data/tool.ini' of 'run_docker' is declared more than once",
+		"path 'relative.ini' of config file 4 of 'run_docker' must be absolute",
+		"config file 5 of 'run_docker' must be a (path template) pair, got [/data/lonely.ini]",
+		"undefined parameter 'mdoe' in config_files of 'run_docker'",
+		"parameter 'unused' is never used by any implementation",
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), messages)
+	}
+	for i, message := range expected {
+		if messages[i] != message {
+			t.Errorf("diagnostic %d = %q, want %q", i, messages[i], message)
+		}
+	}
+}
+
+func TestCheckImplementationFields(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(run_docker
+			(imagee "ubuntu:22.04")
+			(command unquoted)
+			(arguments input))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "implementation-schema")
+	expected := []string{
+		"field 'command' of 'run_docker' must be a string",
+		"unknown field 'imagee' in 'run_docker', did you mean 'image'?",
+		"missing required field 'image' in 'run_docker'",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if diagnostics[i].Message != message {
+			t.Errorf("diagnostic %d = %q, want %q", i, diagnostics[i].Message, message)
+		}
+	}
+	if diagnostics[1].Pos.Line != 5 {
+		t.Errorf("expected unknown field on line 5, got %v", diagnostics[1].Pos)
+	}
+}
+
+func TestCheckImplementationFields_UnknownBlock(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(mode (enum ("fast" "slow")) (default "fast"))
+		(run_dockr (image "ubuntu:22.04") (arguments input mode))
+		(run_podman (image "ubuntu:22.04") (arguments input mode))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "implementation-schema")
+	expected := []string{
+		"unknown implementation block 'run_dockr', did you mean 'run_docker'?",
+		"unknown implementation block 'run_podman'",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if diagnostics[i].Message != message {
+			t.Errorf("diagnostic %d = %q, want %q", i, diagnostics[i].Message, message)
+		}
+	}
+	if diagnostics[0].Pos.Line != 5 {
+		t.Errorf("expected the unknown block on line 5, got %v", diagnostics[0].Pos)
+	}
+}
+
+func TestAnalyzer_SuppressionComments(t *testing.T) {
+	input := `
+	(bala myprog (
+		(used string (desc "Used"))
+		(unused string (desc "Unused")) ; baryon:disable=unused-parameter
+		; baryon:disable=unused-parameter,unpinned-image kept for later
+		(spare string (desc "Spare"))
+		(other string (desc "Other"))
+		(run_docker
+			(image "ubuntu") ; baryon:disable=unpinned-image
+			(arguments used))
+	))
+	`
+	diagnostics := analyzeInput(t, input)
+	unused := diagnosticsForRule(diagnostics, "unused-parameter")
+	if len(unused) != 1 || !strings.Contains(unused[0].Message, "'other'") {
+		t.Errorf("expected only 'other' to be reported as unused, got %v", unused)
+	}
+	if unpinned := diagnosticsForRule(diagnostics, "unpinned-image"); len(unpinned) != 0 {
+		t.Errorf("expected unpinned-image to be suppressed, got %v", unpinned)
+	}
+}
+
+func TestAnalyzer_SetEnabled(t *testing.T) {
+	prog, err := parser.New(lexer.New(`
+	(bala myprog (
+		(unused string (desc "Unused"))
+		(run_docker (image "ubuntu:22.04"))
+	))
+	`)).ParseProgram()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	analyzer := New()
+	if err := analyzer.SetEnabled("unused-parameter", false); err != nil {
+		t.Fatalf("SetEnabled() unexpected error: %v", err)
+	}
+	if diagnostics := diagnosticsForRule(analyzer.Analyze(prog), "unused-parameter"); len(diagnostics) != 0 {
+		t.Errorf("expected disabled rule to report nothing, got %v", diagnostics)
+	}
+
+	err = analyzer.SetEnabled("unused-parameters", false)
+	if err == nil || !strings.Contains(err.Error(), "did you mean 'unused-parameter'?") {
+		t.Errorf("expected unknown rule error with suggestion, got %v", err)
+	}
+}
+
+func TestShellConstruct(t *testing.T) {
+	tests := map[string]bool{
+		"--input":          false,
+		"Rscript /a.r":     false,
+		"echo `id`":        true,
+		"$(whoami)":        true,
+		"\"$(whoami)\"":    true,
+		"'$(whoami)'":      false,
+		"a; rm -rf /":      true,
+		"'a; b'":           false,
+		"*.fastq":          true,
+		"'*.fastq'":        false,
+		"\"sample?.txt\"":  false,
+		"\\*.fastq":        false,
+		"${HOME}/data.csv": false,
+	}
+	for value, expected := range tests {
+		if got := shellConstruct(value) != ""; got != expected {
+			t.Errorf("shellConstruct(%q) = %q, want construct: %v", value, shellConstruct(value), expected)
+		}
+	}
+}
+
+func TestCheckShellInjection(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input file (desc "Input"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(env (("FILES" "*.fastq")))
+			(arguments
+				"--input" input
+				"$(cat /etc/passwd)"
+				"a; b" ; baryon:disable=shell-injection
+			))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "shell-injection")
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diagnostics)
+	}
+	if !strings.Contains(diagnostics[0].Message, "env value '*.fastq'") {
+		t.Errorf("unexpected diagnostic: %v", diagnostics[0])
+	}
+	if !strings.Contains(diagnostics[1].Message, "command substitution") || diagnostics[1].Pos.Line != 9 {
+		t.Errorf("unexpected diagnostic: %v", diagnostics[1])
+	}
+}
+
+func TestCheckOutputPaths(t *testing.T) {
+	input := `
+	(bala myprog (
+		(input directory (desc "Input"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(volumes (input "/scratch"))
+			(arguments input))
+		(outputs
+			(results directory "/scratch/results")
+			(mount directory "/scratch")
+			(relative file "report.html")
+			(lost directory "/results")
+			(prefix directory "/scratch_other"))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "output-path")
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diagnostics)
+	}
+	if !strings.Contains(diagnostics[0].Message, "'lost'") || !strings.Contains(diagnostics[1].Message, "'prefix'") {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestCheckOutputPaths_DefaultMount(t *testing.T) {
+	input := `
+	(bala myprog (
+		(run_docker (image "ubuntu:22.04"))
+		(outputs (results directory "/data/results"))
+	))
+	`
+	if diagnostics := diagnosticsForRule(analyzeInput(t, input), "output-path"); len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diagnostics)
+	}
+}
+
+func TestCheckConditions(t *testing.T) {
+	input := `
+	(bala myprog (
+		(mode (enum ("fast" "sensitive")) (default "fast"))
+		(paired boolean (default false))
+		(threads integer (default 4))
+		(index file (when mode "sensitive"))
+		(mates file (group "Advanced") (when paired true))
+		(seed integer (when mode "exact"))
+		(ratio number (when modes "fast"))
+		(gap integer (when threads 4))
+		(chained integer (when index "x"))
+		(flag boolean (when mode 1))
+		(run_docker
+			(image "ubuntu:22.04")
+			(arguments mode paired threads index mates seed ratio gap chained flag))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "condition")
+	expected := []string{"is in group 'Advanced'", "'exact'", "did you mean 'mode'", "integer parameter", "a file parameter", "is a integer"}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, fragment := range expected {
+		if !strings.Contains(diagnostics[i].Message, fragment) {
+			t.Errorf("diagnostic %d: expected %q in %v", i, fragment, diagnostics[i])
+		}
+	}
+}
+
+func TestCheckTests(t *testing.T) {
+	input := `
+	(bala myprog (
+		(reads file)
+		(mode (enum ("fast" "slow")) (default "fast"))
+		(run_docker (image "ubuntu:22.04") (arguments (reads mode)))
+		(outputs (aligned sam "/data/out.sam"))
+		(tests
+			(valid (params (reads "reads.fq") (mode "slow"))
+				(expect (aligned (has_text "@SQ") (lines 3) (checksum "md5:d41d8cd98f00b204e9800998ecf8427e"))))
+			(invalid (params (read "reads.fq") (mode "other"))
+				(expect (align (lines "x")) (aligned (has_txt "@SQ") (matches "(")))))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "tests")
+	expected := []string{
+		"undefined parameter 'read' in test 'invalid', did you mean 'reads'?",
+		"value 'other' of parameter 'mode'",
+		"gives no value to the required parameter 'reads'",
+		"undefined output 'align' in test 'invalid', did you mean 'aligned'?",
+		"lines expects a number of lines",
+		"unknown assertion 'has_txt'",
+		"invalid regular expression '('",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if !strings.Contains(diagnostics[i].Message, message) {
+			t.Errorf("diagnostic %d: expected %q, got %q", i, message, diagnostics[i].Message)
+		}
+	}
+}
+
+func TestCheckResources(t *testing.T) {
+	input := `
+	(bala myprog (
+		(run_docker (image "ubuntu:22.04"))
+		(resources
+			(cpus 4) (memory "8 GB") (time "1h 30m") (label "process_high")
+			(cpu 2) (cpus 0) (memory 8) (time "2 hours") (gpu_type "nvidia-tesla-v100") (label "high memory"))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "resources")
+	expected := []string{
+		"unknown resource 'cpu', expected one of cpus, memory, time, gpus, gpu_type, label, retries, scratch, stage_in_mode, work_dir, did you mean 'cpus'?",
+		"resource 'cpus' is requested more than once",
+		"cpus must be a positive integer, got '0'",
+		"resource 'memory' is requested more than once",
+		"memory must be an amount with a unit, e.g. \"8 GB\", got '8'",
+		"resource 'time' is requested more than once",
+		"time must be a duration in ms, s, m, h or d, e.g. \"1h 30m\", got '2 hours'",
+		"resource 'label' is requested more than once",
+		"label must be an identifier, e.g. \"process_high\", got 'high memory'",
+		"gpu_type is requested without a number of gpus",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if diagnostics[i].Message != message {
+			t.Errorf("diagnostic %d = %q, want %q", i, diagnostics[i].Message, message)
+		}
+	}
+}
+
+func TestCheckResources_Execution(t *testing.T) {
+	for _, tt := range []struct {
+		resources string
+		expected  []string
+	}{
+		{`(scratch true) (stage_in_mode "copy") (work_dir "/shared/work")`, nil},
+		{`(scratch "$TMPDIR") (retries 0)`, nil},
+		{`(retries 1.5)`, []string{"retries must be a non-negative integer, got '1.5'"}},
+		{`(scratch 1) (stage_in_mode "simlink") (work_dir "")`, []string{
+			"scratch must be true, false or a directory, e.g. \"$TMPDIR\", got '1'",
+			"stage_in_mode must be one of symlink, rellink, link, copy, got 'simlink', did you mean 'symlink'?",
+			"work_dir must be a directory, got ''",
+		}},
+	} {
+		input := `(bala myprog ((run_docker (image "ubuntu:22.04")) (resources ` + tt.resources + `)))`
+		diagnostics := diagnosticsForRule(analyzeInput(t, input), "resources")
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%s: expected %d diagnostics, got %v", tt.resources, len(tt.expected), diagnostics)
+			continue
+		}
+		for i, message := range tt.expected {
+			if diagnostics[i].Message != message {
+				t.Errorf("%s: diagnostic %d = %q, want %q", tt.resources, i, diagnostics[i].Message, message)
+			}
+		}
+	}
+}
+
+func TestCheckSamplesheets(t *testing.T) {
+	input := `
+	(bala myprog (
+		(samples samplesheet (columns "sample, condition:string, fastq_1:file, reads:directory"))
+		(nocols samplesheet)
+		(bad samplesheet (columns "sample, sample, 1st:file, depth:interger"))
+		(reads file (columns "fastq_1:file"))
+		(run_docker (image "ubuntu:22.04") (arguments samples nocols bad reads))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "samplesheets")
+	expected := []string{
+		"samplesheet 'nocols' needs its columns, e.g. (columns \"sample, fastq_1:file\")",
+		"column 'sample' of samplesheet 'bad' is declared more than once",
+		"column '1st' of samplesheet 'bad' must be an identifier",
+		"column 'depth' of samplesheet 'bad' has unknown type 'interger', expected one of string, integer, number, boolean, file, directory, did you mean 'integer'?",
+		"parameter 'reads' declares columns but only samplesheets have columns",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, message := range expected {
+		if diagnostics[i].Message != message {
+			t.Errorf("diagnostic %d = %q, want %q", i, diagnostics[i].Message, message)
+		}
+	}
+
+	diagnostics = diagnosticsForRule(analyzeInput(t, `
+	(bala myprog (
+		(samples samplesheet (columns "sample, condition"))
+		(run_docker (image "ubuntu:22.04") (arguments samples))
+	))
+	`), "samplesheets")
+	if len(diagnostics) != 1 || diagnostics[0].Message != "samplesheet 'samples' has no file or directory column" {
+		t.Errorf("expected a samplesheet without paths to be reported, got %v", diagnostics)
+	}
+}
+
+func TestCheckImagesExist(t *testing.T) {
+	prog, err := parser.New(lexer.New(`
+	(bala myprog (
+		(run_docker (image "ubuntu:22.04"))
+		(run_docker (image "ubuntu:22.40"))
+		(run_docker (image "ubuntu:22.40"))
+	))
+	`)).ParseProgram()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	lookups := 0
+	analyzer := &Analyzer{}
+	analyzer.RegisterCheck("image-exists", CheckImagesExist(func(ref image.Reference) error {
+		lookups++
+		if ref.Tag != "22.04" {
+			return fmt.Errorf("image '%s' not found", ref)
+		}
+		return nil
+	}))
+	diagnostics := analyzer.Analyze(prog)
+	if len(diagnostics) != 2 || diagnostics[0].Pos.Line != 4 {
+		t.Errorf("expected 2 diagnostics for the missing image, got %v", diagnostics)
+	}
+	if lookups != 2 {
+		t.Errorf("expected each image to be looked up once, got %d lookups", lookups)
+	}
+}
+
+func TestCheckParameterNames(t *testing.T) {
+	input := `
+	(bala myprog (
+		(sample-id string (desc "Sample"))
+		(lambda number (desc "Smoothing"))
+		(read.length integer (target_name "read_length"))
+		(sample_id string (desc "Duplicate once mangled"))
+		(run_docker
+			(image "ubuntu:22.04")
+			(arguments sample-id lambda read.length sample_id))
+	))
+	`
+	diagnostics := diagnosticsForRule(analyzeInput(t, input), "parameter-name")
+	warnings, errors := []Diagnostic{}, []Diagnostic{}
+	for _, d := range diagnostics {
+		if d.Severity == SeverityWarning {
+			warnings = append(warnings, d)
+		} else {
+			errors = append(errors, d)
+		}
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	if !strings.Contains(warnings[0].Message, "renamed to 'sample_id' in bash, galaxy, nextflow, python, r") {
+		t.Errorf("unexpected warning: %v", warnings[0])
+	}
+	if !strings.Contains(warnings[1].Message, "renamed to 'lambda_' in python") {
+		t.Errorf("unexpected warning: %v", warnings[1])
+	}
+	if len(errors) != 5 || !strings.Contains(errors[0].Message, "'sample-id' and 'sample_id'") {
+		t.Errorf("expected a collision in every target, got %v", errors)
+	}
+}
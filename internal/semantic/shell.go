@@ -0,0 +1,65 @@
+package semantic
+
+import (
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// shellFields are the fields whose literals the Bash, Nextflow and Galaxy
+// targets interpolate into shell commands.
+var shellFields = map[string]bool{"arguments": true, "env": true}
+
+// checkShellInjection reports argument and env literals that a shell would
+// interpret, such as command substitutions, command separators and unquoted
+// globs. Intentional uses must be opted into with a
+// "; baryon:disable=shell-injection" comment.
+func checkShellInjection(r Reporter, program *ast.Program) {
+	for _, impl := range program.Implementations {
+		for _, lit := range impl.Literals {
+			if !shellFields[lit.Field] {
+				continue
+			}
+			if construct := shellConstruct(lit.Value); construct != "" {
+				r.Errorf(lit.Pos,
+					"%s value '%s' contains %s, which is interpreted by the shell in the bash, nextflow and galaxy targets; "+
+						"add '; %sshell-injection' to allow it",
+					lit.Field, lit.Value, construct, DisableDirective)
+			}
+		}
+	}
+}
+
+// shellConstruct returns a description of the first shell construct found
+// in a value, or "" if there is none. Single quotes disable every construct,
+// double quotes all but substitutions.
+func shellConstruct(value string) string {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+			continue
+		case c == '\\':
+			i++
+			continue
+		case c == '`':
+			return "a backtick command substitution"
+		case c == '$' && i+1 < len(value) && value[i+1] == '(':
+			return "a '$(...)' command substitution"
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+			continue
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';':
+			return "a ';' command separator"
+		case c == '*' || c == '?' || c == '[':
+			return "an unquoted glob '" + string(c) + "'"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+package semantic
+
+import (
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// DisableDirective starts a comment that suppresses rules on a node, as in
+// "; baryon:disable=unused-parameter,unpinned-image".
+const DisableDirective = "baryon:disable="
+
+// suppressions maps source lines to the rules disabled on them. A trailing
+// comment applies to its own line, a comment on its own line to the next one.
+func suppressions(program *ast.Program) map[int][]string {
+	lines := map[int][]string{}
+	for _, comment := range program.Comments {
+		text := strings.TrimSpace(strings.TrimLeft(comment.Text, ";"))
+		rules, ok := strings.CutPrefix(text, DisableDirective)
+		if !ok {
+			continue
+		}
+		line := comment.Pos.Line
+		if !comment.Trailing {
+			line++
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			// Anything after the rule list is a free-form explanation.
+			rule, _, _ = strings.Cut(strings.TrimSpace(rule), " ")
+			if rule != "" {
+				lines[line] = append(lines[line], rule)
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,107 @@
+package semantic
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkTests verifies that the tests block refers to declared parameters
+// and outputs, gives every required parameter a value of its type, and uses
+// known assertions with values of the expected kind.
+func checkTests(r Reporter, program *ast.Program) {
+	params := map[string]ast.Parameter{}
+	names := []string{}
+	for _, param := range program.Parameters {
+		params[param.Name] = param
+		names = append(names, param.Name)
+	}
+	outputs := []string{}
+	for _, output := range program.Outputs {
+		outputs = append(outputs, output.Name)
+	}
+
+	seen := map[string]bool{}
+	for _, test := range program.Tests {
+		if seen[test.Name] {
+			r.Errorf(test.Pos, "test '%s' is declared more than once", test.Name)
+		}
+		seen[test.Name] = true
+
+		set := map[string]bool{}
+		for _, value := range test.Params {
+			param, ok := params[value.Name]
+			if !ok {
+				r.Errorf(value.Pos, "undefined parameter '%s' in test '%s'%s",
+					value.Name, test.Name, suggestion(value.Name, names))
+				continue
+			}
+			if set[value.Name] {
+				r.Errorf(value.Pos, "parameter '%s' is set more than once in test '%s'", value.Name, test.Name)
+			}
+			set[value.Name] = true
+			if !LiteralMatchesType(value.Value, param.Type) {
+				r.Errorf(value.Pos, "value of parameter '%s' in test '%s' is a %s, expected a %s value",
+					value.Name, test.Name, literalKind(value.Value), param.Type)
+			} else if param.Type == ast.TypeEnum && !slices.Contains(param.Constraints, value.Value) {
+				r.Errorf(value.Pos, "value '%v' of parameter '%s' in test '%s' is not one of the allowed values %v",
+					value.Value, value.Name, test.Name, param.Constraints)
+			}
+		}
+		for _, param := range program.Parameters {
+			if param.Default == nil && !set[param.Name] {
+				r.Errorf(test.Pos, "test '%s' gives no value to the required parameter '%s'", test.Name, param.Name)
+			}
+		}
+
+		for _, expect := range test.Expect {
+			if !slices.Contains(outputs, expect.Output) {
+				r.Errorf(expect.Pos, "undefined output '%s' in test '%s'%s",
+					expect.Output, test.Name, suggestion(expect.Output, outputs))
+			}
+			for _, assertion := range expect.Assertions {
+				if problem := assertionProblem(assertion); problem != "" {
+					r.Errorf(assertion.Pos, "assertion on output '%s' in test '%s': %s", expect.Output, test.Name, problem)
+				}
+			}
+		}
+	}
+}
+
+// assertionProblem describes what is wrong with an assertion, if anything.
+func assertionProblem(assertion ast.Assertion) string {
+	if !slices.Contains(ast.AssertionKinds, assertion.Kind) {
+		return fmt.Sprintf("unknown assertion '%s', expected one of %s%s", assertion.Kind,
+			strings.Join(ast.AssertionKinds, ", "), suggestion(assertion.Kind, ast.AssertionKinds))
+	}
+	if assertion.Kind == ast.AssertLines {
+		if n, ok := assertion.Value.(int); !ok || n < 0 {
+			return fmt.Sprintf("%s expects a number of lines, got '%v'", assertion.Kind, assertion.Value)
+		}
+		return ""
+	}
+	text, ok := assertion.Value.(string)
+	if !ok {
+		return fmt.Sprintf("%s expects a string, got a %s", assertion.Kind, literalKind(assertion.Value))
+	}
+	switch assertion.Kind {
+	case ast.AssertMatches:
+		if _, err := regexp.Compile(text); err != nil {
+			return fmt.Sprintf("invalid regular expression '%s': %v", text, err)
+		}
+	case ast.AssertChecksum:
+		algorithm, digest, _ := strings.Cut(text, ":")
+		length, known := ChecksumAlgorithms[algorithm]
+		if !known {
+			return fmt.Sprintf("checksum '%s' must be <algorithm>:<digest>, with md5, sha1, sha256 or sha512", text)
+		}
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != length {
+			return fmt.Sprintf("%s digest must have %d hexadecimal digits, got '%s'", algorithm, length, digest)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package semantic
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkParameterTypes warns about parameter types that aren't built-in, since
+// only the targets that register them will accept the program.
+func checkParameterTypes(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		if param.Type == "" {
+			r.Errorf(param.Pos, "parameter '%s' has no type", param.Name)
+			continue
+		}
+		if !slices.Contains(ast.Types, param.Type) {
+			r.Warnf(param.Pos, "parameter '%s' has unknown type '%s'", param.Name, param.Type)
+		}
+	}
+}
+
+// LiteralMatchesType reports whether a parsed literal is a valid value for a
+// parameter of the given type. Unknown types accept any literal.
+func LiteralMatchesType(value any, paramType string) bool {
+	switch paramType {
+	case ast.TypeString, ast.TypeFile, ast.TypeDirectory, ast.TypeEnum, ast.TypeSamplesheet:
+		_, ok := value.(string)
+		return ok
+	case ast.TypeCharacter:
+		s, ok := value.(string)
+		return ok && len([]rune(s)) == 1
+	case ast.TypeInteger:
+		_, ok := value.(int)
+		return ok
+	case ast.TypeNumber:
+		switch value.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	case ast.TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	}
+	return true
+}
+
+// literalKind describes a parsed literal for diagnostics.
+func literalKind(value any) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case int:
+		return "integer"
+	case float64:
+		return "number"
+	}
+	return "string"
+}
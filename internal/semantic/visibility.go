@@ -0,0 +1,41 @@
+package semantic
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkVisibility verifies the hidden and advanced markers of parameters:
+// they are true or false, and the parameters they mark have a default or
+// are optional, the interfaces not asking for them. A parameter others
+// depend on can't be hidden.
+func checkVisibility(r Reporter, program *ast.Program) {
+	for _, param := range program.Parameters {
+		marked := []string{}
+		for _, marker := range []string{"hidden", "advanced"} {
+			value, ok := param.Metadata[marker]
+			switch {
+			case !ok:
+			case value != "true" && value != "false":
+				r.Errorf(param.Pos, "%s of parameter '%s' must be true or false, got '%s'", marker, param.Name, value)
+			case value == "true":
+				marked = append(marked, marker)
+			}
+		}
+		if len(marked) == 0 {
+			continue
+		}
+		if param.Default == nil && param.Metadata["optional"] != "true" {
+			r.Errorf(param.Pos, "%s parameter '%s' must have a default or be optional, as it isn't asked for",
+				marked[0], param.Name)
+		}
+		if len(marked) == 2 {
+			r.Warnf(param.Pos, "parameter '%s' is hidden, marking it advanced has no effect", param.Name)
+		}
+		dependsOn := func(p ast.Parameter) bool { return p.When != nil && p.When.Param == param.Name }
+		if marked[0] == "hidden" && slices.ContainsFunc(program.Parameters, dependsOn) {
+			r.Errorf(param.Pos, "parameter '%s' can't be hidden, other parameters depend on it", param.Name)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package semantic
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// checkVolumes verifies that every volume maps a parameter, keyword or
+// absolute host path to an absolute guest path.
+func checkVolumes(r Reporter, program *ast.Program) {
+	declared := map[string]bool{}
+	for _, param := range program.Parameters {
+		declared[param.Name] = true
+	}
+
+	for _, impl := range program.Implementations {
+		volumes, ok := impl.Fields["volumes"].([]any)
+		if !ok {
+			continue
+		}
+		pos := impl.FieldPosition("volumes")
+
+		// Unknown identifiers are reported by the reference check
+		referenced := map[string]bool{}
+		for _, ref := range impl.References {
+			if ref.Field == "volumes" {
+				referenced[ref.Name] = true
+			}
+		}
+
+		for i, vol := range volumes {
+			entry, _ := vol.([]any)
+			if len(entry) < 2 {
+				r.Errorf(pos, "volume %d of '%s' must be a (host guest) pair, got %v",
+					i+1, impl.Name, entry)
+				continue
+			}
+			if len(entry) > 2 {
+				r.Warnf(pos, "volume %d of '%s' has extra elements %v, which are ignored",
+					i+1, impl.Name, entry[2:])
+			}
+
+			host := fmt.Sprintf("%v", entry[0])
+			guest := fmt.Sprintf("%v", entry[1])
+
+			if !declared[host] && !slices.Contains(Keywords, host) &&
+				host != "parent-folder" && !path.IsAbs(host) && !referenced[host] {
+				r.Errorf(pos, "host '%s' of volume %d of '%s' is neither a parameter nor an absolute path",
+					host, i+1, impl.Name)
+			}
+			if !path.IsAbs(guest) {
+				r.Errorf(pos, "guest path '%s' of volume %d of '%s' must be absolute",
+					guest, i+1, impl.Name)
+			}
+		}
+	}
+}
+
+// DefaultGuestMount is the guest path the docker-based targets mount the
+// working directory to when an implementation declares no volumes.
+const DefaultGuestMount = "/data"
+
+// checkOutputPaths verifies that absolute output paths sit under a guest
+// mount point of an implementation, so that the results reach the host.
+// Relative paths are resolved by the target and are not checked.
+func checkOutputPaths(r Reporter, program *ast.Program) {
+	if len(program.Implementations) == 0 {
+		return
+	}
+	mounts := guestMounts(program)
+
+	for _, output := range program.Outputs {
+		if !path.IsAbs(output.Path) {
+			continue
+		}
+		if !slices.ContainsFunc(mounts, func(mount string) bool { return underPath(output.Path, mount) }) {
+			r.Errorf(output.Pos, "output '%s' is written to '%s', which is not under any mounted volume (%s), the host will not see it",
+				output.Name, output.Path, strings.Join(mounts, ", "))
+		}
+	}
+}
+
+// guestMounts returns the absolute guest paths mounted by the
+// implementations, in declaration order.
+func guestMounts(program *ast.Program) []string {
+	mounts := []string{}
+	for _, impl := range program.Implementations {
+		volumes, ok := impl.Fields["volumes"].([]any)
+		if !ok || len(volumes) == 0 {
+			mounts = append(mounts, DefaultGuestMount)
+			continue
+		}
+		for _, vol := range volumes {
+			if entry, _ := vol.([]any); len(entry) >= 2 {
+				if guest := fmt.Sprintf("%v", entry[1]); path.IsAbs(guest) {
+					mounts = append(mounts, path.Clean(guest))
+				}
+			}
+		}
+	}
+	return slices.Compact(mounts)
+}
+
+// underPath reports whether p is dir or inside it.
+func underPath(p, dir string) bool {
+	p = path.Clean(p)
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/importer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/protobuf"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// The gRPC status codes of the responses.
+const (
+	codeOK                = 0
+	codeInvalidArgument   = 3
+	codeResourceExhausted = 8
+	codeUnimplemented     = 12
+	codeInternal          = 13
+)
+
+// rpcError is a failed call, answered with its gRPC status.
+type rpcError struct {
+	code    int
+	message string
+}
+
+func (e *rpcError) Error() string {
+	return e.message
+}
+
+func invalidArgument(format string, args ...any) error {
+	return &rpcError{code: codeInvalidArgument, message: fmt.Sprintf(format, args...)}
+}
+
+// GRPCHandler returns the handler of the baryon.v1.Baryon service of
+// api/baryon/v1/baryon.proto. gRPC runs over HTTP/2, so the handler is
+// served without TLS by a server accepting unencrypted HTTP/2, as
+// "baryon serve -grpc" does. Compressed messages aren't supported.
+func (s *Server) GRPCHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /baryon.v1.Baryon/Parse", s.unary(s.parseRPC))
+	mux.HandleFunc("POST /baryon.v1.Baryon/Transpile", s.unary(s.transpileRPC))
+	mux.HandleFunc("POST /baryon.v1.Baryon/Import", s.unary(s.importRPC))
+	return mux
+}
+
+// unary serves a unary method, which decodes its request message and
+// returns its response message.
+func (s *Server) unary(method func(context.Context, []byte) ([]byte, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/grpc" && !strings.HasPrefix(contentType, "application/grpc+proto") {
+			http.Error(w, "expected a gRPC request", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+
+		response, err := s.readMessage(r)
+		if err == nil {
+			response, err = method(r.Context(), response)
+		}
+		if err == nil {
+			frame := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(response)))
+			_, err = w.Write(append(frame, response...))
+		}
+		code, message := codeOK, ""
+		if err != nil {
+			var rpcErr *rpcError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &rpcError{code: codeInternal, message: err.Error()}
+			}
+			code, message = rpcErr.code, rpcErr.message
+		}
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		w.Header().Set("Grpc-Message", url.PathEscape(message))
+	}
+}
+
+// readMessage reads the length-prefixed message of a request.
+func (s *Server) readMessage(r *http.Request) ([]byte, error) {
+	limit := s.MaxBytes
+	if limit == 0 {
+		limit = DefaultMaxBytes
+	}
+	var prefix [5]byte
+	if _, err := io.ReadFull(r.Body, prefix[:]); err != nil {
+		return nil, invalidArgument("reading the request message: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, &rpcError{code: codeUnimplemented, message: "compressed messages aren't supported"}
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if int64(length) > limit {
+		return nil, &rpcError{code: codeResourceExhausted, message: fmt.Sprintf("message larger than %d bytes", limit)}
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r.Body, message); err != nil {
+		return nil, invalidArgument("reading the request message: %v", err)
+	}
+	return message, nil
+}
+
+// parseRPC implements Parse.
+func (s *Server) parseRPC(ctx context.Context, request []byte) ([]byte, error) {
+	var source string
+	err := protobuf.Decode(request, func(f protobuf.Field) error {
+		if f.Number == 1 {
+			source = f.String()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, invalidArgument("invalid ParseRequest: %v", err)
+	}
+
+	var response protobuf.Encoder
+	program, err := parseProgram(source)
+	if err != nil {
+		encodeDiagnostics(&response, syntaxDiagnostics(err))
+		return response.Bytes(), nil
+	}
+	analysis, err := s.analyze(program)
+	if err != nil {
+		return nil, err
+	}
+	if !semantic.HasErrors(analysis) {
+		response.Message(1, func(e *protobuf.Encoder) { protobuf.EncodeProgram(e, program) })
+	}
+	encodeDiagnostics(&response, semanticDiagnostics(analysis))
+	return response.Bytes(), nil
+}
+
+// transpileRPC implements Transpile. The programs that don't compile get
+// a response with their diagnostics and no code.
+func (s *Server) transpileRPC(ctx context.Context, request []byte) ([]byte, error) {
+	var (
+		source  string
+		program *ast.Program
+		lang    string
+		options = map[string]string{}
+	)
+	err := protobuf.Decode(request, func(f protobuf.Field) (err error) {
+		switch f.Number {
+		case 1:
+			source, program = f.String(), nil
+		case 2:
+			program, err = protobuf.UnmarshalProgram(f.Data)
+		case 3:
+			lang = strings.ToLower(f.String())
+		case 4:
+			key, value, err := protobuf.DecodeStringEntry(f.Data)
+			if err != nil {
+				return err
+			}
+			options[key] = value
+		}
+		return err
+	})
+	if err != nil {
+		return nil, invalidArgument("invalid TranspileRequest: %v", err)
+	}
+	descriptor, err := transpiler.GetTranspiler(lang)
+	if err != nil {
+		return nil, invalidArgument("unsupported target '%s', expected one of %s",
+			lang, strings.Join(transpiler.GetTranspilerNames(), ", "))
+	}
+	t := descriptor.Initializer()
+	merged := maps.Clone(s.Options[lang])
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, options)
+	if err := transpiler.ApplyOptions(t, lang, merged); err != nil {
+		return nil, invalidArgument("%v", err)
+	}
+
+	var response protobuf.Encoder
+	if program == nil {
+		if program, err = parseProgram(source); err != nil {
+			encodeDiagnostics(&response, syntaxDiagnostics(err))
+			return response.Bytes(), nil
+		}
+	}
+	analysis, err := s.analyze(program)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := semanticDiagnostics(analysis)
+	if semantic.HasErrors(analysis) {
+		encodeDiagnostics(&response, diagnostics)
+		return response.Bytes(), nil
+	}
+	warnings, err := transpiler.NegotiateFeatures(lang, program)
+	if err != nil {
+		encodeDiagnostics(&response, append(diagnostics, transpileDiagnostic(err)))
+		return response.Bytes(), nil
+	}
+	for _, warning := range warnings {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: semantic.SeverityWarning.String(), Rule: warning.Feature, Message: warning.Note,
+			Line: warning.Pos.Line, Column: warning.Pos.Column,
+		})
+	}
+	var code strings.Builder
+	if err := transpiler.TranspileContext(ctx, t, &code, program); err != nil {
+		encodeDiagnostics(&response, append(diagnostics, transpileDiagnostic(err)))
+		return response.Bytes(), nil
+	}
+	response.String(1, code.String())
+	encodeDiagnostics(&response, diagnostics)
+	return response.Bytes(), nil
+}
+
+// importRPC implements Import, for Galaxy tool definitions.
+func (s *Server) importRPC(ctx context.Context, request []byte) ([]byte, error) {
+	var (
+		format     string
+		definition []byte
+	)
+	err := protobuf.Decode(request, func(f protobuf.Field) error {
+		switch f.Number {
+		case 1:
+			format = strings.ToLower(f.String())
+		case 2:
+			definition = f.Data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, invalidArgument("invalid ImportRequest: %v", err)
+	}
+	if format != "galaxy" {
+		return nil, invalidArgument("unsupported format '%s', expected galaxy", format)
+	}
+
+	var response protobuf.Encoder
+	galaxyImporter := &importer.GalaxyImporter{}
+	source, err := "", galaxyImporter.Import(definition)
+	if err == nil {
+		source, err = galaxyImporter.Export()
+	}
+	if err != nil {
+		encodeDiagnostics(&response, []Diagnostic{{
+			Severity: semantic.SeverityError.String(), Rule: "import", Message: err.Error(),
+		}})
+		return response.Bytes(), nil
+	}
+	response.String(1, source)
+	return response.Bytes(), nil
+}
+
+// transpileDiagnostic returns the diagnostic of a program a target can't
+// transpile, its rule the unsupported feature when known.
+func transpileDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Severity: semantic.SeverityError.String(), Rule: "transpile", Message: err.Error()}
+	var unsupported *transpiler.UnsupportedFeatureError
+	if errors.As(err, &unsupported) {
+		d.Rule, d.Message = unsupported.Feature, unsupported.Message
+		d.Line, d.Column = unsupported.Pos.Line, unsupported.Pos.Column
+	}
+	return d
+}
+
+// encodeDiagnostics writes diagnostics as the Diagnostic messages of field
+// 2, the field of the diagnostics in every response.
+func encodeDiagnostics(e *protobuf.Encoder, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		e.Message(2, func(m *protobuf.Encoder) {
+			severity := 1
+			if d.Severity == semantic.SeverityWarning.String() {
+				severity = 2
+			}
+			m.Int(1, severity)
+			m.String(2, d.Rule)
+			m.String(3, d.Message)
+			if d.Line != 0 {
+				m.Message(4, func(pos *protobuf.Encoder) {
+					protobuf.EncodePosition(pos, ast.Position{Line: d.Line, Column: d.Column})
+				})
+			}
+			m.String(5, d.Code)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/protobuf"
+)
+
+const galaxyTool = `<tool id="bwa_mem" name="bwa_mem" version="0.7.17">
+  <description>Align reads</description>
+  <requirements>
+    <container type="docker">biocontainers/bwa:0.7.17</container>
+  </requirements>
+  <command>bwa mem $reads</command>
+  <inputs>
+    <param name="reads" type="data" help="Input reads"/>
+  </inputs>
+  <outputs>
+    <data name="bam" format="bam" label="Alignments"/>
+  </outputs>
+</tool>`
+
+// call sends a unary request to the gRPC service over unencrypted HTTP/2,
+// returning the response message and the status of the trailers.
+func call(t *testing.T, s *Server, method string, request []byte) ([]byte, string, string) {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(s.GRPCHandler())
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	defer transport.CloseIdleConnections()
+
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(request)))
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/baryon.v1.Baryon/"+method, bytes.NewReader(append(body, request...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s: reading the response: %v", method, err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("%s: response over %s, want HTTP/2", method, resp.Proto)
+	}
+	var message []byte
+	if len(data) > 0 {
+		if len(data) < 5 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+			t.Fatalf("%s: malformed response frame % x", method, data)
+		}
+		message = data[5:]
+	}
+	statusMessage, _ := url.PathUnescape(resp.Trailer.Get("Grpc-Message"))
+	return message, resp.Trailer.Get("Grpc-Status"), statusMessage
+}
+
+// rpcResponse holds the fields of the responses of the methods.
+type rpcResponse struct {
+	Program     *ast.Program
+	Text        string // the code of Transpile, the source of Import
+	Diagnostics []Diagnostic
+}
+
+// decodeResponse decodes a response, its first field a program for Parse.
+func decodeResponse(t *testing.T, data []byte, parse bool) rpcResponse {
+	t.Helper()
+	var response rpcResponse
+	err := protobuf.Decode(data, func(f protobuf.Field) (err error) {
+		switch {
+		case f.Number == 1 && parse:
+			response.Program, err = protobuf.UnmarshalProgram(f.Data)
+		case f.Number == 1:
+			response.Text = f.String()
+		case f.Number == 2:
+			d := Diagnostic{}
+			err = protobuf.Decode(f.Data, func(f protobuf.Field) error {
+				switch f.Number {
+				case 1:
+					d.Severity = map[int]string{1: "error", 2: "warning"}[f.Int()]
+				case 2:
+					d.Rule = f.String()
+				case 3:
+					d.Message = f.String()
+				case 4:
+					pos, err := protobuf.DecodePosition(f.Data)
+					d.Line, d.Column = pos.Line, pos.Column
+					return err
+				case 5:
+					d.Code = f.String()
+				}
+				return nil
+			})
+			response.Diagnostics = append(response.Diagnostics, d)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("invalid response message: %v", err)
+	}
+	return response
+}
+
+func TestGRPCParse(t *testing.T) {
+	var request protobuf.Encoder
+	request.String(1, alignSource)
+	data, status, message := call(t, &Server{}, "Parse", request.Bytes())
+	if status != "0" {
+		t.Fatalf("grpc-status = %s (%s), want 0", status, message)
+	}
+	response := decodeResponse(t, data, true)
+	if response.Program == nil || response.Program.Name != "align" || len(response.Program.Parameters) != 1 {
+		t.Errorf("unexpected program: %+v", response.Program)
+	}
+
+	request = protobuf.Encoder{}
+	request.String(1, "(bala align (")
+	response = decodeResponse(t, first(call(t, &Server{}, "Parse", request.Bytes())), true)
+	if response.Program != nil || len(response.Diagnostics) == 0 || response.Diagnostics[0].Code != "unclosed-list" {
+		t.Errorf("expected syntax diagnostics with their code and no program, got %+v", response)
+	}
+}
+
+func TestGRPCTranspile(t *testing.T) {
+	s := &Server{Options: map[string]map[string]string{"nextflow": {"engine": "singularity"}}}
+	var request protobuf.Encoder
+	request.String(1, alignSource)
+	request.String(3, "nextflow")
+	response := decodeResponse(t, first(call(t, s, "Transpile", request.Bytes())), false)
+	if !strings.Contains(response.Text, "container 'docker://docker.io/biocontainers/bwa:0.7.17'") {
+		t.Errorf("expected the options of the server to apply:\n%s", response.Text)
+	}
+
+	// A program returned by Parse, with the options of the request
+	program, err := parseProgram(alignSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = protobuf.Encoder{}
+	request.Message(2, func(e *protobuf.Encoder) { protobuf.EncodeProgram(e, program) })
+	request.String(3, "nextflow")
+	request.StringMap(4, map[string]string{"engine": "podman"}, []string{"engine"})
+	response = decodeResponse(t, first(call(t, s, "Transpile", request.Bytes())), false)
+	if !strings.Contains(response.Text, "container 'docker.io/biocontainers/bwa:0.7.17'") {
+		t.Errorf("expected the options of the request to win:\n%s", response.Text)
+	}
+
+	request = protobuf.Encoder{}
+	request.String(1, `(bala align ((run_docker (image "ubuntu:22.04") (arguments missing))))`)
+	request.String(3, "python")
+	response = decodeResponse(t, first(call(t, &Server{}, "Transpile", request.Bytes())), false)
+	if response.Text != "" || len(response.Diagnostics) == 0 || response.Diagnostics[0].Severity != "error" {
+		t.Errorf("expected error diagnostics and no code, got %+v", response)
+	}
+
+	request = protobuf.Encoder{}
+	request.String(1, alignSource)
+	request.String(3, "cobol")
+	if _, status, message := call(t, &Server{}, "Transpile", request.Bytes()); status != "3" || !strings.Contains(message, "unsupported target 'cobol'") {
+		t.Errorf("grpc-status = %s (%s), want 3", status, message)
+	}
+}
+
+func TestGRPCImport(t *testing.T) {
+	var request protobuf.Encoder
+	request.String(1, "galaxy")
+	request.String(2, galaxyTool)
+	response := decodeResponse(t, first(call(t, &Server{}, "Import", request.Bytes())), false)
+	if !strings.HasPrefix(response.Text, "(bala bwa_mem (") || !strings.Contains(response.Text, `(image "biocontainers/bwa:0.7.17")`) {
+		t.Errorf("unexpected source:\n%s", response.Text)
+	}
+
+	request = protobuf.Encoder{}
+	request.String(1, "cwl")
+	if _, status, _ := call(t, &Server{}, "Import", request.Bytes()); status != "3" {
+		t.Errorf("grpc-status = %s, want 3", status)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	var request protobuf.Encoder
+	request.String(1, alignSource)
+	if _, status, _ := call(t, &Server{MaxBytes: 16}, "Parse", request.Bytes()); status != "8" {
+		t.Errorf("grpc-status = %s, want 8 for a message over MaxBytes", status)
+	}
+	if _, status, _ := call(t, &Server{}, "Parse", []byte{0x0a, 0x20}); status != "3" {
+		t.Errorf("grpc-status = %s, want 3 for a truncated message", status)
+	}
+
+	recorder := httptest.NewRecorder()
+	(&Server{}).GRPCHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/baryon.v1.Baryon/Parse", strings.NewReader(alignSource)))
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status = %d, want 415 without the gRPC content type", recorder.Code)
+	}
+}
+
+func first(message []byte, _, _ string) []byte {
+	return message
+}
@@ -0,0 +1,270 @@
+// Package server serves the parser, the semantic checks and the
+// transpilers over HTTP, for web front-ends and CI services that post the
+// source of a program instead of running baryon-lang themselves.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// DefaultMaxBytes bounds the size of a posted program.
+const DefaultMaxBytes = 1 << 20
+
+// Server handles the requests on programs. Its endpoints take the source
+// of a program as the request body:
+//
+//	POST /parse                 the syntax tree, as written by "baryon ast"
+//	POST /lint                  the diagnostics of the semantic checks
+//	POST /transpile?lang=python the generated code, with -option style
+//	                            option=name=value parameters
+//
+// /lint answers with the diagnostics of every program, including those
+// with syntax errors. /parse answers programs with syntax errors, and
+// /transpile those that don't pass the checks or can't be transpiled, with
+// a 422 response listing the diagnostics. Invalid targets and options get
+// a 400 response, programs larger than MaxBytes a 413 one.
+type Server struct {
+	// Rules enables or disables semantic checks by rule name, as the lint
+	// section of baryon.toml.
+	Rules map[string]bool
+	// Options are the target options by target, replaced by the ones of
+	// the request.
+	Options map[string]map[string]string
+	// MaxBytes bounds the size of a program, DefaultMaxBytes when 0.
+	MaxBytes int64
+}
+
+// Diagnostic is a finding on a program, in the responses.
+type Diagnostic struct {
+	Severity string `json:"severity"`       // "error" or "warning"
+	Rule     string `json:"rule"`           // the semantic rule, "syntax" or the feature of the target
+	Code     string `json:"code,omitempty"` // the parser.Code of syntax errors
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// LintResponse is the response of /lint.
+type LintResponse struct {
+	Valid       bool         `json:"valid"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TranspileResponse is the response of /transpile.
+type TranspileResponse struct {
+	Lang        string       `json:"lang"`
+	Code        string       `json:"code"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// errorResponse is the response of failed requests, with the diagnostics
+// of programs that don't compile.
+type errorResponse struct {
+	Error       string       `json:"error"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Handler returns the handler of the endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /parse", s.handleParse)
+	mux.HandleFunc("POST /lint", s.handleLint)
+	mux.HandleFunc("POST /transpile", s.handleTranspile)
+	return mux
+}
+
+func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	program, ok := s.readProgram(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, program)
+}
+
+func (s *Server) handleLint(w http.ResponseWriter, r *http.Request) {
+	source, ok := s.readSource(w, r)
+	if !ok {
+		return
+	}
+	program, err := parseProgram(source)
+	if err != nil {
+		writeJSON(w, http.StatusOK, LintResponse{Diagnostics: syntaxDiagnostics(err)})
+		return
+	}
+	diagnostics, err := s.analyze(program)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, LintResponse{
+		Valid:       !semantic.HasErrors(diagnostics),
+		Diagnostics: semanticDiagnostics(diagnostics),
+	})
+}
+
+func (s *Server) handleTranspile(w http.ResponseWriter, r *http.Request) {
+	lang := strings.ToLower(r.URL.Query().Get("lang"))
+	descriptor, err := transpiler.GetTranspiler(lang)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unsupported target '%s', expected one of %s",
+			lang, strings.Join(transpiler.GetTranspilerNames(), ", "))})
+		return
+	}
+	options := maps.Clone(s.Options[lang])
+	if options == nil {
+		options = map[string]string{}
+	}
+	for _, option := range r.URL.Query()["option"] {
+		name, value, ok := strings.Cut(option, "=")
+		if !ok || name == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("expected option=name=value, got %q", option)})
+			return
+		}
+		options[name] = value
+	}
+
+	source, ok := s.readSource(w, r)
+	if !ok {
+		return
+	}
+	program, err := parseProgram(source)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "syntax error", Diagnostics: syntaxDiagnostics(err)})
+		return
+	}
+	analysis, err := s.analyze(program)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	diagnostics := semanticDiagnostics(analysis)
+	if semantic.HasErrors(analysis) {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "semantic error", Diagnostics: diagnostics})
+		return
+	}
+
+	warnings, err := transpiler.NegotiateFeatures(lang, program)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: err.Error(), Diagnostics: diagnostics})
+		return
+	}
+	for _, warning := range warnings {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: semantic.SeverityWarning.String(), Rule: warning.Feature, Message: warning.Note,
+			Line: warning.Pos.Line, Column: warning.Pos.Column,
+		})
+	}
+	t := descriptor.Initializer()
+	if err := transpiler.ApplyOptions(t, lang, options); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	var code strings.Builder
+	if err := transpiler.TranspileContext(r.Context(), t, &code, program); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "transpilation failed: " + err.Error(), Diagnostics: diagnostics})
+		return
+	}
+	writeJSON(w, http.StatusOK, TranspileResponse{Lang: lang, Code: code.String(), Diagnostics: diagnostics})
+}
+
+// readSource reads the program of a request, answering it when the body
+// can't be read or is too large.
+func (s *Server) readSource(w http.ResponseWriter, r *http.Request) (string, bool) {
+	limit := s.MaxBytes
+	if limit == 0 {
+		limit = DefaultMaxBytes
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{Error: fmt.Sprintf("program larger than %d bytes", limit)})
+		return "", false
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "reading program: " + err.Error()})
+		return "", false
+	}
+	return string(data), true
+}
+
+// readProgram reads and parses the program of a request, answering it with
+// the syntax errors.
+func (s *Server) readProgram(w http.ResponseWriter, r *http.Request) (*ast.Program, bool) {
+	source, ok := s.readSource(w, r)
+	if !ok {
+		return nil, false
+	}
+	program, err := parseProgram(source)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "syntax error", Diagnostics: syntaxDiagnostics(err)})
+		return nil, false
+	}
+	return program, true
+}
+
+// analyze runs the semantic checks enabled by the rules.
+func (s *Server) analyze(program *ast.Program) ([]semantic.Diagnostic, error) {
+	analyzer := semantic.New()
+	for _, rule := range slices.Sorted(maps.Keys(s.Rules)) {
+		if err := analyzer.SetEnabled(rule, s.Rules[rule]); err != nil {
+			return nil, err
+		}
+	}
+	return analyzer.Analyze(program), nil
+}
+
+func parseProgram(source string) (*ast.Program, error) {
+	return parser.New(lexer.New(source)).ParseProgram()
+}
+
+// syntaxDiagnostics returns the diagnostics of the syntax errors joined in
+// the error of the parser.
+func syntaxDiagnostics(err error) []Diagnostic {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	diagnostics := []Diagnostic{}
+	for _, err := range errs {
+		d := Diagnostic{Severity: semantic.SeverityError.String(), Rule: "syntax", Message: err.Error()}
+		var parseErr *parser.ParseError
+		if errors.As(err, &parseErr) {
+			d.Code, d.Message = string(parseErr.Code), parseErr.Message
+			d.Line, d.Column = parseErr.Pos.Line, parseErr.Pos.Column
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+func semanticDiagnostics(diagnostics []semantic.Diagnostic) []Diagnostic {
+	converted := []Diagnostic{}
+	for _, d := range diagnostics {
+		converted = append(converted, Diagnostic{
+			Severity: d.Severity.String(), Rule: d.Rule, Message: d.Message,
+			Line: d.Pos.Line, Column: d.Pos.Column,
+		})
+	}
+	return converted
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const alignSource = `(bala align (
+	(reads file (desc "Input reads"))
+	(run_docker (image "biocontainers/bwa:0.7.17") (arguments "bwa" reads))
+))`
+
+// post sends a program to an endpoint, decoding the JSON response into v.
+func post(t *testing.T, s *Server, target, body string, v any) int {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	if v != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: invalid JSON response: %v\n%s", target, err, recorder.Body)
+		}
+	}
+	return recorder.Code
+}
+
+func TestParse(t *testing.T) {
+	var program struct {
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name string `json:"name"`
+		} `json:"parameters"`
+	}
+	if status := post(t, &Server{}, "/parse", alignSource, &program); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if program.Name != "align" || len(program.Parameters) != 1 || program.Parameters[0].Name != "reads" {
+		t.Errorf("unexpected syntax tree: %+v", program)
+	}
+
+	var response errorResponse
+	if status := post(t, &Server{}, "/parse", "(bala align (", &response); status != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", status)
+	}
+	if len(response.Diagnostics) == 0 || response.Diagnostics[0].Rule != "syntax" || response.Diagnostics[0].Line != 1 ||
+		response.Diagnostics[0].Code != "unclosed-list" {
+		t.Errorf("expected a syntax diagnostic with its position, got %+v", response)
+	}
+}
+
+func TestLint(t *testing.T) {
+	source := `(bala align (
+	(reads file)
+	(extra string)
+	(run_docker (image "ubuntu:22.04") (arguments "cat" reads unknown))
+))`
+	var response LintResponse
+	if status := post(t, &Server{}, "/lint", source, &response); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if response.Valid {
+		t.Errorf("expected an invalid program")
+	}
+	rules := map[string]string{}
+	for _, d := range response.Diagnostics {
+		rules[d.Rule] = d.Severity
+	}
+	if rules["unresolved-reference"] != "error" || rules["unused-parameter"] != "warning" {
+		t.Errorf("unexpected diagnostics: %+v", response.Diagnostics)
+	}
+
+	// The rules of the configuration apply
+	s := &Server{Rules: map[string]bool{"unused-parameter": false}}
+	post(t, s, "/lint", source, &response)
+	for _, d := range response.Diagnostics {
+		if d.Rule == "unused-parameter" {
+			t.Errorf("expected the disabled rule to be skipped, got %+v", d)
+		}
+	}
+}
+
+func TestTranspile(t *testing.T) {
+	var response TranspileResponse
+	s := &Server{Options: map[string]map[string]string{"nextflow": {"engine": "singularity"}}}
+	if status := post(t, s, "/transpile?lang=nextflow", alignSource, &response); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if !strings.Contains(response.Code, "container 'docker://docker.io/biocontainers/bwa:0.7.17'") {
+		t.Errorf("expected the options of the server to apply:\n%s", response.Code)
+	}
+	post(t, s, "/transpile?lang=nextflow&option=engine=podman", alignSource, &response)
+	if !strings.Contains(response.Code, "container 'docker.io/biocontainers/bwa:0.7.17'") {
+		t.Errorf("expected the options of the request to win:\n%s", response.Code)
+	}
+
+	for _, tt := range []struct {
+		target, body string
+		status       int
+	}{
+		{"/transpile?lang=cobol", alignSource, http.StatusBadRequest},
+		{"/transpile?lang=python&option=models", alignSource, http.StatusBadRequest},
+		{"/transpile?lang=python&option=unknown=1", alignSource, http.StatusBadRequest},
+		{"/transpile?lang=python", "(bala align (", http.StatusUnprocessableEntity},
+		{"/transpile?lang=python", `(bala align ((run_docker (image "ubuntu:22.04") (arguments missing))))`, http.StatusUnprocessableEntity},
+	} {
+		var response errorResponse
+		if status := post(t, &Server{}, tt.target, tt.body, &response); status != tt.status || response.Error == "" {
+			t.Errorf("%s: status = %d, error %q, want %d with an error", tt.target, status, response.Error, tt.status)
+		}
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	semanticError := `(bala align ((run_docker (image "ubuntu:22.04") (arguments missing))))`
+	for _, tt := range []struct {
+		target, body string
+		status       int
+	}{
+		{"/parse", alignSource, http.StatusOK},
+		{"/parse", "(bala align (", http.StatusUnprocessableEntity},
+		{"/parse", semanticError, http.StatusOK},
+		{"/lint", alignSource, http.StatusOK},
+		{"/lint", "(bala align (", http.StatusOK},
+		{"/lint", semanticError, http.StatusOK},
+		{"/transpile?lang=python", alignSource, http.StatusOK},
+		{"/transpile?lang=python", "(bala align (", http.StatusUnprocessableEntity},
+		{"/transpile?lang=python", semanticError, http.StatusUnprocessableEntity},
+		{"/transpile?lang=cobol", alignSource, http.StatusBadRequest},
+	} {
+		if status := post(t, &Server{}, tt.target, tt.body, nil); status != tt.status {
+			t.Errorf("%s %q: status = %d, want %d", tt.target, tt.body, status, tt.status)
+		}
+	}
+
+	var response LintResponse
+	post(t, &Server{}, "/lint", "(bala align (", &response)
+	if response.Valid || len(response.Diagnostics) == 0 || response.Diagnostics[0].Rule != "syntax" {
+		t.Errorf("expected an invalid program with syntax diagnostics, got %+v", response)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	var response errorResponse
+	if status := post(t, &Server{MaxBytes: 16}, "/lint", alignSource, &response); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", status)
+	}
+	recorder := httptest.NewRecorder()
+	(&Server{}).Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/lint", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", recorder.Code)
+	}
+}
@@ -0,0 +1,472 @@
+// Package testrun runs the tests block of a program, for "baryon test
+// -execute": each test runs the container of the run_docker block with the
+// values of its parameters, the inputs of the test-data directory staged
+// in a work directory, and its assertions are checked on the outputs the
+// container wrote there.
+//
+// The container is run as the targets run it: every volume of a parameter,
+// and /data when the block declares none, mounts the work directory, and
+// file and directory parameters are passed as their path in the mount.
+package testrun
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// DataDir is the directory of the inputs and expected files of the tests,
+// next to the program.
+const DataDir = "test-data"
+
+// Runner runs the tests of a program.
+type Runner struct {
+	// Docker is the Docker-compatible CLI running the containers, "docker"
+	// when empty.
+	Docker string
+	// Data is the directory test inputs and expected files are read from.
+	Data string
+	// WorkDir is where the work directories of the tests are created, the
+	// temporary directory when empty.
+	WorkDir string
+	// Keep keeps the work directories of the tests, removed otherwise.
+	Keep bool
+	// Exec runs a command and returns its combined output; it runs the
+	// command as a process when nil.
+	Exec func(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// Plan is how a test runs: the arguments of "docker run" and where the
+// outputs are found on the host, by output name.
+type Plan struct {
+	Args    []string
+	Outputs map[string]string
+}
+
+// Result is the outcome of a test.
+type Result struct {
+	Test     string
+	WorkDir  string // empty when it was removed
+	Duration time.Duration
+	// Failures are the assertions that don't hold and the container
+	// errors, empty when the test passed.
+	Failures []string
+}
+
+// Passed reports whether the test passed.
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Run runs a test of a program. The error is for tests that can't be run,
+// e.g. a missing input; failing tests are reported in the result.
+func (r *Runner) Run(ctx context.Context, program *ast.Program, test ast.TestBlock) (Result, error) {
+	result := Result{Test: test.Name}
+	workDir, err := os.MkdirTemp(r.WorkDir, "baryon-test-"+unsafeNameChars.ReplaceAllString(test.Name, "_")+"-")
+	if err != nil {
+		return result, err
+	}
+	if r.Keep {
+		result.WorkDir = workDir
+	} else {
+		defer os.RemoveAll(workDir)
+	}
+
+	if err := r.stage(program, test, workDir); err != nil {
+		return result, err
+	}
+	plan, err := NewPlan(program, test, workDir)
+	if err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	output, err := r.exec(ctx, r.docker(), plan.Args...)
+	result.Duration = time.Since(start)
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.Failures = append(result.Failures, fmt.Sprintf("the container exited with status %d:\n%s",
+			exitErr.ExitCode(), tail(string(output), 20)))
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	for _, expect := range test.Expect {
+		result.Failures = append(result.Failures, r.check(expect, plan.Outputs[expect.Output])...)
+	}
+	return result, nil
+}
+
+// NewPlan returns how a test of a program runs with its inputs staged in
+// workDir.
+func NewPlan(program *ast.Program, test ast.TestBlock, workDir string) (Plan, error) {
+	impl, err := dockerBlock(program)
+	if err != nil {
+		return Plan{}, err
+	}
+	image, _ := impl.Fields["image"].(string)
+	values := testValues(program, test)
+	params := map[string]ast.Parameter{}
+	for _, param := range program.Parameters {
+		params[param.Name] = param
+	}
+
+	// The mounts, from host to guest path; parameters mount the work
+	// directory, where their inputs are staged
+	mounts := [][2]string{}
+	mount := func(host, guest string) {
+		if !slices.Contains(mounts, [2]string{host, guest}) {
+			mounts = append(mounts, [2]string{host, guest})
+		}
+	}
+	// The guest path of the inputs of each parameter, the one of the first
+	// parameter volume for the others
+	inputMount := semantic.DefaultGuestMount
+	paramMounts := map[string]string{}
+	volumes, _ := impl.Fields["volumes"].([]any)
+	for _, volume := range volumes {
+		entry, _ := volume.([]any)
+		if len(entry) < 2 {
+			continue
+		}
+		host, guest := fmt.Sprint(entry[0]), path.Clean(fmt.Sprint(entry[1]))
+		switch {
+		case transpiler.IsParamReference(host, program.Parameters):
+			if isInput(params[host]) {
+				if len(paramMounts) == 0 {
+					inputMount = guest
+				}
+				paramMounts[host] = guest
+				mount(workDir, guest)
+			}
+		case host == "parent_folder" || host == "parent-folder":
+			mount(workDir, guest)
+		default:
+			mount(host, guest)
+		}
+	}
+	if len(volumes) == 0 {
+		mount(workDir, semantic.DefaultGuestMount)
+	} else {
+		// The targets mount their results directory there
+		results := path.Join(semantic.DefaultGuestMount, program.Name+"_results")
+		mount(filepath.Join(workDir, program.Name+"_results"), results)
+	}
+
+	args := []string{"run", "--rm"}
+	for _, m := range mounts {
+		args = append(args, "-v", m[0]+":"+m[1])
+	}
+	env, _ := impl.Fields["env"].([]any)
+	for _, variable := range env {
+		entry, _ := variable.([]any)
+		if len(entry) < 2 {
+			continue
+		}
+		value := fmt.Sprint(entry[1])
+		if transpiler.IsParamReference(value, program.Parameters) {
+			if values[value] == nil {
+				continue
+			}
+			value = fmt.Sprint(values[value])
+		}
+		args = append(args, "-e", fmt.Sprintf("%v=%s", entry[0], value))
+	}
+	args = append(args, image)
+
+	arguments, _ := impl.Fields["arguments"].([]any)
+	for _, argument := range arguments {
+		name := fmt.Sprint(argument)
+		switch {
+		case name == "_":
+		case !transpiler.IsParamReference(name, program.Parameters):
+			args = append(args, name)
+		case values[name] == nil:
+			// Optional parameters without a value pass nothing
+		case params[name].Type == ast.TypeBoolean:
+			if values[name] == true {
+				args = append(args, transpiler.BooleanFlag(name, program.Parameters))
+			}
+		case isInput(params[name]):
+			guest, ok := paramMounts[name]
+			if !ok {
+				guest = inputMount
+			}
+			args = append(args, path.Join(guest, path.Base(filepath.ToSlash(fmt.Sprint(values[name])))))
+		default:
+			args = append(args, fmt.Sprint(values[name]))
+		}
+	}
+
+	// The outputs are found through the mount of their guest path, the
+	// relative ones in the work directory
+	outputs := map[string]string{}
+	for _, output := range program.Outputs {
+		outputs[output.Name] = filepath.Join(workDir, filepath.FromSlash(output.Path))
+		if !path.IsAbs(output.Path) {
+			continue
+		}
+		best := ""
+		for _, m := range mounts {
+			if rel, ok := relativeTo(output.Path, m[1]); ok && len(m[1]) > len(best) {
+				best = m[1]
+				outputs[output.Name] = filepath.Join(m[0], filepath.FromSlash(rel))
+			}
+		}
+	}
+	return Plan{Args: args, Outputs: outputs}, nil
+}
+
+// stage copies the inputs of a test from the data directory to the work
+// directory.
+func (r *Runner) stage(program *ast.Program, test ast.TestBlock, workDir string) error {
+	values := testValues(program, test)
+	for _, param := range program.Parameters {
+		value, ok := values[param.Name].(string)
+		if !ok || !isInput(param) {
+			continue
+		}
+		source, err := r.dataPath(value)
+		if err == nil {
+			err = copyPath(source, filepath.Join(workDir, filepath.Base(source)))
+		}
+		if err != nil {
+			return fmt.Errorf("staging input '%s' of test '%s': %w", param.Name, test.Name, err)
+		}
+	}
+	if impl, err := dockerBlock(program); err == nil {
+		if volumes, _ := impl.Fields["volumes"].([]any); len(volumes) > 0 {
+			if err := os.MkdirAll(filepath.Join(workDir, program.Name+"_results"), 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unsafeNameChars matches the characters of test names left out of the
+// names of their work directories, e.g. path separators.
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// dataPath returns the path of a file of the data directory, refusing the
+// paths that are absolute or lead out of it.
+func (r *Runner) dataPath(rel string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return "", fmt.Errorf("path '%s' is not within the data directory", rel)
+	}
+	return filepath.Join(r.Data, filepath.FromSlash(rel)), nil
+}
+
+// check returns the assertions on an output that don't hold. The outputs
+// that are directories are only checked to exist.
+func (r *Runner) check(expect ast.OutputExpectation, hostPath string) []string {
+	info, err := os.Stat(hostPath)
+	if err != nil {
+		return []string{fmt.Sprintf("output '%s' wasn't written: %v", expect.Output, err)}
+	}
+	if info.IsDir() {
+		return nil
+	}
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		return []string{fmt.Sprintf("output '%s': %v", expect.Output, err)}
+	}
+
+	failures := []string{}
+	for _, assertion := range expect.Assertions {
+		if problem := r.assert(assertion, data); problem != "" {
+			failures = append(failures, fmt.Sprintf("%s: output '%s': %s", assertion.Pos, expect.Output, problem))
+		}
+	}
+	return failures
+}
+
+// assert describes why an assertion doesn't hold on the content of an
+// output, if it doesn't.
+func (r *Runner) assert(assertion ast.Assertion, data []byte) string {
+	text := fmt.Sprint(assertion.Value)
+	switch assertion.Kind {
+	case ast.AssertHasText:
+		if !bytes.Contains(data, []byte(text)) {
+			return fmt.Sprintf("has no text %q", text)
+		}
+	case ast.AssertHasLine:
+		if !slices.Contains(strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), text) {
+			return fmt.Sprintf("has no line %q", text)
+		}
+	case ast.AssertMatches:
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return err.Error()
+		}
+		if !re.Match(data) {
+			return fmt.Sprintf("doesn't match %q", text)
+		}
+	case ast.AssertLines:
+		lines := bytes.Count(data, []byte("\n"))
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			lines++
+		}
+		if expected, _ := assertion.Value.(int); lines != expected {
+			return fmt.Sprintf("has %d lines, expected %d", lines, expected)
+		}
+	case ast.AssertChecksum:
+		algorithm, digest, _ := strings.Cut(text, ":")
+		h, ok := hashes[algorithm]
+		if !ok {
+			return fmt.Sprintf("unknown checksum algorithm '%s'", algorithm)
+		}
+		sum := h()
+		sum.Write(data)
+		if actual := hex.EncodeToString(sum.Sum(nil)); actual != strings.ToLower(digest) {
+			return fmt.Sprintf("has checksum %s:%s, expected %s", algorithm, actual, text)
+		}
+	case ast.AssertFile:
+		path, err := r.dataPath(text)
+		if err != nil {
+			return err.Error()
+		}
+		expected, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Sprintf("reading the expected file: %v", err)
+		}
+		if !bytes.Equal(data, expected) {
+			return fmt.Sprintf("differs from %s", text)
+		}
+	default:
+		return fmt.Sprintf("unknown assertion '%s'", assertion.Kind)
+	}
+	return ""
+}
+
+// hashes are the algorithms of the checksum assertion, see
+// semantic.ChecksumAlgorithms.
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func (r *Runner) docker() string {
+	if r.Docker == "" {
+		return "docker"
+	}
+	return r.Docker
+}
+
+func (r *Runner) exec(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if r.Exec != nil {
+		return r.Exec(ctx, name, args...)
+	}
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// dockerBlock returns the run_docker block the tests run.
+func dockerBlock(program *ast.Program) (ast.ImplementationBlock, error) {
+	for _, impl := range program.Implementations {
+		if impl.Name == "run_docker" {
+			return impl, nil
+		}
+	}
+	return ast.ImplementationBlock{}, fmt.Errorf("program '%s' has no run_docker block to run its tests with", program.Name)
+}
+
+// testValues returns the values of the parameters in a test, their default
+// when the test doesn't set them.
+func testValues(program *ast.Program, test ast.TestBlock) map[string]any {
+	values := map[string]any{}
+	for _, param := range program.Parameters {
+		values[param.Name] = param.Default
+	}
+	for _, value := range test.Params {
+		values[value.Name] = value.Value
+	}
+	return values
+}
+
+// isInput reports whether the values of a parameter are paths of inputs,
+// staged from the data directory.
+func isInput(param ast.Parameter) bool {
+	return param.Type == ast.TypeFile || param.Type == ast.TypeDirectory || param.Type == ast.TypeSamplesheet
+}
+
+// relativeTo returns the path of p relative to dir, if p is under dir.
+func relativeTo(p, dir string) (string, bool) {
+	p = path.Clean(p)
+	switch {
+	case p == dir:
+		return ".", true
+	case dir == "/":
+		return strings.TrimPrefix(p, "/"), true
+	case strings.HasPrefix(p, dir+"/"):
+		return strings.TrimPrefix(p, dir+"/"), true
+	}
+	return "", false
+}
+
+// copyPath copies a file, or a directory and its content.
+func copyPath(source, target string) error {
+	return filepath.WalkDir(source, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, file)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(destination, 0755)
+		}
+		in, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(destination)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// tail returns the last lines of a text.
+func tail(text string, lines int) string {
+	all := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -0,0 +1,217 @@
+package testrun
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+)
+
+const countSource = `(bala count (
+	(reads file (desc "Reads"))
+	(mode enum ("fast" "slow") (default "fast"))
+	(verbose boolean (default false))
+	(run_docker
+		(image "biocontainers/count:1.0")
+		(env (MODE mode))
+		(arguments "count" reads mode verbose "-o" "/data/counts.txt"))
+	(outputs
+		(counts txt "/data/counts.txt"))
+	(tests
+		(small
+			(params (reads "reads.fq") (verbose true))
+			(expect (counts (has_line "reads 2") (lines 1)
+				(checksum "sha256:2e205cf4f3f9efb83d4ed91d3e0c5f76141c773ade84a2d9402d06da111bce8e"))))
+		(wrong
+			(params (reads "reads.fq"))
+			(expect (counts (has_text "reads 3")))))
+))`
+
+func parseProgram(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	program, err := parser.New(lexer.New(source)).ParseProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestNewPlan(t *testing.T) {
+	program := parseProgram(t, countSource)
+	plan, err := NewPlan(program, program.Tests[0], "/work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"run", "--rm", "-v", "/work:/data", "-e", "MODE=fast", "biocontainers/count:1.0",
+		"count", "/data/reads.fq", "fast", "--verbose", "-o", "/data/counts.txt"}
+	if !reflect.DeepEqual(plan.Args, expected) {
+		t.Errorf("Args = %q, want %q", plan.Args, expected)
+	}
+	if plan.Outputs["counts"] != filepath.FromSlash("/work/counts.txt") {
+		t.Errorf("Outputs = %v", plan.Outputs)
+	}
+}
+
+func TestNewPlan_Volumes(t *testing.T) {
+	program := parseProgram(t, `(bala align (
+	(reads file)
+	(index directory)
+	(run_docker
+		(image "aligner:1.0")
+		(volumes (reads "/in") (index "/ref") ("/opt/db" "/db"))
+		(arguments reads index))
+	(outputs (bam bam "/in/out.bam") (log txt "results.log"))
+	(tests (t (params (reads "a/r.fq") (index "idx")))))
+))`)
+	plan, err := NewPlan(program, program.Tests[0], "/work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"run", "--rm", "-v", "/work:/in", "-v", "/work:/ref", "-v", "/opt/db:/db",
+		"-v", "/work/align_results:/data/align_results", "aligner:1.0", "/in/r.fq", "/ref/idx"}
+	if !reflect.DeepEqual(plan.Args, expected) {
+		t.Errorf("Args = %q, want %q", plan.Args, expected)
+	}
+	if plan.Outputs["bam"] != filepath.FromSlash("/work/out.bam") || plan.Outputs["log"] != filepath.FromSlash("/work/results.log") {
+		t.Errorf("Outputs = %v", plan.Outputs)
+	}
+}
+
+// fakeDocker writes the counts of its reads argument to the host path of
+// its -o argument, as the container would.
+func fakeDocker() func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		host := map[string]string{}
+		readsArg, outputArg := "", ""
+		for i, arg := range args {
+			switch {
+			case arg == "-v":
+				h, g, _ := strings.Cut(args[i+1], ":")
+				host[g] = h
+			case arg == "-o":
+				outputArg = args[i+1]
+			case strings.HasSuffix(arg, ".fq"):
+				readsArg = arg
+			}
+		}
+		toHost := func(guest string) string {
+			return filepath.Join(host["/data"], strings.TrimPrefix(guest, "/data/"))
+		}
+		reads, err := os.ReadFile(toHost(readsArg))
+		if err != nil {
+			return nil, err
+		}
+		counts := []byte("reads " + string(rune('0'+strings.Count(string(reads), "@"))) + "\n")
+		return nil, os.WriteFile(toHost(outputArg), counts, 0644)
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	program := parseProgram(t, countSource)
+	data := t.TempDir()
+	os.WriteFile(filepath.Join(data, "reads.fq"), []byte("@r1\nACGT\n+\nIIII\n@r2\nACGT\n+\nIIII\n"), 0644)
+	runner := &Runner{Data: data, WorkDir: t.TempDir(), Exec: fakeDocker()}
+
+	result, err := runner.Run(context.Background(), program, program.Tests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed() {
+		t.Errorf("test small failed: %v", result.Failures)
+	}
+
+	result, err = runner.Run(context.Background(), program, program.Tests[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failures) != 1 || !strings.Contains(result.Failures[0], `has no text "reads 3"`) {
+		t.Errorf("Failures = %q, want the has_text assertion", result.Failures)
+	}
+	if entries, _ := os.ReadDir(runner.WorkDir); len(entries) != 0 {
+		t.Errorf("work directories weren't removed: %v", entries)
+	}
+}
+
+func TestRunner_RunErrors(t *testing.T) {
+	program := parseProgram(t, countSource)
+	runner := &Runner{Data: t.TempDir(), WorkDir: t.TempDir(), Exec: fakeDocker()}
+	if _, err := runner.Run(context.Background(), program, program.Tests[0]); err == nil ||
+		!strings.Contains(err.Error(), "staging input 'reads'") {
+		t.Errorf("Run() error = %v, want the missing input", err)
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	os.WriteFile(filepath.Join(runner.Data, "reads.fq"), []byte("@r1\n"), 0644)
+	runner.Exec = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, "sh", "-c", "echo no space left; exit 3").CombinedOutput()
+	}
+	result, err := runner.Run(context.Background(), program, program.Tests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failures) != 1 || !strings.Contains(result.Failures[0], "status 3:\nno space left") {
+		t.Errorf("Failures = %q, want the exit status and output", result.Failures)
+	}
+}
+
+func TestRunner_RunPaths(t *testing.T) {
+	program := parseProgram(t, countSource)
+	root := t.TempDir()
+	data := filepath.Join(root, "test-data")
+	os.Mkdir(data, 0755)
+	os.WriteFile(filepath.Join(data, "reads.fq"), []byte("@r1\nACGT\n+\nIIII\n@r2\nACGT\n+\nIIII\n"), 0644)
+	os.WriteFile(filepath.Join(root, "secret.fq"), []byte("secret\n"), 0644)
+	runner := &Runner{Data: data, WorkDir: t.TempDir(), Exec: fakeDocker()}
+
+	// Test names may not be valid file names
+	test := program.Tests[0]
+	test.Name = "small/../reads"
+	if result, err := runner.Run(context.Background(), program, test); err != nil || !result.Passed() {
+		t.Errorf("Run() = %v, %v, want the test to pass", result.Failures, err)
+	}
+
+	for _, path := range []string{"../secret.fq", filepath.ToSlash(filepath.Join(root, "secret.fq"))} {
+		test := program.Tests[0]
+		test.Params = []ast.TestParam{{Name: "reads", Value: path}}
+		if _, err := runner.Run(context.Background(), program, test); err == nil || !strings.Contains(err.Error(), "not within the data directory") {
+			t.Errorf("%s: Run() error = %v, want the path refused", path, err)
+		}
+	}
+	if failure := runner.assert(ast.Assertion{Kind: ast.AssertFile, Value: "../secret.fq"}, []byte("secret\n")); !strings.Contains(failure, "not within the data directory") {
+		t.Errorf("file assertion = %q, want the path refused", failure)
+	}
+}
+
+func TestRunner_Assert(t *testing.T) {
+	data := t.TempDir()
+	os.WriteFile(filepath.Join(data, "expected.txt"), []byte("a\nb\n"), 0644)
+	runner := &Runner{Data: data}
+	tests := []struct {
+		assertion ast.Assertion
+		ok        bool
+	}{
+		{ast.Assertion{Kind: ast.AssertHasText, Value: "a\nb"}, true},
+		{ast.Assertion{Kind: ast.AssertHasLine, Value: "b"}, true},
+		{ast.Assertion{Kind: ast.AssertHasLine, Value: "a\nb"}, false},
+		{ast.Assertion{Kind: ast.AssertMatches, Value: `^a\s`}, true},
+		{ast.Assertion{Kind: ast.AssertLines, Value: 2}, true},
+		{ast.Assertion{Kind: ast.AssertLines, Value: 3}, false},
+		{ast.Assertion{Kind: ast.AssertChecksum, Value: "md5:1f0d3a6a0e2c0e4f9d8a0b8c8f1e6c4b"}, false},
+		{ast.Assertion{Kind: ast.AssertFile, Value: "expected.txt"}, true},
+		{ast.Assertion{Kind: ast.AssertFile, Value: "missing.txt"}, false},
+	}
+	for _, tt := range tests {
+		if problem := runner.assert(tt.assertion, []byte("a\nb\n")); (problem == "") != tt.ok {
+			t.Errorf("assert(%s %v) = %q, want ok %v", tt.assertion.Kind, tt.assertion.Value, problem, tt.ok)
+		}
+	}
+}
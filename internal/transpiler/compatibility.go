@@ -0,0 +1,201 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Support describes how well a target handles a DSL feature.
+type Support int
+
+const (
+	Supported Support = iota
+	Degraded
+	Unsupported
+)
+
+func (s Support) String() string {
+	switch s {
+	case Degraded:
+		return "degraded"
+	case Unsupported:
+		return "unsupported"
+	}
+	return "supported"
+}
+
+// Limitation describes a feature a target doesn't fully support.
+type Limitation struct {
+	Support Support
+	Note    string
+}
+
+// Feature keys, as reported by ProgramFeatures.
+func typeFeature(paramType string) string      { return "type:" + paramType }
+func fieldFeature(impl, field string) string   { return "field:" + impl + "." + field }
+func outputFeature(format string) string       { return "output:" + format }
+func implementationFeature(impl string) string { return "implementation:" + impl }
+func blockFeature(block string) string         { return "block:" + block }
+func clauseFeature(clause string) string       { return "clause:" + clause }
+
+// OutputStyle describes how a target exposes the outputs of a program.
+type OutputStyle string
+
+const (
+	// OutputsDeclared: each output is declared in the generated tool.
+	OutputsDeclared OutputStyle = "declared"
+	// OutputsDirectory: the results are returned as a single directory.
+	OutputsDirectory OutputStyle = "directory"
+	// OutputsEchoed: the output paths are printed when the run ends.
+	OutputsEchoed OutputStyle = "echoed"
+	// OutputsPublished: the results are published to a fixed path.
+	OutputsPublished OutputStyle = "published"
+	// OutputsNone: the target produces no outputs.
+	OutputsNone OutputStyle = "none"
+)
+
+// Capabilities declares the DSL features a target supports.
+type Capabilities struct {
+	// ParameterTypes lists the supported parameter types.
+	ParameterTypes []string
+	// Implementations lists the supported implementation blocks.
+	Implementations []string
+	Outputs         OutputStyle
+	// Limitations lists the features that are degraded or unsupported,
+	// by feature key, e.g. "field:run_docker.env" or "output:*".
+	Limitations map[string]Limitation
+}
+
+// Support returns how the target handles a feature, as reported by
+// ProgramFeatures. The fields of an unsupported implementation are
+// reported with the implementation itself.
+func (c *Capabilities) Support(feature string) Limitation {
+	if limitation, ok := c.Limitations[feature]; ok {
+		return limitation
+	}
+	if limitation, ok := c.Limitations[wildcardFeature(feature)]; ok {
+		return limitation
+	}
+	kind, name, _ := strings.Cut(feature, ":")
+	switch kind {
+	case "type":
+		if !slices.Contains(c.ParameterTypes, name) {
+			return Limitation{Unsupported, fmt.Sprintf("parameters of type %s are not supported", name)}
+		}
+	case "implementation":
+		if !slices.Contains(c.Implementations, name) {
+			return Limitation{Unsupported, fmt.Sprintf("the %s implementation is not supported", name)}
+		}
+	}
+	return Limitation{Support: Supported}
+}
+
+// allTypes returns the built-in parameter types, for the Capabilities of
+// the targets supporting all of them.
+func allTypes() []string {
+	return slices.Clone(ast.Types)
+}
+
+// UsedFeature is a DSL feature used by a program, with the position of its
+// first use.
+type UsedFeature struct {
+	Key string
+	Pos ast.Position
+}
+
+// ProgramFeatures lists the features used by a program, in source order.
+func ProgramFeatures(program *ast.Program) []UsedFeature {
+	features := []UsedFeature{}
+	seen := map[string]bool{}
+	add := func(key string, pos ast.Position) {
+		if !seen[key] {
+			seen[key] = true
+			features = append(features, UsedFeature{Key: key, Pos: pos})
+		}
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Parameter:
+			add(typeFeature(n.Type), n.Pos)
+			if n.When != nil {
+				add(clauseFeature("when"), n.When.Pos)
+			}
+		case *ast.ImplementationBlock:
+			add(implementationFeature(n.Name), n.Pos)
+			fields := make([]string, 0, len(n.Fields))
+			for name := range n.Fields {
+				fields = append(fields, name)
+			}
+			sort.Strings(fields)
+			for _, name := range fields {
+				add(fieldFeature(n.Name, name), n.FieldPosition(name))
+			}
+		case *ast.OutputBlock:
+			add(outputFeature(n.Format), n.Pos)
+		case *ast.TestBlock:
+			add(blockFeature("tests"), n.Pos)
+		}
+		return true
+	})
+	if len(program.Resources) > 0 {
+		add(blockFeature("resources"), program.Resources[0].Pos)
+	}
+	return features
+}
+
+// CompatibilityIssue is a feature of a program that a target degrades or
+// doesn't support.
+type CompatibilityIssue struct {
+	Target  string
+	Feature string
+	Pos     ast.Position
+	Limitation
+}
+
+func (i CompatibilityIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", i.Pos, i.Support, i.Note, i.Feature)
+}
+
+// CheckCompatibility reports the features of a program that a target
+// degrades or doesn't support, including the features introduced after the
+// DSL version of the target. Targets without declared Capabilities, such as
+// plugins, report only the latter.
+func CheckCompatibility(lang string, program *ast.Program) ([]CompatibilityIssue, error) {
+	descriptor, err := GetTranspiler(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := languageIssues(lang, descriptor, program)
+	if descriptor.Capabilities == nil {
+		return issues, nil
+	}
+	for _, feature := range ProgramFeatures(program) {
+		if slices.ContainsFunc(issues, func(i CompatibilityIssue) bool { return i.Feature == feature.Key }) {
+			continue
+		}
+		limitation := descriptor.Capabilities.Support(feature.Key)
+		if limitation.Support == Supported {
+			continue
+		}
+		issues = append(issues, CompatibilityIssue{
+			Target:     lang,
+			Feature:    feature.Key,
+			Pos:        feature.Pos,
+			Limitation: limitation,
+		})
+	}
+	return issues, nil
+}
+
+// wildcardFeature returns the "kind:*" key matching any feature of a kind,
+// e.g. "output:*" for "output:tsv".
+func wildcardFeature(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind + ":*"
+}
@@ -0,0 +1,183 @@
+package transpiler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func compatibilityProgram() *ast.Program {
+	return &ast.Program{
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "sep"}, Type: TypeCharacter},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "input"}, Type: TypeFile},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name: "run_docker",
+			Fields: map[string]any{
+				"image":     "ubuntu:22.04",
+				"arguments": []any{"sep", "input"},
+			},
+		}},
+		Outputs: []ast.OutputBlock{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "results"}, Format: TypeDirectory},
+		},
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"galaxy", []string{outputFeature(TypeDirectory)}},
+		{"nextflow", []string{typeFeature(TypeCharacter)}},
+		{"r", []string{outputFeature(TypeDirectory)}},
+		{"streamflow", []string{implementationFeature("run_docker")}},
+	}
+	for _, tt := range tests {
+		issues, err := CheckCompatibility(tt.lang, compatibilityProgram())
+		if err != nil {
+			t.Fatalf("CheckCompatibility(%q) unexpected error: %v", tt.lang, err)
+		}
+		if len(issues) != len(tt.expected) {
+			t.Fatalf("CheckCompatibility(%q) = %v, want features %v", tt.lang, issues, tt.expected)
+		}
+		for i, issue := range issues {
+			if issue.Feature != tt.expected[i] {
+				t.Errorf("CheckCompatibility(%q)[%d].Feature = %q, want %q", tt.lang, i, issue.Feature, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestCheckCompatibility_UnknownTarget(t *testing.T) {
+	if _, err := CheckCompatibility("cobol", compatibilityProgram()); err == nil {
+		t.Error("CheckCompatibility() expected error for unknown target")
+	}
+}
+
+func TestCapabilities_Support(t *testing.T) {
+	caps := &Capabilities{
+		ParameterTypes:  []string{TypeString},
+		Implementations: []string{"run_docker"},
+		Limitations: map[string]Limitation{
+			fieldFeature("run_docker", "env"): {Degraded, "env is ignored"},
+			outputFeature("*"):                {Degraded, "outputs are not declared"},
+		},
+	}
+	tests := []struct {
+		feature  string
+		expected Support
+	}{
+		{typeFeature(TypeString), Supported},
+		{typeFeature(TypeFile), Unsupported},
+		{implementationFeature("run_docker"), Supported},
+		{implementationFeature("run_singularity"), Unsupported},
+		{fieldFeature("run_docker", "env"), Degraded},
+		{fieldFeature("run_docker", "image"), Supported},
+		{outputFeature("tsv"), Degraded},
+	}
+	for _, tt := range tests {
+		if got := caps.Support(tt.feature); got.Support != tt.expected {
+			t.Errorf("Support(%q) = %v, want %v", tt.feature, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckCompatibility_UnknownCapabilities(t *testing.T) {
+	RegisterTranspiler("no-capabilities", &TranspilerDescriptor{})
+	defer delete(transpilerRegistry, "no-capabilities")
+
+	issues, err := CheckCompatibility("no-capabilities", compatibilityProgram())
+	if err != nil || len(issues) != 0 {
+		t.Errorf("CheckCompatibility() = %v, %v, want no issues", issues, err)
+	}
+}
+
+func TestNegotiateFeatures(t *testing.T) {
+	RegisterTranspiler("legacy", &TranspilerDescriptor{
+		Language: "1.1",
+		Capabilities: &Capabilities{
+			Limitations: map[string]Limitation{
+				typeFeature(TypeCharacter): {Degraded, "characters are plain strings"},
+			},
+		},
+	})
+	RegisterTranspiler("ancient", &TranspilerDescriptor{Language: "1.0"})
+	defer delete(transpilerRegistry, "legacy")
+	defer delete(transpilerRegistry, "ancient")
+
+	warnings, err := NegotiateFeatures("legacy", compatibilityProgram())
+	if err != nil {
+		t.Fatalf("NegotiateFeatures() unexpected error: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Feature != typeFeature(TypeCharacter) || warnings[0].Support != Degraded {
+		t.Errorf("NegotiateFeatures() = %v, want a degraded character type", warnings)
+	}
+
+	_, err = NegotiateFeatures("ancient", compatibilityProgram())
+	var unsupported *UnsupportedFeatureError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("NegotiateFeatures() error = %v, want an UnsupportedFeatureError", err)
+	}
+	expected := "target ancient does not support parameters of type character (introduced in bala 1.2)"
+	if unsupported.Feature != typeFeature(TypeCharacter) || unsupported.Message != expected {
+		t.Errorf("NegotiateFeatures() error = %q, want %q", unsupported.Message, expected)
+	}
+
+	// Current targets accept every feature
+	if warnings, err := NegotiateFeatures("r", compatibilityProgram()); err != nil || len(warnings) != 0 {
+		t.Errorf("NegotiateFeatures(r) = %v, %v, want no warnings", warnings, err)
+	}
+}
+
+func TestNewerFeatures(t *testing.T) {
+	issues, err := NewerFeatures(compatibilityProgram(), "1.0")
+	if err != nil {
+		t.Fatalf("NewerFeatures() unexpected error: %v", err)
+	}
+	keys := []string{}
+	for _, issue := range issues {
+		keys = append(keys, issue.Feature)
+	}
+	if len(keys) != 2 || keys[0] != typeFeature(TypeCharacter) || keys[1] != outputFeature(TypeDirectory) {
+		t.Errorf("NewerFeatures(1.0) = %v, want the character type and the outputs", keys)
+	}
+	if expected := "bala 1.0 doesn't have parameters of type character, introduced in 1.2"; issues[0].Note != expected {
+		t.Errorf("NewerFeatures(1.0) note = %q, want %q", issues[0].Note, expected)
+	}
+
+	if issues, err := NewerFeatures(compatibilityProgram(), LanguageVersion); err != nil || len(issues) != 0 {
+		t.Errorf("NewerFeatures(%s) = %v, %v, want none", LanguageVersion, issues, err)
+	}
+	for _, version := range []string{"2.0", "1", "latest"} {
+		if _, err := NewerFeatures(compatibilityProgram(), version); err == nil {
+			t.Errorf("NewerFeatures(%q) expected error", version)
+		}
+	}
+}
+
+func TestFeatureVersion(t *testing.T) {
+	tests := []struct {
+		feature  string
+		expected string
+	}{
+		{typeFeature(TypeString), "1.0"},
+		{fieldFeature("run_docker", "image"), "1.0"},
+		{fieldFeature("run_docker", "env"), "1.1"},
+		{outputFeature("tsv"), "1.1"},
+		{typeFeature(TypeCharacter), "1.2"},
+		{blockFeature("tests"), "1.3"},
+		{fieldFeature("run_docker", "version_command"), "1.3"},
+		{fieldFeature("run_docker", "exit_codes"), "1.3"},
+		{blockFeature("resources"), "1.3"},
+		{clauseFeature("when"), "1.3"},
+	}
+	for _, tt := range tests {
+		if got := FeatureVersion(tt.feature); got != tt.expected {
+			t.Errorf("FeatureVersion(%q) = %q, want %q", tt.feature, got, tt.expected)
+		}
+	}
+}
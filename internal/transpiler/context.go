@@ -0,0 +1,32 @@
+package transpiler
+
+import (
+	"context"
+	"io"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// TranspileContext is like t.TranspileTo, but stops with the error of ctx
+// when ctx is canceled before the transpilation completes. The built-in
+// transpilers check ctx between parameters and implementation blocks,
+// plugins are killed.
+func TranspileContext(ctx context.Context, t Transpiler, w io.Writer, program *ast.Program) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c, ok := t.(interface {
+		transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error
+	}); ok {
+		return c.transpileContext(ctx, w, program)
+	}
+	return t.TranspileTo(w, program)
+}
+
+// canceled returns the error of the context of the call, if any.
+func (t *TranspilerBase) canceled() error {
+	if t.ctx == nil {
+		return nil
+	}
+	return t.ctx.Err()
+}
@@ -0,0 +1,43 @@
+package transpiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// ErrUnsupported matches every UnsupportedFeatureError with errors.Is.
+var ErrUnsupported = errors.New("unsupported feature")
+
+// UnsupportedFeatureError reports a construct of a program that a target
+// can't transpile.
+type UnsupportedFeatureError struct {
+	Target  string
+	Feature string // the feature key, e.g. "implementation:run_singularity"
+	Pos     ast.Position
+	Message string
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	if e.Pos.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Is makes errors.Is(err, ErrUnsupported) report true.
+func (e *UnsupportedFeatureError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// unsupportedImplementation reports an implementation block without a
+// handler in a target.
+func unsupportedImplementation(target string, impl *ast.ImplementationBlock, format string) error {
+	return &UnsupportedFeatureError{
+		Target:  target,
+		Feature: implementationFeature(impl.Name),
+		Pos:     impl.Pos,
+		Message: fmt.Sprintf(format, impl.Name),
+	}
+}
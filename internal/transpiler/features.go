@@ -0,0 +1,183 @@
+package transpiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// LanguageVersion is the version of the DSL implemented by this release.
+const LanguageVersion = "1.3"
+
+// featureVersions records the DSL version that introduced each feature, by
+// feature key. Features not listed date back to 1.0.
+var featureVersions = map[string]string{
+	outputFeature("*"):                            "1.1",
+	fieldFeature("run_docker", "env"):             "1.1",
+	typeFeature(TypeCharacter):                    "1.2",
+	fieldFeature("run_docker", "command"):         "1.2",
+	blockFeature("tests"):                         "1.3",
+	fieldFeature("run_docker", "version_command"): "1.3",
+	fieldFeature("run_docker", "exit_codes"):      "1.3",
+	fieldFeature("run_docker", "error_patterns"):  "1.3",
+	fieldFeature("run_docker", "config_files"):    "1.3",
+	blockFeature("resources"):                     "1.3",
+	typeFeature(TypeSamplesheet):                  "1.3",
+	clauseFeature("when"):                         "1.3",
+}
+
+// FeatureVersion returns the DSL version that introduced a feature.
+func FeatureVersion(feature string) string {
+	if version, ok := featureVersions[feature]; ok {
+		return version
+	}
+	if version, ok := featureVersions[wildcardFeature(feature)]; ok {
+		return version
+	}
+	return "1.0"
+}
+
+// parseVersion parses a major.minor DSL version.
+func parseVersion(version string) ([2]int, error) {
+	major, minor, ok := strings.Cut(version, ".")
+	if ok {
+		x, errX := strconv.Atoi(major)
+		y, errY := strconv.Atoi(minor)
+		if errX == nil && errY == nil && x >= 0 && y >= 0 {
+			return [2]int{x, y}, nil
+		}
+	}
+	return [2]int{}, fmt.Errorf("invalid language version %q, expected major.minor", version)
+}
+
+// newerVersion reports whether version a is after b; both are valid.
+func newerVersion(a, b string) bool {
+	x, _ := parseVersion(a)
+	y, _ := parseVersion(b)
+	return x[0] > y[0] || x[0] == y[0] && x[1] > y[1]
+}
+
+// NewerFeatures reports the features of a program introduced after a DSL
+// version, e.g. the one a collection of programs is migrated to. The
+// version must not be after LanguageVersion.
+func NewerFeatures(program *ast.Program, version string) ([]CompatibilityIssue, error) {
+	if _, err := parseVersion(version); err != nil {
+		return nil, err
+	}
+	if newerVersion(version, LanguageVersion) {
+		return nil, fmt.Errorf("bala %s is after the current version %s", version, LanguageVersion)
+	}
+	issues := []CompatibilityIssue{}
+	for _, feature := range ProgramFeatures(program) {
+		introduced := FeatureVersion(feature.Key)
+		if !newerVersion(introduced, version) {
+			continue
+		}
+		issues = append(issues, CompatibilityIssue{
+			Feature: feature.Key,
+			Pos:     feature.Pos,
+			Limitation: Limitation{Unsupported, fmt.Sprintf("bala %s doesn't have %s, introduced in %s",
+				version, describeFeature(feature.Key), introduced)},
+		})
+	}
+	return issues, nil
+}
+
+// DSLVersion returns the DSL version implemented by a target.
+func (d *TranspilerDescriptor) DSLVersion() string {
+	if d.Language == "" {
+		return LanguageVersion
+	}
+	return d.Language
+}
+
+// describeFeature returns the name of a feature in messages, e.g. "the env
+// field of run_docker" for "field:run_docker.env".
+func describeFeature(feature string) string {
+	kind, name, _ := strings.Cut(feature, ":")
+	switch kind {
+	case "type":
+		return fmt.Sprintf("parameters of type %s", name)
+	case "field":
+		impl, field, _ := strings.Cut(name, ".")
+		return fmt.Sprintf("the %s field of %s", field, impl)
+	case "output":
+		return "outputs"
+	case "block":
+		return fmt.Sprintf("the %s block", name)
+	case "clause":
+		return fmt.Sprintf("the %s clause of parameters", name)
+	case "implementation":
+		return fmt.Sprintf("the %s implementation", name)
+	}
+	return feature
+}
+
+// languageIssues reports the features of a program introduced after the
+// DSL version of a target. The target may declare how it handles them in
+// its Limitations, otherwise they are unsupported.
+func languageIssues(lang string, descriptor *TranspilerDescriptor, program *ast.Program) []CompatibilityIssue {
+	issues := []CompatibilityIssue{}
+	for _, feature := range ProgramFeatures(program) {
+		introduced := FeatureVersion(feature.Key)
+		if !newerVersion(introduced, descriptor.DSLVersion()) {
+			continue
+		}
+		limitation := Limitation{Unsupported, fmt.Sprintf("target %s does not support %s (introduced in bala %s)",
+			lang, describeFeature(feature.Key), introduced)}
+		if declared, ok := descriptor.declaredLimitation(feature.Key); ok {
+			if declared.Support == Supported {
+				continue
+			}
+			if declared.Support == Degraded {
+				limitation = Limitation{Degraded, fmt.Sprintf("%s (introduced in bala %s)", declared.Note, introduced)}
+			}
+		}
+		issues = append(issues, CompatibilityIssue{
+			Target:     lang,
+			Feature:    feature.Key,
+			Pos:        feature.Pos,
+			Limitation: limitation,
+		})
+	}
+	return issues
+}
+
+// declaredLimitation returns the limitation a target declares for a
+// feature, by key or wildcard.
+func (d *TranspilerDescriptor) declaredLimitation(feature string) (Limitation, bool) {
+	if d.Capabilities == nil {
+		return Limitation{}, false
+	}
+	if limitation, ok := d.Capabilities.Limitations[feature]; ok {
+		return limitation, true
+	}
+	limitation, ok := d.Capabilities.Limitations[wildcardFeature(feature)]
+	return limitation, ok
+}
+
+// NegotiateFeatures checks the features of a program against the DSL
+// version of a target before transpiling. Newer features the target
+// degrades are returned as warnings; the first one it doesn't support is
+// returned as an *UnsupportedFeatureError.
+func NegotiateFeatures(lang string, program *ast.Program) ([]CompatibilityIssue, error) {
+	descriptor, err := GetTranspiler(lang)
+	if err != nil {
+		return nil, err
+	}
+	warnings := []CompatibilityIssue{}
+	for _, issue := range languageIssues(lang, descriptor, program) {
+		if issue.Support == Unsupported {
+			return nil, &UnsupportedFeatureError{
+				Target:  lang,
+				Feature: issue.Feature,
+				Pos:     issue.Pos,
+				Message: issue.Note,
+			}
+		}
+		warnings = append(warnings, issue)
+	}
+	return warnings, nil
+}
@@ -0,0 +1,221 @@
+package transpiler
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+)
+
+// galaxyMounts returns the guest paths of an implementation that the job
+// working directory stands for: /data, where the other targets mount the
+// directory of the inputs, and the guest paths of its volumes, which become
+// directories of the job working directory.
+func galaxyMounts(impl *ast.ImplementationBlock) []string {
+	mounts := []string{"/data"}
+	volumes, _ := impl.Fields["volumes"].([]any)
+	for _, volume := range volumes {
+		if pair, ok := volume.([]any); ok && len(pair) >= 2 {
+			mounts = append(mounts, path.Clean(fmt.Sprint(pair[1])))
+		}
+	}
+	return mounts
+}
+
+// galaxyWorkPath returns the path in the job working directory of a path of
+// the container, e.g. out.sam for /data/out.sam and inputs/a.fq for
+// /inputs/a.fq with a volume mounted at /inputs. Other paths are kept.
+func galaxyWorkPath(guestPath string, mounts []string) string {
+	for _, mount := range mounts {
+		if !underGuestPath(guestPath, mount) {
+			continue
+		}
+		relative := guestPath
+		if mount == "/data" {
+			relative = strings.TrimPrefix(guestPath, "/data")
+		}
+		if relative = strings.Trim(relative, "/"); relative == "" {
+			return "."
+		}
+		return relative
+	}
+	return guestPath
+}
+
+// underGuestPath reports whether a path is a mount point or under it.
+func underGuestPath(p, mount string) bool {
+	return p == mount || strings.HasPrefix(p, strings.TrimSuffix(mount, "/")+"/")
+}
+
+// galaxyStagedPath returns where a file or directory parameter is staged
+// in the job working directory, under its name, with the extension of the
+// dataset for files. A volume mounting a directory stages it at its guest
+// path, and one mounting the directory of a file stages the file there.
+func galaxyStagedPath(param ast.Parameter, params []ast.Parameter, impl *ast.ImplementationBlock) string {
+	name := param.Name
+	if param.Type == TypeFile {
+		name += ".${" + strings.TrimPrefix(galaxyVariable(param, params), "$") + ".ext}"
+	}
+	if guest, ok := volumeOf(param, impl); ok {
+		dir := galaxyWorkPath(guest, galaxyMounts(impl))
+		switch {
+		case param.Type == TypeDirectory && dir != ".":
+			return dir
+		case param.Type == TypeFile && dir != ".":
+			return dir + "/" + name
+		}
+	}
+	return name
+}
+
+// volumeOf returns the guest path of the volume mounting a parameter, if
+// any.
+func volumeOf(param ast.Parameter, impl *ast.ImplementationBlock) (string, bool) {
+	volumes, _ := impl.Fields["volumes"].([]any)
+	for _, volume := range volumes {
+		if pair, ok := volume.([]any); ok && len(pair) >= 2 && fmt.Sprint(pair[0]) == param.Name {
+			return path.Clean(fmt.Sprint(pair[1])), true
+		}
+	}
+	return "", false
+}
+
+// staged reports whether a parameter is staged in the job working
+// directory, as the files and directories other than data tables are.
+func staged(param ast.Parameter) bool {
+	_, dataTable := param.Metadata["galaxy_data_table"]
+	return !dataTable && (param.Type == TypeFile || param.Type == TypeDirectory)
+}
+
+// writeCommand writes the Cheetah command of a docker implementation, its
+// steps chained with &&, as Galaxy joins the lines of the command: the
+// directories of the staged inputs, of the config files and of the outputs
+// are created, the config files and the inputs are linked under the names
+// the arguments use, the tool runs, and
+// the outputs it writes are moved to their datasets, or its standard
+// output is captured in the first output without a path.
+func (g *GalaxyTranspiler) writeCommand(impl *ast.ImplementationBlock, program *ast.Program) error {
+	params := program.Parameters
+	mounts := galaxyMounts(impl)
+	args, _ := impl.Fields["arguments"].([]any)
+	placeholders := configPlaceholders(impl)
+	used := []ast.Parameter{} // the inputs the arguments, the volumes or the config files use
+	for _, param := range params {
+		_, mounted := volumeOf(param, impl)
+		if staged(param) && (mounted || slices.Contains(placeholders, param.Name) ||
+			slices.ContainsFunc(args, func(arg any) bool { return arg == param.Name })) {
+			used = append(used, param)
+		}
+	}
+	configs, err := g.writeConfigfiles(impl, program)
+	if err != nil {
+		return err
+	}
+
+	dirs := []string{}
+	addDir := func(dir string) {
+		if dir != "." && dir != "" && !path.IsAbs(dir) && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, param := range used {
+		if param.Type == TypeDirectory {
+			addDir(galaxyStagedPath(param, params, impl))
+		} else {
+			addDir(path.Dir(galaxyStagedPath(param, params, impl)))
+		}
+	}
+	for _, config := range configs {
+		addDir(path.Dir(config))
+	}
+	for _, output := range program.Outputs {
+		if output.Path == "" {
+			continue
+		}
+		if output.Format == TypeDirectory {
+			addDir(galaxyDiscoverDatasets(output.Path).Directory)
+		} else {
+			addDir(path.Dir(galaxyWorkPath(output.Path, mounts)))
+		}
+	}
+
+	command := &galaxy.Command{}
+	g.galaxyTool.Command = command
+	if len(dirs) > 0 {
+		quoted := []string{}
+		for _, dir := range dirs {
+			quoted = append(quoted, "'"+dir+"'")
+		}
+		command.Value += "mkdir -p " + strings.Join(quoted, " ") + " &&\n"
+	}
+	for i, config := range configs {
+		command.Value += fmt.Sprintf("ln -s '$%s' '%s' &&\n", g.galaxyTool.Configfiles.Configfile[i].Name, config)
+	}
+	for _, param := range used {
+		i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == param.Name })
+		link := fmt.Sprintf("ln -s '%s' '%s' &&", galaxyVariable(param, params), galaxyStagedPath(param, params, impl))
+		if param.Type == TypeDirectory {
+			element := "$" + param.Name + "_element"
+			link = fmt.Sprintf("#for %s in %s\n  ln -s '%s' '%s/${%s.element_identifier}' &&\n#end for",
+				element, galaxyVariable(param, params), element, galaxyStagedPath(param, params, impl), strings.TrimPrefix(element, "$"))
+		}
+		if test := galaxyArgumentTest(params, i); test != "" {
+			link = "#if " + test + "\n" + indentLines(link, "  ") + "\n#end if"
+		}
+		command.Value += link + "\n"
+	}
+
+	pending := "" // an option, e.g. -k, kept with the conditional parameter after it
+	for _, arg := range args {
+		argStr, ok := arg.(string)
+		if !ok || argStr == "_" {
+			continue
+		}
+		// Format the argument to include Galaxy parameter references
+		formattedArg := formatGalaxyArgument(argStr, params, impl)
+		i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == argStr })
+		if i < 0 && strings.HasPrefix(argStr, "-") {
+			g.appendArgument(pending)
+			pending = formattedArg
+			continue
+		}
+		if test := galaxyArgumentTest(params, i); test != "" {
+			// The params of a conditional only exist for its values,
+			// optional params may have no value, and booleans are flags
+			g.appendConditionalArgument(strings.TrimSpace(pending+" "+formattedArg), test)
+			pending = ""
+			continue
+		}
+		g.appendArgument(pending)
+		g.appendArgument(formattedArg)
+		pending = ""
+	}
+	g.appendArgument(pending)
+
+	moves := []string{}
+	captured := false
+	for _, output := range program.Outputs {
+		switch {
+		case output.Format == TypeDirectory:
+			// The datasets of collections are discovered where they are
+		case output.Path == "" && !captured:
+			g.appendArgument("> '$" + output.Name + "'")
+			captured = true
+		case output.Path != "":
+			moves = append(moves, fmt.Sprintf("&& mv '%s' '$%s'", galaxyWorkPath(output.Path, mounts), output.Name))
+		}
+	}
+	command.Value = strings.TrimSuffix(command.Value, "\n")
+	if len(moves) > 0 {
+		command.Value += "\n" + strings.Join(moves, "\n")
+	}
+	return nil
+}
+
+// indentLines indents each line of a block.
+func indentLines(block, indent string) string {
+	return indent + strings.ReplaceAll(block, "\n", "\n"+indent)
+}
@@ -0,0 +1,174 @@
+package transpiler
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+)
+
+// galaxyConditionalName returns the name of the conditional of the
+// parameter other parameters depend on.
+func galaxyConditionalName(selector string) string {
+	return selector + "_conditional"
+}
+
+// galaxyConditional returns the name of the conditional a parameter is in,
+// as the one others depend on or as one depending on it, if any.
+func galaxyConditional(param ast.Parameter, params []ast.Parameter) (string, bool) {
+	if param.When != nil {
+		return galaxyConditionalName(param.When.Param), true
+	}
+	if slices.ContainsFunc(params, func(p ast.Parameter) bool { return p.When != nil && p.When.Param == param.Name }) {
+		return galaxyConditionalName(param.Name), true
+	}
+	return "", false
+}
+
+// galaxyPath returns the names of the section and conditional a parameter
+// is in, if any, followed by its own name.
+func galaxyPath(param ast.Parameter, params []ast.Parameter) []string {
+	path := []string{}
+	if group := galaxyGroup(param, params); group != "" {
+		path = append(path, galaxySectionName(group))
+	}
+	if conditional, ok := galaxyConditional(param, params); ok {
+		path = append(path, conditional)
+	}
+	return append(path, param.Name)
+}
+
+// galaxyVariable returns the Cheetah variable of a parameter, qualified by
+// its section and conditional, e.g. $mode_conditional.index.
+func galaxyVariable(param ast.Parameter, params []ast.Parameter) string {
+	return "$" + strings.Join(galaxyPath(param, params), ".")
+}
+
+// galaxyTestParamName returns the name of a parameter in a test, with the
+// section and conditional it is in, e.g. mode_conditional|index.
+func galaxyTestParamName(name string, params []ast.Parameter) string {
+	i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == name })
+	if i < 0 {
+		return name
+	}
+	return strings.Join(galaxyPath(params[i], params), "|")
+}
+
+// galaxyCondition returns the Cheetah test of the condition of a
+// parameter, e.g. str($mode_conditional.mode) == 'sensitive'.
+func galaxyCondition(when *ast.Condition, params []ast.Parameter) string {
+	selector := ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: when.Param}}
+	if i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == when.Param }); i >= 0 {
+		selector = params[i]
+	}
+	test := "str(" + galaxyVariable(selector, params) + ")"
+	values := []string{}
+	for _, value := range when.Values {
+		values = append(values, "'"+FormatLiteral(value, galaxyLiteralSyntax)+"'")
+	}
+	if len(values) == 1 {
+		return test + " == " + values[0]
+	}
+	return test + " in [" + strings.Join(values, ", ") + "]"
+}
+
+// galaxyArgumentTest returns the Cheetah test of the argument of the i-th
+// parameter, when it is only passed for some values: its condition,
+// whether an optional parameter has a value, and whether a boolean is true.
+// Optional selects are None without a value, and other optional values are
+// empty.
+func galaxyArgumentTest(params []ast.Parameter, i int) string {
+	if i < 0 {
+		return ""
+	}
+	param := params[i]
+	tests := []string{}
+	if param.When != nil {
+		tests = append(tests, galaxyCondition(param.When, params))
+	}
+	if param.Metadata["optional"] == "true" {
+		variable := galaxyVariable(param, params)
+		switch param.Type {
+		case TypeFile, TypeDirectory:
+			tests = append(tests, variable)
+		case TypeEnum:
+			tests = append(tests, "str("+variable+") != 'None'")
+		default:
+			tests = append(tests, "str("+variable+")")
+		}
+	}
+	switch {
+	case param.Type == TypeBoolean && galaxyHidden(param):
+		// The value of a hidden param is text, "false" included
+		tests = append(tests, "str("+galaxyVariable(param, params)+") == 'true'")
+	case param.Type == TypeBoolean:
+		tests = append(tests, galaxyVariable(param, params))
+	}
+	return strings.Join(tests, " and ")
+}
+
+// selectorValues returns the values of a parameter that others depend on,
+// one for each <when> of its conditional.
+func selectorValues(param ast.Parameter) []string {
+	if param.Type == TypeBoolean {
+		return []string{"true", "false"}
+	}
+	values := []string{}
+	for _, value := range param.Constraints {
+		values = append(values, FormatLiteral(value, galaxyLiteralSyntax))
+	}
+	return values
+}
+
+// nestConditionals replaces the param of each parameter others depend on
+// with a <conditional>, holding it and a <when> for each of its values with
+// the params of the parameters used for that value.
+func (g *GalaxyTranspiler) nestConditionals(params []ast.Parameter) {
+	dependents := map[string][]ast.Parameter{}
+	for _, param := range params {
+		if param.When != nil {
+			dependents[param.When.Param] = append(dependents[param.When.Param], param)
+		}
+	}
+	if len(dependents) == 0 {
+		return
+	}
+
+	generated := map[string]galaxy.Param{}
+	for _, element := range g.galaxyTool.Inputs.Elements {
+		if param, ok := element.(galaxy.Param); ok {
+			generated[param.Name] = param
+		}
+	}
+
+	elements := []any{}
+	for _, element := range g.galaxyTool.Inputs.Elements {
+		param, ok := element.(galaxy.Param)
+		i := slices.IndexFunc(params, func(p ast.Parameter) bool { return ok && p.Name == param.Name })
+		switch {
+		case i < 0:
+			elements = append(elements, element)
+		case params[i].When != nil:
+			// In the conditional of the parameter it depends on
+		case dependents[param.Name] != nil:
+			conditional := galaxy.Conditional{Name: galaxyConditionalName(param.Name), Param: param}
+			for _, value := range selectorValues(params[i]) {
+				when := galaxy.When{Value: value}
+				for _, dependent := range dependents[param.Name] {
+					used := slices.ContainsFunc(dependent.When.Values, func(v any) bool {
+						return FormatLiteral(v, galaxyLiteralSyntax) == value
+					})
+					if generated, ok := generated[dependent.Name]; ok && used {
+						when.Elements = append(when.Elements, generated)
+					}
+				}
+				conditional.When = append(conditional.When, when)
+			}
+			elements = append(elements, conditional)
+		default:
+			elements = append(elements, element)
+		}
+	}
+	g.galaxyTool.Inputs.Elements = elements
+}
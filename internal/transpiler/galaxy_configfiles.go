@@ -0,0 +1,109 @@
+package transpiler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/schema"
+)
+
+var configfileNameInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// cheetahEscaper escapes the text of templates, where $ and # start Cheetah
+// placeholders and directives.
+var cheetahEscaper = strings.NewReplacer("$", `\$`, "#", `\#`)
+
+// galaxyConfigfileName returns the name of the configfile of a path of the
+// container, from its base name, e.g. tool_ini for /data/tool.ini. Names
+// taken by params, outputs or other configfiles get a config_ prefix, and
+// then a number.
+func galaxyConfigfileName(file string, taken []string) string {
+	name := strings.Trim(configfileNameInvalid.ReplaceAllString(strings.ToLower(path.Base(file)), "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' || slices.Contains(taken, name) {
+		name = strings.TrimSuffix("config_"+name, "_")
+	}
+	for i, base := 2, name; slices.Contains(taken, name); i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	return name
+}
+
+// configPlaceholders returns the names in the placeholders of the config
+// files of an implementation.
+func configPlaceholders(impl *ast.ImplementationBlock) []string {
+	names := []string{}
+	files, _ := impl.Fields["config_files"].([]any)
+	for _, item := range files {
+		if entry, ok := item.([]any); ok && len(entry) == 2 {
+			names = append(names, schema.Placeholders(fmt.Sprint(entry[1]))...)
+		}
+	}
+	return names
+}
+
+// galaxyTemplate writes a config file template in Cheetah: each placeholder
+// becomes the value of its parameter as the command passes it, staged files
+// and directories by their path, and the rest of the text is escaped.
+func galaxyTemplate(template string, params []ast.Parameter, impl *ast.ImplementationBlock) string {
+	return schema.ExpandTemplate(template, func(name string) string {
+		i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == name })
+		if i < 0 {
+			return cheetahEscaper.Replace("${" + name + "}")
+		}
+		variable := strings.TrimPrefix(galaxyVariable(params[i], params), "$")
+		if _, ok := params[i].Metadata["galaxy_data_table"]; ok {
+			return "${" + variable + ".fields.path}"
+		}
+		if staged(params[i]) {
+			return galaxyStagedPath(params[i], params, impl)
+		}
+		return "${" + variable + "}"
+	}, cheetahEscaper.Replace)
+}
+
+// writeConfigfiles adds a configfile for each config file of a docker
+// implementation, and returns the paths of the job working directory where
+// the tool reads them, in the same order. Config files must be written
+// under /data or the guest path of a volume.
+func (g *GalaxyTranspiler) writeConfigfiles(impl *ast.ImplementationBlock, program *ast.Program) ([]string, error) {
+	files, _ := impl.Fields["config_files"].([]any)
+	if len(files) == 0 {
+		return nil, nil
+	}
+	taken := []string{}
+	for _, param := range program.Parameters {
+		taken = append(taken, param.Name)
+	}
+	for _, output := range program.Outputs {
+		taken = append(taken, output.Name)
+	}
+
+	mounts := galaxyMounts(impl)
+	configfiles := &galaxy.Configfiles{}
+	paths := []string{}
+	for _, item := range files {
+		entry, ok := item.([]any)
+		if !ok || len(entry) != 2 {
+			continue
+		}
+		file := path.Clean(fmt.Sprint(entry[0]))
+		work := galaxyWorkPath(file, mounts)
+		if path.IsAbs(work) || work == "." {
+			return nil, fmt.Errorf("config file '%s' must be written under /data or the guest path of a volume", file)
+		}
+		name := galaxyConfigfileName(file, taken)
+		taken = append(taken, name)
+		configfiles.Configfile = append(configfiles.Configfile, galaxy.Configfile{
+			Name:  name,
+			Value: galaxyTemplate(fmt.Sprint(entry[1]), program.Parameters, impl),
+		})
+		paths = append(paths, work)
+	}
+	g.galaxyTool.Configfiles = configfiles
+	return paths, nil
+}
@@ -0,0 +1,76 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+)
+
+// GalaxyDataTablesFile is the file describing the data tables of the
+// tools, next to the .loc.sample files of the tables in tool-data.
+const GalaxyDataTablesFile = "tool_data_table_conf.xml.sample"
+
+// galaxyDataTableColumns are the columns of the data tables the params of
+// galaxy_data_table parameters are selected from, by index.
+var galaxyDataTableColumns = []string{"value", "name", "path"}
+
+// galaxyDataTableFiles returns the sample files of the data tables of the
+// programs, so that admins can install their tools: the
+// tool_data_table_conf.xml.sample declaring the tables, and a
+// tool-data/<table>.loc.sample for each, documenting its columns. Programs
+// without data tables have none.
+func galaxyDataTableFiles(programs []*ast.Program) ([]PackageFile, error) {
+	names := []string{}
+	users := map[string][]string{} // the tools using each table
+	for _, program := range programs {
+		for _, param := range program.Parameters {
+			name, ok := param.Metadata["galaxy_data_table"]
+			if !ok {
+				continue
+			}
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+			if !slices.Contains(users[name], program.Name) {
+				users[name] = append(users[name], program.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	tables := galaxy.Tables{}
+	locs := []PackageFile{}
+	for _, name := range names {
+		tables.Table = append(tables.Table, galaxy.Table{
+			Name:        name,
+			CommentChar: "#",
+			Columns:     strings.Join(galaxyDataTableColumns, ", "),
+			File:        galaxy.TableFile{Path: "tool-data/" + name + ".loc"},
+		})
+		locs = append(locs, PackageFile{Path: "tool-data/" + name + ".loc.sample", Content: locSample(name, users[name])})
+	}
+	content, err := galaxyDocument(tables)
+	if err != nil {
+		return nil, fmt.Errorf("data tables: %w", err)
+	}
+	return append([]PackageFile{{Path: GalaxyDataTablesFile, Content: content}}, locs...), nil
+}
+
+// locSample writes the .loc.sample of a data table, which only documents
+// its lines: admins copy it to the .loc file and add a line for each
+// entry.
+func locSample(name string, tools []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Entries of the %s data table, used by %s.\n", name, strings.Join(tools, ", "))
+	fmt.Fprintf(&b, "# Each line has the tab-separated columns %s:\n", strings.Join(galaxyDataTableColumns, ", "))
+	b.WriteString("#\n")
+	fmt.Fprintf(&b, "#   %s\n", strings.Join(galaxyDataTableColumns, "\\t"))
+	b.WriteString("#\n# e.g. for a reference genome:\n#\n")
+	b.WriteString("#   hg38\\tHuman (hg38)\\t/data/references/hg38/hg38.fa\n")
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package transpiler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// galaxyHelp writes the reStructuredText help of a tool: what it does, from
+// the description of the program, and a list of its inputs and outputs with
+// their descriptions, but the hidden ones. Descriptions are written in
+// Markdown and converted.
+func galaxyHelp(program *ast.Program) string {
+	var sections []string
+	if program.Description != "" {
+		sections = append(sections, "**What it does**\n\n"+markdownToRST(program.Description))
+	}
+	if len(program.Parameters) > 0 {
+		items := []string{}
+		for _, param := range program.Parameters {
+			if galaxyHidden(param) {
+				continue // not in the form
+			}
+			details := []string{param.Type}
+			if param.Type == TypeEnum && len(param.Constraints) > 0 {
+				values := []string{}
+				for _, value := range param.Constraints {
+					values = append(values, "``"+FormatLiteral(value, galaxyLiteralSyntax)+"``")
+				}
+				details = append(details, "one of "+strings.Join(values, ", "))
+			}
+			if param.Default != nil {
+				details = append(details, "default ``"+FormatLiteral(param.Default, galaxyLiteralSyntax)+"``")
+			}
+			items = append(items, helpItem(param.Name, strings.Join(details, ", "), param.Description))
+		}
+		if len(items) > 0 {
+			sections = append(sections, "**Inputs**\n\n"+strings.Join(items, "\n"))
+		}
+	}
+	if len(program.Outputs) > 0 {
+		items := []string{}
+		for _, output := range program.Outputs {
+			items = append(items, helpItem(output.Name, output.Format, output.Description))
+		}
+		sections = append(sections, "**Outputs**\n\n"+strings.Join(items, "\n"))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// helpItem writes an entry of the list of inputs or outputs.
+func helpItem(name, details, description string) string {
+	item := fmt.Sprintf("- **%s** (%s)", name, details)
+	if description != "" {
+		// Continuation lines are indented under the text of the item
+		item += ": " + strings.ReplaceAll(markdownToRST(description), "\n", "\n  ")
+	}
+	return item
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	markdownBullet  = regexp.MustCompile(`^(\s*)[*+-]\s+`)
+	markdownCode    = regexp.MustCompile("`([^`]+)`")
+	markdownLink    = regexp.MustCompile(`!?\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownBold    = regexp.MustCompile(`__([^_]+)__`)
+	markdownItalic  = regexp.MustCompile(`\b_([^_]+)_\b`)
+	blankLines      = regexp.MustCompile(`\n{3,}`)
+)
+
+// rstUnderlines are the characters underlining headings, by level.
+var rstUnderlines = []string{"=", "-", "~", "^", "\"", "'"}
+
+// markdownToRST converts the common Markdown of descriptions, headings,
+// lists, fenced code blocks, code spans, links and emphasis, to
+// reStructuredText. Other text is kept as is.
+func markdownToRST(text string) string {
+	var out []string
+	fenced := false
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if fenced = !fenced; fenced {
+				out = append(out, "", "::", "")
+			} else {
+				out = append(out, "")
+			}
+			continue
+		}
+		if fenced {
+			if line != "" {
+				line = "    " + line
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			title := markdownInline(m[2])
+			out = append(out, "", title, strings.Repeat(rstUnderlines[len(m[1])-1], utf8.RuneCountInString(title)), "")
+			continue
+		}
+		line = markdownBullet.ReplaceAllString(line, "$1- ")
+		out = append(out, markdownInline(line))
+	}
+	// The blank lines around headings and code blocks may double those of
+	// the text
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(out, "\n"), "\n\n"))
+}
+
+// markdownInline converts the inline markup of a line, leaving the text of
+// code spans untouched.
+func markdownInline(line string) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range markdownCode.FindAllStringSubmatchIndex(line, -1) {
+		b.WriteString(markdownText(line[last:span[0]]))
+		b.WriteString("``" + line[span[2]:span[3]] + "``")
+		last = span[1]
+	}
+	b.WriteString(markdownText(line[last:]))
+	return b.String()
+}
+
+// markdownText converts the links of text outside code spans, and the
+// emphasis outside links, which also underline their anonymous targets.
+func markdownText(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, link := range markdownLink.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(markdownEmphasis(text[last:link[0]]))
+		b.WriteString("`" + text[link[2]:link[3]] + " <" + text[link[4]:link[5]] + ">`__")
+		last = link[1]
+	}
+	b.WriteString(markdownEmphasis(text[last:]))
+	return b.String()
+}
+
+// markdownEmphasis converts the underscore emphasis of Markdown, the
+// asterisks being the same in reStructuredText.
+func markdownEmphasis(text string) string {
+	text = markdownBold.ReplaceAllString(text, "**$1**")
+	return markdownItalic.ReplaceAllString(text, "*$1*")
+}
@@ -0,0 +1,142 @@
+package transpiler
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+)
+
+// GalaxyMacrosFile is the file of the macros shared by the tools of a
+// suite.
+const GalaxyMacrosFile = "macros.xml"
+
+// requirementsMacro is the name of the macro of the shared requirements.
+const requirementsMacro = "requirements"
+
+// TranspileSuite implements Suite. Each program is written to <name>.xml,
+// and the requirements of all the tools, when they are the same, and the
+// params defined the same way by several tools are moved to macros.xml,
+// which the tools expand. The data tables of the tools are described in
+// the sample files of galaxyDataTableFiles.
+func (g *GalaxyTranspiler) TranspileSuite(programs []*ast.Program) ([]PackageFile, error) {
+	tools := []*galaxy.Tool{}
+	for _, program := range programs {
+		c := NewGalaxyTranspiler()
+		if err := g.call(context.Background(), &c.TranspilerBase, func() error { return c.buildTool(program) }); err != nil {
+			return nil, fmt.Errorf("%s: %w", program.Name, err)
+		}
+		tools = append(tools, c.galaxyTool)
+	}
+
+	files := []PackageFile{}
+	if macros := factorMacros(tools); macros != nil {
+		content, err := galaxyDocument(macros)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, PackageFile{Path: GalaxyMacrosFile, Content: content})
+	}
+	for _, tool := range tools {
+		content, err := galaxyDocument(tool)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tool.Id, err)
+		}
+		files = append(files, PackageFile{Path: tool.Id + ".xml", Content: content})
+	}
+	tables, err := galaxyDataTableFiles(programs)
+	if err != nil {
+		return nil, err
+	}
+	return append(files, tables...), nil
+}
+
+// galaxyDocument returns an XML document, ending with a newline.
+func galaxyDocument(document any) (string, error) {
+	var b strings.Builder
+	if err := writeGalaxyXML(&b, document); err != nil {
+		return "", err
+	}
+	return b.String() + "\n", nil
+}
+
+// factorMacros moves the definitions shared by the tools to macros, which
+// they expand instead, and returns the macros, nil if nothing is shared.
+func factorMacros(tools []*galaxy.Tool) *galaxy.Macros {
+	if len(tools) < 2 {
+		return nil
+	}
+	macros := &galaxy.Macros{}
+
+	requirements := map[string]int{}
+	for _, tool := range tools {
+		requirements[marshalFragment(tool.Requirements)]++
+	}
+	if len(requirements) == 1 {
+		macros.XML = append(macros.XML, galaxy.Macro{Name: requirementsMacro, Elements: []any{tools[0].Requirements}})
+		for _, tool := range tools {
+			tool.Requirements = nil
+			tool.Expand = append(tool.Expand, galaxy.Expand{Macro: requirementsMacro})
+		}
+	}
+
+	// A param is shared when several tools define it the same way, and
+	// no tool defines it differently
+	definitions := map[string]map[string]int{}
+	order := []string{}
+	for _, tool := range tools {
+		for _, element := range tool.Inputs.Elements {
+			param, ok := element.(galaxy.Param)
+			if !ok {
+				continue
+			}
+			if definitions[param.Name] == nil {
+				definitions[param.Name] = map[string]int{}
+				order = append(order, param.Name)
+			}
+			definitions[param.Name][marshalFragment(param)]++
+		}
+	}
+	shared := map[string]bool{}
+	for _, name := range order {
+		if len(definitions[name]) != 1 {
+			continue
+		}
+		for _, count := range definitions[name] {
+			shared[name] = count > 1
+		}
+	}
+
+	defined := map[string]bool{}
+	for _, tool := range tools {
+		for i, element := range tool.Inputs.Elements {
+			param, ok := element.(galaxy.Param)
+			if !ok || !shared[param.Name] {
+				continue
+			}
+			macro := "param_" + param.Name
+			if !defined[macro] {
+				defined[macro] = true
+				macros.XML = append(macros.XML, galaxy.Macro{Name: macro, Elements: []any{param}})
+			}
+			tool.Inputs.Elements[i] = galaxy.Expand{Macro: macro}
+		}
+	}
+
+	if len(macros.XML) == 0 {
+		return nil
+	}
+	for _, tool := range tools {
+		tool.Macros = &galaxy.Macros{Import: []string{GalaxyMacrosFile}}
+	}
+	return macros
+}
+
+// marshalFragment returns the XML of an element, to compare definitions.
+func marshalFragment(element any) string {
+	data, _ := xml.Marshal(element)
+	return string(data)
+}
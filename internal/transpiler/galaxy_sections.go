@@ -0,0 +1,70 @@
+package transpiler
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+)
+
+var sectionNameInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+
+// galaxySectionName returns the name of the section of a group, its title
+// in lower case with runs of other characters replaced by _, e.g.
+// advanced_options for "Advanced options".
+func galaxySectionName(title string) string {
+	name := strings.Trim(sectionNameInvalid.ReplaceAllString(strings.ToLower(title), "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "section_" + name
+	}
+	return strings.TrimSuffix(name, "_")
+}
+
+// galaxyGroup returns the group of a parameter, from its "group" metadata,
+// or galaxyAdvancedGroup for advanced parameters without one. A parameter
+// depending on another is in the group of that parameter, with the
+// conditional they share.
+func galaxyGroup(param ast.Parameter, params []ast.Parameter) string {
+	if param.When != nil {
+		if i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == param.When.Param }); i >= 0 {
+			param = params[i]
+		}
+	}
+	if group := param.Metadata["group"]; group != "" || !galaxyAdvanced(param) {
+		return group
+	}
+	return galaxyAdvancedGroup
+}
+
+// groupSections moves the inputs of the parameters of each group to a
+// <section>, collapsed in the form, where the first of them was.
+func (g *GalaxyTranspiler) groupSections(params []ast.Parameter) {
+	elements := []any{}
+	sections := map[string]int{} // index of each section in elements, by name
+	for _, element := range g.galaxyTool.Inputs.Elements {
+		name := ""
+		switch element := element.(type) {
+		case galaxy.Param:
+			name = element.Name
+		case galaxy.Conditional:
+			name = element.Param.Name
+		}
+		i := slices.IndexFunc(params, func(p ast.Parameter) bool { return name != "" && p.Name == name })
+		if i < 0 || galaxyGroup(params[i], params) == "" {
+			elements = append(elements, element)
+			continue
+		}
+		title := galaxyGroup(params[i], params)
+		section := galaxySectionName(title)
+		if _, ok := sections[section]; !ok {
+			sections[section] = len(elements)
+			elements = append(elements, galaxy.Section{Name: section, Title: title})
+		}
+		grouped := elements[sections[section]].(galaxy.Section)
+		grouped.Elements = append(grouped.Elements, element)
+		elements[sections[section]] = grouped
+	}
+	g.galaxyTool.Inputs.Elements = elements
+}
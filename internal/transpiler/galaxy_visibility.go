@@ -0,0 +1,52 @@
+package transpiler
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
+)
+
+// galaxyAdvancedGroup is the group of the advanced parameters without one
+// of their own, a collapsed section of the form.
+const galaxyAdvancedGroup = "Advanced options"
+
+// galaxyHidden reports whether a parameter is a hidden param of the form,
+// fixed to its default. Datasets and data table selections can't be
+// hidden, and are shown with the advanced parameters instead.
+func galaxyHidden(param ast.Parameter) bool {
+	if param.Metadata["hidden"] != "true" || param.Metadata["galaxy_data_table"] != "" {
+		return false
+	}
+	switch param.Type {
+	case TypeFile, TypeDirectory, TypeSamplesheet:
+		return false
+	}
+	return true
+}
+
+// galaxyAdvanced reports whether a parameter is in the advanced section,
+// when it has no group of its own.
+func galaxyAdvanced(param ast.Parameter) bool {
+	return param.Metadata["advanced"] == "true" || param.Metadata["hidden"] == "true" && !galaxyHidden(param)
+}
+
+// hideParams replaces the params of the hidden parameters with hidden
+// params holding their default, empty for optional parameters.
+func (g *GalaxyTranspiler) hideParams(params []ast.Parameter) {
+	for i, element := range g.galaxyTool.Inputs.Elements {
+		element, ok := element.(galaxy.Param)
+		if !ok {
+			continue
+		}
+		j := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == element.Name })
+		if j < 0 || !galaxyHidden(params[j]) {
+			continue
+		}
+		hidden := galaxy.Param{Type: "hidden", Name: element.Name}
+		if params[j].Default != nil {
+			hidden.Value = FormatLiteral(params[j].Default, galaxyLiteralSyntax)
+		}
+		g.galaxyTool.Inputs.Elements[i] = hidden
+	}
+}
@@ -0,0 +1,36 @@
+package transpiler
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Localize returns a copy of a program whose parameters are described in a
+// locale, from their (label (<locale> "...")) translations, so the Galaxy
+// labels and the help of the command lines are in the language of the
+// users. It also returns the described parameters without a translation,
+// which keep their description.
+func Localize(program *ast.Program, locale string) (*ast.Program, []ast.Parameter) {
+	if locale == "" {
+		return program, nil
+	}
+	localized := *program
+	localized.Parameters = slices.Clone(program.Parameters)
+	missing := []ast.Parameter{}
+	for i, param := range localized.Parameters {
+		label, ok := param.Label(locale)
+		if !ok {
+			if param.Description != "" {
+				missing = append(missing, param)
+			}
+			continue
+		}
+		param.Description = label
+		param.Metadata = maps.Clone(param.Metadata)
+		param.Metadata["desc"] = label
+		localized.Parameters[i] = param
+	}
+	return &localized, missing
+}
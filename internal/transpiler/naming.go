@@ -0,0 +1,105 @@
+package transpiler
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/naming"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/schema"
+)
+
+// targetProgram returns a copy of the program with each parameter renamed
+// to its identifier in the target, following the scheme of package naming,
+// along with the references to it. The program is returned as is when no
+// parameter is renamed. Only Nextflow splits samplesheets into their
+// samples, the other targets pass them as files.
+func targetProgram(lang string, program *ast.Program) *ast.Program {
+	if lang != "nextflow" {
+		program = samplesheetFiles(program)
+	}
+	renames := map[string]string{}
+	for _, param := range program.Parameters {
+		if name := naming.TargetName(lang, param.Name, param.Metadata); name != param.Name {
+			renames[param.Name] = name
+		}
+	}
+	if len(renames) == 0 {
+		return program
+	}
+
+	renamed := *program
+	renamed.Parameters = slices.Clone(program.Parameters)
+	for i, param := range renamed.Parameters {
+		if name, ok := renames[param.Name]; ok {
+			renamed.Parameters[i].Name = name
+		}
+	}
+
+	renamed.Implementations = slices.Clone(program.Implementations)
+	for i, impl := range renamed.Implementations {
+		renamed.Implementations[i] = renameReferences(impl, renames)
+	}
+	return &renamed
+}
+
+// renameReferences returns a copy of an implementation block with the
+// parameter references renamed.
+func renameReferences(impl ast.ImplementationBlock, renames map[string]string) ast.ImplementationBlock {
+	implSchema, _ := schema.Lookup(impl.Name)
+
+	// Only identifiers reference parameters, literals with the same text
+	// are kept.
+	referenced := map[string]map[string]bool{}
+	impl.References = slices.Clone(impl.References)
+	for i, ref := range impl.References {
+		if name, ok := renames[ref.Name]; ok {
+			if referenced[ref.Field] == nil {
+				referenced[ref.Field] = map[string]bool{}
+			}
+			referenced[ref.Field][ref.Name] = true
+			impl.References[i].Name = name
+		}
+	}
+	rename := func(field string, value any) any {
+		if s, ok := value.(string); ok && referenced[field][s] {
+			return renames[s]
+		}
+		return value
+	}
+
+	fields := make(map[string]any, len(impl.Fields))
+	for name, value := range impl.Fields {
+		field, _ := implSchema.Field(name)
+		items, ok := value.([]any)
+		if !ok || referenced[name] == nil {
+			fields[name] = value
+			continue
+		}
+		items = slices.Clone(items)
+		for i, item := range items {
+			switch field.Kind {
+			case schema.FieldList:
+				items[i] = rename(name, item)
+			case schema.FieldPairs:
+				entry, ok := item.([]any)
+				if ok && field.PairReference != schema.NoReference && field.PairReference < len(entry) {
+					entry = slices.Clone(entry)
+					entry[field.PairReference] = rename(name, entry[field.PairReference])
+					items[i] = entry
+				}
+				if ok && len(entry) > 0 && field.Templates {
+					if template, isString := entry[len(entry)-1].(string); isString {
+						entry = slices.Clone(entry)
+						entry[len(entry)-1] = schema.ExpandTemplate(template, func(placeholder string) string {
+							return "${" + rename(name, placeholder).(string) + "}"
+						}, func(text string) string { return text })
+						items[i] = entry
+					}
+				}
+			}
+		}
+		fields[name] = items
+	}
+	impl.Fields = fields
+	return impl
+}
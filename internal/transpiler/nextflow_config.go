@@ -0,0 +1,156 @@
+package transpiler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// NextflowConfigFile is the configuration Nextflow reads from the launch
+// directory of a pipeline.
+const NextflowConfigFile = "nextflow.config"
+
+// NextflowMainScript is the script of a pipeline package.
+const NextflowMainScript = "main.nf"
+
+// TranspilePackage implements Packaged: the pipeline, as main.nf, with a
+// nextflow.config declaring its params, its container and the docker,
+// singularity, podman and conda profiles, e.g. nextflow run . -profile docker. An
+// nf-core module comes with its meta.yml and environment.yml instead.
+func (n *NextflowTranspiler) TranspilePackage(program *ast.Program) ([]PackageFile, error) {
+	var code strings.Builder
+	c := NewNextflowTranspiler()
+	if err := n.call(context.Background(), &c.TranspilerBase, func() error { return c.transpile(&code, program) }); err != nil {
+		return nil, err
+	}
+	if c.nfCore() {
+		return nfCorePackage(targetProgram("nextflow", program), code.String()), nil
+	}
+	program = targetProgram("nextflow", program)
+	files := []PackageFile{
+		{Path: NextflowMainScript, Content: code.String()},
+		{Path: NextflowConfigFile, Content: nextflowConfig(program, c.useSchema(), c.engine())},
+	}
+	if c.useSchema() {
+		schema, err := nextflowSchemaJSON(program)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, PackageFile{Path: NextflowSchemaFile, Content: schema})
+	}
+	return files, nil
+}
+
+// nextflowConfig returns the nextflow.config of a pipeline. The container
+// of each process is declared again, so that a config can replace it, and a
+// conda package is derived from BioContainers images for the conda profile.
+// The nf-schema plugin is declared when it validates the params, and the
+// containers are declared for the engine of the pipeline. The work
+// directory of the tasks is set when it is requested.
+func nextflowConfig(program *ast.Program, schema bool, engine string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Nextflow configuration: %s\n\n", program.Name)
+
+	sb.WriteString("manifest {\n")
+	fmt.Fprintf(&sb, "  name = %s\n", groovyString(program.Name))
+	if program.Description != "" {
+		fmt.Fprintf(&sb, "  description = %s\n", groovyString(FormatDescription(program.Description)))
+	}
+	if author := program.Metadata["author"]; author != "" {
+		fmt.Fprintf(&sb, "  author = %s\n", groovyString(author))
+	}
+	if version := program.Metadata["version"]; version != "" {
+		fmt.Fprintf(&sb, "  version = %s\n", groovyString(version))
+	}
+	fmt.Fprintf(&sb, "  mainScript = %s\n", groovyString(NextflowMainScript))
+	sb.WriteString("}\n\n")
+
+	if schema {
+		sb.WriteString("plugins {\n")
+		fmt.Fprintf(&sb, "  id %s\n", groovyString(nextflowSchemaPlugin))
+		sb.WriteString("}\n\n")
+	}
+
+	sb.WriteString("params {\n")
+	for _, param := range program.Parameters {
+		fmt.Fprintf(&sb, "  %s = %s\n", param.Name, nextflowDefault(param))
+	}
+	sb.WriteString("  outdir = 'results'\n")
+	sb.WriteString("}\n")
+
+	// The work directory can't be set by a process
+	if workDir, ok := program.Resource(ast.ResourceWorkDir); ok {
+		fmt.Fprintf(&sb, "\nworkDir = %s\n", groovyString(fmt.Sprint(workDir)))
+	}
+
+	// Each step runs in the container of its implementation
+	steps, _ := nextflowSteps(program)
+	selectors := []string{}
+	for _, step := range steps {
+		container, ok := step.Impl.Fields["image"].(string)
+		if !ok || container == "" {
+			continue
+		}
+		var selector strings.Builder
+		fmt.Fprintf(&selector, "  withName: %s {\n", groovyString(step.Name))
+		fmt.Fprintf(&selector, "    container = %s\n", groovyString(nextflowContainer(container, engine)))
+		if pkg := biocondaPackage(container); pkg != "" {
+			fmt.Fprintf(&selector, "    conda = %s\n", groovyString(pkg))
+		} else {
+			selector.WriteString("    // No conda package is known for the image, the conda profile runs the tool from the PATH\n")
+		}
+		selector.WriteString("  }\n")
+		selectors = append(selectors, selector.String())
+	}
+	if len(selectors) > 0 {
+		sb.WriteString("\nprocess {\n")
+		sb.WriteString(strings.Join(selectors, ""))
+		sb.WriteString("}\n")
+	}
+
+	sb.WriteString("\nprofiles {\n")
+	sb.WriteString("  docker {\n")
+	sb.WriteString("    docker.enabled = true\n")
+	sb.WriteString("    docker.runOptions = '-u $(id -u):$(id -g)'\n")
+	sb.WriteString("  }\n")
+	sb.WriteString("  singularity {\n")
+	sb.WriteString("    singularity.enabled = true\n")
+	sb.WriteString("    singularity.autoMounts = true\n")
+	sb.WriteString("  }\n")
+	sb.WriteString("  podman {\n")
+	sb.WriteString("    podman.enabled = true\n")
+	sb.WriteString("  }\n")
+	sb.WriteString("  conda {\n")
+	sb.WriteString("    conda.enabled = true\n")
+	sb.WriteString("  }\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// biocontainerTag matches the tags of BioContainers images: the version of
+// the package, then a build on quay.io, e.g. 1.17--h00cdaf9_0, or a
+// container revision on Docker Hub, e.g. v0.7.17_cv1.
+var biocontainerTag = regexp.MustCompile(`^v?([0-9][^-_]*)(--.+|_cv[0-9]+)?$`)
+
+// biocondaPackage returns the Bioconda package of a BioContainers image,
+// e.g. bioconda::samtools=1.17 for quay.io/biocontainers/samtools:1.17--h00cdaf9_0,
+// or "" for other images.
+func biocondaPackage(reference string) string {
+	ref, err := image.Parse(reference)
+	if err != nil {
+		return ""
+	}
+	name, ok := strings.CutPrefix(ref.Path, "biocontainers/")
+	if !ok || strings.Contains(name, "/") || name == "biocontainers" {
+		return ""
+	}
+	m := biocontainerTag.FindStringSubmatch(ref.Tag)
+	if m == nil {
+		return ""
+	}
+	return "bioconda::" + name + "=" + m[1]
+}
@@ -0,0 +1,33 @@
+package transpiler
+
+import (
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// nextflowEngine selects the container engine the containers of the
+// processes are declared for: "docker" keeps the image reference,
+// "singularity" pulls it from its registry with a docker:// URI and
+// "podman", without a default registry, gets the qualified reference.
+const nextflowEngine = "engine"
+
+// engine returns the container engine of the pipeline.
+func (n *NextflowTranspiler) engine() string {
+	return n.option(nextflowEngine, "docker")
+}
+
+// nextflowContainer returns the container of a process for an engine.
+// References that don't parse are kept, they are reported by the
+// image-reference rule.
+func nextflowContainer(reference, engine string) string {
+	ref, err := image.Parse(reference)
+	if err != nil {
+		return reference
+	}
+	switch engine {
+	case "singularity":
+		return "docker://" + ref.Qualified().String()
+	case "podman":
+		return ref.Qualified().String()
+	}
+	return reference
+}
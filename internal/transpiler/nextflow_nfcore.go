@@ -0,0 +1,345 @@
+package transpiler
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
+)
+
+// Nextflow target options.
+const (
+	// nextflowStyle selects what is written: "pipeline" a runnable
+	// pipeline, "nf-core" a module following the nf-core guidelines.
+	nextflowStyle = "style"
+)
+
+// Files of an nf-core module, besides its main.nf.
+const (
+	NFCoreMetaFile        = "meta.yml"
+	NFCoreEnvironmentFile = "environment.yml"
+)
+
+// nfCoreVariables are the variables of the script of an nf-core module,
+// which parameters can't be named after.
+var nfCoreVariables = []string{"meta", "args", "task"}
+
+// TargetOptions implements Configurable.
+func (n *NextflowTranspiler) TargetOptions() []TargetOption {
+	return []TargetOption{
+		{Name: nextflowStyle, Values: []string{"pipeline", "nf-core"}, Default: "pipeline",
+			Help: "write a runnable pipeline, or a module following the nf-core guidelines"},
+		{Name: nextflowValidate, Values: []string{"checks", "nf-schema"}, Default: "checks",
+			Help: "check the required params, or validate them all against nextflow_schema.json with nf-schema"},
+		{Name: nextflowEngine, Values: []string{"docker", "singularity", "podman"}, Default: "docker",
+			Help: "the container engine the containers of a pipeline are declared for"},
+	}
+}
+
+// nfCore reports whether the program is written as an nf-core module.
+func (n *NextflowTranspiler) nfCore() bool {
+	return n.option(nextflowStyle, "pipeline") == "nf-core"
+}
+
+// nfCoreProcessName returns the name of the process of an nf-core module,
+// in upper case, e.g. ALIGN_READS.
+func nfCoreProcessName(program *ast.Program) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(program.Name))
+}
+
+// nfCoreMetaInput returns the index of the parameter that comes with the
+// meta map of the sample, the first required file, or -1.
+func nfCoreMetaInput(params []ast.Parameter) int {
+	return slices.IndexFunc(params, func(p ast.Parameter) bool {
+		return p.Type == TypeFile && p.Metadata["optional"] != "true" && p.When == nil
+	})
+}
+
+// writeModule writes the process of a docker implementation as an nf-core
+// module: the meta map of the sample comes with the first required file
+// and every output, the arguments of task.ext.args follow the executable,
+// and the script and stub write the version of the tool to versions.yml.
+func (n *NextflowTranspiler) writeModule(impl *ast.ImplementationBlock, program *ast.Program, reference string) error {
+	for _, param := range program.Parameters {
+		if slices.Contains(nfCoreVariables, param.Name) {
+			return fmt.Errorf("parameter '%s' clashes with the %s variable of nf-core modules", param.Name, param.Name)
+		}
+	}
+	outputs, capture, err := nextflowOutputs(program)
+	if err != nil {
+		return err
+	}
+	label := nextflowLabel(program)
+	if label == "" {
+		label = "process_single"
+	}
+
+	n.WriteLine("process %s {", nfCoreProcessName(program))
+	n.SetIndentLevel(n.GetIndentLevel() + 1)
+	n.WriteLine(`tag "$meta.id"`)
+	n.WriteLine("label %s", groovyString(label))
+	n.WriteLine("")
+	n.WriteLine(`conda "${moduleDir}/%s"`, NFCoreEnvironmentFile)
+	if singularity := singularityImage(reference); singularity != "" {
+		n.WriteLine(`container "${ workflow.containerEngine == 'singularity' && !task.ext.singularity_pull_docker_container ?`)
+		n.WriteLine("  %s :", groovyString(singularity))
+		n.WriteLine(`  %s }"`, groovyString(reference))
+	} else {
+		n.WriteLine("container %s", groovyString(reference))
+	}
+	n.writeResources(program)
+
+	n.WriteLine("")
+	n.WriteLine("input:")
+	meta := nfCoreMetaInput(program.Parameters)
+	if meta < 0 {
+		n.WriteLine("val meta")
+	}
+	for i, param := range program.Parameters {
+		qualifier := "val"
+		if param.Type == TypeFile || param.Type == TypeDirectory {
+			qualifier = "path"
+		}
+		if i == meta {
+			n.WriteLine("tuple val(meta), path(%s)", param.Name)
+			continue
+		}
+		n.WriteLine("%s %s", qualifier, param.Name)
+	}
+
+	n.WriteLine("")
+	n.WriteLine("output:")
+	for _, output := range outputs {
+		n.WriteLine("tuple val(meta), path(%s), emit: %s", groovyString(output.Path), output.Name)
+	}
+	n.WriteLine("path 'versions.yml', emit: versions")
+
+	n.WriteLine("")
+	n.WriteLine("when:")
+	n.WriteLine("task.ext.when == null || task.ext.when")
+
+	versions := nfCoreVersions(impl, reference)
+	n.WriteLine("")
+	n.WriteLine("script:")
+	n.WriteLine("def args = task.ext.args ?: ''")
+	n.WriteLine(`"""`)
+	command := nfCoreCommand(impl, program.Parameters)
+	if dirs := nextflowOutputDirs(outputs); len(dirs) > 0 {
+		command = gstringText("mkdir -p "+strings.Join(dirs, " ")) + " && " + command
+	}
+	if capture != "" {
+		command += " > " + nextflowShellWord(capture)
+	}
+	n.WriteLine("%s", command)
+	n.WriteLine("")
+	n.writeVersions(versions)
+	n.WriteLine(`"""`)
+
+	n.WriteLine("")
+	n.WriteLine("stub:")
+	n.WriteLine(`"""`)
+	if stub := nextflowStub(outputs); stub != "" {
+		n.WriteLine("%s", gstringText(stub))
+		n.WriteLine("")
+	}
+	n.writeVersions(versions)
+	n.WriteLine(`"""`)
+
+	n.SetIndentLevel(n.GetIndentLevel() - 1)
+	n.WriteLine("}")
+	return nil
+}
+
+// nfCoreCommand returns the command line of the script of a module, with
+// the arguments of task.ext.args after the executable and its
+// subcommands, the literal words before the first option, parameter or
+// path, or at the end when the command doesn't start with a literal word.
+func nfCoreCommand(impl *ast.ImplementationBlock, params []ast.Parameter) string {
+	command := nextflowCommand(impl, params)
+	args, _ := impl.Fields["arguments"].([]any)
+	leading := []string{}
+	for _, arg := range args {
+		argStr, ok := arg.(string)
+		if !ok || argStr == "_" {
+			continue
+		}
+		isParam := slices.ContainsFunc(params, func(p ast.Parameter) bool { return p.Name == argStr })
+		if isParam || path.IsAbs(argStr) || strings.HasPrefix(argStr, "-") || strings.ContainsAny(argStr, " \t\n\r") {
+			break
+		}
+		leading = append(leading, gstringText(argStr))
+	}
+	if len(leading) == 0 {
+		return strings.TrimSpace(command + " $args")
+	}
+	prefix := strings.Join(leading, " ")
+	return prefix + " $args" + strings.TrimPrefix(command, prefix)
+}
+
+// nfCoreVersions returns the tool of a module and the shell expression of
+// its version: the version command of the implementation, or the version
+// in the tag of its image.
+func nfCoreVersions(impl *ast.ImplementationBlock, reference string) [2]string {
+	tool := ""
+	if args, _ := impl.Fields["arguments"].([]any); len(args) > 0 {
+		if first, ok := args[0].(string); ok {
+			tool = path.Base(first)
+		}
+	}
+	ref, _ := image.Parse(reference)
+	if tool == "" || tool == "." || tool == "/" {
+		tool = path.Base(ref.Path)
+	}
+	if command, ok := impl.Fields["version_command"].(string); ok && command != "" {
+		return [2]string{tool, `\$(` + gstringText(command) + ")"}
+	}
+	version := ref.Tag
+	if m := biocontainerTag.FindStringSubmatch(ref.Tag); m != nil {
+		version = m[1]
+	}
+	if version == "" {
+		version = image.DefaultTag
+	}
+	return [2]string{tool, gstringText(version)}
+}
+
+// writeVersions writes the versions.yml of a module, keyed by the name of
+// the process.
+func (n *NextflowTranspiler) writeVersions(versions [2]string) {
+	n.WriteLine("cat <<-END_VERSIONS > versions.yml")
+	n.WriteLine(`"${task.process}":`)
+	n.WriteLine("    %s: %s", versions[0], versions[1])
+	n.WriteLine("END_VERSIONS")
+}
+
+// singularityImage returns the Singularity image the Galaxy depot builds
+// for a BioContainers image of quay.io, or "".
+func singularityImage(reference string) string {
+	ref, err := image.Parse(reference)
+	if err != nil || biocondaPackage(reference) == "" || !strings.Contains(ref.Tag, "--") {
+		return ""
+	}
+	return "https://depot.galaxyproject.org/singularity/" + path.Base(ref.Path) + ":" + ref.Tag
+}
+
+// nfCoreMetaType returns the type of an input or output in meta.yml.
+func nfCoreMetaType(typ string) string {
+	switch typ {
+	case TypeFile, TypeDirectory, TypeBoolean, TypeInteger:
+		return typ
+	case TypeNumber:
+		return "float"
+	}
+	return "string"
+}
+
+// nfCoreMeta returns the meta.yml of a module, describing its tool, its
+// inputs and its outputs, with the versions.yml every module emits.
+func nfCoreMeta(program *ast.Program, impl *ast.ImplementationBlock, reference string) string {
+	var sb strings.Builder
+	entry := func(name, typ, description, pattern string) {
+		fmt.Fprintf(&sb, "  - %s:\n", name)
+		fmt.Fprintf(&sb, "      type: %s\n", typ)
+		// Every entry is described, by its name at least
+		if description == "" {
+			description = name
+		}
+		fmt.Fprintf(&sb, "      description: %s\n", strconv.Quote(FormatDescription(description)))
+		if pattern != "" {
+			fmt.Fprintf(&sb, "      pattern: %s\n", strconv.Quote(pattern))
+		}
+	}
+	metaMap := "Groovy Map containing sample information, e.g. [ id:'sample1' ]"
+
+	description := program.Description
+	if description == "" {
+		description = program.Name
+	}
+	fmt.Fprintf(&sb, "name: %s\n", strconv.Quote(strings.ToLower(nfCoreProcessName(program))))
+	fmt.Fprintf(&sb, "description: %s\n", strconv.Quote(FormatDescription(description)))
+	sb.WriteString("keywords:\n")
+	keywords := []string{strings.ToLower(nfCoreProcessName(program))}
+	for _, keyword := range strings.Split(program.Metadata["keywords"], ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" && !slices.Contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	for _, keyword := range keywords {
+		fmt.Fprintf(&sb, "  - %s\n", strconv.Quote(keyword))
+	}
+
+	tool := nfCoreVersions(impl, reference)[0]
+	sb.WriteString("tools:\n")
+	fmt.Fprintf(&sb, "  - %s:\n", strconv.Quote(tool))
+	fmt.Fprintf(&sb, "      description: %s\n", strconv.Quote(FormatDescription(description)))
+	if homepage := program.Metadata["homepage"]; homepage != "" {
+		fmt.Fprintf(&sb, "      homepage: %s\n", strconv.Quote(homepage))
+	}
+	if license := program.Metadata["license"]; license != "" {
+		fmt.Fprintf(&sb, "      licence: [%s]\n", strconv.Quote(license))
+	}
+
+	sb.WriteString("input:\n")
+	entry("meta", "map", metaMap, "")
+	for _, param := range program.Parameters {
+		entry(param.Name, nfCoreMetaType(param.Type), param.Description, "")
+	}
+
+	// The outputs are described with their paths in the task work directory
+	outputs, _, _ := nextflowOutputs(program)
+	sb.WriteString("output:\n")
+	entry("meta", "map", metaMap, "")
+	for _, output := range outputs {
+		typ := TypeFile
+		if output.Format == TypeDirectory && !strings.ContainsAny(path.Base(output.Path), "*?[") {
+			typ = TypeDirectory
+		}
+		entry(output.Name, typ, output.Description, output.Path)
+	}
+	entry("versions", TypeFile, "File containing software versions", "versions.yml")
+
+	if author := program.Metadata["author"]; author != "" {
+		sb.WriteString("authors:\n")
+		fmt.Fprintf(&sb, "  - %s\n", strconv.Quote(author))
+		sb.WriteString("maintainers:\n")
+		fmt.Fprintf(&sb, "  - %s\n", strconv.Quote(author))
+	}
+	return sb.String()
+}
+
+// nfCoreEnvironment returns the environment.yml of a module, with the
+// Bioconda package of its image, if it is a BioContainers image.
+func nfCoreEnvironment(reference string) string {
+	var sb strings.Builder
+	sb.WriteString("channels:\n")
+	sb.WriteString("  - conda-forge\n")
+	sb.WriteString("  - bioconda\n")
+	if pkg := biocondaPackage(reference); pkg != "" {
+		sb.WriteString("dependencies:\n")
+		fmt.Fprintf(&sb, "  - %s\n", pkg)
+	} else {
+		sb.WriteString("# No conda package is known for the image\n")
+		sb.WriteString("dependencies: []\n")
+	}
+	return sb.String()
+}
+
+// nfCorePackage returns the files of an nf-core module, from the code of
+// its main.nf.
+func nfCorePackage(program *ast.Program, code string) []PackageFile {
+	i := slices.IndexFunc(program.Implementations, func(b ast.ImplementationBlock) bool { return b.Name == "run_docker" })
+	impl := &ast.ImplementationBlock{Fields: map[string]any{}}
+	if i >= 0 {
+		impl = &program.Implementations[i]
+	}
+	reference, _ := impl.Fields["image"].(string)
+	return []PackageFile{
+		{Path: NextflowMainScript, Content: code},
+		{Path: NFCoreMetaFile, Content: nfCoreMeta(program, impl, reference)},
+		{Path: NFCoreEnvironmentFile, Content: nfCoreEnvironment(reference)},
+	}
+}
@@ -0,0 +1,89 @@
+package transpiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// nextflowSamplesheet returns the samplesheet parameter of a pipeline, if
+// any. The process runs on each of its samples, so a pipeline reads a
+// single, required, samplesheet, and the meta map of the samples is named
+// meta.
+func nextflowSamplesheet(program *ast.Program) (*ast.Parameter, error) {
+	var samplesheet *ast.Parameter
+	for i, param := range program.Parameters {
+		if param.Type != TypeSamplesheet {
+			continue
+		}
+		if samplesheet != nil {
+			return nil, fmt.Errorf("samplesheets '%s' and '%s': a pipeline reads its samples from a single samplesheet", samplesheet.Name, param.Name)
+		}
+		if !nextflowRequired(param) {
+			return nil, fmt.Errorf("samplesheet '%s' must be required, the process runs on each of its samples", param.Name)
+		}
+		samplesheet = &program.Parameters[i]
+	}
+	if samplesheet != nil && slices.ContainsFunc(program.Parameters, func(p ast.Parameter) bool { return p.Name == "meta" }) {
+		return nil, fmt.Errorf("parameter 'meta' clashes with the meta map of the samples of '%s'", samplesheet.Name)
+	}
+	return samplesheet, nil
+}
+
+// nextflowSampleID returns the column of the id of the samples of a
+// samplesheet, the first one which isn't a path, or "" without one.
+func nextflowSampleID(samplesheet ast.Parameter) string {
+	for _, column := range samplesheet.Columns() {
+		if !nextflowPathColumn(column) {
+			return column.Name
+		}
+	}
+	return ""
+}
+
+func nextflowPathColumn(column ast.Column) bool {
+	return column.Type == TypeFile || column.Type == TypeDirectory
+}
+
+// nextflowSampleTuple returns the closure mapping a row of a samplesheet to
+// the tuple of a sample: its meta map, with the id and the other columns
+// which aren't paths, and the list of its files, checked to exist.
+func nextflowSampleTuple(samplesheet ast.Parameter) string {
+	meta, files := []string{}, []string{}
+	id := nextflowSampleID(samplesheet)
+	for _, column := range samplesheet.Columns() {
+		value := "row." + column.Name
+		switch column.Type {
+		case TypeFile:
+			files = append(files, "file("+value+", checkIfExists: true)")
+			continue
+		case TypeDirectory:
+			files = append(files, "file("+value+", type: 'dir', checkIfExists: true)")
+			continue
+		case TypeInteger:
+			value += " as Integer"
+		case TypeNumber:
+			value += " as Double"
+		case TypeBoolean:
+			value += ".toBoolean()"
+		}
+		key := column.Name
+		if key == id {
+			key = "id"
+		}
+		meta = append(meta, key+": "+value)
+	}
+	return fmt.Sprintf("{ row -> tuple([%s], [%s]) }", strings.Join(meta, ", "), strings.Join(files, ", "))
+}
+
+// writeSamplesheetChannel writes the channel of the samples of a
+// samplesheet: its rows, read with their header, mapped to tuples.
+func (n *NextflowTranspiler) writeSamplesheetChannel(samplesheet ast.Parameter) {
+	n.WriteLine("%s_ch = Channel.fromPath(params.%s, checkIfExists: true)", samplesheet.Name, samplesheet.Name)
+	n.SetIndentLevel(n.GetIndentLevel() + 1)
+	n.WriteLine(".splitCsv(header: true)")
+	n.WriteLine(".map %s", nextflowSampleTuple(samplesheet))
+	n.SetIndentLevel(n.GetIndentLevel() - 1)
+}
@@ -0,0 +1,169 @@
+package transpiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// NextflowSchemaFile is the JSON schema of the params of a pipeline, read
+// by the nf-schema plugin.
+const NextflowSchemaFile = "nextflow_schema.json"
+
+// nextflowSchemaPlugin is the version of nf-schema validating the params.
+const nextflowSchemaPlugin = "nf-schema@2.3.0"
+
+// nextflowValidate selects how params are validated: "checks" writes the
+// checks of the required params, "nf-schema" validates them all against
+// nextflow_schema.json with the nf-schema plugin.
+const nextflowValidate = "validate"
+
+// useSchema reports whether the params are validated by nf-schema.
+func (n *NextflowTranspiler) useSchema() bool {
+	return n.option(nextflowValidate, "checks") == "nf-schema"
+}
+
+type nextflowSchema struct {
+	Schema      string                         `json:"$schema"`
+	Title       string                         `json:"title"`
+	Description string                         `json:"description,omitempty"`
+	Type        string                         `json:"type"`
+	Defs        map[string]nextflowSchemaGroup `json:"$defs"`
+	AllOf       []map[string]string            `json:"allOf"`
+}
+
+type nextflowSchemaGroup struct {
+	Title      string                   `json:"title"`
+	Type       string                   `json:"type"`
+	Required   []string                 `json:"required,omitempty"`
+	Properties nextflowSchemaProperties `json:"properties"`
+}
+
+type nextflowSchemaProperty struct {
+	Name        string   `json:"-"`
+	Type        string   `json:"type"`
+	Format      string   `json:"format,omitempty"`
+	Exists      bool     `json:"exists,omitempty"`
+	Mimetype    string   `json:"mimetype,omitempty"`
+	Enum        []any    `json:"enum,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Minimum     *float64 `json:"minimum,omitempty"`
+	Maximum     *float64 `json:"maximum,omitempty"`
+	MinLength   int      `json:"minLength,omitempty"`
+	MaxLength   int      `json:"maxLength,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Hidden      bool     `json:"hidden,omitempty"`
+}
+
+// nextflowSchemaProperties keeps the properties in the order of the
+// parameters, which nf-schema follows in the help.
+type nextflowSchemaProperties []nextflowSchemaProperty
+
+func (p nextflowSchemaProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, property := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(property.Name)
+		value, err := json.Marshal(property)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// nextflowSchemaJSON returns the nextflow_schema.json of a pipeline: the
+// type of each param, with its allowed values, range, pattern and default,
+// and the required params. Advanced params are in a group of their own,
+// and hidden ones are left out of the help.
+func nextflowSchemaJSON(program *ast.Program) (string, error) {
+	group := nextflowSchemaGroup{Title: "Input/output options", Type: "object"}
+	advanced := nextflowSchemaGroup{Title: "Advanced options", Type: "object"}
+	for _, param := range program.Parameters {
+		in := &group
+		if param.Metadata["advanced"] == "true" {
+			in = &advanced
+		}
+		if nextflowRequired(param) {
+			in.Required = append(in.Required, param.Name)
+		}
+		in.Properties = append(in.Properties, nextflowSchemaParam(param))
+	}
+	group.Properties = append(group.Properties, nextflowSchemaProperty{
+		Name: "outdir", Type: "string", Format: "directory-path", Default: "results",
+		Description: "Directory the outputs are published to",
+	})
+
+	schema := nextflowSchema{
+		Schema:      "https://json-schema.org/draft/2020-12/schema",
+		Title:       program.Name + " parameters",
+		Description: FormatDescription(program.Description),
+		Type:        "object",
+		Defs:        map[string]nextflowSchemaGroup{"input_output_options": group},
+		AllOf:       []map[string]string{{"$ref": "#/$defs/input_output_options"}},
+	}
+	if len(advanced.Properties) > 0 {
+		schema.Defs["advanced_options"] = advanced
+		schema.AllOf = append(schema.AllOf, map[string]string{"$ref": "#/$defs/advanced_options"})
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(schema); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// nextflowSchemaParam returns the property of the param of a parameter.
+// Files may be globs, matching the inputs of several tasks, while a
+// samplesheet is a single CSV file.
+func nextflowSchemaParam(param ast.Parameter) nextflowSchemaProperty {
+	property := nextflowSchemaProperty{
+		Name:        param.Name,
+		Type:        "string",
+		Default:     param.Default,
+		Description: FormatDescription(param.Description),
+		Hidden:      param.Metadata["hidden"] == "true",
+	}
+	switch param.Type {
+	case TypeInteger, TypeNumber, TypeBoolean:
+		property.Type = param.Type
+	case TypeFile:
+		property.Format = "file-path-pattern"
+	case TypeDirectory:
+		property.Format, property.Exists = "directory-path", true
+	case TypeSamplesheet:
+		property.Format, property.Exists, property.Mimetype = "file-path", true, "text/csv"
+		property.Pattern = `^\S+\.csv$`
+	case TypeEnum:
+		property.Enum = param.Constraints
+	case TypeCharacter:
+		property.MinLength, property.MaxLength = 1, 1
+	}
+	if param.Type == TypeBoolean && param.Default == nil {
+		property.Default = false
+	}
+	switch param.Type {
+	case TypeInteger, TypeNumber:
+		for key, bound := range map[string]**float64{"min": &property.Minimum, "max": &property.Maximum} {
+			if value, err := strconv.ParseFloat(param.Metadata[key], 64); err == nil {
+				*bound = &value
+			}
+		}
+	case TypeString:
+		property.Pattern = param.Metadata["pattern"]
+	}
+	return property
+}
@@ -0,0 +1,113 @@
+package transpiler
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// nextflowStep is the process of a docker implementation of a pipeline.
+type nextflowStep struct {
+	Name    string
+	Impl    *ast.ImplementationBlock
+	Params  []ast.Parameter   // the parameters it takes
+	Inputs  []nextflowInput   // the outputs of earlier steps it reads
+	Outputs []ast.OutputBlock // its outputs, with their paths in the task work directory
+	Capture string            // the file its standard output is written to, if any
+}
+
+// nextflowInput is an output of an earlier step read by a step, staged at
+// its path in the task work directory.
+type nextflowInput struct {
+	From   string
+	Output ast.OutputBlock
+}
+
+// stageAs returns where the input is staged: the path of the output, or
+// the files of a glob in its directory, under their own names.
+func (i nextflowInput) stageAs() string {
+	if strings.ContainsAny(path.Base(i.Output.Path), "*?[") {
+		return path.Join(path.Dir(i.Output.Path), "*")
+	}
+	return i.Output.Path
+}
+
+// nextflowSteps returns the processes of the docker implementations of a
+// program, which run in order as they share /data. A single one is named
+// after the program and takes every parameter. Several are named
+// <program>_step<n>, take the parameters of their arguments and each
+// output goes to the first step whose arguments write to its path, or the
+// last one. Later steps whose arguments read that path take it as an
+// input, wired to the output channel.
+func nextflowSteps(program *ast.Program) ([]nextflowStep, error) {
+	impls := []*ast.ImplementationBlock{}
+	for i := range program.Implementations {
+		if program.Implementations[i].Name == "run_docker" {
+			impls = append(impls, &program.Implementations[i])
+		}
+	}
+	outputs, capture, err := nextflowOutputs(program)
+	if err != nil {
+		return nil, err
+	}
+	if len(impls) == 0 {
+		return nil, nil
+	}
+	if len(impls) == 1 {
+		return []nextflowStep{{Name: program.Name, Impl: impls[0], Params: program.Parameters, Outputs: outputs, Capture: capture}}, nil
+	}
+
+	steps := make([]nextflowStep, len(impls))
+	paths := make([][]string, len(impls))
+	for i, impl := range impls {
+		steps[i].Name = fmt.Sprintf("%s_step%d", program.Name, i+1)
+		steps[i].Impl = impl
+		args, _ := impl.Fields["arguments"].([]any)
+		for _, param := range program.Parameters {
+			if slices.Contains(args, any(param.Name)) {
+				steps[i].Params = append(steps[i].Params, param)
+			}
+		}
+		for _, arg := range args {
+			if argStr, ok := arg.(string); ok && path.IsAbs(argStr) {
+				paths[i] = append(paths[i], galaxyWorkPath(path.Clean(argStr), galaxyMounts(impl)))
+			}
+		}
+	}
+	last := len(steps) - 1
+	steps[last].Capture = capture
+
+	for _, output := range outputs {
+		at := func(p string) bool { return outputAt(output, p) }
+		producer := last
+		if output.Path != capture {
+			if i := slices.IndexFunc(paths, func(p []string) bool { return slices.ContainsFunc(p, at) }); i >= 0 {
+				producer = i
+			}
+		}
+		steps[producer].Outputs = append(steps[producer].Outputs, output)
+		for j := producer + 1; j < len(steps); j++ {
+			if slices.ContainsFunc(paths[j], at) {
+				steps[j].Inputs = append(steps[j].Inputs, nextflowInput{From: steps[producer].Name, Output: output})
+			}
+		}
+	}
+	return steps, nil
+}
+
+// outputAt reports whether a path in the task work directory is, or is in,
+// an output: its path, a file under a directory output, or the directory
+// of a glob or a file matching it.
+func outputAt(output ast.OutputBlock, p string) bool {
+	if p == output.Path || output.Format == TypeDirectory && strings.HasPrefix(p, output.Path+"/") {
+		return true
+	}
+	if !strings.ContainsAny(path.Base(output.Path), "*?[") {
+		return false
+	}
+	matched, _ := path.Match(output.Path, p)
+	return matched || p == path.Dir(output.Path)
+}
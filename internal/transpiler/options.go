@@ -0,0 +1,74 @@
+package transpiler
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Configurable is implemented by the transpilers with target options, such
+// as the parameter models of the Python target.
+type Configurable interface {
+	// TargetOptions describes the options of the target.
+	TargetOptions() []TargetOption
+	// SetOption sets an option, already checked against its description.
+	SetOption(name, value string) error
+}
+
+// TargetOption describes an option of a target.
+type TargetOption struct {
+	Name    string
+	Values  []string // the accepted values, any when empty
+	Default string
+	Help    string
+}
+
+// ApplyOptions sets the options of a transpiler, by name.
+func ApplyOptions(t Transpiler, lang string, options map[string]string) error {
+	if len(options) == 0 {
+		return nil
+	}
+	configurable, ok := t.(Configurable)
+	if !ok {
+		return fmt.Errorf("target '%s' has no options", lang)
+	}
+	described := configurable.TargetOptions()
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		i := slices.IndexFunc(described, func(o TargetOption) bool { return o.Name == name })
+		if i < 0 {
+			names := make([]string, len(described))
+			for j, o := range described {
+				names[j] = o.Name
+			}
+			return fmt.Errorf("unknown option '%s' for target '%s', expected one of %s",
+				name, lang, strings.Join(names, ", "))
+		}
+		value := options[name]
+		if values := described[i].Values; len(values) > 0 && !slices.Contains(values, value) {
+			return fmt.Errorf("invalid value '%s' for option '%s' of target '%s', expected one of %s",
+				value, name, lang, strings.Join(values, ", "))
+		}
+		if err := configurable.SetOption(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetOption sets an option of the transpiler.
+func (t *TranspilerBase) SetOption(name, value string) error {
+	if t.options == nil {
+		t.options = map[string]string{}
+	}
+	t.options[name] = value
+	return nil
+}
+
+// option returns the value of an option, or its default when it isn't set.
+func (t *TranspilerBase) option(name, def string) string {
+	if value, ok := t.options[name]; ok {
+		return value
+	}
+	return def
+}
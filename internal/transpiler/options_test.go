@@ -0,0 +1,41 @@
+package transpiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func transpileWithOptions(t *testing.T, lang string, options map[string]string, program *ast.Program) string {
+	t.Helper()
+	descriptor, _ := GetTranspiler(lang)
+	tr := descriptor.Initializer()
+	if err := ApplyOptions(tr, lang, options); err != nil {
+		t.Fatalf("%s: ApplyOptions() unexpected error: %v", lang, err)
+	}
+	code, err := tr.Transpile(program)
+	if err != nil {
+		t.Fatalf("%s: Transpile() unexpected error: %v", lang, err)
+	}
+	return code
+}
+
+func TestApplyOptions_Errors(t *testing.T) {
+	tests := []struct {
+		lang     string
+		options  map[string]string
+		expected string
+	}{
+		{"python", map[string]string{"color": "red"}, "unknown option 'color' for target 'python'"},
+		{"python", map[string]string{pythonModels: "attrs"}, "invalid value 'attrs' for option 'models'"},
+		{"streamflow", map[string]string{"x": "y"}, "target 'streamflow' has no options"},
+	}
+	for _, tt := range tests {
+		descriptor, _ := GetTranspiler(tt.lang)
+		err := ApplyOptions(descriptor.Initializer(), tt.lang, tt.options)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("ApplyOptions(%q, %v) error = %v, want %q", tt.lang, tt.options, err, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package transpiler
+
+import (
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// PackageFile is a file of the package of a program.
+type PackageFile struct {
+	Path    string // relative to the package directory, with slashes
+	Content string
+}
+
+// Packaged is implemented by the transpilers that can write a program as
+// an installable package, rather than as a single file.
+type Packaged interface {
+	// TranspilePackage returns the files of the package of a program.
+	TranspilePackage(program *ast.Program) ([]PackageFile, error)
+}
+
+// Suite is implemented by the transpilers that write the programs of a
+// directory together, sharing what they have in common.
+type Suite interface {
+	// TranspileSuite returns the files of a suite of programs.
+	TranspileSuite(programs []*ast.Program) ([]PackageFile, error)
+}
@@ -0,0 +1,163 @@
+package transpiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// PluginPrefix is the name prefix of plugin executables: an executable
+// named baryon-snakemake in a plugin directory adds the "snakemake" target.
+const PluginPrefix = "baryon-"
+
+// PluginPathEnv lists additional plugin directories, separated as in PATH.
+const PluginPathEnv = "BARYON_PLUGIN_PATH"
+
+// Plugin describes a transpiler implemented by an external command, which
+// reads the program as JSON (see docs/ast-json.md) on its standard input
+// and writes the generated code to its standard output.
+type Plugin struct {
+	Name      string
+	Display   string
+	Extension string
+	Command   []string
+	// Language is the DSL version the plugin understands, the current one
+	// when empty.
+	Language string
+}
+
+// plugins holds the names of the targets registered by RegisterPlugin.
+var plugins = map[string]bool{}
+
+// RegisterPlugin registers a plugin as a target. Plugins may replace other
+// plugins but not the built-in targets.
+func RegisterPlugin(p Plugin) error {
+	if _, exists := transpilerRegistry[p.Name]; exists && !plugins[p.Name] {
+		return fmt.Errorf("plugin '%s' conflicts with the built-in target of the same name", p.Name)
+	}
+	if len(p.Command) == 0 {
+		return fmt.Errorf("plugin '%s' has no command", p.Name)
+	}
+	if p.Language != "" {
+		if _, err := parseVersion(p.Language); err != nil {
+			return fmt.Errorf("plugin '%s': %w", p.Name, err)
+		}
+	}
+	if p.Display == "" {
+		p.Display = p.Name
+	}
+	plugins[p.Name] = true
+	RegisterTranspiler(p.Name, &TranspilerDescriptor{
+		Extension:   p.Extension,
+		Display:     p.Display,
+		Initializer: func() Transpiler { return NewPluginTranspiler(p) },
+		Language:    p.Language,
+	})
+	return nil
+}
+
+// IsPlugin reports whether a target is implemented by a plugin.
+func IsPlugin(lang string) bool {
+	return plugins[lang]
+}
+
+// DiscoverPlugins finds the plugin executables in dirs, skipping the
+// directories that don't exist. The target of baryon-<name>[.ext] is name,
+// and its output extension defaults to ".<name>".
+func DiscoverPlugins(dirs []string) ([]Plugin, error) {
+	found := []Plugin{}
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading plugin directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), PluginPrefix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+			info, err := entry.Info()
+			if err != nil || info.Mode()&0111 == 0 || name == "" || seen[name] {
+				continue // not executable, or shadowed by an earlier directory
+			}
+			seen[name] = true
+			found = append(found, Plugin{
+				Name:      name,
+				Extension: "." + name,
+				Command:   []string{filepath.Join(dir, entry.Name())},
+			})
+		}
+	}
+	return found, nil
+}
+
+// PluginDirs returns the directories listed in BARYON_PLUGIN_PATH.
+func PluginDirs() []string {
+	return filepath.SplitList(os.Getenv(PluginPathEnv))
+}
+
+// PluginTranspiler runs a plugin command for each transpilation.
+type PluginTranspiler struct {
+	TranspilerBase
+	plugin Plugin
+}
+
+func NewPluginTranspiler(p Plugin) *PluginTranspiler {
+	t := &PluginTranspiler{plugin: p}
+	t.Initialize()
+	return t
+}
+
+// Transpile implements Transpiler.
+func (t *PluginTranspiler) Transpile(program *ast.Program) (string, error) {
+	return transpileToString(t, program)
+}
+
+// TranspileTo implements Transpiler. The command also receives the target
+// name and the AST schema version in the BARYON_TARGET and
+// BARYON_AST_SCHEMA_VERSION environment variables.
+func (t *PluginTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return t.transpileContext(context.Background(), w, program)
+}
+
+func (t *PluginTranspiler) transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error {
+	input, err := json.Marshal(program)
+	if err != nil {
+		return fmt.Errorf("encoding AST: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.plugin.Command[0], t.plugin.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"BARYON_TARGET="+t.plugin.Name,
+		fmt.Sprintf("BARYON_AST_SCHEMA_VERSION=%d", ast.SchemaVersion))
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("plugin '%s': %w: %s", t.plugin.Name, err, msg)
+		}
+		return fmt.Errorf("plugin '%s': %w", t.plugin.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package transpiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func unregister(t *testing.T, name string) {
+	t.Cleanup(func() {
+		delete(transpilerRegistry, name)
+		delete(plugins, name)
+	})
+}
+
+func TestDiscoverPlugins(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writePlugin(t, first, "baryon-snakemake", "cat")
+	writePlugin(t, second, "baryon-snakemake", "cat")
+	writePlugin(t, second, "baryon-cwl.sh", "cat")
+	if err := os.WriteFile(filepath.Join(second, "baryon-notes"), []byte("not executable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writePlugin(t, second, "other-tool", "cat")
+
+	found, err := DiscoverPlugins([]string{first, filepath.Join(first, "missing"), second})
+	if err != nil {
+		t.Fatalf("DiscoverPlugins() unexpected error: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("DiscoverPlugins() = %+v, want snakemake and cwl", found)
+	}
+	if found[0].Name != "snakemake" || found[0].Command[0] != filepath.Join(first, "baryon-snakemake") {
+		t.Errorf("expected the first directory to take precedence, got %+v", found[0])
+	}
+	if found[1].Name != "cwl" || found[1].Extension != ".cwl" {
+		t.Errorf("unexpected plugin %+v", found[1])
+	}
+}
+
+func TestPluginTranspiler(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "baryon-echo", `grep -o '"name":"[a-z]*"' | head -n 1; echo "target=$BARYON_TARGET schema=$BARYON_AST_SCHEMA_VERSION"`)
+	writePlugin(t, dir, "baryon-broken", `echo "unsupported field" >&2; exit 3`)
+	unregister(t, "echo")
+	unregister(t, "broken")
+
+	found, err := DiscoverPlugins([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range found {
+		if err := RegisterPlugin(p); err != nil {
+			t.Fatalf("RegisterPlugin() unexpected error: %v", err)
+		}
+	}
+
+	program := compatibilityProgram()
+	program.Name = "align"
+	descriptor, err := GetTranspiler("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := descriptor.Initializer().Transpile(program)
+	if err != nil {
+		t.Fatalf("Transpile() unexpected error: %v", err)
+	}
+	want := "\"name\":\"" + program.Name + "\"\ntarget=echo schema=1\n"
+	if out != want {
+		t.Errorf("Transpile() = %q, want %q", out, want)
+	}
+
+	descriptor, _ = GetTranspiler("broken")
+	_, err = descriptor.Initializer().Transpile(program)
+	if err == nil || !strings.Contains(err.Error(), "unsupported field") {
+		t.Errorf("Transpile() expected the plugin error output, got %v", err)
+	}
+}
+
+func TestPluginTranspiler_Register(t *testing.T) {
+	plugin := NewPluginTranspiler(Plugin{Name: "echo", Command: []string{"cat"}})
+	var tr Transpiler = plugin
+	tr.RegisterImplementationHandler("run_singularity",
+		func(BaseTranspiler, *ast.ImplementationBlock, *ast.Program) error { return nil })
+	tr.RegisterTypeValidator("file", func(BaseTranspiler, ast.Parameter) error { return nil })
+	if _, ok := plugin.GetImplementationHandlers()["run_singularity"]; !ok {
+		t.Error("expected the registered handler")
+	}
+	if _, ok := plugin.GetTypeValidators()["file"]; !ok {
+		t.Error("expected the registered type validator")
+	}
+}
+
+func TestRegisterPlugin_BuiltinConflict(t *testing.T) {
+	if err := RegisterPlugin(Plugin{Name: "r", Command: []string{"cat"}}); err == nil {
+		t.Error("RegisterPlugin() expected error when shadowing a built-in target")
+	}
+	if _, ok := GetTranspiler("r"); ok != nil || IsPlugin("r") {
+		t.Error("the built-in target was replaced")
+	}
+}
+
+func TestRegisterPlugin_InvalidLanguage(t *testing.T) {
+	if err := RegisterPlugin(Plugin{Name: "old", Command: []string{"cat"}, Language: "one"}); err == nil {
+		t.Error("RegisterPlugin() expected error for an invalid language version")
+	}
+	if IsPlugin("old") {
+		t.Error("the plugin was registered")
+	}
+}
@@ -0,0 +1,104 @@
+package transpiler
+
+import (
+	"context"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Annotated is implemented by the transpilers that can point the generated
+// code back to the lines of the program it comes from.
+type Annotated interface {
+	// SetProvenance enables "# baryon: <source>:<line>" comments, naming
+	// the program file source, or disables them when source is empty.
+	SetProvenance(source string)
+	// SourceMappings returns the generated line ranges of the last
+	// transpilation, with the constructs they come from.
+	SourceMappings() []SourceMapping
+}
+
+// SourceMapping maps a range of generated lines to a program construct.
+// Ranges nest: the construct of a line is the one of its innermost range.
+type SourceMapping struct {
+	Generated LineRange    `json:"generated"`
+	Source    ast.Position `json:"source"`
+	Construct string       `json:"construct"` // e.g. "parameter input"
+}
+
+// LineRange is an inclusive range of lines, starting at 1.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SourceMapVersion is the version of the SourceMap format.
+const SourceMapVersion = 1
+
+// SourceMap is the sidecar file describing where the lines of a generated
+// file come from.
+type SourceMap struct {
+	Version  int             `json:"version"`
+	File     string          `json:"file"`   // the generated file
+	Source   string          `json:"source"` // the program, relative to the source map
+	Mappings []SourceMapping `json:"mappings"`
+}
+
+// SetProvenance implements Annotated. The R and Python transpilers annotate
+// the parameter validation and argument construction, the others ignore it.
+func (t *TranspilerBase) SetProvenance(source string) {
+	t.provenance = source
+}
+
+// SourceMappings implements Annotated.
+func (t *TranspilerBase) SourceMappings() []SourceMapping {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mappings
+}
+
+// Annotation is the provenance of a single TranspileContext call and the
+// source mappings it generated, for a transpiler shared by the calls on
+// different programs.
+type Annotation struct {
+	// Provenance replaces the source set with SetProvenance, when not
+	// empty.
+	Provenance string
+	// Mappings are set by the call, as returned by SourceMappings.
+	Mappings []SourceMapping
+}
+
+type annotationKey struct{}
+
+// WithAnnotation returns a context whose TranspileContext calls annotate
+// the code as a describes, and record their source mappings in a.
+func WithAnnotation(ctx context.Context, a *Annotation) context.Context {
+	return context.WithValue(ctx, annotationKey{}, a)
+}
+
+// markSource writes the provenance comment of the construct at pos, and
+// starts its source mapping, see sourceSpan.
+func (t *TranspilerBase) markSource(pos ast.Position, construct string) (done func()) {
+	if t.provenance != "" && pos.Line > 0 {
+		t.WriteLine("# baryon: %s:%d", t.provenance, pos.Line)
+	}
+	return t.sourceSpan(pos, construct)
+}
+
+// sourceSpan maps the lines written until done is called to the construct
+// at pos. Lines rendered for a section template are not mapped, as the
+// template may move them.
+func (t *TranspilerBase) sourceSpan(pos ast.Position, construct string) (done func()) {
+	if t.capturing || pos.Line == 0 {
+		return func() {}
+	}
+	start := t.line + 1
+	return func() {
+		if t.line >= start {
+			t.mappings = append(t.mappings, SourceMapping{
+				Generated: LineRange{Start: start, End: t.line},
+				Source:    pos,
+				Construct: construct,
+			})
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package transpiler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func TestSetProvenance(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{{
+			NamedBaseNode: ast.NamedBaseNode{Name: "input", BaseNode: ast.BaseNode{Pos: ast.Position{Line: 3}}},
+			Type:          TypeFile,
+		}},
+		Implementations: []ast.ImplementationBlock{{
+			Name:     "run_docker",
+			Fields:   map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input"}},
+			FieldPos: map[string]ast.Position{"arguments": {Line: 7}},
+		}},
+	}
+
+	for _, tt := range []struct {
+		lang string
+		next []string // lines following the comments of line 3 and 7
+	}{
+		{"r", []string{"if (missing(input))", "additional_arguments = c("}},
+		{"python", []string{"if not isinstance(input, str):", "docker_args.append(input_filename)"}},
+	} {
+		descriptor, _ := GetTranspiler(tt.lang)
+		plain, _ := descriptor.Initializer().Transpile(program)
+		if strings.Contains(plain, "# baryon:") {
+			t.Errorf("%s: provenance comments are emitted by default", tt.lang)
+		}
+
+		tr := descriptor.Initializer()
+		tr.(Annotated).SetProvenance("tool.bala")
+		code, err := tr.Transpile(program)
+		if err != nil {
+			t.Fatalf("%s: Transpile() unexpected error: %v", tt.lang, err)
+		}
+		for i, line := range []string{"# baryon: tool.bala:3", "# baryon: tool.bala:7"} {
+			_, after, found := strings.Cut(code, line+"\n")
+			if !found || !strings.HasPrefix(strings.TrimSpace(after), tt.next[i]) {
+				t.Errorf("%s: expected %q before %q in:\n%s", tt.lang, line, tt.next[i], code)
+			}
+		}
+	}
+}
+
+func TestSourceMappings(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{{
+			NamedBaseNode: ast.NamedBaseNode{Name: "input", BaseNode: ast.BaseNode{Pos: ast.Position{Line: 3}}},
+			Type:          TypeFile,
+		}},
+		Implementations: []ast.ImplementationBlock{{
+			BaseNode: ast.BaseNode{Pos: ast.Position{Line: 5}},
+			Name:     "run_docker",
+			Fields:   map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input"}},
+			FieldPos: map[string]ast.Position{"arguments": {Line: 7}},
+		}},
+	}
+
+	for _, tt := range []struct {
+		lang  string
+		first map[string]string // first generated line of each construct
+	}{
+		{"r", map[string]string{
+			"implementation run_docker": "",
+			"parameter input":           "if (missing(input))",
+			"arguments of run_docker":   "additional_arguments = c(",
+		}},
+		{"python", map[string]string{
+			"implementation run_docker": "",
+			"parameter input":           "if not isinstance(input, str):",
+			"arguments of run_docker":   "docker_args.append(input_filename)",
+		}},
+	} {
+		descriptor, _ := GetTranspiler(tt.lang)
+		for _, source := range []string{"", "tool.bala"} {
+			tr := descriptor.Initializer()
+			tr.(Annotated).SetProvenance(source)
+			code, err := tr.Transpile(program)
+			if err != nil {
+				t.Fatalf("%s: Transpile() unexpected error: %v", tt.lang, err)
+			}
+			lines := strings.Split(code, "\n")
+
+			mappings := tr.(Annotated).SourceMappings()
+			if len(mappings) != len(tt.first) {
+				t.Errorf("%s: SourceMappings() = %+v, want %d mappings", tt.lang, mappings, len(tt.first))
+			}
+			for _, m := range mappings {
+				prefix, ok := tt.first[m.Construct]
+				if !ok || m.Generated.Start > m.Generated.End || m.Generated.End > len(lines) {
+					t.Errorf("%s: unexpected mapping %+v", tt.lang, m)
+					continue
+				}
+				if got := strings.TrimSpace(lines[m.Generated.Start-1]); !strings.HasPrefix(got, prefix) {
+					t.Errorf("%s: %s starts at line %q, want %q", tt.lang, m.Construct, got, prefix)
+				}
+			}
+		}
+	}
+}
+
+func TestWithAnnotation(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{{
+			NamedBaseNode: ast.NamedBaseNode{Name: "input", BaseNode: ast.BaseNode{Pos: ast.Position{Line: 3}}},
+			Type:          TypeFile,
+		}},
+		Implementations: []ast.ImplementationBlock{{
+			BaseNode: ast.BaseNode{Pos: ast.Position{Line: 5}},
+			Name:     "run_docker",
+			Fields:   map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input"}},
+		}},
+	}
+	descriptor, _ := GetTranspiler("python")
+	tr := descriptor.Initializer()
+
+	// A transpiler shared by the calls on different programs
+	sources := []string{"a.bala", "b.bala", "c.bala", "d.bala"}
+	codes := make([]strings.Builder, len(sources))
+	annotations := make([]Annotation, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		annotations[i].Provenance = source
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithAnnotation(context.Background(), &annotations[i])
+			if err := TranspileContext(ctx, tr, &codes[i], program); err != nil {
+				t.Errorf("%s: TranspileContext() unexpected error: %v", source, err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i, source := range sources {
+		if code := codes[i].String(); strings.Count(code, "# baryon: ") != strings.Count(code, "# baryon: "+source+":") {
+			t.Errorf("%s: expected only its own provenance comments in:\n%s", source, code)
+		}
+		if len(annotations[i].Mappings) != 3 {
+			t.Errorf("%s: Mappings = %+v, want the parameter, the implementation and its arguments", source, annotations[i].Mappings)
+		}
+	}
+	if code, _ := tr.Transpile(program); strings.Contains(code, "# baryon:") {
+		t.Errorf("the annotations changed the provenance of the transpiler:\n%s", code)
+	}
+}
@@ -0,0 +1,24 @@
+package transpiler
+
+import (
+	"slices"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// samplesheetFiles returns a copy of the program with its samplesheet
+// parameters declared as files, for the targets running the tool on the
+// whole samplesheet. The program is returned as is without samplesheets.
+func samplesheetFiles(program *ast.Program) *ast.Program {
+	if !slices.ContainsFunc(program.Parameters, func(p ast.Parameter) bool { return p.Type == TypeSamplesheet }) {
+		return program
+	}
+	files := *program
+	files.Parameters = slices.Clone(program.Parameters)
+	for i, param := range files.Parameters {
+		if param.Type == TypeSamplesheet {
+			files.Parameters[i].Type = TypeFile
+		}
+	}
+	return &files
+}
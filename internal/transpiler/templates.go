@@ -0,0 +1,116 @@
+package transpiler
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+// Section names of the generated code that templates can override.
+const (
+	SectionHeader = "header" // start of the file: shebang, imports, helpers
+	SectionDocker = "docker" // the container invocation
+	SectionResult = "result" // handling of the results
+)
+
+// Templated is implemented by the transpilers whose output sections can be
+// replaced by text/template overrides.
+type Templated interface {
+	// TemplateSections lists the sections that can be overridden.
+	TemplateSections() []string
+	// SetTemplate parses the template overriding a section.
+	SetTemplate(section, text string) error
+}
+
+// SectionData is the data the section templates are executed with.
+type SectionData struct {
+	Target         string
+	Program        *ast.Program
+	Implementation *ast.ImplementationBlock // nil outside implementation blocks
+	Image          string                   // container image of the implementation
+	// Default is the code the section generates without a template, so
+	// that templates can extend it rather than replace it.
+	Default string
+}
+
+// templateFuncs are available to every section template.
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  strings.Join,
+}
+
+// ApplyTemplates sets the section templates of a transpiler, by section
+// name.
+func ApplyTemplates(t Transpiler, lang string, templates map[string]string) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	templated, ok := t.(Templated)
+	if !ok {
+		return fmt.Errorf("target '%s' does not support templates", lang)
+	}
+	sections := templated.TemplateSections()
+	for _, section := range slices.Sorted(maps.Keys(templates)) {
+		if !slices.Contains(sections, section) {
+			return fmt.Errorf("unknown template section '%s' for target '%s', expected one of %s",
+				section, lang, strings.Join(sections, ", "))
+		}
+		if err := templated.SetTemplate(section, templates[section]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTemplate parses the template overriding a section.
+func (t *TranspilerBase) SetTemplate(section, text string) error {
+	tmpl, err := template.New(section).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("parsing template '%s': %w", section, err)
+	}
+	if t.templates == nil {
+		t.templates = map[string]*template.Template{}
+	}
+	t.templates[section] = tmpl
+	return nil
+}
+
+// writeSection writes a section with write, or with its template when one
+// is set. The template output is indented like the section.
+func (t *TranspilerBase) writeSection(section string, data SectionData, write func()) {
+	tmpl, ok := t.templates[section]
+	if !ok {
+		write()
+		return
+	}
+
+	// Render the default at indentation 0: the template output is
+	// re-indented as a whole
+	out, level := t.out, t.IndentLevel
+	var def bytes.Buffer
+	t.out, t.IndentLevel, t.capturing = &def, 0, true
+	write()
+	t.out, t.IndentLevel, t.capturing = out, level, false
+	data.Default = strings.TrimSuffix(def.String(), "\n")
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		if t.writeErr == nil {
+			t.writeErr = fmt.Errorf("executing template '%s': %w", section, err)
+		}
+		return
+	}
+	text := strings.TrimSuffix(rendered.String(), "\n")
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		t.WriteLine("%s", line)
+	}
+}
@@ -0,0 +1,87 @@
+package transpiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func templateProgram() *ast.Program {
+	return &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "input"}, Type: TypeFile},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name:   "run_docker",
+			Fields: map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input"}},
+		}},
+	}
+}
+
+func transpileWithTemplates(t *testing.T, lang string, templates map[string]string) (string, error) {
+	t.Helper()
+	descriptor, _ := GetTranspiler(lang)
+	tr := descriptor.Initializer()
+	if err := ApplyTemplates(tr, lang, templates); err != nil {
+		t.Fatalf("%s: ApplyTemplates() unexpected error: %v", lang, err)
+	}
+	return tr.Transpile(templateProgram())
+}
+
+func TestTemplates_DefaultIsIdentity(t *testing.T) {
+	identity := map[string]string{
+		SectionHeader: "{{.Default}}\n",
+		SectionDocker: "{{.Default}}",
+		SectionResult: "{{.Default}}\n",
+	}
+	for _, lang := range []string{"r", "python", "bash"} {
+		want, _ := transpileWithTemplates(t, lang, nil)
+		got, err := transpileWithTemplates(t, lang, identity)
+		if err != nil {
+			t.Fatalf("%s: Transpile() unexpected error: %v", lang, err)
+		}
+		if got != want {
+			t.Errorf("%s: {{.Default}} templates changed the output:\n%s\nwant:\n%s", lang, got, want)
+		}
+	}
+}
+
+func TestTemplates_Override(t *testing.T) {
+	code, err := transpileWithTemplates(t, "python", map[string]string{
+		SectionDocker: "log_start({{quote .Image}})\n{{.Default}}",
+		SectionResult: `return Result(status="done", output_dir={{quote .Program.Name}})`,
+	})
+	if err != nil {
+		t.Fatalf("Transpile() unexpected error: %v", err)
+	}
+	// The template output is indented like the section it replaces
+	if !strings.Contains(code, "\n    log_start(\"ubuntu:22.04\")\n    \n    # Run Docker container\n") {
+		t.Errorf("docker template not applied:\n%s", code)
+	}
+	if !strings.Contains(code, "\n    return Result(status=\"done\", output_dir=\"tool\")\n") ||
+		strings.Contains(code, "# Create results directory") {
+		t.Errorf("result template not applied:\n%s", code)
+	}
+}
+
+func TestApplyTemplates_Errors(t *testing.T) {
+	descriptor, _ := GetTranspiler("r")
+	if err := ApplyTemplates(descriptor.Initializer(), "r", map[string]string{"footer": ""}); err == nil ||
+		!strings.Contains(err.Error(), "unknown template section 'footer'") {
+		t.Errorf("ApplyTemplates() expected unknown section error, got %v", err)
+	}
+	if err := ApplyTemplates(descriptor.Initializer(), "r", map[string]string{SectionHeader: "{{.Default"}); err == nil {
+		t.Error("ApplyTemplates() expected parse error")
+	}
+	descriptor, _ = GetTranspiler("galaxy")
+	if err := ApplyTemplates(descriptor.Initializer(), "galaxy", map[string]string{SectionHeader: ""}); err == nil {
+		t.Error("ApplyTemplates() expected error for a target without templates")
+	}
+
+	_, err := transpileWithTemplates(t, "bash", map[string]string{SectionHeader: "{{.Missing}}"})
+	if err == nil || !strings.Contains(err.Error(), "executing template 'header'") {
+		t.Errorf("Transpile() expected template execution error, got %v", err)
+	}
+}
@@ -2,8 +2,13 @@ package transpiler
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"maps"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
 	"slices"
@@ -13,6 +18,11 @@ type TranspilerDescriptor struct {
 	Extension   string
 	Display     string
 	Initializer func() Transpiler
+	// Capabilities declares the supported features, nil when unknown.
+	Capabilities *Capabilities
+	// Language is the DSL version the target implements, LanguageVersion
+	// when empty. Newer features fail, see NegotiateFeatures.
+	Language string
 }
 
 var transpilerRegistry map[string]*TranspilerDescriptor = map[string]*TranspilerDescriptor{}
@@ -21,15 +31,17 @@ func RegisterTranspiler(lang string, t *TranspilerDescriptor) {
 	transpilerRegistry[lang] = t
 }
 
-// GetTranspiler retrieves a registered transpiler by language name.
+// GetTranspilerNames returns the registered language names, sorted.
 func GetTranspilerNames() []string {
 	names := make([]string, 0, len(transpilerRegistry))
 	for lang := range transpilerRegistry {
 		names = append(names, lang)
 	}
+	slices.Sort(names)
 	return names
 }
 
+// GetTranspiler retrieves a registered transpiler by language name.
 func GetTranspiler(lang string) (*TranspilerDescriptor, error) {
 	t, exists := transpilerRegistry[lang]
 	if !exists {
@@ -39,20 +51,26 @@ func GetTranspiler(lang string) (*TranspilerDescriptor, error) {
 }
 
 const (
-	TypeString    = "string"
-	TypeNumber    = "number"
-	TypeInteger   = "integer"
-	TypeBoolean   = "boolean"
-	TypeEnum      = "enum"
-	TypeFile      = "file"
-	TypeDirectory = "directory"
-	TypeCharacter = "character"
+	TypeString      = ast.TypeString
+	TypeNumber      = ast.TypeNumber
+	TypeInteger     = ast.TypeInteger
+	TypeBoolean     = ast.TypeBoolean
+	TypeEnum        = ast.TypeEnum
+	TypeFile        = ast.TypeFile
+	TypeDirectory   = ast.TypeDirectory
+	TypeCharacter   = ast.TypeCharacter
+	TypeSamplesheet = ast.TypeSamplesheet
 )
 
-// Transpiler defines the interface for all language transpilers.
+// Transpiler defines the interface for all language transpilers. The
+// built-in transpilers can run Transpile and TranspileTo concurrently once
+// their handlers, validators and templates are set.
 type Transpiler interface {
 	// Transpile converts a Baryon program AST to target language code.
 	Transpile(program *ast.Program) (string, error)
+	// TranspileTo writes the target language code of a program to w, as it
+	// is generated.
+	TranspileTo(w io.Writer, program *ast.Program) error
 	// RegisterImplementationHandler adds a custom implementation handler.
 	RegisterImplementationHandler(name string, handler ImplementationHandler)
 	// RegisterTypeValidator adds a custom type validator.
@@ -84,15 +102,77 @@ type BaseTranspiler interface {
 
 // TranspilerBase implements BaseTranspiler and provides common functionality
 type TranspilerBase struct {
-	IndentLevel    int
-	Buffer         bytes.Buffer
-	ImplHandlers   map[string]ImplementationHandler
-	TypeValidators map[string]TypeValidator
+	IndentLevel int
+	Buffer      bytes.Buffer
+	// implHandlers and typeValidators are set by the Register methods
+	// only, which record them in registrations.
+	implHandlers   map[string]ImplementationHandler
+	typeValidators map[string]TypeValidator
+	// out receives the lines instead of Buffer while set.
+	out      io.Writer
+	writeErr error
+	// templates override sections of the output, see SetTemplate.
+	templates map[string]*template.Template
+	// provenance names the program file in provenance comments.
+	provenance string
+	// options holds the target options, see SetOption.
+	options map[string]string
+	// line counts the lines written to out, for the source mappings.
+	line      int
+	mappings  []SourceMapping
+	capturing bool
+	// ctx is the context of the call, see canceled.
+	ctx context.Context
+	// registrations replays the handlers and validators registered on a
+	// transpiler on the transpilers of its calls, see call.
+	registrations []func(*TranspilerBase)
+	// mu guards mappings of the transpiler, set by its calls.
+	mu sync.Mutex
 }
 
 func (t *TranspilerBase) WriteLine(format string, args ...any) {
 	indent := strings.Repeat("  ", t.IndentLevel)
-	fmt.Fprintf(&t.Buffer, indent+format+"\n", args...)
+	var w io.Writer = &t.Buffer
+	if t.out != nil {
+		w = t.out
+	}
+	if t.writeErr == nil {
+		line := fmt.Sprintf(indent+format+"\n", args...)
+		if !t.capturing {
+			t.line += strings.Count(line, "\n")
+		}
+		_, t.writeErr = io.WriteString(w, line)
+	}
+}
+
+// SetOutput directs the lines written by WriteLine to w, or back to Buffer
+// when w is nil, and clears the indentation and any previous write error.
+// Setting a new output also clears the source mappings.
+func (t *TranspilerBase) SetOutput(w io.Writer) {
+	t.out = w
+	t.writeErr = nil
+	t.IndentLevel = 0
+	t.Buffer.Reset()
+	if w != nil {
+		t.line = 0
+		t.mappings = nil
+	}
+}
+
+// OutputError returns the first error writing to the output set with
+// SetOutput.
+func (t *TranspilerBase) OutputError() error {
+	return t.writeErr
+}
+
+// transpileToString collects the output of TranspileTo, for the Transpile
+// method of transpilers that stream their output.
+func transpileToString(t Transpiler, program *ast.Program) (string, error) {
+	var sb strings.Builder
+	if err := t.TranspileTo(&sb, program); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
 }
 
 func (t *TranspilerBase) GetIndentLevel() int {
@@ -103,12 +183,16 @@ func (t *TranspilerBase) SetIndentLevel(level int) {
 	t.IndentLevel = level
 }
 
+// GetImplementationHandlers returns a copy of the implementation handlers;
+// handlers are added with RegisterImplementationHandler.
 func (t *TranspilerBase) GetImplementationHandlers() map[string]ImplementationHandler {
-	return t.ImplHandlers
+	return maps.Clone(t.implHandlers)
 }
 
+// GetTypeValidators returns a copy of the type validators; validators are
+// added with RegisterTypeValidator.
 func (t *TranspilerBase) GetTypeValidators() map[string]TypeValidator {
-	return t.TypeValidators
+	return maps.Clone(t.typeValidators)
 }
 
 func (t *TranspilerBase) GetBuffer() *bytes.Buffer {
@@ -117,18 +201,50 @@ func (t *TranspilerBase) GetBuffer() *bytes.Buffer {
 
 // Initialize a transpiler base with common handlers and validators.
 func (t *TranspilerBase) Initialize() {
-	t.ImplHandlers = make(map[string]ImplementationHandler)
-	t.TypeValidators = make(map[string]TypeValidator)
+	t.implHandlers = make(map[string]ImplementationHandler)
+	t.typeValidators = make(map[string]TypeValidator)
 }
 
 // RegisterImplementationHandler adds a custom implementation handler.
 func (t *TranspilerBase) RegisterImplementationHandler(name string, handler ImplementationHandler) {
-	t.ImplHandlers[name] = handler
+	t.implHandlers[name] = handler
+	t.registrations = append(t.registrations, func(c *TranspilerBase) {
+		c.RegisterImplementationHandler(name, handler)
+	})
 }
 
 // RegisterTypeValidator adds a custom type validator.
 func (t *TranspilerBase) RegisterTypeValidator(typeName string, validator TypeValidator) {
-	t.TypeValidators[typeName] = validator
+	t.typeValidators[typeName] = validator
+	t.registrations = append(t.registrations, func(c *TranspilerBase) {
+		c.RegisterTypeValidator(typeName, validator)
+	})
+}
+
+// call runs transpile on the base of a new transpiler of the same target,
+// so that calls don't share their output state and a configured transpiler
+// can be used concurrently. The new transpiler gets the handlers and
+// validators registered on t after its construction, its templates, options
+// and provenance, unless ctx has an Annotation; t keeps the source mappings
+// of the last call.
+func (t *TranspilerBase) call(ctx context.Context, c *TranspilerBase, transpile func() error) error {
+	for _, register := range t.registrations[len(c.registrations):] {
+		register(c)
+	}
+	c.templates, c.options, c.provenance, c.ctx = t.templates, t.options, t.provenance, ctx
+	annotation, _ := ctx.Value(annotationKey{}).(*Annotation)
+	if annotation != nil && annotation.Provenance != "" {
+		c.provenance = annotation.Provenance
+	}
+
+	err := transpile()
+	if annotation != nil {
+		annotation.Mappings = c.mappings
+	}
+	t.mu.Lock()
+	t.mappings = c.mappings
+	t.mu.Unlock()
+	return err
 }
 
 // FormatDescription formats multi-line descriptions for documentation
@@ -155,6 +271,12 @@ func IdentifyFileParameters(params []ast.Parameter) []string {
 	return fileParams
 }
 
+// resultsMount returns the path the results directory of a program is
+// mounted at in its container, under the /data mount of the inputs.
+func resultsMount(program *ast.Program) string {
+	return "/data/" + program.Name + "_results"
+}
+
 // IsParamReference checks if a string is a parameter reference rather than a literal
 func IsParamReference(s string, params []ast.Parameter) bool {
 	for _, param := range params {
@@ -165,6 +287,64 @@ func IsParamReference(s string, params []ast.Parameter) bool {
 	return false
 }
 
+// BooleanFlag returns the argument a boolean parameter passes to the
+// container when true: its "flag" metadata, or --<name>.
+func BooleanFlag(name string, params []ast.Parameter) string {
+	for _, param := range params {
+		if param.Name == name && param.Metadata["flag"] != "" {
+			return param.Metadata["flag"]
+		}
+	}
+	return "--" + name
+}
+
+// InputChecksum returns the algorithm and lowercase digest of the
+// "checksum" metadata of a file parameter, e.g. "sha256:9f86...", and false
+// when it declares none. The semantic checks verify the form.
+func InputChecksum(param ast.Parameter) (algorithm, digest string, ok bool) {
+	checksum := param.Metadata["checksum"]
+	if checksum == "" {
+		return "", "", false
+	}
+	algorithm, digest, _ = strings.Cut(checksum, ":")
+	return algorithm, strings.ToLower(digest), true
+}
+
+// IsOmittable reports whether a parameter is optional without a default:
+// it has no value unless one is given, and is then left off the command.
+func IsOmittable(param ast.Parameter) bool {
+	return param.Metadata["optional"] == "true" && param.Default == nil
+}
+
+// omittableParam reports whether a name is a parameter left off the
+// command without a value.
+func omittableParam(name string, params []ast.Parameter) bool {
+	i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == name })
+	return i >= 0 && IsOmittable(params[i])
+}
+
+// mainFirst moves the first file parameter always given to the front,
+// its directory is the main mount point.
+func mainFirst(fileParams []string, params []ast.Parameter) []string {
+	i := slices.IndexFunc(fileParams, func(name string) bool { return !omittableParam(name, params) })
+	if i <= 0 {
+		return fileParams
+	}
+	return slices.Concat([]string{fileParams[i]}, fileParams[:i], fileParams[i+1:])
+}
+
+// heldOption reports whether the argument at i is an option, e.g. -k,
+// followed by a parameter left off the command without a value, which
+// takes the option along.
+func heldOption(args []any, i int, params []ast.Parameter) bool {
+	option, ok := args[i].(string)
+	if !ok || !strings.HasPrefix(strings.Trim(option, "\"'"), "-") || i+1 >= len(args) {
+		return false
+	}
+	name, _ := args[i+1].(string)
+	return omittableParam(name, params) && GetParamType(name, params) != TypeBoolean
+}
+
 // GetParamType returns the type of a parameter by name
 func GetParamType(name string, params []ast.Parameter) string {
 	for _, param := range params {
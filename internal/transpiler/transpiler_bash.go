@@ -1,7 +1,9 @@
 package transpiler
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
@@ -12,6 +14,22 @@ func init() {
 		Extension:   ".sh",
 		Display:     "BASH",
 		Initializer: func() Transpiler { return NewBashTranspiler() },
+		Capabilities: &Capabilities{
+			ParameterTypes:  allTypes(),
+			Implementations: []string{"run_docker"},
+			Outputs:         OutputsEchoed,
+			Limitations: map[string]Limitation{
+				fieldFeature("run_docker", "command"):         {Degraded, "the command field is ignored, use arguments instead"},
+				fieldFeature("run_docker", "version_command"): {Degraded, "the version command is only used by the Galaxy target"},
+				fieldFeature("run_docker", "exit_codes"):      {Degraded, "exit codes are only used by the Galaxy target, any non-zero code fails the run"},
+				fieldFeature("run_docker", "error_patterns"):  {Degraded, "error patterns are only used by the Galaxy target"},
+				fieldFeature("run_docker", "config_files"):    {Unsupported, "config files are only written by the Galaxy target"},
+				blockFeature("resources"):                     {Degraded, "resources are only requested by the Nextflow target"},
+				typeFeature(TypeSamplesheet):                  {Degraded, "samplesheets are passed as a CSV file, the tool reads its samples"},
+				clauseFeature("when"):                         {Degraded, "conditional parameters are always used"},
+				outputFeature("*"):                            {Degraded, "outputs are only echoed at the end of the script"},
+			},
+		},
 	})
 }
 
@@ -20,36 +38,63 @@ type BashTranspiler struct{ TranspilerBase }
 
 // Transpile implements Transpiler.
 func (b *BashTranspiler) Transpile(program *ast.Program) (string, error) {
-	b.Buffer.Reset()
+	return transpileToString(b, program)
+}
+
+// TranspileTo implements Transpiler.
+func (b *BashTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return b.transpileContext(context.Background(), w, program)
+}
+
+func (b *BashTranspiler) transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error {
+	c := NewBashTranspiler()
+	return b.call(ctx, &c.TranspilerBase, func() error { return c.transpile(w, program) })
+}
+
+// transpile writes the code of a program, on the transpiler of a call.
+func (b *BashTranspiler) transpile(w io.Writer, program *ast.Program) error {
+	program = targetProgram("bash", program)
+	b.SetOutput(w)
+	defer b.SetOutput(nil)
 
-	b.writeHeader()
+	b.writeSection(SectionHeader, SectionData{Target: "bash", Program: program}, b.writeHeader)
 	b.writeUtilityFunctions()
 	b.writeArgumentParsing(program.Parameters)
 
 	err := b.writeTypeValidation(program.Parameters)
 	if err != nil {
-		return "", fmt.Errorf("error writing type validation: %w", err)
+		return fmt.Errorf("error writing type validation: %w", err)
 	}
 
 	for _, impl := range program.Implementations {
+		if err := b.canceled(); err != nil {
+			return err
+		}
 		handler, ok := b.GetImplementationHandlers()[impl.Name]
 		if !ok {
-			return "", fmt.Errorf("unknown implementation block: %s", impl.Name)
+			return unsupportedImplementation("bash", &impl, "unknown implementation block: %s")
 		}
 		if err := handler(b, &impl, program); err != nil {
-			return "", fmt.Errorf("error processing implementation '%s': %w", impl.Name, err)
+			return fmt.Errorf("error processing implementation '%s': %w", impl.Name, err)
 		}
 	}
 
-	if len(program.Outputs) > 0 {
-		b.WriteLine("")
-		b.WriteLine("# Outputs")
-		for _, output := range program.Outputs {
-			b.WriteLine("echo \"Output generated: %s\"", output.Path)
+	b.writeSection(SectionResult, SectionData{Target: "bash", Program: program}, func() {
+		if len(program.Outputs) > 0 {
+			b.WriteLine("")
+			b.WriteLine("# Outputs")
+			for _, output := range program.Outputs {
+				b.WriteLine("echo \"Output generated: %s\"", output.Path)
+			}
 		}
-	}
+	})
+
+	return b.OutputError()
+}
 
-	return b.Buffer.String(), nil
+// TemplateSections implements Templated.
+func (b *BashTranspiler) TemplateSections() []string {
+	return []string{SectionHeader, SectionDocker, SectionResult}
 }
 
 func (b *BashTranspiler) writeUtilityFunctions() {
@@ -59,6 +104,7 @@ func (b *BashTranspiler) writeUtilityFunctions() {
 	b.WriteLine("")
 	b.WriteLine("check_docker() {")
 	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("[[ \"$dry_run\" == \"true\" ]] && return 0")
 	b.WriteLine("if ! command -v docker &> /dev/null; then")
 	b.SetIndentLevel(b.GetIndentLevel() + 1)
 	b.WriteLine("log_error \"Docker is not installed or not in PATH.\"")
@@ -78,8 +124,54 @@ func (b *BashTranspiler) writeUtilityFunctions() {
 	b.SetIndentLevel(b.GetIndentLevel() - 1)
 	b.WriteLine("done")
 	b.WriteLine("[[ \"$1\" == \"--\" ]] && shift")
+	b.WriteLine("if [[ \"$dry_run\" == \"true\" ]]; then")
+	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("printf '%%q ' docker run --rm \"${opts[@]}\" \"$image\" \"$@\"")
+	b.WriteLine("printf '\\n'")
+	b.WriteLine("return 0")
+	b.SetIndentLevel(b.GetIndentLevel() - 1)
+	b.WriteLine("fi")
 	b.WriteLine("log_info \"Running Docker image: $image\"")
-	b.WriteLine("docker run --rm \"${opts[@]}\" \"$image\" \"$@\"")
+	// Traps only run once a foreground command returns, so the container
+	// runs in the background for an interrupt to stop it
+	b.WriteLine("container_name=\"baryon-$$-$RANDOM\"")
+	b.WriteLine("trap 'stop_container 130' INT")
+	b.WriteLine("trap 'stop_container 143' TERM")
+	b.WriteLine("docker run --rm --name \"$container_name\" \"${opts[@]}\" \"$image\" \"$@\" &")
+	b.WriteLine("local status=0")
+	b.WriteLine("wait $! || status=$?")
+	b.WriteLine("trap - INT TERM")
+	b.WriteLine("return $status")
+	b.SetIndentLevel(b.GetIndentLevel() - 1)
+	b.WriteLine("}")
+	b.WriteLine("")
+	b.WriteLine("stop_container() {")
+	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("log_error \"Interrupted, stopping container $container_name\"")
+	b.WriteLine("docker stop \"$container_name\" > /dev/null 2>&1 || true")
+	b.WriteLine("exit \"$1\"")
+	b.SetIndentLevel(b.GetIndentLevel() - 1)
+	b.WriteLine("}")
+	b.WriteLine("")
+	// The first input directory is mounted at /data, the others under
+	// /inputs, numbered in the order they are mounted
+	b.WriteLine("mount_input() {")
+	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("local i")
+	b.WriteLine("for i in \"${!input_dirs[@]}\"; do")
+	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("if [[ \"${input_dirs[$i]}\" == \"$1\" ]]; then")
+	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("input_mount=\"/inputs/$i\"")
+	b.WriteLine("[[ $i -eq 0 ]] && input_mount=\"/data\"")
+	b.WriteLine("return 0")
+	b.SetIndentLevel(b.GetIndentLevel() - 1)
+	b.WriteLine("fi")
+	b.SetIndentLevel(b.GetIndentLevel() - 1)
+	b.WriteLine("done")
+	b.WriteLine("input_dirs+=(\"$1\")")
+	b.WriteLine("input_mount=\"/inputs/$(( ${#input_dirs[@]} - 1 ))\"")
+	b.WriteLine("docker_opts+=(-v \"$1:$input_mount\")")
 	b.SetIndentLevel(b.GetIndentLevel() - 1)
 	b.WriteLine("}")
 	b.WriteLine("")
@@ -91,8 +183,12 @@ func (b *BashTranspiler) writeArgumentParsing(params []ast.Parameter) {
 	b.SetIndentLevel(b.GetIndentLevel() + 1)
 	b.WriteLine("echo \"Usage: $0 [options]\"")
 	for _, param := range params {
+		if param.Metadata["hidden"] == "true" {
+			continue
+		}
 		b.WriteLine("echo \"  --%s <value>\"", param.Name)
 	}
+	b.WriteLine("echo \"  --dry-run  print the docker command instead of running it\"")
 	b.WriteLine("exit 1")
 	b.SetIndentLevel(b.GetIndentLevel() - 1)
 	b.WriteLine("}")
@@ -106,6 +202,7 @@ func (b *BashTranspiler) writeArgumentParsing(params []ast.Parameter) {
 			b.WriteLine("%s=\"\"", param.Name)
 		}
 	}
+	b.WriteLine("dry_run=\"false\"")
 	b.WriteLine("")
 
 	b.WriteLine("while [[ $# -gt 0 ]]; do")
@@ -129,6 +226,12 @@ func (b *BashTranspiler) writeArgumentParsing(params []ast.Parameter) {
 		b.WriteLine(";;")
 	}
 
+	b.WriteLine("--dry-run)")
+	b.SetIndentLevel(b.GetIndentLevel() + 1)
+	b.WriteLine("dry_run=\"true\"")
+	b.WriteLine("shift")
+	b.SetIndentLevel(b.GetIndentLevel() - 1)
+	b.WriteLine(";;")
 	b.WriteLine("-h|--help)")
 	b.SetIndentLevel(b.GetIndentLevel() + 1)
 	b.WriteLine("usage")
@@ -153,22 +256,75 @@ func (b *BashTranspiler) writeTypeValidation(params []ast.Parameter) error {
 		return nil
 	}
 	for _, param := range params {
-		if param.Default != nil {
+		if err := b.canceled(); err != nil {
+			return err
+		}
+		// The defaults are only validated against the range of the
+		// parameter, and the optional parameters when given
+		_, min := param.Metadata["min"]
+		_, max := param.Metadata["max"]
+		if param.Default != nil && !min && !max {
 			continue
 		}
 		validator, exists := b.GetTypeValidator()[param.Type]
 		if !exists {
 			b.WriteLine("# No specific validation for type '%s'", param.Type)
+			continue
+		}
+		if IsOmittable(param) {
+			b.WriteLine("if [[ -n \"$%s\" ]]; then", param.Name)
+			b.SetIndentLevel(b.GetIndentLevel() + 1)
 		}
 		if err := validator(b, param); err != nil {
 			return fmt.Errorf("error validating parameter '%s': %w", param.Name, err)
 		}
+		b.writeRangeCheck(param)
+		if IsOmittable(param) {
+			b.SetIndentLevel(b.GetIndentLevel() - 1)
+			b.WriteLine("fi")
+		}
 	}
 	return nil
 }
 
+// writeRangeCheck writes the checks of the min and max of a numeric
+// parameter, which are inclusive, compared by awk as numbers may have
+// decimals.
+func (b *BashTranspiler) writeRangeCheck(param ast.Parameter) {
+	if param.Type != TypeInteger && param.Type != TypeNumber {
+		return
+	}
+	for _, bound := range []struct{ key, operator, text string }{
+		{"min", "<", "at least"},
+		{"max", ">", "at most"},
+	} {
+		if value, ok := param.Metadata[bound.key]; ok {
+			b.WriteLine("if awk -v value=\"$%s\" 'BEGIN { exit !(value %s %s) }'; then", param.Name, bound.operator, value)
+			b.SetIndentLevel(b.GetIndentLevel() + 1)
+			b.WriteLine("echo \"Error: %s must be %s %s\" >&2", param.Name, bound.text, value)
+			b.WriteLine("exit 1")
+			b.SetIndentLevel(b.GetIndentLevel() - 1)
+			b.WriteLine("fi")
+		}
+	}
+}
+
+// bashWhenGiven writes the lines of a parameter run only when it is given,
+// if it is an optional one left off without a value.
+func bashWhenGiven(base BaseTranspiler, name string, params []ast.Parameter, write func()) {
+	if !omittableParam(name, params) {
+		write()
+		return
+	}
+	base.WriteLine("if [[ -n \"$%s\" ]]; then", name)
+	base.SetIndentLevel(base.GetIndentLevel() + 1)
+	write()
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	base.WriteLine("fi")
+}
+
 func (b *BashTranspiler) GetTypeValidator() map[string]TypeValidator {
-	return b.TypeValidators
+	return b.GetTypeValidators()
 }
 
 func (b *BashTranspiler) writeHeader() {
@@ -217,11 +373,16 @@ func (b *BashTranspiler) handleDockerImplementation(
 
 	base.WriteLine("")
 	base.WriteLine("# Process file paths for Docker")
-	fileParams := IdentifyFileParameters(program.Parameters)
+	fileParams := mainFirst(IdentifyFileParameters(program.Parameters), program.Parameters)
 	for _, param := range fileParams {
-		base.WriteLine("%s_abspath=$(cd \"$(dirname \"$%s\")\" && pwd)/$(basename \"$%s\")", param, param, param)
-		base.WriteLine("%s_dir=$(dirname \"$%s_abspath\")", param, param)
-		base.WriteLine("%s_filename=$(basename \"$%s_abspath\")", param, param)
+		if omittableParam(param, program.Parameters) {
+			base.WriteLine("%s_filename=\"\"", param)
+		}
+		bashWhenGiven(base, param, program.Parameters, func() {
+			base.WriteLine("%s_abspath=$(cd \"$(dirname \"$%s\")\" && pwd)/$(basename \"$%s\")", param, param, param)
+			base.WriteLine("%s_dir=$(dirname \"$%s_abspath\")", param, param)
+			base.WriteLine("%s_filename=$(basename \"$%s_abspath\")", param, param)
+		})
 	}
 
 	base.WriteLine("")
@@ -240,7 +401,9 @@ func (b *BashTranspiler) handleDockerImplementation(
 				key := pair[0].(string)
 				val := pair[1].(string)
 				if IsParamReference(val, program.Parameters) {
-					base.WriteLine("docker_opts+=(-e \"%s=$%s\")", key, val)
+					bashWhenGiven(base, val, program.Parameters, func() {
+						base.WriteLine("docker_opts+=(-e \"%s=$%s\")", key, val)
+					})
 				} else {
 					base.WriteLine("docker_opts+=(-e \"%s=%s\")", key, val)
 				}
@@ -259,11 +422,13 @@ func (b *BashTranspiler) handleDockerImplementation(
 	
 				if IsParamReference(hostPath, program.Parameters) {
 					// Use the _dir variable for file parameters to mount the directory
-					if Contains(fileParams, hostPath) {
-						base.WriteLine("docker_opts+=(-v \"$%s_dir:%s\")", hostPath, containerPath)
-					} else {
-						base.WriteLine("docker_opts+=(-v \"$%s:%s\")", hostPath, containerPath)
-					}
+					bashWhenGiven(base, hostPath, program.Parameters, func() {
+						if Contains(fileParams, hostPath) {
+							base.WriteLine("docker_opts+=(-v \"$%s_dir:%s\")", hostPath, containerPath)
+						} else {
+							base.WriteLine("docker_opts+=(-v \"$%s:%s\")", hostPath, containerPath)
+						}
+					})
 				} else if hostPath == "parent-folder" || hostPath == "parent_folder" {
 					base.WriteLine("docker_opts+=(-v \"$(pwd):%s\")", containerPath)
 				} else {
@@ -272,7 +437,25 @@ func (b *BashTranspiler) handleDockerImplementation(
 			}
 		} else {
 			if len(fileParams) > 0 {
-				base.WriteLine("docker_opts+=(-v \"$%s_dir:/data\")", fileParams[0])
+				// The current directory stands for the main input when
+				// every file parameter is optional and left off
+				mainDir := fmt.Sprintf("$%s_dir", fileParams[0])
+				if omittableParam(fileParams[0], program.Parameters) {
+					mainDir = fmt.Sprintf("${%s_dir:-$(pwd)}", fileParams[0])
+				}
+				base.WriteLine("docker_opts+=(-v \"%s:/data\")", mainDir)
+				if len(fileParams) > 1 {
+					// Mount the directories of the other inputs
+					base.WriteLine("input_dirs=(\"%s\")", mainDir)
+					for _, param := range fileParams[1:] {
+						bashWhenGiven(base, param, program.Parameters, func() {
+							base.WriteLine("mount_input \"$%s_dir\"", param)
+							base.WriteLine("if [[ \"$input_mount\" != \"/data\" ]]; then")
+							base.WriteLine("  %s_filename=\"$input_mount/$%s_filename\"", param, param)
+							base.WriteLine("fi")
+						})
+					}
+				}
 			} else {
 				base.WriteLine("docker_opts+=(-v \"$(pwd):/data\")")
 			}
@@ -280,12 +463,26 @@ func (b *BashTranspiler) handleDockerImplementation(
 	
 		base.WriteLine("container_args=()")
 		if args, ok := impl.Fields["arguments"].([]any); ok {
-			for _, a := range args {
+			for i, a := range args {
 				argStr, ok := a.(string)
-				if !ok {
+				// The options written with the parameter after them are
+				// skipped
+				if !ok || heldOption(args, i, program.Parameters) {
 					continue
 				}
-				if IsParamReference(argStr, program.Parameters) {
+				if IsParamReference(argStr, program.Parameters) && omittableParam(argStr, program.Parameters) && GetParamType(argStr, program.Parameters) != TypeBoolean {
+					// Left off without a value, with its option
+					values := []string{fmt.Sprintf("\"$%s\"", argStr)}
+					if Contains(fileParams, argStr) {
+						values[0] = fmt.Sprintf("\"$%s_filename\"", argStr)
+					}
+					if i > 0 && heldOption(args, i-1, program.Parameters) {
+						values = append([]string{fmt.Sprintf("\"%s\"", strings.Trim(fmt.Sprint(args[i-1]), "\"'"))}, values...)
+					}
+					base.WriteLine("if [[ -n \"$%s\" ]]; then", argStr)
+					base.WriteLine("  container_args+=(%s)", strings.Join(values, " "))
+					base.WriteLine("fi")
+				} else if IsParamReference(argStr, program.Parameters) {
 					if Contains(fileParams, argStr) {
 						base.WriteLine("container_args+=(\"$%s_filename\")", argStr)
 					} else {
@@ -297,7 +494,10 @@ func (b *BashTranspiler) handleDockerImplementation(
 			}
 		}
 	
-		base.WriteLine("run_docker \"%s\" \"${docker_opts[@]}\" -- \"${container_args[@]}\"", image)
+		data := SectionData{Target: "bash", Program: program, Implementation: impl, Image: image}
+		b.writeSection(SectionDocker, data, func() {
+			base.WriteLine("run_docker \"%s\" \"${docker_opts[@]}\" -- \"${container_args[@]}\"", image)
+		})
 		return nil
 	}
 	func (b *BashTranspiler) validateStringType(
@@ -384,6 +584,14 @@ func (b *BashTranspiler) validateFileType(
 	base.WriteLine("exit 1")
 	base.SetIndentLevel(base.GetIndentLevel() - 1)
 	base.WriteLine("fi")
+	if algorithm, digest, ok := InputChecksum(param); ok {
+		base.WriteLine("if ! echo \"" + digest + "  $" + param.Name + "\" | " + algorithm + "sum -c --status; then")
+		base.SetIndentLevel(base.GetIndentLevel() + 1)
+		base.WriteLine("echo \"Error: " + param.Name + " does not match its " + algorithm + " checksum\" >&2")
+		base.WriteLine("exit 1")
+		base.SetIndentLevel(base.GetIndentLevel() - 1)
+		base.WriteLine("fi")
+	}
 	return nil
 }
 
@@ -1,9 +1,15 @@
 package transpiler
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/galaxy"
@@ -14,36 +20,185 @@ func init() {
 		Extension:   ".xml",
 		Display:     "Galaxy",
 		Initializer: func() Transpiler { return NewGalaxyTranspiler() },
+		Capabilities: &Capabilities{
+			ParameterTypes:  allTypes(),
+			Implementations: []string{"run_docker"},
+			Outputs:         OutputsDeclared,
+			Limitations: map[string]Limitation{
+				typeFeature(TypeDirectory):            {Degraded, "directories are mapped to data collections"},
+				fieldFeature("run_docker", "env"):     {Unsupported, "environment variables are not passed to the container"},
+				fieldFeature("run_docker", "volumes"): {Degraded, "volumes become directories of the job working directory"},
+				fieldFeature("run_docker", "command"): {Degraded, "the command field is ignored, use arguments instead"},
+				outputFeature(TypeDirectory):          {Degraded, "directory outputs become a list collection of the files at their top level"},
+				blockFeature("resources"):             {Degraded, "resources are only requested by the Nextflow target"},
+				typeFeature(TypeSamplesheet):          {Degraded, "samplesheets are passed as a CSV file, the tool reads its samples"},
+			},
+		},
 	})
 }
 
+// galaxyLiteralSyntax spells literal values in Galaxy XML attributes, which
+// are escaped by the XML encoder.
+var galaxyLiteralSyntax = LiteralSyntax{
+	True:  "true",
+	False: "false",
+	Quote: func(s string) string { return s },
+}
+
+const (
+	// galaxyProfile selects the Galaxy release the tool declares as its
+	// profile, "none" declaring none. Defaults implied by the profile, such
+	// as failing on a non-zero exit code since 16.04, are left out of the
+	// tool.
+	galaxyProfile = "profile"
+	// galaxySanitize gives the text params a sanitizer removing the
+	// characters other than letters, digits and galaxyAllowedChars, when
+	// "true".
+	galaxySanitize = "sanitize"
+	// galaxyAllowedChars lists the characters the sanitizers keep besides
+	// letters and digits.
+	galaxyAllowedChars = "allowed_chars"
+)
+
+// galaxyUnsafeChars are the characters the shell or Cheetah interpret,
+// blanks included, which sanitizers never keep.
+const galaxyUnsafeChars = " '\"`$\\;|&<>(){}#!*?[]~\n\r\t"
+
 // GalaxyTranspiler converts Baryon AST to Galaxy XML format.
 type GalaxyTranspiler struct {
 	TranspilerBase
 	galaxyTool *galaxy.Tool
 }
 
+// TargetOptions implements Configurable.
+func (g *GalaxyTranspiler) TargetOptions() []TargetOption {
+	return []TargetOption{
+		{Name: galaxyProfile, Values: append([]string{"none"}, galaxy.Profiles...), Default: "none",
+			Help: "declare the Galaxy release whose behavior the tool expects, and validate the tool for it"},
+		{Name: galaxySanitize, Values: []string{"true", "false"}, Default: "true",
+			Help: "sanitize the text params, keeping letters, digits and the allowed characters"},
+		{Name: galaxyAllowedChars, Default: "._-",
+			Help: "the characters the sanitizers keep besides letters and digits, and those of the default of the param when unset"},
+	}
+}
+
+// SetOption implements Configurable, refusing to let the sanitizers keep
+// the characters the shell or Cheetah interpret.
+func (g *GalaxyTranspiler) SetOption(name, value string) error {
+	if i := strings.IndexAny(value, galaxyUnsafeChars); name == galaxyAllowedChars && i >= 0 {
+		return fmt.Errorf("option '%s' of target 'galaxy' can't allow %q, which the shell or Cheetah interpret",
+			name, value[i:i+1])
+	}
+	return g.TranspilerBase.SetOption(name, value)
+}
+
+// galaxySanitizer returns the sanitizer of a text param, if they are
+// sanitized. Unless allowed_chars is set, the characters of the default of
+// the param are kept too, so that a separator like "," survives its own
+// sanitizer; those the shell or Cheetah interpret never are.
+func (g *GalaxyTranspiler) galaxySanitizer(param ast.Parameter) *galaxy.Sanitizer {
+	if g.option(galaxySanitize, "true") != "true" {
+		return nil
+	}
+	allowed, explicit := g.options[galaxyAllowedChars]
+	if !explicit {
+		allowed = "._-"
+		if def, ok := param.Default.(string); ok {
+			allowed += strings.Map(func(c rune) rune {
+				if c > unicode.MaxASCII || unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune(galaxyUnsafeChars, c) {
+					return -1
+				}
+				return c
+			}, def)
+		}
+	}
+	valid := &galaxy.Valid{Initial: "string.ascii_letters,string.digits"}
+	for _, c := range allowed {
+		if add := (galaxy.SanitizerAdd{Value: string(c)}); !slices.Contains(valid.Add, add) {
+			valid.Add = append(valid.Add, add)
+		}
+	}
+	return &galaxy.Sanitizer{Valid: valid}
+}
+
+// profile returns the profile of the tool, empty when it declares none.
+func (g *GalaxyTranspiler) profile() string {
+	return strings.TrimPrefix(g.option(galaxyProfile, "none"), "none")
+}
+
 // Transpile implements Transpiler.
 func (g *GalaxyTranspiler) Transpile(program *ast.Program) (string, error) {
+	return transpileToString(g, program)
+}
+
+// TranspileTo implements Transpiler.
+func (g *GalaxyTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return g.transpileContext(context.Background(), w, program)
+}
+
+// TranspilePackage implements Packaged: the tool, with the sample
+// configuration and .loc files of its data tables, if any.
+func (g *GalaxyTranspiler) TranspilePackage(program *ast.Program) ([]PackageFile, error) {
+	c := NewGalaxyTranspiler()
+	if err := g.call(context.Background(), &c.TranspilerBase, func() error { return c.buildTool(program) }); err != nil {
+		return nil, err
+	}
+	content, err := galaxyDocument(c.galaxyTool)
+	if err != nil {
+		return nil, err
+	}
+	files := []PackageFile{{Path: c.galaxyTool.Id + ".xml", Content: content}}
+	tables, err := galaxyDataTableFiles([]*ast.Program{program})
+	if err != nil {
+		return nil, err
+	}
+	return append(files, tables...), nil
+}
+
+func (g *GalaxyTranspiler) transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error {
+	c := NewGalaxyTranspiler()
+	return g.call(ctx, &c.TranspilerBase, func() error { return c.transpile(w, program) })
+}
+
+// transpile writes the code of a program, on the transpiler of a call.
+func (g *GalaxyTranspiler) transpile(w io.Writer, program *ast.Program) error {
+	if err := g.buildTool(program); err != nil {
+		return err
+	}
+	return writeGalaxyXML(w, g.galaxyTool)
+}
+
+// buildTool sets the tool of a program, on the transpiler of a call.
+func (g *GalaxyTranspiler) buildTool(program *ast.Program) error {
+	program = targetProgram("galaxy", program)
 
 	g.galaxyTool = &galaxy.Tool{
 		Id:           program.Name,
 		Name:         program.Name,
+		Version:      program.Metadata["version"],
+		Profile:      g.profile(),
 		Description:  program.Description,
 		Requirements: &galaxy.Requirements{},
-		Inputs: &galaxy.Inputs{
-			Param: []galaxy.Param{},
-		},
-		Outputs: &galaxy.Outputs{},
+		Inputs:       &galaxy.Inputs{},
+		Outputs:      &galaxy.Outputs{},
 	}
 
 	if err := g.writeTypeValidation(program.Parameters); err != nil {
-		return "", fmt.Errorf("error writing type validation: %w", err)
+		return fmt.Errorf("error writing type validation: %w", err)
 	}
+	g.hideParams(program.Parameters)
+	g.nestConditionals(program.Parameters)
+	g.groupSections(program.Parameters)
 
 	if err := g.writeOutputDefinitions(program.Outputs); err != nil {
-		return "", fmt.Errorf("error writing output definitions: %w", err)
+		return fmt.Errorf("error writing output definitions: %w", err)
+	}
+
+	g.writeTests(program)
+	if help := galaxyHelp(program); help != "" {
+		g.galaxyTool.Help = &galaxy.Help{Value: help}
 	}
+	g.writeCitations(program)
 
 	if len(program.Implementations) == 0 {
 		g.galaxyTool.Command = &galaxy.Command{
@@ -53,15 +208,28 @@ func (g *GalaxyTranspiler) Transpile(program *ast.Program) (string, error) {
 
 	for _, impl := range program.Implementations {
 		if handler, ok := g.GetImplementationHandlers()[impl.Name]; ok {
-			handler(g, &impl, program)
+			if err := handler(g, &impl, program); err != nil {
+				return fmt.Errorf("error processing '%s' implementation: %w", impl.Name, err)
+			}
 		}
 	}
+	if err := g.galaxyTool.Validate(); err != nil {
+		return fmt.Errorf("invalid Galaxy tool: %w", err)
+	}
+	return nil
+}
 
-	outputString, err := xml.MarshalIndent(g.galaxyTool, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("error marshaling Galaxy tool XML: %w", err)
+// writeGalaxyXML writes an indented XML document, a tool or its macros.
+func writeGalaxyXML(w io.Writer, document any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("error marshaling Galaxy tool XML: %w", err)
 	}
-	return xml.Header + string(outputString), nil
+	return nil
 }
 
 // NewGalaxyTranspiler initializes a new Galaxy transpiler instance.
@@ -83,7 +251,7 @@ func NewGalaxyTranspiler() *GalaxyTranspiler {
 		TypeNumber:    GalaxyTypeValidatorFloat,
 		TypeInteger:   GalaxyTypeValidatorInteger,
 		TypeBoolean:   GalaxyTypeValidatorBoolean,
-		TypeFile:      GalaxyTypeValidatorFile,
+		TypeFile:      GalaxyTypeValidatorData,
 		TypeDirectory: GalaxyTypeValidatorDataCollection,
 	}
 
@@ -111,10 +279,9 @@ func (g *GalaxyTranspiler) writeTypeValidation(params []ast.Parameter) error {
 		return nil
 	}
 	for _, param := range params {
-		if param.Default != nil {
-			continue
+		if err := g.canceled(); err != nil {
+			return err
 		}
-
 		// Check for Galaxy Data Table metadata
 		if tableName, ok := param.Metadata["galaxy_data_table"]; ok {
 			if err := g.createDataTableParam(param, tableName); err != nil {
@@ -125,7 +292,12 @@ func (g *GalaxyTranspiler) writeTypeValidation(params []ast.Parameter) error {
 
 		validator, exists := g.GetTypeValidators()[param.Type]
 		if !exists {
-			return fmt.Errorf("no validator registered for type '%s'", param.Type)
+			return &UnsupportedFeatureError{
+				Target:  "galaxy",
+				Feature: typeFeature(param.Type),
+				Pos:     param.Pos,
+				Message: fmt.Sprintf("no validator registered for type '%s'", param.Type),
+			}
 		}
 		if err := validator(g, param); err != nil {
 			return fmt.Errorf("error validating parameter '%s': %w", param.Name, err)
@@ -138,16 +310,16 @@ func (g *GalaxyTranspiler) createDataTableParam(param ast.Parameter, tableName s
 	options := &galaxy.Options{
 		FromDataTable: tableName,
 		Columns: []galaxy.Column{
-			{Name: "name", Index: 1},
-			{Name: "value", Index: 0},
-			{Name: "path", Index: 2},
+			{Name: "name", Index: slices.Index(galaxyDataTableColumns, "name")},
+			{Name: "value", Index: slices.Index(galaxyDataTableColumns, "value")},
+			{Name: "path", Index: slices.Index(galaxyDataTableColumns, "path")},
 		},
 		Filter: []galaxy.Filter{
 			{Type: "sort_by", Column: 1},
 		},
 	}
 
-	g.galaxyTool.Inputs.Param = append(g.galaxyTool.Inputs.Param, galaxy.Param{
+	g.galaxyTool.Inputs.Elements = append(g.galaxyTool.Inputs.Elements, galaxy.Param{
 		Type:       "select",
 		Name:       param.Name,
 		Label:      param.Description,
@@ -164,15 +336,10 @@ func (g *GalaxyTranspiler) writeOutputDefinitions(outputs []ast.OutputBlock) err
 	for _, output := range outputs {
 		if output.Format == "directory" {
 			g.galaxyTool.Outputs.Collection = append(g.galaxyTool.Outputs.Collection, galaxy.Collection{
-				Name: output.Name,
-				Type: "list", // Assuming "list" for now, as Baryon doesn't specify collection type
-				Data: []galaxy.Data{
-					{
-						Name:   output.Name, // Use the output name for the data element inside the collection
-						Format: "auto",      // Galaxy often uses 'auto' for collection elements
-						Label:  output.Description,
-					},
-				},
+				Name:             output.Name,
+				Type:             "list", // Baryon doesn't specify collection types
+				Label:            output.Description,
+				DiscoverDatasets: []galaxy.DiscoverDatasets{galaxyDiscoverDatasets(output.Path)},
 			})
 		} else {
 			g.galaxyTool.Outputs.Data = append(g.galaxyTool.Outputs.Data, galaxy.Data{
@@ -185,19 +352,220 @@ func (g *GalaxyTranspiler) writeOutputDefinitions(outputs []ast.OutputBlock) err
 	return nil
 }
 
+// galaxyDiscoverDatasets discovers the elements of the collection of a
+// directory output in the files of its path, relative to the working
+// directory of the job as the default mount. A glob in the last element of
+// the path, e.g. /data/reports/*.html, selects the files and names each
+// element after the text matching its wildcards, with the extension as the
+// format when it is fixed; without one, every file is an element named
+// after its file name and typed after its extension.
+func galaxyDiscoverDatasets(outputPath string) galaxy.DiscoverDatasets {
+	directory := outputPath
+	if directory == "/data" || strings.HasPrefix(directory, "/data/") {
+		directory = strings.TrimPrefix(directory, "/data")
+	}
+	directory = strings.Trim(directory, "/")
+	base := path.Base(directory)
+	if !strings.ContainsAny(base, "*?[") {
+		return galaxy.DiscoverDatasets{Pattern: "__name_and_ext__", Directory: directory}
+	}
+	discover := galaxy.DiscoverDatasets{Directory: strings.TrimSuffix(path.Dir(directory), "."), Format: "auto"}
+	stem, ext, _ := cutExtension(base)
+	if ext != "" && !strings.ContainsAny(ext, "*?[") {
+		discover.Format = ext
+		base = stem
+	}
+	discover.Pattern = "(?P<designation>" + globRegexp(base) + ")"
+	if discover.Format != "auto" {
+		discover.Pattern += regexp.QuoteMeta("." + discover.Format)
+	}
+	return discover
+}
+
+// cutExtension splits a file name at its last dot.
+func cutExtension(name string) (stem, ext string, found bool) {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return name, "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// globRegexp converts a glob to a regular expression: * matches any
+// characters of a file name, ? one, and character classes are kept.
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(glob[i:]))
+				return b.String()
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// writeTests generates a <test> for each test of the program. The file
+// values and the expected files are paths in the test-data directory of
+// the tool.
+func (g *GalaxyTranspiler) writeTests(program *ast.Program) {
+	if len(program.Tests) == 0 {
+		return
+	}
+	collections := map[string]bool{}
+	for _, output := range program.Outputs {
+		collections[output.Name] = output.Format == TypeDirectory
+	}
+
+	g.galaxyTool.Tests = &galaxy.Tests{}
+	for _, test := range program.Tests {
+		galaxyTest := galaxy.Test{}
+		for _, param := range test.Params {
+			galaxyTest.Param = append(galaxyTest.Param, galaxy.TestParam{
+				Name:  galaxyTestParamName(param.Name, program.Parameters),
+				Value: FormatLiteral(param.Value, galaxyLiteralSyntax),
+			})
+		}
+		for _, expect := range test.Expect {
+			// The elements of a collection aren't known in advance
+			if collections[expect.Output] {
+				galaxyTest.OutputCollection = append(galaxyTest.OutputCollection, galaxy.OutputCollection{Name: expect.Output})
+				continue
+			}
+			galaxyTest.Output = append(galaxyTest.Output, galaxyTestOutput(expect))
+		}
+		g.galaxyTool.Tests.Test = append(g.galaxyTool.Tests.Test, galaxyTest)
+	}
+}
+
+// writeCitations generates the citations of the tool from the doi metadata
+// of the program, DOIs separated by spaces or commas, and from its bibtex
+// metadata, one or more BibTeX entries.
+func (g *GalaxyTranspiler) writeCitations(program *ast.Program) {
+	citations := []galaxy.Citation{}
+	for _, doi := range strings.FieldsFunc(program.Metadata["doi"], func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "doi:"} {
+			doi = strings.TrimPrefix(doi, prefix)
+		}
+		citations = append(citations, galaxy.Citation{Type: "doi", Value: galaxyText.Replace(doi)})
+	}
+	for _, entry := range bibtexEntries(program.Metadata["bibtex"]) {
+		citations = append(citations, galaxy.Citation{Type: "bibtex", Value: galaxyText.Replace(entry)})
+	}
+	if len(citations) > 0 {
+		g.galaxyTool.Citations = &galaxy.Citations{Citation: citations}
+	}
+}
+
+// galaxyText escapes text written as inner XML, which keeps its lines.
+var galaxyText = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// bibtexEntry matches the start of a BibTeX entry, an @ at the start of a
+// line.
+var bibtexEntry = regexp.MustCompile(`(?m)^[ \t]*@`)
+
+// bibtexEntries splits BibTeX text into its entries.
+func bibtexEntries(text string) []string {
+	entries := []string{}
+	starts := bibtexEntry.FindAllStringIndex(text, -1)
+	for i, start := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		entries = append(entries, strings.TrimSpace(text[start[0]:end]))
+	}
+	return entries
+}
+
+// galaxyTestOutput translates the assertions on an output into the checks
+// of a test <output>.
+func galaxyTestOutput(expect ast.OutputExpectation) galaxy.TestOutput {
+	output := galaxy.TestOutput{Name: expect.Output}
+	contents := &galaxy.AssertContents{}
+	for _, assertion := range expect.Assertions {
+		value := fmt.Sprint(assertion.Value)
+		switch assertion.Kind {
+		case ast.AssertFile:
+			output.File = value
+		case ast.AssertChecksum:
+			algorithm, digest, _ := strings.Cut(value, ":")
+			output.Checksum = algorithm + "$" + digest
+		case ast.AssertHasText:
+			contents.HasText = append(contents.HasText, galaxy.HasText{Text: value})
+		case ast.AssertHasLine:
+			contents.HasLine = append(contents.HasLine, galaxy.HasLine{Line: value})
+		case ast.AssertMatches:
+			contents.HasTextMatching = append(contents.HasTextMatching, galaxy.HasTextMatching{Expression: value})
+		case ast.AssertLines:
+			n, _ := assertion.Value.(int)
+			contents.HasNLines = append(contents.HasNLines, galaxy.HasNLines{N: n})
+		}
+	}
+	if len(contents.HasText)+len(contents.HasLine)+len(contents.HasTextMatching)+len(contents.HasNLines) > 0 {
+		output.AssertContents = contents
+	}
+	return output
+}
+
 func (g *GalaxyTranspiler) validateGenericType(paramType GalaxyTypeValidator) func(BaseTranspiler, ast.Parameter) error {
 	return func(_ BaseTranspiler, param ast.Parameter) error {
-		g.galaxyTool.Inputs.Param = append(g.galaxyTool.Inputs.Param, galaxy.Param{
+		galaxyParam := galaxy.Param{
 			Type:            string(paramType),
 			Name:            param.Name,
-			Value:           fmt.Sprintf("%v", param.Default),
 			Label:           param.Description,
 			RefreshOnChange: false,
-		})
+		}
+		if param.Default != nil {
+			// Booleans are preselected with "checked" rather than "value"
+			if paramType == GalaxyTypeValidatorBoolean {
+				galaxyParam.Checked = FormatLiteral(param.Default, galaxyLiteralSyntax)
+			} else {
+				galaxyParam.Value = FormatLiteral(param.Default, galaxyLiteralSyntax)
+			}
+		}
+		galaxyConstraints(&galaxyParam, param)
+		// Datasets of any datatype, whose extension stages the file
+		if paramType == GalaxyTypeValidatorData {
+			galaxyParam.Format = "data"
+		}
+		if paramType == GalaxyTypeValidatorText {
+			galaxyParam.Sanitizer = g.galaxySanitizer(param)
+		}
+		g.galaxyTool.Inputs.Elements = append(g.galaxyTool.Inputs.Elements, galaxyParam)
 		return nil
 	}
 }
 
+// galaxyConstraints validates a param in the form as the parameter is:
+// within its min and max, matching its pattern, and optional when it is.
+func galaxyConstraints(galaxyParam *galaxy.Param, param ast.Parameter) {
+	galaxyParam.Min, galaxyParam.Max = param.Metadata["min"], param.Metadata["max"]
+	galaxyParam.Optional = param.Metadata["optional"] == "true"
+	if pattern, ok := param.Metadata["pattern"]; ok {
+		galaxyParam.Validators = append(galaxyParam.Validators, galaxy.Validator{
+			Type:    "regex",
+			Message: "The value must match " + pattern,
+			Value:   pattern,
+		})
+	}
+}
+
 func (g *GalaxyTranspiler) validateEnumType(_ BaseTranspiler, param ast.Parameter) error {
 	if len(param.Constraints) == 0 {
 		return fmt.Errorf("enum type '%s' must have at least one constraint", param.Name)
@@ -215,13 +583,21 @@ func (g *GalaxyTranspiler) validateEnumType(_ BaseTranspiler, param ast.Paramete
 		})
 	}
 
-	g.galaxyTool.Inputs.Param = append(g.galaxyTool.Inputs.Param, galaxy.Param{
+	// Default to first option
+	value := opts[0].Value
+	if param.Default != nil {
+		value = FormatLiteral(param.Default, galaxyLiteralSyntax)
+	}
+
+	galaxyParam := galaxy.Param{
 		Type:    string(GalaxyTypeValidatorSelect),
 		Name:    param.Name,
 		Label:   param.Description,
 		Options: opts,
-		Value:   opts[0].Value, // Default to first option
-	})
+		Value:   value,
+	}
+	galaxyConstraints(&galaxyParam, param)
+	g.galaxyTool.Inputs.Elements = append(g.galaxyTool.Inputs.Elements, galaxyParam)
 
 	return nil
 }
@@ -235,25 +611,18 @@ func (g *GalaxyTranspiler) handleDockerImplementation(
 		return fmt.Errorf("docker implementation requires 'image' option")
 	}
 
-	// Handle arguments
-	args, ok := impl.Fields["arguments"].([]any)
-	if ok && len(args) > 0 {
-		for _, arg := range args {
-			argStr, ok := arg.(string)
-			if ok {
-				// Format the argument to include Galaxy parameter references
-				formattedArg := formatGalaxyArgument(argStr, program.Parameters)
-				if g.galaxyTool.Command == nil {
-					g.galaxyTool.Command = &galaxy.Command{
-						Value: "",
-					}
-				}
-				if g.galaxyTool.Command.Value != "" {
-					g.galaxyTool.Command.Value += " "
-				}
-				g.galaxyTool.Command.Value += formattedArg
-			}
-		}
+	if err := g.writeCommand(impl, program); err != nil {
+		return err
+	}
+
+	if versionCommand, ok := impl.Fields["version_command"].(string); ok && versionCommand != "" {
+		g.galaxyTool.VersionCommand = &galaxy.VersionCommand{Value: versionCommand}
+	}
+
+	g.galaxyTool.Stdio = galaxyStdio(impl)
+	// Profiles since 16.04 fail on a non-zero exit code by default
+	if g.galaxyTool.Stdio == nil && g.galaxyTool.Command != nil && !galaxy.ProfileAtLeast(g.profile(), "16.04") {
+		g.galaxyTool.Command.DetectErrors = "exit_code"
 	}
 
 	g.galaxyTool.Requirements.Container = []galaxy.Container{
@@ -265,24 +634,99 @@ func (g *GalaxyTranspiler) handleDockerImplementation(
 	return nil
 }
 
+// galaxyStdio translates the exit codes and error patterns of an
+// implementation into a <stdio>, nil if it has neither. Error patterns are
+// matched on stderr, and without exit codes every non-zero code is fatal.
+func galaxyStdio(impl *ast.ImplementationBlock) *galaxy.Stdio {
+	exitCodes, _ := impl.Fields["exit_codes"].([]any)
+	patterns, _ := impl.Fields["error_patterns"].([]any)
+	if len(exitCodes) == 0 && len(patterns) == 0 {
+		return nil
+	}
+	stdio := &galaxy.Stdio{}
+	for _, item := range exitCodes {
+		if entry, _ := item.([]any); len(entry) >= 2 {
+			stdio.ExitCode = append(stdio.ExitCode, galaxy.ExitCode{
+				Range:       fmt.Sprint(entry[0]),
+				Level:       fmt.Sprint(entry[1]),
+				Description: pairDescription(entry),
+			})
+		}
+	}
+	if len(stdio.ExitCode) == 0 {
+		stdio.ExitCode = []galaxy.ExitCode{{Range: "1:", Level: "fatal"}}
+	}
+	for _, item := range patterns {
+		if entry, _ := item.([]any); len(entry) >= 2 {
+			stdio.Regex = append(stdio.Regex, galaxy.Regex{
+				Match:       fmt.Sprint(entry[0]),
+				Source:      "stderr",
+				Level:       fmt.Sprint(entry[1]),
+				Description: pairDescription(entry),
+			})
+		}
+	}
+	return stdio
+}
+
+// pairDescription returns the optional third element of an exit code or
+// error pattern.
+func pairDescription(entry []any) string {
+	if len(entry) < 3 {
+		return ""
+	}
+	return fmt.Sprint(entry[2])
+}
+
+// appendArgument adds an argument to the command, if not empty.
+func (g *GalaxyTranspiler) appendArgument(arg string) {
+	command := g.galaxyTool.Command
+	if arg == "" {
+		return
+	}
+	if command.Value != "" && !strings.HasSuffix(command.Value, "\n") {
+		command.Value += " "
+	}
+	command.Value += arg
+}
+
+// appendConditionalArgument adds the argument of a conditional or optional
+// parameter to the command, in an #if block on its own lines.
+func (g *GalaxyTranspiler) appendConditionalArgument(arg, test string) {
+	command := g.galaxyTool.Command
+	if command.Value != "" && !strings.HasSuffix(command.Value, "\n") {
+		command.Value += "\n"
+	}
+	command.Value += "#if " + test + "\n  " + arg + "\n#end if\n"
+}
+
 // formatGalaxyArgument checks if the given string is a Baryon parameter name
-// and formats it into a Galaxy-compatible argument.
-func formatGalaxyArgument(arg string, params []ast.Parameter) string {
+// and formats it into a Galaxy-compatible argument: staged inputs by their
+// path in the job working directory, text values quoted, boolean flags, and
+// literal paths of the container by their path in the job working
+// directory.
+func formatGalaxyArgument(arg string, params []ast.Parameter, impl *ast.ImplementationBlock) string {
 	for _, param := range params {
 		if param.Name == arg {
 			// Check for Data Table metadata
 			if _, ok := param.Metadata["galaxy_data_table"]; ok {
-				return fmt.Sprintf("$%s.fields.path", param.Name)
+				return fmt.Sprintf("%s.fields.path", galaxyVariable(param, params))
 			}
 
-			// For file and directory types, Galaxy often uses .path or .name attributes
-			// For simplicity, we start with $param_name. For directories, use .path
-			if param.Type == TypeFile || param.Type == TypeDirectory {
-				return fmt.Sprintf("$%s.path", param.Name)
+			switch param.Type {
+			case TypeFile, TypeDirectory:
+				return "'" + galaxyStagedPath(param, params, impl) + "'"
+			case TypeBoolean:
+				return BooleanFlag(param.Name, params)
+			case TypeString, TypeCharacter, TypeEnum:
+				return "'" + galaxyVariable(param, params) + "'"
 			}
-			return fmt.Sprintf("$%s", param.Name)
+			return galaxyVariable(param, params)
 		}
 	}
+	if path.IsAbs(arg) {
+		arg = galaxyWorkPath(arg, galaxyMounts(impl))
+	}
 	// If it's not a parameter, and contains spaces, wrap in single quotes for basic shell safety
 	if strings.ContainsAny(arg, " \t\n\r") {
 		return fmt.Sprintf("'%s'", arg)
@@ -1,7 +1,12 @@
 package transpiler
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
@@ -12,11 +17,30 @@ func init() {
 		Extension:   ".nf",
 		Display:     "NextFlow",
 		Initializer: func() Transpiler { return NewNextflowTranspiler() },
+		Capabilities: &Capabilities{
+			ParameterTypes:  allTypes(),
+			Implementations: []string{"run_docker"},
+			Outputs:         OutputsPublished,
+			Limitations: map[string]Limitation{
+				typeFeature(TypeCharacter):                    {Degraded, "characters are declared as plain strings"},
+				fieldFeature("run_docker", "env"):             {Unsupported, "environment variables are not passed to the container"},
+				fieldFeature("run_docker", "volumes"):         {Unsupported, "volumes are not mounted in the docker invocation"},
+				fieldFeature("run_docker", "command"):         {Degraded, "the command field is ignored, use arguments instead"},
+				fieldFeature("run_docker", "version_command"): {Degraded, "the version command is only used by the Galaxy target"},
+				fieldFeature("run_docker", "exit_codes"):      {Degraded, "exit codes are only used by the Galaxy target, any non-zero code fails the run"},
+				fieldFeature("run_docker", "error_patterns"):  {Degraded, "error patterns are only used by the Galaxy target"},
+				fieldFeature("run_docker", "config_files"):    {Unsupported, "config files are only written by the Galaxy target"},
+				clauseFeature("when"):                         {Degraded, "conditional parameters are always used"},
+			},
+		},
 	})
 }
 
 type NextflowTranspiler struct {
 	TranspilerBase
+	steps       []nextflowStep // the processes of the program, in order
+	next        int            // the step of the next docker implementation
+	samplesheet *ast.Parameter // the samplesheet the samples are read from, if any
 }
 
 func NewNextflowTranspiler() *NextflowTranspiler {
@@ -28,24 +52,66 @@ func NewNextflowTranspiler() *NextflowTranspiler {
 
 // Transpile converts a Baryon program AST to Nextflow DSL code.
 func (n *NextflowTranspiler) Transpile(program *ast.Program) (string, error) {
-	n.Buffer.Reset()
+	return transpileToString(n, program)
+}
+
+// TranspileTo implements Transpiler.
+func (n *NextflowTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return n.transpileContext(context.Background(), w, program)
+}
+
+func (n *NextflowTranspiler) transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error {
+	c := NewNextflowTranspiler()
+	return n.call(ctx, &c.TranspilerBase, func() error { return c.transpile(w, program) })
+}
+
+// transpile writes the code of a program, on the transpiler of a call.
+func (n *NextflowTranspiler) transpile(w io.Writer, program *ast.Program) error {
+	program = targetProgram("nextflow", program)
+	n.SetOutput(w)
+	defer n.SetOutput(nil)
+	steps, err := nextflowSteps(program)
+	if err != nil {
+		return err
+	}
+	n.steps, n.next = steps, 0
+	if n.samplesheet, err = nextflowSamplesheet(program); err != nil {
+		return err
+	}
+
+	// A module only has its process
+	if n.nfCore() {
+		if len(steps) > 1 {
+			return fmt.Errorf("an nf-core module has a single process, found %d docker implementations", len(steps))
+		}
+		if n.samplesheet != nil {
+			return fmt.Errorf("samplesheet '%s': an nf-core module takes a sample in its meta map, the pipeline reads the samplesheet", n.samplesheet.Name)
+		}
+		if err := n.processImplementations(program); err != nil {
+			return fmt.Errorf("error processing implementations: %w", err)
+		}
+		return n.OutputError()
+	}
 
 	// Write workflow header
 	n.writeWorkflowHeader(program)
+	if n.useSchema() {
+		n.WriteLine("include { validateParameters } from 'plugin/nf-schema'")
+		n.WriteLine("")
+	}
 
 	// Write parameter declarations
 	n.writeParameters(program.Parameters)
 
 	// Write process blocks
-	err := n.processImplementations(program)
-	if err != nil {
-		return "", fmt.Errorf("error processing implementations: %w", err)
+	if err := n.processImplementations(program); err != nil {
+		return fmt.Errorf("error processing implementations: %w", err)
 	}
 
 	// Write workflow definition
 	n.writeWorkflow(program)
 
-	return n.Buffer.String(), nil
+	return n.OutputError()
 }
 
 func (n *NextflowTranspiler) writeWorkflowHeader(program *ast.Program) {
@@ -57,39 +123,79 @@ func (n *NextflowTranspiler) writeWorkflowHeader(program *ast.Program) {
 	n.WriteLine("")
 }
 
-// TODO: Improve parameter default value handling and quoting for Nextflow
+// nextflowLiteralSyntax spells literal values in Groovy.
+var nextflowLiteralSyntax = LiteralSyntax{True: "true", False: "false", Quote: groovyString}
+
+// groovyString returns the single-quoted Groovy string literal of s, which
+// doesn't interpolate ${...}.
+func groovyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + "'"
+}
+
+// writeParameters declares a param for each parameter, set to its default.
+// Booleans default to false, as flags, and the other parameters without a
+// default to null: the required ones are checked when the script starts,
+// and optional ones are left out of the command.
 func (n *NextflowTranspiler) writeParameters(params []ast.Parameter) {
 	n.WriteLine("// Input Parameters")
 	for _, param := range params {
-		defaultStr := ""
-		if param.Default != nil {
-			defaultStr = fmt.Sprintf(" = %v", param.Default)
-		}
-
-		switch param.Type {
-		case TypeString, TypeFile, TypeDirectory:
-			n.WriteLine("params.%s = '%s'", param.Name, defaultStr)
-		case TypeNumber, TypeInteger:
-			n.WriteLine("params.%s = %s", param.Name, defaultStr)
-		case TypeBoolean:
-			n.WriteLine("params.%s = s", param.Name, defaultStr)
-		case TypeEnum:
-			if len(param.Constraints) > 0 {
-				choices := make([]string, len(param.Constraints))
-				for i, c := range param.Constraints {
-					choices[i] = fmt.Sprintf("\"%v\"", c)
-				}
-				choicesStr := strings.Join(choices, ", ")
-				n.WriteLine("// Allowed values: %s", choicesStr)
-				n.WriteLine("params.%s = ''%s", param.Name, defaultStr)
-			}
-		case TypeCharacter:
-			n.WriteLine("params.%s = ''%s", param.Name, defaultStr)
+		if param.Description != "" {
+			n.WriteLine("// %s", strings.ReplaceAll(FormatDescription(param.Description), "\n", "\n// "))
+		}
+		if param.Type == TypeEnum && len(param.Constraints) > 0 {
+			choices := make([]string, len(param.Constraints))
+			for i, c := range param.Constraints {
+				choices[i] = FormatLiteral(c, nextflowLiteralSyntax)
+			}
+			n.WriteLine("// Allowed values: %s", strings.Join(choices, ", "))
+		}
+		n.WriteLine("params.%s = %s", param.Name, nextflowDefault(param))
+	}
+	n.WriteLine("// Directory the outputs are published to")
+	n.WriteLine("params.outdir = 'results'")
+	n.WriteLine("")
+
+	if n.useSchema() {
+		n.WriteLine("// Validate the params against %s", NextflowSchemaFile)
+		n.WriteLine("validateParameters()")
+		n.WriteLine("")
+		return
+	}
+	required := []ast.Parameter{}
+	for _, param := range params {
+		if nextflowRequired(param) {
+			required = append(required, param)
 		}
 	}
+	if len(required) == 0 {
+		return
+	}
+	n.WriteLine("// Required parameters")
+	for _, param := range required {
+		n.WriteLine("if (params.%s == null) {", param.Name)
+		n.WriteLine("  error %s", groovyString("Missing required parameter: --"+param.Name))
+		n.WriteLine("}")
+	}
 	n.WriteLine("")
 }
 
+// nextflowRequired reports whether the param of a parameter must be given:
+// it has no default, isn't optional and is always used.
+func nextflowRequired(param ast.Parameter) bool {
+	return nextflowDefault(param) == "null" && param.Metadata["optional"] != "true" && param.When == nil
+}
+
+// nextflowDefault returns the Groovy value of the param of a parameter.
+func nextflowDefault(param ast.Parameter) string {
+	switch {
+	case param.Default != nil:
+		return FormatLiteral(param.Default, nextflowLiteralSyntax)
+	case param.Type == TypeBoolean:
+		return "false"
+	}
+	return "null"
+}
+
 func (n *NextflowTranspiler) processImplementations(program *ast.Program) error {
 	if len(program.Implementations) == 0 {
 		n.WriteLine("// No implementation blocks found")
@@ -98,9 +204,12 @@ func (n *NextflowTranspiler) processImplementations(program *ast.Program) error
 	}
 
 	for _, impl := range program.Implementations {
+		if err := n.canceled(); err != nil {
+			return err
+		}
 		handler, ok := n.GetImplementationHandlers()[impl.Name]
 		if !ok {
-			return fmt.Errorf("no handler registered for implementation '%s'", impl.Name)
+			return unsupportedImplementation("nextflow", &impl, "no handler registered for implementation '%s'")
 		}
 
 		err := handler(n, &impl, program)
@@ -112,55 +221,421 @@ func (n *NextflowTranspiler) processImplementations(program *ast.Program) error
 	return nil
 }
 
+// handleDockerImplementation writes the process of a docker
+// implementation, run in its container by Nextflow: a path input for each
+// file and directory parameter of its step and a val input for the others,
+// a path input for each output of an earlier step it reads, a path output
+// for each of its outputs, copied to params.outdir, a script running the
+// arguments in the task work directory, which stands for /data, and a stub
+// creating empty outputs.
 func (n *NextflowTranspiler) handleDockerImplementation(t BaseTranspiler, impl *ast.ImplementationBlock, program *ast.Program) error {
 	image, ok := impl.Fields["image"].(string)
 	if !ok || image == "" {
 		return fmt.Errorf("Docker image not specified or invalid")
 	}
+	if n.nfCore() {
+		return n.writeModule(impl, program, image)
+	}
+	step := n.steps[n.next]
+	n.next++
+	outputs := step.Outputs
 
 	n.WriteLine("")
-	n.WriteLine("process %s {", impl.Name)
+	n.WriteLine("process %s {", step.Name)
 	n.SetIndentLevel(n.GetIndentLevel() + 1)
-	n.WriteLine("container '%s'", image)
+	// The tasks of the samples are told apart by their id
+	perSample := n.samplesheet != nil && nextflowSampleID(*n.samplesheet) != "" &&
+		slices.ContainsFunc(step.Params, func(p ast.Parameter) bool { return p.Name == n.samplesheet.Name })
+	if perSample {
+		n.WriteLine(`tag "${meta.id}"`)
+	}
+	if label := nextflowLabel(program); label != "" {
+		n.WriteLine("label %s", groovyString(label))
+	}
+	n.WriteLine("container %s", groovyString(nextflowContainer(image, n.engine())))
+	n.writeResources(program)
+	switch {
+	case len(outputs) > 0 && perSample:
+		n.WriteLine(`publishDir "${params.outdir}/${meta.id}", mode: 'copy'`)
+	case len(outputs) > 0:
+		// The outputs keep their path under /data
+		n.WriteLine("publishDir params.outdir, mode: 'copy'")
+	}
 
-	// Declare input parameters
-	n.WriteLine("input:")
-	for _, param := range program.Parameters {
-		n.WriteLine("val params.%s", param.Name)
+	if len(step.Params) > 0 || len(step.Inputs) > 0 {
+		n.WriteLine("")
+		n.WriteLine("input:")
+		for _, param := range step.Params {
+			qualifier := "val"
+			switch param.Type {
+			case TypeFile, TypeDirectory:
+				qualifier = "path"
+			case TypeSamplesheet:
+				// The files of a sample, with its meta map
+				n.WriteLine("tuple val(meta), path(%s)", param.Name)
+				continue
+			}
+			n.WriteLine("%s %s", qualifier, param.Name)
+		}
+		for _, input := range step.Inputs {
+			// Staged where the arguments read it, as under /data
+			n.WriteLine("path %s, stageAs: %s", input.Output.Name, groovyString(input.stageAs()))
+		}
 	}
 
-	// Declare output
-	n.WriteLine("output:")
-	n.WriteLine("path 'results/'")
+	if len(outputs) > 0 {
+		n.WriteLine("")
+		n.WriteLine("output:")
+		for _, output := range outputs {
+			n.WriteLine("path %s, emit: %s", groovyString(output.Path), output.Name)
+		}
+	}
 
-	// Script block
+	n.WriteLine("")
 	n.WriteLine("script:")
-	n.SetIndentLevel(n.GetIndentLevel() + 1)
-	n.WriteLine("def args = [")
-	if args, ok := impl.Fields["arguments"].([]any); ok {
-		for _, arg := range args {
-			argStr := fmt.Sprintf("%v", arg)
-			if IsParamReference(argStr, program.Parameters) {
-				n.WriteLine("params.%s,", argStr)
-			} else {
-				n.WriteLine("'%s',", argStr)
-			}
-		}
+	n.WriteLine(`"""`)
+	command := nextflowCommand(impl, program.Parameters)
+	if dirs := nextflowOutputDirs(outputs); len(dirs) > 0 {
+		command = gstringText("mkdir -p "+strings.Join(dirs, " ")) + " && " + command
+	}
+	if step.Capture != "" {
+		command += " > " + nextflowShellWord(step.Capture)
+	}
+	n.WriteLine("%s", command)
+	n.WriteLine(`"""`)
+	if stub := nextflowStub(outputs); stub != "" {
+		n.WriteLine("")
+		n.WriteLine("stub:")
+		n.WriteLine(`"""`)
+		n.WriteLine("%s", gstringText(stub))
+		n.WriteLine(`"""`)
 	}
-	n.WriteLine("].join(' ')")
-	n.WriteLine("sh 'docker run --rm %s $args'", image)
-	n.SetIndentLevel(n.GetIndentLevel() - 1)
 
 	n.SetIndentLevel(n.GetIndentLevel() - 1)
 	n.WriteLine("}")
 	return nil
 }
 
+// nextflowLabel returns the label of a process, which configs select to
+// override its resources: the requested one, or the nf-core label of its
+// number of CPUs.
+func nextflowLabel(program *ast.Program) string {
+	if label, ok := program.Resource(ast.ResourceLabel); ok {
+		return fmt.Sprint(label)
+	}
+	value, _ := program.Resource(ast.ResourceCPUs)
+	cpus, ok := value.(int)
+	switch {
+	case !ok:
+		return ""
+	case cpus <= 1:
+		return "process_single"
+	case cpus <= 2:
+		return "process_low"
+	case cpus <= 6:
+		return "process_medium"
+	}
+	return "process_high"
+}
+
+// writeResources writes the directives of the requested resources, and of
+// the scratch directory and the stage-in mode of the inputs. The settings
+// of a config take precedence over them. A failed task is retried as many
+// times as requested, with its memory and time multiplied by its attempt,
+// so that a task killed for lack of them can succeed.
+func (n *NextflowTranspiler) writeResources(program *ast.Program) {
+	value, _ := program.Resource(ast.ResourceRetries)
+	retries, _ := value.(int)
+	if cpus, ok := program.Resource(ast.ResourceCPUs); ok {
+		n.WriteLine("cpus %v", cpus)
+	}
+	for _, name := range []string{ast.ResourceMemory, ast.ResourceTime} {
+		amount, ok := program.Resource(name)
+		switch {
+		case !ok:
+		case retries > 0:
+			n.WriteLine("%s { %s }", name, nextflowEscalation(fmt.Sprint(amount)))
+		default:
+			n.WriteLine("%s %s", name, groovyString(fmt.Sprint(amount)))
+		}
+	}
+	if gpus, ok := program.Resource(ast.ResourceGPUs); ok {
+		accelerator := fmt.Sprint(gpus)
+		if gpuType, ok := program.Resource(ast.ResourceGPUType); ok {
+			accelerator += ", type: " + groovyString(fmt.Sprint(gpuType))
+		}
+		n.WriteLine("accelerator %s", accelerator)
+	}
+	switch scratch, _ := program.Resource(ast.ResourceScratch); scratch := scratch.(type) {
+	case bool:
+		n.WriteLine("scratch %t", scratch)
+	case string:
+		// Single quotes leave $TMPDIR to the shell of the task
+		n.WriteLine("scratch %s", groovyString(scratch))
+	}
+	if mode, ok := program.Resource(ast.ResourceStageInMode); ok {
+		n.WriteLine("stageInMode %s", groovyString(fmt.Sprint(mode)))
+	}
+	if retries > 0 {
+		n.WriteLine("errorStrategy 'retry'")
+		n.WriteLine("maxRetries %d", retries)
+	}
+}
+
+var resourceAmount = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// nextflowEscalation returns the GString of an amount of memory or time
+// multiplied by the attempt of the task, e.g. "${8 * task.attempt} GB".
+func nextflowEscalation(amount string) string {
+	return `"` + resourceAmount.ReplaceAllStringFunc(gstringText(amount), func(number string) string {
+		return "${" + number + " * task.attempt}"
+	}) + `"`
+}
+
+// nextflowOutputs returns the outputs of a program, with their paths in
+// the task work directory, and the file the standard output is written to.
+// The first output without a path captures it, in <name>.<format>.
+func nextflowOutputs(program *ast.Program) ([]ast.OutputBlock, string, error) {
+	mounts := nextflowMounts(program)
+	declared := []ast.OutputBlock{}
+	capture := ""
+	for _, output := range program.Outputs {
+		switch {
+		case output.Path != "":
+			work := galaxyWorkPath(path.Clean(output.Path), mounts)
+			if path.IsAbs(output.Path) && path.IsAbs(work) {
+				return nil, "", fmt.Errorf("output '%s' must be written under /data or a volume, which the task work directory stands for", output.Name)
+			}
+			output.Path = work
+		case capture == "":
+			capture = output.Name
+			if output.Format != "" && output.Format != TypeFile {
+				capture += "." + output.Format
+			}
+			output.Path = capture
+		default:
+			continue
+		}
+		declared = append(declared, output)
+	}
+	return declared, capture, nil
+}
+
+// nextflowMounts returns the guest paths the task work directory stands
+// for: /data and the volumes of the docker implementations.
+func nextflowMounts(program *ast.Program) []string {
+	mounts := []string{}
+	for i := range program.Implementations {
+		if program.Implementations[i].Name != "run_docker" {
+			continue
+		}
+		for _, mount := range galaxyMounts(&program.Implementations[i]) {
+			if !slices.Contains(mounts, mount) {
+				mounts = append(mounts, mount)
+			}
+		}
+	}
+	if len(mounts) == 0 {
+		mounts = append(mounts, "/data")
+	}
+	return mounts
+}
+
+// nextflowOutputDirs returns the directories to create in the task work
+// directory for the tool to write its outputs: the directory outputs and
+// the parents of the others, quoted for the shell.
+func nextflowOutputDirs(outputs []ast.OutputBlock) []string {
+	dirs := []string{}
+	for _, output := range outputs {
+		dir := output.Path
+		// A glob selects the files of the directory
+		if output.Format != TypeDirectory || strings.ContainsAny(path.Base(dir), "*?[") {
+			dir = path.Dir(dir)
+		}
+		if quoted := nextflowShellWord(dir); dir != "." && !slices.Contains(dirs, quoted) {
+			dirs = append(dirs, quoted)
+		}
+	}
+	return dirs
+}
+
+// nextflowStub returns the command of the stub of a process, run by
+// -stub-run instead of the script: it creates empty outputs, the
+// directories and a file under each other path, matching its glob.
+func nextflowStub(outputs []ast.OutputBlock) string {
+	files := []string{}
+	for _, output := range outputs {
+		if output.Format != TypeDirectory || strings.ContainsAny(path.Base(output.Path), "*?[") {
+			files = append(files, nextflowShellWord(stubFile(output.Path)))
+		}
+	}
+	commands := []string{}
+	if dirs := nextflowOutputDirs(outputs); len(dirs) > 0 {
+		commands = append(commands, "mkdir -p "+strings.Join(dirs, " "))
+	}
+	if len(files) > 0 {
+		commands = append(commands, "touch "+strings.Join(files, " "))
+	}
+	return strings.Join(commands, " && ")
+}
+
+var globClass = regexp.MustCompile(`\[(!|\^)?([^\]]?)[^\]]*\]`)
+
+// stubFile returns a file name matching a glob: a star matches "stub", a
+// question mark "0", and a class its first character, or "_" when it is
+// negated.
+func stubFile(glob string) string {
+	name := globClass.ReplaceAllStringFunc(glob, func(class string) string {
+		m := globClass.FindStringSubmatch(class)
+		if m[1] != "" || m[2] == "" {
+			return "_"
+		}
+		return m[2]
+	})
+	return strings.NewReplacer("*", "stub", "?", "0").Replace(name)
+}
+
+// nextflowCommand returns the command line of the script of a process, a
+// Groovy GString: the arguments, with the inputs interpolated and the paths
+// under /data or a volume relative to the task work directory. Booleans pass their flag
+// when true and optional parameters are left out without a value, with the
+// option before them.
+func nextflowCommand(impl *ast.ImplementationBlock, params []ast.Parameter) string {
+	args, _ := impl.Fields["arguments"].([]any)
+	words := []string{}
+	pending := "" // an option, e.g. -k, kept with the optional parameter after it
+	flush := func() {
+		if pending != "" {
+			words = append(words, gstringText(nextflowShellWord(pending)))
+			pending = ""
+		}
+	}
+	for _, arg := range args {
+		argStr, ok := arg.(string)
+		if !ok || argStr == "_" {
+			continue
+		}
+		i := slices.IndexFunc(params, func(p ast.Parameter) bool { return p.Name == argStr })
+		if i < 0 {
+			flush()
+			if path.IsAbs(argStr) {
+				argStr = galaxyWorkPath(path.Clean(argStr), galaxyMounts(impl))
+			}
+			if strings.HasPrefix(argStr, "-") {
+				pending = argStr
+				continue
+			}
+			words = append(words, gstringText(nextflowShellWord(argStr)))
+			continue
+		}
+		param := params[i]
+		switch {
+		case param.Type == TypeBoolean:
+			flush()
+			words = append(words, "${"+param.Name+" ? "+groovyString(BooleanFlag(param.Name, params))+" : ''}")
+		case param.Metadata["optional"] == "true":
+			value := nextflowValue(param)
+			if pending != "" {
+				value = groovyString(nextflowShellWord(pending)+" ") + " + " + value
+				pending = ""
+			}
+			// Paths without a value are an empty list, and 0 is false
+			test := param.Name + " != null"
+			if param.Type == TypeFile || param.Type == TypeDirectory {
+				test = param.Name
+			}
+			words = append(words, "${"+test+" ? "+value+" : ''}")
+		default:
+			flush()
+			words = append(words, nextflowInterpolation(param))
+		}
+	}
+	flush()
+	return strings.Join(words, " ")
+}
+
+// nextflowInterpolation returns the interpolation of a parameter in the
+// script, quoted for the shell unless it is a path or a number.
+func nextflowInterpolation(param ast.Parameter) string {
+	switch param.Type {
+	case TypeString, TypeCharacter, TypeEnum:
+		return "'${" + param.Name + "}'"
+	}
+	return "${" + param.Name + "}"
+}
+
+// nextflowValue returns the Groovy expression of the argument of a
+// parameter, the value of nextflowInterpolation.
+func nextflowValue(param ast.Parameter) string {
+	switch param.Type {
+	case TypeString, TypeCharacter, TypeEnum:
+		return `"'" + ` + param.Name + ` + "'"`
+	}
+	return param.Name + ".toString()"
+}
+
+// nextflowShellWord quotes a literal argument with spaces for the shell, as
+// the Galaxy target does. Other shell constructs are checked by the
+// shell-injection rule.
+func nextflowShellWord(arg string) string {
+	if strings.ContainsAny(arg, " \t\n\r") {
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return arg
+}
+
+// gstringText escapes text in a triple-quoted GString.
+func gstringText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "$", `\$`, `"`, `\"`).Replace(text)
+}
+
+// writeWorkflow writes the workflow running the processes of the program,
+// from a channel of the paths matching each file and directory parameter,
+// checked to exist, and the values of the other params. Optional paths
+// without a value are passed as an empty list, staging nothing. A
+// samplesheet is split into a channel of its samples, and the other paths
+// are then staged together for each sample. The outputs of a step read by
+// later ones are passed from its output channels.
 func (n *NextflowTranspiler) writeWorkflow(program *ast.Program) {
+	n.WriteLine("")
 	n.WriteLine("workflow {")
 	n.SetIndentLevel(n.GetIndentLevel() + 1)
-	for _, impl := range program.Implementations {
-		n.WriteLine("%s()", impl.Name)
+	if len(n.steps) > 0 {
+		channels := map[string]string{}
+		for _, param := range program.Parameters {
+			if param.Type == TypeSamplesheet {
+				n.writeSamplesheetChannel(param)
+				channels[param.Name] = param.Name + "_ch"
+				continue
+			}
+			if param.Type != TypeFile && param.Type != TypeDirectory {
+				channels[param.Name] = "params." + param.Name
+				continue
+			}
+			options := "checkIfExists: true"
+			if param.Type == TypeDirectory {
+				options = "type: 'dir', " + options
+			}
+			channel := fmt.Sprintf("Channel.fromPath(params.%s, %s)", param.Name, options)
+			if n.samplesheet != nil {
+				channel += ".collect()"
+			}
+			if param.Metadata["optional"] == "true" {
+				channel = fmt.Sprintf("params.%s ? file(params.%s, %s) : []", param.Name, param.Name, options)
+			}
+			n.WriteLine("%s_ch = %s", param.Name, channel)
+			channels[param.Name] = param.Name + "_ch"
+		}
+		for _, step := range n.steps {
+			inputs := []string{}
+			for _, param := range step.Params {
+				inputs = append(inputs, channels[param.Name])
+			}
+			for _, input := range step.Inputs {
+				inputs = append(inputs, input.From+".out."+input.Output.Name)
+			}
+			n.WriteLine("%s(%s)", step.Name, strings.Join(inputs, ", "))
+		}
 	}
 	n.SetIndentLevel(n.GetIndentLevel() - 1)
 	n.WriteLine("}")
@@ -0,0 +1,567 @@
+package transpiler
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func TestNextflow_Parameters(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "paired"}, Type: TypeBoolean},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "strict"}, Type: TypeBoolean, Default: true},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "ratio"}, Type: TypeNumber, Default: 0.5},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "label"}, Type: TypeString, Default: "it's ${x}\n"},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "seed"}, Type: TypeInteger, Metadata: map[string]string{"optional": "true"}})
+	code := transpileWithOptions(t, "nextflow", nil, program)
+	for _, expected := range []string{
+		"// Input reads\nparams.reads = null\n",
+		"params.sep = null\n",
+		"// Allowed values: 'fast', 'sensitive'\nparams.mode = 'fast'\n",
+		"params.threads = 4\n",
+		"params.paired = false\n",
+		"params.strict = true\n",
+		"params.ratio = 0.5\n",
+		`params.label = 'it\'s ${x}\n'` + "\n",
+		"params.seed = null\n// Directory the outputs are published to\nparams.outdir = 'results'\n",
+		"// Required parameters\n" +
+			"if (params.reads == null) {\n  error 'Missing required parameter: --reads'\n}\n" +
+			"if (params.sep == null) {\n  error 'Missing required parameter: --sep'\n}\n\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestNextflow_Process(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "index"}, Type: TypeDirectory},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "mates"}, Type: TypeFile, Metadata: map[string]string{"optional": "true"}},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "paired"}, Type: TypeBoolean, Metadata: map[string]string{"flag": "-p"}},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "seed"}, Type: TypeInteger, Metadata: map[string]string{"optional": "true"}})
+	program.Implementations[0].Fields["arguments"] = []any{
+		"bwa", "mem", "paired", "-k", "seed", "-t", "threads", "-o", "/data/results/out.sam", "read group", "index", "reads", "mode", "mates"}
+	program.Outputs = []ast.OutputBlock{
+		{NamedBaseNode: ast.NamedBaseNode{Name: "log"}, Format: "txt"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "aligned"}, Format: "sam", Path: "/data/results/out.sam"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "pages"}, Format: TypeDirectory, Path: "/data/site/page_*.html"},
+	}
+	code := transpileWithOptions(t, "nextflow", nil, program)
+	for _, expected := range []string{
+		"process align_reads {\n  container 'biocontainers/bwa:0.7.17'\n  publishDir params.outdir, mode: 'copy'\n",
+		"  input:\n  path reads\n  val sep\n  val mode\n  val threads\n  path index\n  path mates\n  val paired\n  val seed\n",
+		"  output:\n  path 'log.txt', emit: log\n  path 'results/out.sam', emit: aligned\n  path 'site/page_*.html', emit: pages\n",
+		"  script:\n  \"\"\"\n  mkdir -p results site && bwa mem ${paired ? '-p' : ''} ${seed != null ? '-k ' + seed.toString() : ''} " +
+			"-t ${threads} -o results/out.sam 'read group' ${index} ${reads} '${mode}' ${mates ? mates.toString() : ''} > log.txt\n  \"\"\"\n  \n" +
+			"  stub:\n  \"\"\"\n  mkdir -p results site && touch log.txt results/out.sam site/page_stub.html\n  \"\"\"\n}\n",
+		"workflow {\n" +
+			"  reads_ch = Channel.fromPath(params.reads, checkIfExists: true)\n" +
+			"  index_ch = Channel.fromPath(params.index, type: 'dir', checkIfExists: true)\n" +
+			"  mates_ch = params.mates ? file(params.mates, checkIfExists: true) : []\n" +
+			"  align_reads(reads_ch, params.sep, params.mode, params.threads, index_ch, mates_ch, params.paired, params.seed)\n}\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	// Nothing is published or stubbed without outputs
+	program.Outputs = nil
+	if code := transpileWithOptions(t, "nextflow", nil, program); strings.Contains(code, "publishDir") || strings.Contains(code, "stub:") {
+		t.Errorf("expected no publishDir and no stub without outputs:\n%s", code)
+	}
+
+	// The task work directory stands for /data and the volumes only
+	program.Outputs = []ast.OutputBlock{{NamedBaseNode: ast.NamedBaseNode{Name: "out"}, Format: TypeFile, Path: "/scratch/out.txt"}}
+	descriptor, _ := GetTranspiler("nextflow")
+	if _, err := descriptor.Initializer().Transpile(program); err == nil || !strings.Contains(err.Error(), "output 'out'") {
+		t.Errorf("expected an error for an output outside /data, got %v", err)
+	}
+}
+
+func TestNextflow_Volumes(t *testing.T) {
+	source, err := os.ReadFile("../../examples/enrichment_analysis.bala")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := transpileWithOptions(t, "nextflow", nil, parseSource(t, string(source)))
+	for _, expected := range []string{
+		"  output:\n  path 'scratch/aligned_results', emit: aligned_results\n",
+		"  mkdir -p scratch/aligned_results && /home/qiime_full.sh\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestNextflow_Resources(t *testing.T) {
+	program := alignProgram()
+	program.Resources = []ast.Resource{
+		{Name: ast.ResourceCPUs, Value: 8},
+		{Name: ast.ResourceMemory, Value: "16 GB"},
+		{Name: ast.ResourceTime, Value: "1h 30m"},
+		{Name: ast.ResourceGPUs, Value: 1},
+		{Name: ast.ResourceGPUType, Value: "nvidia-tesla-v100"},
+	}
+	code := transpileWithOptions(t, "nextflow", nil, program)
+	expected := "process align_reads {\n  label 'process_high'\n  container 'biocontainers/bwa:0.7.17'\n" +
+		"  cpus 8\n  memory '16 GB'\n  time '1h 30m'\n  accelerator 1, type: 'nvidia-tesla-v100'\n"
+	if !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+
+	// The label follows the CPUs, unless it is requested
+	for _, tt := range []struct {
+		resources []ast.Resource
+		label     string
+	}{
+		{[]ast.Resource{{Name: ast.ResourceCPUs, Value: 1}}, "process_single"},
+		{[]ast.Resource{{Name: ast.ResourceCPUs, Value: 2}}, "process_low"},
+		{[]ast.Resource{{Name: ast.ResourceCPUs, Value: 6}}, "process_medium"},
+		{[]ast.Resource{{Name: ast.ResourceCPUs, Value: 2}, {Name: ast.ResourceLabel, Value: "bwa_mem"}}, "bwa_mem"},
+	} {
+		program.Resources = tt.resources
+		if code := transpileWithOptions(t, "nextflow", nil, program); !strings.Contains(code, "  label '"+tt.label+"'\n") {
+			t.Errorf("expected label %s for %v:\n%s", tt.label, tt.resources, code)
+		}
+	}
+
+	program.Resources = nil
+	if code := transpileWithOptions(t, "nextflow", nil, program); strings.Contains(code, "label") {
+		t.Errorf("expected no label without resources:\n%s", code)
+	}
+}
+
+func TestStubFile(t *testing.T) {
+	for glob, expected := range map[string]string{
+		"out.sam":          "out.sam",
+		"site/page_*.html": "site/page_stub.html",
+		"chr?.vcf":         "chr0.vcf",
+		"run[12].log":      "run1.log",
+		"run[!12].log":     "run_.log",
+	} {
+		if got := stubFile(glob); got != expected {
+			t.Errorf("stubFile(%q) = %q, want %q", glob, got, expected)
+		}
+	}
+}
+
+func TestNextflow_TranspilePackage(t *testing.T) {
+	program := alignProgram()
+	program.Description = "Align reads"
+	program.Metadata = map[string]string{"version": "1.2.0"}
+
+	files, err := NewNextflowTranspiler().TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+
+	expected := map[string][]string{
+		NextflowMainScript: {"process align_reads {\n"},
+		NextflowConfigFile: {
+			"manifest {\n  name = 'align_reads'\n  description = 'Align reads'\n  version = '1.2.0'\n  mainScript = 'main.nf'\n}\n",
+			"params {\n  reads = null\n  sep = null\n  mode = 'fast'\n  threads = 4\n  outdir = 'results'\n}\n",
+			"process {\n  withName: 'align_reads' {\n    container = 'biocontainers/bwa:0.7.17'\n    conda = 'bioconda::bwa=0.7.17'\n  }\n}\n",
+			"  docker {\n    docker.enabled = true\n",
+			"  singularity {\n    singularity.enabled = true\n    singularity.autoMounts = true\n  }\n",
+			"  conda {\n    conda.enabled = true\n  }\n",
+		},
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("TranspilePackage() files = %v, want %d files", files, len(expected))
+	}
+	for path, fragments := range expected {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+}
+
+func TestBiocondaPackage(t *testing.T) {
+	for reference, expected := range map[string]string{
+		"quay.io/biocontainers/samtools:1.17--h00cdaf9_0": "bioconda::samtools=1.17",
+		"biocontainers/bwa:v0.7.17_cv1":                   "bioconda::bwa=0.7.17",
+		"biocontainers/bwa:0.7.17":                        "bioconda::bwa=0.7.17",
+		"biocontainers/bwa":                               "",
+		"ubuntu:22.04":                                    "",
+	} {
+		if got := biocondaPackage(reference); got != expected {
+			t.Errorf("biocondaPackage(%q) = %q, want %q", reference, got, expected)
+		}
+	}
+}
+
+func TestNextflow_NFCoreModule(t *testing.T) {
+	program := alignProgram()
+	program.Description = "Align reads"
+	program.Implementations[0].Fields["image"] = "quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8"
+	program.Implementations[0].Fields["version_command"] = "bwa 2>&1 | grep Version"
+	program.Implementations[0].Fields["arguments"] = []any{"bwa", "mem", "-t", "threads", "reads", "mode"}
+	program.Outputs = []ast.OutputBlock{
+		{NamedBaseNode: ast.NamedBaseNode{Name: "aligned", BaseNode: ast.BaseNode{Description: "Alignments"}}, Format: "sam", Path: "/data/out.sam"},
+	}
+
+	tr := NewNextflowTranspiler()
+	if err := ApplyOptions(tr, "nextflow", map[string]string{nextflowStyle: "nf-core"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+
+	versions := "  cat <<-END_VERSIONS > versions.yml\n  \"${task.process}\":\n      bwa: \\$(bwa 2>&1 | grep Version)\n  END_VERSIONS\n"
+	expected := map[string][]string{
+		NextflowMainScript: {
+			"process ALIGN_READS {\n  tag \"$meta.id\"\n  label 'process_single'\n  \n  conda \"${moduleDir}/environment.yml\"\n",
+			"    'https://depot.galaxyproject.org/singularity/bwa:0.7.17--h5bf99c6_8' :\n" +
+				"    'quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8' }\"\n",
+			"  input:\n  tuple val(meta), path(reads)\n  val sep\n  val mode\n  val threads\n",
+			"  output:\n  tuple val(meta), path('out.sam'), emit: aligned\n  path 'versions.yml', emit: versions\n",
+			"  when:\n  task.ext.when == null || task.ext.when\n",
+			"  script:\n  def args = task.ext.args ?: ''\n  \"\"\"\n  bwa mem $args -t ${threads} ${reads} '${mode}'\n  \n" + versions,
+			"  stub:\n  \"\"\"\n  touch out.sam\n  \n" + versions,
+		},
+		NFCoreMetaFile: {
+			"name: \"align_reads\"\ndescription: \"Align reads\"\n",
+			"tools:\n  - \"bwa\":\n",
+			"input:\n  - meta:\n      type: map\n",
+			"  - reads:\n      type: file\n      description: \"Input reads\"\n",
+			"  - aligned:\n      type: file\n      description: \"Alignments\"\n      pattern: \"out.sam\"\n",
+			"  - versions:\n      type: file\n",
+		},
+		NFCoreEnvironmentFile: {"dependencies:\n  - bioconda::bwa=0.7.17\n"},
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("TranspilePackage() files = %v, want %d files", files, len(expected))
+	}
+	for path, fragments := range expected {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+	if strings.Contains(contents[NextflowMainScript], "workflow {") || strings.Contains(contents[NextflowMainScript], "params.") {
+		t.Errorf("expected a module without params and workflow:\n%s", contents[NextflowMainScript])
+	}
+
+	// The script of a module defines its own variables
+	program.Parameters = append(program.Parameters, ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "meta"}, Type: TypeString})
+	if _, err := tr.Transpile(program); err == nil || !strings.Contains(err.Error(), "parameter 'meta' clashes") {
+		t.Errorf("expected an error for a parameter named meta, got %v", err)
+	}
+}
+
+func TestNextflow_Retries(t *testing.T) {
+	program := alignProgram()
+	program.Resources = []ast.Resource{
+		{Name: ast.ResourceMemory, Value: "1.5 GB"},
+		{Name: ast.ResourceTime, Value: "1h 30m"},
+		{Name: ast.ResourceRetries, Value: 2},
+	}
+	code := transpileWithOptions(t, "nextflow", nil, program)
+	expected := "  memory { \"${1.5 * task.attempt} GB\" }\n  time { \"${1 * task.attempt}h ${30 * task.attempt}m\" }\n" +
+		"  errorStrategy 'retry'\n  maxRetries 2\n"
+	if !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+
+	// Without retries, the resources are fixed
+	program.Resources[2].Value = 0
+	code = transpileWithOptions(t, "nextflow", nil, program)
+	if !strings.Contains(code, "  memory '1.5 GB'\n  time '1h 30m'\n") || strings.Contains(code, "errorStrategy") {
+		t.Errorf("expected fixed resources without retries:\n%s", code)
+	}
+}
+
+func TestNextflow_Scratch(t *testing.T) {
+	program := alignProgram()
+	program.Resources = []ast.Resource{
+		{Name: ast.ResourceScratch, Value: "$TMPDIR"},
+		{Name: ast.ResourceStageInMode, Value: "copy"},
+		{Name: ast.ResourceWorkDir, Value: "/shared/work"},
+	}
+	files, err := NewNextflowTranspiler().TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	if expected := "  container 'biocontainers/bwa:0.7.17'\n  scratch '$TMPDIR'\n  stageInMode 'copy'\n"; !strings.Contains(files[0].Content, expected) {
+		t.Errorf("%s does not contain %q:\n%s", files[0].Path, expected, files[0].Content)
+	}
+	if expected := "}\n\nworkDir = '/shared/work'\n"; !strings.Contains(files[1].Content, expected) {
+		t.Errorf("%s does not contain %q:\n%s", files[1].Path, expected, files[1].Content)
+	}
+
+	program.Resources = []ast.Resource{{Name: ast.ResourceScratch, Value: true}}
+	if code := transpileWithOptions(t, "nextflow", nil, program); !strings.Contains(code, "  scratch true\n") {
+		t.Errorf("generated code does not contain scratch true:\n%s", code)
+	}
+}
+
+func TestNextflow_Schema(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[3].Metadata = map[string]string{"min": "1", "max": "64"}
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "index"}, Type: TypeDirectory},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "paired"}, Type: TypeBoolean},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "group"}, Type: TypeString, Default: "rg1",
+			Metadata: map[string]string{"pattern": "^[a-z0-9]+$"}})
+
+	tr := NewNextflowTranspiler()
+	if err := ApplyOptions(tr, "nextflow", map[string]string{nextflowValidate: "nf-schema"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Required   []string                  `json:"required"`
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+		AllOf []map[string]string `json:"allOf"`
+	}
+	if err := json.Unmarshal([]byte(contents[NextflowSchemaFile]), &schema); err != nil {
+		t.Fatalf("invalid %s: %v\n%s", NextflowSchemaFile, err, contents[NextflowSchemaFile])
+	}
+	group := schema.Defs["input_output_options"]
+	if !reflect.DeepEqual(group.Required, []string{"reads", "sep", "index"}) {
+		t.Errorf("required = %v, want reads, sep and index", group.Required)
+	}
+	if len(schema.AllOf) != 1 || schema.AllOf[0]["$ref"] != "#/$defs/input_output_options" {
+		t.Errorf("allOf = %v, want a reference to the group", schema.AllOf)
+	}
+	expected := map[string]map[string]any{
+		"reads":   {"type": "string", "format": "file-path-pattern", "description": "Input reads"},
+		"sep":     {"type": "string", "minLength": 1.0, "maxLength": 1.0},
+		"mode":    {"type": "string", "enum": []any{"fast", "sensitive"}, "default": "fast"},
+		"threads": {"type": "integer", "default": 4.0, "minimum": 1.0, "maximum": 64.0},
+		"index":   {"type": "string", "format": "directory-path", "exists": true},
+		"paired":  {"type": "boolean", "default": false},
+		"group":   {"type": "string", "pattern": "^[a-z0-9]+$", "default": "rg1"},
+		"outdir":  {"type": "string", "format": "directory-path", "default": "results", "description": "Directory the outputs are published to"},
+	}
+	if !reflect.DeepEqual(group.Properties, expected) {
+		t.Errorf("properties = %v, want %v", group.Properties, expected)
+	}
+	if i := strings.Index(contents[NextflowSchemaFile], `"reads"`); i < 0 || i > strings.Index(contents[NextflowSchemaFile], `"outdir"`) {
+		t.Errorf("expected the properties in the order of the parameters:\n%s", contents[NextflowSchemaFile])
+	}
+
+	for path, fragments := range map[string][]string{
+		NextflowMainScript: {
+			"include { validateParameters } from 'plugin/nf-schema'\n",
+			"// Validate the params against nextflow_schema.json\nvalidateParameters()\n",
+		},
+		NextflowConfigFile: {"plugins {\n  id 'nf-schema@2.3.0'\n}\n"},
+	} {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+	if strings.Contains(contents[NextflowMainScript], "Missing required parameter") {
+		t.Errorf("expected nf-schema to check the required params:\n%s", contents[NextflowMainScript])
+	}
+}
+
+func TestNextflow_SchemaHiddenAdvanced(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[2].Metadata = map[string]string{"hidden": "true"}
+	program.Parameters[3].Metadata = map[string]string{"advanced": "true"}
+	tr := NewNextflowTranspiler()
+	if err := ApplyOptions(tr, "nextflow", map[string]string{nextflowValidate: "nf-schema"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Title      string                    `json:"title"`
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"$defs"`
+		AllOf []map[string]string `json:"allOf"`
+	}
+	if err := json.Unmarshal([]byte(contents[NextflowSchemaFile]), &schema); err != nil {
+		t.Fatalf("invalid %s: %v\n%s", NextflowSchemaFile, err, contents[NextflowSchemaFile])
+	}
+	if hidden := schema.Defs["input_output_options"].Properties["mode"]["hidden"]; hidden != true {
+		t.Errorf("mode hidden = %v, want true", hidden)
+	}
+	advanced := schema.Defs["advanced_options"]
+	if advanced.Title != "Advanced options" || advanced.Properties["threads"] == nil {
+		t.Errorf("expected threads in the advanced options group, got %v", schema.Defs)
+	}
+	if _, ok := schema.Defs["input_output_options"].Properties["threads"]; ok {
+		t.Errorf("expected threads out of the input/output options group")
+	}
+	if len(schema.AllOf) != 2 || schema.AllOf[1]["$ref"] != "#/$defs/advanced_options" {
+		t.Errorf("allOf = %v, want a reference to each group", schema.AllOf)
+	}
+}
+
+func TestNextflow_Steps(t *testing.T) {
+	program := alignProgram()
+	program.Implementations[0].Fields["arguments"] = []any{"bwa", "mem", "-t", "threads", "reads", "-o", "/data/bam/aligned.bam"}
+	program.Implementations = append(program.Implementations,
+		ast.ImplementationBlock{Name: "run_docker", Fields: map[string]any{
+			"image":     "quay.io/biocontainers/samtools:1.17--h00cdaf9_0",
+			"arguments": []any{"samtools", "sort", "-o", "/data/sorted.bam", "/data/bam/aligned.bam"},
+		}},
+		ast.ImplementationBlock{Name: "run_docker", Fields: map[string]any{
+			"image":     "quay.io/biocontainers/samtools:1.17--h00cdaf9_0",
+			"arguments": []any{"samtools", "flagstat", "mode", "/data/sorted.bam", "/data/bam"},
+		}})
+	program.Outputs = []ast.OutputBlock{
+		{NamedBaseNode: ast.NamedBaseNode{Name: "aligned"}, Format: TypeDirectory, Path: "/data/bam/*.bam"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "sorted"}, Format: TypeFile, Path: "/data/sorted.bam"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "stats"}, Format: "txt"},
+	}
+	code := transpileWithOptions(t, "nextflow", nil, program)
+	for _, expected := range []string{
+		"process align_reads_step1 {\n",
+		"  input:\n  path reads\n  val threads\n  \n  output:\n  path 'bam/*.bam', emit: aligned\n",
+		"process align_reads_step2 {\n  container 'quay.io/biocontainers/samtools:1.17--h00cdaf9_0'\n",
+		"  input:\n  path aligned, stageAs: 'bam/*'\n  \n  output:\n  path 'sorted.bam', emit: sorted\n",
+		"  input:\n  val mode\n  path aligned, stageAs: 'bam/*'\n  path sorted, stageAs: 'sorted.bam'\n  \n  output:\n  path 'stats.txt', emit: stats\n",
+		"  samtools flagstat '${mode}' sorted.bam bam > stats.txt\n",
+		"  reads_ch = Channel.fromPath(params.reads, checkIfExists: true)\n" +
+			"  align_reads_step1(reads_ch, params.threads)\n" +
+			"  align_reads_step2(align_reads_step1.out.aligned)\n" +
+			"  align_reads_step3(params.mode, align_reads_step1.out.aligned, align_reads_step2.out.sorted)\n}\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	// Each step runs in its own container
+	files, err := NewNextflowTranspiler().TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	if config := files[1].Content; !strings.Contains(config, "  withName: 'align_reads_step1' {\n    container = 'biocontainers/bwa:0.7.17'\n") ||
+		!strings.Contains(config, "  withName: 'align_reads_step3' {\n    container = 'quay.io/biocontainers/samtools:1.17--h00cdaf9_0'\n") {
+		t.Errorf("expected a container for each step:\n%s", config)
+	}
+
+	// A module has a single process
+	tr := NewNextflowTranspiler()
+	if err := ApplyOptions(tr, "nextflow", map[string]string{nextflowStyle: "nf-core"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	if _, err := tr.Transpile(program); err == nil || !strings.Contains(err.Error(), "single process") {
+		t.Errorf("expected an error for an nf-core module with several steps, got %v", err)
+	}
+}
+
+func TestNextflow_Samplesheet(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[0] = ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "reads"}, Type: TypeSamplesheet,
+		Metadata: map[string]string{"columns": "sample, single:boolean, fastq_1:file, index:directory, depth:number"}}
+	program.Parameters = append(program.Parameters, ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "genome"}, Type: TypeFile})
+	program.Implementations[0].Fields["arguments"] = []any{"bwa", "mem", "genome", "reads", "mode"}
+	program.Outputs = []ast.OutputBlock{{NamedBaseNode: ast.NamedBaseNode{Name: "aligned"}, Format: "sam", Path: "/data/out.sam"}}
+	code := transpileWithOptions(t, "nextflow", nil, program)
+	for _, expected := range []string{
+		"process align_reads {\n  tag \"${meta.id}\"\n  container 'biocontainers/bwa:0.7.17'\n" +
+			"  publishDir \"${params.outdir}/${meta.id}\", mode: 'copy'\n",
+		"  input:\n  tuple val(meta), path(reads)\n  val sep\n",
+		"  bwa mem ${genome} ${reads} '${mode}'\n",
+		"  reads_ch = Channel.fromPath(params.reads, checkIfExists: true)\n" +
+			"    .splitCsv(header: true)\n" +
+			"    .map { row -> tuple([id: row.sample, single: row.single.toBoolean(), depth: row.depth as Double], " +
+			"[file(row.fastq_1, checkIfExists: true), file(row.index, type: 'dir', checkIfExists: true)]) }\n" +
+			"  genome_ch = Channel.fromPath(params.genome, checkIfExists: true).collect()\n" +
+			"  align_reads(reads_ch, params.sep, params.mode, params.threads, genome_ch)\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	// The other targets pass the samplesheet as a file
+	if code := transpileWithOptions(t, "python", nil, program); !strings.Contains(code, "os.path.isfile(reads_path)") {
+		t.Errorf("expected the samplesheet to be checked as a file:\n%s", code)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*ast.Program)
+		err    string
+	}{
+		{"two samplesheets", func(p *ast.Program) { p.Parameters[4].Type = TypeSamplesheet }, "a single samplesheet"},
+		{"optional", func(p *ast.Program) { p.Parameters[0].Metadata["optional"] = "true" }, "must be required"},
+		{"meta", func(p *ast.Program) { p.Parameters[4].Name = "meta" }, "clashes with the meta map"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clone := *program
+			clone.Parameters = append([]ast.Parameter{}, program.Parameters...)
+			clone.Parameters[0].Metadata = map[string]string{"columns": "sample, fastq_1:file"}
+			tt.modify(&clone)
+			if _, err := NewNextflowTranspiler().Transpile(&clone); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNextflow_Engine(t *testing.T) {
+	program := alignProgram()
+	for engine, container := range map[string]string{
+		"docker":      "biocontainers/bwa:0.7.17",
+		"singularity": "docker://docker.io/biocontainers/bwa:0.7.17",
+		"podman":      "docker.io/biocontainers/bwa:0.7.17",
+	} {
+		tr := NewNextflowTranspiler()
+		if err := ApplyOptions(tr, "nextflow", map[string]string{nextflowEngine: engine}); err != nil {
+			t.Fatalf("ApplyOptions() unexpected error: %v", err)
+		}
+		files, err := tr.TranspilePackage(program)
+		if err != nil {
+			t.Fatalf("TranspilePackage() unexpected error: %v", err)
+		}
+		if expected := "  container '" + container + "'\n"; !strings.Contains(files[0].Content, expected) {
+			t.Errorf("%s: %s does not contain %q:\n%s", engine, files[0].Path, expected, files[0].Content)
+		}
+		if expected := "    container = '" + container + "'\n"; !strings.Contains(files[1].Content, expected) {
+			t.Errorf("%s: %s does not contain %q:\n%s", engine, files[1].Path, expected, files[1].Content)
+		}
+		if !strings.Contains(files[1].Content, "  podman {\n    podman.enabled = true\n  }\n") {
+			t.Errorf("%s: expected a podman profile:\n%s", engine, files[1].Content)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package transpiler
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/naming"
 )
 
 func init() {
@@ -12,12 +17,57 @@ func init() {
 		Extension:   ".py",
 		Display:     "Python 3",
 		Initializer: func() Transpiler { return NewPythonTranspiler() },
+		Capabilities: &Capabilities{
+			ParameterTypes:  allTypes(),
+			Implementations: []string{"run_docker"},
+			Outputs:         OutputsDirectory,
+			Limitations: map[string]Limitation{
+				fieldFeature("run_docker", "command"):         {Degraded, "the command field is ignored, use arguments instead"},
+				fieldFeature("run_docker", "version_command"): {Degraded, "the version command is only used by the Galaxy target"},
+				fieldFeature("run_docker", "exit_codes"):      {Degraded, "exit codes are only used by the Galaxy target, any non-zero code fails the run"},
+				fieldFeature("run_docker", "error_patterns"):  {Degraded, "error patterns are only used by the Galaxy target"},
+				fieldFeature("run_docker", "config_files"):    {Unsupported, "config files are only written by the Galaxy target"},
+				blockFeature("resources"):                     {Degraded, "resources are only requested by the Nextflow target"},
+				typeFeature(TypeSamplesheet):                  {Degraded, "samplesheets are passed as a CSV file, the tool reads its samples"},
+				clauseFeature("when"):                         {Degraded, "conditional parameters are always used"},
+				outputFeature("*"):                            {Degraded, "outputs are not declared, results are returned as a directory"},
+			},
+		},
 	})
 }
 
+// pythonLiteralSyntax spells literal values in Python.
+var pythonLiteralSyntax = LiteralSyntax{True: "True", False: "False", Quote: strconv.Quote}
+
+// Python target options.
+const (
+	// pythonModels selects how parameters are validated: "checks" writes
+	// isinstance checks, "pydantic" a pydantic model of the parameters.
+	pythonModels = "models"
+	// pythonDocker selects how containers are run: "cli" builds a docker
+	// command, "sdk" uses the docker Python SDK.
+	pythonDocker = "docker"
+	// pythonAsync generates an async def wrapper, running the container
+	// with asyncio, when "true".
+	pythonAsync = "async"
+	// pythonInstrument records the wall time and the CPU and memory usage
+	// of the container in the result and the manifest, when "true".
+	pythonInstrument = "instrument"
+	// pythonWorkDir selects what is mounted into the container: "input"
+	// mounts the directory of the first input, "temp" a temporary work
+	// directory of the run, with the inputs staged into it.
+	pythonWorkDir = "workdir"
+	// pythonLog selects how the wrapper logs: "text" writes messages, "json"
+	// JSON lines recording the events of the run and their fields.
+	pythonLog = "logging"
+)
+
 // PythonTranspiler converts Baryon's ast.Program to Python code.
 type PythonTranspiler struct {
 	TranspilerBase
+	// mainFunction wraps the entry point in a main function, for the
+	// console script of a package.
+	mainFunction bool
 }
 
 // NewPythonTranspiler creates a new PythonTranspiler instance with default handlers.
@@ -45,23 +95,199 @@ func NewPythonTranspiler() *PythonTranspiler {
 	return t
 }
 
+// TargetOptions implements Configurable.
+func (t *PythonTranspiler) TargetOptions() []TargetOption {
+	return []TargetOption{
+		{Name: pythonModels, Values: []string{"checks", "pydantic"}, Default: "checks",
+			Help: "validate the parameters with isinstance checks or a pydantic model"},
+		{Name: pythonDocker, Values: []string{"cli", "sdk"}, Default: "cli",
+			Help: "run the container with the docker command or the docker Python SDK"},
+		{Name: pythonAsync, Values: []string{"false", "true"}, Default: "false",
+			Help: "generate an async def wrapper that callers can await"},
+		{Name: pythonInstrument, Values: []string{"false", "true"}, Default: "false",
+			Help: "record the wall time and the CPU and memory usage of the container"},
+		{Name: pythonWorkDir, Values: []string{"input", "temp"}, Default: "input",
+			Help: "mount the directory of the inputs, or stage them in a temporary work directory"},
+		{Name: pythonLog, Values: []string{"text", "json"}, Default: "text",
+			Help: "log messages, or the events of the runs as JSON lines"},
+	}
+}
+
+// usePydantic reports whether the parameters are validated by a pydantic
+// model.
+func (t *PythonTranspiler) usePydantic() bool {
+	return t.option(pythonModels, "checks") == "pydantic"
+}
+
+// useDockerSDK reports whether containers are run with the docker SDK.
+func (t *PythonTranspiler) useDockerSDK() bool {
+	return t.option(pythonDocker, "cli") == "sdk"
+}
+
+// useAsync reports whether the wrapper is generated as an async def.
+func (t *PythonTranspiler) useAsync() bool {
+	return t.option(pythonAsync, "false") == "true"
+}
+
+// useInstrumentation reports whether the resource usage of the runs is
+// recorded.
+func (t *PythonTranspiler) useInstrumentation() bool {
+	return t.option(pythonInstrument, "false") == "true"
+}
+
+// useWorkDir reports whether the runs stage their inputs in a temporary
+// work directory.
+func (t *PythonTranspiler) useWorkDir() bool {
+	return t.option(pythonWorkDir, "input") == "temp"
+}
+
+// useJSONLogging reports whether the events of the runs are logged as JSON
+// lines.
+func (t *PythonTranspiler) useJSONLogging() bool {
+	return t.option(pythonLog, "text") == "json"
+}
+
+// writeLog writes a logger call at a level, such as "info". With JSON
+// logging, it is a log_event call, recording the event and its fields,
+// given as name=expression.
+func (t *PythonTranspiler) writeLog(level, event, message string, fields ...string) {
+	if !t.useJSONLogging() {
+		t.WriteLine("logger.%s(%s)", level, message)
+		return
+	}
+	args := append([]string{"logging." + strings.ToUpper(level), strconv.Quote(event), message}, fields...)
+	t.WriteLine("log_event(%s)", strings.Join(args, ", "))
+}
+
+// dockerParameters returns the parameters of the run_docker helpers.
+func (t *PythonTranspiler) dockerParameters() string {
+	params := "image: str, volumes: Dict[str, str], env: Dict[str, str], args: List[str]"
+	if t.useInstrumentation() {
+		params += ", usage: Optional[Dict[str, Any]] = None"
+	}
+	return params + ", log_files: Optional[Dict[str, str]] = None"
+}
+
 // Transpile converts a Baryon program AST to Python code
 func (t *PythonTranspiler) Transpile(program *ast.Program) (string, error) {
-	t.Buffer.Reset()
+	return transpileToString(t, program)
+}
+
+// TranspileTo implements Transpiler.
+func (t *PythonTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return t.transpileContext(context.Background(), w, program)
+}
+
+func (t *PythonTranspiler) transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error {
+	c := NewPythonTranspiler()
+	return t.call(ctx, &c.TranspilerBase, func() error { return c.transpile(w, program) })
+}
+
+// TranspilePackage implements Packaged: the package has a pyproject.toml
+// installing the program as a console script, and the generated code as
+// the tool module of src/<name>.
+func (t *PythonTranspiler) TranspilePackage(program *ast.Program) ([]PackageFile, error) {
+	var code strings.Builder
+	c := NewPythonTranspiler()
+	err := t.call(context.Background(), &c.TranspilerBase, func() error {
+		c.mainFunction = true
+		return c.transpile(&code, program)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	module := naming.Mangle("python", program.Name)
+	exports := []string{strconv.Quote("Result"), strconv.Quote(program.Name)}
+	imports := "Result, " + program.Name
+	if t.usePydantic() {
+		exports = append(exports, strconv.Quote(modelName(program)))
+		imports += ", " + modelName(program)
+	}
+
+	var init strings.Builder
+	if program.Description != "" {
+		fmt.Fprintf(&init, "\"\"\"%s\"\"\"\n\n", FormatDescription(program.Description))
+	}
+	fmt.Fprintf(&init, "from .tool import %s\n\n__all__ = [%s]\n", imports, strings.Join(exports, ", "))
+
+	return []PackageFile{
+		{Path: "pyproject.toml", Content: t.pyproject(program, module)},
+		{Path: "src/" + module + "/__init__.py", Content: init.String()},
+		{Path: "src/" + module + "/__main__.py", Content: "from .tool import main\n\nmain()\n"},
+		{Path: "src/" + module + "/tool.py", Content: code.String()},
+	}, nil
+}
+
+// pyproject returns the pyproject.toml of the package of a program, with
+// the version, author and license of its metadata.
+func (t *PythonTranspiler) pyproject(program *ast.Program, module string) string {
+	distribution := strings.ReplaceAll(module, "_", "-")
+	version := program.Metadata["version"]
+	if version == "" {
+		version = "0.1.0"
+	}
+	dependencies := []string{}
+	if t.usePydantic() {
+		dependencies = append(dependencies, strconv.Quote("pydantic>=2"))
+	}
+	if t.useDockerSDK() {
+		dependencies = append(dependencies, strconv.Quote("docker>=6"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[build-system]\n")
+	sb.WriteString("requires = [\"setuptools>=61\"]\n")
+	sb.WriteString("build-backend = \"setuptools.build_meta\"\n\n")
+	sb.WriteString("[project]\n")
+	fmt.Fprintf(&sb, "name = %s\n", strconv.Quote(distribution))
+	fmt.Fprintf(&sb, "version = %s\n", strconv.Quote(version))
+	if program.Description != "" {
+		fmt.Fprintf(&sb, "description = %s\n", strconv.Quote(FormatDescription(program.Description)))
+	}
+	sb.WriteString("requires-python = \">=3.9\"\n")
+	fmt.Fprintf(&sb, "dependencies = [%s]\n", strings.Join(dependencies, ", "))
+	if author := program.Metadata["author"]; author != "" {
+		fmt.Fprintf(&sb, "authors = [{name = %s}]\n", strconv.Quote(author))
+	}
+	if license := program.Metadata["license"]; license != "" {
+		fmt.Fprintf(&sb, "license = {text = %s}\n", strconv.Quote(license))
+	}
+	sb.WriteString("\n[project.scripts]\n")
+	fmt.Fprintf(&sb, "%s = %s\n", distribution, strconv.Quote(module+".tool:main"))
+	return sb.String()
+}
+
+// transpile writes the code of a program, on the transpiler of a call.
+func (t *PythonTranspiler) transpile(w io.Writer, program *ast.Program) error {
+	program = targetProgram("python", program)
+	t.SetOutput(w)
+	defer t.SetOutput(nil)
 
 	// Generate shebang and imports
-	t.writeHeader()
+	t.writeSection(SectionHeader, SectionData{Target: "python", Program: program}, t.writeHeader)
 
 	// Generate utility functions
 	t.writeUtilityFunctions()
 
+	if t.usePydantic() {
+		if err := t.writeParameterModel(program); err != nil {
+			return fmt.Errorf("error generating parameter model: %w", err)
+		}
+	}
+
 	// Generate function with docstring
 	t.writeFunctionHeader(program)
 
 	// Generate parameter validation
-	err := t.writeTypeValidation(program.Parameters)
+	var err error
+	if t.usePydantic() {
+		err = t.writeModelValidation(program)
+	} else {
+		err = t.writeTypeValidation(program.Parameters)
+	}
 	if err != nil {
-		return "", fmt.Errorf("error generating type validation: %w", err)
+		return fmt.Errorf("error generating type validation: %w", err)
 	}
 
 	// Generate security checks
@@ -70,32 +296,104 @@ func (t *PythonTranspiler) Transpile(program *ast.Program) (string, error) {
 	// Process implementation blocks
 	err = t.processImplementations(program)
 	if err != nil {
-		return "", fmt.Errorf("error processing implementations: %w", err)
+		return fmt.Errorf("error processing implementations: %w", err)
 	}
 
 	// Add main entry point
 	t.SetIndentLevel(0)
 	t.writeEntryPoint(program)
 
-	return t.Buffer.String(), nil
+	return t.OutputError()
 }
 
 // writeHeader generates header comments, shebang, and imports
 func (t *PythonTranspiler) writeHeader() {
 	t.WriteLine("#!/usr/bin/env python3")
 	t.WriteLine("")
+	if t.useAsync() {
+		t.WriteLine("import asyncio")
+	}
+	t.WriteLine("import hashlib")
+	t.WriteLine("import json")
 	t.WriteLine("import os")
 	t.WriteLine("import sys")
 	t.WriteLine("import re")
+	t.WriteLine("import shlex")
+	if t.useWorkDir() {
+		t.WriteLine("import shutil")
+	}
+	t.WriteLine("import signal")
 	t.WriteLine("import subprocess")
+	if t.useWorkDir() {
+		t.WriteLine("import tempfile")
+	}
 	t.WriteLine("import pathlib")
 	t.WriteLine("import logging")
-	t.WriteLine("from typing import Dict, List, Any, Optional, Union")
-	t.WriteLine("from dataclasses import dataclass")
+	if t.useInstrumentation() {
+		t.WriteLine("import threading")
+		t.WriteLine("import time")
+	}
+	if !t.useDockerSDK() {
+		t.WriteLine("import uuid")
+	}
+	if t.usePydantic() {
+		t.WriteLine("from typing import Callable, Dict, List, Any, Literal, Optional, Union")
+	} else {
+		t.WriteLine("from typing import Callable, Dict, List, Any, Optional, Union")
+	}
+	t.WriteLine("from dataclasses import dataclass, field")
+	t.WriteLine("from datetime import datetime, timezone")
+	if t.usePydantic() || t.useDockerSDK() {
+		t.WriteLine("")
+	}
+	if t.useDockerSDK() {
+		t.WriteLine("import docker")
+		t.WriteLine("from docker.types import Mount")
+	}
+	if t.usePydantic() {
+		t.WriteLine("from pydantic import BaseModel, ConfigDict, Field, ValidationError")
+	}
 	t.WriteLine("")
 	t.WriteLine("# Configure logging")
 	t.WriteLine("logger = logging.getLogger(__name__)")
 	t.WriteLine("")
+	if t.useJSONLogging() {
+		t.writeJSONLogging()
+	}
+}
+
+// writeJSONLogging generates the formatter of the JSON lines and the
+// log_event helper, logging an event with its fields.
+func (t *PythonTranspiler) writeJSONLogging() {
+	t.WriteLine("class JsonFormatter(logging.Formatter):")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Format the log records as JSON lines, for the orchestrators ingesting them.\"\"\"")
+	t.WriteLine("")
+	t.WriteLine("def format(self, record: logging.LogRecord) -> str:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("entry = {")
+	t.WriteLine("  \"time\": datetime.fromtimestamp(record.created, timezone.utc).isoformat(),")
+	t.WriteLine("  \"level\": record.levelname.lower(),")
+	t.WriteLine("  \"event\": getattr(record, \"event\", \"message\"),")
+	t.WriteLine("  \"message\": record.getMessage(),")
+	t.WriteLine("}")
+	t.WriteLine("entry.update(getattr(record, \"fields\", {}))")
+	t.WriteLine("if record.exc_info:")
+	t.WriteLine("  entry[\"exception\"] = self.formatException(record.exc_info)")
+	t.WriteLine("return json.dumps(entry, default=str)")
+	t.SetIndentLevel(t.GetIndentLevel() - 2)
+	t.WriteLine("")
+	t.WriteLine("def log_event(level: int, event: str, message: str, **fields: Any) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Log an event of the run, with fields that the JsonFormatter records.\"\"\"")
+	t.WriteLine("logger.log(level, message, extra={\"event\": event, \"fields\": fields})")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// TemplateSections implements Templated.
+func (t *PythonTranspiler) TemplateSections() []string {
+	return []string{SectionHeader, SectionDocker, SectionResult}
 }
 
 // writeUtilityFunctions generates helper functions
@@ -107,6 +405,9 @@ func (t *PythonTranspiler) writeUtilityFunctions() {
 	t.WriteLine("status: str")
 	t.WriteLine("output_dir: str")
 	t.WriteLine("message: str = \"\"")
+	t.WriteLine("manifest: str = \"\"")
+	t.WriteLine("resources: Dict[str, Any] = field(default_factory=dict)")
+	t.WriteLine("logs: Dict[str, str] = field(default_factory=dict)")
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
 	t.WriteLine("")
 
@@ -130,35 +431,87 @@ func (t *PythonTranspiler) writeUtilityFunctions() {
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
 	t.WriteLine("")
 
-	// Docker run function
-	t.WriteLine("def run_docker(image: str, volumes: Dict[str, str], env: Dict[str, str], args: List[str]) -> str:")
+	t.writeEnvFunctions()
+	t.writeManifestFunctions()
+	if t.useInstrumentation() {
+		t.writeUsageFunctions()
+	}
+	t.writeSignalFunctions()
+	t.writeLogFunctions()
+	t.writeMountFunctions()
+	if t.useWorkDir() {
+		t.writeWorkDirFunctions()
+	}
+
+	// Docker command, also printed by dry runs
+	t.WriteLine("def docker_command(image: str, volumes: Dict[str, str], env: Dict[str, str], args: List[str],")
+	t.WriteLine("                   options: Optional[List[str]] = None) -> List[str]:")
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
-	t.WriteLine("\"\"\"Run a Docker container with specified parameters.\"\"\"")
-	t.WriteLine("cmd = ['docker', 'run', '--rm']")
+	t.WriteLine("\"\"\"Return the docker command running a container.\"\"\"")
+	t.WriteLine("cmd = ['docker', 'run', '--rm'] + (options or [])")
 	t.WriteLine("")
 	t.WriteLine("for src, dst in volumes.items():")
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
 	t.WriteLine("cmd.extend(['-v', f\"{src}:{dst}\"])")
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
-
 	t.WriteLine("")
 	t.WriteLine("for key, val in env.items():")
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
 	t.WriteLine("cmd.extend(['-e', f\"{key}={val}\"])")
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
-
 	t.WriteLine("")
 	t.WriteLine("cmd.append(image)")
 	t.WriteLine("cmd.extend(args)")
-
+	t.WriteLine("return cmd")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
 	t.WriteLine("")
-	t.WriteLine("logger.info(f\"Running Docker command: {' '.join(cmd)}\")")
-	t.WriteLine("result = subprocess.run(cmd, capture_output=True, text=True, check=False)")
+
+	// Docker run function
+	if t.useDockerSDK() && t.useAsync() {
+		// The SDK blocks, its calls run in a thread
+		t.writeDockerSDKFunction("run_docker_blocking")
+		t.WriteLine("async def run_docker(%s) -> str:", t.dockerParameters())
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("\"\"\"Run a Docker container with the docker SDK, in a thread.\"\"\"")
+		if t.useInstrumentation() {
+			t.WriteLine("return await asyncio.to_thread(run_docker_blocking, image, volumes, env, args, usage, log_files)")
+		} else {
+			t.WriteLine("return await asyncio.to_thread(run_docker_blocking, image, volumes, env, args, log_files)")
+		}
+		t.SetIndentLevel(t.GetIndentLevel() - 1)
+		t.WriteLine("")
+		return
+	}
+	if t.useDockerSDK() {
+		t.writeDockerSDKFunction("run_docker")
+		return
+	}
+	if t.useAsync() {
+		t.writeAsyncDockerFunction()
+		return
+	}
+	t.WriteLine("def run_docker(%s) -> str:", t.dockerParameters())
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Run a Docker container with specified parameters.\"\"\"")
+	t.writeDockerCommand()
+	t.writeLog("info", "docker_command", "f\"Running Docker command: {' '.join(cmd)}\"", "command=cmd")
+	t.WriteLine("process = subprocess.Popen(cmd, stdout=subprocess.PIPE, stderr=subprocess.PIPE, text=True)")
+	if t.useInstrumentation() {
+		t.WriteLine("stop = threading.Event()")
+		t.WriteLine("sampler = threading.Thread(target=sample_container, args=(name, usage, stop), daemon=True)")
+		t.WriteLine("sampler.start()")
+	}
+	t.writeInterruptible("stdout, stderr = process.communicate()", "process.kill()", func() {
+		t.WriteLine("stop.set()")
+		t.WriteLine("sampler.join()")
+	})
+	t.WriteLine("result = subprocess.CompletedProcess(cmd, process.returncode, stdout, stderr)")
+	t.WriteLine("write_logs(log_files, stdout, stderr)")
 
 	t.WriteLine("")
 	t.WriteLine("if result.returncode != 0:")
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
-	t.WriteLine("logger.error(f\"Docker execution failed: {result.stderr}\")")
+	t.writeLog("error", "error", "f\"Docker execution failed: {result.stderr}\"", "exit_code=result.returncode")
 	t.WriteLine("raise RuntimeError(f\"Docker execution failed: {result.stderr}\")")
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
 
@@ -168,11 +521,432 @@ func (t *PythonTranspiler) writeUtilityFunctions() {
 	t.WriteLine("")
 }
 
+// pythonEnvConverters maps parameter types to the functions converting
+// their environment overrides; the others are kept as strings.
+var pythonEnvConverters = map[string]string{
+	TypeInteger: "int",
+	TypeNumber:  "float",
+	TypeBoolean: "env_flag",
+}
+
+// writeEnvFunctions generates the helpers reading the environment variables
+// that override the defaults of the command line options.
+func (t *PythonTranspiler) writeEnvFunctions() {
+	t.WriteLine("def env_flag(value: str) -> bool:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Convert the value of an environment variable to a boolean.\"\"\"")
+	t.WriteLine("if value.lower() in (\"1\", \"true\", \"yes\", \"on\"):")
+	t.WriteLine("  return True")
+	t.WriteLine("if value.lower() in (\"0\", \"false\", \"no\", \"off\", \"\"):")
+	t.WriteLine("  return False")
+	t.WriteLine("raise ValueError(f\"not a boolean: {value}\")")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def env_default(name: str, default: Any, convert: Callable[[str], Any] = str) -> Any:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Return the value of the environment variable overriding an option, or its default.\"\"\"")
+	t.WriteLine("value = os.environ.get(name)")
+	t.WriteLine("if value is None:")
+	t.WriteLine("  return default")
+	t.WriteLine("try:")
+	t.WriteLine("  return convert(value)")
+	t.WriteLine("except ValueError:")
+	t.WriteLine("  raise SystemExit(f\"Invalid value {value!r} of {name}\")")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// writeManifestFunctions generates the helpers writing manifest.json into
+// the results directory: the produced files with their checksums, the
+// parameters, the image digest and the start and end times of the run.
+func (t *PythonTranspiler) writeManifestFunctions() {
+	t.WriteLine("def image_digest(image: str) -> str:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Return the digest of a local image, or an empty string when it can't be inspected.\"\"\"")
+	if t.useDockerSDK() {
+		t.WriteLine("try:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("client = docker.from_env()")
+		t.WriteLine("try:")
+		t.WriteLine("  attrs = client.images.get(image).attrs")
+		t.WriteLine("finally:")
+		t.WriteLine("  client.close()")
+		t.SetIndentLevel(t.GetIndentLevel() - 1)
+		t.WriteLine("except docker.errors.DockerException:")
+		t.WriteLine("  return \"\"")
+		t.WriteLine("digests = attrs.get(\"RepoDigests\") or []")
+		t.WriteLine("return digests[0] if digests else attrs.get(\"Id\", \"\")")
+	} else {
+		t.WriteLine("cmd = ['docker', 'image', 'inspect', '--format',")
+		t.WriteLine("       '{{if .RepoDigests}}{{index .RepoDigests 0}}{{else}}{{.Id}}{{end}}', image]")
+		t.WriteLine("try:")
+		t.WriteLine("  result = subprocess.run(cmd, capture_output=True, text=True, check=False)")
+		t.WriteLine("except OSError:")
+		t.WriteLine("  return \"\"")
+		t.WriteLine("return result.stdout.strip() if result.returncode == 0 else \"\"")
+	}
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def file_sha256(path: str) -> str:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Return the SHA-256 checksum of a file.\"\"\"")
+	t.WriteLine("digest = hashlib.sha256()")
+	t.WriteLine("with open(path, \"rb\") as f:")
+	t.WriteLine("  for chunk in iter(lambda: f.read(1 << 20), b\"\"):")
+	t.WriteLine("    digest.update(chunk)")
+	t.WriteLine("return digest.hexdigest()")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def verify_checksum(path: str, algorithm: str, expected: str) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Raise a ValueError when the checksum of an input file isn't the declared one.\"\"\"")
+	t.WriteLine("digest = hashlib.new(algorithm)")
+	t.WriteLine("with open(path, \"rb\") as f:")
+	t.WriteLine("  for chunk in iter(lambda: f.read(1 << 20), b\"\"):")
+	t.WriteLine("    digest.update(chunk)")
+	t.WriteLine("if digest.hexdigest() != expected:")
+	t.WriteLine("  raise ValueError(f\"{path}: {algorithm} checksum {digest.hexdigest()} does not match {expected}\")")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def write_manifest(output_dir: str, tool: str, image: str, parameters: Dict[str, Any], started: str,")
+	t.WriteLine("                   resources: Optional[Dict[str, Any]] = None, logs: Optional[Dict[str, str]] = None) -> str:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Write manifest.json into the results directory and return its path.\"\"\"")
+	t.WriteLine("files = []")
+	t.WriteLine("for root, dirs, names in os.walk(output_dir):")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("dirs.sort()")
+	t.WriteLine("for name in sorted(names):")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("path = os.path.join(root, name)")
+	t.WriteLine("relative = os.path.relpath(path, output_dir)")
+	t.WriteLine("if relative == \"manifest.json\":")
+	t.WriteLine("  continue")
+	t.WriteLine("files.append({\"path\": relative, \"size\": os.path.getsize(path), \"sha256\": file_sha256(path)})")
+	t.SetIndentLevel(t.GetIndentLevel() - 2)
+	t.WriteLine("")
+	t.WriteLine("manifest = {")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"tool\": tool,")
+	t.WriteLine("\"image\": image,")
+	t.WriteLine("\"image_digest\": image_digest(image),")
+	t.WriteLine("\"parameters\": parameters,")
+	t.WriteLine("\"started\": started,")
+	t.WriteLine("\"finished\": datetime.now(timezone.utc).isoformat(),")
+	t.WriteLine("\"files\": files,")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("}")
+	t.WriteLine("if resources:")
+	t.WriteLine("  manifest[\"resources\"] = resources")
+	t.WriteLine("if logs:")
+	t.WriteLine("  manifest[\"logs\"] = {stream: os.path.relpath(path, output_dir) for stream, path in logs.items()}")
+	t.WriteLine("path = os.path.join(output_dir, \"manifest.json\")")
+	t.WriteLine("with open(path, \"w\") as f:")
+	t.WriteLine("  json.dump(manifest, f, indent=2, default=str)")
+	t.WriteLine("return path")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// writeDockerCommand builds the docker command of the run_docker helpers,
+// naming the container to stop it when the run is interrupted and to
+// sample its usage.
+func (t *PythonTranspiler) writeDockerCommand() {
+	t.WriteLine("name = f\"baryon-{uuid.uuid4().hex[:12]}\"")
+	t.WriteLine("cmd = docker_command(image, volumes, env, args, ['--name', name])")
+	t.WriteLine("")
+}
+
+// writeSignalFunctions generates the helpers stopping the container of an
+// interrupted run: a SIGTERM exits like a Ctrl-C, through the cleanup of
+// run_docker, instead of leaving the container running.
+func (t *PythonTranspiler) writeSignalFunctions() {
+	t.WriteLine("def exit_on_signal(signum: int, frame: Any) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Exit on a termination signal, stopping the running container on the way.\"\"\"")
+	t.WriteLine("raise SystemExit(128 + signum)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+	if t.useDockerSDK() {
+		// The SDK removes the container in a finally block
+		return
+	}
+	t.WriteLine("def stop_container(name: str) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Stop a container of an interrupted run, which --rm then removes.\"\"\"")
+	t.writeLog("warning", "interrupted", "f\"Interrupted, stopping container {name}\"", "container=name")
+	t.WriteLine("subprocess.run(['docker', 'stop', name], capture_output=True, check=False)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// writeLogFunctions generates the helpers writing the standard output and
+// error of the containers into the logs directory of the results.
+func (t *PythonTranspiler) writeLogFunctions() {
+	t.WriteLine("def log_paths(output_dir: str) -> Dict[str, str]:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Return the log files of the streams of the container, in the results directory.\"\"\"")
+	t.WriteLine("logs_dir = os.path.join(output_dir, \"logs\")")
+	t.WriteLine("return {stream: os.path.join(logs_dir, f\"{stream}.log\") for stream in (\"stdout\", \"stderr\")}")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def write_logs(log_files: Optional[Dict[str, str]], stdout: str, stderr: str) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Write the streams of a container to their log files, when there are any.\"\"\"")
+	t.WriteLine("for stream, text in ((\"stdout\", stdout), (\"stderr\", stderr)):")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("if log_files and stream in log_files:")
+	t.WriteLine("  os.makedirs(os.path.dirname(log_files[stream]), exist_ok=True)")
+	t.WriteLine("  with open(log_files[stream], \"w\") as f:")
+	t.WriteLine("    f.write(text)")
+	t.SetIndentLevel(t.GetIndentLevel() - 2)
+	t.WriteLine("")
+}
+
+// writeMountFunctions generates the helper mounting the directory of each
+// input: the first one is mounted at /data, the others under /inputs.
+func (t *PythonTranspiler) writeMountFunctions() {
+	t.WriteLine("def mount_input(volumes: Dict[str, str], host_dir: str, filename: str) -> str:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Mount the directory of an input, returning the path of the input in the container.\"\"\"")
+	t.WriteLine("if host_dir not in volumes:")
+	t.WriteLine("  inputs = [guest for guest in volumes.values() if guest.startswith(\"/inputs/\")]")
+	t.WriteLine("  volumes[host_dir] = f\"/inputs/{len(inputs) + 1}\"")
+	t.WriteLine("if volumes[host_dir] == \"/data\":")
+	t.WriteLine("  return filename")
+	t.WriteLine("return f\"{volumes[host_dir]}/{filename}\"")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// writeWorkDirFunctions generates the helpers of the work directories:
+// the inputs are hard-linked into them, or copied across file systems, and
+// what the run writes is copied back next to the inputs, as if their
+// directory was mounted.
+func (t *PythonTranspiler) writeWorkDirFunctions() {
+	t.WriteLine("def link_or_copy(src: str, dst: str) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Hard-link a file, or copy it when it is on another file system.\"\"\"")
+	t.WriteLine("try:")
+	t.WriteLine("  os.link(src, dst)")
+	t.WriteLine("except OSError:")
+	t.WriteLine("  shutil.copy2(src, dst)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def stage_input(path: str, work_dir: str) -> str:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Stage an input file or directory in the work directory, returning its name.\"\"\"")
+	t.WriteLine("name = os.path.basename(path)")
+	t.WriteLine("target = os.path.join(work_dir, name)")
+	t.WriteLine("if os.path.lexists(target):")
+	t.WriteLine("  raise ValueError(f\"Inputs with the same name {name} cannot be staged together\")")
+	t.WriteLine("if os.path.isdir(path):")
+	t.WriteLine("  shutil.copytree(path, target, copy_function=link_or_copy)")
+	t.WriteLine("else:")
+	t.WriteLine("  link_or_copy(path, target)")
+	t.WriteLine("return name")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def collect_outputs(work_dir: str, results_parent: str, staged: List[str]) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Copy what the run wrote in the work directory, leaving out the staged inputs.\"\"\"")
+	t.WriteLine("shutil.copytree(work_dir, results_parent, dirs_exist_ok=True,")
+	t.WriteLine("                ignore=lambda directory, names: staged if directory == work_dir else [])")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// writeUsageFunctions generates the helpers of the instrumentation, which
+// sample the CPU and memory usage of a running container every second and
+// keep the maximum of each.
+func (t *PythonTranspiler) writeUsageFunctions() {
+	t.WriteLine("def record_usage(usage: Optional[Dict[str, Any]], cpu_percent: float, memory_bytes: int) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Record a sample of the usage of a container, keeping the maximum.\"\"\"")
+	t.WriteLine("if usage is None:")
+	t.WriteLine("  return")
+	t.WriteLine("usage[\"cpu_percent_max\"] = max(usage.get(\"cpu_percent_max\", 0.0), round(cpu_percent, 2))")
+	t.WriteLine("usage[\"memory_bytes_max\"] = max(usage.get(\"memory_bytes_max\", 0), memory_bytes)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	if t.useDockerSDK() {
+		t.WriteLine("def sample_container(container: Any, usage: Optional[Dict[str, Any]], stop: threading.Event) -> None:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("\"\"\"Sample the usage of a container with the docker SDK until stopped.\"\"\"")
+		t.WriteLine("while not stop.is_set():")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("try:")
+		t.WriteLine("  stats = container.stats(stream=False)")
+		t.WriteLine("except docker.errors.DockerException:")
+		t.WriteLine("  return")
+		t.WriteLine("cpu, previous = stats.get(\"cpu_stats\", {}), stats.get(\"precpu_stats\", {})")
+		t.WriteLine("cpu_delta = cpu.get(\"cpu_usage\", {}).get(\"total_usage\", 0) - previous.get(\"cpu_usage\", {}).get(\"total_usage\", 0)")
+		t.WriteLine("system_delta = cpu.get(\"system_cpu_usage\", 0) - previous.get(\"system_cpu_usage\", 0)")
+		t.WriteLine("cpu_percent = cpu_delta / system_delta * (cpu.get(\"online_cpus\") or 1) * 100 if system_delta > 0 else 0.0")
+		t.WriteLine("record_usage(usage, cpu_percent, stats.get(\"memory_stats\", {}).get(\"usage\", 0))")
+		t.WriteLine("stop.wait(1.0)")
+		t.SetIndentLevel(t.GetIndentLevel() - 2)
+		t.WriteLine("")
+		return
+	}
+
+	t.WriteLine("def parse_size(size: str) -> int:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Parse a size printed by docker stats, e.g. 12.5MiB, in bytes.\"\"\"")
+	t.WriteLine("match = re.match(r\"([0-9.]+)\\s*([KMGT]?i?B)\", size.strip())")
+	t.WriteLine("if not match:")
+	t.WriteLine("  return 0")
+	t.WriteLine("units = {\"B\": 1, \"kB\": 1000, \"KB\": 1000, \"MB\": 1000**2, \"GB\": 1000**3, \"TB\": 1000**4,")
+	t.WriteLine("         \"KiB\": 1024, \"MiB\": 1024**2, \"GiB\": 1024**3, \"TiB\": 1024**4}")
+	t.WriteLine("return int(float(match.group(1)) * units.get(match.group(2), 1))")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+
+	t.WriteLine("def sample_container(name: str, usage: Optional[Dict[str, Any]], stop: threading.Event) -> None:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Sample the usage of a container with docker stats until stopped.\"\"\"")
+	t.WriteLine("cmd = ['docker', 'stats', '--no-stream', '--format', '{{.CPUPerc}}\\t{{.MemUsage}}', name]")
+	t.WriteLine("while not stop.wait(1.0):")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("result = subprocess.run(cmd, capture_output=True, text=True, check=False)")
+	t.WriteLine("fields = result.stdout.strip().split(\"\\t\")")
+	t.WriteLine("if result.returncode != 0 or len(fields) != 2:")
+	t.WriteLine("  continue")
+	t.WriteLine("try:")
+	t.WriteLine("  cpu_percent = float(fields[0].rstrip(\"%%\"))")
+	t.WriteLine("except ValueError:")
+	t.WriteLine("  continue")
+	t.WriteLine("record_usage(usage, cpu_percent, parse_size(fields[1].split(\"/\")[0]))")
+	t.SetIndentLevel(t.GetIndentLevel() - 2)
+	t.WriteLine("")
+}
+
+// writeDockerSDKFunction generates the run_docker helper of the docker SDK
+// mode, which binds the volumes as mounts, streams the container logs and
+// removes the container however the run ends.
+func (t *PythonTranspiler) writeDockerSDKFunction(name string) {
+	t.WriteLine("def %s(%s) -> str:", name, t.dockerParameters())
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Run a Docker container with the docker SDK, streaming its logs.\"\"\"")
+	t.WriteLine("client = docker.from_env()")
+	t.WriteLine("mounts = [Mount(target=dst, source=src, type=\"bind\") for src, dst in volumes.items()]")
+	t.WriteLine("")
+	t.writeLog("info", "docker_command", "f\"Running Docker image {image} with arguments {args}\"", "image=image", "args=args")
+	t.WriteLine("container = client.containers.run(image, args, mounts=mounts, environment=env, detach=True)")
+	t.WriteLine("output = []")
+	if t.useInstrumentation() {
+		t.WriteLine("stop = threading.Event()")
+		t.WriteLine("sampler = threading.Thread(target=sample_container, args=(container, usage, stop), daemon=True)")
+		t.WriteLine("sampler.start()")
+	}
+	t.WriteLine("try:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("for chunk in container.logs(stream=True, follow=True):")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("line = chunk.decode(errors=\"replace\")")
+	t.WriteLine("output.append(line)")
+	t.writeLog("info", "container_output", "line.rstrip()")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+	t.WriteLine("status = container.wait()")
+	t.WriteLine("write_logs(log_files, container.logs(stdout=True, stderr=False).decode(errors=\"replace\"),")
+	t.WriteLine("           container.logs(stdout=False, stderr=True).decode(errors=\"replace\"))")
+	t.WriteLine("if status[\"StatusCode\"] != 0:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("tail = \"\".join(output[-20:])")
+	t.writeLog("error", "error", "f\"Docker execution failed with exit code {status['StatusCode']}: {tail}\"",
+		"exit_code=status['StatusCode']")
+	t.WriteLine("raise RuntimeError(f\"Docker execution failed with exit code {status['StatusCode']}: {tail}\")")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("finally:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	if t.useInstrumentation() {
+		t.WriteLine("stop.set()")
+		t.WriteLine("sampler.join()")
+	}
+	t.WriteLine("container.remove(force=True)")
+	t.WriteLine("client.close()")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+	t.WriteLine("return \"\".join(output)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
+// writeInterruptible generates the wait for the docker command, which
+// stops the container when it is interrupted, e.g. by a Ctrl-C, a SIGTERM
+// or the cancellation of the task, then kills the command. The sampling of
+// the usage is stopped however the wait ends.
+func (t *PythonTranspiler) writeInterruptible(wait, kill string, stopSampling func()) {
+	t.WriteLine("try:")
+	t.WriteLine("  %s", wait)
+	t.WriteLine("except BaseException:")
+	t.WriteLine("  stop_container(name)")
+	t.WriteLine("  %s", kill)
+	t.WriteLine("  raise")
+	if t.useInstrumentation() {
+		t.WriteLine("finally:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		stopSampling()
+		t.SetIndentLevel(t.GetIndentLevel() - 1)
+	}
+}
+
+// writeAsyncDockerFunction generates the run_docker helper of the async
+// mode, which runs the docker command with asyncio.
+func (t *PythonTranspiler) writeAsyncDockerFunction() {
+	t.WriteLine("async def run_docker(%s) -> str:", t.dockerParameters())
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Run a Docker container with specified parameters, without blocking the event loop.\"\"\"")
+	t.writeDockerCommand()
+	t.writeLog("info", "docker_command", "f\"Running Docker command: {' '.join(cmd)}\"", "command=cmd")
+	t.WriteLine("proc = await asyncio.create_subprocess_exec(")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("*cmd, stdout=asyncio.subprocess.PIPE, stderr=asyncio.subprocess.PIPE)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	if t.useInstrumentation() {
+		t.WriteLine("stop = threading.Event()")
+		t.WriteLine("sampler = asyncio.create_task(asyncio.to_thread(sample_container, name, usage, stop))")
+	}
+	t.writeInterruptible("stdout, stderr = await proc.communicate()", "proc.kill()", func() {
+		t.WriteLine("stop.set()")
+		t.WriteLine("await sampler")
+	})
+	t.WriteLine("write_logs(log_files, stdout.decode(errors=\"replace\"), stderr.decode(errors=\"replace\"))")
+	t.WriteLine("")
+	t.WriteLine("if proc.returncode != 0:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.writeLog("error", "error", "f\"Docker execution failed: {stderr.decode()}\"", "exit_code=proc.returncode")
+	t.WriteLine("raise RuntimeError(f\"Docker execution failed: {stderr.decode()}\")")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+	t.WriteLine("return stdout.decode()")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+}
+
 // writeFunctionHeader generates the function signature and docstring
 func (t *PythonTranspiler) writeFunctionHeader(program *ast.Program) {
 	// Generate function signature
 	paramList := t.formatParameterList(program.Parameters)
-	t.WriteLine("def %s(%s) -> Result:", program.Name, paramList)
+	if paramList != "" {
+		paramList += ", "
+	}
+	paramList += "output_dir: Optional[str] = None, dry_run: bool = False"
+	if t.useAsync() {
+		t.WriteLine("async def %s(%s) -> Result:", program.Name, paramList)
+	} else {
+		t.WriteLine("def %s(%s) -> Result:", program.Name, paramList)
+	}
 
 	// Generate function docstring
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
@@ -183,27 +957,27 @@ func (t *PythonTranspiler) writeFunctionHeader(program *ast.Program) {
 	}
 
 	// Parameter documentation
-	if len(program.Parameters) > 0 {
-		t.WriteLine("Parameters:")
-		for _, param := range program.Parameters {
-			desc := param.Description
-			if desc == "" {
-				desc = fmt.Sprintf("Parameter of type '%s'", param.Type)
-			}
+	t.WriteLine("Parameters:")
+	for _, param := range program.Parameters {
+		desc := param.Description
+		if desc == "" {
+			desc = fmt.Sprintf("Parameter of type '%s'", param.Type)
+		}
 
-			// For enum types, include allowed values
-			if param.Type == "enum" && len(param.Constraints) > 0 {
-				values := make([]string, len(param.Constraints))
-				for i, c := range param.Constraints {
-					values[i] = fmt.Sprintf("%v", c)
-				}
-				desc += fmt.Sprintf(" (allowed values: %s)", strings.Join(values, ", "))
+		// For enum types, include allowed values
+		if param.Type == "enum" && len(param.Constraints) > 0 {
+			values := make([]string, len(param.Constraints))
+			for i, c := range param.Constraints {
+				values[i] = fmt.Sprintf("%v", c)
 			}
-
-			t.WriteLine("    %s: %s", param.Name, FormatDescription(desc))
+			desc += fmt.Sprintf(" (allowed values: %s)", strings.Join(values, ", "))
 		}
-		t.WriteLine("")
+
+		t.WriteLine("    %s: %s", param.Name, FormatDescription(desc))
 	}
+	t.WriteLine("    output_dir: Results directory, %s_results next to the first input by default", program.Name)
+	t.WriteLine("    dry_run: Print the docker command instead of running it")
+	t.WriteLine("")
 
 	// Return value documentation
 	returnDesc := "Results of the operation"
@@ -246,26 +1020,25 @@ func (t *PythonTranspiler) formatParameterList(params []ast.Parameter) string {
 			paramStr += "Any"
 		}
 
-		// Add default value if specified
+		// Add default value if specified, None for the parameters left off
+		// the command without a value
 		if param.Default != nil {
-			switch param.Type {
-			case "string", "file", "directory", "character", "enum":
-				paramStr += fmt.Sprintf(" = \"%v\"", param.Default)
-			case "boolean":
-				boolVal, ok := param.Default.(bool)
-				if ok {
-					paramStr += fmt.Sprintf(" = %v", boolVal)
-				} else {
-					paramStr += fmt.Sprintf(" = %v", param.Default)
-				}
-			default:
-				paramStr += fmt.Sprintf(" = %v", param.Default)
-			}
+			paramStr += " = " + FormatLiteral(param.Default, pythonLiteralSyntax)
+		} else if IsOmittable(param) {
+			paramStr = param.Name + ": Optional[" + strings.TrimPrefix(paramStr, param.Name+": ") + "] = None"
 		}
 
 		paramStrings[i] = paramStr
 	}
 
+	// Required parameters can't follow defaulted ones, unless they are
+	// keyword-only
+	defaulted := func(param ast.Parameter) bool { return param.Default != nil || IsOmittable(param) }
+	for i := 1; i < len(params); i++ {
+		if !defaulted(params[i]) && defaulted(params[i-1]) {
+			return "*, " + strings.Join(paramStrings, ", ")
+		}
+	}
 	return strings.Join(paramStrings, ", ")
 }
 
@@ -278,25 +1051,171 @@ func (t *PythonTranspiler) writeTypeValidation(params []ast.Parameter) error {
 	t.WriteLine("# Parameter validation")
 
 	for _, param := range params {
-		// Skip validation for parameters with default values
-		if param.Default != nil {
-			continue
+		if err := t.canceled(); err != nil {
+			return err
 		}
-
 		validator, ok := t.GetTypeValidators()[param.Type]
 		if !ok {
 			t.WriteLine("# No specific validation for type '%s'", param.Type)
 			continue
 		}
 
+		done := t.markSource(param.Pos, "parameter "+param.Name)
+		// Parameters without a value are only checked when given
+		if IsOmittable(param) {
+			if param.Type == TypeFile || param.Type == TypeDirectory {
+				t.WriteLine("%s_path = None", param.Name)
+			}
+			t.WriteLine("if %s is not None:", param.Name)
+			t.SetIndentLevel(t.GetIndentLevel() + 1)
+		}
 		if err := validator(t, param); err != nil {
 			return fmt.Errorf("error validating parameter '%s': %w", param.Name, err)
 		}
+		t.writeRangeCheck(param)
+		if IsOmittable(param) {
+			t.SetIndentLevel(t.GetIndentLevel() - 1)
+		}
+		done()
 	}
 
 	return nil
 }
 
+// writeRangeCheck checks that the value of a number parameter is within
+// its min and max.
+func (t *PythonTranspiler) writeRangeCheck(param ast.Parameter) {
+	if param.Type != TypeInteger && param.Type != TypeNumber {
+		return
+	}
+	for _, bound := range []struct{ key, operator, text string }{
+		{"min", "<", "at least"},
+		{"max", ">", "at most"},
+	} {
+		if value, ok := param.Metadata[bound.key]; ok {
+			t.WriteLine("if %s %s %s:", param.Name, bound.operator, value)
+			t.SetIndentLevel(t.GetIndentLevel() + 1)
+			t.WriteLine("raise ValueError(f\"%s must be %s %s, got {%s}\")", param.Name, bound.text, value, param.Name)
+			t.SetIndentLevel(t.GetIndentLevel() - 1)
+		}
+	}
+}
+
+// modelName returns the name of the pydantic model of the parameters of a
+// program, e.g. AlignReadsParameters for align_reads.
+func modelName(program *ast.Program) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(program.Name, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.'
+	}) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String() + "Parameters"
+}
+
+// writeParameterModel generates the pydantic model of the parameters, with
+// enums as Literal types and the constraints as Field arguments.
+func (t *PythonTranspiler) writeParameterModel(program *ast.Program) error {
+	t.WriteLine("class %s(BaseModel):", modelName(program))
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("\"\"\"Parameters of %s.\"\"\"", program.Name)
+	t.WriteLine("model_config = ConfigDict(extra=\"forbid\")")
+	if len(program.Parameters) > 0 {
+		t.WriteLine("")
+	}
+
+	for _, param := range program.Parameters {
+		if err := t.canceled(); err != nil {
+			return err
+		}
+		var annotation string
+		args := []string{"..."}
+		if param.Default != nil {
+			args[0] = FormatLiteral(param.Default, pythonLiteralSyntax)
+		}
+		switch param.Type {
+		case TypeString, TypeFile, TypeDirectory:
+			annotation = "str"
+		case TypeNumber:
+			annotation = "float"
+		case TypeInteger:
+			annotation = "int"
+		case TypeBoolean:
+			annotation = "bool"
+		case TypeCharacter:
+			annotation = "str"
+			args = append(args, "min_length=1", "max_length=1")
+		case TypeEnum:
+			if len(param.Constraints) == 0 {
+				return fmt.Errorf("enum type requires constraints with allowed values")
+			}
+			values := make([]string, len(param.Constraints))
+			for i, c := range param.Constraints {
+				values[i] = strconv.Quote(fmt.Sprint(c))
+			}
+			annotation = fmt.Sprintf("Literal[%s]", strings.Join(values, ", "))
+		default:
+			annotation = "Any"
+		}
+		if min, ok := param.Metadata["min"]; ok && (param.Type == TypeInteger || param.Type == TypeNumber) {
+			args = append(args, "ge="+min)
+		}
+		if max, ok := param.Metadata["max"]; ok && (param.Type == TypeInteger || param.Type == TypeNumber) {
+			args = append(args, "le="+max)
+		}
+		if IsOmittable(param) {
+			annotation, args[0] = "Optional["+annotation+"]", "None"
+		}
+		if param.Description != "" {
+			args = append(args, "description="+strconv.Quote(FormatDescription(param.Description)))
+		}
+
+		done := t.markSource(param.Pos, "parameter "+param.Name)
+		t.WriteLine("%s: %s = Field(%s)", param.Name, annotation, strings.Join(args, ", "))
+		done()
+	}
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("")
+	return nil
+}
+
+// writeModelValidation validates the arguments of the function with the
+// parameter model, then rebinds them to the validated values.
+func (t *PythonTranspiler) writeModelValidation(program *ast.Program) error {
+	if len(program.Parameters) == 0 {
+		return nil
+	}
+
+	t.WriteLine("# Parameter validation")
+	t.WriteLine("try:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("params = %s(", modelName(program))
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	for _, param := range program.Parameters {
+		t.WriteLine("%s=%s,", param.Name, param.Name)
+	}
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine(")")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.WriteLine("except ValidationError as e:")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.WriteLine("raise ValueError(f\"Invalid parameters for %s:\\n{e}\") from e", program.Name)
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+
+	for _, param := range program.Parameters {
+		if err := t.canceled(); err != nil {
+			return err
+		}
+		t.WriteLine("%s = params.%s", param.Name, param.Name)
+		if (param.Type == TypeFile || param.Type == TypeDirectory) && IsOmittable(param) {
+			t.WriteLine("%s_path = validate_path(%s) if %s is not None else None", param.Name, param.Name, param.Name)
+		} else if param.Type == TypeFile || param.Type == TypeDirectory {
+			t.WriteLine("%s_path = validate_path(%s)", param.Name, param.Name)
+		}
+	}
+	return nil
+}
+
 // validateStringType validates string parameters
 func (t *PythonTranspiler) validateStringType(base BaseTranspiler, param ast.Parameter) error {
 	base.WriteLine("if not isinstance(%s, str):", param.Name)
@@ -389,13 +1308,18 @@ func (t *PythonTranspiler) writeSecurityChecks(params []ast.Parameter) {
 				fileParams = true
 			}
 
-			t.WriteLine("if not is_running_in_docker():")
+			t.WriteLine("if %snot is_running_in_docker():", givenPath(param))
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
 			t.WriteLine("if not os.path.isfile(%s_path):", param.Name)
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
 			t.WriteLine("raise FileNotFoundError(f\"File {%s_path} does not exist\")", param.Name)
 			t.SetIndentLevel(t.GetIndentLevel() - 1)
 			t.SetIndentLevel(t.GetIndentLevel() - 1)
+			if algorithm, digest, ok := InputChecksum(param); ok {
+				// In a container, the path may only exist on the host
+				t.WriteLine("if %sos.path.isfile(%s_path):", givenPath(param), param.Name)
+				t.WriteLine("  verify_checksum(%s_path, %q, %q)", param.Name, algorithm, digest)
+			}
 		} else if param.Type == "directory" {
 			if !fileParams {
 				t.WriteLine("")
@@ -403,7 +1327,7 @@ func (t *PythonTranspiler) writeSecurityChecks(params []ast.Parameter) {
 				fileParams = true
 			}
 
-			t.WriteLine("if not is_running_in_docker():")
+			t.WriteLine("if %snot is_running_in_docker():", givenPath(param))
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
 			t.WriteLine("if not os.path.isdir(%s_path):", param.Name)
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
@@ -414,6 +1338,15 @@ func (t *PythonTranspiler) writeSecurityChecks(params []ast.Parameter) {
 	}
 }
 
+// givenPath returns the condition, followed by "and", that a path
+// parameter left off the command without a value was given, or nothing.
+func givenPath(param ast.Parameter) string {
+	if IsOmittable(param) {
+		return param.Name + "_path is not None and "
+	}
+	return ""
+}
+
 // processImplementations handles implementation blocks
 func (t *PythonTranspiler) processImplementations(program *ast.Program) error {
 	if len(program.Implementations) == 0 {
@@ -425,14 +1358,19 @@ func (t *PythonTranspiler) processImplementations(program *ast.Program) error {
 
 	// Process each implementation
 	for _, impl := range program.Implementations {
+		if err := t.canceled(); err != nil {
+			return err
+		}
 		handler, ok := t.GetImplementationHandlers()[impl.Name]
 		if !ok {
-			return fmt.Errorf("no handler registered for implementation type '%s'", impl.Name)
+			return unsupportedImplementation("python", &impl, "no handler registered for implementation type '%s'")
 		}
 
+		done := t.sourceSpan(impl.Pos, "implementation "+impl.Name)
 		if err := handler(t, &impl, program); err != nil {
 			return fmt.Errorf("error processing '%s' implementation: %w", impl.Name, err)
 		}
+		done()
 	}
 
 	return nil
@@ -446,31 +1384,75 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 		return fmt.Errorf("Docker image not specified or invalid")
 	}
 
+	if t.useJSONLogging() {
+		base.WriteLine("")
+		t.writeLog("info", "start", fmt.Sprintf("\"Running %s\"", program.Name),
+			"tool="+strconv.Quote(program.Name), "image="+strconv.Quote(image))
+	}
+
 	base.WriteLine("")
 	base.WriteLine("# Process file paths for Docker volume mounting")
 
 	// Get file parameters for volume mounting
 	fileParams := IdentifyFileParameters(program.Parameters)
 
+	// The parameters left off the command without a value are only
+	// mounted when given
+	omittable := func(name string) bool { return omittableParam(name, program.Parameters) }
+	whenGiven := func(name string, write func()) {
+		if !omittable(name) {
+			write()
+			return
+		}
+		base.WriteLine("if %s is not None:", name)
+		base.SetIndentLevel(base.GetIndentLevel() + 1)
+		write()
+		base.SetIndentLevel(base.GetIndentLevel() - 1)
+	}
+
 	if len(fileParams) > 0 {
 		// Setup for file parameters
 		for _, param := range fileParams {
 			base.WriteLine("# Process %s for Docker", param)
-			base.WriteLine("%s_abspath = os.path.abspath(%s_path if '%s_path' in locals() else %s)",
-				param, param, param, param)
-			base.WriteLine("%s_dir = os.path.dirname(%s_abspath)", param, param)
-			base.WriteLine("%s_filename = os.path.basename(%s)", param, param)
+			if omittable(param) {
+				base.WriteLine("%s_filename = None", param)
+			}
+			whenGiven(param, func() {
+				base.WriteLine("%s_abspath = os.path.abspath(%s_path if '%s_path' in locals() else %s)",
+					param, param, param, param)
+				base.WriteLine("%s_dir = os.path.dirname(%s_abspath)", param, param)
+				base.WriteLine("%s_filename = os.path.basename(%s)", param, param)
+			})
 		}
 
-		// Use first file parameter's directory as main mount point
+		// Use the directory of the first file parameter always given as
+		// main mount point
+		fileParams = mainFirst(fileParams, program.Parameters)
 		base.WriteLine("")
 		base.WriteLine("# Main volume mount point")
-		base.WriteLine("main_mount_dir = %s_dir", fileParams[0])
+		if main := fileParams[0]; omittable(main) {
+			base.WriteLine("main_mount_dir = %s_dir if %s is not None else os.path.abspath(os.getcwd())", main, main)
+		} else {
+			base.WriteLine("main_mount_dir = %s_dir", main)
+		}
 	} else {
 		// Fallback to current directory
 		base.WriteLine("# No file parameters found, using current directory")
 		base.WriteLine("main_mount_dir = os.path.abspath(os.getcwd())")
 	}
+	base.WriteLine("")
+	base.WriteLine("# Results directory, mounted where the tool writes its results")
+	base.WriteLine("if output_dir is None:")
+	base.WriteLine("  output_dir = os.path.join(main_mount_dir, \"%s_results\")", program.Name)
+	base.WriteLine("output_dir = os.path.abspath(output_dir)")
+	base.WriteLine("log_files = log_paths(output_dir)")
+	if t.useWorkDir() {
+		// The results go where they would with the inputs mounted
+		base.WriteLine("")
+		base.WriteLine("# Work directory of the run, removed after it")
+		base.WriteLine("results_parent = main_mount_dir")
+		base.WriteLine("work_dir = tempfile.mkdtemp(prefix=\"baryon-\")")
+	}
 
 	// Setup execution block with error handling
 	base.WriteLine("")
@@ -478,6 +1460,26 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 	base.WriteLine("try:")
 	base.SetIndentLevel(base.GetIndentLevel() + 1)
 
+	if t.useWorkDir() {
+		base.WriteLine("# Stage the inputs in the work directory, next to the mount point of the results")
+		base.WriteLine("staged = [\"%s_results\"]", program.Name)
+		if len(fileParams) > 0 {
+			base.WriteLine("if not dry_run:")
+			base.SetIndentLevel(base.GetIndentLevel() + 1)
+			for _, param := range fileParams {
+				whenGiven(param, func() {
+					base.WriteLine("staged.append(stage_input(%s_abspath, work_dir))", param)
+				})
+			}
+			base.SetIndentLevel(base.GetIndentLevel() - 1)
+			for _, param := range fileParams {
+				base.WriteLine("%s_dir = work_dir", param)
+			}
+		}
+		base.WriteLine("main_mount_dir = work_dir")
+		base.WriteLine("")
+	}
+
 	// Prepare Docker volumes
 	base.WriteLine("# Prepare Docker volumes")
 	base.WriteLine("volumes = {}")
@@ -492,7 +1494,9 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 
 					// Check if src is a parameter reference
 					if IsParamReference(src, program.Parameters) {
-						base.WriteLine("volumes[%s_dir] = \"%s\"", src, dst)
+						whenGiven(src, func() {
+							base.WriteLine("volumes[%s_dir] = \"%s\"", src, dst)
+						})
 					} else if src == "parent-folder" || src == "parent_folder" {
 						base.WriteLine("volumes[main_mount_dir] = \"%s\"", dst)
 					} else {
@@ -501,9 +1505,18 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 				}
 			}
 		}
+		base.WriteLine("volumes[output_dir] = \"%s\"", resultsMount(program))
 	} else {
-		// Default volume mapping
+		// Default volume mapping, with the directories of the other inputs
 		base.WriteLine("volumes[main_mount_dir] = \"/data\"")
+		base.WriteLine("volumes[output_dir] = \"%s\"", resultsMount(program))
+		if len(fileParams) > 1 && !t.useWorkDir() {
+			for _, param := range fileParams[1:] {
+				whenGiven(param, func() {
+					base.WriteLine("%s_filename = mount_input(volumes, %s_dir, %s_filename)", param, param, param)
+				})
+			}
+		}
 	}
 
 	// Prepare environment variables
@@ -521,7 +1534,9 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 
 					// Check if val is a parameter reference
 					if IsParamReference(val, program.Parameters) {
-						base.WriteLine("env_vars[\"%s\"] = str(%s)", key, val)
+						whenGiven(val, func() {
+							base.WriteLine("env_vars[\"%s\"] = str(%s)", key, val)
+						})
 					} else {
 						base.WriteLine("env_vars[\"%s\"] = \"%s\"", key, val)
 					}
@@ -536,16 +1551,31 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 	base.WriteLine("docker_args = []")
 	args, ok := impl.Fields["arguments"].([]any)
 	if ok && len(args) > 0 {
-		for _, arg := range args {
+		done := t.markSource(impl.FieldPosition("arguments"), "arguments of "+impl.Name)
+		for i, arg := range args {
 			argStr := fmt.Sprintf("%v", arg)
 
-			// Skip placeholders
-			if argStr == "_" {
+			// Skip placeholders, and the options written with the
+			// parameter after them
+			if argStr == "_" || heldOption(args, i, program.Parameters) {
 				continue
 			}
 
 			// Check if it's a parameter reference
-			if IsParamReference(argStr, program.Parameters) {
+			if IsParamReference(argStr, program.Parameters) && omittable(argStr) && GetParamType(argStr, program.Parameters) != TypeBoolean {
+				// Left off without a value, with its option
+				base.WriteLine("if %s is not None:", argStr)
+				base.SetIndentLevel(base.GetIndentLevel() + 1)
+				if i > 0 && heldOption(args, i-1, program.Parameters) {
+					base.WriteLine("docker_args.append(%s)", strconv.Quote(strings.Trim(fmt.Sprint(args[i-1]), "\"'")))
+				}
+				if Contains(fileParams, argStr) {
+					base.WriteLine("docker_args.append(%s_filename)", argStr)
+				} else {
+					base.WriteLine("docker_args.append(str(%s))", argStr)
+				}
+				base.SetIndentLevel(base.GetIndentLevel() - 1)
+			} else if IsParamReference(argStr, program.Parameters) {
 				paramType := GetParamType(argStr, program.Parameters)
 
 				if paramType == "file" || (paramType == "string" && Contains(fileParams, argStr)) {
@@ -555,7 +1585,7 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 					// Convert boolean to flag
 					base.WriteLine("if %s:", argStr)
 					base.SetIndentLevel(base.GetIndentLevel() + 1)
-					base.WriteLine("docker_args.append(\"--true-flag\")")
+					base.WriteLine("docker_args.append(%s)", strconv.Quote(BooleanFlag(argStr, program.Parameters)))
 					base.SetIndentLevel(base.GetIndentLevel() - 1)
 				} else {
 					base.WriteLine("docker_args.append(str(%s))", argStr)
@@ -568,39 +1598,173 @@ func (t *PythonTranspiler) handleDockerImplementation(base BaseTranspiler, impl
 				base.WriteLine("docker_args.append(\"%s\")", argStr)
 			}
 		}
+		done()
 	}
 
-	// Run the Docker container
+	// Dry runs stop before the container runs
 	base.WriteLine("")
-	base.WriteLine("# Run Docker container")
-	base.WriteLine("run_docker(\"%s\", volumes, env_vars, docker_args)", image)
+	base.WriteLine("if dry_run:")
+	base.SetIndentLevel(base.GetIndentLevel() + 1)
+	base.WriteLine("command = shlex.join(docker_command(\"%s\", volumes, env_vars, docker_args))", image)
+	base.WriteLine("print(command)")
+	base.WriteLine("return Result(status=\"dry_run\", output_dir=\"\", message=command)")
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
 
-	// Create output directory and return result
+	// The start of the run, recorded in the manifest
 	base.WriteLine("")
-	base.WriteLine("# Create results directory")
-	base.WriteLine("output_dir = os.path.join(main_mount_dir, \"%s_results\")", program.Name)
 	base.WriteLine("os.makedirs(output_dir, exist_ok=True)")
+	base.WriteLine("started = datetime.now(timezone.utc).isoformat()")
+	if t.useInstrumentation() {
+		base.WriteLine("usage = {}")
+		base.WriteLine("run_started = time.monotonic()")
+	}
 
-	base.WriteLine("")
-	base.WriteLine("return Result(status=\"success\", output_dir=output_dir)")
+	data := SectionData{Target: "python", Program: program, Implementation: impl, Image: image}
+	t.writeSection(SectionDocker, data, func() {
+		// Run the Docker container
+		base.WriteLine("")
+		base.WriteLine("# Run Docker container")
+		args := "volumes, env_vars, docker_args"
+		if t.useInstrumentation() {
+			args += ", usage"
+		}
+		args += ", log_files"
+		if t.useAsync() {
+			base.WriteLine("await run_docker(\"%s\", %s)", image, args)
+		} else {
+			base.WriteLine("run_docker(\"%s\", %s)", image, args)
+		}
+	})
+	if t.useInstrumentation() {
+		base.WriteLine("usage[\"wall_seconds\"] = round(time.monotonic() - run_started, 3)")
+	}
+
+	t.writeSection(SectionResult, data, func() {
+		if t.useWorkDir() {
+			base.WriteLine("")
+			base.WriteLine("# Copy the outputs of the work directory")
+			base.WriteLine("collect_outputs(work_dir, results_parent, staged)")
+		}
+
+		base.WriteLine("")
+		base.WriteLine("# Record the run")
+		base.WriteLine("parameters = {")
+		base.SetIndentLevel(base.GetIndentLevel() + 1)
+		for _, param := range program.Parameters {
+			base.WriteLine("\"%s\": %s,", param.Name, param.Name)
+		}
+		base.SetIndentLevel(base.GetIndentLevel() - 1)
+		base.WriteLine("}")
+		if t.useInstrumentation() {
+			base.WriteLine("manifest = write_manifest(output_dir, \"%s\", \"%s\", parameters, started, usage, log_files)", program.Name, image)
+			t.writeCompletion(program)
+			base.WriteLine("")
+			base.WriteLine("return Result(status=\"success\", output_dir=output_dir, manifest=manifest, resources=usage, logs=log_files)")
+		} else {
+			base.WriteLine("manifest = write_manifest(output_dir, \"%s\", \"%s\", parameters, started, logs=log_files)", program.Name, image)
+			t.writeCompletion(program)
+			base.WriteLine("")
+			base.WriteLine("return Result(status=\"success\", output_dir=output_dir, manifest=manifest, logs=log_files)")
+		}
+	})
 
 	// Error handling
 	base.SetIndentLevel(base.GetIndentLevel() - 1)
 	base.WriteLine("except Exception as e:")
 	base.SetIndentLevel(base.GetIndentLevel() + 1)
-	base.WriteLine("logger.error(f\"Docker execution failed: {str(e)}\")")
-	base.WriteLine("return Result(status=\"error\", output_dir=\"\", message=str(e))")
+	t.writeLog("error", "error", "f\"Docker execution failed: {str(e)}\"")
+	base.WriteLine("logs = {stream: path for stream, path in log_files.items() if os.path.exists(path)}")
+	base.WriteLine("return Result(status=\"error\", output_dir=\"\", message=str(e), logs=logs)")
 	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	if t.useWorkDir() {
+		base.WriteLine("finally:")
+		base.WriteLine("  shutil.rmtree(work_dir, ignore_errors=True)")
+	}
 
 	return nil
 }
 
+// writeCompletion logs the completion event of a run, with JSON logging.
+func (t *PythonTranspiler) writeCompletion(program *ast.Program) {
+	if t.useJSONLogging() {
+		t.writeLog("info", "completion", fmt.Sprintf("\"%s completed\"", program.Name),
+			"tool="+strconv.Quote(program.Name), "output_dir=output_dir", "manifest=manifest")
+	}
+}
+
+// pythonLogging records which of the logging options the entry point has:
+// the ones clashing with a parameter are left out.
+type pythonLogging struct {
+	verbose, quiet, logFile bool
+}
+
+func newPythonLogging(params []ast.Parameter) pythonLogging {
+	free := func(flag string) bool {
+		return !slices.ContainsFunc(params, func(p ast.Parameter) bool {
+			return p.Name == flag || strings.ReplaceAll(p.Name, "_", "-") == flag
+		})
+	}
+	return pythonLogging{verbose: free("verbose"), quiet: free("quiet"), logFile: free("log-file")}
+}
+
+// writeLoggingOptions adds the --verbose, --quiet and --log-file options.
+func (t *PythonTranspiler) writeLoggingOptions(l pythonLogging) {
+	t.WriteLine("")
+	t.WriteLine("logging_options = parser.add_argument_group(\"logging\")")
+	t.WriteLine("verbosity = logging_options.add_mutually_exclusive_group()")
+	if l.verbose {
+		t.WriteLine("verbosity.add_argument('--verbose', dest=\"log_verbose\", action=\"store_true\", help=\"Log debug messages\")")
+	}
+	if l.quiet {
+		t.WriteLine("verbosity.add_argument('--quiet', dest=\"log_quiet\", action=\"store_true\", help=\"Only log errors\")")
+	}
+	if l.logFile {
+		t.WriteLine("logging_options.add_argument('--log-file', dest=\"log_file\", help=\"Also write the log to this file\")")
+	}
+}
+
+// writeLoggingConfig configures the logger from the logging options.
+func (t *PythonTranspiler) writeLoggingConfig(l pythonLogging) {
+	t.WriteLine("")
+	t.WriteLine("log_level = logging.INFO")
+	if l.verbose {
+		t.WriteLine("if args.log_verbose:")
+		t.WriteLine("  log_level = logging.DEBUG")
+	}
+	if l.quiet {
+		t.WriteLine("if args.log_quiet:")
+		t.WriteLine("  log_level = logging.ERROR")
+	}
+	t.WriteLine("log_handlers = [logging.StreamHandler()]")
+	if l.logFile {
+		t.WriteLine("if args.log_file:")
+		t.WriteLine("  log_handlers.append(logging.FileHandler(args.log_file))")
+	}
+	if t.useJSONLogging() {
+		t.WriteLine("for log_handler in log_handlers:")
+		t.WriteLine("  log_handler.setFormatter(JsonFormatter())")
+	}
+	t.WriteLine("logging.basicConfig(level=log_level, format=\"%%(asctime)s %%(levelname)s %%(message)s\", handlers=log_handlers)")
+}
+
+// pythonAdvanced reports whether a parameter is in the advanced options of
+// the command line, hidden parameters being left out of the help.
+func pythonAdvanced(param ast.Parameter) bool {
+	return param.Metadata["advanced"] == "true" && param.Metadata["hidden"] != "true"
+}
+
 // writeEntryPoint adds a main block for direct execution
 func (t *PythonTranspiler) writeEntryPoint(program *ast.Program) {
 	t.WriteLine("")
 	t.WriteLine("")
-	t.WriteLine("if __name__ == \"__main__\":")
-	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	if t.mainFunction {
+		t.WriteLine("def main() -> None:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("\"\"\"Run %s from the command line.\"\"\"", program.Name)
+	} else {
+		t.WriteLine("if __name__ == \"__main__\":")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+	}
 	t.WriteLine("import argparse")
 	t.WriteLine("")
 	t.WriteLine("parser = argparse.ArgumentParser(description=\"%s\")",
@@ -608,6 +1772,11 @@ func (t *PythonTranspiler) writeEntryPoint(program *ast.Program) {
 
 	var argName string
 
+	// Advanced parameters are listed after the others in the help
+	if slices.ContainsFunc(program.Parameters, pythonAdvanced) {
+		t.WriteLine("advanced_options = parser.add_argument_group(\"advanced options\")")
+	}
+
 	// Add arguments for each parameter
 	for _, param := range program.Parameters {
 		argName = "--" + param.Name
@@ -616,40 +1785,101 @@ func (t *PythonTranspiler) writeEntryPoint(program *ast.Program) {
 			helpText = fmt.Sprintf("Parameter of type '%s'", param.Type)
 		}
 
+		// A JSON file of parameters may replace the required options
+		required := param.Default == nil && !IsOmittable(param) && !t.usePydantic()
+
+		args := []string{fmt.Sprintf("'%s'", argName)}
 		switch param.Type {
 		case "boolean":
-			t.WriteLine("parser.add_argument('%s', action='store_true', help=\"%s\")",
-				argName, helpText)
+			args = append(args, "action=argparse.BooleanOptionalAction")
 		case "enum":
 			if len(param.Constraints) > 0 {
 				choices := make([]string, len(param.Constraints))
 				for i, c := range param.Constraints {
 					choices[i] = fmt.Sprintf("\"%v\"", c)
 				}
-				choicesStr := strings.Join(choices, ", ")
-				t.WriteLine("parser.add_argument('%s', choices=[%s], help=\"%s\")",
-					argName, choicesStr, helpText)
-			} else {
-				t.WriteLine("parser.add_argument('%s', help=\"%s\")", argName, helpText)
+				args = append(args, fmt.Sprintf("choices=[%s]", strings.Join(choices, ", ")))
 			}
-		default:
-			t.WriteLine("parser.add_argument('%s', help=\"%s\")", argName, helpText)
+		case "integer":
+			args = append(args, "type=int")
+		case "number":
+			args = append(args, "type=float")
+		}
+		// The environment variable overrides the default, and stands for
+		// a required option
+		env := naming.EnvVariable(program.Name, param.Name)
+		override := []string{strconv.Quote(env), "None"}
+		if param.Default != nil {
+			override[1] = FormatLiteral(param.Default, pythonLiteralSyntax)
+		}
+		if convert, ok := pythonEnvConverters[param.Type]; ok {
+			override = append(override, convert)
+		}
+		args = append(args, fmt.Sprintf("default=env_default(%s)", strings.Join(override, ", ")))
+		if param.Default == nil && required {
+			args = append(args, fmt.Sprintf("required=%s not in os.environ", strconv.Quote(env)))
+		}
+		group, help := "parser", fmt.Sprintf("\"%s (env %s)\"", helpText, env)
+		if param.Metadata["hidden"] == "true" {
+			help = "argparse.SUPPRESS"
+		} else if pythonAdvanced(param) {
+			group = "advanced_options"
 		}
+		args = append(args, "help="+help)
+		t.WriteLine("%s.add_argument(%s)", group, strings.Join(args, ", "))
 	}
 
+	if t.usePydantic() {
+		t.WriteLine("parser.add_argument('--params-json', help=\"JSON file with the parameters, instead of the options\")")
+	}
+	outputEnv := naming.EnvVariable(program.Name, "output_dir")
+	t.WriteLine("parser.add_argument('--output-dir', dest=\"output_dir\", default=env_default(%s, None), help=\"Results directory (env %s)\")",
+		strconv.Quote(outputEnv), outputEnv)
+	t.WriteLine("parser.add_argument('--dry-run', action=\"store_true\", help=\"Print the docker command instead of running it\")")
+	logging := newPythonLogging(program.Parameters)
+	t.writeLoggingOptions(logging)
+
 	t.WriteLine("")
 	t.WriteLine("args = parser.parse_args()")
+	t.writeLoggingConfig(logging)
+	t.WriteLine("signal.signal(signal.SIGTERM, exit_on_signal)")
 	t.WriteLine("")
 
+	// The async wrapper runs in its own event loop
+	call, end := program.Name+"(", ")"
+	if t.useAsync() {
+		call, end = "asyncio.run("+program.Name+"(", "))"
+	}
+
+	if t.usePydantic() {
+		t.WriteLine("if args.params_json:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("with open(args.params_json) as f:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+		t.WriteLine("params = %s.model_validate_json(f.read())", modelName(program))
+		t.SetIndentLevel(t.GetIndentLevel() - 1)
+		t.WriteLine("result = %s**params.model_dump(), output_dir=args.output_dir, dry_run=args.dry_run%s", call, end)
+		t.SetIndentLevel(t.GetIndentLevel() - 1)
+		t.WriteLine("else:")
+		t.SetIndentLevel(t.GetIndentLevel() + 1)
+	}
+
 	// Call the function with parsed arguments
-	t.WriteLine("result = %s(", program.Name)
+	t.WriteLine("result = %s", call)
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
 	for _, param := range program.Parameters {
 		t.WriteLine("%s=args.%s,", param.Name, param.Name)
 	}
+	t.WriteLine("output_dir=args.output_dir,")
+	t.WriteLine("dry_run=args.dry_run,")
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
-	t.WriteLine(")")
+	t.WriteLine("%s", end)
+	if t.usePydantic() {
+		t.SetIndentLevel(t.GetIndentLevel() - 1)
+	}
 	t.WriteLine("")
+	t.WriteLine("if result.status == \"dry_run\":")
+	t.WriteLine("  sys.exit(0)")
 	t.WriteLine("print(f\"Status: {result.status}\")")
 	t.WriteLine("if result.status == \"success\":")
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
@@ -661,4 +1891,11 @@ func (t *PythonTranspiler) writeEntryPoint(program *ast.Program) {
 	t.WriteLine("sys.exit(1)")
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
 	t.SetIndentLevel(t.GetIndentLevel() - 1)
+
+	if t.mainFunction {
+		t.WriteLine("")
+		t.WriteLine("")
+		t.WriteLine("if __name__ == \"__main__\":")
+		t.WriteLine("  main()")
+	}
 }
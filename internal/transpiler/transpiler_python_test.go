@@ -0,0 +1,345 @@
+package transpiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func alignProgram() *ast.Program {
+	return &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "align_reads"},
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "reads", BaseNode: ast.BaseNode{Description: "Input reads"}}, Type: TypeFile},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "sep"}, Type: TypeCharacter},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "mode"}, Type: TypeEnum, Constraints: []any{"fast", "sensitive"}, Default: "fast"},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "threads"}, Type: TypeInteger, Default: 4},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name:   "run_docker",
+			Fields: map[string]any{"image": "biocontainers/bwa:0.7.17", "arguments": []any{"reads", "mode"}},
+		}},
+	}
+}
+
+func TestPython_PydanticModels(t *testing.T) {
+	code := transpileWithOptions(t, "python", map[string]string{pythonModels: "pydantic"}, alignProgram())
+	for _, expected := range []string{
+		"from pydantic import BaseModel, ConfigDict, Field, ValidationError\n",
+		"class AlignReadsParameters(BaseModel):\n",
+		`  reads: str = Field(..., description="Input reads")` + "\n",
+		"  sep: str = Field(..., min_length=1, max_length=1)\n",
+		`  mode: Literal["fast", "sensitive"] = Field("fast")` + "\n",
+		"  threads: int = Field(4)\n",
+		"    params = AlignReadsParameters(\n",
+		"  reads_path = validate_path(reads)\n",
+		"      params = AlignReadsParameters.model_validate_json(f.read())\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "isinstance(") {
+		t.Errorf("pydantic models still use isinstance checks:\n%s", code)
+	}
+
+	code = transpileWithOptions(t, "python", nil, alignProgram())
+	if strings.Contains(code, "pydantic") || !strings.Contains(code, "isinstance(reads, str)") {
+		t.Errorf("default models should use isinstance checks:\n%s", code)
+	}
+}
+
+func TestPython_DockerSDK(t *testing.T) {
+	code := transpileWithOptions(t, "python", map[string]string{pythonDocker: "sdk"}, alignProgram())
+	for _, expected := range []string{
+		"import docker\nfrom docker.types import Mount\n",
+		"  client = docker.from_env()\n",
+		`  mounts = [Mount(target=dst, source=src, type="bind") for src, dst in volumes.items()]` + "\n",
+		"    for chunk in container.logs(stream=True, follow=True):\n",
+		"  finally:\n    container.remove(force=True)\n",
+		`run_docker("biocontainers/bwa:0.7.17", volumes, env_vars, docker_args, log_files)`,
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "subprocess.run(") {
+		t.Errorf("the docker SDK mode still runs the docker command:\n%s", code)
+	}
+}
+
+func TestPython_Async(t *testing.T) {
+	code := transpileWithOptions(t, "python", map[string]string{pythonAsync: "true"}, alignProgram())
+	for _, expected := range []string{
+		"import asyncio\n",
+		"async def run_docker(image: str, volumes: Dict[str, str], env: Dict[str, str], args: List[str], log_files: Optional[Dict[str, str]] = None) -> str:\n",
+		"  proc = await asyncio.create_subprocess_exec(\n",
+		"async def align_reads(",
+		`    await run_docker("biocontainers/bwa:0.7.17", volumes, env_vars, docker_args, log_files)` + "\n",
+		"  result = asyncio.run(align_reads(\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	// The blocking SDK runs in a thread
+	code = transpileWithOptions(t, "python", map[string]string{pythonAsync: "true", pythonDocker: "sdk"}, alignProgram())
+	if !strings.Contains(code, "def run_docker_blocking(") ||
+		!strings.Contains(code, "return await asyncio.to_thread(run_docker_blocking, image, volumes, env, args, log_files)") {
+		t.Errorf("async SDK mode does not run the SDK in a thread:\n%s", code)
+	}
+}
+
+func TestPython_TranspilePackage(t *testing.T) {
+	program := alignProgram()
+	program.Description = "Align reads"
+	program.Metadata = map[string]string{"version": "1.2.0", "license": "MIT"}
+
+	tr := NewPythonTranspiler()
+	if err := ApplyOptions(tr, "python", map[string]string{pythonModels: "pydantic"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+
+	expected := map[string][]string{
+		"pyproject.toml": {
+			`name = "align-reads"` + "\n",
+			`version = "1.2.0"` + "\n",
+			`dependencies = ["pydantic>=2"]` + "\n",
+			`license = {text = "MIT"}` + "\n",
+			"[project.scripts]\nalign-reads = \"align_reads.tool:main\"\n",
+		},
+		"src/align_reads/__init__.py": {
+			"from .tool import Result, align_reads, AlignReadsParameters\n",
+		},
+		"src/align_reads/__main__.py": {"from .tool import main\n"},
+		"src/align_reads/tool.py": {
+			"def main() -> None:\n",
+			"if __name__ == \"__main__\":\n  main()\n",
+		},
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("TranspilePackage() files = %v, want %d files", files, len(expected))
+	}
+	for path, fragments := range expected {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+}
+
+func TestPython_ArgparseDefaults(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "ratio"}, Type: TypeNumber, Default: 0.5})
+	code := transpileWithOptions(t, "python", nil, program)
+	for _, expected := range []string{
+		`parser.add_argument('--reads', default=env_default("BARYON_ALIGN_READS_READS", None), ` +
+			`required="BARYON_ALIGN_READS_READS" not in os.environ, help="Input reads (env BARYON_ALIGN_READS_READS)")`,
+		`parser.add_argument('--mode', choices=["fast", "sensitive"], default=env_default("BARYON_ALIGN_READS_MODE", "fast"), help=`,
+		`parser.add_argument('--threads', type=int, default=env_default("BARYON_ALIGN_READS_THREADS", 4, int), help=`,
+		`parser.add_argument('--ratio', type=float, default=env_default("BARYON_ALIGN_READS_RATIO", 0.5, float), help=`,
+		// Defaulted parameters are validated too
+		"  if not isinstance(threads, int) or isinstance(threads, bool):\n",
+		// The required parameters come first, the signature is kept as is
+		"def align_reads(reads: str, sep: str, mode: str = \"fast\", threads: int = 4, ratio: float = 0.5, output_dir: Optional[str] = None, dry_run: bool = False)",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "index"}, Type: TypeFile})
+	code = transpileWithOptions(t, "python", nil, program)
+	if !strings.Contains(code, "def align_reads(*, reads: str,") {
+		t.Errorf("a required parameter after defaulted ones should make the parameters keyword-only:\n%s", code)
+	}
+}
+
+func TestPython_BooleanFlags(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters, ast.Parameter{
+		NamedBaseNode: ast.NamedBaseNode{Name: "fast"},
+		Type:          TypeBoolean,
+		Default:       true,
+		Metadata:      map[string]string{"flag": "--fast-mode"},
+	})
+	program.Implementations[0].Fields["arguments"] = []any{"reads", "fast"}
+	code := transpileWithOptions(t, "python", nil, program)
+	for _, expected := range []string{
+		"parser.add_argument('--fast', action=argparse.BooleanOptionalAction, default=env_default(\"BARYON_ALIGN_READS_FAST\", True, env_flag), help=",
+		"    if fast:\n      docker_args.append(\"--fast-mode\")\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "'--fast', action=\"store_true\"") || strings.Contains(code, "--true-flag") {
+		t.Errorf("booleans should use BooleanOptionalAction and their flag name:\n%s", code)
+	}
+
+	delete(program.Parameters[len(program.Parameters)-1].Metadata, "flag")
+	code = transpileWithOptions(t, "python", nil, program)
+	if !strings.Contains(code, `docker_args.append("--fast")`) {
+		t.Errorf("the flag should default to the parameter name:\n%s", code)
+	}
+}
+
+func TestPython_HiddenAdvanced(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[2].Metadata = map[string]string{"hidden": "true"}
+	program.Parameters[3].Metadata = map[string]string{"advanced": "true"}
+	code := transpileWithOptions(t, "python", nil, program)
+	for _, expected := range []string{
+		"  advanced_options = parser.add_argument_group(\"advanced options\")\n",
+		`choices=["fast", "sensitive"], default=env_default("BARYON_ALIGN_READS_MODE", "fast"), help=argparse.SUPPRESS)` + "\n",
+		"  advanced_options.add_argument('--threads', type=int, ",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	code = transpileWithOptions(t, "python", nil, alignProgram())
+	if strings.Contains(code, "advanced_options") || strings.Contains(code, "argparse.SUPPRESS") {
+		t.Errorf("parameters shouldn't be hidden or advanced without the markers:\n%s", code)
+	}
+}
+
+func TestPython_LoggingOptions(t *testing.T) {
+	code := transpileWithOptions(t, "python", nil, alignProgram())
+	for _, expected := range []string{
+		`verbosity.add_argument('--verbose', dest="log_verbose", action="store_true", help=`,
+		`verbosity.add_argument('--quiet', dest="log_quiet", action="store_true", help=`,
+		`logging_options.add_argument('--log-file', dest="log_file", help=`,
+		"  if args.log_file:\n    log_handlers.append(logging.FileHandler(args.log_file))\n",
+		"  logging.basicConfig(level=log_level, format=\"%(asctime)s %(levelname)s %(message)s\", handlers=log_handlers)\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	// A parameter takes precedence over a logging option of the same name
+	program := alignProgram()
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "verbose"}, Type: TypeBoolean, Default: false})
+	code = transpileWithOptions(t, "python", nil, program)
+	if strings.Contains(code, "'--verbose', dest=") || strings.Contains(code, "args.log_verbose") {
+		t.Errorf("the verbose parameter should replace the logging option:\n%s", code)
+	}
+	if !strings.Contains(code, "args.log_quiet") {
+		t.Errorf("the other logging options should be kept:\n%s", code)
+	}
+}
+
+func TestPython_Manifest(t *testing.T) {
+	for _, options := range []map[string]string{nil, {pythonDocker: "sdk"}} {
+		code := transpileWithOptions(t, "python", options, alignProgram())
+		for _, expected := range []string{
+			"def write_manifest(output_dir: str, tool: str, image: str, parameters: Dict[str, Any], started: str,\n",
+			"    started = datetime.now(timezone.utc).isoformat()\n",
+			"      \"threads\": threads,\n",
+			`    manifest = write_manifest(output_dir, "align_reads", "biocontainers/bwa:0.7.17", parameters, started, logs=log_files)` + "\n",
+			"    return Result(status=\"success\", output_dir=output_dir, manifest=manifest, logs=log_files)\n",
+		} {
+			if !strings.Contains(code, expected) {
+				t.Errorf("generated code with options %v does not contain %q:\n%s", options, expected, code)
+			}
+		}
+	}
+}
+
+func TestPython_Instrumentation(t *testing.T) {
+	for _, options := range []map[string]string{
+		{pythonInstrument: "true"},
+		{pythonInstrument: "true", pythonAsync: "true"},
+		{pythonInstrument: "true", pythonDocker: "sdk"},
+	} {
+		code := transpileWithOptions(t, "python", options, alignProgram())
+		for _, expected := range []string{
+			"import threading\nimport time\n",
+			"def record_usage(usage: Optional[Dict[str, Any]], cpu_percent: float, memory_bytes: int) -> None:\n",
+			"args: List[str], usage: Optional[Dict[str, Any]] = None, log_files: Optional[Dict[str, str]] = None) -> str:\n",
+			"    run_started = time.monotonic()\n",
+			`run_docker("biocontainers/bwa:0.7.17", volumes, env_vars, docker_args, usage, log_files)` + "\n",
+			"    usage[\"wall_seconds\"] = round(time.monotonic() - run_started, 3)\n",
+			"parameters, started, usage, log_files)\n",
+			"    return Result(status=\"success\", output_dir=output_dir, manifest=manifest, resources=usage, logs=log_files)\n",
+		} {
+			if !strings.Contains(code, expected) {
+				t.Errorf("generated code with options %v does not contain %q:\n%s", options, expected, code)
+			}
+		}
+	}
+
+	code := transpileWithOptions(t, "python", nil, alignProgram())
+	if strings.Contains(code, "run_started") || strings.Contains(code, "import threading") {
+		t.Errorf("the usage should only be recorded when instrumented:\n%s", code)
+	}
+}
+
+func TestPython_WorkDir(t *testing.T) {
+	code := transpileWithOptions(t, "python", map[string]string{pythonWorkDir: "temp"}, alignProgram())
+	for _, expected := range []string{
+		"import shutil\n",
+		"import tempfile\n",
+		"def stage_input(path: str, work_dir: str) -> str:\n",
+		"  results_parent = main_mount_dir\n  work_dir = tempfile.mkdtemp(prefix=\"baryon-\")\n",
+		"    if not dry_run:\n      staged.append(stage_input(reads_abspath, work_dir))\n    reads_dir = work_dir\n    main_mount_dir = work_dir\n",
+		"    collect_outputs(work_dir, results_parent, staged)\n",
+		"    staged = [\"align_reads_results\"]\n",
+		"  finally:\n    shutil.rmtree(work_dir, ignore_errors=True)\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	code = transpileWithOptions(t, "python", nil, alignProgram())
+	if strings.Contains(code, "work_dir") {
+		t.Errorf("the inputs should only be staged with a work directory:\n%s", code)
+	}
+}
+
+func TestPython_JSONLogging(t *testing.T) {
+	for _, options := range []map[string]string{
+		{pythonLog: "json"},
+		{pythonLog: "json", pythonAsync: "true"},
+	} {
+		code := transpileWithOptions(t, "python", options, alignProgram())
+		for _, expected := range []string{
+			"class JsonFormatter(logging.Formatter):\n",
+			"  logger.log(level, message, extra={\"event\": event, \"fields\": fields})\n",
+			`  log_event(logging.INFO, "start", "Running align_reads", tool="align_reads", image="biocontainers/bwa:0.7.17")` + "\n",
+			`log_event(logging.INFO, "docker_command", f"Running Docker command: {' '.join(cmd)}", command=cmd)` + "\n",
+			`    log_event(logging.INFO, "completion", "align_reads completed", tool="align_reads", output_dir=output_dir, manifest=manifest)` + "\n",
+			`    log_event(logging.ERROR, "error", f"Docker execution failed: {str(e)}")` + "\n",
+			"    log_handler.setFormatter(JsonFormatter())\n",
+		} {
+			if !strings.Contains(code, expected) {
+				t.Errorf("generated code with options %v does not contain %q:\n%s", options, expected, code)
+			}
+		}
+		if strings.Contains(code, "logger.info(") || strings.Contains(code, "logger.error(") {
+			t.Errorf("the events should be logged with log_event:\n%s", code)
+		}
+	}
+
+	code := transpileWithOptions(t, "python", nil, alignProgram())
+	if strings.Contains(code, "log_event") {
+		t.Errorf("the events should only be logged as JSON lines with the option:\n%s", code)
+	}
+}
@@ -1,10 +1,15 @@
 package transpiler
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/naming"
 )
 
 func init() {
@@ -12,9 +17,70 @@ func init() {
 		Extension:   ".R",
 		Display:     "R",
 		Initializer: func() Transpiler { return NewRTranspiler() },
+		Capabilities: &Capabilities{
+			ParameterTypes:  allTypes(),
+			Implementations: []string{"run_docker"},
+			Outputs:         OutputsDirectory,
+			Limitations: map[string]Limitation{
+				fieldFeature("run_docker", "command"):         {Degraded, "the command field is ignored, use arguments instead"},
+				fieldFeature("run_docker", "version_command"): {Degraded, "the version command is only used by the Galaxy target"},
+				fieldFeature("run_docker", "exit_codes"):      {Degraded, "exit codes are only used by the Galaxy target, any non-zero code fails the run"},
+				fieldFeature("run_docker", "error_patterns"):  {Degraded, "error patterns are only used by the Galaxy target"},
+				fieldFeature("run_docker", "config_files"):    {Unsupported, "config files are only written by the Galaxy target"},
+				blockFeature("resources"):                     {Degraded, "resources are only requested by the Nextflow target"},
+				typeFeature(TypeSamplesheet):                  {Degraded, "samplesheets are passed as a CSV file, the tool reads its samples"},
+				clauseFeature("when"):                         {Degraded, "conditional parameters are always used"},
+				outputFeature("*"):                            {Degraded, "outputs are not declared, results are returned as a directory"},
+			},
+		},
 	})
 }
 
+// rLiteralSyntax spells literal values in R.
+var rLiteralSyntax = LiteralSyntax{True: "TRUE", False: "FALSE", Quote: rString}
+
+// rString returns the R string literal of s. Every string of the program
+// emitted in R code goes through it: the escapes of Go's quoting, such as
+// \n, \x07 or \u00e9, are valid R too, but R strings can't contain NUL,
+// which is dropped.
+func rString(s string) string {
+	return strconv.Quote(strings.ReplaceAll(s, "\x00", ""))
+}
+
+// rDocEscape escapes the text of roxygen comments, which is copied into
+// the Rd pages: @ starts a tag in roxygen and % a comment in Rd.
+var rDocEscape = strings.NewReplacer(`\`, `\\`, "%", `\%`, "{", `\{`, "}", `\}`, "@", "@@")
+
+// rDoc returns the text of a roxygen comment, on one line.
+func rDoc(text string) string {
+	return rDocEscape.Replace(FormatDescription(text))
+}
+
+// R target options.
+const (
+	// rInstrument records the wall time of the container in the result and
+	// the manifest, when "true".
+	rInstrument = "instrument"
+	// rCLI adds an optparse entry point running the function from Rscript,
+	// when "true".
+	rCLI = "cli"
+	// rDocker selects how run_in_docker builds the docker command:
+	// "normalizepath" maps the volumes with the normalizepath package, so
+	// that they resolve on the host when R itself runs in a container, and
+	// "system2" only uses base R.
+	rDocker = "docker"
+	// rValidation selects how the parameters are validated: with if/stop
+	// blocks in base R, or with the assertions of the checkmate package.
+	rValidation = "validation"
+	// rWorkDir selects what is mounted into the container: "input" mounts
+	// the directory of the first input, "temp" a temporary work directory
+	// of the run, with the inputs staged into it.
+	rWorkDir = "workdir"
+	// rLog logs the events of the runs as JSON lines on the standard
+	// error, when "json".
+	rLog = "logging"
+)
+
 // RTranspiler converts Baryon AST to R code.
 type RTranspiler struct {
 	TranspilerBase
@@ -45,11 +111,279 @@ func NewRTranspiler() *RTranspiler {
 	return t
 }
 
+// TargetOptions implements Configurable.
+func (t *RTranspiler) TargetOptions() []TargetOption {
+	return []TargetOption{
+		{Name: rInstrument, Values: []string{"false", "true"}, Default: "false",
+			Help: "record the wall time of the container"},
+		{Name: rCLI, Values: []string{"false", "true"}, Default: "false",
+			Help: "add an optparse entry point, to run the script with Rscript"},
+		{Name: rDocker, Values: []string{"normalizepath", "system2"}, Default: "normalizepath",
+			Help: "run docker with the normalizepath package, or with base R only"},
+		{Name: rValidation, Values: []string{"base", "checkmate"}, Default: "base",
+			Help: "validate the parameters with if/stop blocks, or with checkmate assertions"},
+		{Name: rWorkDir, Values: []string{"input", "temp"}, Default: "input",
+			Help: "mount the directory of the inputs, or stage them in a temporary work directory"},
+		{Name: rLog, Values: []string{"none", "json"}, Default: "none",
+			Help: "log the events of the runs as JSON lines"},
+	}
+}
+
+// useInstrumentation reports whether the duration of the runs is recorded.
+func (t *RTranspiler) useInstrumentation() bool {
+	return t.option(rInstrument, "false") == "true"
+}
+
+// useCLI reports whether the script has a command line entry point.
+func (t *RTranspiler) useCLI() bool {
+	return t.option(rCLI, "false") == "true"
+}
+
+// usePlainDocker reports whether run_in_docker only uses base R.
+func (t *RTranspiler) usePlainDocker() bool {
+	return t.option(rDocker, "normalizepath") == "system2"
+}
+
+// useCheckmate reports whether the parameters are validated with checkmate.
+func (t *RTranspiler) useCheckmate() bool {
+	return t.option(rValidation, "base") == "checkmate"
+}
+
+// useWorkDir reports whether the runs stage their inputs in a temporary
+// work directory.
+func (t *RTranspiler) useWorkDir() bool {
+	return t.option(rWorkDir, "input") == "temp"
+}
+
+// useJSONLogging reports whether the events of the runs are logged as JSON
+// lines.
+func (t *RTranspiler) useJSONLogging() bool {
+	return t.option(rLog, "none") == "json"
+}
+
+// writeLog writes a log_event call at a level, such as "info", with JSON
+// logging. The fields are given as name = expression.
+func (t *RTranspiler) writeLog(event, message, level string, fields ...string) {
+	if !t.useJSONLogging() {
+		return
+	}
+	args := []string{rString(event), message}
+	if level != "info" {
+		args = append(args, "level = "+rString(level))
+	}
+	t.WriteLine("log_event(%s)", strings.Join(append(args, fields...), ", "))
+}
+
+// imports returns the packages the generated code depends on.
+func (t *RTranspiler) imports() []string {
+	imports := []string{}
+	if t.useCheckmate() {
+		imports = append(imports, "checkmate")
+	}
+	imports = append(imports, "digest", "jsonlite")
+	if !t.usePlainDocker() {
+		imports = append(imports, "normalizepath")
+	}
+	return imports
+}
+
 // Transpile converts a Baryon program AST to R code
 func (t *RTranspiler) Transpile(program *ast.Program) (string, error) {
-	t.Buffer.Reset()
+	return transpileToString(t, program)
+}
 
-	t.writeDockerHelpers()
+// TranspileTo implements Transpiler.
+func (t *RTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return t.transpileContext(context.Background(), w, program)
+}
+
+func (t *RTranspiler) transpileContext(ctx context.Context, w io.Writer, program *ast.Program) error {
+	c := NewRTranspiler()
+	return t.call(ctx, &c.TranspilerBase, func() error { return c.transpile(w, program) })
+}
+
+// TranspilePackage implements Packaged: the package has the DESCRIPTION
+// and NAMESPACE files, the generated code in R/<name>.R, its man page and
+// testthat tests of its signature and parameter validation.
+func (t *RTranspiler) TranspilePackage(program *ast.Program) ([]PackageFile, error) {
+	var code strings.Builder
+	c := NewRTranspiler()
+	var imports []string
+	err := t.call(context.Background(), &c.TranspilerBase, func() error {
+		imports = c.imports()
+		// Scripts are run with Rscript, packages are loaded
+		c.SetOption(rCLI, "false")
+		return c.transpile(&code, program)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	program = targetProgram("r", program)
+	name := rPackageName(program.Name)
+	return []PackageFile{
+		{Path: "DESCRIPTION", Content: rDescription(program, name, imports)},
+		{Path: "NAMESPACE", Content: rNamespace(program)},
+		{Path: "R/" + program.Name + ".R", Content: code.String()},
+		{Path: "man/" + program.Name + ".Rd", Content: rManPage(program)},
+		{Path: "tests/testthat.R", Content: fmt.Sprintf("library(testthat)\nlibrary(%s)\n\ntest_check(%s)\n", name, rString(name))},
+		{Path: "tests/testthat/test-" + program.Name + ".R", Content: rTests(program)},
+	}, nil
+}
+
+// rNamespace returns the NAMESPACE file of the package of a program, which
+// exports the function and registers the methods of its result.
+func rNamespace(program *ast.Program) string {
+	var sb strings.Builder
+	sb.WriteString("# Generated by baryon-lang\n\n")
+	fmt.Fprintf(&sb, "export(%s)\n", program.Name)
+	sb.WriteString("S3method(print, baryon_result)\n")
+	sb.WriteString("S3method(summary, baryon_result)\n")
+	sb.WriteString("S3method(print, summary.baryon_result)\n")
+	return sb.String()
+}
+
+// rPackageName returns the name of the package of a program: R package
+// names only have letters, digits and dots, and start with a letter.
+func rPackageName(program string) string {
+	var sb strings.Builder
+	for _, r := range program {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('.')
+		}
+	}
+	name := strings.Trim(sb.String(), ".")
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		name = "pkg" + name
+	}
+	return name
+}
+
+// rDescription returns the DESCRIPTION file of the package of a program,
+// with the version, author and license of its metadata and the packages the
+// code imports.
+func rDescription(program *ast.Program, name string, imports []string) string {
+	version := program.Metadata["version"]
+	if version == "" {
+		version = "0.1.0"
+	}
+	description := FormatDescription(program.Description)
+	if description == "" {
+		description = fmt.Sprintf("Runs %s in a Docker container.", program.Name)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Package: %s\n", name)
+	sb.WriteString("Type: Package\n")
+	fmt.Fprintf(&sb, "Title: %s\n", program.Name)
+	fmt.Fprintf(&sb, "Version: %s\n", version)
+	if author := program.Metadata["author"]; author != "" {
+		fmt.Fprintf(&sb, "Author: %s\n", author)
+		maintainer := program.Metadata["maintainer"]
+		if maintainer == "" {
+			maintainer = author
+		}
+		fmt.Fprintf(&sb, "Maintainer: %s\n", maintainer)
+	}
+	fmt.Fprintf(&sb, "Description: %s\n", description)
+	if license := program.Metadata["license"]; license != "" {
+		fmt.Fprintf(&sb, "License: %s\n", license)
+	}
+	sb.WriteString("Encoding: UTF-8\n")
+	sb.WriteString("Imports:\n    " + strings.Join(imports, ",\n    ") + "\n")
+	sb.WriteString("Suggests:\n    testthat (>= 3.0.0)\n")
+	sb.WriteString("Config/testthat/edition: 3\n")
+	return sb.String()
+}
+
+// rdEscape escapes the special characters of Rd text.
+var rdEscape = strings.NewReplacer(`\`, `\\`, "%", `\%`, "{", `\{`, "}", `\}`)
+
+// rManPage returns the Rd page of the function of a program, with the
+// content of its roxygen documentation.
+func rManPage(program *ast.Program) string {
+	var sb strings.Builder
+	sb.WriteString("% Generated by baryon-lang: do not edit by hand\n")
+	fmt.Fprintf(&sb, "\\name{%s}\n", program.Name)
+	fmt.Fprintf(&sb, "\\alias{%s}\n", program.Name)
+	fmt.Fprintf(&sb, "\\title{%s}\n", rdEscape.Replace(program.Name))
+	fmt.Fprintf(&sb, "\\usage{\n%s(%s)\n}\n", program.Name, rdEscape.Replace(strings.Join(rFormals(program), ", ")))
+	sb.WriteString("\\arguments{\n")
+	for _, param := range program.Parameters {
+		fmt.Fprintf(&sb, "\\item{%s}{%s}\n", param.Name, rdEscape.Replace(rParameterDescription(param)))
+	}
+	fmt.Fprintf(&sb, "\\item{output_dir}{%s}\n", rdEscape.Replace(rOutputDirDescription(program)))
+	fmt.Fprintf(&sb, "\\item{dry_run}{%s}\n", rDryRunDescription)
+	sb.WriteString("}\n")
+	fmt.Fprintf(&sb, "\\value{\n%s\n}\n", rdEscape.Replace(rReturnDescription(program)))
+	description := FormatDescription(program.Description)
+	if description == "" {
+		description = program.Name
+	}
+	fmt.Fprintf(&sb, "\\description{\n%s\n}\n", rdEscape.Replace(description))
+	return sb.String()
+}
+
+// rTestValues are values of each type passing the type validation, and
+// values failing it.
+var rTestValues = map[string][2]string{
+	TypeString:    {`"x"`, "1"},
+	TypeFile:      {`"x"`, "1"},
+	TypeDirectory: {`"x"`, "1"},
+	TypeCharacter: {`"a"`, "1"},
+	TypeInteger:   {"1L", `"x"`},
+	TypeNumber:    {"1", `"x"`},
+	TypeBoolean:   {"TRUE", `"x"`},
+	TypeEnum:      {"", "1"},
+}
+
+// rTests returns the testthat tests of the function of a program: its
+// arguments, and the rejection of a value of the wrong type for each
+// parameter.
+func rTests(program *ast.Program) string {
+	names := make([]string, 0, len(program.Parameters)+2)
+	for _, param := range program.Parameters {
+		names = append(names, rString(param.Name))
+	}
+	names = append(names, rString("output_dir"), rString("dry_run"))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "test_that(\"%s has the declared parameters\", {\n", program.Name)
+	fmt.Fprintf(&sb, "  expect_identical(names(formals(%s)), c(%s))\n", program.Name, strings.Join(names, ", "))
+	sb.WriteString("})\n")
+
+	valid := func(param ast.Parameter) string {
+		if param.Type == TypeEnum && len(param.Constraints) > 0 {
+			return rString(fmt.Sprint(param.Constraints[0]))
+		}
+		return rTestValues[param.Type][0]
+	}
+	for _, param := range program.Parameters {
+		args := []string{}
+		for _, other := range program.Parameters {
+			switch {
+			case other.Name == param.Name:
+				args = append(args, fmt.Sprintf("%s = %s", other.Name, rTestValues[other.Type][1]))
+			case other.Default == nil:
+				args = append(args, fmt.Sprintf("%s = %s", other.Name, valid(other)))
+			}
+		}
+		fmt.Fprintf(&sb, "\ntest_that(\"%s validates %s\", {\n", program.Name, param.Name)
+		fmt.Fprintf(&sb, "  expect_error(%s(%s), %s)\n", program.Name, strings.Join(args, ", "), rString(param.Name))
+		sb.WriteString("})\n")
+	}
+	return sb.String()
+}
+
+// transpile writes the code of a program, on the transpiler of a call.
+func (t *RTranspiler) transpile(w io.Writer, program *ast.Program) error {
+	program = targetProgram("r", program)
+	t.SetOutput(w)
+	defer t.SetOutput(nil)
+
+	t.writeSection(SectionHeader, SectionData{Target: "r", Program: program}, t.writeDockerHelpers)
 
 	t.writeDocumentation(program)
 
@@ -57,20 +391,148 @@ func (t *RTranspiler) Transpile(program *ast.Program) (string, error) {
 
 	err := t.writeTypeValidation(program.Parameters)
 	if err != nil {
-		return "", fmt.Errorf("error generating type validation: %w", err)
+		return fmt.Errorf("error generating type validation: %w", err)
 	}
 
 	t.writeSecurityChecks(program.Parameters)
 
 	err = t.processImplementations(program)
 	if err != nil {
-		return "", fmt.Errorf("error processing implementations: %w", err)
+		return fmt.Errorf("error processing implementations: %w", err)
 	}
 
 	t.SetIndentLevel(0)
 	t.WriteLine("}")
 
-	return t.Buffer.String(), nil
+	if t.useCLI() {
+		t.writeEntryPoint(program)
+	}
+
+	return t.OutputError()
+}
+
+// rOptionTypes maps parameter types to the types of optparse options.
+var rOptionTypes = map[string]string{
+	TypeString:    "character",
+	TypeFile:      "character",
+	TypeDirectory: "character",
+	TypeCharacter: "character",
+	TypeEnum:      "character",
+	TypeInteger:   "integer",
+	TypeNumber:    "double",
+	TypeBoolean:   "logical",
+}
+
+// rEnvConverters maps parameter types to the functions converting their
+// environment overrides; the others are kept as strings.
+var rEnvConverters = map[string]string{
+	TypeInteger: "as.integer",
+	TypeNumber:  "as.numeric",
+	TypeBoolean: "as.logical",
+}
+
+// rEnvDefault returns the default of the option of a parameter, read from
+// its environment variable when it is set.
+func rEnvDefault(program *ast.Program, param ast.Parameter, def string) string {
+	args := []string{rString(naming.EnvVariable(program.Name, param.Name)), def}
+	if convert, ok := rEnvConverters[param.Type]; ok {
+		args = append(args, convert)
+	}
+	return fmt.Sprintf("env_default(%s)", strings.Join(args, ", "))
+}
+
+// writeEntryPoint adds a block running the function when the script is run
+// with Rscript, with an optparse option per parameter, like the __main__
+// block of the Python target.
+func (t *RTranspiler) writeEntryPoint(program *ast.Program) {
+	t.WriteLine("")
+	t.WriteLine("if (sys.nframe() == 0) {")
+	t.SetIndentLevel(1)
+	// The environment variables override the defaults of the options
+	t.WriteLine("env_default <- function(name, default, convert = identity) {")
+	t.WriteLine("  value <- Sys.getenv(name, unset = NA)")
+	t.WriteLine("  if (is.na(value)) {")
+	t.WriteLine("    return(default)")
+	t.WriteLine("  }")
+	t.WriteLine("  converted <- suppressWarnings(convert(value))")
+	t.WriteLine("  if (is.na(converted)) {")
+	t.WriteLine("    stop(paste0(\"invalid value '\", value, \"' of \", name))")
+	t.WriteLine("  }")
+	t.WriteLine("  return(converted)")
+	t.WriteLine("}")
+	t.WriteLine("")
+	t.WriteLine("option_list <- list(")
+	t.SetIndentLevel(2)
+	required := []string{}
+	for _, param := range program.Parameters {
+		help := param.Description
+		if help == "" {
+			help = fmt.Sprintf("Parameter of type '%s'", param.Type)
+		}
+		help += fmt.Sprintf(" (env %s)", naming.EnvVariable(program.Name, param.Name))
+		flag := rString("--" + param.Name)
+		if param.Type == TypeBoolean {
+			def := "FALSE"
+			if param.Default != nil {
+				def = FormatLiteral(param.Default, rLiteralSyntax)
+			}
+			t.WriteLine("optparse::make_option(%s, action = \"store_true\", default = %s, help = %s),",
+				flag, rEnvDefault(program, param, def), rString(help))
+			t.WriteLine("optparse::make_option(%s, action = \"store_false\", dest = %s),",
+				rString("--no-"+param.Name), rString(param.Name))
+			continue
+		}
+		option := fmt.Sprintf("optparse::make_option(%s, type = %s", flag, rString(rOptionTypes[param.Type]))
+		if param.Default != nil {
+			option += ", default = " + rEnvDefault(program, param, FormatLiteral(param.Default, rLiteralSyntax))
+		} else {
+			option += ", default = " + rEnvDefault(program, param, "NULL")
+			if !IsOmittable(param) {
+				required = append(required, rString(param.Name))
+			}
+		}
+		t.WriteLine("%s, help = %s),", option, rString(help))
+	}
+	t.WriteLine("optparse::make_option(\"--output-dir\", type = \"character\", default = %s, dest = \"output_dir\",",
+		fmt.Sprintf("env_default(%s, NULL)", rString(naming.EnvVariable(program.Name, "output_dir"))))
+	t.WriteLine("  help = %s),", rString("Results directory (env "+naming.EnvVariable(program.Name, "output_dir")+")"))
+	t.WriteLine("optparse::make_option(\"--dry-run\", action = \"store_true\", default = FALSE, dest = \"dry_run\",")
+	t.WriteLine("  help = \"Print the docker command instead of running it\")")
+	t.SetIndentLevel(1)
+	t.WriteLine(")")
+	t.WriteLine("parser <- optparse::OptionParser(description = %s, option_list = option_list)",
+		rString(FormatDescription(program.Description)))
+	t.WriteLine("args <- optparse::parse_args(parser)")
+	if len(required) > 0 {
+		t.WriteLine("")
+		t.WriteLine("required <- c(%s)", strings.Join(required, ", "))
+		t.WriteLine("missing_options <- required[vapply(required, function(name) is.null(args[[name]]), logical(1))]")
+		t.WriteLine("if (length(missing_options) > 0) {")
+		t.WriteLine("  optparse::print_help(parser)")
+		t.WriteLine("  stop(paste(\"missing required options:\", paste0(\"--\", missing_options, collapse = \", \")))")
+		t.WriteLine("}")
+	}
+	t.WriteLine("")
+	t.WriteLine("result <- %s(", program.Name)
+	t.SetIndentLevel(2)
+	for _, param := range program.Parameters {
+		t.WriteLine("%s = args[[%s]],", param.Name, rString(param.Name))
+	}
+	t.WriteLine("output_dir = args$output_dir,")
+	t.WriteLine("dry_run = args$dry_run")
+	t.SetIndentLevel(1)
+	t.WriteLine(")")
+	t.WriteLine("if (result$status == \"error\") {")
+	t.WriteLine("  cat(\"Status:\", result$status, \"\\n\")")
+	t.WriteLine("  cat(\"Error:\", result$message, \"\\n\")")
+	t.WriteLine("  quit(status = 1)")
+	t.WriteLine("}")
+	t.WriteLine("if (result$status != \"dry_run\") {")
+	t.WriteLine("  cat(\"Status:\", result$status, \"\\n\")")
+	t.WriteLine("  cat(\"Output directory:\", result$output_dir, \"\\n\")")
+	t.WriteLine("}")
+	t.SetIndentLevel(0)
+	t.WriteLine("}")
 }
 
 // writeDocumentation generates Roxygen-style documentation for the R function
@@ -78,68 +540,91 @@ func (t *RTranspiler) writeDocumentation(program *ast.Program) {
 	t.WriteLine("#' %s", program.Name)
 	t.WriteLine("#'")
 	if program.Description != "" {
-		t.WriteLine("#' @description %s", FormatDescription(program.Description))
+		t.WriteLine("#' @description %s", rDoc(program.Description))
 	}
 
 	// Parameter documentation
 	for _, param := range program.Parameters {
-		desc := param.Description
-		if desc == "" {
-			desc = fmt.Sprintf("Parameter of type '%s'", param.Type)
-		}
+		t.WriteLine("#' @param %s %s", param.Name, rDoc(rParameterDescription(param)))
+	}
+	t.WriteLine("#' @param output_dir %s", rDoc(rOutputDirDescription(program)))
+	t.WriteLine("#' @param dry_run %s", rDryRunDescription)
 
-		// For enum types, include allowed values
-		if param.Type == "enum" && len(param.Constraints) > 0 {
-			values := make([]string, len(param.Constraints))
-			for i, v := range param.Constraints {
-				values[i] = fmt.Sprintf("%v", v)
-			}
-			desc += fmt.Sprintf(" (allowed values: %s)", strings.Join(values, ", "))
+	t.WriteLine("#' @return %s", rDoc(rReturnDescription(program)))
+	t.WriteLine("#'")
+	t.WriteLine("#' @export")
+}
+
+// rDryRunDescription documents the dry_run argument of the functions.
+const rDryRunDescription = "Print the docker command instead of running it."
+
+// rOutputDirDescription documents the output_dir argument of a function.
+func rOutputDirDescription(program *ast.Program) string {
+	return fmt.Sprintf("Results directory, %s_results next to the first input by default.", program.Name)
+}
+
+// rParameterDescription returns the documentation of a parameter, with the
+// allowed values of enums and the default of hidden and advanced ones.
+func rParameterDescription(param ast.Parameter) string {
+	desc := param.Description
+	if desc == "" {
+		desc = fmt.Sprintf("Parameter of type '%s'", param.Type)
+	}
+
+	// For enum types, include allowed values
+	if param.Type == "enum" && len(param.Constraints) > 0 {
+		values := make([]string, len(param.Constraints))
+		for i, v := range param.Constraints {
+			values[i] = fmt.Sprintf("%v", v)
 		}
+		desc += fmt.Sprintf(" (allowed values: %s)", strings.Join(values, ", "))
+	}
 
-		t.WriteLine("#' @param %s %s", param.Name, FormatDescription(desc))
+	// Hidden and advanced arguments are documented, with the default that
+	// most calls keep
+	kind := ""
+	switch {
+	case param.Metadata["hidden"] == "true":
+		kind = "internal"
+	case param.Metadata["advanced"] == "true":
+		kind = "advanced"
 	}
+	if kind != "" && param.Default != nil {
+		desc += fmt.Sprintf(" (%s, default: %s)", kind, FormatLiteral(param.Default, rLiteralSyntax))
+	} else if kind != "" {
+		desc += fmt.Sprintf(" (%s)", kind)
+	}
+	return FormatDescription(desc)
+}
 
-	// Get return value from metadata if available
+// rReturnDescription returns the documentation of the value of the
+// function, from the return metadata if available.
+func rReturnDescription(program *ast.Program) string {
 	returnDesc := "Results of the operation"
 	if desc, ok := program.Metadata["return"]; ok {
 		returnDesc = desc
 	}
-	t.WriteLine("#' @return %s", FormatDescription(returnDesc))
-	t.WriteLine("#'")
-	t.WriteLine("#' @export")
+	return FormatDescription(returnDesc)
 }
 
-// writeSignature generates the function signature
-func (t *RTranspiler) writeSignature(program *ast.Program) {
-	// Create parameter list with default values where available
+// rFormals returns the arguments of the function, with their defaults.
+func rFormals(program *ast.Program) []string {
 	params := make([]string, len(program.Parameters))
 	for i, param := range program.Parameters {
 		paramDef := param.Name
 		if param.Default != nil {
-			// Format default value based on type
-			switch param.Type {
-			case "string", "character":
-				paramDef += fmt.Sprintf(" = \"%v\"", param.Default)
-			case "boolean":
-				boolVal, ok := param.Default.(bool)
-				if ok {
-					if boolVal {
-						paramDef += " = TRUE"
-					} else {
-						paramDef += " = FALSE"
-					}
-				} else {
-					paramDef += fmt.Sprintf(" = %v", param.Default)
-				}
-			default:
-				paramDef += fmt.Sprintf(" = %v", param.Default)
-			}
+			paramDef += " = " + FormatLiteral(param.Default, rLiteralSyntax)
+		} else if IsOmittable(param) {
+			paramDef += " = NULL"
 		}
 		params[i] = paramDef
 	}
+	return append(params, "output_dir = NULL", "dry_run = FALSE")
+}
 
-	t.WriteLine("%s <- function(%s) {", program.Name, strings.Join(params, ",\n"))
+// writeSignature generates the function signature
+func (t *RTranspiler) writeSignature(program *ast.Program) {
+	t.WriteLine("%s <- function(%s) {", program.Name, strings.Join(rFormals(program), ",\n"))
 	t.SetIndentLevel(t.GetIndentLevel() + 1)
 }
 
@@ -152,26 +637,100 @@ func (t *RTranspiler) writeTypeValidation(params []ast.Parameter) error {
 	t.WriteLine("# Type validation")
 
 	for _, param := range params {
-		// Skip validation for parameters with default values
-		if param.Default != nil {
-			continue
+		if err := t.canceled(); err != nil {
+			return err
 		}
-
 		validator, ok := t.GetTypeValidators()[param.Type]
+		if t.useCheckmate() {
+			validator, ok = t.checkmateValidator(param.Type)
+		}
 		if !ok {
 			// Default validation for unknown types
 			t.WriteLine("# No specific validation for type '%s'", param.Type)
 			continue
 		}
 
+		done := t.markSource(param.Pos, "parameter "+param.Name)
+		// Explicit values are validated like the others, only parameters
+		// without a default must be given, and the optional ones are only
+		// validated when given
+		if IsOmittable(param) {
+			t.WriteLine("if (!is.null(%s)) {", param.Name)
+			t.SetIndentLevel(t.GetIndentLevel() + 1)
+		} else if param.Default == nil {
+			t.WriteLine("if (missing(%s)) {", param.Name)
+			t.WriteLine("  stop(\"%s is required\")", param.Name)
+			t.WriteLine("}")
+		}
 		if err := validator(t, param); err != nil {
 			return fmt.Errorf("error validating parameter '%s': %w", param.Name, err)
 		}
+		t.writeRangeCheck(param)
+		if IsOmittable(param) {
+			t.SetIndentLevel(t.GetIndentLevel() - 1)
+			t.WriteLine("}")
+		}
+		done()
 	}
 
 	return nil
 }
 
+// writeRangeCheck generates the checks of the min and max of a numeric
+// parameter, which are inclusive.
+func (t *RTranspiler) writeRangeCheck(param ast.Parameter) {
+	if param.Type != TypeInteger && param.Type != TypeNumber {
+		return
+	}
+	for _, bound := range []struct{ key, operator, text string }{
+		{"min", "<", "at least"},
+		{"max", ">", "at most"},
+	} {
+		if value, ok := param.Metadata[bound.key]; ok {
+			t.WriteLine("if (%s %s %s) {", param.Name, bound.operator, value)
+			t.WriteLine("  stop(paste(\"%s must be %s %s, got\", %s))", param.Name, bound.text, value, param.Name)
+			t.WriteLine("}")
+		}
+	}
+}
+
+// checkmateAssertions are the checkmate assertions of the parameter types.
+var checkmateAssertions = map[string]string{
+	TypeString:    "checkmate::assert_string(%s)",
+	TypeFile:      "checkmate::assert_string(%s)",
+	TypeDirectory: "checkmate::assert_string(%s)",
+	TypeCharacter: "checkmate::assert_string(%s, n.chars = 1)",
+	TypeNumber:    "checkmate::assert_number(%s)",
+	TypeInteger:   "checkmate::assert_int(%s)",
+	TypeBoolean:   "checkmate::assert_flag(%s)",
+}
+
+// checkmateValidator returns the validator of a type asserting it with
+// checkmate, with the allowed values of enums.
+func (t *RTranspiler) checkmateValidator(paramType string) (TypeValidator, bool) {
+	if paramType == TypeEnum {
+		return func(base BaseTranspiler, param ast.Parameter) error {
+			if len(param.Constraints) == 0 {
+				return fmt.Errorf("enum type requires constraints with allowed values")
+			}
+			values := make([]string, len(param.Constraints))
+			for i, c := range param.Constraints {
+				values[i] = rString(fmt.Sprint(c))
+			}
+			base.WriteLine("checkmate::assert_choice(%s, c(%s))", param.Name, strings.Join(values, ", "))
+			return nil
+		}, true
+	}
+	assertion, ok := checkmateAssertions[paramType]
+	if !ok {
+		return nil, false
+	}
+	return func(base BaseTranspiler, param ast.Parameter) error {
+		base.WriteLine(assertion, param.Name)
+		return nil
+	}, true
+}
+
 // writeSecurityChecks generates security validation code
 func (t *RTranspiler) writeSecurityChecks(params []ast.Parameter) {
 	// Check for path traversal in file parameters
@@ -184,7 +743,7 @@ func (t *RTranspiler) writeSecurityChecks(params []ast.Parameter) {
 				fileParams = true
 			}
 
-			t.WriteLine("if (grepl(\"\\\\.\\\\./|\\\\.\\\\\\\\|\\\\/\\\\.\\\\./|\\\\\\\\\\\\.\\\\\\\\\\\\.\\\\\\\\\", %s)) {", param.Name)
+			t.WriteLine("if (%sgrepl(\"\\\\.\\\\./|\\\\.\\\\\\\\|\\\\/\\\\.\\\\./|\\\\\\\\\\\\.\\\\\\\\\\\\.\\\\\\\\\", %s)) {", rGiven(param), param.Name)
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
 			t.WriteLine("stop(\"Path traversal detected in %s\")", param.Name)
 			t.SetIndentLevel(t.GetIndentLevel() - 1)
@@ -197,31 +756,54 @@ func (t *RTranspiler) writeSecurityChecks(params []ast.Parameter) {
 		if param.Type == "file" {
 			t.WriteLine("")
 			t.WriteLine("# Check if file exists")
-			t.WriteLine("if (!is_running_in_docker()) {")
+			t.WriteLine("if (%s!is_running_in_docker()) {", rGiven(param))
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
-			t.WriteLine("if (!file.exists(%s)) {", param.Name)
-			t.SetIndentLevel(t.GetIndentLevel() + 1)
-			t.WriteLine("stop(paste(\"%s:\", %s, \"does not exist\"))", param.Name, param.Name)
-			t.SetIndentLevel(t.GetIndentLevel() - 1)
-			t.WriteLine("}")
+			if t.useCheckmate() {
+				t.WriteLine("checkmate::assert_file_exists(%s)", param.Name)
+			} else {
+				t.WriteLine("if (!file.exists(%s)) {", param.Name)
+				t.SetIndentLevel(t.GetIndentLevel() + 1)
+				t.WriteLine("stop(paste(\"%s:\", %s, \"does not exist\"))", param.Name, param.Name)
+				t.SetIndentLevel(t.GetIndentLevel() - 1)
+				t.WriteLine("}")
+			}
 			t.SetIndentLevel(t.GetIndentLevel() - 1)
 			t.WriteLine("}")
+			if algorithm, digest, ok := InputChecksum(param); ok {
+				// In a container, the path may only exist on the host
+				t.WriteLine("if (%sfile.exists(%s)) {", rGiven(param), param.Name)
+				t.WriteLine("  verify_checksum(%s, %s, %s)", param.Name, rString(algorithm), rString(digest))
+				t.WriteLine("}")
+			}
 		} else if param.Type == "directory" {
 			t.WriteLine("")
 			t.WriteLine("# Check if directory exists")
-			t.WriteLine("if (!is_running_in_docker()) {")
-			t.SetIndentLevel(t.GetIndentLevel() + 1)
-			t.WriteLine("if (!dir.exists(%s)) {", param.Name)
+			t.WriteLine("if (%s!is_running_in_docker()) {", rGiven(param))
 			t.SetIndentLevel(t.GetIndentLevel() + 1)
-			t.WriteLine("stop(paste(\"%s:\", %s, \"does not exist\"))", param.Name, param.Name)
-			t.SetIndentLevel(t.GetIndentLevel() - 1)
-			t.WriteLine("}")
+			if t.useCheckmate() {
+				t.WriteLine("checkmate::assert_directory_exists(%s)", param.Name)
+			} else {
+				t.WriteLine("if (!dir.exists(%s)) {", param.Name)
+				t.SetIndentLevel(t.GetIndentLevel() + 1)
+				t.WriteLine("stop(paste(\"%s:\", %s, \"does not exist\"))", param.Name, param.Name)
+				t.SetIndentLevel(t.GetIndentLevel() - 1)
+				t.WriteLine("}")
+			}
 			t.SetIndentLevel(t.GetIndentLevel() - 1)
 			t.WriteLine("}")
 		}
 	}
 }
 
+// rGiven returns the condition a check of an optional parameter left off
+// without a value starts with, "x is given and ".
+func rGiven(param ast.Parameter) string {
+	if IsOmittable(param) {
+		return fmt.Sprintf("!is.null(%s) && ", param.Name)
+	}
+	return ""
+}
+
 // processImplementations handles all implementation blocks
 func (t *RTranspiler) processImplementations(program *ast.Program) error {
 	if len(program.Implementations) == 0 {
@@ -233,14 +815,19 @@ func (t *RTranspiler) processImplementations(program *ast.Program) error {
 
 	// Process each implementation block
 	for _, impl := range program.Implementations {
+		if err := t.canceled(); err != nil {
+			return err
+		}
 		handler, ok := t.GetImplementationHandlers()[impl.Name]
 		if !ok {
-			return fmt.Errorf("no handler registered for implementation type '%s'", impl.Name)
+			return unsupportedImplementation("r", &impl, "no handler registered for implementation type '%s'")
 		}
 
+		done := t.sourceSpan(impl.Pos, "implementation "+impl.Name)
 		if err := handler(t, &impl, program); err != nil {
 			return fmt.Errorf("error processing '%s' implementation: %w", impl.Name, err)
 		}
+		done()
 	}
 
 	return nil
@@ -308,7 +895,7 @@ func (t *RTranspiler) validateEnumType(base BaseTranspiler, param ast.Parameter)
 	// Format constraint values
 	constraints := make([]string, len(param.Constraints))
 	for i, c := range param.Constraints {
-		constraints[i] = fmt.Sprintf("\"%v\"", c)
+		constraints[i] = rString(fmt.Sprint(c))
 	}
 
 	// Generate validation code
@@ -343,6 +930,12 @@ func (t *RTranspiler) handleDockerImplementation(base BaseTranspiler, impl *ast.
 		return fmt.Errorf("Docker image not specified or invalid")
 	}
 
+	if t.useJSONLogging() {
+		base.WriteLine("")
+		t.writeLog("start", rString("Running "+program.Name), "info",
+			"tool = "+rString(program.Name), "image = "+rString(image))
+	}
+
 	base.WriteLine("")
 	base.WriteLine("# Process file paths for Docker volume mounting")
 
@@ -353,20 +946,50 @@ func (t *RTranspiler) handleDockerImplementation(base BaseTranspiler, impl *ast.
 		// Setup for file parameters
 		for _, param := range fileParams {
 			base.WriteLine("# Process %s for Docker", param)
-			base.WriteLine("%s_abspath <- normalizePath(%s, mustWork = FALSE)", param, param)
-			base.WriteLine("%s_dir <- dirname(%s_abspath)", param, param)
-			base.WriteLine("%s_filename <- basename(%s)", param, param)
+			if omittableParam(param, program.Parameters) {
+				base.WriteLine("%s_filename <- NULL", param)
+			}
+			rWhenGiven(base, param, program.Parameters, func() {
+				base.WriteLine("%s_abspath <- normalizePath(%s, mustWork = FALSE)", param, param)
+				base.WriteLine("%s_dir <- dirname(%s_abspath)", param, param)
+				base.WriteLine("%s_filename <- basename(%s)", param, param)
+			})
 		}
 
-		// Use first file parameter's directory as main mount point
+		// Use the directory of the first file parameter always given as
+		// main mount point
+		fileParams = mainFirst(fileParams, program.Parameters)
 		base.WriteLine("")
 		base.WriteLine("# Main volume mount point")
-		base.WriteLine("main_mount_dir <- %s_dir", fileParams[0])
+		if main := fileParams[0]; omittableParam(main, program.Parameters) {
+			base.WriteLine("main_mount_dir <- if (!is.null(%s)) %s_dir else normalizePath(getwd(), mustWork = FALSE)", main, main)
+		} else {
+			base.WriteLine("main_mount_dir <- %s_dir", main)
+		}
 	} else {
 		// Fallback to current directory
 		base.WriteLine("# No file parameters found, using current directory")
 		base.WriteLine("main_mount_dir <- normalizePath(getwd(), mustWork = FALSE)")
 	}
+	base.WriteLine("")
+	base.WriteLine("# Results directory, mounted where the tool writes its results")
+	base.WriteLine("if (is.null(output_dir)) {")
+	base.WriteLine("  output_dir <- file.path(main_mount_dir, %s)", rString(program.Name+"_results"))
+	base.WriteLine("}")
+	base.WriteLine("output_dir <- normalizePath(output_dir, mustWork = FALSE)")
+	base.WriteLine("log_files <- c(")
+	base.WriteLine("  stdout = file.path(output_dir, \"logs\", \"stdout.log\"),")
+	base.WriteLine("  stderr = file.path(output_dir, \"logs\", \"stderr.log\")")
+	base.WriteLine(")")
+	if t.useWorkDir() {
+		// The results go where they would with the inputs mounted
+		base.WriteLine("")
+		base.WriteLine("# Work directory of the run, removed after it")
+		base.WriteLine("results_parent <- main_mount_dir")
+		base.WriteLine("work_dir <- tempfile(\"baryon-\")")
+		base.WriteLine("dir.create(work_dir)")
+		base.WriteLine("on.exit(unlink(work_dir, recursive = TRUE), add = TRUE)")
+	}
 
 	// Setup execution block with error handling
 	base.WriteLine("")
@@ -374,18 +997,101 @@ func (t *RTranspiler) handleDockerImplementation(base BaseTranspiler, impl *ast.
 	base.WriteLine("tryCatch({")
 	base.SetIndentLevel(base.GetIndentLevel() + 1)
 
-	// Generate Docker run command
-	base.WriteLine("result <- run_in_docker(")
+	if t.useWorkDir() {
+		base.WriteLine("# Stage the inputs in the work directory, next to the mount point of the results")
+		base.WriteLine("staged <- %s", rString(program.Name+"_results"))
+		if len(fileParams) > 0 {
+			values := make([]string, len(fileParams))
+			for i, param := range fileParams {
+				values[i] = fmt.Sprintf("stage_input(%s_abspath, work_dir)", param)
+				if omittableParam(param, program.Parameters) {
+					values[i] = fmt.Sprintf("if (!is.null(%s)) %s", param, values[i])
+				}
+			}
+			base.WriteLine("if (!dry_run) {")
+			base.SetIndentLevel(base.GetIndentLevel() + 1)
+			writeRVector(base, "staged <- c(staged,", values, ")")
+			base.SetIndentLevel(base.GetIndentLevel() - 1)
+			base.WriteLine("}")
+			for _, param := range fileParams {
+				base.WriteLine("%s_dir <- work_dir", param)
+			}
+		}
+		base.WriteLine("main_mount_dir <- work_dir")
+		base.WriteLine("")
+	}
+
+	// The start of the run, recorded in the manifest
+	base.WriteLine("if (!dry_run) {")
+	base.WriteLine("  dir.create(output_dir, showWarnings = FALSE, recursive = TRUE)")
+	base.WriteLine("}")
+	base.WriteLine("started <- Sys.time()")
+
+	data := SectionData{Target: "r", Program: program, Implementation: impl, Image: image}
+	t.writeSection(SectionDocker, data, func() { t.writeDockerRun(base, impl, program, image, fileParams) })
+	base.WriteLine("if (dry_run) {")
+	base.WriteLine("  return(baryon_result(status = \"dry_run\", message = result))")
+	base.WriteLine("}")
+	base.WriteLine("if (result != 0) {")
+	base.WriteLine("  stop(paste(\"the container exited with status\", result))")
+	base.WriteLine("}")
+	if t.useInstrumentation() {
+		base.WriteLine("resources <- list(")
+		base.WriteLine("  wall_seconds = round(as.numeric(difftime(Sys.time(), started, units = \"secs\")), 3)")
+		base.WriteLine(")")
+	}
+
+	t.writeSection(SectionResult, data, func() { t.writeResult(base, program, image) })
+
+	// Error handling
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	base.WriteLine("}, error = function(e) {")
 	base.SetIndentLevel(base.GetIndentLevel() + 1)
-	base.WriteLine("image_name = \"%s\",", image)
+	t.writeLog("error", "paste(\"Docker execution failed:\", e$message)", "error")
+	base.WriteLine("return(baryon_result(")
+	base.WriteLine("  status = \"error\",")
+	base.WriteLine("  message = paste(\"Docker execution failed:\", e$message),")
+	base.WriteLine("  logs = as.list(log_files[file.exists(log_files)])")
+	base.WriteLine("))")
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	base.WriteLine("})")
 
-	// Handle volumes
+	return nil
+}
+
+// writeDockerRun generates the run_in_docker call of an implementation.
+func (t *RTranspiler) writeDockerRun(base BaseTranspiler, impl *ast.ImplementationBlock, program *ast.Program, image string, fileParams []string) {
+	// Mount the directories of the other inputs next to the main one
 	volumes, ok := impl.Fields["volumes"].([]any)
-	if ok && len(volumes) > 0 {
-		base.WriteLine("volumes = list(")
-		base.SetIndentLevel(base.GetIndentLevel() + 1)
+	mountInputs := !(ok && len(volumes) > 0) && len(fileParams) > 1 && !t.useWorkDir()
+	if mountInputs {
+		writeRVector(base, "volumes <- list(", []string{
+			"c(main_mount_dir, \"/data\")",
+			fmt.Sprintf("c(output_dir, %s)", rString(resultsMount(program))),
+		}, ")")
+		for _, param := range fileParams[1:] {
+			rWhenGiven(base, param, program.Parameters, func() {
+				base.WriteLine("volumes <- mount_input(volumes, %s_dir)", param)
+				base.WriteLine("%s_filename <- input_path(volumes, %s_dir, %s_filename)", param, param, param)
+			})
+		}
+	}
 
-		for index, vol := range volumes {
+	// Generate Docker run command
+	base.WriteLine("result <- run_in_docker(")
+	base.SetIndentLevel(base.GetIndentLevel() + 1)
+	base.WriteLine("image_name = %s,", rString(image))
+	base.WriteLine("dry_run = dry_run,")
+	base.WriteLine("log_files = log_files,")
+
+	// Handle volumes, the ones of the optional parameters left off are
+	// filtered out
+	mounts := []string{}
+	omitted := false
+	if mountInputs {
+		base.WriteLine("volumes = volumes,")
+	} else if ok && len(volumes) > 0 {
+		for _, vol := range volumes {
 			switch v := vol.(type) {
 			case []any:
 				if len(v) >= 2 {
@@ -393,41 +1099,37 @@ func (t *RTranspiler) handleDockerImplementation(base BaseTranspiler, impl *ast.
 					src := fmt.Sprintf("%v", v[0])
 					dst := fmt.Sprintf("%v", v[1])
 
-					isIndexLast := index == len(volumes)-1
-					comma := ""
-					if !isIndexLast {
-						comma = ","
-					}
-
 					// Check if src is a parameter reference
-					if IsParamReference(src, program.Parameters) {
-						base.WriteLine("c(%s_dir, \"%s\")%s", src, dst, comma)
+					if IsParamReference(src, program.Parameters) && omittableParam(src, program.Parameters) {
+						mounts = append(mounts, fmt.Sprintf("if (!is.null(%s)) c(%s_dir, %s)", src, src, rString(dst)))
+						omitted = true
+					} else if IsParamReference(src, program.Parameters) {
+						mounts = append(mounts, fmt.Sprintf("c(%s_dir, %s)", src, rString(dst)))
 					} else if src == "parent-folder" || src == "parent_folder" {
-						base.WriteLine("c(main_mount_dir, \"%s\")%s", dst, comma)
+						mounts = append(mounts, fmt.Sprintf("c(main_mount_dir, %s)", rString(dst)))
 					} else {
-						base.WriteLine("c(\"%s\", \"%s\")%s", src, dst, comma)
+						mounts = append(mounts, fmt.Sprintf("c(%s, %s)", rString(src), rString(dst)))
 					}
 				}
 			}
 		}
-
-		base.SetIndentLevel(base.GetIndentLevel() - 1)
-		base.WriteLine("),")
 	} else {
 		// Default volume mapping if none specified
-		base.WriteLine("volumes = list(")
-		base.SetIndentLevel(base.GetIndentLevel() + 1)
-		base.WriteLine("c(main_mount_dir, \"/data\")")
-		base.SetIndentLevel(base.GetIndentLevel() - 1)
-		base.WriteLine("),")
+		mounts = append(mounts, "c(main_mount_dir, \"/data\")")
+	}
+	if !mountInputs {
+		mounts = append(mounts, fmt.Sprintf("c(output_dir, %s)", rString(resultsMount(program))))
+		if omitted {
+			writeRVector(base, "volumes = Filter(Negate(is.null), list(", mounts, ")),")
+		} else {
+			writeRVector(base, "volumes = list(", mounts, "),")
+		}
 	}
 
 	// Handle environment variables
 	env, ok := impl.Fields["env"].([]any)
 	if ok && len(env) > 0 {
-		base.WriteLine("env = c(")
-		base.SetIndentLevel(base.GetIndentLevel() + 1)
-
+		values := []string{}
 		for _, e := range env {
 			switch ev := e.(type) {
 			case []any:
@@ -437,29 +1139,27 @@ func (t *RTranspiler) handleDockerImplementation(base BaseTranspiler, impl *ast.
 
 					// Check if val is a parameter reference
 					if IsParamReference(val, program.Parameters) {
-						base.WriteLine("\"%s\" = %s,", key, val)
+						values = append(values, fmt.Sprintf("%s = as.character(%s)", rString(key), val))
 					} else {
-						base.WriteLine("\"%s\" = \"%s\",", key, val)
+						values = append(values, fmt.Sprintf("%s = %s", rString(key), rString(val)))
 					}
 				}
 			}
 		}
-
-		base.SetIndentLevel(base.GetIndentLevel() - 1)
-		base.WriteLine("),")
+		writeRVector(base, "env = c(", values, "),")
 	}
 
 	// Handle arguments
 	args, ok := impl.Fields["arguments"].([]any)
 	if ok && len(args) > 0 {
-		base.WriteLine("additional_arguments = c(")
-		base.SetIndentLevel(base.GetIndentLevel() + 1)
-
-		for _, arg := range args {
+		done := t.markSource(impl.FieldPosition("arguments"), "arguments of "+impl.Name)
+		values := []string{}
+		for i, arg := range args {
 			argStr := fmt.Sprintf("%v", arg)
 
-			// Skip placeholders
-			if argStr == "_" {
+			// Skip placeholders, and the options written with the
+			// parameter after them
+			if argStr == "_" || heldOption(args, i, program.Parameters) {
 				continue
 			}
 
@@ -468,53 +1168,181 @@ func (t *RTranspiler) handleDockerImplementation(base BaseTranspiler, impl *ast.
 				paramType := GetParamType(argStr, program.Parameters)
 
 				// Handle different parameter types
+				value := argStr
 				if paramType == "file" || (paramType == "string" && Contains(fileParams, argStr)) {
 					// Use just the filename for file parameters
-					base.WriteLine("%s_filename,", argStr)
+					value = argStr + "_filename"
 				} else if paramType == "number" || paramType == "integer" {
 					// Convert numeric types to string
-					base.WriteLine("as.character(%s),", argStr)
+					value = fmt.Sprintf("as.character(%s)", argStr)
 				} else if paramType == "boolean" {
 					// Convert boolean to flag if TRUE
-					base.WriteLine("if(%s) \"--true-flag\" else character(0),", argStr)
-				} else {
-					base.WriteLine("%s,", argStr)
+					values = append(values, fmt.Sprintf("if(%s) %s else character(0)", argStr, rString(BooleanFlag(argStr, program.Parameters))))
+					continue
 				}
-			} else if strings.HasPrefix(argStr, "\"") || strings.HasPrefix(argStr, "'") {
-				// Already a string literal
-				base.WriteLine("%s,", argStr)
+				if omittableParam(argStr, program.Parameters) {
+					// Left off without a value, with its option
+					if i > 0 && heldOption(args, i-1, program.Parameters) {
+						value = fmt.Sprintf("c(%s, %s)", rString(strings.Trim(fmt.Sprint(args[i-1]), "\"'")), value)
+					}
+					value = fmt.Sprintf("if(!is.null(%s)) %s else character(0)", argStr, value)
+				}
+				values = append(values, value)
 			} else {
 				// Treat as plain string
-				base.WriteLine("\"%s\",", argStr)
+				values = append(values, rString(argStr))
 			}
 		}
+		writeRVector(base, "additional_arguments = c(", values, ")")
+		done()
+	}
 
-		base.SetIndentLevel(base.GetIndentLevel() - 1)
-		base.WriteLine(")")
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	base.WriteLine(")")
+}
+
+// rWhenGiven writes the lines of a parameter run only when it is given, if it
+// is an optional one left off without a value.
+func rWhenGiven(base BaseTranspiler, name string, params []ast.Parameter, write func()) {
+	if !omittableParam(name, params) {
+		write()
+		return
 	}
+	base.WriteLine("if (!is.null(%s)) {", name)
+	base.SetIndentLevel(base.GetIndentLevel() + 1)
+	write()
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	base.WriteLine("}")
+}
 
+// writeRVector writes the elements of an R vector or list, one per line,
+// separated by commas: R doesn't accept a trailing one.
+func writeRVector(base BaseTranspiler, open string, values []string, close string) {
+	base.WriteLine("%s", open)
+	base.SetIndentLevel(base.GetIndentLevel() + 1)
+	for i, value := range values {
+		if i < len(values)-1 {
+			value += ","
+		}
+		base.WriteLine("%s", value)
+	}
+	base.SetIndentLevel(base.GetIndentLevel() - 1)
+	base.WriteLine("%s", close)
+}
+
+// writeResult generates the value returned after the container ran, and
+// the manifest of the run.
+func (t *RTranspiler) writeResult(base BaseTranspiler, program *ast.Program, image string) {
+	base.WriteLine("")
+	base.WriteLine("# Record the run")
+	if t.useWorkDir() {
+		base.WriteLine("collect_outputs(work_dir, results_parent, staged)")
+	}
+	base.WriteLine("parameters <- list(")
+	base.SetIndentLevel(base.GetIndentLevel() + 1)
+	for i, param := range program.Parameters {
+		comma := ","
+		if i == len(program.Parameters)-1 {
+			comma = ""
+		}
+		base.WriteLine("%s = %s%s", param.Name, param.Name, comma)
+	}
 	base.SetIndentLevel(base.GetIndentLevel() - 1)
 	base.WriteLine(")")
+	if t.useInstrumentation() {
+		base.WriteLine("manifest <- write_manifest(output_dir, %s, %s, parameters, started, resources, log_files)", rString(program.Name), rString(image))
+	} else {
+		base.WriteLine("manifest <- write_manifest(output_dir, %s, %s, parameters, started, logs = log_files)", rString(program.Name), rString(image))
+	}
+	t.writeLog("completion", rString(program.Name+" completed"), "info",
+		"tool = "+rString(program.Name), "output_dir = output_dir", "manifest = manifest")
 
 	// Process result
 	base.WriteLine("")
 	base.WriteLine("# Process result")
-	base.WriteLine("return(list(")
+	base.WriteLine("return(baryon_result(")
 	base.SetIndentLevel(base.GetIndentLevel() + 1)
 	base.WriteLine("status = \"success\",")
-	base.WriteLine("output_dir = file.path(main_mount_dir, \"%s_results\")", program.Name)
+	base.WriteLine("output_dir = output_dir,")
+	base.WriteLine("manifest = manifest,")
+	if t.useInstrumentation() {
+		base.WriteLine("resources = resources,")
+	}
+	base.WriteLine("logs = as.list(log_files)")
 	base.SetIndentLevel(base.GetIndentLevel() - 1)
 	base.WriteLine("))")
+}
 
-	// Error handling
-	base.SetIndentLevel(base.GetIndentLevel() - 1)
-	base.WriteLine("}, error = function(e) {")
-	base.SetIndentLevel(base.GetIndentLevel() + 1)
-	base.WriteLine("stop(paste(\"Docker execution failed:\", e$message))")
-	base.SetIndentLevel(base.GetIndentLevel() - 1)
-	base.WriteLine("})")
+// writeResultClass generates the baryon_result class of the values of the
+// functions, with the same fields as the Result of the Python target.
+func (t *RTranspiler) writeResultClass() {
+	t.WriteLine("#' Create the result of a run.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param status \"success\", \"error\" or \"dry_run\".")
+	t.WriteLine("#' @param output_dir The results directory.")
+	t.WriteLine("#' @param message The error, or the docker command of a dry run.")
+	t.WriteLine("#' @param manifest The path of the manifest of the run.")
+	t.WriteLine("#' @param resources The resource usage of the run, if recorded.")
+	t.WriteLine("#' @param logs The log files of the standard output and error of the container.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @returns An object of class `baryon_result`.")
+	t.WriteLine("baryon_result <- function(status, output_dir = \"\", message = \"\", manifest = \"\",")
+	t.WriteLine("                          resources = list(), logs = list()) {")
+	t.WriteLine("  structure(")
+	t.WriteLine("    list(")
+	t.WriteLine("      status = status,")
+	t.WriteLine("      output_dir = output_dir,")
+	t.WriteLine("      message = message,")
+	t.WriteLine("      manifest = manifest,")
+	t.WriteLine("      resources = resources,")
+	t.WriteLine("      logs = logs")
+	t.WriteLine("    ),")
+	t.WriteLine("    class = \"baryon_result\"")
+	t.WriteLine("  )")
+	t.WriteLine("}")
+	t.WriteLine("#' @export")
+	t.WriteLine("print.baryon_result <- function(x, ...) {")
+	t.WriteLine("  cat(\"<baryon_result>\", x$status, \"\\n\")")
+	t.WriteLine("  if (nzchar(x$output_dir)) {")
+	t.WriteLine("    cat(\"Output directory:\", x$output_dir, \"\\n\")")
+	t.WriteLine("  }")
+	t.WriteLine("  if (nzchar(x$message)) {")
+	t.WriteLine("    cat(\"Message:\", x$message, \"\\n\")")
+	t.WriteLine("  }")
+	t.WriteLine("  invisible(x)")
+	t.WriteLine("}")
+	t.WriteLine("#' @export")
+	t.WriteLine("summary.baryon_result <- function(object, ...) {")
+	t.WriteLine("  files <- list()")
+	t.WriteLine("  if (nzchar(object$manifest) && file.exists(object$manifest)) {")
+	t.WriteLine("    files <- jsonlite::read_json(object$manifest)$files")
+	t.WriteLine("  }")
+	t.WriteLine("  structure(")
+	t.WriteLine("    list(")
+	t.WriteLine("      status = object$status,")
+	t.WriteLine("      output_dir = object$output_dir,")
+	t.WriteLine("      files = length(files),")
+	t.WriteLine("      bytes = sum(vapply(files, function(f) as.numeric(f$size), numeric(1))),")
+	t.WriteLine("      resources = object$resources")
+	t.WriteLine("    ),")
+	t.WriteLine("    class = \"summary.baryon_result\"")
+	t.WriteLine("  )")
+	t.WriteLine("}")
+	t.WriteLine("#' @export")
+	t.WriteLine("print.summary.baryon_result <- function(x, ...) {")
+	t.WriteLine("  cat(\"Status:\", x$status, \"\\n\")")
+	t.WriteLine("  cat(\"Output directory:\", x$output_dir, \"\\n\")")
+	t.WriteLine("  cat(sprintf(\"Files: %%d (%%s bytes)\\n\", x$files, format(x$bytes)))")
+	t.WriteLine("  for (name in names(x$resources)) {")
+	t.WriteLine("    cat(paste0(name, \":\"), x$resources[[name]], \"\\n\")")
+	t.WriteLine("  }")
+	t.WriteLine("  invisible(x)")
+	t.WriteLine("}")
+}
 
-	return nil
+// TemplateSections implements Templated.
+func (t *RTranspiler) TemplateSections() []string {
+	return []string{SectionHeader, SectionDocker, SectionResult}
 }
 
 func (t *RTranspiler) writeDockerHelpers() {
@@ -563,13 +1391,134 @@ func (t *RTranspiler) writeDockerHelpers() {
 	t.WriteLine("#'")
 	t.WriteLine("#' @param image_name The docker image you want to run.")
 	t.WriteLine("#' @param volumes The list of volumes to mount to the container.")
+	t.WriteLine("#' @param env Named vector of the environment variables of the container.")
 	t.WriteLine("#' @param additional_arguments Vector of arguments to pass to the container.")
+	t.WriteLine("#' @param dry_run %s", rDryRunDescription)
+	t.WriteLine("#' @param log_files Named vector of the files the stdout and stderr of the")
+	t.WriteLine("#' container are written to, instead of the console.")
 	t.WriteLine("#'")
+	t.WriteLine("#' @returns The exit status of docker, or the command of a dry run.")
 	t.WriteLine("#' @export")
 	t.WriteLine("run_in_docker <- function(image_name,")
 	t.WriteLine("                          volumes = list(),")
-	t.WriteLine("                          additional_arguments = c()) {")
-	t.WriteLine("  base_command <- \"run --privileged=true --platform linux/amd64 --rm\"")
+	t.WriteLine("                          env = c(),")
+	t.WriteLine("                          additional_arguments = c(),")
+	t.WriteLine("                          dry_run = FALSE,")
+	t.WriteLine("                          log_files = NULL) {")
+	if t.usePlainDocker() {
+		t.writePlainDockerRun()
+	} else {
+		t.writeNormalizePathDockerRun()
+	}
+	t.writeResultClass()
+	t.WriteLine("#' Return the digest of a local docker image.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param image_name The docker image.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @returns The first repository digest or the image ID, or an empty")
+	t.WriteLine("#' string when the image can't be inspected.")
+	t.WriteLine("image_digest <- function(image_name) {")
+	t.WriteLine("  format <- \"{{if .RepoDigests}}{{index .RepoDigests 0}}{{else}}{{.Id}}{{end}}\"")
+	t.WriteLine("  digest <- tryCatch(")
+	t.WriteLine("    suppressWarnings(system2(\"docker\",")
+	t.WriteLine("      args = c(\"image\", \"inspect\", \"--format\", shQuote(format), image_name),")
+	t.WriteLine("      stdout = TRUE, stderr = FALSE")
+	t.WriteLine("    )),")
+	t.WriteLine("    error = function(e) character(0)")
+	t.WriteLine("  )")
+	t.WriteLine("  if (length(digest) == 0 || !is.null(attr(digest, \"status\"))) {")
+	t.WriteLine("    return(\"\")")
+	t.WriteLine("  }")
+	t.WriteLine("  return(digest[1])")
+	t.WriteLine("}")
+	t.WriteLine("#' Stop when the checksum of an input file isn't the declared one.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param path The input file.")
+	t.WriteLine("#' @param algorithm The digest algorithm, such as \"sha256\".")
+	t.WriteLine("#' @param expected The declared hexadecimal digest.")
+	t.WriteLine("verify_checksum <- function(path, algorithm, expected) {")
+	t.WriteLine("  actual <- digest::digest(file = path, algo = algorithm)")
+	t.WriteLine("  if (actual != expected) {")
+	t.WriteLine("    stop(paste0(path, \": \", algorithm, \" checksum \", actual, \" does not match \", expected))")
+	t.WriteLine("  }")
+	t.WriteLine("}")
+	t.writeMountHelpers()
+	if t.useWorkDir() {
+		t.writeWorkDirHelpers()
+	}
+	if t.useJSONLogging() {
+		t.WriteLine("#' Log an event of a run as a JSON line on the standard error.")
+		t.WriteLine("#'")
+		t.WriteLine("#' @param event The event, such as \"start\" or \"completion\".")
+		t.WriteLine("#' @param message The message of the event.")
+		t.WriteLine("#' @param level The level of the event.")
+		t.WriteLine("#' @param ... The fields of the event.")
+		t.WriteLine("log_event <- function(event, message, level = \"info\", ...) {")
+		t.WriteLine("  entry <- c(list(")
+		t.WriteLine("    time = format(Sys.time(), \"%%Y-%%m-%%dT%%H:%%M:%%OS3Z\", tz = \"UTC\"),")
+		t.WriteLine("    level = level,")
+		t.WriteLine("    event = event,")
+		t.WriteLine("    message = message")
+		t.WriteLine("  ), list(...))")
+		t.WriteLine("  cat(jsonlite::toJSON(entry, auto_unbox = TRUE, null = \"null\"), \"\\n\", sep = \"\", file = stderr())")
+		t.WriteLine("}")
+	}
+	t.WriteLine("#' Write manifest.json into a results directory.")
+	t.WriteLine("#'")
+	t.WriteLine("#' The manifest lists the produced files with their SHA-256 checksums, the")
+	t.WriteLine("#' parameters of the run, the digest of the image and the start and end")
+	t.WriteLine("#' times of the run.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param output_dir The results directory.")
+	t.WriteLine("#' @param tool The name of the tool.")
+	t.WriteLine("#' @param image_name The docker image that ran.")
+	t.WriteLine("#' @param parameters The named list of the parameters.")
+	t.WriteLine("#' @param started The start time of the run.")
+	t.WriteLine("#' @param resources The resource usage of the run, if recorded.")
+	t.WriteLine("#' @param logs The log files of the container, in the results directory.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @returns The path of the manifest.")
+	t.WriteLine("write_manifest <- function(output_dir, tool, image_name, parameters, started,")
+	t.WriteLine("                           resources = NULL, logs = NULL) {")
+	t.WriteLine("  timestamp <- function(time) format(time, \"%%Y-%%m-%%dT%%H:%%M:%%SZ\", tz = \"UTC\")")
+	t.WriteLine("  paths <- setdiff(")
+	t.WriteLine("    sort(list.files(output_dir, recursive = TRUE, all.files = TRUE)),")
+	t.WriteLine("    \"manifest.json\"")
+	t.WriteLine("  )")
+	t.WriteLine("  files <- lapply(paths, function(path) {")
+	t.WriteLine("    full_path <- file.path(output_dir, path)")
+	t.WriteLine("    list(")
+	t.WriteLine("      path = path,")
+	t.WriteLine("      size = file.size(full_path),")
+	t.WriteLine("      sha256 = digest::digest(file = full_path, algo = \"sha256\")")
+	t.WriteLine("    )")
+	t.WriteLine("  })")
+	t.WriteLine("  manifest <- list(")
+	t.WriteLine("    tool = tool,")
+	t.WriteLine("    image = image_name,")
+	t.WriteLine("    image_digest = image_digest(image_name),")
+	t.WriteLine("    parameters = parameters,")
+	t.WriteLine("    started = timestamp(started),")
+	t.WriteLine("    finished = timestamp(Sys.time()),")
+	t.WriteLine("    files = files")
+	t.WriteLine("  )")
+	t.WriteLine("  if (length(resources) > 0) {")
+	t.WriteLine("    manifest$resources <- resources")
+	t.WriteLine("  }")
+	t.WriteLine("  if (length(logs) > 0) {")
+	t.WriteLine("    manifest$logs <- lapply(as.list(logs), function(path) substring(path, nchar(output_dir) + 2))")
+	t.WriteLine("  }")
+	t.WriteLine("  path <- file.path(output_dir, \"manifest.json\")")
+	t.WriteLine("  jsonlite::write_json(manifest, path, auto_unbox = TRUE, pretty = TRUE, null = \"null\")")
+	t.WriteLine("  return(path)")
+	t.WriteLine("}")
+	t.WriteLine("")
+}
+
+// writeNormalizePathDockerRun generates the body of run_in_docker mapping
+// the volumes with normalizepath, to the host when R runs in a container.
+func (t *RTranspiler) writeNormalizePathDockerRun() {
+	t.WriteLine("  base_command <- \"--privileged=true --platform linux/amd64 --rm\"")
 	t.WriteLine("  for (volume in volumes) {")
 	t.WriteLine("    volume[1] <- normalizepath::normalize_path(volume[1],")
 	t.WriteLine("      path_mappers = c(normalizepath::docker_mount_mapper)")
@@ -580,11 +1529,137 @@ func (t *RTranspiler) writeDockerHelpers() {
 	t.WriteLine("      sep = \":\"")
 	t.WriteLine("    ))")
 	t.WriteLine("  }")
+	t.WriteLine("  for (name in names(env)) {")
+	t.WriteLine("    base_command <- paste(base_command, \"-e\", shQuote(paste0(name, \"=\", env[[name]])))")
+	t.WriteLine("  }")
 	t.WriteLine("  base_command <- paste(base_command, image_name)")
 	t.WriteLine("  for (argument in additional_arguments) {")
 	t.WriteLine("    base_command <- paste(base_command, argument)")
 	t.WriteLine("  }")
-	t.WriteLine("  system2(\"docker\", args = base_command, stdout = \"\", stderr = \"\")")
+	t.WriteLine("  if (dry_run) {")
+	t.WriteLine("    command <- paste(\"docker run\", base_command)")
+	t.WriteLine("    cat(command, \"\\n\")")
+	t.WriteLine("    return(invisible(command))")
+	t.WriteLine("  }")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.writeLog("docker_command", "paste(\"Running Docker command: docker run\", base_command)",
+		"info", "command = paste(\"docker run\", base_command)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.writeInterruptible("paste(\"run --name\", container, base_command)")
 	t.WriteLine("}")
-	t.WriteLine("")
+}
+
+// writePlainDockerRun generates the body of run_in_docker with base R
+// only: the volumes are absolute paths on the machine running R, and the
+// arguments are quoted one by one.
+func (t *RTranspiler) writePlainDockerRun() {
+	t.WriteLine("  args <- c(\"--privileged=true\", \"--platform\", \"linux/amd64\", \"--rm\")")
+	t.WriteLine("  for (volume in volumes) {")
+	t.WriteLine("    host_path <- normalizePath(volume[1], mustWork = FALSE)")
+	t.WriteLine("    args <- c(args, \"-v\", paste(host_path, volume[2], sep = \":\"))")
+	t.WriteLine("  }")
+	t.WriteLine("  for (name in names(env)) {")
+	t.WriteLine("    args <- c(args, \"-e\", paste0(name, \"=\", env[[name]]))")
+	t.WriteLine("  }")
+	t.WriteLine("  args <- c(args, image_name, additional_arguments)")
+	t.WriteLine("  if (dry_run) {")
+	t.WriteLine("    command <- paste(c(\"docker\", \"run\", shQuote(args)), collapse = \" \")")
+	t.WriteLine("    cat(command, \"\\n\")")
+	t.WriteLine("    return(invisible(command))")
+	t.WriteLine("  }")
+	t.SetIndentLevel(t.GetIndentLevel() + 1)
+	t.writeLog("docker_command", "paste(\"Running Docker command: docker run\", paste(shQuote(args), collapse = \" \"))",
+		"info", "command = c(\"docker\", \"run\", args)")
+	t.SetIndentLevel(t.GetIndentLevel() - 1)
+	t.writeInterruptible("c(\"run\", \"--name\", container, shQuote(args))")
+	t.WriteLine("}")
+}
+
+// writeMountHelpers generates the helpers mounting the directory of each
+// input, like the one of the Python target.
+func (t *RTranspiler) writeMountHelpers() {
+	t.WriteLine("#' Mount the directory of an input under /inputs, unless it is already mounted.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param volumes The volumes of the run, as pairs of host and container paths.")
+	t.WriteLine("#' @param host_dir The directory of the input.")
+	t.WriteLine("#' @return The volumes, with the directory mounted.")
+	t.WriteLine("mount_input <- function(volumes, host_dir) {")
+	t.WriteLine("  if (!(host_dir %%in%% vapply(volumes, `[`, character(1), 1))) {")
+	t.WriteLine("    inputs <- sum(startsWith(vapply(volumes, `[`, character(1), 2), \"/inputs/\"))")
+	t.WriteLine("    volumes <- c(volumes, list(c(host_dir, paste0(\"/inputs/\", inputs + 1))))")
+	t.WriteLine("  }")
+	t.WriteLine("  return(volumes)")
+	t.WriteLine("}")
+	t.WriteLine("#' Return the path of an input in the container.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param volumes The volumes of the run, with the directory of the input.")
+	t.WriteLine("#' @param host_dir The directory of the input.")
+	t.WriteLine("#' @param filename The name of the input.")
+	t.WriteLine("#' @return The name of the input in /data, and its path elsewhere.")
+	t.WriteLine("input_path <- function(volumes, host_dir, filename) {")
+	t.WriteLine("  guest_dir <- volumes[[match(host_dir, vapply(volumes, `[`, character(1), 1))]][2]")
+	t.WriteLine("  if (guest_dir == \"/data\") {")
+	t.WriteLine("    return(filename)")
+	t.WriteLine("  }")
+	t.WriteLine("  return(paste(guest_dir, filename, sep = \"/\"))")
+	t.WriteLine("}")
+}
+
+// writeWorkDirHelpers generates the helpers of the work directories, like
+// the ones of the Python target.
+func (t *RTranspiler) writeWorkDirHelpers() {
+	t.WriteLine("#' Stage an input file or directory in the work directory of a run.")
+	t.WriteLine("#'")
+	t.WriteLine("#' Files are hard-linked, or copied when they are on another file system.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param path The input.")
+	t.WriteLine("#' @param work_dir The work directory.")
+	t.WriteLine("#' @return The name of the staged input.")
+	t.WriteLine("stage_input <- function(path, work_dir) {")
+	t.WriteLine("  name <- basename(path)")
+	t.WriteLine("  target <- file.path(work_dir, name)")
+	t.WriteLine("  if (file.exists(target)) {")
+	t.WriteLine("    stop(paste0(\"Inputs with the same name \", name, \" cannot be staged together\"))")
+	t.WriteLine("  }")
+	t.WriteLine("  if (dir.exists(path)) {")
+	t.WriteLine("    file.copy(path, work_dir, recursive = TRUE)")
+	t.WriteLine("  } else if (!suppressWarnings(file.link(path, target))) {")
+	t.WriteLine("    file.copy(path, target)")
+	t.WriteLine("  }")
+	t.WriteLine("  return(name)")
+	t.WriteLine("}")
+	t.WriteLine("#' Copy what a run wrote in its work directory, leaving out the staged inputs.")
+	t.WriteLine("#'")
+	t.WriteLine("#' @param work_dir The work directory.")
+	t.WriteLine("#' @param results_parent The directory the outputs are copied to.")
+	t.WriteLine("#' @param staged The names of the staged inputs.")
+	t.WriteLine("collect_outputs <- function(work_dir, results_parent, staged) {")
+	t.WriteLine("  outputs <- setdiff(list.files(work_dir, all.files = TRUE, no.. = TRUE), staged)")
+	t.WriteLine("  file.copy(file.path(work_dir, outputs), results_parent, recursive = TRUE, overwrite = TRUE)")
+	t.WriteLine("  invisible(outputs)")
+	t.WriteLine("}")
+}
+
+// writeInterruptible generates the run of the container, named so that an
+// interrupted run, e.g. by a Ctrl-C, stops it instead of leaving it
+// running. R can't catch SIGTERM, which leaves the container running.
+func (t *RTranspiler) writeInterruptible(args string) {
+	t.WriteLine("  stdout <- \"\"")
+	t.WriteLine("  stderr <- \"\"")
+	t.WriteLine("  if (!is.null(log_files)) {")
+	t.WriteLine("    dir.create(dirname(log_files[[\"stdout\"]]), showWarnings = FALSE, recursive = TRUE)")
+	t.WriteLine("    stdout <- log_files[[\"stdout\"]]")
+	t.WriteLine("    stderr <- log_files[[\"stderr\"]]")
+	t.WriteLine("  }")
+	t.WriteLine("  container <- basename(tempfile(\"baryon-\"))")
+	t.WriteLine("  tryCatch(")
+	t.WriteLine("    system2(\"docker\", args = %s, stdout = stdout, stderr = stderr),", args)
+	t.WriteLine("    interrupt = function(e) {")
+	if t.useJSONLogging() {
+		t.WriteLine("      log_event(\"interrupted\", paste(\"Interrupted, stopping container\", container), level = \"warning\", container = container)")
+	}
+	t.WriteLine("      system2(\"docker\", args = c(\"stop\", container), stdout = FALSE, stderr = FALSE)")
+	t.WriteLine("      stop(paste(\"interrupted, stopped container\", container), call. = FALSE)")
+	t.WriteLine("    }")
+	t.WriteLine("  )")
 }
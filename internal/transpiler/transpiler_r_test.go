@@ -0,0 +1,340 @@
+package transpiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
+
+func TestR_Manifest(t *testing.T) {
+	code := transpileWithOptions(t, "r", nil, alignProgram())
+	for _, expected := range []string{
+		"write_manifest <- function(output_dir, tool, image_name, parameters, started,\n",
+		`  timestamp <- function(time) format(time, "%Y-%m-%dT%H:%M:%SZ", tz = "UTC")` + "\n",
+		"    started <- Sys.time()\n",
+		"    dir.create(output_dir, showWarnings = FALSE, recursive = TRUE)\n",
+		"      threads = threads\n    )\n",
+		`    manifest <- write_manifest(output_dir, "align_reads", "biocontainers/bwa:0.7.17", parameters, started, logs = log_files)` + "\n",
+		"      manifest = manifest,\n      logs = as.list(log_files)\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestR_Instrumentation(t *testing.T) {
+	code := transpileWithOptions(t, "r", map[string]string{rInstrument: "true"}, alignProgram())
+	for _, expected := range []string{
+		`      wall_seconds = round(as.numeric(difftime(Sys.time(), started, units = "secs")), 3)` + "\n",
+		"parameters, started, resources, log_files)\n",
+		"      manifest = manifest,\n      resources = resources,\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestR_EntryPoint(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "fast"}, Type: TypeBoolean, Default: true})
+	code := transpileWithOptions(t, "r", map[string]string{rCLI: "true"}, program)
+	for _, expected := range []string{
+		"if (sys.nframe() == 0) {\n",
+		`    optparse::make_option("--reads", type = "character", default = env_default("BARYON_ALIGN_READS_READS", NULL), ` +
+			`help = "Input reads (env BARYON_ALIGN_READS_READS)"),` + "\n",
+		`    optparse::make_option("--threads", type = "integer", default = env_default("BARYON_ALIGN_READS_THREADS", 4, as.integer), ` +
+			`help = "Parameter of type 'integer' (env BARYON_ALIGN_READS_THREADS)"),` + "\n",
+		`    optparse::make_option("--fast", action = "store_true", default = env_default("BARYON_ALIGN_READS_FAST", TRUE, as.logical), ` +
+			`help = "Parameter of type 'boolean' (env BARYON_ALIGN_READS_FAST)"),` + "\n",
+		"  env_default <- function(name, default, convert = identity) {\n",
+		`    optparse::make_option("--no-fast", action = "store_false", dest = "fast"),` + "\n",
+		`  required <- c("reads", "sep")` + "\n",
+		"    threads = args[[\"threads\"]],\n",
+		"    dry_run = args$dry_run\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	code = transpileWithOptions(t, "r", nil, program)
+	if strings.Contains(code, "sys.nframe()") {
+		t.Errorf("the entry point should only be added with the cli option:\n%s", code)
+	}
+}
+
+func TestR_HiddenAdvanced(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[2].Metadata = map[string]string{"hidden": "true"}
+	program.Parameters[3].Metadata = map[string]string{"advanced": "true"}
+	code := transpileWithOptions(t, "r", nil, program)
+	for _, expected := range []string{
+		"#' @param mode Parameter of type 'enum' (allowed values: fast, sensitive) (internal, default: \"fast\")\n",
+		"#' @param threads Parameter of type 'integer' (advanced, default: 4)\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestR_ErrorResult(t *testing.T) {
+	code := transpileWithOptions(t, "r", map[string]string{rCLI: "true"}, alignProgram())
+	for _, expected := range []string{
+		"    if (result != 0) {\n      stop(paste(\"the container exited with status\", result))\n    }\n",
+		"  }, error = function(e) {\n    return(baryon_result(\n      status = \"error\",\n" +
+			"      message = paste(\"Docker execution failed:\", e$message),\n" +
+			"      logs = as.list(log_files[file.exists(log_files)])\n    ))\n  })\n",
+		"  if (result$status == \"error\") {\n    cat(\"Status:\", result$status, \"\\n\")\n" +
+			"    cat(\"Error:\", result$message, \"\\n\")\n    quit(status = 1)\n  }\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "stop(paste(\"Docker execution failed:\"") {
+		t.Errorf("a failed run should return an error result instead of stopping:\n%s", code)
+	}
+}
+
+func TestR_PlainDocker(t *testing.T) {
+	program := alignProgram()
+	program.Implementations[0].Fields["env"] = []any{[]any{"MODE", "mode"}, []any{"LANG", "C"}}
+	code := transpileWithOptions(t, "r", map[string]string{rDocker: "system2"}, program)
+	for _, expected := range []string{
+		"                          env = c(),\n",
+		"    host_path <- normalizePath(volume[1], mustWork = FALSE)\n",
+		`    args <- c(args, "-e", paste0(name, "=", env[[name]]))` + "\n",
+		`    system2("docker", args = c("run", "--name", container, shQuote(args)), stdout = stdout, stderr = stderr),` + "\n",
+		"      env = c(\n        \"MODE\" = as.character(mode),\n        \"LANG\" = \"C\"\n      ),\n",
+		"        mode\n      )\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "normalizepath::") {
+		t.Errorf("the system2 mode should not use normalizepath:\n%s", code)
+	}
+
+	tr := NewRTranspiler()
+	if err := ApplyOptions(tr, "r", map[string]string{rDocker: "system2"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(alignProgram())
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	if description := files[0].Content; !strings.Contains(description, "Imports:\n    digest,\n    jsonlite\n") {
+		t.Errorf("DESCRIPTION should not import normalizepath:\n%s", description)
+	}
+}
+
+func TestR_StringEscaping(t *testing.T) {
+	program := alignProgram()
+	program.Description = "Align 100% of the \"reads\" @ C:\\data"
+	program.Parameters[1].Default = `"`
+	program.Parameters[2].Constraints = []any{`say "hi"`, "sensitive"}
+	program.Parameters[2].Default = nil
+	program.Implementations[0].Fields["arguments"] = []any{"--label=\"x y\"", "'%d'", "reads"}
+	code := transpileWithOptions(t, "r", map[string]string{rCLI: "true"}, program)
+	for _, expected := range []string{
+		"#' @description Align 100\\% of the \"reads\" @@ C:\\\\data\n",
+		"\nsep = \"\\\"\",\n",
+		`valid_mode <- c("say \"hi\"", "sensitive")` + "\n",
+		`        "--label=\"x y\"",` + "\n",
+		`        "'%d'",` + "\n",
+		`parser <- optparse::OptionParser(description = "Align 100% of the \"reads\" @ C:\\data", option_list = option_list)`,
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "%!") {
+		t.Errorf("generated code has formatting errors:\n%s", code)
+	}
+}
+
+func TestR_ValidatesDefaults(t *testing.T) {
+	code := transpileWithOptions(t, "r", nil, alignProgram())
+	for _, expected := range []string{
+		"  if (missing(reads)) {\n    stop(\"reads is required\")\n  }\n",
+		"  if (!is.numeric(threads) || length(threads) != 1 || threads != round(threads)) {\n",
+		"  valid_mode <- c(\"fast\", \"sensitive\")\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "missing(threads)") {
+		t.Errorf("parameters with a default may be omitted:\n%s", code)
+	}
+}
+
+func TestR_CheckmateValidation(t *testing.T) {
+	code := transpileWithOptions(t, "r", map[string]string{rValidation: "checkmate"}, alignProgram())
+	for _, expected := range []string{
+		"  if (missing(reads)) {\n    stop(\"reads is required\")\n  }\n  checkmate::assert_string(reads)\n",
+		"  checkmate::assert_string(sep, n.chars = 1)\n",
+		"  checkmate::assert_choice(mode, c(\"fast\", \"sensitive\"))\n",
+		"  checkmate::assert_int(threads)\n",
+		"    checkmate::assert_file_exists(reads)\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "is.numeric(") {
+		t.Errorf("the checkmate validation should replace the if/stop blocks:\n%s", code)
+	}
+
+	tr := NewRTranspiler()
+	if err := ApplyOptions(tr, "r", map[string]string{rValidation: "checkmate"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(alignProgram())
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	if description := files[0].Content; !strings.Contains(description, "Imports:\n    checkmate,\n    digest,\n") {
+		t.Errorf("DESCRIPTION should import checkmate:\n%s", description)
+	}
+}
+
+func TestR_WorkDir(t *testing.T) {
+	code := transpileWithOptions(t, "r", map[string]string{rWorkDir: "temp"}, alignProgram())
+	for _, expected := range []string{
+		"stage_input <- function(path, work_dir) {\n",
+		"  work_dir <- tempfile(\"baryon-\")\n  dir.create(work_dir)\n  on.exit(unlink(work_dir, recursive = TRUE), add = TRUE)\n",
+		"    if (!dry_run) {\n      staged <- c(staged,\n        stage_input(reads_abspath, work_dir)\n      )\n    }\n",
+		"    reads_dir <- work_dir\n    main_mount_dir <- work_dir\n",
+		"    collect_outputs(work_dir, results_parent, staged)\n",
+		"    staged <- \"align_reads_results\"\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	code = transpileWithOptions(t, "r", nil, alignProgram())
+	if strings.Contains(code, "work_dir") {
+		t.Errorf("the inputs should only be staged with a work directory:\n%s", code)
+	}
+}
+
+func TestR_JSONLogging(t *testing.T) {
+	for _, docker := range []string{"normalizepath", "system2"} {
+		code := transpileWithOptions(t, "r", map[string]string{rLog: "json", rDocker: docker}, alignProgram())
+		for _, expected := range []string{
+			"log_event <- function(event, message, level = \"info\", ...) {\n",
+			"  log_event(\"docker_command\", paste(\"Running Docker command: docker run\", ",
+			"      log_event(\"interrupted\", paste(\"Interrupted, stopping container\", container), level = \"warning\", container = container)\n",
+			`  log_event("start", "Running align_reads", tool = "align_reads", image = "biocontainers/bwa:0.7.17")` + "\n",
+			`    log_event("completion", "align_reads completed", tool = "align_reads", output_dir = output_dir, manifest = manifest)` + "\n",
+			`    log_event("error", paste("Docker execution failed:", e$message), level = "error")` + "\n",
+		} {
+			if !strings.Contains(code, expected) {
+				t.Errorf("generated code with docker = %s does not contain %q:\n%s", docker, expected, code)
+			}
+		}
+	}
+
+	code := transpileWithOptions(t, "r", nil, alignProgram())
+	if strings.Contains(code, "log_event") {
+		t.Errorf("the events should only be logged with the option:\n%s", code)
+	}
+}
+
+func TestR_TranspilePackage(t *testing.T) {
+	program := alignProgram()
+	program.Description = "Align reads"
+	program.Metadata = map[string]string{"version": "1.2.0", "license": "MIT", "author": "Jane Doe <jane@example.org>"}
+
+	tr := NewRTranspiler()
+	if err := ApplyOptions(tr, "r", map[string]string{rCLI: "true"}); err != nil {
+		t.Fatalf("ApplyOptions() unexpected error: %v", err)
+	}
+	files, err := tr.TranspilePackage(program)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+
+	expected := map[string][]string{
+		"DESCRIPTION": {
+			"Package: align.reads\n",
+			"Version: 1.2.0\n",
+			"Maintainer: Jane Doe <jane@example.org>\n",
+			"License: MIT\n",
+			"Imports:\n    digest,\n    jsonlite,\n    normalizepath\n",
+		},
+		"NAMESPACE": {"export(align_reads)\n", "S3method(print, baryon_result)\n"},
+		"R/align_reads.R": {
+			"align_reads <- function(reads,\n",
+		},
+		"man/align_reads.Rd": {
+			"\\name{align_reads}\n",
+			"\\usage{\nalign_reads(reads, sep, mode = \"fast\", threads = 4, output_dir = NULL, dry_run = FALSE)\n}\n",
+			"\\item{mode}{Parameter of type 'enum' (allowed values: fast, sensitive)}\n",
+		},
+		"tests/testthat.R": {"test_check(\"align.reads\")\n"},
+		"tests/testthat/test-align_reads.R": {
+			`expect_identical(names(formals(align_reads)), c("reads", "sep", "mode", "threads", "output_dir", "dry_run"))`,
+			`expect_error(align_reads(reads = 1, sep = "a"), "reads")`,
+			`expect_error(align_reads(reads = "x", sep = 1), "sep")`,
+			`expect_error(align_reads(reads = "x", sep = "a", threads = "x"), "threads")`,
+		},
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("TranspilePackage() files = %v, want %d files", files, len(expected))
+	}
+	for path, fragments := range expected {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+	if strings.Contains(contents["R/align_reads.R"], "sys.nframe()") {
+		t.Errorf("packages should not have the command line entry point")
+	}
+}
+
+func TestRPackageName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"align_reads": "align.reads",
+		"bwa2":        "bwa2",
+		"_tool_":      "tool",
+		"2pass":       "pkg2pass",
+	} {
+		if got := rPackageName(name); got != expected {
+			t.Errorf("rPackageName(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestR_Result(t *testing.T) {
+	code := transpileWithOptions(t, "r", nil, alignProgram())
+	for _, expected := range []string{
+		"baryon_result <- function(status, output_dir = \"\", message = \"\", manifest = \"\",\n",
+		"    class = \"baryon_result\"\n",
+		"print.baryon_result <- function(x, ...) {\n",
+		"summary.baryon_result <- function(object, ...) {\n",
+		"print.summary.baryon_result <- function(x, ...) {\n",
+		"    return(baryon_result(\n      status = \"success\",\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "return(list(\n") {
+		t.Errorf("the function should return a baryon_result:\n%s", code)
+	}
+}
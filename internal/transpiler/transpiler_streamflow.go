@@ -1,12 +1,23 @@
 package transpiler
 
-import "github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+import (
+	"io"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+)
 
 func init() {
 	RegisterTranspiler("streamflow", &TranspilerDescriptor{
 		Extension:   "",
 		Display:     "StreamFlow",
 		Initializer: func() Transpiler { return NewStreamFlowTranspiler() },
+		Capabilities: &Capabilities{
+			ParameterTypes: allTypes(),
+			Outputs:        OutputsNone,
+			Limitations: map[string]Limitation{
+				implementationFeature("*"): {Unsupported, "the StreamFlow target is not implemented yet"},
+			},
+		},
 	})
 }
 
@@ -26,7 +37,16 @@ func (s *StreamFlowTranspiler) RegisterTypeValidator(typeName string, validator
 
 // Transpile implements Transpiler.
 func (s *StreamFlowTranspiler) Transpile(program *ast.Program) (string, error) {
-	panic("unimplemented")
+	return transpileToString(s, program)
+}
+
+// TranspileTo implements Transpiler.
+func (s *StreamFlowTranspiler) TranspileTo(w io.Writer, program *ast.Program) error {
+	return &UnsupportedFeatureError{
+		Target:  "streamflow",
+		Feature: implementationFeature("*"),
+		Message: "the StreamFlow target is not implemented yet",
+	}
 }
 
 func NewStreamFlowTranspiler() *StreamFlowTranspiler {
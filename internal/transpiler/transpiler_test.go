@@ -1,9 +1,16 @@
 package transpiler
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
 )
 
 func TestFormatDescription(t *testing.T) {
@@ -121,3 +128,1134 @@ func TestTranspilerBase_GetBuffer(t *testing.T) {
 		t.Errorf("GetBuffer() = %q, want %q", buf.String(), "abc")
 	}
 }
+
+func TestFormatLiteral(t *testing.T) {
+	tests := []struct {
+		value    any
+		syntax   LiteralSyntax
+		expected string
+	}{
+		{true, pythonLiteralSyntax, "True"},
+		{false, rLiteralSyntax, "FALSE"},
+		{4, pythonLiteralSyntax, "4"},
+		{0.5, rLiteralSyntax, "0.5"},
+		{`say "hi"`, pythonLiteralSyntax, `"say \"hi\""`},
+		{"plain", galaxyLiteralSyntax, "plain"},
+	}
+	for _, tt := range tests {
+		if got := FormatLiteral(tt.value, tt.syntax); got != tt.expected {
+			t.Errorf("FormatLiteral(%#v) = %q, want %q", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestTargetProgram_RenamesParameters(t *testing.T) {
+	program := &ast.Program{
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "sample-id"}, Type: TypeString},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "lambda"}, Type: TypeNumber,
+				Metadata: map[string]string{"target_name": "smoothing"}},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name: "run_docker",
+			Fields: map[string]any{
+				"arguments":    []any{"--id", "sample-id", "lambda"},
+				"env":          []any{[]any{"SAMPLE", "sample-id"}},
+				"config_files": []any{[]any{"/data/run.ini", "id = ${sample-id}\nlambda = ${lambda}"}},
+			},
+			References: []ast.Reference{
+				{Name: "sample-id", Field: "arguments"},
+				{Name: "lambda", Field: "arguments"},
+				{Name: "sample-id", Field: "env"},
+				{Name: "sample-id", Field: "config_files"},
+				{Name: "lambda", Field: "config_files"},
+			},
+		}},
+	}
+
+	renamed := targetProgram("python", program)
+	if renamed.Parameters[0].Name != "sample_id" || renamed.Parameters[1].Name != "smoothing" {
+		t.Errorf("unexpected parameter names: %q, %q", renamed.Parameters[0].Name, renamed.Parameters[1].Name)
+	}
+	args := renamed.Implementations[0].Fields["arguments"].([]any)
+	if args[0] != "--id" || args[1] != "sample_id" || args[2] != "smoothing" {
+		t.Errorf("unexpected arguments: %v", args)
+	}
+	env := renamed.Implementations[0].Fields["env"].([]any)[0].([]any)
+	if env[0] != "SAMPLE" || env[1] != "sample_id" {
+		t.Errorf("unexpected env: %v", env)
+	}
+	config := renamed.Implementations[0].Fields["config_files"].([]any)[0].([]any)
+	if config[0] != "/data/run.ini" || config[1] != "id = ${sample_id}\nlambda = ${smoothing}" {
+		t.Errorf("unexpected config file: %v", config)
+	}
+	if program.Parameters[0].Name != "sample-id" || program.Implementations[0].Fields["arguments"].([]any)[1] != "sample-id" {
+		t.Error("targetProgram() modified the original program")
+	}
+}
+
+// failingWriter accepts limit bytes, then fails every write.
+type failingWriter struct{ limit int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return 0, errors.New("disk full")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestTranspileTo_MatchesTranspile(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "input"}, Type: TypeFile},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name:   "run_docker",
+			Fields: map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input"}},
+		}},
+	}
+	for _, lang := range []string{"r", "python", "bash", "galaxy", "nextflow"} {
+		descriptor, _ := GetTranspiler(lang)
+		code, err := descriptor.Initializer().Transpile(program)
+		if err != nil {
+			t.Fatalf("%s: Transpile() unexpected error: %v", lang, err)
+		}
+		var sb strings.Builder
+		if err := descriptor.Initializer().TranspileTo(&sb, program); err != nil {
+			t.Fatalf("%s: TranspileTo() unexpected error: %v", lang, err)
+		}
+		if sb.String() != code {
+			t.Errorf("%s: TranspileTo() output differs from Transpile()", lang)
+		}
+		if err := descriptor.Initializer().TranspileTo(&failingWriter{limit: 10}, program); err == nil {
+			t.Errorf("%s: TranspileTo() expected write error", lang)
+		}
+	}
+}
+
+func TestTranspile_OutputDir(t *testing.T) {
+	tests := []struct {
+		lang     string
+		options  map[string]string
+		expected []string
+	}{
+		{"python", nil, []string{
+			"output_dir: Optional[str] = None, dry_run: bool = False) -> Result:\n",
+			"  if output_dir is None:\n    output_dir = os.path.join(main_mount_dir, \"align_reads_results\")\n",
+			"    volumes[output_dir] = \"/data/align_reads_results\"\n",
+			"    os.makedirs(output_dir, exist_ok=True)\n",
+			`parser.add_argument('--output-dir', dest="output_dir", default=env_default("BARYON_ALIGN_READS_OUTPUT_DIR", None), help=`,
+			"    output_dir=args.output_dir,\n",
+		}},
+		{"r", map[string]string{rCLI: "true"}, []string{
+			"output_dir = NULL,\ndry_run = FALSE) {\n",
+			"#' @param output_dir Results directory, align_reads_results next to the first input by default.\n",
+			"  if (is.null(output_dir)) {\n    output_dir <- file.path(main_mount_dir, \"align_reads_results\")\n  }\n",
+			"        c(main_mount_dir, \"/data\"),\n        c(output_dir, \"/data/align_reads_results\")\n      ),\n",
+			`    optparse::make_option("--output-dir", type = "character", default = env_default("BARYON_ALIGN_READS_OUTPUT_DIR", NULL), dest = "output_dir",`,
+			"    output_dir = args$output_dir,\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, tt.options, alignProgram())
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+	}
+}
+
+func TestTranspile_ContainerLogs(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"python", []string{
+			"  log_files = log_paths(output_dir)\n",
+			"  write_logs(log_files, stdout, stderr)\n",
+			"logs=log_files)\n",
+			`    manifest["logs"] = {stream: os.path.relpath(path, output_dir) for stream, path in logs.items()}` + "\n",
+		}},
+		{"r", []string{
+			"  log_files <- c(\n    stdout = file.path(output_dir, \"logs\", \"stdout.log\"),\n",
+			"      log_files = log_files,\n",
+			"    stdout <- log_files[[\"stdout\"]]\n",
+			"      logs = as.list(log_files)\n",
+			"    manifest$logs <- lapply(as.list(logs), function(path) substring(path, nchar(output_dir) + 2))\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, nil, alignProgram())
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+	}
+}
+
+func TestTranspile_InputMounts(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters, ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "index"}, Type: TypeFile})
+	impl := &program.Implementations[0]
+	impl.Fields["arguments"] = []any{"reads", "index"}
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"python", []string{
+			"    volumes[output_dir] = \"/data/align_reads_results\"\n    index_filename = mount_input(volumes, index_dir, index_filename)\n",
+			"    docker_args.append(index_filename)\n",
+		}},
+		{"r", []string{
+			"    volumes <- mount_input(volumes, index_dir)\n    index_filename <- input_path(volumes, index_dir, index_filename)\n",
+			"      volumes = volumes,\n",
+			"        reads_filename,\n        index_filename\n",
+		}},
+		{"bash", []string{
+			"input_dirs=(\"$reads_dir\")\nmount_input \"$index_dir\"\n",
+			"  index_filename=\"$input_mount/$index_filename\"\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, nil, program)
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+		if strings.Contains(code, "reads_filename = mount_input") || strings.Contains(code, "mount_input \"$reads_dir\"") {
+			t.Errorf("%s: the directory of the first input should only be mounted at /data:\n%s", tt.lang, code)
+		}
+	}
+
+	// The declared volumes are kept as they are
+	impl.Fields["volumes"] = []any{[]any{"reads", "/data"}}
+	if code := transpileWithOptions(t, "python", nil, program); strings.Contains(code, "index_filename = mount_input") {
+		t.Errorf("declared volumes should not mount the other inputs:\n%s", code)
+	}
+}
+
+func TestGalaxy_Tests(t *testing.T) {
+	program := alignProgram()
+	program.Outputs = []ast.OutputBlock{
+		{NamedBaseNode: ast.NamedBaseNode{Name: "aligned"}, Format: "sam", Path: "/data/out.sam"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "reports"}, Format: TypeDirectory, Path: "/data/reports"},
+	}
+	program.Tests = []ast.TestBlock{{
+		NamedBaseNode: ast.NamedBaseNode{Name: "small"},
+		Params:        []ast.TestParam{{Name: "reads", Value: "reads.fq"}, {Name: "threads", Value: 2}},
+		Expect: []ast.OutputExpectation{
+			{Output: "aligned", Assertions: []ast.Assertion{
+				{Kind: ast.AssertChecksum, Value: "sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+				{Kind: ast.AssertHasText, Value: "@SQ"},
+				{Kind: ast.AssertLines, Value: 12},
+			}},
+			{Output: "reports", Assertions: []ast.Assertion{{Kind: ast.AssertHasText, Value: "x"}}},
+		},
+	}, {
+		NamedBaseNode: ast.NamedBaseNode{Name: "expected"},
+		Params:        []ast.TestParam{{Name: "reads", Value: "reads.fq"}},
+		Expect:        []ast.OutputExpectation{{Output: "aligned", Assertions: []ast.Assertion{{Kind: ast.AssertFile, Value: "out.sam"}}}},
+	}}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"      <param name=\"reads\" value=\"reads.fq\"></param>\n      <param name=\"threads\" value=\"2\"></param>\n",
+		"      <output name=\"aligned\" checksum=\"sha1$da39a3ee5e6b4b0d3255bfef95601890afd80709\">\n        <assert_contents>\n" +
+			"          <has_text text=\"@SQ\"></has_text>\n          <has_n_lines n=\"12\"></has_n_lines>\n",
+		"      <output_collection name=\"reports\"></output_collection>\n",
+		"      <output name=\"aligned\" file=\"out.sam\"></output>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Index(code, "<tests>") < strings.Index(code, "</outputs>") {
+		t.Errorf("the tests should follow the outputs:\n%s", code)
+	}
+}
+
+func TestGalaxy_CollectionOutputs(t *testing.T) {
+	program := alignProgram()
+	program.Outputs = []ast.OutputBlock{
+		{NamedBaseNode: ast.NamedBaseNode{Name: "reports", BaseNode: ast.BaseNode{Description: "QC reports"}}, Format: TypeDirectory, Path: "/data/reports"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "pages"}, Format: TypeDirectory, Path: "/data/site/page_*.html"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "runs"}, Format: TypeDirectory, Path: "/scratch/run?"},
+	}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"    <collection name=\"reports\" type=\"list\" label=\"QC reports\">\n" +
+			"      <discover_datasets pattern=\"__name_and_ext__\" directory=\"reports\"></discover_datasets>\n    </collection>\n",
+		"      <discover_datasets pattern=\"(?P&lt;designation&gt;page_.*)\\.html\" directory=\"site\" format=\"html\"></discover_datasets>\n",
+		"      <discover_datasets pattern=\"(?P&lt;designation&gt;run.)\" directory=\"scratch\" format=\"auto\"></discover_datasets>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "<data") {
+		t.Errorf("collections should discover their datasets instead of a placeholder:\n%s", code)
+	}
+}
+
+func TestGalaxy_Citations(t *testing.T) {
+	program := alignProgram()
+	program.Metadata = map[string]string{
+		"doi":    "10.1093/bioinformatics/btp324, https://doi.org/10.1186/s13059-014-0550-8",
+		"bibtex": "@article{li2009,\n  author = {Li, Heng <lh3@sanger.ac.uk>},\n  title = {BWA}\n}\n@misc{bwa, title = {bwa}}",
+	}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"    <citation type=\"doi\">10.1093/bioinformatics/btp324</citation>\n",
+		"    <citation type=\"doi\">10.1186/s13059-014-0550-8</citation>\n",
+		"    <citation type=\"bibtex\">@article{li2009,\n  author = {Li, Heng &lt;lh3@sanger.ac.uk&gt;},\n  title = {BWA}\n}</citation>\n",
+		"    <citation type=\"bibtex\">@misc{bwa, title = {bwa}}</citation>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	if code := transpileWithOptions(t, "galaxy", nil, alignProgram()); strings.Contains(code, "<citations>") {
+		t.Errorf("a program without citation metadata should have no citations:\n%s", code)
+	}
+}
+
+// parseSource parses the source of a program.
+func parseSource(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	program, err := parser.New(lexer.New(source)).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram() unexpected error: %v", err)
+	}
+	return program
+}
+
+func TestGalaxy_CitationsFromSource(t *testing.T) {
+	program := parseSource(t, `(bala bwa_mem (
+  (desc "Align reads with BWA-MEM")
+  (doi "10.1093/bioinformatics/btp324")
+  (bibtex "@misc{bwa, title = {bwa}}")
+  (reads file (desc "Input reads"))
+  (run_docker (image "bwa:0.7.17") (arguments "mem" reads))
+))`)
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<tool id="bwa_mem" name="bwa_mem">
+  <description>Align reads with BWA-MEM</description>
+  <requirements>
+    <container type="docker">bwa:0.7.17</container>
+  </requirements>
+  <command detect_errors="exit_code"><![CDATA[ln -s '$reads' 'reads.${reads.ext}' &&
+mem 'reads.${reads.ext}']]></command>
+  <inputs>
+    <param type="data" name="reads" format="data">
+      <label>Input reads</label>
+    </param>
+  </inputs>
+  <outputs></outputs>
+  <help><![CDATA[**What it does**
+
+Align reads with BWA-MEM
+
+**Inputs**
+
+- **reads** (file): Input reads]]></help>
+  <citations>
+    <citation type="doi">10.1093/bioinformatics/btp324</citation>
+    <citation type="bibtex">@misc{bwa, title = {bwa}}</citation>
+  </citations>
+</tool>`
+	if code := strings.TrimSpace(transpileWithOptions(t, "galaxy", nil, program)); code != expected {
+		t.Errorf("generated code = \n%s\nwant\n%s", code, expected)
+	}
+}
+
+func TestVersionFromSource(t *testing.T) {
+	program := parseSource(t, `(bala bwa_mem (
+  (version "0.7.17")
+  (reads file)
+  (run_docker (image "bwa:0.7.17") (arguments "mem" reads))
+))`)
+	if code := transpileWithOptions(t, "galaxy", nil, program); !strings.Contains(code, `<tool id="bwa_mem" name="bwa_mem" version="0.7.17">`) {
+		t.Errorf("expected the version of the tool:\n%s", code)
+	}
+	for _, target := range []struct {
+		transpiler Packaged
+		path       string
+		expected   string
+	}{
+		{NewRTranspiler(), "DESCRIPTION", "Version: 0.7.17\n"},
+		{NewNextflowTranspiler(), NextflowConfigFile, "  version = '0.7.17'\n"},
+	} {
+		files, err := target.transpiler.TranspilePackage(program)
+		if err != nil {
+			t.Fatalf("TranspilePackage() unexpected error: %v", err)
+		}
+		for _, file := range files {
+			if file.Path == target.path && !strings.Contains(file.Content, target.expected) {
+				t.Errorf("%s does not contain %q:\n%s", file.Path, target.expected, file.Content)
+			}
+		}
+	}
+}
+
+func TestGalaxy_Help(t *testing.T) {
+	program := alignProgram()
+	program.Description = "# Align\n\nAligns reads with [BWA](https://github.com/lh3/bwa), __fast__.\n\n* uses `bwa mem`\n* _single_ end\n\n```\nbwa mem ref.fa reads.fq\n```"
+	program.Outputs = []ast.OutputBlock{{NamedBaseNode: ast.NamedBaseNode{Name: "bam", BaseNode: ast.BaseNode{Description: "Aligned reads"}}, Format: "bam"}}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	expected := "  <help><![CDATA[**What it does**\n\n" +
+		"Align\n=====\n\n" +
+		"Aligns reads with `BWA <https://github.com/lh3/bwa>`__, **fast**.\n\n" +
+		"- uses ``bwa mem``\n- *single* end\n\n" +
+		"::\n\n    bwa mem ref.fa reads.fq\n\n" +
+		"**Inputs**\n\n" +
+		"- **reads** (file): Input reads\n" +
+		"- **sep** (character)\n" +
+		"- **mode** (enum, one of ``fast``, ``sensitive``, default ``fast``)\n" +
+		"- **threads** (integer, default ``4``)\n\n" +
+		"**Outputs**\n\n" +
+		"- **bam** (bam): Aligned reads]]></help>\n"
+	if !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+}
+
+func TestGalaxy_Version(t *testing.T) {
+	program := alignProgram()
+	program.Metadata = map[string]string{"version": "0.7.17+galaxy0"}
+	program.Implementations[0].Fields["version_command"] = "bwa 2>&1 | grep Version"
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"<tool id=\"align_reads\" name=\"align_reads\" version=\"0.7.17+galaxy0\">\n",
+		"  </requirements>\n  <version_command><![CDATA[bwa 2>&1 | grep Version]]></version_command>\n  <command detect_errors=\"exit_code\">",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	code = transpileWithOptions(t, "galaxy", nil, alignProgram())
+	if strings.Contains(code, "name=\"align_reads\" version=") || strings.Contains(code, "<version_command>") {
+		t.Errorf("a program without a version should have no version nor version command:\n%s", code)
+	}
+}
+
+func TestGalaxy_Stdio(t *testing.T) {
+	program := alignProgram()
+	program.Implementations[0].Fields["exit_codes"] = []any{[]any{"1:", "fatal"}, []any{"137", "fatal_oom", "Out of memory"}}
+	program.Implementations[0].Fields["error_patterns"] = []any{[]any{"^\\[E::", "fatal", "BWA error"}}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	expected := "  <stdio>\n" +
+		"    <exit_code range=\"1:\" level=\"fatal\"></exit_code>\n" +
+		"    <exit_code range=\"137\" level=\"fatal_oom\" description=\"Out of memory\"></exit_code>\n" +
+		"    <regex match=\"^\\[E::\" source=\"stderr\" level=\"fatal\" description=\"BWA error\"></regex>\n" +
+		"  </stdio>\n  <command><![CDATA["
+	if !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+
+	// Without exit codes, error patterns come with the failure of the
+	// non-zero codes
+	delete(program.Implementations[0].Fields, "exit_codes")
+	code = transpileWithOptions(t, "galaxy", nil, program)
+	if expected := "  <stdio>\n    <exit_code range=\"1:\" level=\"fatal\"></exit_code>\n    <regex "; !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+
+	code = transpileWithOptions(t, "galaxy", nil, alignProgram())
+	if expected := "<command detect_errors=\"exit_code\">"; !strings.Contains(code, expected) || strings.Contains(code, "<stdio>") {
+		t.Errorf("a program without exit codes should detect errors by exit code:\n%s", code)
+	}
+}
+
+func TestGalaxy_Conditionals(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters, ast.Parameter{
+		NamedBaseNode: ast.NamedBaseNode{Name: "seed"},
+		Type:          TypeInteger,
+		When:          &ast.Condition{Param: "mode", Values: []any{"sensitive"}},
+	})
+	program.Implementations[0].Fields["arguments"] = []any{"mem", "-t", "threads", "-k", "seed", "mode", "reads"}
+	program.Tests = []ast.TestBlock{{
+		NamedBaseNode: ast.NamedBaseNode{Name: "sensitive"},
+		Params:        []ast.TestParam{{Name: "reads", Value: "reads.fq"}, {Name: "mode", Value: "sensitive"}, {Name: "seed", Value: 19}},
+	}}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"<![CDATA[ln -s '$reads' 'reads.${reads.ext}' &&\nmem -t $threads\n" +
+			"#if str($mode_conditional.mode) == 'sensitive'\n  -k $mode_conditional.seed\n#end if\n" +
+			"'$mode_conditional.mode' 'reads.${reads.ext}']]></command>",
+		"    <conditional name=\"mode_conditional\">\n" +
+			"      <param type=\"select\" name=\"mode\" value=\"fast\">\n",
+		"      </param>\n      <when value=\"fast\"></when>\n      <when value=\"sensitive\">\n" +
+			"        <param type=\"integer\" name=\"seed\"></param>\n      </when>\n    </conditional>\n" +
+			"    <param type=\"integer\" name=\"threads\" value=\"4\"></param>\n  </inputs>",
+		"      <param name=\"mode_conditional|mode\" value=\"sensitive\"></param>\n" +
+			"      <param name=\"mode_conditional|seed\" value=\"19\"></param>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestGalaxy_Command(t *testing.T) {
+	program := alignProgram()
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "index"}, Type: TypeDirectory},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "paired"}, Type: TypeBoolean, Metadata: map[string]string{"flag": "-p"}})
+	program.Implementations[0].Fields["volumes"] = []any{[]any{"index", "/refs"}}
+	program.Implementations[0].Fields["arguments"] = []any{"mem", "paired", "-o", "/data/results/out.sam", "index", "reads"}
+	program.Outputs = []ast.OutputBlock{
+		{NamedBaseNode: ast.NamedBaseNode{Name: "log"}, Format: "txt"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "aligned"}, Format: "sam", Path: "/data/results/out.sam"},
+		{NamedBaseNode: ast.NamedBaseNode{Name: "reports"}, Format: TypeDirectory, Path: "/data/reports"},
+	}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	expected := "<![CDATA[mkdir -p 'refs' 'results' 'reports' &&\n" +
+		"ln -s '$reads' 'reads.${reads.ext}' &&\n" +
+		"#for $index_element in $index\n  ln -s '$index_element' 'refs/${index_element.element_identifier}' &&\n#end for\n" +
+		"mem\n#if $paired\n  -p\n#end if\n-o results/out.sam 'refs' 'reads.${reads.ext}' > '$log'\n" +
+		"&& mv 'results/out.sam' '$aligned']]></command>"
+	if !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+}
+
+func TestGalaxy_ConfigFiles(t *testing.T) {
+	program := alignProgram()
+	program.Implementations[0].Fields["config_files"] = []any{
+		[]any{"/data/conf/bwa.ini", "# Alignment\nreads = ${reads}\nmode = ${mode}\nthreads = ${threads}\ncost = $5"},
+		[]any{"/data/reads", "${sep}"},
+	}
+	program.Implementations[0].Fields["arguments"] = []any{"mem", "--config", "/data/conf/bwa.ini"}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"<![CDATA[mkdir -p 'conf' &&\n" +
+			"ln -s '$bwa_ini' 'conf/bwa.ini' &&\nln -s '$config_reads' 'reads' &&\n" +
+			"ln -s '$reads' 'reads.${reads.ext}' &&\nmem --config conf/bwa.ini]]></command>\n",
+		"  <configfiles>\n" +
+			"    <configfile name=\"bwa_ini\"><![CDATA[\\# Alignment\nreads = reads.${reads.ext}\nmode = ${mode}\nthreads = ${threads}\ncost = \\$5]]></configfile>\n" +
+			"    <configfile name=\"config_reads\"><![CDATA[${sep}]]></configfile>\n" +
+			"  </configfiles>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+
+	// The tool only sees the job working directory
+	program.Implementations[0].Fields["config_files"] = []any{[]any{"/etc/bwa.ini", ""}}
+	descriptor, _ := GetTranspiler("galaxy")
+	if _, err := descriptor.Initializer().Transpile(program); err == nil || !strings.Contains(err.Error(), "'/etc/bwa.ini'") {
+		t.Errorf("expected an error for a config file outside the mounts, got %v", err)
+	}
+}
+
+func TestGalaxy_Sections(t *testing.T) {
+	program := alignProgram()
+	for i := range program.Parameters[2:] {
+		program.Parameters[2+i].Metadata = map[string]string{"group": "Advanced options"}
+	}
+	program.Parameters = append(program.Parameters, ast.Parameter{
+		NamedBaseNode: ast.NamedBaseNode{Name: "seed"},
+		Type:          TypeInteger,
+		When:          &ast.Condition{Param: "mode", Values: []any{"sensitive"}},
+	})
+	program.Implementations[0].Fields["arguments"] = []any{"mem", "-t", "threads", "-k", "seed", "reads"}
+	program.Tests = []ast.TestBlock{{
+		NamedBaseNode: ast.NamedBaseNode{Name: "sensitive"},
+		Params:        []ast.TestParam{{Name: "reads", Value: "reads.fq"}, {Name: "threads", Value: 2}},
+	}}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"mem -t $advanced_options.threads\n" +
+			"#if str($advanced_options.mode_conditional.mode) == 'sensitive'\n  -k $advanced_options.mode_conditional.seed\n#end if\n",
+		"    </param>\n" +
+			"    <section name=\"advanced_options\" title=\"Advanced options\" expanded=\"false\">\n" +
+			"      <conditional name=\"mode_conditional\">\n",
+		"          <param type=\"integer\" name=\"seed\"></param>\n        </when>\n      </conditional>\n" +
+			"      <param type=\"integer\" name=\"threads\" value=\"4\"></param>\n    </section>\n  </inputs>",
+		"      <param name=\"advanced_options|threads\" value=\"2\"></param>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestGalaxy_HiddenAdvanced(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[0].Metadata = map[string]string{"hidden": "true"}
+	program.Parameters[2].Metadata = map[string]string{"hidden": "true"}
+	program.Parameters[3].Metadata = map[string]string{"advanced": "true"}
+	program.Parameters = append(program.Parameters, ast.Parameter{
+		NamedBaseNode: ast.NamedBaseNode{Name: "fast"},
+		Type:          TypeBoolean,
+		Default:       false,
+		Metadata:      map[string]string{"hidden": "true"},
+	})
+	program.Implementations[0].Fields["arguments"] = []any{"mem", "-t", "threads", "fast", "mode", "reads"}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"mem -t $advanced_options.threads\n#if str($fast) == 'true'\n  --fast\n#end if\n'$mode'",
+		"    <section name=\"advanced_options\" title=\"Advanced options\" expanded=\"false\">\n" +
+			"      <param type=\"data\" name=\"reads\" format=\"data\">\n",
+		"    <param type=\"hidden\" name=\"mode\" value=\"fast\"></param>\n",
+		"    <param type=\"hidden\" name=\"fast\" value=\"false\"></param>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	// Hidden params aren't described in the help
+	if strings.Contains(code, "- **mode**") || !strings.Contains(code, "- **reads**") {
+		t.Errorf("help should leave out the hidden params only:\n%s", code)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[0].Metadata = map[string]string{"desc": "Input reads", "label.it": "Letture"}
+	localized, missing := Localize(program, "it_IT")
+	if len(missing) != 0 {
+		t.Errorf("expected every described parameter to be translated, got %v", missing)
+	}
+	if program.Parameters[0].Description != "Input reads" || program.Parameters[0].Metadata["desc"] != "Input reads" {
+		t.Errorf("the program should be left as is, got %v", program.Parameters[0])
+	}
+
+	code := transpileWithOptions(t, "galaxy", nil, localized)
+	for _, expected := range []string{"<label>Letture</label>", "- **reads** (file): Letture"} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+	code = transpileWithOptions(t, "python", nil, localized)
+	if !strings.Contains(code, `help="Letture (env BARYON_ALIGN_READS_READS)"`) {
+		t.Errorf("expected the translated help of reads:\n%s", code)
+	}
+
+	if _, missing = Localize(program, "fr"); len(missing) != 1 || missing[0].Name != "reads" {
+		t.Errorf("expected reads to have no fr label, got %v", missing)
+	}
+	if unchanged, _ := Localize(program, ""); unchanged != program {
+		t.Errorf("expected the program as is without a locale")
+	}
+}
+
+func TestGalaxy_Profile(t *testing.T) {
+	code := transpileWithOptions(t, "galaxy", map[string]string{"profile": "23.1"}, alignProgram())
+	if !strings.Contains(code, "<tool id=\"align_reads\" name=\"align_reads\" profile=\"23.1\">") {
+		t.Errorf("expected the profile of the tool:\n%s", code)
+	}
+	// Jobs fail on a non-zero exit code by default since 16.04
+	if !strings.Contains(code, "<command><![CDATA[") {
+		t.Errorf("expected no detect_errors with a profile:\n%s", code)
+	}
+
+	code = transpileWithOptions(t, "galaxy", map[string]string{"profile": "none"}, alignProgram())
+	if strings.Contains(code, "profile=") || !strings.Contains(code, "<command detect_errors=\"exit_code\">") {
+		t.Errorf("expected no profile and detect_errors without a profile:\n%s", code)
+	}
+	if err := ApplyOptions(NewGalaxyTranspiler(), "galaxy", map[string]string{"profile": "15.10"}); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestGalaxy_Validators(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[3].Metadata = map[string]string{"min": "1", "max": "64"}
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{
+			NamedBaseNode: ast.NamedBaseNode{Name: "sample"},
+			Type:          TypeString,
+			Metadata:      map[string]string{"pattern": "^[A-Za-z0-9_]+$"},
+		},
+		ast.Parameter{
+			NamedBaseNode: ast.NamedBaseNode{Name: "mates"},
+			Type:          TypeFile,
+			Metadata:      map[string]string{"optional": "true"},
+		})
+	program.Implementations[0].Fields["arguments"] = []any{"mem", "-t", "threads", "-R", "sample", "reads", "mates"}
+	code := transpileWithOptions(t, "galaxy", nil, program)
+	for _, expected := range []string{
+		"#if $mates\n  ln -s '$mates' 'mates.${mates.ext}' &&\n#end if\n" +
+			"mem -t $threads -R '$sample' 'reads.${reads.ext}'\n#if $mates\n  'mates.${mates.ext}'\n#end if]]>",
+		"    <param type=\"integer\" name=\"threads\" value=\"4\" min=\"1\" max=\"64\"></param>\n",
+		"    <param type=\"text\" name=\"sample\">\n" +
+			"      <validator type=\"regex\" message=\"The value must match ^[A-Za-z0-9_]+$\">^[A-Za-z0-9_]+$</validator>\n",
+		"    <param type=\"data\" name=\"mates\" format=\"data\" optional=\"true\"></param>\n",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestGalaxy_Sanitizers(t *testing.T) {
+	code := transpileWithOptions(t, "galaxy", map[string]string{"allowed_chars": "._/."}, alignProgram())
+	expected := "    <param type=\"text\" name=\"sep\">\n      <sanitizer invalid_char=\"\">\n" +
+		"        <valid initial=\"string.ascii_letters,string.digits\">\n" +
+		"          <add value=\".\"></add>\n          <add value=\"_\"></add>\n          <add value=\"/\"></add>\n" +
+		"        </valid>\n      </sanitizer>\n    </param>\n"
+	if !strings.Contains(code, expected) {
+		t.Errorf("generated code does not contain %q:\n%s", expected, code)
+	}
+	// Selects are limited to their options
+	if strings.Count(code, "<sanitizer") != 1 {
+		t.Errorf("expected a sanitizer on the text param only:\n%s", code)
+	}
+
+	// Without allowed_chars, the characters of the default are kept
+	program := alignProgram()
+	for i := range program.Parameters {
+		if program.Parameters[i].Name == "sep" {
+			program.Parameters[i].Default = ","
+		}
+	}
+	code = transpileWithOptions(t, "galaxy", nil, program)
+	if !strings.Contains(code, "<add value=\"-\"></add>\n          <add value=\",\"></add>\n        </valid>") {
+		t.Errorf("expected the sanitizer to keep the comma of the default:\n%s", code)
+	}
+
+	code = transpileWithOptions(t, "galaxy", map[string]string{"sanitize": "false"}, alignProgram())
+	if strings.Contains(code, "<sanitizer") {
+		t.Errorf("expected no sanitizer:\n%s", code)
+	}
+	err := ApplyOptions(NewGalaxyTranspiler(), "galaxy", map[string]string{"allowed_chars": "-;"})
+	if err == nil || !strings.Contains(err.Error(), "\";\"") {
+		t.Errorf("expected an error for an unsafe character, got %v", err)
+	}
+}
+
+func TestGalaxy_TranspileSuite(t *testing.T) {
+	align := alignProgram()
+	index := alignProgram()
+	index.Name = "bwa_index"
+	index.Parameters[0].Description = "Reference"
+
+	files, err := NewGalaxyTranspiler().TranspileSuite([]*ast.Program{align, index})
+	if err != nil {
+		t.Fatalf("TranspileSuite() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+	if len(contents) != 3 {
+		t.Fatalf("expected macros.xml and a file per tool, got %d files", len(contents))
+	}
+	expected := map[string][]string{
+		GalaxyMacrosFile: {
+			"  <xml name=\"requirements\">\n    <requirements>\n      <container type=\"docker\">biocontainers/bwa:0.7.17</container>\n",
+			"  <xml name=\"param_mode\">\n    <param type=\"select\" name=\"mode\" value=\"fast\">\n",
+			"  <xml name=\"param_threads\">\n",
+		},
+		"bwa_index.xml": {
+			"  <macros>\n    <import>macros.xml</import>\n  </macros>\n  <expand macro=\"requirements\"></expand>\n  <command",
+			"    <param type=\"data\" name=\"reads\" format=\"data\">\n      <label>Reference</label>\n",
+			"    <expand macro=\"param_sep\"></expand>\n    <expand macro=\"param_mode\"></expand>\n",
+		},
+	}
+	for path, fragments := range expected {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+	// Params defined differently by the tools stay in each tool
+	if strings.Contains(contents[GalaxyMacrosFile], "param_reads") {
+		t.Errorf("differing params should not be shared:\n%s", contents[GalaxyMacrosFile])
+	}
+
+	files, err = NewGalaxyTranspiler().TranspileSuite([]*ast.Program{align})
+	if err != nil || len(files) != 1 || strings.Contains(files[0].Content, "<macros>") {
+		t.Errorf("a single tool should have no macros, got %v, %v", files, err)
+	}
+}
+
+func TestGalaxy_DataTableFiles(t *testing.T) {
+	align := alignProgram()
+	align.Parameters[0].Metadata = map[string]string{"galaxy_data_table": "bwa_indexes"}
+	files, err := NewGalaxyTranspiler().TranspilePackage(align)
+	if err != nil {
+		t.Fatalf("TranspilePackage() unexpected error: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+	expected := map[string][]string{
+		"align_reads.xml": {`<options from_data_table="bwa_indexes">`},
+		GalaxyDataTablesFile: {
+			"<tables>\n  <table name=\"bwa_indexes\" comment_char=\"#\">\n    <columns>value, name, path</columns>\n" +
+				"    <file path=\"tool-data/bwa_indexes.loc\"></file>\n  </table>\n</tables>\n",
+		},
+		"tool-data/bwa_indexes.loc.sample": {
+			"# Entries of the bwa_indexes data table, used by align_reads.\n",
+			"#   value\\tname\\tpath\n",
+		},
+	}
+	if len(contents) != len(expected) {
+		t.Errorf("TranspilePackage() files = %v, want %d files", files, len(expected))
+	}
+	for path, fragments := range expected {
+		for _, fragment := range fragments {
+			if !strings.Contains(contents[path], fragment) {
+				t.Errorf("%s does not contain %q:\n%s", path, fragment, contents[path])
+			}
+		}
+	}
+
+	// The tables used by several tools are declared once
+	index := alignProgram()
+	index.Name = "bwa_index"
+	index.Parameters[0].Metadata = map[string]string{"galaxy_data_table": "bwa_indexes"}
+	files, err = NewGalaxyTranspiler().TranspileSuite([]*ast.Program{align, index})
+	if err != nil {
+		t.Fatalf("TranspileSuite() unexpected error: %v", err)
+	}
+	contents = map[string]string{}
+	for _, file := range files {
+		contents[file.Path] = file.Content
+	}
+	if n := strings.Count(contents[GalaxyDataTablesFile], "<table "); n != 1 {
+		t.Errorf("expected a single table, got %d:\n%s", n, contents[GalaxyDataTablesFile])
+	}
+	if !strings.Contains(contents["tool-data/bwa_indexes.loc.sample"], "used by align_reads, bwa_index.\n") {
+		t.Errorf("the .loc.sample should list the tools using the table:\n%s", contents["tool-data/bwa_indexes.loc.sample"])
+	}
+
+	// Tools without data tables have only their XML
+	files, err = NewGalaxyTranspiler().TranspilePackage(alignProgram())
+	if err != nil || len(files) != 1 {
+		t.Errorf("expected a single file without data tables, got %v, %v", files, err)
+	}
+}
+
+func TestTranspile_DryRun(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"python", []string{
+			"def align_reads(reads: str, sep: str, mode: str = \"fast\", threads: int = 4, output_dir: Optional[str] = None, dry_run: bool = False) -> Result:\n",
+			"    if dry_run:\n      command = shlex.join(docker_command(\"biocontainers/bwa:0.7.17\", volumes, env_vars, docker_args))\n",
+			"parser.add_argument('--dry-run', action=\"store_true\", help=",
+			"    dry_run=args.dry_run,\n",
+		}},
+		{"r", []string{
+			"threads = 4,\noutput_dir = NULL,\ndry_run = FALSE) {\n",
+			"      dry_run = dry_run,\n",
+			`      return(baryon_result(status = "dry_run", message = result))` + "\n",
+		}},
+		{"bash", []string{
+			"dry_run=\"false\"\n",
+			"    --dry-run)\n      dry_run=\"true\"\n",
+			"    printf '%q ' docker run --rm \"${opts[@]}\" \"$image\" \"$@\"\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, nil, alignProgram())
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+	}
+}
+
+// TestTranspile_Deterministic guards against output depending on map
+// iteration order, which differs between runs.
+func TestTranspile_Deterministic(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Metadata:      map[string]string{"return": "Results", "author": "lab", "version": "1.0", "license": "MIT"},
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "input"}, Type: TypeFile,
+				Metadata: map[string]string{"label": "Input", "desc": "Reads", "format": "fastq"}},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "mode"}, Type: TypeEnum, Constraints: []any{"a", "b", "c"}},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "threads"}, Type: TypeInteger, Default: 4},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name: "run_docker",
+			Fields: map[string]any{
+				"image":     "ubuntu:22.04",
+				"command":   "run.sh",
+				"volumes":   []any{[]any{"input", "/data"}, []any{"/ref", "/ref"}},
+				"env":       []any{[]any{"A", "1"}, []any{"B", "mode"}, []any{"C", "3"}, []any{"D", "threads"}},
+				"arguments": []any{"input", "mode", "threads"},
+			},
+		}},
+		Outputs: []ast.OutputBlock{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "out"}, Format: TypeFile, Path: "/data/out.txt",
+				Metadata: map[string]string{"label": "Out", "desc": "Result"}},
+		},
+	}
+	for _, lang := range []string{"r", "python", "bash", "galaxy", "nextflow"} {
+		descriptor, _ := GetTranspiler(lang)
+		first, err := descriptor.Initializer().Transpile(program)
+		if err != nil {
+			t.Fatalf("%s: Transpile() unexpected error: %v", lang, err)
+		}
+		for range 20 {
+			if code, _ := descriptor.Initializer().Transpile(program); code != first {
+				t.Fatalf("%s: Transpile() output differs between runs", lang)
+			}
+		}
+	}
+	for range 20 {
+		if program.String() != program.String() {
+			t.Fatal("Program.String() differs between calls")
+		}
+	}
+}
+
+// cancelingContext is canceled after its Err method was called limit times.
+type cancelingContext struct {
+	context.Context
+	limit int
+}
+
+func (c *cancelingContext) Err() error {
+	if c.limit--; c.limit < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestTranspileContext_Canceled(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "input"}, Type: TypeFile},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name:   "run_docker",
+			Fields: map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input"}},
+		}},
+	}
+	for _, lang := range []string{"r", "python", "bash", "galaxy", "nextflow"} {
+		descriptor, _ := GetTranspiler(lang)
+		ctx := &cancelingContext{Context: context.Background(), limit: 1}
+		if err := TranspileContext(ctx, descriptor.Initializer(), io.Discard, program); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: TranspileContext() = %v, want context.Canceled", lang, err)
+		}
+	}
+}
+
+func TestTranspile_Concurrent(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode: ast.NamedBaseNode{Name: "tool"},
+		Parameters: []ast.Parameter{
+			{NamedBaseNode: ast.NamedBaseNode{Name: "input"}, Type: TypeFile},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "mode"}, Type: TypeEnum, Constraints: []any{"a", "b"}},
+			{NamedBaseNode: ast.NamedBaseNode{Name: "tag"}, Type: "label"},
+		},
+		Implementations: []ast.ImplementationBlock{{
+			Name:   "run_docker",
+			Fields: map[string]any{"image": "ubuntu:22.04", "arguments": []any{"input", "mode"}},
+		}},
+	}
+	for _, lang := range []string{"r", "python", "bash", "galaxy", "nextflow"} {
+		descriptor, _ := GetTranspiler(lang)
+		tr := descriptor.Initializer()
+		// Registered after construction, so every call must inherit it
+		tr.RegisterTypeValidator("label", func(base BaseTranspiler, param ast.Parameter) error {
+			base.WriteLine("# label %s", param.Name)
+			return nil
+		})
+		expected, err := tr.Transpile(program)
+		if err != nil {
+			t.Fatalf("%s: Transpile() unexpected error: %v", lang, err)
+		}
+
+		results := make([]string, 8)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], _ = tr.Transpile(program)
+			}()
+		}
+		wg.Wait()
+		for i, code := range results {
+			if code != expected {
+				t.Errorf("%s: concurrent Transpile() %d differs from a sequential one", lang, i)
+			}
+		}
+	}
+}
+
+func TestTranspile_HandlersCopied(t *testing.T) {
+	program := &ast.Program{
+		NamedBaseNode:   ast.NamedBaseNode{Name: "tool"},
+		Implementations: []ast.ImplementationBlock{{Name: "run_custom", Fields: map[string]any{}}},
+	}
+	handler := func(base BaseTranspiler, impl *ast.ImplementationBlock, program *ast.Program) error {
+		base.WriteLine("# custom %s", impl.Name)
+		return nil
+	}
+	for _, lang := range []string{"r", "python", "bash", "nextflow"} {
+		descriptor, _ := GetTranspiler(lang)
+		tr := descriptor.Initializer()
+
+		// Writing into the map of the handlers doesn't register a handler
+		tr.(BaseTranspiler).GetImplementationHandlers()["run_custom"] = handler
+		if _, err := tr.Transpile(program); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: Transpile() = %v, want an unsupported implementation", lang, err)
+		}
+
+		tr.RegisterImplementationHandler("run_custom", handler)
+		for range 2 {
+			if code, err := tr.Transpile(program); err != nil || !strings.Contains(code, "# custom run_custom") {
+				t.Errorf("%s: Transpile() = %v, want the registered handler to run:\n%s", lang, err, code)
+			}
+		}
+	}
+}
+
+func TestTranspile_InputChecksums(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[0].Metadata = map[string]string{"checksum": "md5:D41D8CD98F00B204E9800998ECF8427E"}
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"python", []string{
+			"def verify_checksum(path: str, algorithm: str, expected: str) -> None:\n",
+			"  if os.path.isfile(reads_path):\n    verify_checksum(reads_path, \"md5\", \"d41d8cd98f00b204e9800998ecf8427e\")\n",
+		}},
+		{"r", []string{
+			"verify_checksum <- function(path, algorithm, expected) {\n",
+			"  if (file.exists(reads)) {\n    verify_checksum(reads, \"md5\", \"d41d8cd98f00b204e9800998ecf8427e\")\n  }\n",
+		}},
+		{"bash", []string{
+			"if ! echo \"d41d8cd98f00b204e9800998ecf8427e  $reads\" | md5sum -c --status; then\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, nil, program)
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+	}
+}
+
+func TestTranspile_OptionalParameters(t *testing.T) {
+	program := alignProgram()
+	program.Parameters[3].Metadata = map[string]string{"min": "1", "max": "64"}
+	program.Parameters = append(program.Parameters,
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "mates"}, Type: TypeFile, Metadata: map[string]string{"optional": "true"}},
+		ast.Parameter{NamedBaseNode: ast.NamedBaseNode{Name: "seed"}, Type: TypeInteger, Metadata: map[string]string{"optional": "true"}},
+	)
+	program.Implementations[0].Fields["arguments"] = []any{"-t", "threads", "-k", "seed", "reads", "mates"}
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"python", []string{
+			"mates: Optional[str] = None, seed: Optional[int] = None,",
+			"  if threads < 1:\n    raise ValueError(f\"threads must be at least 1, got {threads}\")\n",
+			"  if threads > 64:\n    raise ValueError(f\"threads must be at most 64, got {threads}\")\n",
+			"  mates_path = None\n  if mates is not None:\n",
+			"  if mates_path is not None and not is_running_in_docker():\n",
+			"    if mates is not None:\n      mates_filename = mount_input(volumes, mates_dir, mates_filename)\n",
+			"    if seed is not None:\n      docker_args.append(\"-k\")\n      docker_args.append(str(seed))\n",
+			"    if mates is not None:\n      docker_args.append(mates_filename)\n",
+		}},
+		{"r", []string{
+			"mates = NULL,\nseed = NULL,\n",
+			"  if (threads < 1) {\n    stop(paste(\"threads must be at least 1, got\", threads))\n  }\n",
+			"  if (threads > 64) {\n    stop(paste(\"threads must be at most 64, got\", threads))\n  }\n",
+			"  if (!is.null(seed)) {\n    if (!is.numeric(seed)",
+			"  if (!is.null(mates) && !is_running_in_docker()) {\n",
+			"        if(!is.null(seed)) c(\"-k\", as.character(seed)) else character(0),\n",
+			"        if(!is.null(mates)) mates_filename else character(0)\n",
+		}},
+		{"bash", []string{
+			"if awk -v value=\"$threads\" 'BEGIN { exit !(value < 1) }'; then\n",
+			"if awk -v value=\"$threads\" 'BEGIN { exit !(value > 64) }'; then\n",
+			"if [[ -n \"$mates\" ]]; then\n  if [[ ! -e \"$mates\" ]]; then\n",
+			"if [[ -n \"$seed\" ]]; then\n  container_args+=(\"-k\" \"$seed\")\nfi\n",
+			"if [[ -n \"$mates\" ]]; then\n  container_args+=(\"$mates_filename\")\nfi\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, nil, program)
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+		for _, unexpected := range []string{"mates is required", "seed is required", "\"-k\",\n", "container_args+=(\"-k\")"} {
+			if strings.Contains(code, unexpected) {
+				t.Errorf("%s: an optional parameter without a value should be left off, found %q:\n%s", tt.lang, unexpected, code)
+			}
+		}
+	}
+}
+
+func TestTranspile_StopsInterruptedContainers(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected []string
+	}{
+		{"python", []string{
+			"  cmd = docker_command(image, volumes, env, args, ['--name', name])\n",
+			"  try:\n    stdout, stderr = process.communicate()\n  except BaseException:\n    stop_container(name)\n    process.kill()\n    raise\n",
+			"  subprocess.run(['docker', 'stop', name], capture_output=True, check=False)\n",
+			"  signal.signal(signal.SIGTERM, exit_on_signal)\n",
+		}},
+		{"r", []string{
+			"  container <- basename(tempfile(\"baryon-\"))\n",
+			"    system2(\"docker\", args = paste(\"run --name\", container, base_command), stdout = stdout, stderr = stderr),\n",
+			"    interrupt = function(e) {\n      system2(\"docker\", args = c(\"stop\", container), stdout = FALSE, stderr = FALSE)\n",
+		}},
+		{"bash", []string{
+			"  trap 'stop_container 143' TERM\n",
+			"  docker run --rm --name \"$container_name\" \"${opts[@]}\" \"$image\" \"$@\" &\n  local status=0\n  wait $! || status=$?\n",
+			"  docker stop \"$container_name\" > /dev/null 2>&1 || true\n",
+		}},
+	}
+	for _, tt := range tests {
+		code := transpileWithOptions(t, tt.lang, nil, alignProgram())
+		for _, expected := range tt.expected {
+			if !strings.Contains(code, expected) {
+				t.Errorf("%s: generated code does not contain %q:\n%s", tt.lang, expected, code)
+			}
+		}
+	}
+}
+
+// BenchmarkTranspile transpiles the align program to every built-in target
+// that supports it.
+func BenchmarkTranspile(b *testing.B) {
+	for _, name := range GetTranspilerNames() {
+		descriptor, _ := GetTranspiler(name)
+		b.Run(name, func(b *testing.B) {
+			program := alignProgram()
+			issues, _ := CheckCompatibility(name, program)
+			for _, issue := range issues {
+				if issue.Support == Unsupported {
+					b.Skip(issue)
+				}
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := descriptor.Initializer().TranspileTo(io.Discard, program); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
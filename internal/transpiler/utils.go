@@ -1,5 +1,37 @@
 package transpiler
 
+import (
+	"fmt"
+	"strconv"
+)
+
+// LiteralSyntax describes how a target language spells literal values.
+type LiteralSyntax struct {
+	True  string
+	False string
+	Quote func(string) string
+}
+
+// FormatLiteral formats a parsed literal (string, int, float64 or bool) using
+// the target syntax. Defaults are type-checked during semantic analysis, so
+// the Go type of the value matches the parameter type.
+func FormatLiteral(value any, syntax LiteralSyntax) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return syntax.True
+		}
+		return syntax.False
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return syntax.Quote(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 // https://docs.galaxyproject.org/en/latest/dev/schema.html#tool-inputs-param
 type GalaxyTypeValidator string
 
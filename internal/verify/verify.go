@@ -0,0 +1,133 @@
+// Package verify checks the syntax of generated files with the tools of
+// their language, for -verify: Python compiles them, R parses them, bash
+// -n, nextflow lint and nextflow config read them, and xmllint checks the
+// XML, against the Galaxy schema for tools when it is configured. Files
+// are verified only when the tool is installed.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnavailable is returned by Check when the tool of a checker isn't in
+// the PATH.
+var ErrUnavailable = errors.New("not installed")
+
+// Options configures the checkers.
+type Options struct {
+	// GalaxySchema is the galaxy.xsd schema Galaxy tools are validated
+	// against; without it, xmllint only checks that they are well-formed.
+	GalaxySchema string
+}
+
+// Checker runs the tool of a language on a file.
+type Checker struct {
+	// Name describes the check, e.g. "bash -n".
+	Name string
+	// Tool is the executable, looked up in the PATH.
+	Tool string
+	// args returns the arguments of the tool for a file.
+	args func(path string) []string
+}
+
+// SyntaxError is a file rejected by its checker, with the output of the
+// tool.
+type SyntaxError struct {
+	Path    string
+	Checker string
+	Output  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: rejected by %s:\n%s", e.Path, e.Checker, strings.TrimRight(e.Output, "\n"))
+}
+
+// pythonCompile compiles a file as py_compile does, without writing its
+// bytecode next to it.
+const pythonCompile = "import sys; compile(open(sys.argv[1], 'rb').read(), sys.argv[1], 'exec')"
+
+// For returns the checker of a file, by its name and extension. Files of
+// other kinds, such as package metadata or data, have none.
+func For(path string, opts Options) (Checker, bool) {
+	switch base := filepath.Base(path); {
+	case base == "nextflow.config":
+		// nextflow config reads the configuration of a project directory
+		return Checker{"nextflow config", "nextflow", func(path string) []string {
+			return []string{"config", filepath.Dir(path)}
+		}}, true
+	case strings.HasSuffix(base, ".py"):
+		return Checker{"python compile", "python3", func(path string) []string {
+			return []string{"-c", pythonCompile, path}
+		}}, true
+	case strings.HasSuffix(base, ".R") || strings.HasSuffix(base, ".r"):
+		return Checker{"R parse", "Rscript", func(path string) []string {
+			return []string{"-e", "invisible(parse(file = commandArgs(TRUE)[1]))", path}
+		}}, true
+	case strings.HasSuffix(base, ".sh"):
+		return Checker{"bash -n", "bash", func(path string) []string {
+			return []string{"-n", path}
+		}}, true
+	case strings.HasSuffix(base, ".nf"):
+		return Checker{"nextflow lint", "nextflow", func(path string) []string {
+			return []string{"lint", path}
+		}}, true
+	case strings.HasSuffix(base, ".xml"):
+		if opts.GalaxySchema != "" && isGalaxyTool(path) {
+			return Checker{"xmllint --schema", "xmllint", func(path string) []string {
+				return []string{"--noout", "--schema", opts.GalaxySchema, path}
+			}}, true
+		}
+		return Checker{"xmllint", "xmllint", func(path string) []string {
+			return []string{"--noout", path}
+		}}, true
+	}
+	return Checker{}, false
+}
+
+// Check runs the checker on a file. It returns a *SyntaxError when the
+// tool rejects it, and ErrUnavailable when the tool isn't installed.
+func (c Checker) Check(ctx context.Context, path string) error {
+	tool, err := exec.LookPath(c.Tool)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Tool, ErrUnavailable)
+	}
+	output, err := exec.CommandContext(ctx, tool, c.args(path)...).CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: %s: %w", path, c.Name, ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &SyntaxError{Path: path, Checker: c.Name, Output: string(output)}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", path, c.Name, err)
+	}
+	return nil
+}
+
+// isGalaxyTool reports whether the root element of an XML file is a tool,
+// rather than e.g. the macros shared by the tools of a suite.
+func isGalaxyTool(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "tool"
+		}
+	}
+}
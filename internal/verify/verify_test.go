@@ -0,0 +1,92 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "align.xml")
+	macros := filepath.Join(dir, "macros.xml")
+	os.WriteFile(tool, []byte(`<?xml version="1.0"?><tool id="align"/>`), 0644)
+	os.WriteFile(macros, []byte(`<macros/>`), 0644)
+	opts := Options{GalaxySchema: "galaxy.xsd"}
+
+	tests := []struct {
+		path string
+		want string // checker name, empty for none
+	}{
+		{"out/align.py", "python compile"},
+		{"out/R/align.R", "R parse"},
+		{"align.sh", "bash -n"},
+		{"main.nf", "nextflow lint"},
+		{"pipeline/nextflow.config", "nextflow config"},
+		{tool, "xmllint --schema"},
+		{macros, "xmllint"},
+		{"pyproject.toml", ""},
+		{"DESCRIPTION", ""},
+	}
+	for _, tt := range tests {
+		checker, ok := For(tt.path, opts)
+		if ok != (tt.want != "") || checker.Name != tt.want {
+			t.Errorf("For(%q) = %q, %v, want %q", tt.path, checker.Name, ok, tt.want)
+		}
+	}
+	if checker, _ := For(tool, Options{}); checker.Name != "xmllint" {
+		t.Errorf("For() without schema = %q, want xmllint", checker.Name)
+	}
+	if args := strings.Join(mustFor(t, "pipeline/nextflow.config").args("pipeline/nextflow.config"), " "); args != "config pipeline" {
+		t.Errorf("nextflow config arguments = %q, want the project directory", args)
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	// A fake Rscript accepts files containing "ok"
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\ngrep -q ok \"$last\" || { echo \"unexpected symbol\"; exit 1; }\n"
+	if err := os.WriteFile(filepath.Join(bin, "Rscript"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	valid, invalid := filepath.Join(dir, "valid.R"), filepath.Join(dir, "invalid.R")
+	os.WriteFile(valid, []byte("ok <- 1\n"), 0644)
+	os.WriteFile(invalid, []byte("x <- (\n"), 0644)
+
+	checker := mustFor(t, valid)
+	if err := checker.Check(context.Background(), valid); err != nil {
+		t.Errorf("Check(valid) = %v", err)
+	}
+	err := checker.Check(context.Background(), invalid)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Path != invalid || !strings.Contains(syntaxErr.Output, "unexpected symbol") {
+		t.Errorf("Check(invalid) = %v, want a SyntaxError with the output of the tool", err)
+	}
+}
+
+func TestChecker_CheckUnavailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	err := mustFor(t, "main.nf").Check(context.Background(), "main.nf")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Check() = %v, want ErrUnavailable", err)
+	}
+}
+
+func mustFor(t *testing.T, path string) Checker {
+	t.Helper()
+	checker, ok := For(path, Options{})
+	if !ok {
+		t.Fatalf("For(%q) found no checker", path)
+	}
+	return checker
+}
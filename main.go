@@ -1,27 +1,57 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/build"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/config"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/image"
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parsecache"
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
 	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/verify"
 )
 
 func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	// When check mode is enabled, don't ask for a output file, or a target language.
 	check := flag.Bool("check", false, "Check syntax only, do not transpile")
-	inputFile := flag.String("input", "", "Input Baryon file (.bala)")
+	verifyImages := flag.Bool("verify-images", false, "Check that container images exist in their registry")
+	provenance := flag.Bool("provenance", false, "Annotate the generated code with the source lines it comes from")
+	sourceMap := flag.Bool("source-map", false, "Write a source map of the generated code next to the output file")
+	verifyOutput := flag.Bool("verify", false, "Check the syntax of the generated files with the tools of their language, when installed")
+	locale := flag.String("locale", "", "Language of the labels and help of the generated code, e.g. it, from the (label ...) translations of the parameters")
+	emitPackage := flag.Bool("emit-package", false, "Write an installable package to the output directory instead of a single file (python, r, galaxy, nextflow)")
+	inputFile := flag.String("input", "", "Input Baryon file (.bala), or a directory of them")
 	outputFile := flag.String("output", "", "Output file (default: same name with language-specific extension)")
 	langFlag := flag.String("lang", "r",
-		fmt.Sprintf("Target language: %s",
+		fmt.Sprintf("Target language: %s (directories: a comma-separated list, or all)",
 			strings.Join(transpiler.GetTranspilerNames(), ", ")))
+	jobs := flag.Int("jobs", build.DefaultJobs(), "Number of programs parsed and transpiled at once, for directories")
+	useCache := flag.Bool("cache", false, "Reuse the syntax trees of unchanged programs, cached under the user cache directory")
+	maxDepth := flag.Int("max-depth", parser.DefaultMaxDepth, "Number of nested lists past which a program is rejected, 0 for no limit")
+	options := optionFlags{}
+	flag.Var(options, "option", "Set a target option as name=value, see 'targets -describe' (repeatable)")
+	flag.Usage = usage
 	flag.Parse()
 
 	if *inputFile == "" {
@@ -30,6 +60,54 @@ func main() {
 		os.Exit(1)
 	}
 
+	// A directory is transpiled as a suite of programs
+	info, err := os.Stat(*inputFile)
+	directory := err == nil && info.IsDir()
+	configDir := filepath.Dir(*inputFile)
+	if directory {
+		configDir = *inputFile
+	}
+
+	cfg, err := config.Load(configDir)
+	if err != nil {
+		log.Fatalf("loading configuration: %v", err)
+	}
+	if err := registerPlugins(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	// A cache that can't be located or created is only a slower build
+	cache := &parsecache.Cache{MaxDepth: *maxDepth}
+	if *useCache {
+		if dir, err := parsecache.DefaultDir(); err == nil && os.MkdirAll(dir, 0755) == nil {
+			cache.Dir = dir
+		}
+	}
+
+	// Directories can be transpiled to several targets at once
+	if directory {
+		targets, err := parseTargets(*langFlag)
+		if err != nil || len(targets) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: Unsupported language '%s'.", *langFlag)
+			os.Exit(1)
+		}
+		if *emitPackage {
+			fmt.Fprintln(os.Stderr, "Error: -emit-package applies to a single program")
+			os.Exit(1)
+		}
+		outDir := *outputFile
+		if outDir == "" {
+			outDir = *inputFile
+		}
+		if err := processDirectory(*inputFile, outDir, targets, cfg, *check, *jobs, cache,
+			analysisOptions{verifyImages: *verifyImages},
+			transpileOptions{provenance: *provenance, sourceMap: *sourceMap, verify: *verifyOutput, locale: *locale, options: options}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Validate target language
 	targetLang := strings.ToLower(*langFlag)
 	currentTranspiler, err := transpiler.GetTranspiler(targetLang)
@@ -44,6 +122,9 @@ func main() {
 		ext := filepath.Ext(*inputFile)
 		baseFile := (*inputFile)[0 : len(*inputFile)-len(ext)]
 		outFile = baseFile + currentTranspiler.Extension
+		if *emitPackage {
+			outFile = baseFile
+		}
 	}
 
 	fmt.Printf("Reading: %s\n", *inputFile)
@@ -53,11 +134,16 @@ func main() {
 	}
 
 	fmt.Println("Parsing Baryon code...")
-	program, err := parseProgram(string(data))
+	program, err := decodeProgram(*inputFile, data, cache)
 	if err != nil {
 		log.Fatalf("parsing error: %v", err)
 	}
 
+	fmt.Println("Analyzing Baryon code...")
+	if err := analyzeProgram(program, cfg, analysisOptions{verifyImages: *verifyImages}); err != nil {
+		log.Fatalf("semantic error: %v", err)
+	}
+
 	if *check {
 		fmt.Println("✅ Syntax check passed")
 		fmt.Print(program.String())
@@ -65,30 +151,308 @@ func main() {
 	}
 
 	// Process and transpile the file
-	if err := processFile(outFile, targetLang, currentTranspiler, program); err != nil {
+	if err := processFile(outFile, targetLang, currentTranspiler, program, cfg,
+		transpileOptions{source: *inputFile, provenance: *provenance, sourceMap: *sourceMap, emitPackage: *emitPackage, verify: *verifyOutput,
+			locale: *locale, options: options}); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// transpileOptions selects the optional annotations of the generated code,
+// and its verification.
+type transpileOptions struct {
+	source     string // path of the program, as given on the command line
+	provenance bool
+	sourceMap  bool // write <output>.map, see writeSourceMap
+	// emitPackage writes the package of a Packaged target to the output
+	// directory.
+	emitPackage bool
+	// verify checks the written files, see verifyFiles.
+	verify bool
+	// locale selects the translations of the labels of the parameters,
+	// see localize.
+	locale string
+	// options are the target options of the command line, which override
+	// the ones of the configuration.
+	options map[string]string
+}
+
+// optionFlags collects the repeated -option name=value flags.
+type optionFlags map[string]string
+
+func (o optionFlags) String() string {
+	pairs := []string{}
+	for _, name := range slices.Sorted(maps.Keys(o)) {
+		pairs = append(pairs, name+"="+o[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (o optionFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	o[name] = v
+	return nil
+}
+
 func processFile(outputPath, lang string,
 	currentTranspiler *transpiler.TranspilerDescriptor,
 	program *ast.Program,
+	cfg *config.Config,
+	opts transpileOptions,
 ) error {
 	fmt.Printf("Transpiling to %s...\n", currentTranspiler.Display)
 
-	t := currentTranspiler.Initializer()
-
-	code, err := t.Transpile(program)
+	if err := negotiateFeatures(lang, program, ""); err != nil {
+		return err
+	}
+	program = localize(program, opts.locale, "")
+	t, err := configuredTranspiler(lang, currentTranspiler, cfg, opts)
 	if err != nil {
-		return fmt.Errorf("transpilation failed: %w", err)
+		return err
+	}
+
+	if opts.emitPackage {
+		packaged, ok := t.(transpiler.Packaged)
+		if !ok {
+			return fmt.Errorf("target '%s' can't be written as a package", lang)
+		}
+		files, err := packaged.TranspilePackage(program)
+		if err != nil {
+			return fmt.Errorf("transpilation failed: %w", err)
+		}
+		paths := []string{}
+		for _, file := range files {
+			path := filepath.Join(outputPath, filepath.FromSlash(file.Path))
+			fmt.Printf("Writing: %s\n", path)
+			if err := writeFileSafely(path, []byte(file.Content)); err != nil {
+				return fmt.Errorf("writing package: %w", err)
+			}
+			paths = append(paths, path)
+		}
+		if opts.verify {
+			if err := verifyFiles(paths, cfg); err != nil {
+				return err
+			}
+		}
+		fmt.Println("✅ Transpilation completed successfully")
+		return nil
 	}
 
 	fmt.Printf("Writing: %s\n", outputPath)
-	if err = writeFileSafely(outputPath, []byte(code)); err != nil {
-		return fmt.Errorf("writing output: %w", err)
+	if err := writeFileWith(outputPath, func(w io.Writer) error {
+		return t.TranspileTo(w, program)
+	}); err != nil {
+		return fmt.Errorf("transpilation failed: %w", err)
+	}
+
+	if annotated, ok := t.(transpiler.Annotated); ok && opts.sourceMap {
+		if err := writeSourceMap(outputPath, opts.source, annotated.SourceMappings()); err != nil {
+			return fmt.Errorf("writing source map: %w", err)
+		}
+	}
+	if opts.verify {
+		if err := verifyFiles([]string{outputPath}, cfg); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("✅ Transpilation completed successfully")
+	return nil
+}
+
+// negotiateFeatures prints the warnings of the constructs of a program
+// newer than the target, which are degraded, or fails if they are
+// unsupported. The warnings start with the path of the program, source,
+// when it is set.
+func negotiateFeatures(lang string, program *ast.Program, source string) error {
+	warnings, err := transpiler.NegotiateFeatures(lang, program)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s%s: warning: %s [%s]\n", sourcePrefix(source), w.Pos, w.Note, w.Feature)
+	}
+	return nil
+}
+
+// localize describes the parameters of a program in a locale, warning
+// about the ones without a translation.
+func localize(program *ast.Program, locale, source string) *ast.Program {
+	localized, missing := transpiler.Localize(program, locale)
+	for _, param := range missing {
+		fmt.Fprintf(os.Stderr, "%s%s: warning: parameter '%s' has no %s label, its desc is used\n",
+			sourcePrefix(source), param.Pos, param.Name, locale)
+	}
+	return localized
+}
+
+// sourcePrefix returns the "path: " prefix of the messages about a program,
+// which tells programs apart when several are processed at once.
+func sourcePrefix(source string) string {
+	if source == "" {
+		return ""
+	}
+	return source + ": "
+}
+
+// configuredTranspiler returns a transpiler of the target with the
+// templates and options of the configuration and the command line.
+func configuredTranspiler(lang string, descriptor *transpiler.TranspilerDescriptor, cfg *config.Config, opts transpileOptions) (transpiler.Transpiler, error) {
+	t := descriptor.Initializer()
+	if err := applyTemplates(t, lang, cfg); err != nil {
+		return nil, err
+	}
+	if err := applyOptions(t, lang, cfg, opts.options); err != nil {
+		return nil, err
+	}
+	if annotated, ok := t.(transpiler.Annotated); ok && opts.provenance {
+		annotated.SetProvenance(opts.source)
+	}
+	return t, nil
+}
+
+// processDirectory transpiles the programs of a directory to an output
+// directory, together if the target writes suites, e.g. the Galaxy tools
+// sharing a macros.xml, otherwise each to <name><extension>. With several
+// targets, each writes to a subdirectory named after it. The programs are
+// read and analyzed, then transpiled, by jobs tasks at once, and the errors
+// of every program are reported. In check mode the programs are only
+// analyzed. Programs are parsed through cache, which may be nil. The tasks
+// of a target share its transpiler, each annotating its program.
+func processDirectory(dir, outputDir string, langs []string,
+	cfg *config.Config,
+	check bool,
+	jobs int,
+	cache *parsecache.Cache,
+	analysis analysisOptions,
+	opts transpileOptions,
+) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.bala"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no .bala files in %s", dir)
+	}
+
+	programs := make([]*ast.Program, len(paths))
+	if err := build.Run(jobs, len(paths), func(i int) error {
+		path := paths[i]
+		fmt.Printf("Reading: %s\n", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		program, err := decodeProgram(path, data, cache)
+		if err != nil {
+			return fmt.Errorf("%s: parsing error: %w", path, err)
+		}
+		fileAnalysis := analysis
+		fileAnalysis.source = path
+		if err := analyzeProgram(program, cfg, fileAnalysis); err != nil {
+			return fmt.Errorf("%s: semantic error: %w", path, err)
+		}
+		for _, lang := range langs {
+			if err := negotiateFeatures(lang, program, path); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		programs[i] = localize(program, opts.locale, path)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if check {
+		fmt.Printf("✅ Syntax check passed for %d programs\n", len(programs))
+		return nil
+	}
+
+	// A task transpiles a program to a target, or all of them to a target
+	// writing suites
+	type task struct {
+		lang       string
+		descriptor *transpiler.TranspilerDescriptor
+		transpiler transpiler.Transpiler
+		outputDir  string
+		program    int // -1 for suites
+	}
+	tasks := []task{}
+	for _, lang := range langs {
+		descriptor, _ := transpiler.GetTranspiler(lang)
+		targetDir := outputDir
+		if len(langs) > 1 {
+			targetDir = filepath.Join(outputDir, lang)
+		}
+		fmt.Printf("Transpiling to %s...\n", descriptor.Display)
+		t, err := configuredTranspiler(lang, descriptor, cfg, opts)
+		if err != nil {
+			return err
+		}
+		if _, ok := t.(transpiler.Suite); ok {
+			tasks = append(tasks, task{lang, descriptor, t, targetDir, -1})
+			continue
+		}
+		for i := range programs {
+			tasks = append(tasks, task{lang, descriptor, t, targetDir, i})
+		}
 	}
 
+	if err := build.Run(jobs, len(tasks), func(i int) error {
+		task := tasks[i]
+		t := task.transpiler
+		if task.program < 0 {
+			files, err := t.(transpiler.Suite).TranspileSuite(programs)
+			if err != nil {
+				return fmt.Errorf("%s: transpilation failed: %w", task.lang, err)
+			}
+			paths := []string{}
+			for _, file := range files {
+				path := filepath.Join(task.outputDir, filepath.FromSlash(file.Path))
+				fmt.Printf("Writing: %s\n", path)
+				if err := writeFileSafely(path, []byte(file.Content)); err != nil {
+					return err
+				}
+				paths = append(paths, path)
+			}
+			if opts.verify {
+				return verifyFiles(paths, cfg)
+			}
+			return nil
+		}
+
+		source := paths[task.program]
+		base := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+		path := filepath.Join(task.outputDir, base+task.descriptor.Extension)
+		annotation := &transpiler.Annotation{}
+		if opts.provenance {
+			annotation.Provenance = source
+		}
+		ctx := transpiler.WithAnnotation(context.Background(), annotation)
+		fmt.Printf("Writing: %s\n", path)
+		if err := writeFileWith(path, func(w io.Writer) error {
+			return transpiler.TranspileContext(ctx, t, w, programs[task.program])
+		}); err != nil {
+			if len(langs) > 1 {
+				return fmt.Errorf("%s: %s: transpilation failed: %w", source, task.lang, err)
+			}
+			return fmt.Errorf("%s: transpilation failed: %w", source, err)
+		}
+		if _, ok := t.(transpiler.Annotated); ok && opts.sourceMap {
+			if err := writeSourceMap(path, source, annotation.Mappings); err != nil {
+				return fmt.Errorf("%s: writing source map: %w", source, err)
+			}
+		}
+		if opts.verify {
+			return verifyFiles([]string{path}, cfg)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
 	fmt.Println("✅ Transpilation completed successfully")
 	return nil
 }
@@ -99,8 +463,179 @@ func parseProgram(source string) (*ast.Program, error) {
 	return p.ParseProgram()
 }
 
+// decodeProgram parses a Baryon file, through cache unless it is nil, or
+// decodes it if it is an AST saved as JSON by "baryon ast".
+func decodeProgram(path string, data []byte, cache *parsecache.Cache) (*ast.Program, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		program := &ast.Program{}
+		if err := json.Unmarshal(data, program); err != nil {
+			return nil, fmt.Errorf("decoding AST: %w", err)
+		}
+		return program, nil
+	}
+	return cache.Parse(string(data))
+}
+
+// analysisOptions selects the optional semantic checks.
+type analysisOptions struct {
+	verifyImages bool
+	// source is the path printed before the warnings, see sourcePrefix.
+	source string
+}
+
+// verifyTimeout bounds the check of each generated file by -verify, some
+// tools starting a JVM.
+const verifyTimeout = 2 * time.Minute
+
+// verifyFiles checks the syntax of written files with the tools of their
+// language, see verify.For. Files whose tool isn't installed are skipped
+// with a warning; the errors of every other file are returned together.
+func verifyFiles(paths []string, cfg *config.Config) error {
+	errs := []error{}
+	for _, path := range paths {
+		checker, ok := verify.For(path, verify.Options{GalaxySchema: cfg.Verify.GalaxySchema})
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+		err := checker.Check(ctx, path)
+		cancel()
+		switch {
+		case errors.Is(err, verify.ErrUnavailable):
+			fmt.Fprintf(os.Stderr, "%s: warning: not verified, %v\n", path, err)
+		case err != nil:
+			errs = append(errs, err)
+		default:
+			fmt.Printf("Verified: %s (%s)\n", path, checker.Name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// registryTimeout bounds each request of -verify-images to a container
+// registry, and of "baryon get" to a program registry.
+const registryTimeout = 15 * time.Second
+
+// analyzeProgram runs the semantic checks enabled by the configuration,
+// printing warnings and returning the errors found.
+func analyzeProgram(program *ast.Program, cfg *config.Config, opts analysisOptions) error {
+	analyzer := semantic.New()
+	if opts.verifyImages {
+		registry := image.NewRegistry(registryTimeout)
+		analyzer.RegisterCheck("image-exists", semantic.CheckImagesExist(registry.Exists))
+	}
+	for _, rule := range slices.Sorted(maps.Keys(cfg.Lint.Rules)) {
+		if err := analyzer.SetEnabled(rule, cfg.Lint.Rules[rule]); err != nil {
+			return fmt.Errorf("%s: %w", cfg.Path, err)
+		}
+	}
+	diagnostics := analyzer.Analyze(program)
+	for _, d := range diagnostics {
+		if d.Severity == semantic.SeverityWarning {
+			fmt.Fprintln(os.Stderr, sourcePrefix(opts.source)+d.String())
+		}
+	}
+	return semantic.Error(diagnostics)
+}
+
+// registerPlugins registers the plugin targets found in the directories of
+// BARYON_PLUGIN_PATH and of the configuration, then the ones the
+// configuration declares explicitly.
+func registerPlugins(cfg *config.Config) error {
+	discovered, err := transpiler.DiscoverPlugins(append(transpiler.PluginDirs(), cfg.Plugins.Dirs...))
+	if err != nil {
+		return err
+	}
+	for _, plugin := range discovered {
+		if err := transpiler.RegisterPlugin(plugin); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Plugins.Targets)) {
+		declared := cfg.Plugins.Targets[name]
+		plugin := transpiler.Plugin{
+			Name:      name,
+			Display:   declared.Display,
+			Extension: declared.Extension,
+			Command:   declared.Command,
+			Language:  declared.Language,
+		}
+		if err := transpiler.RegisterPlugin(plugin); err != nil {
+			return fmt.Errorf("%s: %w", cfg.Path, err)
+		}
+	}
+	return nil
+}
+
+// applyTemplates reads the template files configured for a target and
+// sets them on its transpiler.
+func applyTemplates(t transpiler.Transpiler, lang string, cfg *config.Config) error {
+	templates := map[string]string{}
+	for section, path := range cfg.Templates[lang] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading template: %w", err)
+		}
+		templates[section] = string(data)
+	}
+	if err := transpiler.ApplyTemplates(t, lang, templates); err != nil {
+		return fmt.Errorf("%s: %w", cfg.Path, err)
+	}
+	return nil
+}
+
+// applyOptions sets the target options of the configuration on a
+// transpiler, replaced by the ones given on the command line.
+func applyOptions(t transpiler.Transpiler, lang string, cfg *config.Config, overrides map[string]string) error {
+	options := maps.Clone(cfg.Options[lang])
+	if options == nil {
+		options = map[string]string{}
+	}
+	maps.Copy(options, overrides)
+	return transpiler.ApplyOptions(t, lang, options)
+}
+
+// writeSourceMap writes the source map of outputPath to outputPath.map. The
+// program path is written relative to the source map when possible.
+func writeSourceMap(outputPath, source string, mappings []transpiler.SourceMapping) error {
+	mapPath := outputPath + ".map"
+	rel := source
+	if abs, err := filepath.Abs(source); err == nil {
+		rel = abs
+		if dir, err := filepath.Abs(filepath.Dir(mapPath)); err == nil {
+			if r, err := filepath.Rel(dir, abs); err == nil {
+				rel = filepath.ToSlash(r)
+			}
+		}
+	}
+	if mappings == nil {
+		mappings = []transpiler.SourceMapping{}
+	}
+
+	data, err := json.MarshalIndent(transpiler.SourceMap{
+		Version:  transpiler.SourceMapVersion,
+		File:     filepath.Base(outputPath),
+		Source:   rel,
+		Mappings: mappings,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Writing: %s\n", mapPath)
+	return writeFileSafely(mapPath, append(data, '\n'))
+}
+
 // writeFileSafely writes data to a file with appropriate permissions and atomicity
 func writeFileSafely(path string, data []byte) error {
+	return writeFileWith(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileWith streams the output of write to a temporary file, which
+// replaces path only if write succeeds.
+func writeFileWith(path string, write func(w io.Writer) error) error {
 	dir := filepath.Dir(path)
 	if dir != "." {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -108,7 +643,20 @@ func writeFileSafely(path string, data []byte) error {
 		}
 	}
 	tempFile := path + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+	f, err := os.OpenFile(tempFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(f)
+	err = write(buffered)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile)
 		return err
 	}
 	return os.Rename(tempFile, path)
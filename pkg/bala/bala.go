@@ -0,0 +1,335 @@
+// Package bala is the public Go API of baryon-lang. It lets other programs
+// parse, check and transpile Baryon workflows without going through the
+// command line tool.
+//
+//	program, err := bala.Parse(source)
+//	if err != nil { ... }
+//	diagnostics := bala.Analyze(program)
+//	if err := bala.Error(diagnostics); err != nil { ... }
+//	code, err := bala.Transpile(program, "python")
+package bala
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/diff"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/lexer"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/parser"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/transpiler"
+)
+
+// AST types.
+type (
+	Program             = ast.Program
+	Parameter           = ast.Parameter
+	Condition           = ast.Condition
+	ImplementationBlock = ast.ImplementationBlock
+	OutputBlock         = ast.OutputBlock
+	TestBlock           = ast.TestBlock
+	TestParam           = ast.TestParam
+	OutputExpectation   = ast.OutputExpectation
+	Assertion           = ast.Assertion
+	Resource            = ast.Resource
+	Position            = ast.Position
+	Reference           = ast.Reference
+	Literal             = ast.Literal
+	Comment             = ast.Comment
+	Deprecation         = ast.Deprecation
+	Node                = ast.Node
+	Visitor             = ast.Visitor
+)
+
+// ASTSchemaVersion is the version of the JSON encoding of a Program,
+// produced and read by json.Marshal and json.Unmarshal.
+const ASTSchemaVersion = ast.SchemaVersion
+
+// Walk traverses a program in depth-first order, see ast.Walk.
+func Walk(node Node, v Visitor) {
+	ast.Walk(node, v)
+}
+
+// Inspect calls f for every node of a program in depth-first order,
+// descending into the children of a node while f returns true.
+func Inspect(node Node, f func(Node) bool) {
+	ast.Inspect(node, f)
+}
+
+// Built-in parameter types.
+const (
+	TypeString      = ast.TypeString
+	TypeNumber      = ast.TypeNumber
+	TypeInteger     = ast.TypeInteger
+	TypeBoolean     = ast.TypeBoolean
+	TypeEnum        = ast.TypeEnum
+	TypeFile        = ast.TypeFile
+	TypeDirectory   = ast.TypeDirectory
+	TypeCharacter   = ast.TypeCharacter
+	TypeSamplesheet = ast.TypeSamplesheet
+)
+
+// Semantic analysis types.
+type (
+	Analyzer   = semantic.Analyzer
+	Diagnostic = semantic.Diagnostic
+	Severity   = semantic.Severity
+	Reporter   = semantic.Reporter
+	Check      = semantic.Check
+)
+
+const (
+	SeverityError   = semantic.SeverityError
+	SeverityWarning = semantic.SeverityWarning
+)
+
+// Error types. The errors returned by Parse, Error and Transpile can be
+// examined with errors.As, or matched with errors.Is against ErrSyntax,
+// ErrSemantic and ErrUnsupported.
+type (
+	ParseError              = parser.ParseError
+	SemanticError           = semantic.SemanticError
+	UnsupportedFeatureError = transpiler.UnsupportedFeatureError
+)
+
+// ParseErrorCode identifies the kind of a ParseError.
+type ParseErrorCode = parser.Code
+
+const (
+	CodeUnexpectedEOF   = parser.CodeUnexpectedEOF
+	CodeUnclosedList    = parser.CodeUnclosedList
+	CodeMaxDepth        = parser.CodeMaxDepth
+	CodeInvalidProgram  = parser.CodeInvalidProgram
+	CodeUnexpectedToken = parser.CodeUnexpectedToken
+	CodeMalformedBlock  = parser.CodeMalformedBlock
+	CodeUnknownSection  = parser.CodeUnknownSection
+)
+
+var (
+	ErrSyntax      = parser.ErrSyntax
+	ErrSemantic    = semantic.ErrSemantic
+	ErrUnsupported = transpiler.ErrUnsupported
+)
+
+// Target describes a registered transpilation target.
+type Target struct {
+	Name      string // e.g. "python", as passed to Transpile
+	Display   string // e.g. "Python 3"
+	Extension string // extension of the generated file, e.g. ".py"
+	// Capabilities declares the features the target supports, nil for
+	// plugins, whose capabilities are unknown.
+	Capabilities *Capabilities
+	Language     string // DSL version the target implements, e.g. "1.2"
+	// Options lists the target options accepted by TranspileWith.
+	Options []TargetOption
+}
+
+// Target capability types.
+type (
+	Capabilities       = transpiler.Capabilities
+	Limitation         = transpiler.Limitation
+	Support            = transpiler.Support
+	OutputStyle        = transpiler.OutputStyle
+	CompatibilityIssue = transpiler.CompatibilityIssue
+	TargetOption       = transpiler.TargetOption
+)
+
+const (
+	Supported   = transpiler.Supported
+	Degraded    = transpiler.Degraded
+	Unsupported = transpiler.Unsupported
+)
+
+// LanguageVersion is the DSL version implemented by this release.
+const LanguageVersion = transpiler.LanguageVersion
+
+// Negotiate checks a program against the DSL version of a target, as
+// Transpile does: it returns the constructs introduced after that version
+// which the target degrades, and an *UnsupportedFeatureError for the first
+// one it doesn't support.
+func Negotiate(program *Program, target string) ([]CompatibilityIssue, error) {
+	return transpiler.NegotiateFeatures(target, program)
+}
+
+// Parse parses the source of a Baryon program.
+func Parse(source string) (*Program, error) {
+	return ParseContext(context.Background(), source)
+}
+
+// Format prints a program in canonical form, keeping its comments. It
+// fails if the source is not a valid program.
+func Format(src []byte) ([]byte, error) {
+	formatted, err := parser.Format(string(src))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(formatted), nil
+}
+
+// Program comparison types, see Diff.
+type (
+	Change     = diff.Change
+	ChangeKind = diff.Kind
+	Impact     = diff.Impact
+)
+
+const (
+	Added   = diff.Added
+	Removed = diff.Removed
+	Changed = diff.Changed
+
+	Patch = diff.Patch
+	Minor = diff.Minor
+	Major = diff.Major
+)
+
+// Diff returns the interface changes from an old to a new version of a
+// program, each with the version bump it calls for.
+func Diff(old, new *Program) []Change {
+	return diff.Programs(old, new)
+}
+
+// Bump returns the version bump a set of changes calls for, the largest of
+// their impacts, and false when there are no changes.
+func Bump(changes []Change) (Impact, bool) {
+	return diff.Bump(changes)
+}
+
+// Incremental parsing types, see ParseTree.
+type (
+	Tree = parser.Tree
+	Edit = parser.Edit
+)
+
+// ParseTree parses a source into a Tree, which editors update with
+// Tree.Reparse after each edit and convert with Tree.Program.
+func ParseTree(source string) (*Tree, error) {
+	return parser.ParseTree(source)
+}
+
+// ParseContext is like Parse, but returns the error of ctx if it is
+// canceled before the program is parsed.
+func ParseContext(ctx context.Context, source string) (*Program, error) {
+	return parser.New(lexer.New(source)).ParseProgramContext(ctx)
+}
+
+// NewAnalyzer creates an Analyzer with the default checks registered, which
+// can be configured before analyzing a program.
+func NewAnalyzer() *Analyzer {
+	return semantic.New()
+}
+
+// Analyze runs the default checks over a program.
+func Analyze(program *Program) []Diagnostic {
+	return semantic.Analyze(program)
+}
+
+// HasErrors reports whether any diagnostic is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	return semantic.HasErrors(diagnostics)
+}
+
+// Error joins the error diagnostics into a single error, or returns nil if
+// there are none.
+func Error(diagnostics []Diagnostic) error {
+	return semantic.Error(diagnostics)
+}
+
+// Targets lists the registered targets, sorted by name.
+func Targets() []Target {
+	targets := []Target{}
+	for _, name := range transpiler.GetTranspilerNames() {
+		descriptor, _ := transpiler.GetTranspiler(name)
+		target := Target{
+			Name:         name,
+			Display:      descriptor.Display,
+			Extension:    descriptor.Extension,
+			Capabilities: descriptor.Capabilities,
+			Language:     descriptor.DSLVersion(),
+		}
+		if configurable, ok := descriptor.Initializer().(transpiler.Configurable); ok {
+			target.Options = configurable.TargetOptions()
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// Transpile generates the code of a program for a target. The program is
+// expected to have passed Analyze without errors. Constructs introduced
+// after the DSL version of the target fail with an *UnsupportedFeatureError,
+// unless the target degrades them, see Negotiate.
+func Transpile(program *Program, target string) (string, error) {
+	return TranspileWith(program, target, nil)
+}
+
+// TranspileWith is like Transpile, with target options by name, e.g.
+// {"models": "pydantic"} for Python. Target.Options lists them.
+func TranspileWith(program *Program, target string, options map[string]string) (string, error) {
+	descriptor, err := transpiler.GetTranspiler(target)
+	if err != nil {
+		return "", err
+	}
+	if _, err := transpiler.NegotiateFeatures(target, program); err != nil {
+		return "", err
+	}
+	t := descriptor.Initializer()
+	if err := transpiler.ApplyOptions(t, target, options); err != nil {
+		return "", err
+	}
+	code, err := t.Transpile(program)
+	if err != nil {
+		return "", fmt.Errorf("transpilation failed: %w", err)
+	}
+	return code, nil
+}
+
+// TranspileContext is like Transpile, but returns the error of ctx if it
+// is canceled before the code is generated.
+func TranspileContext(ctx context.Context, program *Program, target string) (string, error) {
+	var code strings.Builder
+	if err := TranspileToContext(ctx, &code, program, target); err != nil {
+		return "", err
+	}
+	return code.String(), nil
+}
+
+// TranspileTo writes the code of a program for a target to w as it is
+// generated, without holding it all in memory.
+func TranspileTo(w io.Writer, program *Program, target string) error {
+	return TranspileToContext(context.Background(), w, program, target)
+}
+
+// TranspileToContext is like TranspileTo, but stops with the error of ctx
+// when ctx is canceled; w may then hold part of the code.
+func TranspileToContext(ctx context.Context, w io.Writer, program *Program, target string) error {
+	descriptor, err := transpiler.GetTranspiler(target)
+	if err != nil {
+		return err
+	}
+	if _, err := transpiler.NegotiateFeatures(target, program); err != nil {
+		return err
+	}
+	if err := transpiler.TranspileContext(ctx, descriptor.Initializer(), w, program); err != nil {
+		return fmt.Errorf("transpilation failed: %w", err)
+	}
+	return nil
+}
+
+// Compile parses, analyzes and transpiles a program in one step. The
+// diagnostics are returned even when compilation fails on them.
+func Compile(source, target string) (string, []Diagnostic, error) {
+	program, err := Parse(source)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing error: %w", err)
+	}
+	diagnostics := Analyze(program)
+	if err := Error(diagnostics); err != nil {
+		return "", diagnostics, fmt.Errorf("semantic error: %w", err)
+	}
+	code, err := Transpile(program, target)
+	return code, diagnostics, err
+}
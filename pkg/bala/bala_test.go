@@ -0,0 +1,132 @@
+package bala
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const source = `
+(bala align (
+	(desc "Align reads")
+	(reads file (desc "Input reads"))
+	(threads integer (default 4) (desc "Threads"))
+	(run_docker
+		(image "biocontainers/bwa:0.7.17")
+		(volumes ((reads "/data")))
+		(arguments "bwa" "mem" "-t" threads reads))
+))
+`
+
+func TestParseAnalyzeTranspile(t *testing.T) {
+	program, err := Parse(source)
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if program.Name != "align" || len(program.Parameters) != 2 {
+		t.Fatalf("unexpected program: %s", program)
+	}
+	if diagnostics := Analyze(program); HasErrors(diagnostics) {
+		t.Fatalf("Analyze() unexpected errors: %v", diagnostics)
+	}
+	code, err := Transpile(program, "python")
+	if err != nil {
+		t.Fatalf("Transpile() unexpected error: %v", err)
+	}
+	if !strings.Contains(code, "def align(") {
+		t.Errorf("Transpile() output lacks the function definition:\n%s", code)
+	}
+}
+
+func TestCompile_SemanticError(t *testing.T) {
+	_, diagnostics, err := Compile(strings.Replace(source, "threads reads", "threads raeds", 1), "r")
+	if err == nil || !strings.Contains(err.Error(), "undefined parameter 'raeds'") {
+		t.Errorf("Compile() expected undefined parameter error, got %v", err)
+	}
+	if !HasErrors(diagnostics) {
+		t.Errorf("Compile() expected error diagnostics, got %v", diagnostics)
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	_, err := Parse("(bala align (")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || !errors.Is(err, ErrSyntax) {
+		t.Errorf("Parse() = %v, want a ParseError", err)
+	}
+
+	_, _, err = Compile(strings.Replace(source, "threads reads", "threads raeds", 1), "r")
+	var semErr *SemanticError
+	if !errors.As(err, &semErr) || semErr.Rule != "unresolved-reference" || semErr.Pos.Line == 0 {
+		t.Errorf("Compile() = %v, want an unresolved-reference SemanticError", err)
+	}
+	if errors.Is(err, ErrSyntax) {
+		t.Errorf("Compile() = %v, want no ErrSyntax match", err)
+	}
+
+	program, err := Parse(source)
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	program.Implementations[0].Name = "run_singularity"
+	_, err = Transpile(program, "python")
+	var unsupported *UnsupportedFeatureError
+	if !errors.As(err, &unsupported) || !errors.Is(err, ErrUnsupported) ||
+		unsupported.Feature != "implementation:run_singularity" || unsupported.Target != "python" {
+		t.Errorf("Transpile() = %v, want an UnsupportedFeatureError", err)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	found := false
+	for _, target := range Targets() {
+		if target.Name == "galaxy" && target.Extension != "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Targets() = %v, want galaxy", Targets())
+	}
+	if _, err := Transpile(&Program{}, "cobol"); err == nil {
+		t.Error("Transpile() expected error for unknown target")
+	}
+}
+
+func TestTranspileWith(t *testing.T) {
+	program, err := Parse(source)
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	code, err := TranspileWith(program, "python", map[string]string{"models": "pydantic"})
+	if err != nil {
+		t.Fatalf("TranspileWith() unexpected error: %v", err)
+	}
+	if !strings.Contains(code, "class AlignParameters(BaseModel):") {
+		t.Errorf("TranspileWith() output lacks the parameter model:\n%s", code)
+	}
+	if _, err := TranspileWith(program, "python", map[string]string{"models": "attrs"}); err == nil {
+		t.Error("TranspileWith() expected error for an invalid option value")
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ParseContext(ctx, source); !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseContext() = %v, want context.Canceled", err)
+	}
+	program, err := Parse(source)
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	for _, target := range []string{"r", "python", "bash", "galaxy", "nextflow"} {
+		if _, err := TranspileContext(ctx, program, target); !errors.Is(err, context.Canceled) {
+			t.Errorf("TranspileContext(%q) = %v, want context.Canceled", target, err)
+		}
+	}
+	if _, err := TranspileContext(context.Background(), program, "r"); err != nil {
+		t.Errorf("TranspileContext() unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package bala
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/ast"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/schema"
+	"github.com/reproducible-bioinformatics/baryon-lang/internal/semantic"
+)
+
+// Builder constructs a Program in code, for tools that generate Baryon
+// definitions from other metadata sources. Every call validates its
+// arguments; the errors are collected and returned by Build.
+//
+//	program, err := bala.NewProgram("align").
+//		Describe("Align reads").
+//		Param("reads", bala.TypeFile, bala.Desc("Input reads")).
+//		Param("threads", bala.TypeInteger, bala.Default(4)).
+//		Implementation("run_docker",
+//			bala.Field("image", "biocontainers/bwa:0.7.17"),
+//			bala.Field("volumes", bala.Pair{bala.Ref("reads"), "/data"}),
+//			bala.Field("arguments", "bwa", "mem", "-t", bala.Ref("threads"), bala.Ref("reads"))).
+//		Build()
+type Builder struct {
+	program *Program
+	errs    []error
+}
+
+// Ref is a reference to a parameter or keyword in an implementation field,
+// written unquoted in the source.
+type Ref string
+
+// Pair is an element of a key-value field such as volumes or env.
+type Pair [2]any
+
+// Option sets a property of a parameter or output.
+type Option struct {
+	key   string
+	value any
+}
+
+// Desc sets the description of a parameter or output.
+func Desc(text string) Option {
+	return Option{key: "desc", value: text}
+}
+
+// Default sets the default value of a parameter: a string, a number or a
+// boolean.
+func Default(value any) Option {
+	return Option{key: "default", value: value}
+}
+
+// When makes a parameter depend on another one: it is only used when the
+// enum or boolean parameter param has one of the values.
+func When(param string, values ...any) Option {
+	return Option{key: "when", value: &ast.Condition{Param: param, Values: values}}
+}
+
+// Meta sets a metadata entry of a parameter or output, such as its label.
+func Meta(key, value string) Option {
+	return Option{key: key, value: value}
+}
+
+// ImplementationField is a field of an implementation block, see Field.
+type ImplementationField struct {
+	name   string
+	values []any
+}
+
+// Field creates an implementation field. String fields take a single
+// string, list fields strings and Refs, key-value fields Pairs.
+func Field(name string, values ...any) ImplementationField {
+	return ImplementationField{name: name, values: values}
+}
+
+// NewProgram starts building a program.
+func NewProgram(name string) *Builder {
+	b := &Builder{program: &Program{
+		NamedBaseNode:   ast.NamedBaseNode{Name: name},
+		Parameters:      []Parameter{},
+		Implementations: []ImplementationBlock{},
+		Metadata:        map[string]string{},
+	}}
+	if !validName(name) {
+		b.errorf("invalid program name '%s'", name)
+	}
+	return b
+}
+
+// Describe sets the description of the program.
+func (b *Builder) Describe(text string) *Builder {
+	b.program.Description = text
+	return b
+}
+
+// Meta sets a metadata entry of the program.
+func (b *Builder) Meta(key, value string) *Builder {
+	b.program.Metadata[key] = value
+	return b
+}
+
+// Param adds a parameter of a built-in type. Enum parameters are added with
+// Enum.
+func (b *Builder) Param(name, typ string, opts ...Option) *Builder {
+	if typ == TypeEnum {
+		b.errorf("parameter '%s': enum parameters must be added with Enum", name)
+		return b
+	}
+	if !slices.Contains(ast.Types, typ) {
+		b.errorf("parameter '%s': unknown type '%s', expected one of %s",
+			name, typ, strings.Join(ast.Types, ", "))
+		return b
+	}
+	b.addParam(name, typ, nil, opts)
+	return b
+}
+
+// Enum adds a parameter that takes one of the given values.
+func (b *Builder) Enum(name string, values []string, opts ...Option) *Builder {
+	if len(values) == 0 {
+		b.errorf("parameter '%s': an enum needs at least one value", name)
+		return b
+	}
+	constraints := make([]any, len(values))
+	for i, value := range values {
+		constraints[i] = value
+	}
+	b.addParam(name, TypeEnum, constraints, opts)
+	return b
+}
+
+func (b *Builder) addParam(name, typ string, constraints []any, opts []Option) {
+	if !validName(name) {
+		b.errorf("invalid parameter name '%s'", name)
+		return
+	}
+	if slices.ContainsFunc(b.program.Parameters, func(p Parameter) bool { return p.Name == name }) {
+		b.errorf("parameter '%s' is declared twice", name)
+		return
+	}
+
+	param := Parameter{
+		NamedBaseNode: ast.NamedBaseNode{Name: name},
+		Type:          typ,
+		Constraints:   constraints,
+		Metadata:      map[string]string{},
+	}
+	for _, opt := range opts {
+		switch value := opt.value.(type) {
+		case *ast.Condition:
+			param.When = value
+		case string:
+			param.Metadata[opt.key] = value
+			if opt.key == "desc" {
+				param.Description = value
+			} else if opt.key == "default" {
+				param.Default = value
+			}
+		case bool, int, float64:
+			if opt.key != "default" {
+				b.errorf("parameter '%s': metadata '%s' must be a string", name, opt.key)
+				continue
+			}
+			param.Default = value
+			param.Metadata["default"] = fmt.Sprint(value)
+		default:
+			b.errorf("parameter '%s': unsupported %s value %v of type %T", name, opt.key, value, value)
+		}
+	}
+	b.program.Parameters = append(b.program.Parameters, param)
+}
+
+// Implementation adds an implementation block, whose fields are checked
+// against the schema of the block.
+func (b *Builder) Implementation(name string, fields ...ImplementationField) *Builder {
+	implSchema, ok := schema.Lookup(name)
+	if !ok {
+		b.errorf("unknown implementation block '%s', expected one of %s",
+			name, strings.Join(schema.Names(), ", "))
+		return b
+	}
+
+	block := ImplementationBlock{
+		Name:     name,
+		Fields:   map[string]any{},
+		FieldPos: map[string]Position{},
+	}
+	for _, f := range fields {
+		field, ok := implSchema.Field(f.name)
+		if !ok {
+			b.errorf("unknown field '%s' in '%s', expected one of %s",
+				f.name, name, strings.Join(implSchema.FieldNames(), ", "))
+			continue
+		}
+		if _, ok := block.Fields[f.name]; ok {
+			b.errorf("field '%s' of '%s' is set twice", f.name, name)
+			continue
+		}
+		if err := setField(&block, field, f.values); err != nil {
+			b.errs = append(b.errs, fmt.Errorf("field '%s' of '%s': %w", f.name, name, err))
+		}
+	}
+	for _, field := range implSchema.Fields {
+		if _, ok := block.Fields[field.Name]; field.Required && !ok {
+			b.errorf("missing required field '%s' in '%s'", field.Name, name)
+		}
+	}
+	b.program.Implementations = append(b.program.Implementations, block)
+	return b
+}
+
+// setField stores the values of a field in the shape the parser produces,
+// recording the references and literals.
+func setField(block *ImplementationBlock, field schema.Field, values []any) error {
+	switch field.Kind {
+	case schema.FieldString:
+		if len(values) != 1 {
+			return errors.New("expected a single string")
+		}
+		text, ok := values[0].(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %v of type %T", values[0], values[0])
+		}
+		block.Fields[field.Name] = text
+	case schema.FieldList:
+		items := []any{}
+		for _, value := range values {
+			item, err := fieldValue(block, field.Name, value, true)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		block.Fields[field.Name] = items
+	case schema.FieldPairs:
+		pairs := []any{}
+		for _, value := range values {
+			pair, ok := value.(Pair)
+			if !ok {
+				return fmt.Errorf("expected Pair values, got %v of type %T", value, value)
+			}
+			entry := []any{}
+			for i, element := range pair {
+				item, err := fieldValue(block, field.Name, element, i == field.PairReference)
+				if err != nil {
+					return err
+				}
+				entry = append(entry, item)
+			}
+			if field.Templates && len(entry) > 0 {
+				// The placeholders of templates reference parameters
+				for _, name := range schema.Placeholders(entry[len(entry)-1].(string)) {
+					block.References = append(block.References, Reference{Name: name, Field: field.Name})
+				}
+			}
+			pairs = append(pairs, entry)
+		}
+		block.Fields[field.Name] = pairs
+	}
+	return nil
+}
+
+// fieldValue converts a string or Ref to its field value.
+func fieldValue(block *ImplementationBlock, field string, value any, allowRef bool) (string, error) {
+	switch v := value.(type) {
+	case string:
+		block.Literals = append(block.Literals, Literal{Value: v, Field: field})
+		return v, nil
+	case Ref:
+		if !allowRef {
+			return "", fmt.Errorf("reference '%s' is not allowed in this position", v)
+		}
+		block.References = append(block.References, Reference{Name: string(v), Field: field})
+		return string(v), nil
+	}
+	return "", fmt.Errorf("expected a string or Ref, got %v of type %T", value, value)
+}
+
+// Output adds an output of the program.
+func (b *Builder) Output(name, format, path string, opts ...Option) *Builder {
+	if !validName(name) {
+		b.errorf("invalid output name '%s'", name)
+		return b
+	}
+	output := OutputBlock{
+		NamedBaseNode: ast.NamedBaseNode{Name: name},
+		Format:        format,
+		Path:          path,
+		Metadata:      map[string]string{},
+	}
+	for _, opt := range opts {
+		value, ok := opt.value.(string)
+		if !ok || opt.key == "default" {
+			b.errorf("output '%s': unsupported %s value %v", name, opt.key, opt.value)
+			continue
+		}
+		output.Metadata[opt.key] = value
+		if opt.key == "desc" {
+			output.Description = value
+		}
+	}
+	b.program.Outputs = append(b.program.Outputs, output)
+	return b
+}
+
+// Resource requests a compute resource for the program, e.g. ("cpus", 4)
+// or ("memory", "8 GB").
+func (b *Builder) Resource(name string, value any) *Builder {
+	switch value.(type) {
+	case string, int, float64:
+	default:
+		b.errorf("resource '%s': unsupported value %v", name, value)
+		return b
+	}
+	b.program.Resources = append(b.program.Resources, Resource{Name: name, Value: value})
+	return b
+}
+
+// Build returns the program, or the errors found while building it. The
+// program is also analyzed with the default checks, and their errors are
+// returned as well.
+func (b *Builder) Build() (*Program, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	errs := []error{}
+	for _, d := range semantic.Analyze(b.program) {
+		if d.Severity == SeverityError {
+			// Built programs have no source positions
+			errs = append(errs, fmt.Errorf("%s [%s]", d.Message, d.Rule))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return b.program, nil
+}
+
+func (b *Builder) errorf(format string, args ...any) {
+	b.errs = append(b.errs, fmt.Errorf(format, args...))
+}
+
+// validName reports whether a name can be written as an identifier.
+func validName(name string) bool {
+	for i, c := range name {
+		letter := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+		if !letter && (i == 0 || !('0' <= c && c <= '9' || c == '-' || c == '.')) {
+			return false
+		}
+	}
+	return name != ""
+}
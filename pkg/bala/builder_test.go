@@ -0,0 +1,126 @@
+package bala
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	program, err := NewProgram("align").
+		Describe("Align reads").
+		Param("reads", TypeFile, Desc("Input reads")).
+		Param("threads", TypeInteger, Default(4), Desc("Threads")).
+		Enum("mode", []string{"fast", "sensitive"}, Default("fast")).
+		Implementation("run_docker",
+			Field("image", "biocontainers/bwa:0.7.17"),
+			Field("volumes", Pair{Ref("reads"), "/data"}),
+			Field("arguments", "bwa", "mem", "-t", Ref("threads"), Ref("mode"), Ref("reads"))).
+		Output("aligned", "file", "/data/aligned.bam", Desc("Alignments")).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() unexpected error: %v", err)
+	}
+
+	if len(program.Parameters) != 3 || program.Parameters[1].Default != 4 {
+		t.Errorf("unexpected parameters: %+v", program.Parameters)
+	}
+	if len(program.Implementations[0].References) != 4 {
+		t.Errorf("unexpected references: %+v", program.Implementations[0].References)
+	}
+
+	// A built program transpiles like a parsed one
+	parsed, err := Parse(`
+(bala align (
+	(desc "Align reads")
+	(reads file (desc "Input reads"))
+	(threads integer (default 4) (desc "Threads"))
+	(mode (enum ("fast" "sensitive")) (default "fast"))
+	(run_docker
+		(image "biocontainers/bwa:0.7.17")
+		(volumes ((reads "/data")))
+		(arguments "bwa" "mem" "-t" threads mode reads))
+	(outputs (aligned file "/data/aligned.bam" (desc "Alignments")))
+))`)
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	for _, target := range []string{"r", "python", "bash"} {
+		built, err := Transpile(program, target)
+		if err != nil {
+			t.Fatalf("Transpile(%s) unexpected error: %v", target, err)
+		}
+		want, _ := Transpile(parsed, target)
+		if built != want {
+			t.Errorf("Transpile(%s) of the built program differs from the parsed one:\n%s\nwant:\n%s", target, built, want)
+		}
+	}
+}
+
+func TestBuilder_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{
+			"invalid name",
+			NewProgram("1align"),
+			"invalid program name '1align'",
+		},
+		{
+			"unknown type",
+			NewProgram("p").Param("reads", "fastq"),
+			"unknown type 'fastq'",
+		},
+		{
+			"duplicate parameter",
+			NewProgram("p").Param("reads", TypeFile).Param("reads", TypeString),
+			"parameter 'reads' is declared twice",
+		},
+		{
+			"empty enum",
+			NewProgram("p").Enum("mode", nil),
+			"an enum needs at least one value",
+		},
+		{
+			"unknown field",
+			NewProgram("p").Implementation("run_docker", Field("image", "ubuntu:22.04"), Field("imagee", "x")),
+			"unknown field 'imagee'",
+		},
+		{
+			"missing image",
+			NewProgram("p").Implementation("run_docker", Field("arguments", "ls")),
+			"missing required field 'image'",
+		},
+		{
+			"reference in guest path",
+			NewProgram("p").Param("dir", TypeDirectory).
+				Implementation("run_docker", Field("image", "ubuntu:22.04"), Field("volumes", Pair{Ref("dir"), Ref("dir")})),
+			"reference 'dir' is not allowed",
+		},
+		{
+			"undefined reference",
+			NewProgram("p").Implementation("run_docker", Field("image", "ubuntu:22.04"), Field("arguments", Ref("reads"))),
+			"undefined parameter 'reads' in arguments of 'run_docker' [unresolved-reference]",
+		},
+		{
+			"default of wrong type",
+			NewProgram("p").Param("threads", TypeInteger, Default("four")),
+			"[default-type]",
+		},
+		{
+			"condition on an undefined parameter",
+			NewProgram("p").Param("seed", TypeInteger, When("mode", "sensitive")),
+			"depends on the undefined parameter 'mode' [condition]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Build() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}